@@ -0,0 +1,692 @@
+package claudetool
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/tgruben-circuit/percy/llm"
+	"sketch.dev/claudetool/editbuf"
+	"sketch.dev/claudetool/patchkit"
+)
+
+// ApplyDiffTool applies a unified diff that may create, modify, delete and
+// rename many files at once. Either every change is applied or none is.
+type ApplyDiffTool struct {
+	// WorkingDir is the shared mutable working directory.
+	WorkingDir *MutableWorkingDir
+	// Checkpointer, if set, saves each file's contents before it is changed.
+	Checkpointer Checkpointer
+}
+
+const (
+	applyDiffName        = "apply_diff"
+	applyDiffDescription = `Apply a unified diff, such as the output of git diff, to files in the working directory.
+
+One diff can create, modify, delete and rename any number of files, which makes it the cheapest way to make the same kind of change across many files. Prefer the patch tool for small edits to a single file.
+
+Write a standard unified diff:
+- Start each file with "--- a/<path>" and "+++ b/<path>" lines (a "diff --git" line before them is optional). Paths are relative to the working directory.
+- Use /dev/null as the old path to create a file, and as the new path to delete one.
+- Rename with "diff --git a/<old> b/<new>" followed by "rename from <old>" and "rename to <new>", plus any hunks to apply to the renamed file.
+- Each hunk starts with "@@ -<old line>,<count> +<new line>,<count> @@" and its lines start with " " (context), "-" (removed) or "+" (added). Include a few lines of context around each change.
+
+Hunks are located by their context, so line numbers and counts need not be exact, and small whitespace differences are tolerated. If any hunk cannot be located, no file is changed and the failures are reported.`
+
+	applyDiffInputSchema = `{
+  "type": "object",
+  "required": ["diff"],
+  "properties": {
+    "diff": {
+      "type": "string",
+      "description": "Unified diff to apply"
+    }
+  }
+}`
+)
+
+type applyDiffInput struct {
+	Diff string `json:"diff"`
+}
+
+// Tool returns an llm.Tool for applying unified diffs.
+func (a *ApplyDiffTool) Tool() *llm.Tool {
+	return &llm.Tool{
+		Name:        applyDiffName,
+		Description: applyDiffDescription,
+		InputSchema: llm.MustSchema(applyDiffInputSchema),
+		Run:         a.Run,
+	}
+}
+
+// Run applies the diff in m.
+func (a *ApplyDiffTool) Run(ctx context.Context, m json.RawMessage) llm.ToolOut {
+	var input applyDiffInput
+	if err := json.Unmarshal(m, &input); err != nil {
+		return llm.ErrorfToolOut("failed to unmarshal apply_diff input: %w", err)
+	}
+	patches, err := parseUnifiedDiff(input.Diff)
+	if err != nil {
+		return llm.ErrorToolOut(err)
+	}
+
+	wd := a.WorkingDir.Get()
+	changes, report, err := planFilePatches(ctx, patches, wd)
+	if err != nil {
+		return llm.ErrorToolOut(err)
+	}
+
+	paths := make([]string, len(changes))
+	for i, c := range changes {
+		paths[i] = c.path
+	}
+	saveCheckpoint(ctx, a.Checkpointer, paths)
+	if err := writeDiffChanges(changes); err != nil {
+		return llm.ErrorfToolOut("no changes were made: %w", err)
+	}
+
+	return llm.ToolOut{LLMContent: llm.TextContent("<diff_applied>\n" + strings.Join(report, "\n") + "\n</diff_applied>")}
+}
+
+// filePatch is the part of a unified diff that applies to one file.
+type filePatch struct {
+	oldPath, newPath string // "" for /dev/null
+	hunks            []diffHunk
+}
+
+// diffHunk is one @@ section of a file patch.
+type diffHunk struct {
+	header   string
+	oldStart int // 1-based; the line after which to insert if old is empty
+	old, new string
+}
+
+// hunkLine is a line of a hunk body, including its newline.
+type hunkLine struct {
+	kind byte // ' ', '-' or '+'
+	text string
+}
+
+var hunkHeaderRe = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// parseUnifiedDiff splits a unified diff into per-file patches. It accepts
+// both plain and git-style diffs. Hunk line counts are used when they are
+// right but not required to be.
+func parseUnifiedDiff(text string) ([]filePatch, error) {
+	lines := strings.SplitAfter(strings.TrimRight(text, "\n")+"\n", "\n")
+	lines = lines[:len(lines)-1] // SplitAfter leaves an empty final element
+
+	var patches []filePatch
+	var cur *parsedFile
+	flush := func() error {
+		if cur == nil {
+			return nil
+		}
+		fp, err := cur.filePatch()
+		if err != nil {
+			return err
+		}
+		patches = append(patches, fp)
+		cur = nil
+		return nil
+	}
+	isFileStart := func(i int) bool {
+		return strings.HasPrefix(lines[i], "diff --git ") ||
+			strings.HasPrefix(lines[i], "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ ")
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], "\r\n")
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			cur = &parsedFile{}
+			cur.gitOld, cur.gitNew = splitGitDiffPaths(strings.TrimPrefix(line, "diff --git "))
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			if cur == nil || cur.hasFileLines || len(cur.hunks) > 0 {
+				if err := flush(); err != nil {
+					return nil, err
+				}
+				cur = &parsedFile{}
+			}
+			cur.hasFileLines = true
+			cur.minusPath = headerPath(line[len("--- "):])
+			cur.plusPath = headerPath(strings.TrimRight(lines[i+1], "\r\n")[len("+++ "):])
+			i++
+		case strings.HasPrefix(line, "@@"):
+			if cur == nil {
+				return nil, fmt.Errorf("hunk %q appears before any file header", line)
+			}
+			h, next, err := parseHunk(lines, i, isFileStart)
+			if err != nil {
+				return nil, err
+			}
+			cur.hunks = append(cur.hunks, h)
+			i = next - 1
+		case cur != nil && len(cur.hunks) == 0:
+			// Extended git header lines.
+			switch {
+			case strings.HasPrefix(line, "rename from "):
+				cur.renameFrom = unquotePath(strings.TrimPrefix(line, "rename from "))
+			case strings.HasPrefix(line, "rename to "):
+				cur.renameTo = unquotePath(strings.TrimPrefix(line, "rename to "))
+			case strings.HasPrefix(line, "new file mode"):
+				cur.created = true
+			case strings.HasPrefix(line, "deleted file mode"):
+				cur.deleted = true
+			case strings.HasPrefix(line, "Binary files ") || line == "GIT binary patch":
+				return nil, fmt.Errorf("binary diffs are not supported")
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	if len(patches) == 0 {
+		return nil, fmt.Errorf("no file changes found in diff; expected \"--- a/<path>\" and \"+++ b/<path>\" headers")
+	}
+	return patches, nil
+}
+
+// parsedFile collects the headers of a file patch while it is parsed.
+type parsedFile struct {
+	gitOld, gitNew       string // from the diff --git line
+	minusPath, plusPath  string // from the ---/+++ lines; "/dev/null" is kept
+	hasFileLines         bool
+	renameFrom, renameTo string
+	created, deleted     bool
+	hunks                []diffHunk
+}
+
+func (p *parsedFile) filePatch() (filePatch, error) {
+	oldPath, newPath := p.gitOld, p.gitNew
+	if p.hasFileLines {
+		oldPath, newPath = p.minusPath, p.plusPath
+	}
+	// Strip git's a/ and b/ prefixes, unless the diff was made without them.
+	if (oldPath == "/dev/null" || strings.HasPrefix(oldPath, "a/")) &&
+		(newPath == "/dev/null" || strings.HasPrefix(newPath, "b/")) {
+		oldPath = strings.TrimPrefix(oldPath, "a/")
+		newPath = strings.TrimPrefix(newPath, "b/")
+	}
+	if oldPath == "/dev/null" || p.created {
+		oldPath = ""
+	}
+	if newPath == "/dev/null" || p.deleted {
+		newPath = ""
+	}
+	if p.renameFrom != "" && p.renameTo != "" {
+		oldPath, newPath = p.renameFrom, p.renameTo
+	}
+	if oldPath == "" && newPath == "" {
+		return filePatch{}, fmt.Errorf("file patch has no path")
+	}
+	fp := filePatch{oldPath: oldPath, newPath: newPath, hunks: p.hunks}
+	if len(fp.hunks) == 0 && (oldPath == "" || oldPath == newPath) {
+		return filePatch{}, fmt.Errorf("%s: no hunks", fp.displayPath())
+	}
+	return fp, nil
+}
+
+func (fp *filePatch) displayPath() string {
+	if fp.newPath != "" {
+		return fp.newPath
+	}
+	return fp.oldPath
+}
+
+// parseHunk parses the hunk whose header is lines[i] and returns it with the
+// index of the first line after it.
+func parseHunk(lines []string, i int, isFileStart func(int) bool) (diffHunk, int, error) {
+	header := strings.TrimRight(lines[i], "\r\n")
+	h := diffHunk{header: header}
+	oldCount, newCount := -1, -1
+	if m := hunkHeaderRe.FindStringSubmatch(header); m != nil {
+		h.oldStart, _ = strconv.Atoi(m[1])
+		oldCount, newCount = 1, 1
+		if m[2] != "" {
+			oldCount, _ = strconv.Atoi(m[2])
+		}
+		if m[4] != "" {
+			newCount, _ = strconv.Atoi(m[4])
+		}
+	} else if header != "@@" && !strings.HasPrefix(header, "@@ ") {
+		return diffHunk{}, 0, fmt.Errorf("malformed hunk header %q", header)
+	}
+	// A bare "@@" has no line numbers; its context alone locates it.
+
+	var body []hunkLine
+	j := i + 1
+	for ; j < len(lines); j++ {
+		line := lines[j]
+		counted := oldCount > 0 || newCount > 0
+		if !counted && (strings.HasPrefix(line, "@@") || isFileStart(j)) {
+			break
+		}
+		if strings.HasPrefix(line, `\`) {
+			// "\ No newline at end of file" applies to the previous line.
+			if n := len(body); n > 0 {
+				body[n-1].text = strings.TrimSuffix(strings.TrimSuffix(body[n-1].text, "\n"), "\r")
+			}
+			continue
+		}
+		kind := byte(' ')
+		text := "\n"
+		if line != "\n" && line != "\r\n" {
+			kind, text = line[0], line[1:]
+			if kind != ' ' && kind != '-' && kind != '+' {
+				break
+			}
+		}
+		body = append(body, hunkLine{kind: kind, text: text})
+		if kind != '+' {
+			oldCount--
+		}
+		if kind != '-' {
+			newCount--
+		}
+	}
+	// Blank lines that end the diff or separate files are not context.
+	for len(body) > 0 && body[len(body)-1].kind == ' ' && body[len(body)-1].text == "\n" && oldCount < 0 {
+		body = body[:len(body)-1]
+		oldCount++
+		newCount++
+	}
+	if len(body) == 0 {
+		return diffHunk{}, 0, fmt.Errorf("hunk %q is empty", header)
+	}
+
+	var old, new strings.Builder
+	for _, l := range body {
+		if l.kind != '+' {
+			old.WriteString(l.text)
+		}
+		if l.kind != '-' {
+			new.WriteString(l.text)
+		}
+	}
+	h.old, h.new = old.String(), new.String()
+	return h, j, nil
+}
+
+// splitGitDiffPaths splits the "a/x b/y" part of a diff --git line.
+func splitGitDiffPaths(s string) (string, string) {
+	if strings.HasPrefix(s, `"`) {
+		if old, rest, ok := cutQuoted(s); ok {
+			return old, unquotePath(strings.TrimSpace(rest))
+		}
+	}
+	if i := strings.Index(s, " b/"); i >= 0 {
+		return s[:i], s[i+1:]
+	}
+	if old, new, ok := strings.Cut(s, " "); ok {
+		return old, new
+	}
+	return s, s
+}
+
+// cutQuoted returns the leading quoted string in s, unquoted, and the rest.
+func cutQuoted(s string) (string, string, bool) {
+	for i := 1; i < len(s); i++ {
+		if s[i] == '\\' {
+			i++
+			continue
+		}
+		if s[i] == '"' {
+			unq, err := strconv.Unquote(s[:i+1])
+			return unq, s[i+1:], err == nil
+		}
+	}
+	return "", "", false
+}
+
+// headerPath returns the path in a ---/+++ line, without any timestamp.
+func headerPath(s string) string {
+	s = strings.TrimRight(s, "\r\n")
+	if strings.HasPrefix(s, `"`) {
+		if p, _, ok := cutQuoted(s); ok {
+			return p
+		}
+	}
+	if i := strings.IndexByte(s, '\t'); i >= 0 {
+		s = s[:i]
+	}
+	return strings.TrimSpace(s)
+}
+
+func unquotePath(s string) string {
+	if strings.HasPrefix(s, `"`) {
+		if p, err := strconv.Unquote(s); err == nil {
+			return p
+		}
+	}
+	return s
+}
+
+// diffChange is the planned new state of one file.
+type diffChange struct {
+	path    string
+	existed bool
+	mode    fs.FileMode
+	old     []byte // contents before the change, if it existed
+	new     []byte
+	remove  bool // delete the file instead of writing new
+}
+
+// planFilePatches computes every file change the patches make, without
+// writing anything. It reports all hunks that fail rather than stopping at
+// the first. The report has one line per file patch.
+func planFilePatches(ctx context.Context, patches []filePatch, wd string) ([]diffChange, []string, error) {
+	resolve := func(p string) string {
+		if filepath.IsAbs(p) {
+			return filepath.Clean(p)
+		}
+		return filepath.Join(wd, p)
+	}
+
+	var changes []diffChange
+	var report []string
+	var failures []error
+	touched := make(map[string]bool)
+	claim := func(path string) error {
+		if touched[path] {
+			return fmt.Errorf("%s is changed more than once in the diff", path)
+		}
+		touched[path] = true
+		return nil
+	}
+
+	for _, fp := range patches {
+		name := fp.displayPath()
+		oldAbs, newAbs := "", ""
+		if fp.oldPath != "" {
+			oldAbs = resolve(fp.oldPath)
+			if err := claim(oldAbs); err != nil {
+				failures = append(failures, err)
+				continue
+			}
+		}
+		if fp.newPath != "" && (fp.oldPath == "" || resolve(fp.newPath) != oldAbs) {
+			newAbs = resolve(fp.newPath)
+			if err := claim(newAbs); err != nil {
+				failures = append(failures, err)
+				continue
+			}
+		}
+
+		switch {
+		case fp.oldPath == "":
+			// Create.
+			if _, err := os.Stat(newAbs); err == nil {
+				failures = append(failures, fmt.Errorf("%s: cannot create, file already exists", name))
+				continue
+			}
+			var content strings.Builder
+			for _, h := range fp.hunks {
+				content.WriteString(h.new)
+			}
+			changes = append(changes, diffChange{path: newAbs, mode: 0o600, new: []byte(content.String())})
+			report = append(report, fmt.Sprintf("created %s", name))
+
+		case fp.newPath == "":
+			// Delete.
+			orig, mode, err := readDiffTarget(oldAbs)
+			if err != nil {
+				failures = append(failures, fmt.Errorf("%s: cannot delete: %w", name, err))
+				continue
+			}
+			changes = append(changes, diffChange{path: oldAbs, existed: true, mode: mode, old: orig, remove: true})
+			report = append(report, fmt.Sprintf("deleted %s", name))
+
+		default:
+			// Modify, possibly with a rename.
+			orig, mode, err := readDiffTarget(oldAbs)
+			if err != nil {
+				failures = append(failures, fmt.Errorf("%s: %w", fp.oldPath, err))
+				continue
+			}
+			patched, fuzzy, err := applyHunks(ctx, string(orig), fp.hunks)
+			if err != nil {
+				failures = append(failures, fmt.Errorf("%s: %w", fp.oldPath, err))
+				continue
+			}
+			summary := hunkSummary(len(fp.hunks), fuzzy)
+			if newAbs == "" {
+				changes = append(changes, diffChange{path: oldAbs, existed: true, mode: mode, old: orig, new: []byte(patched)})
+				report = append(report, fmt.Sprintf("modified %s%s", name, summary))
+				continue
+			}
+			if _, err := os.Stat(newAbs); err == nil {
+				failures = append(failures, fmt.Errorf("%s: cannot rename to %s, file already exists", fp.oldPath, fp.newPath))
+				continue
+			}
+			changes = append(changes,
+				diffChange{path: newAbs, mode: mode, new: []byte(patched)},
+				diffChange{path: oldAbs, existed: true, mode: mode, old: orig, remove: true},
+			)
+			report = append(report, fmt.Sprintf("renamed %s -> %s%s", fp.oldPath, fp.newPath, summary))
+		}
+	}
+
+	if len(failures) > 0 {
+		return nil, nil, fmt.Errorf("no changes were made; %d of %d file patches failed:\n%w", len(failures), len(patches), errors.Join(failures...))
+	}
+	return changes, report, nil
+}
+
+func hunkSummary(hunks, fuzzy int) string {
+	if hunks == 0 {
+		return ""
+	}
+	s := fmt.Sprintf(": %d hunk", hunks)
+	if hunks != 1 {
+		s += "s"
+	}
+	if fuzzy > 0 {
+		s += fmt.Sprintf(" (%d matched fuzzily)", fuzzy)
+	}
+	return s
+}
+
+// readDiffTarget reads an existing regular file named in a diff.
+func readDiffTarget(path string) ([]byte, fs.FileMode, error) {
+	fi, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, 0, fmt.Errorf("file does not exist")
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	if !fi.Mode().IsRegular() {
+		return nil, 0, fmt.Errorf("not a regular file")
+	}
+	content, err := os.ReadFile(path)
+	return content, fi.Mode().Perm(), err
+}
+
+// applyHunks applies hunks to orig. Each hunk is placed at the exact match of
+// its old text closest to its stated line; failing that, patchkit's tolerant
+// matchers are tried. It returns the number of hunks matched fuzzily.
+func applyHunks(ctx context.Context, orig string, hunks []diffHunk) (string, int, error) {
+	buf := editbuf.NewBuffer([]byte(orig))
+	fuzzy := 0
+	var errs []error
+	for i, h := range hunks {
+		if h.old == "" {
+			// Pure insertion after line oldStart.
+			buf.Insert(lineOffset(orig, h.oldStart), h.new)
+			continue
+		}
+		if off, ok := nearestLineMatch(orig, h.old, h.oldStart-1); ok {
+			spec := &patchkit.Spec{Off: off, Len: len(h.old), Src: orig, Old: h.old, New: h.new}
+			spec.ApplyToEditBuf(buf)
+			continue
+		}
+		if spec, method := fuzzyMatch(orig, h.old, h.new); spec != nil {
+			slog.DebugContext(ctx, "apply_diff hunk applied", "method", method)
+			spec.ApplyToEditBuf(buf)
+			fuzzy++
+			continue
+		}
+		reason := "context not found"
+		if strings.Count(orig, h.old) > 1 {
+			reason = "context matches more than one place"
+		}
+		errs = append(errs, fmt.Errorf("hunk %d (%s): %s:\n%s", i+1, h.header, reason, h.old))
+	}
+	if len(errs) > 0 {
+		return "", 0, errors.Join(errs...)
+	}
+	patched, err := buf.Bytes()
+	if err != nil {
+		return "", 0, fmt.Errorf("hunks overlap: %w", err)
+	}
+	return string(patched), fuzzy, nil
+}
+
+// fuzzyMatch locates a unique, approximate match of old in orig, in the same
+// order of preference as the patch tool.
+func fuzzyMatch(orig, old, new string) (*patchkit.Spec, string) {
+	if spec, ok := patchkit.UniqueDedent(orig, old, new); ok {
+		spec.New = reindentLines(spec.New, commonIndent(old), commonIndent(spec.Old))
+		return spec, "unique_dedent"
+	}
+	if spec, ok := patchkit.UniqueInValidGo(orig, old, new); ok {
+		return spec, "unique_in_valid_go"
+	}
+	if spec, ok := patchkit.UniqueGoTokens(orig, old, new); ok {
+		return spec, "unique_go_tokens"
+	}
+	if spec, ok := patchkit.UniqueTrim(orig, old, new); ok {
+		return spec, "unique_trim"
+	}
+	return nil, ""
+}
+
+// commonIndent returns the whitespace prefix shared by the non-blank lines
+// of s after the first, whose indentation patchkit disregards.
+func commonIndent(s string) string {
+	var prefix string
+	first := true
+	for i, line := range strings.SplitAfter(s, "\n") {
+		if i == 0 || strings.TrimSpace(line) == "" {
+			continue
+		}
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		if first {
+			prefix, first = indent, false
+			continue
+		}
+		for !strings.HasPrefix(indent, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
+
+// reindentLines replaces the indentation from with to on the lines of s after
+// the first, so that added lines follow the indentation of the context
+// they were matched against.
+func reindentLines(s, from, to string) string {
+	if from == to {
+		return s
+	}
+	lines := strings.SplitAfter(s, "\n")
+	for i := 1; i < len(lines); i++ {
+		if rest, ok := strings.CutPrefix(lines[i], from); ok && strings.TrimSpace(lines[i]) != "" {
+			lines[i] = to + rest
+		}
+	}
+	return strings.Join(lines, "")
+}
+
+// nearestLineMatch returns the offset of the occurrence of needle starting at
+// a line boundary whose line is closest to hintLine (0-based).
+func nearestLineMatch(s, needle string, hintLine int) (int, bool) {
+	best, bestDist := -1, 0
+	line, lineOff := 0, 0 // line number of the offset lineOff
+	for from := 0; from <= len(s); {
+		i := strings.Index(s[from:], needle)
+		if i < 0 {
+			break
+		}
+		off := from + i
+		from = off + 1
+		if off > 0 && s[off-1] != '\n' {
+			continue
+		}
+		line += strings.Count(s[lineOff:off], "\n")
+		lineOff = off
+		dist := line - hintLine
+		if dist < 0 {
+			dist = -dist
+		}
+		if best < 0 || dist < bestDist {
+			best, bestDist = off, dist
+		}
+	}
+	return best, best >= 0
+}
+
+// lineOffset returns the byte offset of the start of line n+1 (that is, the
+// position just after line n, 1-based), clamped to the end of s.
+func lineOffset(s string, n int) int {
+	off := 0
+	for ; n > 0; n-- {
+		i := strings.IndexByte(s[off:], '\n')
+		if i < 0 {
+			return len(s)
+		}
+		off += i + 1
+	}
+	return off
+}
+
+// writeDiffChanges makes the planned changes. If one fails, the changes
+// already made are undone.
+func writeDiffChanges(changes []diffChange) error {
+	for i, c := range changes {
+		var err error
+		if c.remove {
+			err = os.Remove(c.path)
+		} else {
+			err = writeDiffFile(c.path, c.new, c.mode)
+		}
+		if err != nil {
+			for j := i - 1; j >= 0; j-- {
+				undoDiffChange(changes[j])
+			}
+			return fmt.Errorf("%s: %w", c.path, err)
+		}
+	}
+	return nil
+}
+
+func writeDiffFile(path string, content []byte, mode fs.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, content, mode)
+}
+
+func undoDiffChange(c diffChange) {
+	var err error
+	if c.existed {
+		err = writeDiffFile(c.path, c.old, c.mode)
+	} else {
+		err = os.Remove(c.path)
+	}
+	if err != nil {
+		slog.Warn("apply_diff: failed to roll back change", "path", c.path, "error", err)
+	}
+}
@@ -0,0 +1,253 @@
+package claudetool
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func runApplyDiff(t *testing.T, dir, diff string) (string, error) {
+	t.Helper()
+	tool := &ApplyDiffTool{WorkingDir: NewMutableWorkingDir(dir)}
+	msg, err := json.Marshal(applyDiffInput{Diff: diff})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := tool.Run(context.Background(), msg)
+	if out.Error != nil {
+		return "", out.Error
+	}
+	return out.LLMContent[0].Text, nil
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func readFileString(t *testing.T, path string) string {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestApplyDiffMultipleFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a.txt":       "one\ntwo\nthree\nfour\n",
+		"old/name.go": "package x\n\nfunc F() {}\n",
+		"gone.txt":    "bye\n",
+	})
+
+	diff := `diff --git a/a.txt b/a.txt
+index 1111111..2222222 100644
+--- a/a.txt
++++ b/a.txt
+@@ -1,4 +1,4 @@
+ one
+-two
++TWO
+ three
+ four
+diff --git a/new.txt b/new.txt
+new file mode 100644
+--- /dev/null
++++ b/new.txt
+@@ -0,0 +1,2 @@
++hello
++world
+diff --git a/gone.txt b/gone.txt
+deleted file mode 100644
+--- a/gone.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-bye
+diff --git a/old/name.go b/new/name.go
+similarity index 90%
+rename from old/name.go
+rename to new/name.go
+--- a/old/name.go
++++ b/new/name.go
+@@ -1,3 +1,3 @@
+ package x
+ 
+-func F() {}
++func G() {}
+`
+	report, err := runApplyDiff(t, dir, diff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"modified a.txt: 1 hunk", "created new.txt", "deleted gone.txt", "renamed old/name.go -> new/name.go: 1 hunk"} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report missing %q:\n%s", want, report)
+		}
+	}
+
+	if got := readFileString(t, filepath.Join(dir, "a.txt")); got != "one\nTWO\nthree\nfour\n" {
+		t.Errorf("a.txt = %q", got)
+	}
+	if got := readFileString(t, filepath.Join(dir, "new.txt")); got != "hello\nworld\n" {
+		t.Errorf("new.txt = %q", got)
+	}
+	if got := readFileString(t, filepath.Join(dir, "new/name.go")); got != "package x\n\nfunc G() {}\n" {
+		t.Errorf("new/name.go = %q", got)
+	}
+	for _, name := range []string{"gone.txt", "old/name.go"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s should not exist: %v", name, err)
+		}
+	}
+}
+
+func TestApplyDiffAtomic(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a.txt": "alpha\nbeta\n",
+		"b.txt": "gamma\ndelta\n",
+	})
+
+	diff := `--- a/a.txt
++++ b/a.txt
+@@ -1,2 +1,2 @@
+-alpha
++ALPHA
+ beta
+--- a/b.txt
++++ b/b.txt
+@@ -1,2 +1,2 @@
+-epsilon
++EPSILON
+ delta
+`
+	_, err := runApplyDiff(t, dir, diff)
+	if err == nil {
+		t.Fatal("expected failure")
+	}
+	if !strings.Contains(err.Error(), "b.txt") || !strings.Contains(err.Error(), "hunk 1") {
+		t.Errorf("error should name the failing file and hunk: %v", err)
+	}
+	if got := readFileString(t, filepath.Join(dir, "a.txt")); got != "alpha\nbeta\n" {
+		t.Errorf("a.txt was changed despite the failure: %q", got)
+	}
+}
+
+func TestApplyDiffFuzzy(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"f.go": "package f\n\nfunc f() {\n\tif x {\n\t\treturn\n\t}\n}\n",
+	})
+
+	// Wrong line numbers and indentation, as models often produce.
+	diff := `--- f.go
++++ f.go
+@@ -40,3 +40,3 @@
+ if x {
+-	return
++	panic("x")
+ }
+`
+	report, err := runApplyDiff(t, dir, diff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(report, "matched fuzzily") {
+		t.Errorf("report should mention the fuzzy match:\n%s", report)
+	}
+	want := "package f\n\nfunc f() {\n\tif x {\n\t\tpanic(\"x\")\n\t}\n}\n"
+	if got := readFileString(t, filepath.Join(dir, "f.go")); got != want {
+		t.Errorf("f.go = %q, want %q", got, want)
+	}
+}
+
+func TestApplyDiffNearestMatch(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"r.txt": "x\nrepeat\ny\nrepeat\nz\n",
+	})
+
+	diff := `--- a/r.txt
++++ b/r.txt
+@@ -4 +4 @@
+-repeat
++changed
+`
+	if _, err := runApplyDiff(t, dir, diff); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFileString(t, filepath.Join(dir, "r.txt")); got != "x\nrepeat\ny\nchanged\nz\n" {
+		t.Errorf("r.txt = %q", got)
+	}
+}
+
+func TestParseUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name    string
+		diff    string
+		want    []filePatch
+		wantErr string
+	}{
+		{
+			name: "no newline marker",
+			diff: "--- a/f\n+++ b/f\n@@ -1 +1 @@\n-old\n\\ No newline at end of file\n+new\n\\ No newline at end of file\n",
+			want: []filePatch{{oldPath: "f", newPath: "f", hunks: []diffHunk{{header: "@@ -1 +1 @@", oldStart: 1, old: "old", new: "new"}}}},
+		},
+		{
+			name: "wrong counts and blank context",
+			diff: "--- f\n+++ f\n@@ -1,1 +1,1 @@\n a\n\n-b\n+c\n",
+			want: []filePatch{{oldPath: "f", newPath: "f", hunks: []diffHunk{{header: "@@ -1,1 +1,1 @@", oldStart: 1, old: "a\n\nb\n", new: "a\n\nc\n"}}}},
+		},
+		{
+			name:    "no files",
+			diff:    "just some text\n",
+			wantErr: "no file changes",
+		},
+		{
+			name:    "binary",
+			diff:    "diff --git a/x.png b/x.png\nBinary files a/x.png and b/x.png differ\n",
+			wantErr: "binary",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseUnifiedDiff(tt.diff)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d file patches, want %d", len(got), len(tt.want))
+			}
+			for i := range got {
+				g, w := got[i], tt.want[i]
+				if g.oldPath != w.oldPath || g.newPath != w.newPath || len(g.hunks) != len(w.hunks) {
+					t.Fatalf("got %+v, want %+v", g, w)
+				}
+				for j := range g.hunks {
+					if g.hunks[j] != w.hunks[j] {
+						t.Errorf("hunk %d = %+v, want %+v", j, g.hunks[j], w.hunks[j])
+					}
+				}
+			}
+		})
+	}
+}
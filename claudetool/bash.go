@@ -18,6 +18,7 @@ import (
 	"time"
 
 	"github.com/tgruben-circuit/percy/claudetool/bashkit"
+	"github.com/tgruben-circuit/percy/claudetool/sandbox"
 	"github.com/tgruben-circuit/percy/llm"
 )
 
@@ -36,9 +37,22 @@ type BashTool struct {
 	WorkingDir *MutableWorkingDir
 	// LLMProvider provides access to LLM services for tool validation
 	LLMProvider LLMServiceProvider
+	// ModelID is the conversation's model, used for tool validation if
+	// the provider has no route for it.
+	ModelID string
 	// ConversationID is the ID of the conversation this tool belongs to.
 	// It is exposed to invoked commands via PERCY_CONVERSATION_ID.
 	ConversationID string
+	// Checkpointer, if set, saves the files a command is detected to
+	// modify before the command runs.
+	Checkpointer Checkpointer
+	// Jobs runs commands started with background=true. If nil, background
+	// commands are rejected.
+	Jobs *JobManager
+	// Sandbox, if set, runs commands with only the working directory
+	// writable while SandboxEnabled reports true.
+	Sandbox        *sandbox.Sandbox
+	SandboxEnabled func() bool
 }
 
 const (
@@ -100,8 +114,9 @@ const (
 	bashDescription = `Executes shell commands via bash --login -c, returning combined stdout/stderr.
 Bash state changes (working dir, variables, aliases) don't persist between calls.
 
-For long-running processes (servers, watch modes), use tmux instead.
-Do NOT use &, nohup, or disown — the bash tool kills its process group on exit.
+For long-running processes (servers, watch modes), set background=true. The command keeps
+running under supervision; use job_output to read its output or wait for a line, and job_kill to
+stop it. Do NOT use &, nohup, or disown — the bash tool kills its process group on exit.
 
 MUST set slow_ok=true for potentially slow commands: builds, downloads,
 installs, tests, or any other substantive operation.
@@ -128,6 +143,10 @@ For complex scripts, write them to a file first and then execute the file.
     "slow_ok": {
       "type": "boolean",
       "description": "Use extended timeout"
+    },
+    "background": {
+      "type": "boolean",
+      "description": "Run as a background job and return its ID without waiting for it to exit"
     }
   }
 }
@@ -135,13 +154,18 @@ For complex scripts, write them to a file first and then execute the file.
 )
 
 type bashInput struct {
-	Command string `json:"command"`
-	SlowOK  bool   `json:"slow_ok,omitempty"`
+	Command    string `json:"command"`
+	SlowOK     bool   `json:"slow_ok,omitempty"`
+	Background bool   `json:"background,omitempty"`
 }
 
 // BashDisplayData is the display data sent to the UI for bash tool results.
 type BashDisplayData struct {
 	WorkingDir string `json:"workingDir"`
+	// JobID is set for commands started in the background.
+	JobID string `json:"jobId,omitempty"`
+	// Sandboxed is set for commands run in the sandbox.
+	Sandboxed bool `json:"sandboxed,omitempty"`
 }
 
 func (i *bashInput) timeout(t *Timeouts) time.Duration {
@@ -179,8 +203,11 @@ func (b *BashTool) Run(ctx context.Context, m json.RawMessage) llm.ToolOut {
 		}
 	}
 
-	// Check for missing tools and try to install them if needed, best effort only
-	if b.EnableJITInstall {
+	sandboxed := b.sandboxed()
+
+	// Check for missing tools and try to install them if needed, best effort only.
+	// Sandboxed commands must not change the system, so they get no installs.
+	if b.EnableJITInstall && !sandboxed {
 		err := b.checkAndInstallMissingTools(ctx, req.Command)
 		if err != nil {
 			slog.DebugContext(ctx, "failed to auto-install missing tools", "error", err)
@@ -194,7 +221,15 @@ func (b *BashTool) Run(ctx context.Context, m json.RawMessage) llm.ToolOut {
 
 	timeout := req.timeout(b.Timeouts)
 
-	display := BashDisplayData{WorkingDir: wd}
+	display := BashDisplayData{WorkingDir: wd, Sandboxed: sandboxed}
+
+	if b.Checkpointer != nil {
+		saveCheckpoint(ctx, b.Checkpointer, bashCheckpointPaths(ctx, req.Command, wd))
+	}
+
+	if req.Background {
+		return b.startBackground(ctx, req.Command, display)
+	}
 
 	out, execErr := b.executeBash(ctx, req, timeout)
 	if execErr != nil {
@@ -210,7 +245,12 @@ const (
 	maxLineLength        = 200 // truncate displayed lines to this length
 )
 
-func (b *BashTool) makeBashCommand(ctx context.Context, command string, out io.Writer) *exec.Cmd {
+// sandboxed reports whether commands run in the sandbox.
+func (b *BashTool) sandboxed() bool {
+	return b.Sandbox != nil && b.SandboxEnabled != nil && b.SandboxEnabled()
+}
+
+func (b *BashTool) makeBashCommand(ctx context.Context, command string, out io.Writer) (*exec.Cmd, error) {
 	cmd := exec.CommandContext(ctx, "bash", "--login", "-c", command)
 	// Use shared WorkingDir if available, then context, then Pwd fallback
 	cmd.Dir = b.getWorkingDir()
@@ -238,7 +278,12 @@ func (b *BashTool) makeBashCommand(ctx context.Context, command string, out io.W
 		env = append(env, "PERCY_CONVERSATION_ID="+b.ConversationID)
 	}
 	cmd.Env = env
-	return cmd
+	if b.sandboxed() {
+		if err := b.Sandbox.Wrap(cmd, cmd.Dir); err != nil {
+			return nil, err
+		}
+	}
+	return cmd, nil
 }
 
 func cmdWait(cmd *exec.Cmd) error {
@@ -255,13 +300,16 @@ func (b *BashTool) executeBash(ctx context.Context, req bashInput, timeout time.
 	defer cancel()
 
 	output := new(bytes.Buffer)
-	cmd := b.makeBashCommand(execCtx, req.Command, output)
+	cmd, err := b.makeBashCommand(execCtx, req.Command, output)
+	if err != nil {
+		return "", err
+	}
 	cmd.Env = append(cmd.Env, `GIT_SEQUENCE_EDITOR=echo "To do an interactive rebase, run it in a tmux session." && exit 1`)
 	if err := cmd.Start(); err != nil {
 		return "", fmt.Errorf("command failed: %w", err)
 	}
 
-	err := cmdWait(cmd)
+	err = cmdWait(cmd)
 
 	out, formatErr := formatForegroundBashOutput(output.String())
 	if formatErr != nil {
@@ -278,6 +326,39 @@ func (b *BashTool) executeBash(ctx context.Context, req bashInput, timeout time.
 	return out, nil
 }
 
+// startBackground starts command as a background job and reports its
+// startup output.
+func (b *BashTool) startBackground(ctx context.Context, command string, display BashDisplayData) llm.ToolOut {
+	if b.Jobs == nil {
+		return llm.ErrorfToolOut("background jobs are not available; run the command in the foreground")
+	}
+	// The job outlives this tool call, so it must not use ctx. Jobs are
+	// stopped with Job.Kill, which signals the process group. WaitDelay is
+	// kept so that a child that escapes the group and holds the output pipe
+	// open cannot keep the job running after its shell exits.
+	cmd, err := b.makeBashCommand(context.WithoutCancel(ctx), command, nil)
+	if err != nil {
+		return llm.ErrorToolOut(err)
+	}
+	cmd.Cancel = nil
+	job, err := b.Jobs.Start(cmd, command)
+	if err != nil {
+		return llm.ErrorfToolOut("failed to start background job: %w", err)
+	}
+	display.JobID = job.ID
+
+	select {
+	case <-job.Done():
+	case <-time.After(jobStartupWait):
+	case <-ctx.Done():
+	}
+	note := "Use job_output to read its output and job_kill to stop it."
+	if !job.Running() {
+		note = ""
+	}
+	return llm.ToolOut{LLMContent: llm.TextContent(formatJobOutput(job, note, job.readNew())), Display: display}
+}
+
 // formatForegroundBashOutput formats the output of a foreground bash command for display to the agent.
 // If output exceeds largeOutputThreshold, it saves to a file and returns a summary.
 func formatForegroundBashOutput(out string) (string, error) {
@@ -431,7 +512,7 @@ func (b *BashTool) installTool(ctx context.Context, cmd string) error {
 	if b.LLMProvider == nil {
 		return fmt.Errorf("no LLM provider available for tool validation")
 	}
-	llmService, err := b.selectBestLLM()
+	llmService, err := serviceForTask(b.LLMProvider, llm.TaskToolInstall, b.ModelID)
 	if err != nil {
 		return fmt.Errorf("failed to get LLM service for tool validation: %w", err)
 	}
@@ -549,28 +630,3 @@ func (b *BashTool) installPackage(ctx context.Context, cmd, packageName, package
 	slog.InfoContext(ctx, "tool installation successful", "tool", cmd, "package", packageName)
 	return nil
 }
-
-// selectBestLLM selects the best available LLM service for bash tool validation
-func (b *BashTool) selectBestLLM() (llm.Service, error) {
-	if b.LLMProvider == nil {
-		return nil, fmt.Errorf("no LLM provider available")
-	}
-
-	// Preferred models in order of preference for tool validation (fast, cheap models preferred)
-	preferredModels := []string{"qwen3-coder-fireworks", "gpt-5-thinking-mini", "gpt5-mini", "claude-sonnet-4.5", "predictable"}
-
-	for _, model := range preferredModels {
-		svc, err := b.LLMProvider.GetService(model)
-		if err == nil {
-			return svc, nil
-		}
-	}
-
-	// If no preferred model is available, try any available model
-	available := b.LLMProvider.GetAvailableModels()
-	if len(available) > 0 {
-		return b.LLMProvider.GetService(available[0])
-	}
-
-	return nil, fmt.Errorf("no LLM services available")
-}
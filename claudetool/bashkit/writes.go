@@ -0,0 +1,289 @@
+package bashkit
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// WrittenFiles describes the files a bash script is likely to modify.
+type WrittenFiles struct {
+	// Paths are files the script writes, moves or deletes, as written in
+	// the script. Relative paths are relative to the script's starting
+	// directory; a literal cd earlier in the script is taken into account.
+	Paths []string
+	// Recursive are directories the script may delete or move as a whole.
+	Recursive []string
+	// GitWorktree is set if the script runs a git command that can
+	// overwrite or delete uncommitted changes, such as git checkout or
+	// git reset --hard.
+	GitWorktree bool
+}
+
+// Empty reports whether no writes were detected.
+func (w WrittenFiles) Empty() bool {
+	return len(w.Paths) == 0 && len(w.Recursive) == 0 && !w.GitWorktree
+}
+
+// FindWrittenFiles inspects bashScript for commands that modify files:
+// output redirections, common file utilities (rm, mv, cp, tee, sed -i, ...)
+// and git commands that discard local changes. Only literal arguments are
+// reported; paths built from variables or command substitutions are missed.
+// Like Check, this is a best-effort heuristic.
+func FindWrittenFiles(bashScript string) (WrittenFiles, error) {
+	parser := syntax.NewParser()
+	file, err := parser.Parse(strings.NewReader(bashScript), "")
+	if err != nil {
+		return WrittenFiles{}, fmt.Errorf("failed to parse bash command: %w", err)
+	}
+
+	var w WrittenFiles
+	dir := ""
+	add := func(list *[]string, p string) {
+		if p == "" || p == "-" || strings.HasPrefix(p, "/dev/") {
+			return
+		}
+		if dir != "" && !path.IsAbs(p) && !strings.HasPrefix(p, "~") {
+			p = path.Join(dir, p)
+		}
+		*list = append(*list, p)
+	}
+
+	syntax.Walk(file, func(node syntax.Node) bool {
+		switch n := node.(type) {
+		case *syntax.Stmt:
+			for _, r := range n.Redirs {
+				switch r.Op {
+				case syntax.RdrOut, syntax.AppOut, syntax.RdrAll, syntax.AppAll, syntax.ClbOut, syntax.RdrInOut:
+					if p, ok := literalWord(r.Word); ok {
+						add(&w.Paths, p)
+					}
+				}
+			}
+		case *syntax.CallExpr:
+			args := literalArgs(n.Args)
+			if len(args) == 0 {
+				return true
+			}
+			switch args[0] {
+			case "cd":
+				if len(args) == 2 {
+					dir = path.Join(dir, args[1])
+					if path.IsAbs(args[1]) {
+						dir = args[1]
+					}
+				}
+			case "rm":
+				recursive := false
+				for _, a := range args[1:] {
+					short := isOption(a) && !strings.HasPrefix(a, "--")
+					if short && strings.ContainsAny(a, "rR") || a == "--recursive" {
+						recursive = true
+					}
+				}
+				for _, a := range operands(args[1:], nil) {
+					if recursive {
+						add(&w.Recursive, a)
+					} else {
+						add(&w.Paths, a)
+					}
+				}
+			case "mv":
+				ops := operands(args[1:], []string{"-t", "--target-directory", "-S", "--suffix"})
+				if len(ops) < 2 {
+					return true
+				}
+				// Sources disappear, whole directories included; the
+				// destination is written as for cp.
+				dest := ops[len(ops)-1]
+				add(&w.Paths, dest)
+				for _, src := range ops[:len(ops)-1] {
+					add(&w.Recursive, src)
+					add(&w.Paths, path.Join(dest, path.Base(src)))
+				}
+			case "unlink", "touch", "tee", "shred":
+				for _, a := range operands(args[1:], nil) {
+					add(&w.Paths, a)
+				}
+			case "truncate":
+				for _, a := range operands(args[1:], []string{"-s", "-r", "--size", "--reference"}) {
+					add(&w.Paths, a)
+				}
+			case "cp", "install", "ln":
+				ops := operands(args[1:], []string{"-t", "--target-directory", "-m", "--mode", "-o", "-g", "-S", "--suffix"})
+				if len(ops) < 2 {
+					return true
+				}
+				// The destination is either the file to write or a
+				// directory to copy into; record both candidates.
+				dest := ops[len(ops)-1]
+				add(&w.Paths, dest)
+				for _, src := range ops[:len(ops)-1] {
+					add(&w.Paths, path.Join(dest, path.Base(src)))
+				}
+			case "sed", "perl":
+				files := inPlaceFiles(args)
+				for _, a := range files {
+					add(&w.Paths, a)
+				}
+			case "dd":
+				for _, a := range args[1:] {
+					if p, ok := strings.CutPrefix(a, "of="); ok {
+						add(&w.Paths, p)
+					}
+				}
+			case "git":
+				if discardsWorktree(args[1:]) {
+					w.GitWorktree = true
+				}
+			}
+		}
+		return true
+	})
+	return w, nil
+}
+
+// literalWord returns the value of w if it contains no expansions.
+func literalWord(w *syntax.Word) (string, bool) {
+	if w == nil {
+		return "", false
+	}
+	var sb strings.Builder
+	for _, part := range w.Parts {
+		switch p := part.(type) {
+		case *syntax.Lit:
+			sb.WriteString(p.Value)
+		case *syntax.SglQuoted:
+			sb.WriteString(p.Value)
+		case *syntax.DblQuoted:
+			for _, dp := range p.Parts {
+				lit, ok := dp.(*syntax.Lit)
+				if !ok {
+					return "", false
+				}
+				sb.WriteString(lit.Value)
+			}
+		default:
+			return "", false
+		}
+	}
+	return sb.String(), true
+}
+
+// literalArgs returns the values of args up to the first one that is not
+// literal, since later positions can no longer be interpreted reliably.
+func literalArgs(args []*syntax.Word) []string {
+	var out []string
+	for _, a := range args {
+		s, ok := literalWord(a)
+		if !ok {
+			break
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+func isOption(a string) bool {
+	return len(a) > 1 && strings.HasPrefix(a, "-")
+}
+
+// operands returns the non-option arguments in args. Options listed in
+// withValue consume the following argument. Everything after "--" is an
+// operand.
+func operands(args, withValue []string) []string {
+	var out []string
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if a == "--" {
+			return append(out, args[i+1:]...)
+		}
+		if isOption(a) {
+			for _, v := range withValue {
+				if a == v {
+					i++
+					break
+				}
+			}
+			continue
+		}
+		out = append(out, a)
+	}
+	return out
+}
+
+// inPlaceFiles returns the files edited by sed -i or perl -i, or nil if the
+// command does not edit in place.
+func inPlaceFiles(args []string) []string {
+	inPlace, hasScriptOption := false, false
+	for _, a := range args[1:] {
+		if a == "--" {
+			break
+		}
+		if !isOption(a) {
+			continue
+		}
+		if a == "--in-place" || strings.HasPrefix(a, "--in-place=") {
+			inPlace = true
+		}
+		if !strings.HasPrefix(a, "--") {
+			flags := a[1:]
+			// -i may carry a backup suffix (sed -i.bak, perl -i.orig).
+			if i := strings.IndexByte(flags, 'i'); i >= 0 && !strings.ContainsRune(flags[:i], 'e') {
+				inPlace = true
+			}
+			if strings.ContainsAny(flags, "ef") {
+				hasScriptOption = true
+			}
+		}
+		if a == "--expression" || a == "--file" || strings.HasPrefix(a, "--expression=") {
+			hasScriptOption = true
+		}
+	}
+	if !inPlace {
+		return nil
+	}
+	ops := operands(args[1:], []string{"-e", "-f", "--expression", "--file", "-pe", "-pie", "-ne"})
+	if !hasScriptOption && len(ops) > 0 {
+		ops = ops[1:] // the first operand is the script
+	}
+	return ops
+}
+
+// discardsWorktree reports whether the git arguments describe a command that
+// can overwrite uncommitted changes in the working tree.
+func discardsWorktree(args []string) bool {
+	// Skip global options such as -C dir.
+	for len(args) > 0 && isOption(args[0]) {
+		if args[0] == "-C" || args[0] == "-c" {
+			args = args[1:]
+		}
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		return false
+	}
+	sub, rest := args[0], args[1:]
+	switch sub {
+	case "checkout", "switch", "restore", "clean", "merge", "rebase", "pull", "apply", "am", "cherry-pick", "revert", "mv", "rm":
+		return true
+	case "reset":
+		for _, a := range rest {
+			if a == "--hard" || a == "--merge" || a == "--keep" {
+				return true
+			}
+		}
+	case "stash":
+		if len(rest) == 0 {
+			return true
+		}
+		switch rest[0] {
+		case "push", "save", "pop", "apply":
+			return true
+		}
+		return isOption(rest[0])
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package bashkit
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFindWrittenFiles(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   WrittenFiles
+	}{
+		{
+			name:   "read only",
+			script: "ls -la && cat README.md | grep foo",
+			want:   WrittenFiles{},
+		},
+		{
+			name:   "redirections",
+			script: `echo hi > out.txt; date >> "log file.txt" 2>/dev/null; cat x > $OUT`,
+			want:   WrittenFiles{Paths: []string{"out.txt", "log file.txt"}},
+		},
+		{
+			name:   "rm and rm -rf",
+			script: "rm -f a.txt && rm -rf build --force",
+			want:   WrittenFiles{Paths: []string{"a.txt"}, Recursive: []string{"build"}},
+		},
+		{
+			name:   "mv and cp",
+			script: "mv old.go new.go; cp -r src/a.go dst",
+			want: WrittenFiles{
+				Paths:     []string{"new.go", "new.go/old.go", "dst", "dst/a.go"},
+				Recursive: []string{"old.go"},
+			},
+		},
+		{
+			name:   "sed in place",
+			script: "sed -i 's/foo/bar/g' a.go b.go && sed -e 's/x/y/' c.go && sed -i.bak -e 's/x/y/' d.go",
+			want:   WrittenFiles{Paths: []string{"a.go", "b.go", "d.go"}},
+		},
+		{
+			name:   "perl in place",
+			script: "perl -pi -e 's/a/b/' x.pl",
+			want:   WrittenFiles{Paths: []string{"x.pl"}},
+		},
+		{
+			name:   "cd changes the base directory",
+			script: "cd sub && tee out.txt < in.txt && cd /tmp && touch t",
+			want:   WrittenFiles{Paths: []string{"sub/out.txt", "/tmp/t"}},
+		},
+		{
+			name:   "truncate skips the size",
+			script: "truncate -s 0 big.log",
+			want:   WrittenFiles{Paths: []string{"big.log"}},
+		},
+		{
+			name:   "git discarding changes",
+			script: "git status && git reset --hard HEAD",
+			want:   WrittenFiles{GitWorktree: true},
+		},
+		{
+			name:   "git read only",
+			script: "git -C repo log && git stash list && git reset HEAD~1",
+			want:   WrittenFiles{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FindWrittenFiles(tt.script)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FindWrittenFiles(%q) = %+v, want %+v", tt.script, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package claudetool
+
+import (
+	"bytes"
+	"context"
+	"io/fs"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/tgruben-circuit/percy/claudetool/bashkit"
+)
+
+// maxCheckpointFiles bounds how many files a single bash command snapshots,
+// so that commands like rm -rf on a large tree stay fast.
+const maxCheckpointFiles = 500
+
+// Checkpointer saves the contents of files before the agent changes them,
+// so that a conversation's edits can be undone independently of git.
+type Checkpointer interface {
+	// SaveFiles records the current contents of the given absolute paths,
+	// including the fact that a path does not exist. Only the first save
+	// of a path in each turn is kept.
+	SaveFiles(ctx context.Context, paths []string) error
+}
+
+// saveCheckpoint records paths with cp, logging rather than failing on
+// errors: a missing checkpoint must not stop the edit.
+func saveCheckpoint(ctx context.Context, cp Checkpointer, paths []string) {
+	if cp == nil || len(paths) == 0 {
+		return
+	}
+	if err := cp.SaveFiles(ctx, paths); err != nil {
+		slog.WarnContext(ctx, "failed to checkpoint files", "count", len(paths), "error", err)
+	}
+}
+
+// bashCheckpointPaths returns the existing or about-to-be-created files that
+// command may modify, resolved against wd.
+func bashCheckpointPaths(ctx context.Context, command, wd string) []string {
+	written, err := bashkit.FindWrittenFiles(command)
+	if err != nil || written.Empty() {
+		return nil
+	}
+	c := &checkpointPaths{seen: make(map[string]bool)}
+	for _, p := range written.Paths {
+		for _, abs := range resolveGlob(p, wd) {
+			// Directories are only written into, never replaced as a whole.
+			if fi, err := os.Stat(abs); err == nil && fi.IsDir() {
+				continue
+			}
+			c.add(abs)
+		}
+	}
+	for _, p := range written.Recursive {
+		for _, abs := range resolveGlob(p, wd) {
+			c.addTree(abs)
+		}
+	}
+	if written.GitWorktree {
+		for _, p := range gitDirtyFiles(ctx, wd) {
+			c.add(p)
+		}
+	}
+	return c.paths
+}
+
+// checkpointPaths collects unique paths up to maxCheckpointFiles.
+type checkpointPaths struct {
+	paths []string
+	seen  map[string]bool
+}
+
+func (c *checkpointPaths) full() bool {
+	return len(c.paths) >= maxCheckpointFiles
+}
+
+func (c *checkpointPaths) add(p string) {
+	if c.full() || c.seen[p] {
+		return
+	}
+	c.seen[p] = true
+	c.paths = append(c.paths, p)
+}
+
+// addTree adds root, or the regular files under it if it is a directory.
+func (c *checkpointPaths) addTree(root string) {
+	fi, err := os.Lstat(root)
+	if err != nil || !fi.IsDir() {
+		c.add(root)
+		return
+	}
+	_ = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || c.full() {
+			return filepath.SkipAll
+		}
+		if d.IsDir() && d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if d.Type().IsRegular() {
+			c.add(p)
+		}
+		return nil
+	})
+}
+
+// resolveGlob makes p absolute relative to wd and expands any glob pattern
+// in it. A pattern that matches nothing yields no paths.
+func resolveGlob(p, wd string) []string {
+	if rest, ok := strings.CutPrefix(p, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			p = filepath.Join(home, rest)
+		}
+	}
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(wd, p)
+	}
+	p = filepath.Clean(p)
+	if !strings.ContainsAny(p, "*?[") {
+		return []string{p}
+	}
+	matches, err := filepath.Glob(p)
+	if err != nil {
+		return nil
+	}
+	return matches
+}
+
+// gitDirtyFiles returns the modified and untracked files in the git
+// repository containing wd.
+func gitDirtyFiles(ctx context.Context, wd string) []string {
+	root, err := exec.CommandContext(ctx, "git", "-C", wd, "rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		return nil
+	}
+	out, err := exec.CommandContext(ctx, "git", "-C", wd, "status", "--porcelain", "-z", "--untracked-files=all").Output()
+	if err != nil {
+		return nil
+	}
+	top := strings.TrimSpace(string(root))
+	var paths []string
+	entries := bytes.Split(out, []byte{0})
+	for i := 0; i < len(entries); i++ {
+		e := entries[i]
+		if len(e) < 4 {
+			continue
+		}
+		paths = append(paths, filepath.Join(top, string(e[3:])))
+		if e[0] == 'R' || e[0] == 'C' {
+			i++ // the next entry is the rename source
+		}
+	}
+	return paths
+}
@@ -0,0 +1,82 @@
+package claudetool
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// recordingCheckpointer records the contents of files when asked to save them.
+type recordingCheckpointer struct {
+	saved map[string]string // path -> content, "" if missing
+}
+
+func (r *recordingCheckpointer) SaveFiles(ctx context.Context, paths []string) error {
+	for _, p := range paths {
+		content, _ := os.ReadFile(p)
+		r.saved[p] = string(content)
+	}
+	return nil
+}
+
+func TestPatchToolCheckpoints(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "f.txt")
+	if err := os.WriteFile(file, []byte("before\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cp := &recordingCheckpointer{saved: map[string]string{}}
+	patch := &PatchTool{WorkingDir: NewMutableWorkingDir(dir), Checkpointer: cp}
+
+	msg, _ := json.Marshal(PatchInput{
+		Path:    "f.txt",
+		Patches: []PatchRequest{{Operation: "overwrite", NewText: "after\n"}},
+	})
+	if out := patch.Run(context.Background(), msg); out.Error != nil {
+		t.Fatal(out.Error)
+	}
+	if got := cp.saved[file]; got != "before\n" {
+		t.Errorf("checkpointed %q, want the content before the patch", got)
+	}
+}
+
+func TestBashCheckpointPaths(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.go", "b.go", "sub/c.txt", "sub/.git/HEAD"} {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		command string
+		want    []string
+	}{
+		{"ls -la", nil},
+		{"echo hi > out.txt", []string{"out.txt"}},
+		{"rm *.go", []string{"a.go", "b.go"}},
+		{"rm -rf sub", []string{"sub/c.txt"}},
+		{"cd sub && sed -i s/x/y/ c.txt", []string{"sub/c.txt"}},
+		{"cp a.go sub", []string{"sub/a.go"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			var want []string
+			for _, w := range tt.want {
+				want = append(want, filepath.Join(dir, w))
+			}
+			got := bashCheckpointPaths(context.Background(), tt.command, dir)
+			slices.Sort(got)
+			if !slices.Equal(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
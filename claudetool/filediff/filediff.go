@@ -0,0 +1,39 @@
+// Package filediff describes file edits as unified diffs and as display
+// data for the UI's diff viewer.
+package filediff
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/diff"
+)
+
+// DisplayData is the structured data sent to the UI to show an edit of
+// one file.
+type DisplayData struct {
+	Path       string `json:"path"`
+	OldContent string `json:"oldContent"`
+	NewContent string `json:"newContent"`
+	Diff       string `json:"diff"`
+}
+
+// NewDisplayData returns display data for an edit of filePath.
+func NewDisplayData(filePath, original, patched string) DisplayData {
+	return DisplayData{
+		Path:       filePath,
+		OldContent: original,
+		NewContent: patched,
+		Diff:       Unified(filePath, original, patched),
+	}
+}
+
+// Unified returns a unified diff from original to patched.
+func Unified(filePath, original, patched string) string {
+	buf := new(strings.Builder)
+	err := diff.Text(filePath, filePath, original, patched, buf)
+	if err != nil {
+		return fmt.Sprintf("(diff generation failed: %v)\n", err)
+	}
+	return buf.String()
+}
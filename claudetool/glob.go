@@ -0,0 +1,227 @@
+package claudetool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tgruben-circuit/percy/llm"
+)
+
+// GlobTool finds files by name pattern, skipping files ignored by git.
+type GlobTool struct {
+	WorkingDir *MutableWorkingDir
+}
+
+const (
+	globName        = "glob"
+	globDescription = `Find files whose paths match a glob pattern.
+
+Patterns are matched against paths relative to the search directory:
+- * matches within a path segment, ? matches one character, [abc] matches a class
+- ** matches any number of directories, so **/*.go finds Go files at any depth
+- {a,b} matches either alternative, as in src/**/*.{ts,tsx}
+
+Files ignored by .gitignore and .git directories are skipped. Results are sorted by path.
+Use this instead of find or ls in bash to locate files.`
+
+	globInputSchema = `{
+  "type": "object",
+  "required": ["pattern"],
+  "properties": {
+    "pattern": {
+      "type": "string",
+      "description": "Glob pattern, e.g. **/*.go or cmd/*/main.go"
+    },
+    "path": {
+      "type": "string",
+      "description": "Directory to search (absolute or relative to working directory; default: working directory)"
+    },
+    "limit": {
+      "type": "integer",
+      "description": "Maximum number of files to return (default: 200, max: 2000)"
+    }
+  }
+}`
+
+	globDefaultLimit = 200
+	globMaxLimit     = 2000
+)
+
+type globInput struct {
+	Pattern string `json:"pattern"`
+	Path    string `json:"path"`
+	Limit   int    `json:"limit"`
+}
+
+// GlobDisplayData is the structured data sent to the UI for display.
+type GlobDisplayData struct {
+	Pattern   string   `json:"pattern"`
+	Path      string   `json:"path"`
+	Files     []string `json:"files"`
+	Truncated bool     `json:"truncated"`
+}
+
+// Tool returns an llm.Tool for finding files by pattern.
+func (g *GlobTool) Tool() *llm.Tool {
+	return &llm.Tool{
+		Name:        globName,
+		Description: globDescription,
+		InputSchema: llm.MustSchema(globInputSchema),
+		Run:         g.Run,
+	}
+}
+
+// Run finds the files matching the pattern in m.
+func (g *GlobTool) Run(ctx context.Context, m json.RawMessage) llm.ToolOut {
+	var input globInput
+	if err := json.Unmarshal(m, &input); err != nil {
+		return llm.ErrorfToolOut("failed to unmarshal glob input: %w", err)
+	}
+	if input.Pattern == "" {
+		return llm.ErrorfToolOut("pattern is required")
+	}
+	limit := clampLimit(input.Limit, globDefaultLimit, globMaxLimit)
+
+	wd := g.WorkingDir.Get()
+	root, err := searchRoot(wd, input.Path)
+	if err != nil {
+		return llm.ErrorToolOut(err)
+	}
+	if fi, err := os.Stat(root); err != nil || !fi.IsDir() {
+		return llm.ErrorfToolOut("%s is not a directory", root)
+	}
+	matcher, err := newGlobMatcher(input.Pattern)
+	if err != nil {
+		return llm.ErrorToolOut(err)
+	}
+
+	display := GlobDisplayData{Pattern: input.Pattern, Path: root, Files: []string{}}
+	err = walkFiles(ctx, root, func(rel string) error {
+		if !matcher.match(rel) {
+			return nil
+		}
+		if len(display.Files) == limit {
+			display.Truncated = true
+			return filepath.SkipAll
+		}
+		display.Files = append(display.Files, displayPath(wd, filepath.Join(root, filepath.FromSlash(rel))))
+		return nil
+	})
+	if err != nil {
+		return llm.ErrorToolOut(err)
+	}
+
+	var sb strings.Builder
+	if len(display.Files) == 0 {
+		fmt.Fprintf(&sb, "No files match %s", input.Pattern)
+	} else {
+		sb.WriteString(strings.Join(display.Files, "\n"))
+	}
+	if display.Truncated {
+		fmt.Fprintf(&sb, "\n\n(Results truncated at %d files. Use a more specific pattern or path.)", limit)
+	}
+	return llm.ToolOut{LLMContent: llm.TextContent(sb.String()), Display: display}
+}
+
+// globMatcher matches slash-separated relative paths against a glob pattern
+// with ** and {a,b} support.
+type globMatcher struct {
+	patterns [][]string // brace-expanded alternatives, split on "/"
+}
+
+func newGlobMatcher(pattern string) (*globMatcher, error) {
+	pattern = strings.TrimPrefix(filepath.ToSlash(pattern), "./")
+	m := &globMatcher{}
+	for _, alt := range expandBraces(pattern) {
+		segments := strings.Split(alt, "/")
+		for _, seg := range segments {
+			if _, err := filepath.Match(seg, ""); err != nil {
+				return nil, fmt.Errorf("invalid glob pattern %q: %w", pattern, err)
+			}
+		}
+		m.patterns = append(m.patterns, segments)
+	}
+	return m, nil
+}
+
+func (m *globMatcher) match(rel string) bool {
+	parts := strings.Split(rel, "/")
+	for _, p := range m.patterns {
+		if matchSegments(p, parts) {
+			return true
+		}
+	}
+	return false
+}
+
+// expandBraces expands {a,b} alternatives in pattern. Nested braces are
+// expanded from the outside in.
+func expandBraces(pattern string) []string {
+	start := strings.IndexByte(pattern, '{')
+	if start < 0 {
+		return []string{pattern}
+	}
+	depth := 0
+	var alts []string
+	last := start + 1
+	for i := start; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '{':
+			depth++
+		case ',':
+			if depth == 1 {
+				alts = append(alts, pattern[last:i])
+				last = i + 1
+			}
+		case '}':
+			depth--
+			if depth == 0 {
+				alts = append(alts, pattern[last:i])
+				var out []string
+				for _, rest := range expandBraces(pattern[i+1:]) {
+					for _, alt := range alts {
+						for _, a := range expandBraces(alt) {
+							out = append(out, pattern[:start]+a+rest)
+						}
+					}
+				}
+				return out
+			}
+		}
+	}
+	return []string{pattern} // unbalanced; match literally
+}
+
+// searchRoot resolves a search path relative to the working directory.
+func searchRoot(wd, p string) (string, error) {
+	if p == "" {
+		return wd, nil
+	}
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(wd, p)
+	}
+	if _, err := os.Stat(p); err != nil {
+		return "", fmt.Errorf("path does not exist: %s", p)
+	}
+	return filepath.Clean(p), nil
+}
+
+// displayPath returns p relative to wd if it is inside wd, and p otherwise.
+func displayPath(wd, p string) string {
+	if rel, err := filepath.Rel(wd, p); err == nil && filepath.IsLocal(rel) {
+		return rel
+	}
+	return p
+}
+
+// clampLimit returns limit, or def if it is unset, capped at max.
+func clampLimit(limit, def, max int) int {
+	if limit <= 0 {
+		return def
+	}
+	return min(limit, max)
+}
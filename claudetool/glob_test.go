@@ -0,0 +1,88 @@
+package claudetool
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func runGlob(t *testing.T, dir string, input globInput) GlobDisplayData {
+	t.Helper()
+	tool := &GlobTool{WorkingDir: NewMutableWorkingDir(dir)}
+	msg, err := json.Marshal(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := tool.Run(context.Background(), msg)
+	if out.Error != nil {
+		t.Fatalf("glob %q: %v", input.Pattern, out.Error)
+	}
+	return out.Display.(GlobDisplayData)
+}
+
+func TestGlobTool(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		".gitignore":           "node_modules/\n",
+		"main.go":              "",
+		"cmd/app/main.go":      "",
+		"cmd/app/main_test.go": "",
+		"ui/src/App.tsx":       "",
+		"ui/src/index.ts":      "",
+		"ui/node_modules/x.ts": "",
+		"README.md":            "",
+	})
+
+	tests := []struct {
+		pattern string
+		path    string
+		want    []string
+	}{
+		{"*.go", "", []string{"main.go"}},
+		{"**/*.go", "", []string{"cmd/app/main.go", "cmd/app/main_test.go", "main.go"}},
+		{"cmd/*/main.go", "", []string{"cmd/app/main.go"}},
+		{"**/*.{ts,tsx}", "", []string{"ui/src/App.tsx", "ui/src/index.ts"}},
+		{"src/*.ts", "ui", []string{"ui/src/index.ts"}},
+		{"*.rs", "", []string{}},
+	}
+	for _, tt := range tests {
+		got := runGlob(t, dir, globInput{Pattern: tt.pattern, Path: tt.path})
+		if !slices.Equal(got.Files, tt.want) {
+			t.Errorf("glob %q in %q = %v, want %v", tt.pattern, tt.path, got.Files, tt.want)
+		}
+	}
+
+	got := runGlob(t, dir, globInput{Pattern: "**/*", Limit: 2})
+	if len(got.Files) != 2 || !got.Truncated {
+		t.Errorf("limited glob = %v (truncated=%v), want 2 files truncated", got.Files, got.Truncated)
+	}
+}
+
+func TestExpandBraces(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{"*.go", []string{"*.go"}},
+		{"*.{ts,tsx}", []string{"*.ts", "*.tsx"}},
+		{"{a,b}/{c,d}", []string{"a/c", "b/c", "a/d", "b/d"}},
+		{"x.{a,{b,c}}", []string{"x.a", "x.b", "x.c"}},
+		{"x{a,b", []string{"x{a,b"}},
+	}
+	for _, tt := range tests {
+		got := expandBraces(tt.pattern)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("expandBraces(%q) = %v, want %v", tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestGlobToolInvalidPattern(t *testing.T) {
+	tool := &GlobTool{WorkingDir: NewMutableWorkingDir(t.TempDir())}
+	out := tool.Run(context.Background(), json.RawMessage(`{"pattern": "[a-"}`))
+	if out.Error == nil || !strings.Contains(out.Error.Error(), "invalid glob pattern") {
+		t.Errorf("expected invalid pattern error, got %v", out.Error)
+	}
+}
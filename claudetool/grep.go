@@ -0,0 +1,369 @@
+package claudetool
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/tgruben-circuit/percy/llm"
+)
+
+// GrepTool searches file contents with a regular expression, skipping files
+// ignored by git.
+type GrepTool struct {
+	WorkingDir *MutableWorkingDir
+}
+
+const (
+	grepName        = "grep"
+	grepDescription = `Search file contents with a regular expression (Go RE2 syntax).
+
+Searches a file or directory tree, skipping binary files, .git directories and files ignored by .gitignore.
+
+Output modes:
+- files_with_matches (default): paths of files containing a match
+- content: matching lines as path:line:text, with optional context lines
+- count: number of matching lines per file
+
+Narrow the search with glob (e.g. *.go or src/**/*.ts) or type (e.g. go, ts, py).
+Use this instead of grep or rg in bash for code searches.`
+
+	grepInputSchema = `{
+  "type": "object",
+  "required": ["pattern"],
+  "properties": {
+    "pattern": {
+      "type": "string",
+      "description": "Regular expression to search for (RE2 syntax)"
+    },
+    "path": {
+      "type": "string",
+      "description": "File or directory to search (absolute or relative to working directory; default: working directory)"
+    },
+    "glob": {
+      "type": "string",
+      "description": "Only search files matching this glob; patterns without a slash match file names"
+    },
+    "type": {
+      "type": "string",
+      "description": "Only search files of this type, e.g. go, ts, py, rust, md"
+    },
+    "case_insensitive": {
+      "type": "boolean",
+      "description": "Match case-insensitively"
+    },
+    "context": {
+      "type": "integer",
+      "description": "Lines of context to show before and after each match (content mode only)"
+    },
+    "output_mode": {
+      "type": "string",
+      "enum": ["files_with_matches", "content", "count"],
+      "description": "What to return (default: files_with_matches)"
+    },
+    "limit": {
+      "type": "integer",
+      "description": "Maximum number of files, or of matching lines in content mode (default: 100, max: 1000)"
+    }
+  }
+}`
+
+	grepDefaultLimit = 100
+	grepMaxLimit     = 1000
+	grepMaxContext   = 20
+	grepMaxFileSize  = 10 << 20
+	grepMaxLineLen   = 500
+)
+
+// grepFileTypes maps file type names to the file name patterns they cover.
+var grepFileTypes = map[string][]string{
+	"c":        {"*.c", "*.h"},
+	"cpp":      {"*.cc", "*.cpp", "*.cxx", "*.hh", "*.hpp", "*.hxx", "*.h"},
+	"css":      {"*.css", "*.scss", "*.sass", "*.less"},
+	"go":       {"*.go"},
+	"html":     {"*.html", "*.htm"},
+	"java":     {"*.java"},
+	"js":       {"*.js", "*.jsx", "*.mjs", "*.cjs"},
+	"json":     {"*.json"},
+	"kotlin":   {"*.kt", "*.kts"},
+	"md":       {"*.md", "*.markdown"},
+	"php":      {"*.php"},
+	"proto":    {"*.proto"},
+	"py":       {"*.py", "*.pyi"},
+	"rb":       {"*.rb"},
+	"rust":     {"*.rs"},
+	"sh":       {"*.sh", "*.bash", "*.zsh"},
+	"sql":      {"*.sql"},
+	"swift":    {"*.swift"},
+	"toml":     {"*.toml"},
+	"ts":       {"*.ts", "*.tsx", "*.mts", "*.cts"},
+	"yaml":     {"*.yaml", "*.yml"},
+	"makefile": {"Makefile", "makefile", "GNUmakefile", "*.mk"},
+}
+
+type grepInput struct {
+	Pattern         string `json:"pattern"`
+	Path            string `json:"path"`
+	Glob            string `json:"glob"`
+	Type            string `json:"type"`
+	CaseInsensitive bool   `json:"case_insensitive"`
+	Context         int    `json:"context"`
+	OutputMode      string `json:"output_mode"`
+	Limit           int    `json:"limit"`
+}
+
+// GrepDisplayData is the structured data sent to the UI for display.
+type GrepDisplayData struct {
+	Pattern    string     `json:"pattern"`
+	OutputMode string     `json:"output_mode"`
+	Files      []GrepFile `json:"files"`
+	Truncated  bool       `json:"truncated"`
+}
+
+// GrepFile is a file with matches.
+type GrepFile struct {
+	Path  string `json:"path"`
+	Count int    `json:"count"`
+	// Lines holds the matching and context lines in content mode.
+	Lines []GrepLine `json:"lines,omitempty"`
+}
+
+// GrepLine is a matching or context line.
+type GrepLine struct {
+	Number int    `json:"number"`
+	Text   string `json:"text"`
+	Match  bool   `json:"match"`
+}
+
+// Tool returns an llm.Tool for searching file contents.
+func (g *GrepTool) Tool() *llm.Tool {
+	return &llm.Tool{
+		Name:        grepName,
+		Description: grepDescription,
+		InputSchema: llm.MustSchema(grepInputSchema),
+		Run:         g.Run,
+	}
+}
+
+// Run searches for the pattern in m.
+func (g *GrepTool) Run(ctx context.Context, m json.RawMessage) llm.ToolOut {
+	var input grepInput
+	if err := json.Unmarshal(m, &input); err != nil {
+		return llm.ErrorfToolOut("failed to unmarshal grep input: %w", err)
+	}
+	if input.Pattern == "" {
+		return llm.ErrorfToolOut("pattern is required")
+	}
+	switch input.OutputMode {
+	case "":
+		input.OutputMode = "files_with_matches"
+	case "files_with_matches", "content", "count":
+	default:
+		return llm.ErrorfToolOut("unknown output_mode %q: use files_with_matches, content or count", input.OutputMode)
+	}
+
+	expr := input.Pattern
+	if input.CaseInsensitive {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return llm.ErrorfToolOut("invalid regular expression: %w", err)
+	}
+
+	var filters []*globMatcher
+	if input.Glob != "" {
+		gm, err := newFileFilter(input.Glob)
+		if err != nil {
+			return llm.ErrorToolOut(err)
+		}
+		filters = append(filters, gm)
+	}
+	if input.Type != "" {
+		patterns, ok := grepFileTypes[strings.ToLower(input.Type)]
+		if !ok {
+			return llm.ErrorfToolOut("unknown file type %q; known types: %s", input.Type, strings.Join(slices.Sorted(maps.Keys(grepFileTypes)), ", "))
+		}
+		gm, err := newFileFilter("{" + strings.Join(patterns, ",") + "}")
+		if err != nil {
+			return llm.ErrorToolOut(err)
+		}
+		filters = append(filters, gm)
+	}
+
+	wd := g.WorkingDir.Get()
+	root, err := searchRoot(wd, input.Path)
+	if err != nil {
+		return llm.ErrorToolOut(err)
+	}
+
+	s := &grepSearch{
+		re:      re,
+		content: input.OutputMode == "content",
+		context: min(max(input.Context, 0), grepMaxContext),
+		limit:   clampLimit(input.Limit, grepDefaultLimit, grepMaxLimit),
+		display: GrepDisplayData{Pattern: input.Pattern, OutputMode: input.OutputMode, Files: []GrepFile{}},
+	}
+
+	if fi, err := os.Stat(root); err == nil && !fi.IsDir() {
+		// A single file is searched even if it would be filtered out.
+		if err := s.searchFile(root, displayPath(wd, root)); err != nil && err != filepath.SkipAll {
+			return llm.ErrorToolOut(err)
+		}
+	} else {
+		err = walkFiles(ctx, root, func(rel string) error {
+			for _, f := range filters {
+				if !f.match(rel) {
+					return nil
+				}
+			}
+			abs := filepath.Join(root, filepath.FromSlash(rel))
+			return s.searchFile(abs, displayPath(wd, abs))
+		})
+		if err != nil {
+			return llm.ErrorToolOut(err)
+		}
+	}
+
+	return llm.ToolOut{LLMContent: llm.TextContent(s.format()), Display: s.display}
+}
+
+// newFileFilter returns a matcher for a glob filter. Patterns without a
+// slash match the file name at any depth, as in rg --glob.
+func newFileFilter(pattern string) (*globMatcher, error) {
+	if !strings.Contains(pattern, "/") {
+		pattern = "**/" + pattern
+	}
+	return newGlobMatcher(pattern)
+}
+
+type grepSearch struct {
+	re      *regexp.Regexp
+	content bool
+	context int
+	limit   int
+	matches int // matching lines collected, in content mode
+	display GrepDisplayData
+}
+
+// searchFile searches the file at abs, recording matches under name. It
+// returns filepath.SkipAll once the limit is reached.
+func (s *grepSearch) searchFile(abs, name string) error {
+	fi, err := os.Stat(abs)
+	if err != nil || fi.Size() > grepMaxFileSize {
+		return nil
+	}
+	data, err := os.ReadFile(abs)
+	if err != nil || isBinary(data) {
+		return nil
+	}
+
+	var lines []string
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 0, 64*1024), grepMaxFileSize)
+	for sc.Scan() {
+		lines = append(lines, sc.Text())
+	}
+
+	file := GrepFile{Path: name}
+	lastAdded := 0 // number of the last line added to file.Lines
+	for i, line := range lines {
+		if !s.re.MatchString(line) {
+			continue
+		}
+		full := s.content && s.matches == s.limit || !s.content && len(s.display.Files) == s.limit
+		if full {
+			s.display.Truncated = true
+			break
+		}
+		file.Count++
+		if !s.content {
+			continue
+		}
+		s.matches++
+		n := i + 1
+		for c := max(n-s.context, lastAdded+1); c < n; c++ {
+			file.Lines = append(file.Lines, GrepLine{Number: c, Text: truncateGrepLine(lines[c-1])})
+		}
+		file.Lines = append(file.Lines, GrepLine{Number: n, Text: truncateGrepLine(line), Match: true})
+		lastAdded = n
+		// Trailing context is added up to the next match, which adds its own.
+		for c := n + 1; c <= min(n+s.context, len(lines)); c++ {
+			if s.re.MatchString(lines[c-1]) {
+				break
+			}
+			file.Lines = append(file.Lines, GrepLine{Number: c, Text: truncateGrepLine(lines[c-1])})
+			lastAdded = c
+		}
+	}
+	if file.Count > 0 {
+		s.display.Files = append(s.display.Files, file)
+	}
+	if s.display.Truncated {
+		return filepath.SkipAll
+	}
+	return nil
+}
+
+// format renders the results for the LLM, in the style of rg.
+func (s *grepSearch) format() string {
+	var sb strings.Builder
+	if len(s.display.Files) == 0 {
+		fmt.Fprintf(&sb, "No matches for %s", s.display.Pattern)
+		return sb.String()
+	}
+	switch s.display.OutputMode {
+	case "files_with_matches":
+		for _, f := range s.display.Files {
+			sb.WriteString(f.Path + "\n")
+		}
+	case "count":
+		for _, f := range s.display.Files {
+			fmt.Fprintf(&sb, "%s:%d\n", f.Path, f.Count)
+		}
+	case "content":
+		for i, f := range s.display.Files {
+			prev := 0
+			for _, l := range f.Lines {
+				if s.context > 0 && (prev != 0 && l.Number != prev+1 || prev == 0 && i > 0) {
+					sb.WriteString("--\n")
+				}
+				sep := "-"
+				if l.Match {
+					sep = ":"
+				}
+				fmt.Fprintf(&sb, "%s%s%d%s%s\n", f.Path, sep, l.Number, sep, l.Text)
+				prev = l.Number
+			}
+		}
+	}
+	if s.display.Truncated {
+		unit := "files"
+		if s.content {
+			unit = "matching lines"
+		}
+		fmt.Fprintf(&sb, "\n(Results truncated at %d %s. Narrow the search with path, glob or type.)\n", s.limit, unit)
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+func truncateGrepLine(line string) string {
+	if len(line) <= grepMaxLineLen {
+		return line
+	}
+	// Cut at a rune boundary so multi-byte characters are not split.
+	cut := grepMaxLineLen
+	for cut > 0 && !utf8.RuneStart(line[cut]) {
+		cut--
+	}
+	return line[:cut] + "..."
+}
@@ -0,0 +1,122 @@
+package claudetool
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func runGrep(t *testing.T, dir string, input grepInput) (string, GrepDisplayData) {
+	t.Helper()
+	tool := &GrepTool{WorkingDir: NewMutableWorkingDir(dir)}
+	msg, err := json.Marshal(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := tool.Run(context.Background(), msg)
+	if out.Error != nil {
+		t.Fatalf("grep %q: %v", input.Pattern, out.Error)
+	}
+	return out.LLMContent[0].Text, out.Display.(GrepDisplayData)
+}
+
+func grepTestDir(t *testing.T) string {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		".gitignore":  "dist/\n",
+		"a.go":        "package a\n\nfunc Hello() {}\n\nfunc hello() {}\n",
+		"b.go":        "package b\n\n// Hello world\n",
+		"c.ts":        "export function Hello() {}\n",
+		"dist/out.js": "function Hello() {}\n",
+		"bin.dat":     "Hello\x00\x01\x02",
+	})
+	return dir
+}
+
+func TestGrepToolOutputModes(t *testing.T) {
+	dir := grepTestDir(t)
+
+	text, _ := runGrep(t, dir, grepInput{Pattern: "Hello"})
+	if want := "a.go\nb.go\nc.ts"; text != want {
+		t.Errorf("files_with_matches:\n%s\nwant:\n%s", text, want)
+	}
+
+	text, _ = runGrep(t, dir, grepInput{Pattern: "hello", CaseInsensitive: true, OutputMode: "count"})
+	if want := "a.go:2\nb.go:1\nc.ts:1"; text != want {
+		t.Errorf("count:\n%s\nwant:\n%s", text, want)
+	}
+
+	text, display := runGrep(t, dir, grepInput{Pattern: `^func`, OutputMode: "content", Type: "go"})
+	if want := "a.go:3:func Hello() {}\na.go:5:func hello() {}"; text != want {
+		t.Errorf("content:\n%s\nwant:\n%s", text, want)
+	}
+	if len(display.Files) != 1 || display.Files[0].Count != 2 || len(display.Files[0].Lines) != 2 {
+		t.Errorf("unexpected display data: %+v", display)
+	}
+
+	text, _ = runGrep(t, dir, grepInput{Pattern: "Hello", Glob: "*.ts"})
+	if text != "c.ts" {
+		t.Errorf("glob filter: got %q, want c.ts", text)
+	}
+
+	text, _ = runGrep(t, dir, grepInput{Pattern: "nothing matches this"})
+	if !strings.HasPrefix(text, "No matches") {
+		t.Errorf("no matches: got %q", text)
+	}
+}
+
+func TestGrepToolContext(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"f.txt": "1\n2\nmatch 3\n4\n5\n6\n7\nmatch 8\nmatch 9\n10\n",
+	})
+	text, _ := runGrep(t, dir, grepInput{Pattern: "match", OutputMode: "content", Context: 1})
+	want := `f.txt-2-2
+f.txt:3:match 3
+f.txt-4-4
+--
+f.txt-7-7
+f.txt:8:match 8
+f.txt:9:match 9
+f.txt-10-10`
+	if text != want {
+		t.Errorf("context:\n%s\nwant:\n%s", text, want)
+	}
+}
+
+func TestGrepToolLimit(t *testing.T) {
+	dir := grepTestDir(t)
+	text, display := runGrep(t, dir, grepInput{Pattern: "Hello", Limit: 2})
+	if len(display.Files) != 2 || !display.Truncated || !strings.Contains(text, "truncated at 2 files") {
+		t.Errorf("file limit: %q %+v", text, display)
+	}
+	_, display = runGrep(t, dir, grepInput{Pattern: "Hello", OutputMode: "content", Limit: 1})
+	if len(display.Files) != 1 || len(display.Files[0].Lines) != 1 || !display.Truncated {
+		t.Errorf("line limit: %+v", display)
+	}
+}
+
+func TestTruncateGrepLine(t *testing.T) {
+	// "é" is two bytes, so the limit falls inside a rune.
+	line := "x" + strings.Repeat("é", grepMaxLineLen)
+	got := truncateGrepLine(line)
+	if !utf8.ValidString(got) || !strings.HasSuffix(got, "...") || len(got) > grepMaxLineLen+len("...") {
+		t.Errorf("truncateGrepLine split a rune or overran the limit: %q", got)
+	}
+}
+
+func TestGrepToolErrors(t *testing.T) {
+	tool := &GrepTool{WorkingDir: NewMutableWorkingDir(t.TempDir())}
+	for input, want := range map[string]string{
+		`{"pattern": "("}`:                        "invalid regular expression",
+		`{"pattern": "x", "type": "cobol"}`:       "unknown file type",
+		`{"pattern": "x", "output_mode": "json"}`: "unknown output_mode",
+	} {
+		out := tool.Run(context.Background(), json.RawMessage(input))
+		if out.Error == nil || !strings.Contains(out.Error.Error(), want) {
+			t.Errorf("%s: expected error containing %q, got %v", input, want, out.Error)
+		}
+	}
+}
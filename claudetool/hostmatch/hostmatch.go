@@ -0,0 +1,29 @@
+// Package hostmatch matches host names against the domain lists in tool and
+// sandbox configuration, so that every list follows the same rules.
+package hostmatch
+
+import "strings"
+
+// Match reports whether host matches one of patterns. A pattern of the form
+// example.com matches example.com and its subdomains; *.example.com matches
+// only the subdomains. Matching ignores case and trailing dots.
+func Match(patterns []string, host string) bool {
+	host = normalize(host)
+	for _, p := range patterns {
+		p = normalize(p)
+		if suffix, ok := strings.CutPrefix(p, "*."); ok {
+			if strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+			continue
+		}
+		if host == p || strings.HasSuffix(host, "."+p) {
+			return true
+		}
+	}
+	return false
+}
+
+func normalize(s string) string {
+	return strings.TrimSuffix(strings.ToLower(s), ".")
+}
@@ -0,0 +1,22 @@
+package hostmatch
+
+import "testing"
+
+func TestMatch(t *testing.T) {
+	patterns := []string{"example.com", "*.github.com"}
+	for host, want := range map[string]bool{
+		"example.com":         true,
+		"EXAMPLE.com.":        true,
+		"www.example.com":     true,
+		"api.github.com":      true,
+		"a.b.github.com":      true,
+		"github.com":          false,
+		"evilgithub.com":      false,
+		"notexample.com":      false,
+		"example.com.evil.io": false,
+	} {
+		if got := Match(patterns, host); got != want {
+			t.Errorf("Match(%q) = %v, want %v", host, got, want)
+		}
+	}
+}
@@ -0,0 +1,462 @@
+package claudetool
+
+import (
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// htmlDocument is a parsed web page reduced to its main content.
+type htmlDocument struct {
+	Title    string
+	Markdown string
+}
+
+// Elements that never carry readable content.
+var droppedElements = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Template: true,
+	atom.Svg: true, atom.Canvas: true, atom.Iframe: true, atom.Object: true,
+	atom.Embed: true, atom.Button: true, atom.Input: true, atom.Select: true,
+	atom.Textarea: true, atom.Nav: true, atom.Aside: true, atom.Head: true,
+}
+
+// Page chrome dropped when no main or article element marks the content.
+var chromeElements = map[atom.Atom]bool{
+	atom.Header: true, atom.Footer: true, atom.Form: true,
+}
+
+var blockElements = map[atom.Atom]bool{
+	atom.Address: true, atom.Article: true, atom.Blockquote: true, atom.Body: true,
+	atom.Dd: true, atom.Details: true, atom.Dialog: true, atom.Div: true,
+	atom.Dl: true, atom.Dt: true, atom.Fieldset: true, atom.Figcaption: true,
+	atom.Figure: true, atom.Footer: true, atom.Form: true, atom.H1: true,
+	atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+	atom.Header: true, atom.Hr: true, atom.Li: true, atom.Main: true,
+	atom.Ol: true, atom.P: true, atom.Pre: true, atom.Section: true,
+	atom.Summary: true, atom.Table: true, atom.Ul: true,
+}
+
+// htmlToMarkdown parses an HTML page, picks its main content and converts it
+// to markdown. Relative links are resolved against base.
+func htmlToMarkdown(src string, base *url.URL) (*htmlDocument, error) {
+	doc, err := html.Parse(strings.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+	title := ""
+	if n := findElement(doc, func(n *html.Node) bool { return n.DataAtom == atom.Title }); n != nil {
+		title = strings.TrimSpace(collapseSpace(textContent(n)))
+	}
+
+	root, chrome := mainContent(doc)
+	removeElements(root, func(n *html.Node) bool {
+		if droppedElements[n.DataAtom] || (chrome && chromeElements[n.DataAtom]) {
+			return true
+		}
+		if _, hidden := attr(n, "hidden"); hidden || attrValue(n, "aria-hidden") == "true" {
+			return true
+		}
+		role := attrValue(n, "role")
+		return role == "navigation" || role == "banner" || role == "contentinfo"
+	})
+
+	c := &mdConverter{base: base}
+	md := strings.Join(c.blocks(root), "\n\n")
+	if title == "" {
+		if h1 := findElement(root, func(n *html.Node) bool { return n.DataAtom == atom.H1 }); h1 != nil {
+			title = strings.TrimSpace(collapseSpace(textContent(h1)))
+		}
+	}
+	return &htmlDocument{Title: title, Markdown: md}, nil
+}
+
+// mainContent returns the element holding the page's main content and
+// whether it is the whole body, in which case page chrome should be dropped.
+func mainContent(doc *html.Node) (*html.Node, bool) {
+	if n := findElement(doc, func(n *html.Node) bool {
+		return n.DataAtom == atom.Main || attrValue(n, "role") == "main"
+	}); n != nil {
+		return n, false
+	}
+	var articles []*html.Node
+	walkElements(doc, func(n *html.Node) {
+		if n.DataAtom == atom.Article {
+			articles = append(articles, n)
+		}
+	})
+	if len(articles) == 1 {
+		return articles[0], false
+	}
+	if body := findElement(doc, func(n *html.Node) bool { return n.DataAtom == atom.Body }); body != nil {
+		return body, true
+	}
+	return doc, true
+}
+
+// mdConverter converts HTML nodes to markdown.
+type mdConverter struct {
+	base *url.URL
+}
+
+// blocks converts the children of n to markdown blocks, grouping runs of
+// inline content into paragraphs.
+func (c *mdConverter) blocks(n *html.Node) []string {
+	var blocks []string
+	var inline strings.Builder
+	flush := func() {
+		if text := normalizeInline(inline.String()); text != "" {
+			blocks = append(blocks, text)
+		}
+		inline.Reset()
+	}
+	for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+		if ch.Type == html.ElementNode && blockElements[ch.DataAtom] {
+			flush()
+			blocks = append(blocks, c.block(ch)...)
+			continue
+		}
+		inline.WriteString(c.inline(ch))
+	}
+	flush()
+	return blocks
+}
+
+// block converts a block element to markdown blocks.
+func (c *mdConverter) block(n *html.Node) []string {
+	switch n.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		text := strings.ReplaceAll(c.inlineText(n), "\n", " ")
+		if text == "" {
+			return nil
+		}
+		level := int(n.Data[1] - '0')
+		return []string{strings.Repeat("#", level) + " " + text}
+	case atom.Pre:
+		return []string{c.codeBlock(n)}
+	case atom.Ul, atom.Ol:
+		if list := c.list(n); list != "" {
+			return []string{list}
+		}
+		return nil
+	case atom.Blockquote:
+		inner := strings.Join(c.blocks(n), "\n\n")
+		if inner == "" {
+			return nil
+		}
+		return []string{prefixLines(inner, "> ", ">")}
+	case atom.Table:
+		if table := c.table(n); table != "" {
+			return []string{table}
+		}
+		return nil
+	case atom.Hr:
+		return []string{"---"}
+	case atom.Dt:
+		if text := c.inlineText(n); text != "" {
+			return []string{"**" + text + "**"}
+		}
+		return nil
+	default:
+		return c.blocks(n)
+	}
+}
+
+// inline converts n to inline markdown.
+func (c *mdConverter) inline(n *html.Node) string {
+	switch n.Type {
+	case html.TextNode:
+		return collapseSpace(n.Data)
+	case html.ElementNode:
+	default:
+		return ""
+	}
+	if blockElements[n.DataAtom] {
+		return " " + strings.Join(c.block(n), " ") + " "
+	}
+	switch n.DataAtom {
+	case atom.Br:
+		return "\n"
+	case atom.A:
+		text := c.inlineText(n)
+		href := c.resolve(attrValue(n, "href"))
+		if text == "" || href == "" || strings.HasPrefix(href, "#") {
+			return text
+		}
+		return "[" + text + "](" + href + ")"
+	case atom.Img:
+		src := c.resolve(attrValue(n, "src"))
+		if src == "" || strings.HasPrefix(src, "data:") {
+			return ""
+		}
+		return "![" + collapseSpace(attrValue(n, "alt")) + "](" + src + ")"
+	case atom.Strong, atom.B:
+		return wrapInline(c.inlineText(n), "**")
+	case atom.Em, atom.I:
+		return wrapInline(c.inlineText(n), "*")
+	case atom.Del, atom.S:
+		return wrapInline(c.inlineText(n), "~~")
+	case atom.Code, atom.Kbd, atom.Samp:
+		text := collapseSpace(textContent(n))
+		if strings.TrimSpace(text) == "" {
+			return text
+		}
+		fence := "`"
+		if strings.Contains(text, "`") {
+			fence = "``"
+		}
+		return fence + text + fence
+	default:
+		var sb strings.Builder
+		for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+			sb.WriteString(c.inline(ch))
+		}
+		return sb.String()
+	}
+}
+
+// inlineText converts the children of n to normalized inline markdown.
+func (c *mdConverter) inlineText(n *html.Node) string {
+	var sb strings.Builder
+	for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+		sb.WriteString(c.inline(ch))
+	}
+	return normalizeInline(sb.String())
+}
+
+// codeBlock converts a pre element to a fenced code block.
+func (c *mdConverter) codeBlock(n *html.Node) string {
+	code := strings.TrimRight(textContent(n), "\n")
+	code = strings.TrimPrefix(code, "\n")
+	lang := codeLanguage(n)
+	if lang == "" {
+		if child := findElement(n, func(n *html.Node) bool { return n.DataAtom == atom.Code }); child != nil {
+			lang = codeLanguage(child)
+		}
+	}
+	fence := "```"
+	for strings.Contains(code, fence) {
+		fence += "`"
+	}
+	return fence + lang + "\n" + code + "\n" + fence
+}
+
+// codeLanguage returns the language named by a language-* or lang-* class.
+func codeLanguage(n *html.Node) string {
+	for _, class := range strings.Fields(attrValue(n, "class")) {
+		for _, prefix := range []string{"language-", "lang-"} {
+			if lang, ok := strings.CutPrefix(class, prefix); ok {
+				return lang
+			}
+		}
+	}
+	return ""
+}
+
+// list converts a ul or ol element to a markdown list, indenting nested
+// content under its item.
+func (c *mdConverter) list(n *html.Node) string {
+	ordered := n.DataAtom == atom.Ol
+	number := 1
+	var items []string
+	for li := n.FirstChild; li != nil; li = li.NextSibling {
+		if li.Type != html.ElementNode || li.DataAtom != atom.Li {
+			continue
+		}
+		marker := "- "
+		if ordered {
+			marker = strconv.Itoa(number) + ". "
+			number++
+		}
+		content := strings.Join(c.blocks(li), "\n")
+		items = append(items, marker+indentLines(content, strings.Repeat(" ", len(marker))))
+	}
+	return strings.Join(items, "\n")
+}
+
+// table converts a table element to a markdown table whose first row is the
+// header.
+func (c *mdConverter) table(n *html.Node) string {
+	var rows [][]string
+	var collect func(*html.Node)
+	collect = func(n *html.Node) {
+		for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+			switch ch.DataAtom {
+			case atom.Thead, atom.Tbody, atom.Tfoot:
+				collect(ch)
+			case atom.Tr:
+				var row []string
+				for cell := ch.FirstChild; cell != nil; cell = cell.NextSibling {
+					if cell.DataAtom == atom.Th || cell.DataAtom == atom.Td {
+						text := strings.ReplaceAll(c.inlineText(cell), "\n", " ")
+						row = append(row, strings.ReplaceAll(text, "|", `\|`))
+					}
+				}
+				if len(row) > 0 {
+					rows = append(rows, row)
+				}
+			}
+		}
+	}
+	collect(n)
+	if len(rows) == 0 {
+		return ""
+	}
+	cols := 0
+	for _, row := range rows {
+		cols = max(cols, len(row))
+	}
+	var sb strings.Builder
+	writeRow := func(row []string) {
+		sb.WriteString("|")
+		for i := range cols {
+			cell := ""
+			if i < len(row) {
+				cell = row[i]
+			}
+			sb.WriteString(" " + cell + " |")
+		}
+		sb.WriteString("\n")
+	}
+	writeRow(rows[0])
+	sb.WriteString("|" + strings.Repeat(" --- |", cols) + "\n")
+	for _, row := range rows[1:] {
+		writeRow(row)
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// resolve returns ref as an absolute URL, or "" for script links.
+func (c *mdConverter) resolve(ref string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" || strings.HasPrefix(strings.ToLower(ref), "javascript:") {
+		return ""
+	}
+	if strings.HasPrefix(ref, "#") || c.base == nil {
+		return ref
+	}
+	u, err := c.base.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return u.String()
+}
+
+var (
+	spaceRun       = regexp.MustCompile(`[ \t\r\n\f]+`)
+	spacesAroundNL = regexp.MustCompile(` *\n *`)
+	doubleSpace    = regexp.MustCompile(` {2,}`)
+)
+
+// collapseSpace replaces runs of whitespace with a single space.
+func collapseSpace(s string) string {
+	return spaceRun.ReplaceAllString(s, " ")
+}
+
+// normalizeInline tidies the spaces left by joining inline content.
+func normalizeInline(s string) string {
+	s = doubleSpace.ReplaceAllString(s, " ")
+	s = spacesAroundNL.ReplaceAllString(s, "\n")
+	return strings.Trim(s, " \n")
+}
+
+// wrapInline surrounds text with a markdown emphasis marker.
+func wrapInline(text, marker string) string {
+	if text == "" {
+		return ""
+	}
+	return marker + text + marker
+}
+
+// prefixLines prefixes each line of s, using blank for empty lines.
+func prefixLines(s, prefix, blank string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if line == "" {
+			lines[i] = blank
+		} else {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// indentLines indents every line of s but the first.
+func indentLines(s, indent string) string {
+	lines := strings.Split(s, "\n")
+	for i := 1; i < len(lines); i++ {
+		if lines[i] != "" {
+			lines[i] = indent + lines[i]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// textContent returns the text of n and its descendants, unmodified.
+func textContent(n *html.Node) string {
+	var sb strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			sb.WriteString(n.Data)
+		}
+		for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+			walk(ch)
+		}
+	}
+	walk(n)
+	return sb.String()
+}
+
+// walkElements calls fn for each element under n, in document order.
+func walkElements(n *html.Node, fn func(*html.Node)) {
+	for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+		if ch.Type == html.ElementNode {
+			fn(ch)
+		}
+		walkElements(ch, fn)
+	}
+}
+
+// findElement returns the first element under n matching match.
+func findElement(n *html.Node, match func(*html.Node) bool) *html.Node {
+	for ch := n.FirstChild; ch != nil; ch = ch.NextSibling {
+		if ch.Type == html.ElementNode && match(ch) {
+			return ch
+		}
+		if found := findElement(ch, match); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// removeElements removes the elements under n matching match.
+func removeElements(n *html.Node, match func(*html.Node) bool) {
+	for ch := n.FirstChild; ch != nil; {
+		next := ch.NextSibling
+		if ch.Type == html.ElementNode && match(ch) {
+			n.RemoveChild(ch)
+		} else {
+			removeElements(ch, match)
+		}
+		ch = next
+	}
+}
+
+func attr(n *html.Node, key string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+func attrValue(n *html.Node, key string) string {
+	v, _ := attr(n, key)
+	return v
+}
@@ -0,0 +1,79 @@
+package claudetool
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestHTMLToMarkdown(t *testing.T) {
+	base, _ := url.Parse("https://example.com/docs/guide.html")
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{
+			name: "main content",
+			html: `<html><head><title> The  Guide </title><script>var x</script></head><body>
+<header>Site header</header><nav><a href="/">Home</a></nav>
+<main><h1>Guide</h1><p>Read the <a href="api.html">API   docs</a> and <b>enjoy</b>.</p></main>
+<footer>Copyright</footer></body></html>`,
+			want: "# Guide\n\nRead the [API docs](https://example.com/docs/api.html) and **enjoy**.",
+		},
+		{
+			name: "body without main drops chrome",
+			html: `<body><header><h1>Site</h1></header><div>Hello<br>world</div><footer>bye</footer></body>`,
+			want: "Hello\nworld",
+		},
+		{
+			name: "single article",
+			html: `<body><nav>menu</nav><article><header><h2>Post</h2></header><p>Text</p></article><aside>ads</aside></body>`,
+			want: "## Post\n\nText",
+		},
+		{
+			name: "lists",
+			html: `<main><ul><li>one</li><li>two<ol><li>a</li><li>b</li></ol></li></ul></main>`,
+			want: "- one\n- two\n  1. a\n  2. b",
+		},
+		{
+			name: "code",
+			html: "<main><p>Run <code>go test</code>:</p><pre><code class=\"language-sh\">go test ./...\n  -v\n</code></pre></main>",
+			want: "Run `go test`:\n\n```sh\ngo test ./...\n  -v\n```",
+		},
+		{
+			name: "table",
+			html: `<main><table><thead><tr><th>Name</th><th>Value</th></tr></thead><tbody><tr><td>a|b</td><td>1</td></tr><tr><td>c</td></tr></tbody></table></main>`,
+			want: "| Name | Value |\n| --- | --- |\n| a\\|b | 1 |\n| c |  |",
+		},
+		{
+			name: "blockquote and images",
+			html: `<main><blockquote><p>One</p><p>Two</p></blockquote><img src="/logo.png" alt="Logo"><hr></main>`,
+			want: "> One\n>\n> Two\n\n![Logo](https://example.com/logo.png)\n\n---",
+		},
+		{
+			name: "hidden content",
+			html: `<main><p hidden>secret</p><div aria-hidden="true">icon</div><p>shown</p></main>`,
+			want: "shown",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := htmlToMarkdown(tt.html, base)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if doc.Markdown != tt.want {
+				t.Errorf("markdown =\n%s\nwant\n%s", doc.Markdown, tt.want)
+			}
+		})
+	}
+
+	doc, _ := htmlToMarkdown(tests[0].html, base)
+	if doc.Title != "The Guide" {
+		t.Errorf("title = %q", doc.Title)
+	}
+	doc, _ = htmlToMarkdown(`<main><h1>Heading <em>title</em></h1></main>`, base)
+	if doc.Title != "Heading title" {
+		t.Errorf("title from h1 = %q", doc.Title)
+	}
+}
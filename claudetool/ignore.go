@@ -0,0 +1,224 @@
+package claudetool
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ignoreRule is one pattern of a .gitignore file.
+type ignoreRule struct {
+	base     string   // directory of the .gitignore, slash-separated and relative to the walk base; "" for the base
+	segments []string // the pattern, split on "/"
+	anchored bool     // matches from base rather than against the name alone
+	dirOnly  bool
+	negate   bool
+}
+
+// parseIgnoreRules parses the contents of a .gitignore file in directory base.
+func parseIgnoreRules(data []byte, base string) []ignoreRule {
+	var rules []ignoreRule
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		line := strings.TrimSuffix(sc.Text(), "\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// Trailing spaces are ignored unless escaped.
+		for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, `\ `) {
+			line = line[:len(line)-1]
+		}
+		r := ignoreRule{base: base}
+		if strings.HasPrefix(line, "!") {
+			r.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			r.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		// A slash anywhere but the end anchors the pattern to base.
+		r.anchored = strings.Contains(line, "/")
+		r.segments = strings.Split(strings.TrimPrefix(line, "/"), "/")
+		rules = append(rules, r)
+	}
+	return rules
+}
+
+// match reports whether rel, a slash-separated path relative to the walk
+// base, matches r.
+func (r ignoreRule) match(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if r.base != "" {
+		rest, ok := strings.CutPrefix(rel, r.base+"/")
+		if !ok {
+			return false
+		}
+		rel = rest
+	}
+	parts := strings.Split(rel, "/")
+	if !r.anchored {
+		parts = parts[len(parts)-1:]
+	}
+	return matchSegments(r.segments, parts)
+}
+
+// ignored reports whether rel is excluded by rules. Later rules override
+// earlier ones, as in git.
+func ignored(rules []ignoreRule, rel string, isDir bool) bool {
+	for i := len(rules) - 1; i >= 0; i-- {
+		if rules[i].match(rel, isDir) {
+			return !rules[i].negate
+		}
+	}
+	return false
+}
+
+// matchSegments matches path segments against pattern segments, each of
+// which is a path.Match pattern or "**", which matches any number of
+// segments.
+func matchSegments(pattern, parts []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			if len(pattern) == 1 {
+				return len(parts) > 0
+			}
+			for i := range len(parts) + 1 {
+				if matchSegments(pattern[1:], parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(parts) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], parts[0]); !ok {
+			return false
+		}
+		pattern, parts = pattern[1:], parts[1:]
+	}
+	return len(parts) == 0
+}
+
+// findRepoRoot returns the nearest directory at or above dir that contains
+// .git, or "" if there is none.
+func findRepoRoot(dir string) string {
+	for {
+		if _, err := os.Lstat(filepath.Join(dir, ".git")); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// walkFiles calls fn for each file under root, in lexical order, skipping
+// .git directories and whatever .gitignore files exclude. The .gitignore
+// files of root's ancestors within its repository apply too. fn receives the
+// slash-separated path relative to root; returning filepath.SkipAll stops
+// the walk.
+func walkFiles(ctx context.Context, root string, fn func(rel string) error) error {
+	root = filepath.Clean(root)
+	prefix := ""
+	var rules []ignoreRule
+	if repo := findRepoRoot(root); repo != "" {
+		if rel, err := filepath.Rel(repo, root); err == nil && rel != "." {
+			prefix = filepath.ToSlash(rel)
+		}
+		if data, err := os.ReadFile(filepath.Join(repo, ".git", "info", "exclude")); err == nil {
+			rules = append(rules, parseIgnoreRules(data, "")...)
+		}
+		// Load the .gitignore files between the repository root and root.
+		if prefix != "" {
+			dir := ""
+			for _, seg := range strings.Split(prefix, "/") {
+				rules = append(rules, loadIgnoreFile(filepath.Join(repo, filepath.FromSlash(dir)), dir)...)
+				dir = path.Join(dir, seg)
+				if ignored(rules, dir, true) {
+					return nil
+				}
+			}
+		}
+	}
+
+	w := &fileWalker{ctx: ctx, root: root, prefix: prefix, fn: fn}
+	err := w.walk("", rules)
+	if err == filepath.SkipAll {
+		return nil
+	}
+	return err
+}
+
+func loadIgnoreFile(dir, rel string) []ignoreRule {
+	data, err := os.ReadFile(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		return nil
+	}
+	return parseIgnoreRules(data, rel)
+}
+
+type fileWalker struct {
+	ctx    context.Context
+	root   string
+	prefix string // root relative to the walk base
+	fn     func(rel string) error
+}
+
+// walk walks the directory rel (relative to root) with the rules that
+// apply above it.
+func (w *fileWalker) walk(rel string, rules []ignoreRule) error {
+	if err := w.ctx.Err(); err != nil {
+		return err
+	}
+	dir := filepath.Join(w.root, filepath.FromSlash(rel))
+	rules = append(slices.Clip(rules), loadIgnoreFile(dir, path.Join(w.prefix, rel))...)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil // unreadable directories are skipped
+	}
+	for _, e := range entries {
+		if e.Name() == ".git" {
+			continue
+		}
+		childRel := path.Join(rel, e.Name())
+		isDir := e.IsDir()
+		if ignored(rules, path.Join(w.prefix, childRel), isDir) {
+			continue
+		}
+		if isDir {
+			if err := w.walk(childRel, rules); err != nil {
+				return err
+			}
+			continue
+		}
+		if !e.Type().IsRegular() {
+			// Follow symlinks to files, but not to directories, which
+			// could loop.
+			fi, err := os.Stat(filepath.Join(dir, e.Name()))
+			if e.Type()&fs.ModeSymlink == 0 || err != nil || !fi.Mode().IsRegular() {
+				continue
+			}
+		}
+		if err := w.fn(childRel); err != nil {
+			return err
+		}
+	}
+	return nil
+}
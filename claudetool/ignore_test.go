@@ -0,0 +1,100 @@
+package claudetool
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestIgnoreRules(t *testing.T) {
+	rules := parseIgnoreRules([]byte(`# comment
+*.log
+!keep.log
+build/
+/root-only.txt
+docs/**/*.tmp
+`), "")
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"a.log", false, true},
+		{"sub/dir/a.log", false, true},
+		{"keep.log", false, false},
+		{"sub/keep.log", false, false},
+		{"build", true, true},
+		{"sub/build", true, true},
+		{"build", false, false},
+		{"root-only.txt", false, true},
+		{"sub/root-only.txt", false, false},
+		{"docs/a.tmp", false, true},
+		{"docs/x/y/a.tmp", false, true},
+		{"other/a.tmp", false, false},
+		{"main.go", false, false},
+	}
+	for _, tt := range tests {
+		if got := ignored(rules, tt.path, tt.isDir); got != tt.want {
+			t.Errorf("ignored(%q, dir=%v) = %v, want %v", tt.path, tt.isDir, got, tt.want)
+		}
+	}
+}
+
+func TestWalkFilesRespectsGitignore(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		".gitignore":        "*.log\nvendor/\n",
+		".git/HEAD":         "ref: refs/heads/main\n",
+		".git/info/exclude": "secret.txt\n",
+		"main.go":           "package main\n",
+		"debug.log":         "log\n",
+		"secret.txt":        "shh\n",
+		"vendor/dep/dep.go": "package dep\n",
+		"pkg/.gitignore":    "gen_*.go\n!gen_keep.go\n",
+		"pkg/pkg.go":        "package pkg\n",
+		"pkg/gen_a.go":      "package pkg\n",
+		"pkg/gen_keep.go":   "package pkg\n",
+	})
+
+	var got []string
+	if err := walkFiles(context.Background(), dir, func(rel string) error {
+		got = append(got, rel)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{".gitignore", "main.go", "pkg/.gitignore", "pkg/gen_keep.go", "pkg/pkg.go"}
+	if !slices.Equal(got, want) {
+		t.Errorf("walkFiles = %v, want %v", got, want)
+	}
+
+	// Rules from the repository root apply when walking a subdirectory.
+	got = nil
+	if err := walkFiles(context.Background(), filepath.Join(dir, "pkg"), func(rel string) error {
+		got = append(got, rel)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	want = []string{".gitignore", "gen_keep.go", "pkg.go"}
+	if !slices.Equal(got, want) {
+		t.Errorf("walkFiles(pkg) = %v, want %v", got, want)
+	}
+
+	// Nothing is walked inside an ignored directory.
+	got = nil
+	if err := os.WriteFile(filepath.Join(dir, "vendor", "x.log"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := walkFiles(context.Background(), filepath.Join(dir, "vendor", "dep"), func(rel string) error {
+		got = append(got, rel)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Errorf("walkFiles(vendor/dep) = %v, want nothing", got)
+	}
+}
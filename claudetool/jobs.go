@@ -0,0 +1,528 @@
+package claudetool
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/tgruben-circuit/percy/llm"
+)
+
+const (
+	// maxJobOutput is how much output is kept per background job. Older
+	// output is dropped.
+	maxJobOutput = 1 << 20
+	// jobOutputSlack is how far the output may grow past maxJobOutput
+	// before older output is dropped, so that it is not copied on every
+	// write.
+	jobOutputSlack = maxJobOutput / 4
+	// jobStartupWait is how long bash waits after starting a background job,
+	// so that immediate failures and startup output are reported.
+	jobStartupWait = time.Second
+	// jobKillGrace is how long a job has to exit after SIGTERM before it is
+	// killed.
+	jobKillGrace = 5 * time.Second
+	// jobTailSize is how much recent output JobInfo includes.
+	jobTailSize = 4 << 10
+)
+
+// JobManager supervises the background processes started by a
+// conversation's bash tool.
+type JobManager struct {
+	mu     sync.Mutex
+	jobs   []*Job
+	nextID int
+	closed bool
+}
+
+// NewJobManager returns an empty JobManager.
+func NewJobManager() *JobManager {
+	return &JobManager{}
+}
+
+// Job is a background process.
+type Job struct {
+	ID        string
+	Command   string
+	Dir       string
+	StartedAt time.Time
+
+	cmd  *exec.Cmd
+	out  *jobOutput
+	done chan struct{}
+
+	mu       sync.Mutex
+	endedAt  time.Time
+	exitCode int
+	killed   bool
+	readPos  int64 // output already returned by job_output
+}
+
+// JobInfo describes a job for the API.
+type JobInfo struct {
+	ID         string     `json:"id"`
+	Command    string     `json:"command"`
+	WorkingDir string     `json:"working_dir"`
+	PID        int        `json:"pid"`
+	StartedAt  time.Time  `json:"started_at"`
+	Running    bool       `json:"running"`
+	EndedAt    *time.Time `json:"ended_at,omitempty"`
+	ExitCode   *int       `json:"exit_code,omitempty"`
+	Killed     bool       `json:"killed"`
+	// OutputBytes is the total output written, including dropped output.
+	OutputBytes int64 `json:"output_bytes"`
+	// Tail is the most recent output.
+	Tail string `json:"tail"`
+}
+
+// Start runs cmd in the background. cmd must not have been started and
+// should put the process in its own process group, so that Kill stops its
+// children too. Its output is captured by the job.
+func (m *JobManager) Start(cmd *exec.Cmd, command string) (*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil, errors.New("background jobs have been shut down")
+	}
+
+	out := newJobOutput()
+	cmd.Stdout = out
+	cmd.Stderr = out
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	m.nextID++
+	job := &Job{
+		ID:        fmt.Sprintf("job-%d", m.nextID),
+		Command:   command,
+		Dir:       cmd.Dir,
+		StartedAt: time.Now(),
+		cmd:       cmd,
+		out:       out,
+		done:      make(chan struct{}),
+	}
+	m.jobs = append(m.jobs, job)
+
+	go func() {
+		_ = cmd.Wait()
+		job.mu.Lock()
+		job.endedAt = time.Now()
+		job.exitCode = cmd.ProcessState.ExitCode()
+		job.mu.Unlock()
+		close(job.done)
+		out.notify()
+	}()
+	return job, nil
+}
+
+// Get returns the job with the given ID, or nil.
+func (m *JobManager) Get(id string) *Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, j := range m.jobs {
+		if j.ID == id {
+			return j
+		}
+	}
+	return nil
+}
+
+// List describes all jobs, oldest first.
+func (m *JobManager) List() []JobInfo {
+	m.mu.Lock()
+	jobs := slices.Clone(m.jobs)
+	m.mu.Unlock()
+
+	infos := make([]JobInfo, 0, len(jobs))
+	for _, j := range jobs {
+		infos = append(infos, j.Info())
+	}
+	return infos
+}
+
+// HasRunning reports whether any job is still running.
+func (m *JobManager) HasRunning() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, j := range m.jobs {
+		if j.Running() {
+			return true
+		}
+	}
+	return false
+}
+
+// Close kills all running jobs and prevents new ones from starting.
+func (m *JobManager) Close() {
+	m.mu.Lock()
+	m.closed = true
+	jobs := slices.Clone(m.jobs)
+	m.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, j := range jobs {
+		wg.Go(j.Kill)
+	}
+	wg.Wait()
+}
+
+// Done returns a channel that is closed when the job exits.
+func (j *Job) Done() <-chan struct{} {
+	return j.done
+}
+
+// Running reports whether the job's process has not yet exited.
+func (j *Job) Running() bool {
+	select {
+	case <-j.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// Kill stops the job's process group, first with SIGTERM and then, if it
+// does not exit within jobKillGrace, with SIGKILL. It returns once the job
+// has exited, or after a further jobKillGrace if it still has not.
+func (j *Job) Kill() {
+	if !j.Running() {
+		return
+	}
+	j.mu.Lock()
+	j.killed = true
+	j.mu.Unlock()
+
+	pid := j.cmd.Process.Pid
+	_ = syscall.Kill(-pid, syscall.SIGTERM)
+	select {
+	case <-j.done:
+		return
+	case <-time.After(jobKillGrace):
+	}
+	_ = syscall.Kill(-pid, syscall.SIGKILL)
+	select {
+	case <-j.done:
+	case <-time.After(jobKillGrace):
+		slog.Warn("background job did not exit after SIGKILL", "job", j.ID, "pid", pid)
+	}
+}
+
+// Info describes the job.
+func (j *Job) Info() JobInfo {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	info := JobInfo{
+		ID:          j.ID,
+		Command:     j.Command,
+		WorkingDir:  j.Dir,
+		PID:         j.cmd.Process.Pid,
+		StartedAt:   j.StartedAt,
+		Running:     j.Running(),
+		Killed:      j.killed,
+		OutputBytes: j.out.size(),
+	}
+	if !info.Running {
+		endedAt, exitCode := j.endedAt, j.exitCode
+		info.EndedAt = &endedAt
+		info.ExitCode = &exitCode
+	}
+	tail, _ := j.out.since(max(info.OutputBytes-jobTailSize, 0))
+	info.Tail = tail
+	return info
+}
+
+// status summarizes the job's state for the LLM.
+func (j *Job) status() string {
+	info := j.Info()
+	switch {
+	case info.Running:
+		return fmt.Sprintf("%s (pid %d) is running", j.ID, info.PID)
+	case info.Killed:
+		return fmt.Sprintf("%s was killed", j.ID)
+	default:
+		return fmt.Sprintf("%s exited with status %d", j.ID, *info.ExitCode)
+	}
+}
+
+// readNew returns the output written since the previous readNew, noting
+// any output that was dropped in between.
+func (j *Job) readNew() string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	data, dropped := j.out.since(j.readPos)
+	j.readPos += dropped + int64(len(data))
+	if dropped > 0 {
+		data = fmt.Sprintf("[%s of earlier output dropped]\n%s", humanizeBytes(int(dropped)), data)
+	}
+	return data
+}
+
+// waitFor waits until re matches the output not yet read, the job exits,
+// or timeout elapses. It reports whether re matched.
+func (j *Job) waitFor(ctx context.Context, re *regexp.Regexp, timeout time.Duration) bool {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		changed := j.out.changed()
+		j.mu.Lock()
+		data, _ := j.out.since(j.readPos)
+		j.mu.Unlock()
+		if re.MatchString(data) {
+			return true
+		}
+		if !j.Running() {
+			return false
+		}
+		select {
+		case <-changed:
+		case <-timer.C:
+			return false
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// jobOutput keeps at least the most recent maxJobOutput bytes written to it.
+type jobOutput struct {
+	mu      sync.Mutex
+	buf     []byte
+	total   int64
+	changes chan struct{} // closed and replaced on every write
+}
+
+func newJobOutput() *jobOutput {
+	return &jobOutput{changes: make(chan struct{})}
+}
+
+func (o *jobOutput) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	o.buf = append(o.buf, p...)
+	if len(o.buf) > maxJobOutput+jobOutputSlack {
+		o.buf = append(o.buf[:0], o.buf[len(o.buf)-maxJobOutput:]...)
+	}
+	o.total += int64(len(p))
+	o.mu.Unlock()
+	o.notify()
+	return len(p), nil
+}
+
+func (o *jobOutput) notify() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	close(o.changes)
+	o.changes = make(chan struct{})
+}
+
+// changed returns a channel that is closed on the next write.
+func (o *jobOutput) changed() <-chan struct{} {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.changes
+}
+
+func (o *jobOutput) size() int64 {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.total
+}
+
+// since returns the output from offset pos on, and how many bytes from pos
+// on were dropped and are not included.
+func (o *jobOutput) since(pos int64) (string, int64) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	start := o.total - int64(len(o.buf))
+	var dropped int64
+	if pos < start {
+		dropped = start - pos
+		pos = start
+	}
+	return string(o.buf[pos-start:]), dropped
+}
+
+// JobOutputTool reads the output of background jobs started by bash.
+type JobOutputTool struct {
+	Jobs *JobManager
+}
+
+const (
+	jobOutputName        = "job_output"
+	jobOutputDescription = `Reads new output from a background job started with bash background=true.
+
+Each call returns the output written since the previous call, and the job's status.
+Set wait_for to a regular expression to wait until it appears in new output (e.g. a server's
+"listening on" line), the job exits, or timeout_seconds elapses.`
+
+	jobOutputInputSchema = `{
+  "type": "object",
+  "required": ["id"],
+  "properties": {
+    "id": {
+      "type": "string",
+      "description": "Job ID returned by bash"
+    },
+    "wait_for": {
+      "type": "string",
+      "description": "Regular expression (RE2) to wait for in new output"
+    },
+    "timeout_seconds": {
+      "type": "integer",
+      "description": "How long to wait for wait_for (default: 30, max: 600)"
+    }
+  }
+}`
+
+	jobKillName        = "job_kill"
+	jobKillDescription = `Stops a background job started with bash background=true, along with any processes it started.
+Returns the job's remaining output.`
+
+	jobKillInputSchema = `{
+  "type": "object",
+  "required": ["id"],
+  "properties": {
+    "id": {
+      "type": "string",
+      "description": "Job ID returned by bash"
+    }
+  }
+}`
+
+	defaultJobWait = 30 * time.Second
+	maxJobWait     = 10 * time.Minute
+)
+
+type jobOutputInput struct {
+	ID             string `json:"id"`
+	WaitFor        string `json:"wait_for"`
+	TimeoutSeconds int    `json:"timeout_seconds"`
+}
+
+// Tool returns an llm.Tool for reading job output.
+func (t *JobOutputTool) Tool() *llm.Tool {
+	return &llm.Tool{
+		Name:        jobOutputName,
+		Description: jobOutputDescription,
+		InputSchema: llm.MustSchema(jobOutputInputSchema),
+		Run:         t.Run,
+	}
+}
+
+// Run returns the job's new output, waiting for a pattern if requested.
+func (t *JobOutputTool) Run(ctx context.Context, m json.RawMessage) llm.ToolOut {
+	var input jobOutputInput
+	if err := json.Unmarshal(m, &input); err != nil {
+		return llm.ErrorfToolOut("failed to unmarshal job_output input: %w", err)
+	}
+	job, err := lookupJob(t.Jobs, input.ID)
+	if err != nil {
+		return llm.ErrorToolOut(err)
+	}
+
+	var note string
+	if input.WaitFor != "" {
+		re, err := regexp.Compile(input.WaitFor)
+		if err != nil {
+			return llm.ErrorfToolOut("invalid wait_for pattern: %w", err)
+		}
+		timeout := defaultJobWait
+		if input.TimeoutSeconds > 0 {
+			timeout = min(time.Duration(input.TimeoutSeconds)*time.Second, maxJobWait)
+		}
+		if job.waitFor(ctx, re, timeout) {
+			note = fmt.Sprintf("Found %q.", input.WaitFor)
+		} else if job.Running() {
+			note = fmt.Sprintf("%q not found within %s.", input.WaitFor, timeout)
+		} else {
+			note = fmt.Sprintf("%q not found before the job exited.", input.WaitFor)
+		}
+	}
+
+	return llm.ToolOut{LLMContent: llm.TextContent(formatJobOutput(job, note, job.readNew())), Display: job.Info()}
+}
+
+// JobKillTool stops background jobs started by bash.
+type JobKillTool struct {
+	Jobs *JobManager
+}
+
+type jobKillInput struct {
+	ID string `json:"id"`
+}
+
+// Tool returns an llm.Tool for killing jobs.
+func (t *JobKillTool) Tool() *llm.Tool {
+	return &llm.Tool{
+		Name:        jobKillName,
+		Description: jobKillDescription,
+		InputSchema: llm.MustSchema(jobKillInputSchema),
+		Run:         t.Run,
+	}
+}
+
+// Run kills the job.
+func (t *JobKillTool) Run(ctx context.Context, m json.RawMessage) llm.ToolOut {
+	var input jobKillInput
+	if err := json.Unmarshal(m, &input); err != nil {
+		return llm.ErrorfToolOut("failed to unmarshal job_kill input: %w", err)
+	}
+	job, err := lookupJob(t.Jobs, input.ID)
+	if err != nil {
+		return llm.ErrorToolOut(err)
+	}
+	job.Kill()
+	return llm.ToolOut{LLMContent: llm.TextContent(formatJobOutput(job, "", job.readNew())), Display: job.Info()}
+}
+
+// lookupJob returns the job with the given ID, or an error listing the
+// known jobs.
+func lookupJob(jobs *JobManager, id string) (*Job, error) {
+	if jobs == nil {
+		return nil, errors.New("background jobs are not available")
+	}
+	if job := jobs.Get(id); job != nil {
+		return job, nil
+	}
+	var known []string
+	for _, info := range jobs.List() {
+		state := "exited"
+		if info.Running {
+			state = "running"
+		}
+		known = append(known, fmt.Sprintf("%s (%s: %s)", info.ID, state, info.Command))
+	}
+	if len(known) == 0 {
+		return nil, fmt.Errorf("unknown job %q; no background jobs have been started", id)
+	}
+	return nil, fmt.Errorf("unknown job %q; jobs: %s", id, strings.Join(known, ", "))
+}
+
+// formatJobOutput reports a job's status and output to the LLM.
+func formatJobOutput(job *Job, note, output string) string {
+	var sb strings.Builder
+	sb.WriteString(job.status() + ".")
+	if note != "" {
+		sb.WriteString(" " + note)
+	}
+	sb.WriteString("\n")
+	if output == "" {
+		sb.WriteString("[no new output]")
+		return sb.String()
+	}
+	out, err := formatForegroundBashOutput(output)
+	if err != nil {
+		out = output
+	}
+	sb.WriteString(out)
+	return sb.String()
+}
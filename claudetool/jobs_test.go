@@ -0,0 +1,129 @@
+package claudetool
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBackgroundJobs(t *testing.T) {
+	jobs := NewJobManager()
+	defer jobs.Close()
+	bash := &BashTool{WorkingDir: NewMutableWorkingDir(t.TempDir()), Jobs: jobs}
+	output := &JobOutputTool{Jobs: jobs}
+	kill := &JobKillTool{Jobs: jobs}
+
+	out := bash.Run(context.Background(), json.RawMessage(`{"command": "echo starting; echo ready; sleep 60", "background": true}`))
+	if out.Error != nil {
+		t.Fatal(out.Error)
+	}
+	if display := out.Display.(BashDisplayData); display.JobID != "job-1" {
+		t.Fatalf("display job ID = %q, want job-1", display.JobID)
+	}
+	text := out.LLMContent[0].Text
+	if !strings.Contains(text, "job-1 (pid") {
+		t.Fatalf("unexpected startup output: %q", text)
+	}
+	out = output.Run(context.Background(), json.RawMessage(`{"id": "job-1", "wait_for": "ready", "timeout_seconds": 30}`))
+	if out.Error != nil {
+		t.Fatal(out.Error)
+	}
+	text += out.LLMContent[0].Text
+	if !strings.Contains(text, "starting\nready\n") {
+		t.Fatalf("unexpected output: %q", text)
+	}
+
+	// Output is incremental.
+	out = output.Run(context.Background(), json.RawMessage(`{"id": "job-1"}`))
+	if out.Error != nil || !strings.Contains(out.LLMContent[0].Text, "[no new output]") {
+		t.Fatalf("expected no new output, got %v %v", out.LLMContent, out.Error)
+	}
+
+	// Waiting for a pattern that never appears times out.
+	start := time.Now()
+	out = output.Run(context.Background(), json.RawMessage(`{"id": "job-1", "wait_for": "never", "timeout_seconds": 1}`))
+	if out.Error != nil || !strings.Contains(out.LLMContent[0].Text, "not found within 1s") {
+		t.Fatalf("expected wait timeout, got %v %v", out.LLMContent, out.Error)
+	}
+	if time.Since(start) < time.Second {
+		t.Errorf("wait returned early")
+	}
+
+	out = kill.Run(context.Background(), json.RawMessage(`{"id": "job-1"}`))
+	if out.Error != nil || !strings.Contains(out.LLMContent[0].Text, "job-1 was killed") {
+		t.Fatalf("unexpected kill result: %v %v", out.LLMContent, out.Error)
+	}
+	if info := jobs.Get("job-1").Info(); info.Running || !info.Killed || info.ExitCode == nil {
+		t.Errorf("job still running after kill: %+v", info)
+	}
+	if jobs.HasRunning() {
+		t.Error("HasRunning after kill")
+	}
+
+	out = output.Run(context.Background(), json.RawMessage(`{"id": "job-9"}`))
+	if out.Error == nil || !strings.Contains(out.Error.Error(), "job-1 (exited") {
+		t.Errorf("expected unknown job error listing job-1, got %v", out.Error)
+	}
+}
+
+func TestBackgroundJobWaitFor(t *testing.T) {
+	jobs := NewJobManager()
+	defer jobs.Close()
+	bash := &BashTool{WorkingDir: NewMutableWorkingDir(t.TempDir()), Jobs: jobs}
+	output := &JobOutputTool{Jobs: jobs}
+
+	out := bash.Run(context.Background(), json.RawMessage(`{"command": "sleep 1; echo listening on :8080; sleep 60", "background": true}`))
+	if out.Error != nil {
+		t.Fatal(out.Error)
+	}
+	out = output.Run(context.Background(), json.RawMessage(`{"id": "job-1", "wait_for": "listening on :\\d+", "timeout_seconds": 30}`))
+	text := out.LLMContent[0].Text
+	if out.Error != nil || !strings.Contains(text, "Found") || !strings.Contains(text, "listening on :8080") {
+		t.Fatalf("unexpected wait result: %q %v", text, out.Error)
+	}
+
+	// A job that exits is reported with its status.
+	out = bash.Run(context.Background(), json.RawMessage(`{"command": "exit 3", "background": true}`))
+	if out.Error != nil {
+		t.Fatal(out.Error)
+	}
+	<-jobs.Get("job-2").Done()
+	out = output.Run(context.Background(), json.RawMessage(`{"id": "job-2"}`))
+	if out.Error != nil || !strings.Contains(out.LLMContent[0].Text, "job-2 exited with status 3") {
+		t.Fatalf("unexpected result for exited job: %v %v", out.LLMContent, out.Error)
+	}
+
+	// Close kills running jobs and rejects new ones.
+	jobs.Close()
+	if jobs.Get("job-1").Running() {
+		t.Error("job-1 still running after Close")
+	}
+	out = bash.Run(context.Background(), json.RawMessage(`{"command": "true", "background": true}`))
+	if out.Error == nil {
+		t.Error("expected error starting a job after Close")
+	}
+}
+
+func TestJobOutputDropsOldOutput(t *testing.T) {
+	o := newJobOutput()
+	chunk := strings.Repeat("x", maxJobOutput/2)
+	o.Write([]byte(chunk))
+	o.Write([]byte(chunk))
+	o.Write([]byte("tail"))
+	// Output within the slack is kept rather than trimmed on every write.
+	if data, dropped := o.since(0); dropped != 0 || len(data) != maxJobOutput+4 {
+		t.Errorf("since(0) = %d bytes, %d dropped before exceeding the slack", len(data), dropped)
+	}
+	o.Write([]byte(chunk))
+	o.Write([]byte("tail"))
+	data, dropped := o.since(0)
+	if dropped != int64(len(chunk))+4 || len(data) != maxJobOutput+4 || !strings.HasSuffix(data, "tail") {
+		t.Errorf("since(0) = %d bytes, %d dropped", len(data), dropped)
+	}
+	data, dropped = o.since(o.size() - 4)
+	if data != "tail" || dropped != 0 {
+		t.Errorf("since(end-4) = %q, %d dropped", data, dropped)
+	}
+}
@@ -22,6 +22,7 @@ type LLMServiceProvider interface {
 type KeywordTool struct {
 	llmProvider LLMServiceProvider
 	workingDir  *MutableWorkingDir
+	modelID     string // the conversation's model, used if no route applies
 }
 
 // NewKeywordTool creates a new keyword tool with the given LLM provider
@@ -89,6 +90,14 @@ type keywordInput struct {
 	SearchTerms []string `json:"search_terms"`
 }
 
+// keywordResult is the relevance filter's structured answer.
+type keywordResult struct {
+	Files []struct {
+		Path   string `json:"path" description:"absolute file path"`
+		Reason string `json:"reason" description:"concise relevance explanation"`
+	} `json:"files" description:"relevant files in decreasing order of relevance"`
+}
+
 //go:embed keyword_system_prompt.txt
 var keywordSystemPrompt string
 
@@ -149,8 +158,7 @@ func (k *KeywordTool) keywordRun(ctx context.Context, m json.RawMessage) llm.Too
 		keep = keep[:len(keep)-1]
 	}
 
-	// Select the best available LLM service
-	llmService, err := k.selectBestLLM(k.llmProvider)
+	llmService, err := serviceForTask(k.llmProvider, llm.TaskKeyword, k.modelID)
 	if err != nil {
 		return llm.ErrorfToolOut("failed to get LLM service: %w", err)
 	}
@@ -174,15 +182,22 @@ func (k *KeywordTool) keywordRun(ctx context.Context, m json.RawMessage) llm.Too
 		System:   system,
 	}
 
-	resp, err := llmService.Do(ctx, req)
+	result, err := llm.DoStructured(ctx, llmService, req, &llm.StructuredOptions[keywordResult]{
+		Name:        "report_relevant_files",
+		Description: "Report the files relevant to the query.",
+	})
 	if err != nil {
-		return llm.ErrorfToolOut("failed to send relevance filtering message: %w", err)
-	}
-	if len(resp.Content) != 1 {
-		return llm.ErrorfToolOut("unexpected number of messages (%d) in relevance filtering response: %v", len(resp.Content), resp.Content)
+		return llm.ErrorfToolOut("failed to filter keyword search results: %w", err)
 	}
 
-	filtered := resp.Content[0].Text
+	filtered := "No relevant files found"
+	if len(result.Files) > 0 {
+		var buf strings.Builder
+		for _, f := range result.Files {
+			fmt.Fprintf(&buf, "%s: %s\n", f.Path, f.Reason)
+		}
+		filtered = buf.String()
+	}
 
 	slog.InfoContext(ctx, "keyword search results processed",
 		"bytes", len(out),
@@ -192,7 +207,7 @@ func (k *KeywordTool) keywordRun(ctx context.Context, m json.RawMessage) llm.Too
 		"filtered", filtered,
 	)
 
-	return llm.ToolOut{LLMContent: llm.TextContent(resp.Content[0].Text)}
+	return llm.ToolOut{LLMContent: llm.TextContent(filtered)}
 }
 
 func ripgrep(ctx context.Context, wd string, terms []string) (string, error) {
@@ -215,23 +230,28 @@ func ripgrep(ctx context.Context, wd string, terms []string) (string, error) {
 	return outStr, nil
 }
 
-// selectBestLLM selects the best available LLM service for keyword search
-func (k *KeywordTool) selectBestLLM(provider LLMServiceProvider) (llm.Service, error) {
-	// Preferred models in order of preference for keyword search (fast, cheap models preferred)
-	preferredModels := []string{"qwen3-coder-fireworks", "gpt-5-thinking-mini", "gpt5-mini", "claude-sonnet-4.5", "predictable"}
+// taskRouter is implemented by LLM providers with a model routing policy,
+// such as models.Manager.
+type taskRouter interface {
+	ServiceForTask(task llm.Task, modelID string) (llm.Service, error)
+}
 
-	for _, model := range preferredModels {
-		svc, err := provider.GetService(model)
-		if err == nil {
+// serviceForTask picks the model for a side task. Providers without a
+// routing policy fall back to modelID, then to any available model.
+func serviceForTask(provider LLMServiceProvider, task llm.Task, modelID string) (llm.Service, error) {
+	if provider == nil {
+		return nil, fmt.Errorf("no LLM provider available")
+	}
+	if r, ok := provider.(taskRouter); ok {
+		return r.ServiceForTask(task, modelID)
+	}
+	if modelID != "" {
+		if svc, err := provider.GetService(modelID); err == nil {
 			return svc, nil
 		}
 	}
-
-	// If no preferred model is available, try any available model
-	available := provider.GetAvailableModels()
-	if len(available) > 0 {
+	if available := provider.GetAvailableModels(); len(available) > 0 {
 		return provider.GetService(available[0])
 	}
-
 	return nil, fmt.Errorf("no LLM services available")
 }
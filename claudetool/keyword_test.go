@@ -17,6 +17,14 @@ type mockLLMProvider struct{}
 type mockService struct{}
 
 func (m *mockService) Do(ctx context.Context, req *llm.Request) (*llm.Response, error) {
+	if tc := req.ToolChoice; tc != nil && tc.Type == llm.ToolChoiceTypeTool {
+		return &llm.Response{Content: []llm.Content{{
+			ID:        "toolu_1",
+			Type:      llm.ContentTypeToolUse,
+			ToolName:  tc.Name,
+			ToolInput: json.RawMessage(`{"files":[{"path":"/test.txt","reason":"test response"}]}`),
+		}}}, nil
+	}
 	return &llm.Response{Content: llm.TextContent("test response")}, nil
 }
 
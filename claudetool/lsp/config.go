@@ -0,0 +1,170 @@
+package lsp
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// RepoConfigPath is where a repository declares its LSP servers, relative to
+// the repository root.
+const RepoConfigPath = ".percy/lsp.json"
+
+// repoConfig is the format of RepoConfigPath.
+type repoConfig struct {
+	Servers []ServerConfig `json:"servers"`
+}
+
+// MergeServers returns base with overrides applied. An override with the
+// name of a base server replaces the fields it sets; any other override is
+// added. Disabled servers are kept so a later merge can still see them.
+func MergeServers(base, overrides []ServerConfig) []ServerConfig {
+	out := make([]ServerConfig, len(base), len(base)+len(overrides))
+	copy(out, base)
+	for _, o := range overrides {
+		i := indexServer(out, o.Name)
+		if i < 0 {
+			out = append(out, o)
+			continue
+		}
+		cfg := &out[i]
+		if o.Command != "" {
+			cfg.Command = o.Command
+		}
+		if o.Args != nil {
+			cfg.Args = o.Args
+		}
+		if o.Extensions != nil {
+			cfg.Extensions = o.Extensions
+		}
+		if o.InstallHint != "" {
+			cfg.InstallHint = o.InstallHint
+		}
+		if o.InitializationOptions != nil {
+			cfg.InitializationOptions = o.InitializationOptions
+		}
+		if o.RootMarkers != nil {
+			cfg.RootMarkers = o.RootMarkers
+		}
+		cfg.Disabled = o.Disabled
+	}
+	return out
+}
+
+func indexServer(configs []ServerConfig, name string) int {
+	for i := range configs {
+		if configs[i].Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// ValidateServers reports the first server config that cannot be used. Check
+// the result of MergeServers, since an override need only set what it changes.
+func ValidateServers(configs []ServerConfig) error {
+	for _, cfg := range configs {
+		if cfg.Name == "" {
+			return fmt.Errorf("LSP server with command %q has no name", cfg.Command)
+		}
+		if cfg.Disabled {
+			continue
+		}
+		if cfg.Command == "" {
+			return fmt.Errorf("LSP server %q has no command", cfg.Name)
+		}
+		if len(cfg.Extensions) == 0 {
+			return fmt.Errorf("LSP server %q has no extensions", cfg.Name)
+		}
+	}
+	return nil
+}
+
+// loadRepoServers reads the servers declared in repoRoot's RepoConfigPath.
+// A missing file declares none.
+func loadRepoServers(repoRoot string) ([]ServerConfig, error) {
+	data, err := os.ReadFile(filepath.Join(repoRoot, RepoConfigPath))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var cfg repoConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", RepoConfigPath, err)
+	}
+	return cfg.Servers, nil
+}
+
+// restrictRepoServers limits servers declared by an untrusted repository to
+// settings that cannot choose what runs: args, initialization options, root
+// markers and disabled, for servers in base. It returns the restricted
+// servers and the names of those that set a command, extensions or install
+// hint, or that base does not declare.
+func restrictRepoServers(base, servers []ServerConfig) (restricted []ServerConfig, ignored []string) {
+	for _, s := range servers {
+		if indexServer(base, s.Name) < 0 {
+			ignored = append(ignored, s.Name)
+			continue
+		}
+		if s.Command != "" || s.Extensions != nil || s.InstallHint != "" {
+			ignored = append(ignored, s.Name)
+		}
+		restricted = append(restricted, ServerConfig{
+			Name:                  s.Name,
+			Args:                  s.Args,
+			InitializationOptions: s.InitializationOptions,
+			RootMarkers:           s.RootMarkers,
+			Disabled:              s.Disabled,
+		})
+	}
+	return restricted, ignored
+}
+
+// registry maps file extensions to the servers that handle them.
+type registry struct {
+	configs     []ServerConfig
+	extToConfig map[string]*ServerConfig
+}
+
+// newRegistry indexes the enabled servers in configs. When two servers
+// claim an extension, the later one wins.
+func newRegistry(configs []ServerConfig) *registry {
+	r := &registry{extToConfig: make(map[string]*ServerConfig)}
+	for _, cfg := range configs {
+		if !cfg.Disabled {
+			r.configs = append(r.configs, cfg)
+		}
+	}
+	for i := range r.configs {
+		for _, ext := range r.configs[i].Extensions {
+			r.extToConfig[ext] = &r.configs[i]
+		}
+	}
+	return r
+}
+
+// projectRoot returns the directory to serve filePath from: the nearest
+// directory at or above the file, up to repoRoot, that contains one of
+// markers. It returns repoRoot if there is none.
+func projectRoot(filePath, repoRoot string, markers []string) string {
+	if len(markers) == 0 {
+		return repoRoot
+	}
+	rel, err := filepath.Rel(repoRoot, filePath)
+	if err != nil || !filepath.IsLocal(rel) {
+		return repoRoot
+	}
+	for dir := filepath.Dir(filePath); ; dir = filepath.Dir(dir) {
+		for _, marker := range markers {
+			if _, err := os.Stat(filepath.Join(dir, marker)); err == nil {
+				return dir
+			}
+		}
+		if dir == repoRoot || dir == filepath.Dir(dir) {
+			return repoRoot
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package lsp
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// editDiagnosticsTimeout bounds each wait for a server's diagnostics
+	// around an edit.
+	editDiagnosticsTimeout = 3 * time.Second
+
+	// maxEditDiagnostics caps the errors reported for a single edit.
+	maxEditDiagnostics = 10
+)
+
+// EditCheck reports the errors an edit to a file introduces. Create one with
+// Manager.BeginEdit before writing the file and call Finish afterwards.
+type EditCheck struct {
+	manager     *Manager
+	path        string
+	baseline    []Diagnostic
+	hasBaseline bool
+}
+
+// BeginEdit records the diagnostics for filePath before it is edited, so that
+// Finish can tell which errors the edit introduced. It only asks a server
+// that is already running; without one, Finish reports every error. It
+// returns nil if no server handles the file.
+func (m *Manager) BeginEdit(ctx context.Context, filePath string) *EditCheck {
+	if m.ConfigForExt(filepath.Ext(filePath)) == nil {
+		return nil
+	}
+	c := &EditCheck{manager: m, path: filePath}
+	srv := m.runningServer(filePath)
+	if srv == nil {
+		return c
+	}
+	if diags, ok := srv.openFileDiagnostics(fileURI(filePath)); ok {
+		c.baseline, c.hasBaseline = diags, true
+		return c
+	}
+	if _, err := os.Stat(filePath); err != nil {
+		// A new file has no errors to begin with.
+		c.hasBaseline = true
+		return c
+	}
+	diags, ok, err := srv.RefreshDiagnostics(ctx, filePath, editDiagnosticsTimeout)
+	if err != nil {
+		slog.DebugContext(ctx, "lsp: baseline diagnostics failed", "file", filePath, "error", err)
+	}
+	c.baseline, c.hasBaseline = diags, ok
+	return c
+}
+
+// Finish sends the edited file to its server, waits briefly for diagnostics,
+// and describes the errors the edit introduced. It returns "" if there are
+// none or the server did not answer in time.
+func (c *EditCheck) Finish(ctx context.Context) string {
+	if c == nil {
+		return ""
+	}
+	srv, err := c.manager.GetServer(ctx, c.path)
+	if err != nil {
+		slog.DebugContext(ctx, "lsp: no server for edit diagnostics", "file", c.path, "error", err)
+		return ""
+	}
+	diags, ok, err := srv.RefreshDiagnostics(ctx, c.path, editDiagnosticsTimeout)
+	if err != nil || !ok {
+		return ""
+	}
+	errs := newErrors(c.baseline, diags)
+	if len(errs) == 0 {
+		return ""
+	}
+	return formatEditErrors(errs, c.path, c.manager.workingDirFn(), c.hasBaseline)
+}
+
+// newErrors returns the errors in after that are not in before. Errors are
+// matched by message and source rather than position, since edits move
+// lines around.
+func newErrors(before, after []Diagnostic) []Diagnostic {
+	seen := make(map[string]int)
+	for _, d := range before {
+		if d.Severity == DiagnosticSeverityError {
+			seen[d.Source+"\x00"+d.Message]++
+		}
+	}
+	var out []Diagnostic
+	for _, d := range after {
+		if d.Severity != DiagnosticSeverityError {
+			continue
+		}
+		key := d.Source + "\x00" + d.Message
+		if seen[key] > 0 {
+			seen[key]--
+			continue
+		}
+		out = append(out, d)
+	}
+	return out
+}
+
+// formatEditErrors formats the errors found after an edit, up to
+// maxEditDiagnostics of them.
+func formatEditErrors(errs []Diagnostic, filePath, wd string, onlyNew bool) string {
+	var sb strings.Builder
+	if onlyNew {
+		fmt.Fprintf(&sb, "New errors in %s after this edit:\n", relativePath(filePath, wd))
+	} else {
+		fmt.Fprintf(&sb, "Errors in %s after this edit:\n", relativePath(filePath, wd))
+	}
+	for i, d := range errs {
+		if i == maxEditDiagnostics {
+			fmt.Fprintf(&sb, "  ... and %d more\n", len(errs)-i)
+			break
+		}
+		src := ""
+		if d.Source != "" {
+			src = fmt.Sprintf(" [%s]", d.Source)
+		}
+		fmt.Fprintf(&sb, "  L%d:%d: %s%s\n", d.Range.Start.Line+1, d.Range.Start.Character+1, d.Message, src)
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
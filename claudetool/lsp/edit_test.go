@@ -0,0 +1,61 @@
+package lsp
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestNewErrors(t *testing.T) {
+	diag := func(line int, sev DiagnosticSeverity, msg string) Diagnostic {
+		return Diagnostic{
+			Range:    Range{Start: Position{Line: line}},
+			Severity: sev,
+			Source:   "compiler",
+			Message:  msg,
+		}
+	}
+	before := []Diagnostic{
+		diag(3, DiagnosticSeverityError, "undefined: x"),
+		diag(5, DiagnosticSeverityWarning, "unused variable"),
+	}
+	after := []Diagnostic{
+		diag(4, DiagnosticSeverityError, "undefined: x"), // moved, not new
+		diag(6, DiagnosticSeverityError, "undefined: x"), // a second occurrence is new
+		diag(7, DiagnosticSeverityError, "missing return"),
+		diag(8, DiagnosticSeverityWarning, "shadowed"), // warnings are ignored
+	}
+	got := newErrors(before, after)
+	if len(got) != 2 {
+		t.Fatalf("newErrors returned %d diagnostics, want 2: %+v", len(got), got)
+	}
+	if got[0].Range.Start.Line != 6 || got[1].Message != "missing return" {
+		t.Errorf("newErrors = %+v", got)
+	}
+}
+
+func TestFormatEditErrors(t *testing.T) {
+	errs := []Diagnostic{{
+		Range:    Range{Start: Position{Line: 9, Character: 2}},
+		Severity: DiagnosticSeverityError,
+		Source:   "compiler",
+		Message:  "undefined: foo",
+	}}
+	got := formatEditErrors(errs, "/repo/main.go", "/repo", true)
+	want := "New errors in main.go after this edit:\n  L10:3: undefined: foo [compiler]"
+	if got != want {
+		t.Errorf("formatEditErrors = %q, want %q", got, want)
+	}
+	if got := formatEditErrors(errs, "/repo/main.go", "/repo", false); !strings.HasPrefix(got, "Errors in main.go") {
+		t.Errorf("formatEditErrors without baseline = %q", got)
+	}
+
+	errs = nil
+	for i := range maxEditDiagnostics + 3 {
+		errs = append(errs, Diagnostic{Severity: DiagnosticSeverityError, Message: fmt.Sprintf("error %d", i)})
+	}
+	got = formatEditErrors(errs, "/repo/main.go", "/repo", true)
+	if !strings.HasSuffix(got, "  ... and 3 more") {
+		t.Errorf("formatEditErrors did not cap output:\n%s", got)
+	}
+}
@@ -179,6 +179,21 @@ func formatDiagnostics(diags []Diagnostic, filePath, wd string) string {
 	return strings.TrimRight(sb.String(), "\n")
 }
 
+// formatCodeActions lists code actions by number so one can be chosen.
+func formatCodeActions(actions []CodeAction, relPath string, line int) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Found %d quick fix(es) at %s:%d:\n\n", len(actions), relPath, line))
+	for i, a := range actions {
+		preferred := ""
+		if a.IsPreferred {
+			preferred = " (preferred)"
+		}
+		sb.WriteString(fmt.Sprintf("  %d. %s%s\n", i+1, a.Title, preferred))
+	}
+	sb.WriteString("\nCall code_action again with action set to a number to apply it.")
+	return sb.String()
+}
+
 // relativePath returns the path relative to wd, or the original path if it can't be made relative.
 func relativePath(path, wd string) string {
 	rel, err := filepath.Rel(wd, path)
@@ -4,35 +4,54 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 )
 
-// Manager manages the lifecycle of LSP servers, routing requests by file extension.
+// Manager manages the lifecycle of LSP servers, routing requests by file
+// extension and project root.
 type Manager struct {
+	// TrustRepoConfig lets a repository's RepoConfigPath set server
+	// commands and add servers. Without it, a repository may only adjust
+	// the args, initialization options and root markers of configured
+	// servers, since opening it would otherwise run a command it chose.
+	TrustRepoConfig bool
+
 	workingDirFn func() string
-	configs      []ServerConfig
-	extToConfig  map[string]*ServerConfig // extension -> config
+	configs      []ServerConfig // built-in servers merged with configured ones
+
+	regMu      sync.Mutex
+	registries map[string]*repoRegistry // repository root -> its servers
 
 	mu      sync.Mutex
-	servers map[string]*Server // config.Name -> running server
+	servers map[string]*Server // serverKey -> running server
+}
+
+// repoRegistry caches the servers for a repository along with the
+// modification time of its RepoConfigPath when they were loaded.
+type repoRegistry struct {
+	*registry
+	modTime time.Time
 }
 
-// NewManager creates a new LSP server manager.
+// NewManager creates a new LSP server manager with the built-in servers.
 func NewManager(workingDirFn func() string) *Manager {
-	configs := DefaultServers()
-	extToConfig := make(map[string]*ServerConfig)
-	for i := range configs {
-		for _, ext := range configs[i].Extensions {
-			extToConfig[ext] = &configs[i]
-		}
-	}
+	return NewManagerWithServers(workingDirFn, nil)
+}
+
+// NewManagerWithServers creates a new LSP server manager whose built-in
+// servers are merged with servers, as by MergeServers. A repository's
+// RepoConfigPath is merged on top of those for files in that repository,
+// restricted unless TrustRepoConfig is set.
+func NewManagerWithServers(workingDirFn func() string, servers []ServerConfig) *Manager {
 	return &Manager{
 		workingDirFn: workingDirFn,
-		configs:      configs,
-		extToConfig:  extToConfig,
+		configs:      MergeServers(DefaultServers(), servers),
+		registries:   make(map[string]*repoRegistry),
 		servers:      make(map[string]*Server),
 	}
 }
@@ -40,8 +59,9 @@ func NewManager(workingDirFn func() string) *Manager {
 // GetServer returns a running LSP server for the given file path, starting one if needed.
 func (m *Manager) GetServer(ctx context.Context, filePath string) (*Server, error) {
 	ext := filepath.Ext(filePath)
-	cfg, ok := m.extToConfig[ext]
-	if !ok {
+	repoRoot := m.repoRoot()
+	cfg := m.registry(repoRoot).extToConfig[ext]
+	if cfg == nil {
 		return nil, fmt.Errorf("no LSP server configured for %s files", ext)
 	}
 
@@ -50,20 +70,29 @@ func (m *Manager) GetServer(ctx context.Context, filePath string) (*Server, erro
 		return nil, fmt.Errorf("LSP server %q not found. %s", cfg.Command, cfg.InstallHint)
 	}
 
-	rootURI := m.rootURI()
+	rootURI := fileURI(projectRoot(filePath, repoRoot, cfg.RootMarkers))
+	key := serverKey(cfg.Name, rootURI)
 
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	// Check for existing server
-	if srv, ok := m.servers[cfg.Name]; ok {
-		if srv.Alive() && srv.RootURI() == rootURI {
+	if srv, ok := m.servers[key]; ok {
+		if srv.Alive() {
 			return srv, nil
 		}
-		// Dead or stale root — shut down and restart
-		slog.Info("lsp: restarting server", "server", cfg.Name, "reason", "dead or root changed")
+		slog.Info("lsp: restarting server", "server", cfg.Name, "rootURI", rootURI, "reason", "dead")
 		srv.Shutdown()
-		delete(m.servers, cfg.Name)
+		delete(m.servers, key)
+	}
+
+	// Servers for roots outside the current repository are stale.
+	for k, srv := range m.servers {
+		if srv.config.Name == cfg.Name && !withinDir(filePathFromURI(srv.RootURI()), repoRoot) {
+			slog.Info("lsp: shutting down server", "server", cfg.Name, "rootURI", srv.RootURI(), "reason", "root changed")
+			srv.Shutdown()
+			delete(m.servers, k)
+		}
 	}
 
 	// Start new server
@@ -71,29 +100,96 @@ func (m *Manager) GetServer(ctx context.Context, filePath string) (*Server, erro
 	if err != nil {
 		return nil, err
 	}
-	m.servers[cfg.Name] = srv
+	m.servers[key] = srv
 	slog.Info("lsp: started server", "server", cfg.Name, "rootURI", rootURI)
 	return srv, nil
 }
 
-// ConfigForExt returns the server config for a file extension, or nil if none.
+// runningServer returns the live server for filePath without starting one.
+func (m *Manager) runningServer(filePath string) *Server {
+	repoRoot := m.repoRoot()
+	cfg := m.registry(repoRoot).extToConfig[filepath.Ext(filePath)]
+	if cfg == nil {
+		return nil
+	}
+	key := serverKey(cfg.Name, fileURI(projectRoot(filePath, repoRoot, cfg.RootMarkers)))
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	srv := m.servers[key]
+	if srv == nil || !srv.Alive() {
+		return nil
+	}
+	return srv
+}
+
+// ConfigForExt returns the server config for a file extension in the
+// current repository, or nil if none.
 func (m *Manager) ConfigForExt(ext string) *ServerConfig {
-	return m.extToConfig[ext]
+	return m.registry(m.repoRoot()).extToConfig[ext]
 }
 
 // ConfiguredExtensions returns one representative extension per configured server.
 func (m *Manager) ConfiguredExtensions() []string {
-	seen := make(map[string]bool)
 	var exts []string
-	for _, cfg := range m.configs {
-		if len(cfg.Extensions) > 0 && !seen[cfg.Name] {
-			seen[cfg.Name] = true
-			exts = append(exts, cfg.Extensions[0])
+	for _, cfg := range m.registry(m.repoRoot()).configs {
+		// Skip servers whose extensions were all claimed by another.
+		for _, ext := range cfg.Extensions {
+			if m.ConfigForExt(ext).Name == cfg.Name {
+				exts = append(exts, ext)
+				break
+			}
 		}
 	}
 	return exts
 }
 
+// registry returns the servers for repoRoot, reloading its RepoConfigPath
+// when the file changes.
+func (m *Manager) registry(repoRoot string) *registry {
+	var modTime time.Time
+	if fi, err := os.Stat(filepath.Join(repoRoot, RepoConfigPath)); err == nil {
+		modTime = fi.ModTime()
+	}
+
+	m.regMu.Lock()
+	defer m.regMu.Unlock()
+	if r, ok := m.registries[repoRoot]; ok && r.modTime.Equal(modTime) {
+		return r.registry
+	}
+	configs := m.configs
+	servers, err := loadRepoServers(repoRoot)
+	if err == nil && !m.TrustRepoConfig {
+		var ignored []string
+		servers, ignored = restrictRepoServers(configs, servers)
+		if len(ignored) > 0 {
+			slog.Warn("lsp: ignoring commands in untrusted repository LSP config", "root", repoRoot, "servers", ignored)
+		}
+	}
+	if err == nil {
+		merged := MergeServers(configs, servers)
+		if err = ValidateServers(merged); err == nil {
+			configs = merged
+		}
+	}
+	if err != nil {
+		slog.Warn("lsp: ignoring repository LSP config", "root", repoRoot, "error", err)
+	}
+	r := &repoRegistry{registry: newRegistry(configs), modTime: modTime}
+	m.registries[repoRoot] = r
+	return r.registry
+}
+
+// serverKey identifies a running server by its config and root.
+func serverKey(name, rootURI string) string {
+	return name + " " + rootURI
+}
+
+// withinDir reports whether path is dir or inside it.
+func withinDir(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && filepath.IsLocal(rel)
+}
+
 // Close shuts down all running LSP servers.
 func (m *Manager) Close() {
 	m.mu.Lock()
@@ -105,13 +201,15 @@ func (m *Manager) Close() {
 	m.servers = make(map[string]*Server)
 }
 
-func (m *Manager) rootURI() string {
+// repoRoot returns the repository containing the working directory, or the
+// working directory itself outside a repository.
+func (m *Manager) repoRoot() string {
 	wd := m.workingDirFn()
 	root, err := findRepoRoot(wd)
 	if err != nil {
 		root = wd
 	}
-	return fileURI(root)
+	return root
 }
 
 // findRepoRoot finds the git repository root from the given directory.
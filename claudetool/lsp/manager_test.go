@@ -2,6 +2,7 @@ package lsp
 
 import (
 	"context"
+	"os"
 	"os/exec"
 	"path/filepath"
 	"testing"
@@ -43,10 +44,8 @@ func TestManagerConfigForExt(t *testing.T) {
 }
 
 func TestManagerGetServerMissingBinary(t *testing.T) {
-	m := NewManager(func() string { return t.TempDir() })
-
-	// Override configs to use a nonexistent binary
-	m.configs = []ServerConfig{
+	// Configure a server with a nonexistent binary
+	m := NewManagerWithServers(func() string { return t.TempDir() }, []ServerConfig{
 		{
 			Name:        "fake-lsp",
 			Command:     "definitely-not-a-real-binary-12345",
@@ -54,10 +53,7 @@ func TestManagerGetServerMissingBinary(t *testing.T) {
 			Extensions:  []string{".fake"},
 			InstallHint: "Install fake-lsp: go install fake-lsp@latest",
 		},
-	}
-	m.extToConfig = map[string]*ServerConfig{
-		".fake": &m.configs[0],
-	}
+	})
 
 	ctx := context.Background()
 	_, err := m.GetServer(ctx, "/tmp/test.fake")
@@ -107,6 +103,118 @@ func TestManagerGetServerReusesServer(t *testing.T) {
 	}
 }
 
+func TestManagerRepoConfig(t *testing.T) {
+	dir := t.TempDir()
+	m := NewManagerWithServers(func() string { return dir }, []ServerConfig{
+		{Name: "gopls", Args: []string{"serve", "-rpc.trace"}},
+		{Name: "pyright", Disabled: true},
+	})
+	m.TrustRepoConfig = true
+
+	if cfg := m.ConfigForExt(".go"); cfg == nil || cfg.Command != "gopls" || len(cfg.Args) != 2 {
+		t.Errorf("ConfigForExt(.go) = %+v, want gopls with overridden args", cfg)
+	}
+	if cfg := m.ConfigForExt(".py"); cfg != nil {
+		t.Errorf("ConfigForExt(.py) = %+v, want nil for a disabled server", cfg)
+	}
+
+	if err := os.MkdirAll(filepath.Join(dir, ".percy"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	repoConfig := `{"servers": [
+		{"name": "deno", "command": "deno", "args": ["lsp"], "extensions": [".ts"], "initialization_options": {"enable": true}},
+		{"name": "pyright", "disabled": false}
+	]}`
+	if err := os.WriteFile(filepath.Join(dir, RepoConfigPath), []byte(repoConfig), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if cfg := m.ConfigForExt(".ts"); cfg == nil || cfg.Name != "deno" || string(cfg.InitializationOptions) != `{"enable": true}` {
+		t.Errorf("ConfigForExt(.ts) = %+v, want deno from the repository config", cfg)
+	}
+	if cfg := m.ConfigForExt(".tsx"); cfg == nil || cfg.Name != "typescript-language-server" {
+		t.Errorf("ConfigForExt(.tsx) = %+v, want typescript-language-server", cfg)
+	}
+	if cfg := m.ConfigForExt(".py"); cfg == nil || cfg.Name != "pyright" {
+		t.Errorf("ConfigForExt(.py) = %+v, want pyright re-enabled by the repository", cfg)
+	}
+}
+
+func TestManagerUntrustedRepoConfig(t *testing.T) {
+	dir := t.TempDir()
+	m := NewManager(func() string { return dir })
+
+	if err := os.MkdirAll(filepath.Join(dir, ".percy"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	repoConfig := `{"servers": [
+		{"name": "deno", "command": "deno", "args": ["lsp"], "extensions": [".ts"]},
+		{"name": "gopls", "command": "./evil", "args": ["serve"], "extensions": [".txt"], "root_markers": ["go.work"]},
+		{"name": "pyright", "initialization_options": {"python": {}}}
+	]}`
+	if err := os.WriteFile(filepath.Join(dir, RepoConfigPath), []byte(repoConfig), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if cfg := m.ConfigForExt(".ts"); cfg == nil || cfg.Name != "typescript-language-server" {
+		t.Errorf("ConfigForExt(.ts) = %+v, want the built-in server, not one added by the repository", cfg)
+	}
+	if cfg := m.ConfigForExt(".txt"); cfg != nil {
+		t.Errorf("ConfigForExt(.txt) = %+v, want nil", cfg)
+	}
+	cfg := m.ConfigForExt(".go")
+	if cfg == nil || cfg.Command != "gopls" {
+		t.Fatalf("ConfigForExt(.go) = %+v, want the built-in gopls command", cfg)
+	}
+	if len(cfg.Args) != 1 || cfg.Args[0] != "serve" || len(cfg.RootMarkers) != 1 || cfg.RootMarkers[0] != "go.work" {
+		t.Errorf("ConfigForExt(.go) = %+v, want args and root markers from the repository", cfg)
+	}
+	if cfg := m.ConfigForExt(".py"); cfg == nil || string(cfg.InitializationOptions) != `{"python": {}}` {
+		t.Errorf("ConfigForExt(.py) = %+v, want initialization options from the repository", cfg)
+	}
+}
+
+func TestValidateServers(t *testing.T) {
+	merged := MergeServers(DefaultServers(), []ServerConfig{{Name: "gopls", Args: []string{"serve"}}, {Name: "x", Disabled: true}})
+	if err := ValidateServers(merged); err != nil {
+		t.Errorf("ValidateServers: %v", err)
+	}
+	if err := ValidateServers([]ServerConfig{{Name: "x", Extensions: []string{".x"}}}); err == nil {
+		t.Error("expected an error for a server without a command")
+	}
+	if err := ValidateServers([]ServerConfig{{Command: "x", Extensions: []string{".x"}}}); err == nil {
+		t.Error("expected an error for a server without a name")
+	}
+}
+
+func TestProjectRoot(t *testing.T) {
+	repo := t.TempDir()
+	mod := filepath.Join(repo, "services", "api")
+	if err := os.MkdirAll(filepath.Join(mod, "internal"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(mod, "go.mod"), []byte("module api\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	markers := []string{"go.work", "go.mod"}
+
+	tests := []struct {
+		file string
+		want string
+	}{
+		{filepath.Join(mod, "internal", "x.go"), mod},
+		{filepath.Join(mod, "main.go"), mod},
+		{filepath.Join(repo, "tools", "gen.go"), repo},
+		{"/elsewhere/x.go", repo},
+	}
+	for _, tt := range tests {
+		if got := projectRoot(tt.file, repo, markers); got != tt.want {
+			t.Errorf("projectRoot(%s) = %s, want %s", tt.file, got, tt.want)
+		}
+	}
+	if got := projectRoot(filepath.Join(mod, "main.go"), repo, nil); got != repo {
+		t.Errorf("projectRoot without markers = %s, want %s", got, repo)
+	}
+}
+
 func TestManagerClose(t *testing.T) {
 	m := NewManager(func() string { return t.TempDir() })
 	// Close with no servers should not panic
@@ -1,14 +1,22 @@
 package lsp
 
-import "github.com/tgruben-circuit/percy/llm"
+import (
+	"context"
 
-// RegisterLSPTools creates the LSP code intelligence tools and returns them with a cleanup function.
-// The cleanup function shuts down all running LSP servers.
-func RegisterLSPTools(workingDirFn func() string) ([]*llm.Tool, func()) {
-	manager := NewManager(workingDirFn)
+	"github.com/tgruben-circuit/percy/llm"
+)
+
+// RegisterLSPTools creates the LSP code intelligence tools and returns them with
+// the manager of their servers. Close the manager to shut the servers down.
+// servers are merged with the built-in servers; see NewManagerWithServers.
+// beforeWrite, if not nil, is called with the files an edit is about to
+// change, so that they can be checkpointed.
+func RegisterLSPTools(workingDirFn func() string, servers []ServerConfig, beforeWrite func(ctx context.Context, paths []string)) ([]*llm.Tool, *Manager) {
+	manager := NewManagerWithServers(workingDirFn, servers)
 	tool := &CodeIntelTool{
-		manager:    manager,
-		workingDir: workingDirFn,
+		manager:     manager,
+		workingDir:  workingDirFn,
+		beforeWrite: beforeWrite,
 	}
-	return []*llm.Tool{tool.Tool()}, manager.Close
+	return []*llm.Tool{tool.Tool()}, manager
 }
@@ -2,6 +2,7 @@ package lsp
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log/slog"
 	"net/url"
@@ -13,12 +14,23 @@ import (
 )
 
 // ServerConfig describes how to start an LSP server for a given language.
+// It can be declared in percy.json or a repository's .percy/lsp.json.
 type ServerConfig struct {
-	Name        string   // e.g., "gopls", "typescript-language-server"
-	Command     string   // binary name
-	Args        []string // command-line arguments
-	Extensions  []string // file extensions this server handles (e.g., ".go", ".ts")
-	InstallHint string   // message shown if the binary is not found
+	Name        string   `json:"name"`                   // e.g., "gopls", "typescript-language-server"
+	Command     string   `json:"command,omitempty"`      // binary name
+	Args        []string `json:"args,omitempty"`         // command-line arguments
+	Extensions  []string `json:"extensions,omitempty"`   // file extensions this server handles (e.g., ".go", ".ts")
+	InstallHint string   `json:"install_hint,omitempty"` // message shown if the binary is not found
+
+	// InitializationOptions is sent as-is in the initialize request.
+	InitializationOptions json.RawMessage `json:"initialization_options,omitempty"`
+	// RootMarkers are file names that mark a project root, such as go.mod.
+	// Each file is served from the nearest enclosing directory containing
+	// one, so a repository can hold several roots. Without markers, or if
+	// none is found, the repository root is used.
+	RootMarkers []string `json:"root_markers,omitempty"`
+	// Disabled turns off a server, including a built-in one of the same name.
+	Disabled bool `json:"disabled,omitempty"`
 }
 
 // DefaultServers returns built-in server configurations.
@@ -30,6 +42,7 @@ func DefaultServers() []ServerConfig {
 			Args:        []string{"serve"},
 			Extensions:  []string{".go"},
 			InstallHint: "Install gopls: go install golang.org/x/tools/gopls@latest",
+			RootMarkers: []string{"go.work", "go.mod"},
 		},
 		{
 			Name:        "typescript-language-server",
@@ -37,6 +50,7 @@ func DefaultServers() []ServerConfig {
 			Args:        []string{"--stdio"},
 			Extensions:  []string{".ts", ".tsx", ".js", ".jsx"},
 			InstallHint: "Install typescript-language-server: npm install -g typescript-language-server typescript",
+			RootMarkers: []string{"tsconfig.json", "jsconfig.json", "package.json"},
 		},
 		{
 			Name:        "pyright",
@@ -44,6 +58,7 @@ func DefaultServers() []ServerConfig {
 			Args:        []string{"--stdio"},
 			Extensions:  []string{".py"},
 			InstallHint: "Install pyright: npm install -g pyright",
+			RootMarkers: []string{"pyrightconfig.json", "pyproject.toml", "setup.py", "setup.cfg"},
 		},
 		{
 			Name:        "rust-analyzer",
@@ -51,6 +66,7 @@ func DefaultServers() []ServerConfig {
 			Args:        nil,
 			Extensions:  []string{".rs"},
 			InstallHint: "Install rust-analyzer: rustup component add rust-analyzer",
+			RootMarkers: []string{"Cargo.toml"},
 		},
 	}
 }
@@ -111,11 +127,20 @@ func NewServer(ctx context.Context, config ServerConfig, rootURI string) (*Serve
 
 func (s *Server) initialize(ctx context.Context) error {
 	params := InitializeParams{
-		ProcessID: os.Getpid(),
-		RootURI:   s.rootURI,
+		ProcessID:             os.Getpid(),
+		RootURI:               s.rootURI,
+		InitializationOptions: s.config.InitializationOptions,
+		WorkspaceFolders: []WorkspaceFolder{{
+			URI:  s.rootURI,
+			Name: filepath.Base(filePathFromURI(s.rootURI)),
+		}},
 		Capabilities: ClientCapabilities{
+			Workspace: &WorkspaceClientCapabilities{
+				WorkspaceEdit: &WorkspaceEditClientCapabilities{DocumentChanges: true},
+			},
 			TextDocument: &TextDocumentClientCapabilities{
 				Definition: &DefinitionClientCapabilities{},
+				CodeAction: codeActionCapabilities(),
 			},
 		},
 	}
@@ -228,6 +253,86 @@ func (s *Server) WorkspaceSymbols(ctx context.Context, query string) ([]SymbolIn
 	return symbols, nil
 }
 
+// Rename returns the workspace edit that renames the symbol at the given
+// position to newName. The edit is not applied.
+func (s *Server) Rename(ctx context.Context, uri string, pos Position, newName string) (*WorkspaceEdit, error) {
+	params := RenameParams{
+		TextDocument: TextDocumentIdentifier{URI: uri},
+		Position:     pos,
+		NewName:      newName,
+	}
+	var edit *WorkspaceEdit
+	if err := s.client.Call(ctx, "textDocument/rename", params, &edit); err != nil {
+		return nil, err
+	}
+	return edit, nil
+}
+
+// CodeActions returns the code actions for the given range and diagnostics,
+// restricted to the given kinds if any.
+func (s *Server) CodeActions(ctx context.Context, uri string, rng Range, diags []Diagnostic, only ...string) ([]CodeAction, error) {
+	params := CodeActionParams{
+		TextDocument: TextDocumentIdentifier{URI: uri},
+		Range:        rng,
+		Context:      CodeActionContext{Diagnostics: diags, Only: only},
+	}
+	if params.Context.Diagnostics == nil {
+		params.Context.Diagnostics = []Diagnostic{}
+	}
+	// LSP spec: result is (Command | CodeAction)[] | null
+	var raw []json.RawMessage
+	if err := s.client.Call(ctx, "textDocument/codeAction", params, &raw); err != nil {
+		return nil, err
+	}
+	actions := make([]CodeAction, 0, len(raw))
+	for _, r := range raw {
+		var probe struct {
+			Command json.RawMessage `json:"command"`
+		}
+		if err := json.Unmarshal(r, &probe); err != nil {
+			return nil, err
+		}
+		if len(probe.Command) > 0 && probe.Command[0] == '"' {
+			// A bare Command.
+			var cmd Command
+			if err := json.Unmarshal(r, &cmd); err != nil {
+				return nil, err
+			}
+			actions = append(actions, CodeAction{Title: cmd.Title, Command: &cmd})
+			continue
+		}
+		var action CodeAction
+		if err := json.Unmarshal(r, &action); err != nil {
+			return nil, err
+		}
+		actions = append(actions, action)
+	}
+	return actions, nil
+}
+
+// ResolveCodeAction fills in the edit of a code action that the server
+// returned without one.
+func (s *Server) ResolveCodeAction(ctx context.Context, action CodeAction) (CodeAction, error) {
+	var resolved CodeAction
+	if err := s.client.Call(ctx, "codeAction/resolve", action, &resolved); err != nil {
+		return action, err
+	}
+	return resolved, nil
+}
+
+// Format returns the edits that format the given document.
+func (s *Server) Format(ctx context.Context, uri string, opts FormattingOptions) ([]TextEdit, error) {
+	params := DocumentFormattingParams{
+		TextDocument: TextDocumentIdentifier{URI: uri},
+		Options:      opts,
+	}
+	var edits []TextEdit
+	if err := s.client.Call(ctx, "textDocument/formatting", params, &edits); err != nil {
+		return nil, err
+	}
+	return edits, nil
+}
+
 // Alive returns true if the underlying LSP process is still running.
 func (s *Server) Alive() bool {
 	return s.client.Alive()
@@ -283,6 +388,69 @@ func (s *Server) WaitForDiagnostics(uri string, timeout time.Duration) []Diagnos
 	return s.GetDiagnostics(uri)
 }
 
+// RefreshDiagnostics sends filePath's current contents to the server and
+// waits up to timeout for the diagnostics it publishes in response. ok is
+// false if none arrived in time, in which case diags may be stale.
+func (s *Server) RefreshDiagnostics(ctx context.Context, filePath string, timeout time.Duration) (diags []Diagnostic, ok bool, err error) {
+	uri := fileURI(filePath)
+
+	// Register before notifying so a fast reply is not missed.
+	s.diagMu.Lock()
+	ch := make(chan struct{}, 1)
+	s.diagNotify[uri] = ch
+	s.diagMu.Unlock()
+
+	defer func() {
+		s.diagMu.Lock()
+		delete(s.diagNotify, uri)
+		s.diagMu.Unlock()
+	}()
+
+	if err := s.OpenFile(ctx, filePath); err != nil {
+		return nil, false, err
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-ch:
+		ok = true
+	case <-timer.C:
+	case <-ctx.Done():
+		return nil, false, ctx.Err()
+	}
+	return s.GetDiagnostics(uri), ok, nil
+}
+
+// openFileDiagnostics returns the latest diagnostics for uri if the file is
+// open and the server has published diagnostics for it.
+func (s *Server) openFileDiagnostics(uri string) ([]Diagnostic, bool) {
+	s.mu.Lock()
+	_, isOpen := s.openFiles[uri]
+	s.mu.Unlock()
+	if !isOpen {
+		return nil, false
+	}
+	s.diagMu.RLock()
+	defer s.diagMu.RUnlock()
+	diags, ok := s.diagStore[uri]
+	return diags, ok
+}
+
+// codeActionCapabilities advertises support for the code action kinds the
+// code intelligence tool can apply.
+func codeActionCapabilities() *CodeActionClientCapabilities {
+	caps := &CodeActionClientCapabilities{
+		CodeActionLiteralSupport: &CodeActionLiteralSupport{},
+		ResolveSupport:           &CodeActionResolveSupport{Properties: []string{"edit"}},
+	}
+	caps.CodeActionLiteralSupport.CodeActionKind.ValueSet = []string{
+		"", CodeActionKindQuickFix, "refactor", "refactor.extract", "refactor.inline",
+		"refactor.rewrite", "source", "source.organizeImports", "source.fixAll",
+	}
+	return caps
+}
+
 // fileURI converts an absolute file path to a file:// URI.
 func fileURI(path string) string {
 	u := &url.URL{Scheme: "file", Path: path}
@@ -3,6 +3,8 @@ package lsp
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"os"
 	"path/filepath"
 	"strings"
 	"time"
@@ -20,8 +22,13 @@ Operations:
 - hover: Get type information and documentation for a symbol at a given file position
 - symbols: Search for symbols (functions, types, variables) across the workspace
 - diagnostics: Get compiler errors and warnings for a file
+- rename: Rename the symbol at a given file position to new_name, updating every reference across the workspace
+- code_action: List the quick fixes for the diagnostics on a given line (and column, if given); pass action to apply one
+- format: Format a file with its language server
 
-Use this for precise, semantic code navigation. For text-based search, use keyword_search instead.
+rename, code_action (with action) and format edit files on disk and report the changes as unified diffs.
+
+Use this for precise, semantic code navigation and refactoring. For text-based search, use keyword_search instead.
 Requires an LSP server installed for the file's language (e.g., gopls for Go, typescript-language-server for TypeScript, pyright for Python, rust-analyzer for Rust).
 
 Note: The first call for a language may be slow while the LSP server starts and indexes the workspace.
@@ -32,24 +39,32 @@ Note: The first call for a language may be slow while the LSP server starts and
   "properties": {
     "operation": {
       "type": "string",
-      "enum": ["definition", "references", "hover", "symbols", "diagnostics"],
+      "enum": ["definition", "references", "hover", "symbols", "diagnostics", "rename", "code_action", "format"],
       "description": "The code intelligence operation to perform"
     },
     "file": {
       "type": "string",
-      "description": "File path (absolute or relative to working directory). Required for definition, references, hover."
+      "description": "File path (absolute or relative to working directory). Required for all operations except symbols."
     },
     "line": {
       "type": "integer",
-      "description": "Line number (1-based). Required for definition, references, hover."
+      "description": "Line number (1-based). Required for definition, references, hover, rename, code_action."
     },
     "column": {
       "type": "integer",
-      "description": "Column number (1-based). Required for definition, references, hover."
+      "description": "Column number (1-based). Required for definition, references, hover, rename; optional for code_action."
     },
     "query": {
       "type": "string",
       "description": "Symbol name to search for. Required for symbols operation."
+    },
+    "new_name": {
+      "type": "string",
+      "description": "New name for the symbol. Required for rename operation."
+    },
+    "action": {
+      "type": "integer",
+      "description": "Number (1-based) of the code action to apply, as listed by a previous code_action call. Omit to list the available actions."
     }
   }
 }`
@@ -61,12 +76,17 @@ type codeIntelInput struct {
 	Line      int    `json:"line"`
 	Column    int    `json:"column"`
 	Query     string `json:"query"`
+	NewName   string `json:"new_name"`
+	Action    int    `json:"action"`
 }
 
 // CodeIntelTool provides LSP-based code intelligence.
 type CodeIntelTool struct {
 	manager    *Manager
 	workingDir func() string
+	// beforeWrite, if set, is called with the paths of files about to be
+	// edited.
+	beforeWrite func(ctx context.Context, paths []string)
 }
 
 // Tool returns the llm.Tool definition for code intelligence.
@@ -93,8 +113,14 @@ func (c *CodeIntelTool) Run(ctx context.Context, m json.RawMessage) llm.ToolOut
 		return c.runSymbols(ctx, input)
 	case "diagnostics":
 		return c.runDiagnostics(ctx, input)
+	case "rename":
+		return c.runRename(ctx, input)
+	case "code_action":
+		return c.runCodeAction(ctx, input)
+	case "format":
+		return c.runFormat(ctx, input)
 	default:
-		return llm.ErrorfToolOut("unknown operation %q: must be one of definition, references, hover, symbols, diagnostics", input.Operation)
+		return llm.ErrorfToolOut("unknown operation %q: must be one of definition, references, hover, symbols, diagnostics, rename, code_action, format", input.Operation)
 	}
 }
 
@@ -208,13 +234,196 @@ func (c *CodeIntelTool) runDiagnostics(ctx context.Context, input codeIntelInput
 		return llm.ErrorfToolOut("%s", err)
 	}
 
-	if err := srv.OpenFile(ctx, filePath); err != nil {
+	diags, _, err := srv.RefreshDiagnostics(ctx, filePath, 2*time.Second)
+	if err != nil {
 		return llm.ErrorfToolOut("failed to open file in LSP: %s", err)
 	}
 
-	uri := fileURI(filePath)
-	diags := srv.WaitForDiagnostics(uri, 2*time.Second)
-
 	wd := c.workingDir()
 	return llm.ToolOut{LLMContent: llm.TextContent(formatDiagnostics(diags, filePath, wd))}
 }
+
+// resolvePath makes file absolute relative to the working directory.
+func (c *CodeIntelTool) resolvePath(file string) string {
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(c.workingDir(), file)
+	}
+	return filepath.Clean(file)
+}
+
+// openServer returns the server for filePath with the file's current
+// contents open in it.
+func (c *CodeIntelTool) openServer(ctx context.Context, filePath string) (*Server, error) {
+	srv, err := c.manager.GetServer(ctx, filePath)
+	if err != nil {
+		return nil, err
+	}
+	if err := srv.OpenFile(ctx, filePath); err != nil {
+		return nil, fmt.Errorf("failed to open file in LSP: %w", err)
+	}
+	return srv, nil
+}
+
+// applyChanges writes changes to disk, syncs them to srv and reports them
+// as unified diffs under summary, with the patch tool's display data.
+func (c *CodeIntelTool) applyChanges(ctx context.Context, srv *Server, changes []fileChange, summary string) llm.ToolOut {
+	if c.beforeWrite != nil {
+		paths := make([]string, len(changes))
+		for i, ch := range changes {
+			paths[i] = ch.path
+		}
+		c.beforeWrite(ctx, paths)
+	}
+	if err := writeFileChanges(changes); err != nil {
+		return llm.ErrorToolOut(err)
+	}
+	syncFileChanges(ctx, srv, changes)
+	return llm.ToolOut{
+		LLMContent: llm.TextContent(summary + "\n\n" + formatFileChanges(changes, c.workingDir())),
+		Display:    fileChangesDisplay(changes),
+	}
+}
+
+func (c *CodeIntelTool) runRename(ctx context.Context, input codeIntelInput) llm.ToolOut {
+	if input.File == "" {
+		return llm.ErrorfToolOut("file is required for rename operation")
+	}
+	if input.Line < 1 || input.Column < 1 {
+		return llm.ErrorfToolOut("line and column are required and must be >= 1 for rename operation")
+	}
+	if input.NewName == "" {
+		return llm.ErrorfToolOut("new_name is required for rename operation")
+	}
+
+	filePath := c.resolvePath(input.File)
+	srv, err := c.openServer(ctx, filePath)
+	if err != nil {
+		return llm.ErrorfToolOut("%s", err)
+	}
+	pos := Position{Line: input.Line - 1, Character: input.Column - 1}
+	edit, err := srv.Rename(ctx, fileURI(filePath), pos, input.NewName)
+	if err != nil {
+		return llm.ErrorfToolOut("rename failed: %s", err)
+	}
+	changes, err := planWorkspaceEdit(edit)
+	if err != nil {
+		return llm.ErrorfToolOut("rename failed: %s", err)
+	}
+	if len(changes) == 0 {
+		return llm.ToolOut{LLMContent: llm.TextContent("Rename made no changes.")}
+	}
+	return c.applyChanges(ctx, srv, changes, fmt.Sprintf("Renamed to %s in %d file(s):", input.NewName, len(changes)))
+}
+
+func (c *CodeIntelTool) runCodeAction(ctx context.Context, input codeIntelInput) llm.ToolOut {
+	if input.File == "" {
+		return llm.ErrorfToolOut("file is required for code_action operation")
+	}
+	if input.Line < 1 {
+		return llm.ErrorfToolOut("line is required and must be >= 1 for code_action operation")
+	}
+
+	filePath := c.resolvePath(input.File)
+	srv, err := c.manager.GetServer(ctx, filePath)
+	if err != nil {
+		return llm.ErrorfToolOut("%s", err)
+	}
+	diags, _, err := srv.RefreshDiagnostics(ctx, filePath, 2*time.Second)
+	if err != nil {
+		return llm.ErrorfToolOut("failed to open file in LSP: %s", err)
+	}
+
+	// Without a column, ask about the whole line.
+	line := input.Line - 1
+	rng := Range{Start: Position{Line: line}, End: Position{Line: line + 1}}
+	if input.Column >= 1 {
+		pos := Position{Line: line, Character: input.Column - 1}
+		rng = Range{Start: pos, End: pos}
+	}
+	var lineDiags []Diagnostic
+	for _, d := range diags {
+		if d.Range.Start.Line <= line && line <= d.Range.End.Line {
+			lineDiags = append(lineDiags, d)
+		}
+	}
+
+	actions, err := srv.CodeActions(ctx, fileURI(filePath), rng, lineDiags, CodeActionKindQuickFix)
+	if err != nil {
+		return llm.ErrorfToolOut("code action failed: %s", err)
+	}
+	relPath := relativePath(filePath, c.workingDir())
+	if len(actions) == 0 {
+		return llm.ToolOut{LLMContent: llm.TextContent(fmt.Sprintf("No quick fixes available at %s:%d.", relPath, input.Line))}
+	}
+	if input.Action == 0 {
+		return llm.ToolOut{LLMContent: llm.TextContent(formatCodeActions(actions, relPath, input.Line))}
+	}
+	if input.Action < 1 || input.Action > len(actions) {
+		return llm.ErrorfToolOut("action must be between 1 and %d", len(actions))
+	}
+
+	action := actions[input.Action-1]
+	if action.Edit == nil && action.Command == nil {
+		action, err = srv.ResolveCodeAction(ctx, action)
+		if err != nil {
+			return llm.ErrorfToolOut("failed to resolve code action %q: %s", action.Title, err)
+		}
+	}
+	if action.Edit == nil {
+		// Applying a command would need the server to call back with
+		// workspace/applyEdit, which this client does not handle.
+		return llm.ErrorfToolOut("code action %q runs a server command and cannot be applied; make the change with patch instead", action.Title)
+	}
+	changes, err := planWorkspaceEdit(action.Edit)
+	if err != nil {
+		return llm.ErrorfToolOut("code action failed: %s", err)
+	}
+	if len(changes) == 0 {
+		return llm.ToolOut{LLMContent: llm.TextContent(fmt.Sprintf("Code action %q made no changes.", action.Title))}
+	}
+	return c.applyChanges(ctx, srv, changes, fmt.Sprintf("Applied %q:", action.Title))
+}
+
+func (c *CodeIntelTool) runFormat(ctx context.Context, input codeIntelInput) llm.ToolOut {
+	if input.File == "" {
+		return llm.ErrorfToolOut("file is required for format operation")
+	}
+
+	filePath := c.resolvePath(input.File)
+	srv, err := c.openServer(ctx, filePath)
+	if err != nil {
+		return llm.ErrorfToolOut("%s", err)
+	}
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return llm.ErrorToolOut(err)
+	}
+	edits, err := srv.Format(ctx, fileURI(filePath), formattingOptions(content))
+	if err != nil {
+		return llm.ErrorfToolOut("format failed: %s", err)
+	}
+	change, err := planTextEdits(filePath, edits)
+	if err != nil {
+		return llm.ErrorfToolOut("format failed: %s", err)
+	}
+	relPath := relativePath(filePath, c.workingDir())
+	if change == nil {
+		return llm.ToolOut{LLMContent: llm.TextContent(fmt.Sprintf("%s is already formatted.", relPath))}
+	}
+	return c.applyChanges(ctx, srv, []fileChange{*change}, fmt.Sprintf("Formatted %s:", relPath))
+}
+
+// formattingOptions guesses the indentation style of content, for servers
+// that honour the client's formatting options.
+func formattingOptions(content []byte) FormattingOptions {
+	tabs, spaces := 0, 0
+	for _, line := range strings.Split(string(content), "\n") {
+		switch {
+		case strings.HasPrefix(line, "\t"):
+			tabs++
+		case strings.HasPrefix(line, "  "):
+			spaces++
+		}
+	}
+	return FormattingOptions{TabSize: 4, InsertSpaces: spaces > tabs}
+}
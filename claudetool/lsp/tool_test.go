@@ -52,6 +52,26 @@ func TestToolInputValidation(t *testing.T) {
 			input:   codeIntelInput{Operation: "symbols"},
 			wantErr: "query is required",
 		},
+		{
+			name:    "rename missing new name",
+			input:   codeIntelInput{Operation: "rename", File: "test.go", Line: 1, Column: 1},
+			wantErr: "new_name is required",
+		},
+		{
+			name:    "rename missing column",
+			input:   codeIntelInput{Operation: "rename", File: "test.go", Line: 1, NewName: "x"},
+			wantErr: "line and column are required",
+		},
+		{
+			name:    "code_action missing line",
+			input:   codeIntelInput{Operation: "code_action", File: "test.go"},
+			wantErr: "line is required",
+		},
+		{
+			name:    "format missing file",
+			input:   codeIntelInput{Operation: "format"},
+			wantErr: "file is required",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -1,5 +1,7 @@
 package lsp
 
+import "encoding/json"
+
 // LSP protocol types — minimal subset needed for code intelligence operations.
 
 // Position in a text document (0-based line and character).
@@ -146,19 +148,58 @@ type MarkupContent struct {
 
 // InitializeParams is sent as the first request from client to server.
 type InitializeParams struct {
-	ProcessID    int                `json:"processId"`
-	RootURI      string             `json:"rootUri"`
-	Capabilities ClientCapabilities `json:"capabilities"`
+	ProcessID             int                `json:"processId"`
+	RootURI               string             `json:"rootUri"`
+	InitializationOptions json.RawMessage    `json:"initializationOptions,omitempty"`
+	Capabilities          ClientCapabilities `json:"capabilities"`
+	WorkspaceFolders      []WorkspaceFolder  `json:"workspaceFolders,omitempty"`
+}
+
+// WorkspaceFolder is a root folder the server should work on.
+type WorkspaceFolder struct {
+	URI  string `json:"uri"`
+	Name string `json:"name"`
 }
 
 // ClientCapabilities define capabilities the editor / tool provides.
 type ClientCapabilities struct {
+	Workspace    *WorkspaceClientCapabilities    `json:"workspace,omitempty"`
 	TextDocument *TextDocumentClientCapabilities `json:"textDocument,omitempty"`
 }
 
+// WorkspaceClientCapabilities define capabilities the editor / tool provides on the workspace.
+type WorkspaceClientCapabilities struct {
+	WorkspaceEdit *WorkspaceEditClientCapabilities `json:"workspaceEdit,omitempty"`
+}
+
+// WorkspaceEditClientCapabilities describes which forms of WorkspaceEdit the client accepts.
+type WorkspaceEditClientCapabilities struct {
+	DocumentChanges bool `json:"documentChanges,omitempty"`
+}
+
 // TextDocumentClientCapabilities define capabilities the editor / tool provides on text documents.
 type TextDocumentClientCapabilities struct {
 	Definition *DefinitionClientCapabilities `json:"definition,omitempty"`
+	CodeAction *CodeActionClientCapabilities `json:"codeAction,omitempty"`
+}
+
+// CodeActionClientCapabilities declares support for CodeAction literals, so
+// servers return edits rather than bare commands.
+type CodeActionClientCapabilities struct {
+	CodeActionLiteralSupport *CodeActionLiteralSupport `json:"codeActionLiteralSupport,omitempty"`
+	ResolveSupport           *CodeActionResolveSupport `json:"resolveSupport,omitempty"`
+}
+
+// CodeActionLiteralSupport lists the code action kinds the client understands.
+type CodeActionLiteralSupport struct {
+	CodeActionKind struct {
+		ValueSet []string `json:"valueSet"`
+	} `json:"codeActionKind"`
+}
+
+// CodeActionResolveSupport lists the properties a server may fill in lazily via codeAction/resolve.
+type CodeActionResolveSupport struct {
+	Properties []string `json:"properties"`
 }
 
 // DefinitionClientCapabilities indicates whether definition supports dynamic registration.
@@ -182,6 +223,8 @@ type ServerCapabilities struct {
 	CompletionProvider         any  `json:"completionProvider,omitempty"`
 	SignatureHelpProvider      any  `json:"signatureHelpProvider,omitempty"`
 	DocumentFormattingProvider bool `json:"documentFormattingProvider,omitempty"`
+	RenameProvider             any  `json:"renameProvider,omitempty"`
+	CodeActionProvider         any  `json:"codeActionProvider,omitempty"`
 }
 
 // DidOpenTextDocumentParams is sent when a text document is opened.
@@ -244,6 +287,7 @@ type Diagnostic struct {
 	Code     any                `json:"code,omitempty"`
 	Source   string             `json:"source,omitempty"`
 	Message  string             `json:"message"`
+	Data     json.RawMessage    `json:"data,omitempty"` // passed back to the server in code action requests
 }
 
 // PublishDiagnosticsParams is sent from the server to the client to signal results of validation.
@@ -251,3 +295,76 @@ type PublishDiagnosticsParams struct {
 	URI         string       `json:"uri"`
 	Diagnostics []Diagnostic `json:"diagnostics"`
 }
+
+// TextEdit is a textual edit applicable to a text document.
+type TextEdit struct {
+	Range   Range  `json:"range"`
+	NewText string `json:"newText"`
+}
+
+// TextDocumentEdit describes edits to a single versioned text document.
+type TextDocumentEdit struct {
+	TextDocument VersionedTextDocumentIdentifier `json:"textDocument"`
+	Edits        []TextEdit                      `json:"edits"`
+}
+
+// WorkspaceEdit represents changes to many resources managed in the workspace.
+// DocumentChanges holds TextDocumentEdits and, from servers that support
+// them, file create, rename and delete operations.
+type WorkspaceEdit struct {
+	Changes         map[string][]TextEdit `json:"changes,omitempty"`
+	DocumentChanges []json.RawMessage     `json:"documentChanges,omitempty"`
+}
+
+// RenameParams is the params for a textDocument/rename request.
+type RenameParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Position     Position               `json:"position"`
+	NewName      string                 `json:"newName"`
+}
+
+// CodeActionParams is the params for a textDocument/codeAction request.
+type CodeActionParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Range        Range                  `json:"range"`
+	Context      CodeActionContext      `json:"context"`
+}
+
+// CodeActionContext carries the diagnostics a code action request is about.
+type CodeActionContext struct {
+	Diagnostics []Diagnostic `json:"diagnostics"`
+	Only        []string     `json:"only,omitempty"`
+}
+
+// CodeActionKindQuickFix is the kind of code actions that fix a diagnostic.
+const CodeActionKindQuickFix = "quickfix"
+
+// CodeAction is a change that can be performed in code, e.g. to fix a problem.
+type CodeAction struct {
+	Title       string          `json:"title"`
+	Kind        string          `json:"kind,omitempty"`
+	Diagnostics []Diagnostic    `json:"diagnostics,omitempty"`
+	IsPreferred bool            `json:"isPreferred,omitempty"`
+	Edit        *WorkspaceEdit  `json:"edit,omitempty"`
+	Command     *Command        `json:"command,omitempty"`
+	Data        json.RawMessage `json:"data,omitempty"`
+}
+
+// Command represents a reference to a command run by the server.
+type Command struct {
+	Title     string            `json:"title"`
+	Command   string            `json:"command"`
+	Arguments []json.RawMessage `json:"arguments,omitempty"`
+}
+
+// FormattingOptions describes how a document should be formatted.
+type FormattingOptions struct {
+	TabSize      int  `json:"tabSize"`
+	InsertSpaces bool `json:"insertSpaces"`
+}
+
+// DocumentFormattingParams is the params for a textDocument/formatting request.
+type DocumentFormattingParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Options      FormattingOptions      `json:"options"`
+}
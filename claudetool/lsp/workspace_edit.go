@@ -0,0 +1,189 @@
+package lsp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/tgruben-circuit/percy/claudetool/filediff"
+	"sketch.dev/claudetool/editbuf"
+)
+
+// fileChange is the planned new content of a file touched by an edit.
+type fileChange struct {
+	path     string
+	old, new []byte
+}
+
+// textEdits returns the text edits in a workspace edit, keyed by file path.
+// File create, rename and delete operations are not supported.
+func (we *WorkspaceEdit) textEdits() (map[string][]TextEdit, error) {
+	out := make(map[string][]TextEdit)
+	// Per the spec, documentChanges wins over changes when both are present.
+	if len(we.DocumentChanges) > 0 {
+		for _, raw := range we.DocumentChanges {
+			var probe struct {
+				Kind string `json:"kind"`
+			}
+			if err := json.Unmarshal(raw, &probe); err != nil {
+				return nil, err
+			}
+			if probe.Kind != "" {
+				return nil, fmt.Errorf("workspace edit needs a file %s operation, which is not supported", probe.Kind)
+			}
+			var de TextDocumentEdit
+			if err := json.Unmarshal(raw, &de); err != nil {
+				return nil, err
+			}
+			path := filePathFromURI(de.TextDocument.URI)
+			out[path] = append(out[path], de.Edits...)
+		}
+		return out, nil
+	}
+	for uri, edits := range we.Changes {
+		path := filePathFromURI(uri)
+		out[path] = append(out[path], edits...)
+	}
+	return out, nil
+}
+
+// planWorkspaceEdit reads the files a workspace edit touches and computes
+// their new contents without writing anything.
+func planWorkspaceEdit(we *WorkspaceEdit) ([]fileChange, error) {
+	if we == nil {
+		return nil, nil
+	}
+	byFile, err := we.textEdits()
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]string, 0, len(byFile))
+	for path := range byFile {
+		paths = append(paths, path)
+	}
+	slices.Sort(paths)
+
+	var changes []fileChange
+	for _, path := range paths {
+		change, err := planTextEdits(path, byFile[path])
+		if err != nil {
+			return nil, err
+		}
+		if change != nil {
+			changes = append(changes, *change)
+		}
+	}
+	return changes, nil
+}
+
+// planTextEdits computes the new content of path after edits. It returns nil
+// if the edits leave the file unchanged.
+func planTextEdits(path string, edits []TextEdit) (*fileChange, error) {
+	old, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := applyTextEdits(old, edits)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if bytes.Equal(old, updated) {
+		return nil, nil
+	}
+	return &fileChange{path: path, old: old, new: updated}, nil
+}
+
+// applyTextEdits applies LSP text edits to content.
+func applyTextEdits(content []byte, edits []TextEdit) ([]byte, error) {
+	buf := editbuf.NewBuffer(content)
+	for _, e := range edits {
+		start := positionOffset(content, e.Range.Start)
+		end := positionOffset(content, e.Range.End)
+		if end < start {
+			return nil, fmt.Errorf("invalid edit range %d:%d-%d:%d",
+				e.Range.Start.Line+1, e.Range.Start.Character+1, e.Range.End.Line+1, e.Range.End.Character+1)
+		}
+		buf.Replace(start, end, e.NewText)
+	}
+	return buf.Bytes()
+}
+
+// positionOffset converts an LSP position, whose character is counted in
+// UTF-16 code units, to a byte offset in content. Positions past the end of
+// a line or of the file are clamped, as the spec requires.
+func positionOffset(content []byte, pos Position) int {
+	off := 0
+	for line := 0; line < pos.Line; line++ {
+		i := bytes.IndexByte(content[off:], '\n')
+		if i < 0 {
+			return len(content)
+		}
+		off += i + 1
+	}
+	lineEnd := len(content)
+	if i := bytes.IndexByte(content[off:], '\n'); i >= 0 {
+		lineEnd = off + i
+	}
+	if lineEnd > off && content[lineEnd-1] == '\r' {
+		lineEnd--
+	}
+	for units := 0; units < pos.Character && off < lineEnd; {
+		r, size := utf8.DecodeRune(content[off:lineEnd])
+		if r >= 0x10000 {
+			units += 2
+		} else {
+			units++
+		}
+		off += size
+	}
+	return off
+}
+
+// writeFileChanges writes the planned changes to disk. If a write fails, the
+// files already written are restored.
+func writeFileChanges(changes []fileChange) error {
+	// The files already exist, so WriteFile keeps their permissions.
+	for i, c := range changes {
+		if err := os.WriteFile(c.path, c.new, 0o644); err != nil {
+			for _, done := range changes[:i] {
+				_ = os.WriteFile(done.path, done.old, 0o644)
+			}
+			return fmt.Errorf("write %s: %w", c.path, err)
+		}
+	}
+	return nil
+}
+
+// syncFileChanges tells srv about the new contents of changed files.
+func syncFileChanges(ctx context.Context, srv *Server, changes []fileChange) {
+	for _, c := range changes {
+		if err := srv.OpenFile(ctx, c.path); err != nil {
+			slog.DebugContext(ctx, "lsp: failed to sync edited file", "file", c.path, "error", err)
+		}
+	}
+}
+
+// formatFileChanges describes applied changes as unified diffs.
+func formatFileChanges(changes []fileChange, wd string) string {
+	var sb strings.Builder
+	for _, c := range changes {
+		sb.WriteString(filediff.Unified(relativePath(c.path, wd), string(c.old), string(c.new)))
+	}
+	return sb.String()
+}
+
+// fileChangesDisplay returns display data for applied changes, one entry
+// per file, in the form the patch tool uses.
+func fileChangesDisplay(changes []fileChange) []filediff.DisplayData {
+	display := make([]filediff.DisplayData, len(changes))
+	for i, c := range changes {
+		display[i] = filediff.NewDisplayData(c.path, string(c.old), string(c.new))
+	}
+	return display
+}
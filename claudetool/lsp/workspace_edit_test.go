@@ -0,0 +1,122 @@
+package lsp
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPositionOffset(t *testing.T) {
+	content := []byte("ab\r\nx😀y\nlast")
+	tests := []struct {
+		pos  Position
+		want int
+	}{
+		{Position{Line: 0, Character: 0}, 0},
+		{Position{Line: 0, Character: 9}, 2}, // clamped before \r\n
+		{Position{Line: 1, Character: 1}, 5},
+		{Position{Line: 1, Character: 3}, 9}, // the emoji is two UTF-16 units
+		{Position{Line: 2, Character: 4}, 15},
+		{Position{Line: 7, Character: 0}, 15}, // past the end of the file
+	}
+	for _, tt := range tests {
+		if got := positionOffset(content, tt.pos); got != tt.want {
+			t.Errorf("positionOffset(%+v) = %d, want %d", tt.pos, got, tt.want)
+		}
+	}
+}
+
+func TestApplyTextEdits(t *testing.T) {
+	content := []byte("func foo() {\n\tfoo()\n}\n")
+	edits := []TextEdit{
+		{Range: Range{Start: Position{0, 5}, End: Position{0, 8}}, NewText: "bar"},
+		{Range: Range{Start: Position{1, 1}, End: Position{1, 4}}, NewText: "bar"},
+	}
+	got, err := applyTextEdits(content, edits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "func bar() {\n\tbar()\n}\n"; string(got) != want {
+		t.Errorf("applyTextEdits = %q, want %q", got, want)
+	}
+
+	overlapping := []TextEdit{
+		{Range: Range{Start: Position{0, 0}, End: Position{0, 8}}, NewText: "x"},
+		{Range: Range{Start: Position{0, 5}, End: Position{0, 10}}, NewText: "y"},
+	}
+	if _, err := applyTextEdits(content, overlapping); err == nil {
+		t.Error("expected an error for overlapping edits")
+	}
+}
+
+func TestPlanWorkspaceEdit(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.go")
+	b := filepath.Join(dir, "b.go")
+	if err := os.WriteFile(a, []byte("var foo = 1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(b, []byte("var x = foo\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	docEdit := func(path string, rng Range) json.RawMessage {
+		raw, err := json.Marshal(TextDocumentEdit{
+			TextDocument: VersionedTextDocumentIdentifier{URI: fileURI(path)},
+			Edits:        []TextEdit{{Range: rng, NewText: "bar"}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return raw
+	}
+	we := &WorkspaceEdit{DocumentChanges: []json.RawMessage{
+		docEdit(b, Range{Start: Position{0, 8}, End: Position{0, 11}}),
+		docEdit(a, Range{Start: Position{0, 4}, End: Position{0, 7}}),
+	}}
+	changes, err := planWorkspaceEdit(we)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 2 || changes[0].path != a || changes[1].path != b {
+		t.Fatalf("planWorkspaceEdit = %+v", changes)
+	}
+	if got, _ := os.ReadFile(a); string(got) != "var foo = 1\n" {
+		t.Error("planning an edit must not write files")
+	}
+
+	if err := writeFileChanges(changes); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(b); string(got) != "var x = bar\n" {
+		t.Errorf("b.go = %q", got)
+	}
+	out := formatFileChanges(changes, dir)
+	for _, want := range []string{"--- a.go", "+var bar = 1", "--- b.go", "+var x = bar"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("diff missing %q:\n%s", want, out)
+		}
+	}
+	display := fileChangesDisplay(changes)
+	if len(display) != 2 || display[1].Path != b || display[1].NewContent != "var x = bar\n" || !strings.Contains(display[1].Diff, "+var x = bar") {
+		t.Errorf("fileChangesDisplay = %+v", display)
+	}
+
+	rename := &WorkspaceEdit{DocumentChanges: []json.RawMessage{
+		json.RawMessage(`{"kind":"rename","oldUri":"file:///a","newUri":"file:///b"}`),
+	}}
+	if _, err := planWorkspaceEdit(rename); err == nil || !strings.Contains(err.Error(), "rename operation") {
+		t.Errorf("planWorkspaceEdit with a file rename: err = %v", err)
+	}
+}
+
+func TestFormattingOptions(t *testing.T) {
+	if opts := formattingOptions([]byte("func f() {\n\treturn\n}\n")); opts.InsertSpaces {
+		t.Error("tab-indented content should not insert spaces")
+	}
+	if opts := formattingOptions([]byte("def f():\n    return\n")); !opts.InsertSpaces {
+		t.Error("space-indented content should insert spaces")
+	}
+}
@@ -13,7 +13,8 @@ import (
 	"path/filepath"
 	"strings"
 
-	"github.com/pkg/diff"
+	"github.com/tgruben-circuit/percy/claudetool/filediff"
+	"github.com/tgruben-circuit/percy/claudetool/lsp"
 	"github.com/tgruben-circuit/percy/llm"
 	"sketch.dev/claudetool/editbuf"
 	"sketch.dev/claudetool/patchkit"
@@ -39,6 +40,12 @@ type PatchTool struct {
 	// NB: The actual implementation of the patch tool is unchanged,
 	// this flag merely extends the description and input schema to include the clipboard operations.
 	ClipboardEnabled bool
+	// LSP, if set, reports errors each patch introduces, as found by the
+	// file's language server, unless ReportDiagnostics returns false.
+	LSP               *lsp.Manager
+	ReportDiagnostics func() bool // may be nil
+	// Checkpointer, if set, saves each file's contents before it is patched.
+	Checkpointer Checkpointer
 	// clipboards stores clipboard name -> text
 	clipboards map[string]string
 }
@@ -261,12 +268,7 @@ type PatchInputOneString struct {
 }
 
 // PatchDisplayData is the structured data sent to the UI for display.
-type PatchDisplayData struct {
-	Path       string `json:"path"`
-	OldContent string `json:"oldContent"`
-	NewContent string `json:"newContent"`
-	Diff       string `json:"diff"`
-}
+type PatchDisplayData = filediff.DisplayData
 
 // PatchRequest represents a single patch operation.
 type PatchRequest struct {
@@ -532,6 +534,11 @@ func (p *PatchTool) patchRun(ctx context.Context, input *PatchInput) llm.ToolOut
 	if err != nil {
 		return llm.ErrorToolOut(err)
 	}
+	var editCheck *lsp.EditCheck
+	if p.LSP != nil && (p.ReportDiagnostics == nil || p.ReportDiagnostics()) {
+		editCheck = p.LSP.BeginEdit(ctx, input.Path)
+	}
+	saveCheckpoint(ctx, p.Checkpointer, []string{input.Path})
 	if err := os.MkdirAll(filepath.Dir(input.Path), 0o700); err != nil {
 		return llm.ErrorfToolOut("failed to create directory %q: %w", filepath.Dir(input.Path), err)
 	}
@@ -549,19 +556,14 @@ func (p *PatchTool) patchRun(ctx context.Context, input *PatchInput) llm.ToolOut
 		fmt.Fprintf(response, "<warning>%q appears to be autogenerated. Patches were applied anyway.</warning>\n", input.Path)
 	}
 
-	diff := generateUnifiedDiff(input.Path, string(orig), string(patched))
-
-	// Display data for the UI includes structured content for Monaco diff editor
-	displayData := PatchDisplayData{
-		Path:       input.Path,
-		OldContent: string(orig),
-		NewContent: string(patched),
-		Diff:       diff,
+	if report := editCheck.Finish(ctx); report != "" {
+		fmt.Fprintf(response, "<diagnostics>\n%s\n</diagnostics>\n", report)
 	}
 
+	// Display data for the UI includes structured content for Monaco diff editor
 	return llm.ToolOut{
 		LLMContent: llm.TextContent(response.String()),
-		Display:    displayData,
+		Display:    filediff.NewDisplayData(input.Path, string(orig), string(patched)),
 	}
 }
 
@@ -610,15 +612,6 @@ var autogeneratedHeaderSignals = []string{
 	strings.ToLower("export by"),
 }
 
-func generateUnifiedDiff(filePath, original, patched string) string {
-	buf := new(strings.Builder)
-	err := diff.Text(filePath, filePath, original, patched, buf)
-	if err != nil {
-		return fmt.Sprintf("(diff generation failed: %v)\n", err)
-	}
-	return buf.String()
-}
-
 // reindent applies indentation adjustments to text.
 func reindent(text string, adj *Reindent) (string, error) {
 	if adj == nil {
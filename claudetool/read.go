@@ -10,6 +10,7 @@ import (
 	"strings"
 
 	"github.com/tgruben-circuit/percy/llm"
+	"github.com/tgruben-circuit/percy/llm/docutil"
 )
 
 // ReadFileTool reads a file and returns its contents with line numbers.
@@ -25,7 +26,10 @@ Returns file contents formatted with line numbers (like cat -n). Supports pagina
 via offset and limit parameters for large files.
 
 Use this instead of bash cat/head/tail for reading files — it provides line numbers,
-binary detection, and pagination without shell overhead.`
+binary detection, and pagination without shell overhead.
+
+PDF files are returned as documents you can read directly; offset and limit do not
+apply to them.`
 
 	readFileInputSchema = `{
   "type": "object",
@@ -48,6 +52,9 @@ binary detection, and pagination without shell overhead.`
 
 	readFileDefaultLimit = 1000
 	readFileMaxLimit     = 10000
+
+	// readFileMaxDocumentSize matches the largest PDF the providers accept.
+	readFileMaxDocumentSize = 32 << 20
 )
 
 type readFileInput struct {
@@ -112,6 +119,19 @@ func (r *ReadFileTool) Run(ctx context.Context, m json.RawMessage) llm.ToolOut {
 		return llm.ErrorfToolOut("failed to read file: %w", err)
 	}
 
+	// PDFs go to the model as documents.
+	if docutil.IsPDF(data) {
+		if len(data) > readFileMaxDocumentSize {
+			return llm.ErrorfToolOut("PDF is too large to read (%d bytes, max %d): %s", len(data), readFileMaxDocumentSize, path)
+		}
+		return llm.ToolOut{
+			LLMContent: []llm.Content{
+				llm.StringContent(fmt.Sprintf("File: %s (PDF document, %d bytes)", path, len(data))),
+				llm.DocumentContent(filepath.Base(path), "application/pdf", data),
+			},
+		}
+	}
+
 	// Binary detection.
 	if isBinary(data) {
 		return llm.ErrorfToolOut("file appears to be binary: %s", path)
@@ -2,12 +2,15 @@ package claudetool
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/tgruben-circuit/percy/llm"
 )
 
 func TestReadFileTool(t *testing.T) {
@@ -91,6 +94,28 @@ func TestReadFileTool(t *testing.T) {
 		}
 	})
 
+	t.Run("pdf document", func(t *testing.T) {
+		pdf := "%PDF-1.4\n\x00binary\n%%EOF\n"
+		p := writeFile("spec.pdf", pdf)
+		result := tool.Run(ctx, json.RawMessage(fmt.Sprintf(`{"path":%q}`, p)))
+		if result.Error != nil {
+			t.Fatalf("unexpected error: %v", result.Error)
+		}
+		if len(result.LLMContent) != 2 {
+			t.Fatalf("expected 2 contents, got %d", len(result.LLMContent))
+		}
+		if !strings.Contains(result.LLMContent[0].Text, "PDF document") {
+			t.Errorf("expected PDF description, got %q", result.LLMContent[0].Text)
+		}
+		doc := result.LLMContent[1]
+		if doc.Type != llm.ContentTypeDocument || doc.MediaType != "application/pdf" || doc.Title != "spec.pdf" {
+			t.Errorf("unexpected document content: %+v", doc)
+		}
+		if want := base64.StdEncoding.EncodeToString([]byte(pdf)); doc.Data != want {
+			t.Errorf("document data = %q, want %q", doc.Data, want)
+		}
+	})
+
 	t.Run("directory rejection", func(t *testing.T) {
 		_, err := run(readFileInput{Path: tmpDir})
 		if err == nil {
@@ -0,0 +1,168 @@
+package sandbox
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/tgruben-circuit/percy/claudetool/hostmatch"
+)
+
+// proxy is an HTTP proxy that only connects to allowed hosts. It listens
+// on a Unix socket, which sandboxed processes reach through a bridge inside
+// their network namespace.
+type proxy struct {
+	socket string
+	dir    string
+	srv    *http.Server
+}
+
+func startProxy(allowed []string) (*proxy, error) {
+	dir, err := os.MkdirTemp("", "percy-sandbox-")
+	if err != nil {
+		return nil, err
+	}
+	socket := filepath.Join(dir, "proxy.sock")
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	p := &proxy{
+		socket: socket,
+		dir:    dir,
+		srv:    &http.Server{Handler: newProxyHandler(allowed), ReadHeaderTimeout: 30 * time.Second},
+	}
+	go p.srv.Serve(ln) //nolint:errcheck // returns when closed
+	return p, nil
+}
+
+func (p *proxy) close() {
+	p.srv.Close()
+	os.RemoveAll(p.dir)
+}
+
+// proxyHandler serves CONNECT requests and absolute-URL HTTP requests for
+// allowed hosts.
+type proxyHandler struct {
+	allowed   []string
+	transport http.RoundTripper
+	dial      func(network, addr string) (net.Conn, error)
+}
+
+func newProxyHandler(allowed []string) *proxyHandler {
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	return &proxyHandler{
+		allowed: allowed,
+		transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 30 * time.Second,
+		},
+		dial: dialer.Dial,
+	}
+}
+
+// hopHeaders are removed from proxied requests and responses.
+var hopHeaders = []string{
+	"Connection", "Proxy-Connection", "Keep-Alive", "Proxy-Authenticate",
+	"Proxy-Authorization", "Te", "Trailer", "Transfer-Encoding", "Upgrade",
+}
+
+func (h *proxyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodConnect {
+		h.serveConnect(w, r)
+		return
+	}
+	if !r.URL.IsAbs() {
+		http.Error(w, "this is a proxy; requests must use absolute URLs", http.StatusBadRequest)
+		return
+	}
+	if !hostmatch.Match(h.allowed, r.URL.Hostname()) {
+		h.deny(w, r.URL.Hostname())
+		return
+	}
+
+	out := r.Clone(r.Context())
+	out.RequestURI = ""
+	for _, k := range hopHeaders {
+		out.Header.Del(k)
+	}
+	resp, err := h.transport.RoundTrip(out)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	for k, vs := range resp.Header {
+		for _, v := range vs {
+			w.Header().Add(k, v)
+		}
+	}
+	for _, k := range hopHeaders {
+		w.Header().Del(k)
+	}
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body) //nolint:errcheck // client went away
+}
+
+func (h *proxyHandler) serveConnect(w http.ResponseWriter, r *http.Request) {
+	host, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		http.Error(w, "CONNECT needs host:port", http.StatusBadRequest)
+		return
+	}
+	if !hostmatch.Match(h.allowed, host) {
+		h.deny(w, host)
+		return
+	}
+	upstream, err := h.dial("tcp", r.Host)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		upstream.Close()
+		http.Error(w, "hijacking not supported", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	client, buf, err := hj.Hijack()
+	if err != nil {
+		upstream.Close()
+		return
+	}
+	// Bytes the client sent after the CONNECT request are already buffered.
+	if n := buf.Reader.Buffered(); n > 0 {
+		data, _ := buf.Reader.Peek(n)
+		if _, err := upstream.Write(data); err != nil {
+			upstream.Close()
+			client.Close()
+			return
+		}
+	}
+	splice(client, upstream)
+}
+
+func (h *proxyHandler) deny(w http.ResponseWriter, host string) {
+	http.Error(w, "sandbox: network access to "+host+" is not allowed", http.StatusForbidden)
+}
+
+// splice copies between a and b until either side is done, then closes
+// both.
+func splice(a, b net.Conn) {
+	done := make(chan struct{}, 2)
+	cp := func(dst, src net.Conn) {
+		io.Copy(dst, src) //nolint:errcheck // either side may close
+		done <- struct{}{}
+	}
+	go cp(a, b)
+	go cp(b, a)
+	<-done
+	a.Close()
+	b.Close()
+	<-done
+}
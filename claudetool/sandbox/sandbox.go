@@ -0,0 +1,148 @@
+// Package sandbox runs commands with the working tree writable, the rest of
+// the filesystem read-only, network access disabled or limited to allowed
+// hosts, and resource limits applied. API keys are removed from their
+// environment.
+//
+// On Linux the sandbox uses unprivileged user, mount, PID and network namespaces,
+// in the style of bubblewrap. Wrap rewrites a command to re-execute the
+// current binary, which sets up the namespaces before running the command;
+// the package's init function handles that mode, so any binary importing
+// this package can act as the helper. Other platforms do not support the
+// sandbox and Wrap returns an error.
+package sandbox
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+
+	"github.com/tgruben-circuit/percy/claudetool/hostmatch"
+)
+
+// Network modes.
+const (
+	// NetworkNone disables network access; only loopback is available.
+	NetworkNone = "none"
+	// NetworkAllowlist allows HTTP and HTTPS to AllowedHosts through a
+	// filtering proxy, announced with the HTTP_PROXY environment variables.
+	NetworkAllowlist = "allowlist"
+	// NetworkHost shares the host's network.
+	NetworkHost = "host"
+)
+
+// Config configures a sandbox.
+type Config struct {
+	// WritablePaths are writable in addition to the working directory. /tmp,
+	// /run and the temporary directory are writable too, but private and
+	// empty.
+	WritablePaths []string `json:"writable_paths,omitempty"`
+	// Network is NetworkNone (the default), NetworkAllowlist or NetworkHost.
+	Network string `json:"network,omitempty"`
+	// AllowedHosts are the hosts reachable in NetworkAllowlist mode. An
+	// entry matches the host and its subdomains; an entry of the form
+	// *.example.com matches only the subdomains. See hostmatch.Match.
+	AllowedHosts []string `json:"allowed_hosts,omitempty"`
+	// MemoryMB limits the address space of each process, in megabytes.
+	MemoryMB int `json:"memory_mb,omitempty"`
+	// CPUSeconds limits the CPU time of each process.
+	CPUSeconds int `json:"cpu_seconds,omitempty"`
+	// MaxProcesses limits the number of processes of the user. It is a
+	// best-effort guard against fork bombs: the kernel counts all of the
+	// user's processes, including those outside the sandbox, so it must be
+	// set well above the number the user normally runs.
+	MaxProcesses int `json:"max_processes,omitempty"`
+	// MaxFileSizeMB limits the size of files written, in megabytes.
+	MaxFileSizeMB int `json:"max_file_size_mb,omitempty"`
+}
+
+// Validate reports whether c can be used.
+func (c *Config) Validate() error {
+	switch c.Network {
+	case "", NetworkNone, NetworkHost:
+	case NetworkAllowlist:
+		if len(c.AllowedHosts) == 0 {
+			return fmt.Errorf("sandbox network %q needs allowed_hosts", c.Network)
+		}
+	default:
+		return fmt.Errorf("unknown sandbox network %q: use %s, %s or %s", c.Network, NetworkNone, NetworkAllowlist, NetworkHost)
+	}
+	for _, p := range c.WritablePaths {
+		if !filepath.IsAbs(p) {
+			return fmt.Errorf("sandbox writable path %q is not absolute", p)
+		}
+	}
+	if c.MemoryMB < 0 || c.CPUSeconds < 0 || c.MaxProcesses < 0 || c.MaxFileSizeMB < 0 {
+		return fmt.Errorf("sandbox limits must not be negative")
+	}
+	return nil
+}
+
+func (c *Config) network() string {
+	if c.Network == "" {
+		return NetworkNone
+	}
+	return c.Network
+}
+
+// Sandbox wraps commands to run them in a sandbox. It is safe for concurrent
+// use.
+type Sandbox struct {
+	cfg Config
+
+	mu    sync.Mutex
+	proxy *proxy // started on first use in NetworkAllowlist mode
+}
+
+// New returns a sandbox with the given configuration, which should have
+// been checked with Validate. A nil cfg uses the defaults.
+func New(cfg *Config) *Sandbox {
+	s := &Sandbox{}
+	if cfg != nil {
+		s.cfg = *cfg
+	}
+	return s
+}
+
+// Config returns the sandbox's configuration.
+func (s *Sandbox) Config() Config {
+	return s.cfg
+}
+
+// AllowsHost reports whether sandboxed commands may reach host, so tools
+// that make requests on their behalf can apply the same policy.
+func (s *Sandbox) AllowsHost(host string) bool {
+	switch s.cfg.network() {
+	case NetworkHost:
+		return true
+	case NetworkAllowlist:
+		return hostmatch.Match(s.cfg.AllowedHosts, host)
+	default:
+		return false
+	}
+}
+
+// proxySocket returns the path of the filtering proxy's socket, starting
+// the proxy if needed.
+func (s *Sandbox) proxySocket() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.proxy == nil {
+		p, err := startProxy(s.cfg.AllowedHosts)
+		if err != nil {
+			return "", fmt.Errorf("failed to start sandbox proxy: %w", err)
+		}
+		s.proxy = p
+	}
+	return s.proxy.socket, nil
+}
+
+// Close stops the proxy, if it was started. Commands already running lose
+// network access.
+func (s *Sandbox) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.proxy != nil {
+		s.proxy.close()
+		s.proxy = nil
+	}
+}
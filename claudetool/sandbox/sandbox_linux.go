@@ -0,0 +1,551 @@
+package sandbox
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// The helper runs in two stages, selected by os.Args[1]. The init stage
+// starts inside new user, mount, PID and network namespaces, makes the
+// filesystem read-only and starts the exec stage, which applies the
+// resource limits and executes the command. As PID 1 of the PID namespace,
+// the init stage reaps orphaned processes, and they are killed when it
+// exits.
+const (
+	initArg = "__percy-sandbox-init"
+	execArg = "__percy-sandbox-exec"
+	specEnv = "PERCY_SANDBOX_SPEC"
+)
+
+// spec is passed to the helper in specEnv.
+type spec struct {
+	Config      Config   `json:"config"`
+	Writable    []string `json:"writable"`
+	TempDirs    []string `json:"temp_dirs"`
+	Keep        []string `json:"keep,omitempty"`
+	ProxySocket string   `json:"proxy_socket,omitempty"`
+	UID         int      `json:"uid"`
+	GID         int      `json:"gid"`
+}
+
+func init() {
+	if len(os.Args) < 3 {
+		return
+	}
+	switch os.Args[1] {
+	case initArg:
+		os.Exit(runInit(os.Args[2:]))
+	case execArg:
+		os.Exit(runExec(os.Args[2:]))
+	}
+}
+
+// Wrap changes cmd, which must not have been started, to run in the
+// sandbox with the paths in writable, typically the working directory,
+// writable. It returns an error if the sandbox is unavailable, for example
+// because unprivileged user namespaces are disabled.
+func (s *Sandbox) Wrap(cmd *exec.Cmd, writable ...string) error {
+	if err := available(); err != nil {
+		return err
+	}
+	sp := spec{
+		Config:   s.cfg,
+		Writable: writablePaths(append(slices.Clone(writable), s.cfg.WritablePaths...)),
+		// /run holds the sockets of the Docker daemon and the user's D-Bus
+		// session, which can start processes outside the sandbox. Parents
+		// come first, so a temporary directory inside /run stays private.
+		TempDirs: writablePaths([]string{"/run", "/var/run", "/tmp", os.TempDir()}),
+		// resolv.conf is often a link into /run.
+		Keep: writablePaths([]string{"/etc/resolv.conf"}),
+	}
+	if s.cfg.network() == NetworkAllowlist {
+		socket, err := s.proxySocket()
+		if err != nil {
+			return err
+		}
+		sp.ProxySocket = socket
+	}
+	return wrap(cmd, sp)
+}
+
+func wrap(cmd *exec.Cmd, sp spec) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("sandbox: %w", err)
+	}
+	sp.UID, sp.GID = os.Getuid(), os.Getgid()
+	data, err := json.Marshal(sp)
+	if err != nil {
+		return err
+	}
+
+	cmd.Args = append([]string{exe, initArg, cmd.Path}, cmd.Args...)
+	cmd.Path = exe
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	cmd.Env = append(scrubEnv(cmd.Env), specEnv+"="+string(data))
+
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	attr := cmd.SysProcAttr
+	attr.Cloneflags |= syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS | syscall.CLONE_NEWPID
+	if sp.Config.network() != NetworkHost {
+		attr.Cloneflags |= syscall.CLONE_NEWNET
+	}
+	attr.UidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: sp.UID, Size: 1}}
+	attr.GidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: sp.GID, Size: 1}}
+	attr.GidMappingsEnableSetgroups = false
+	return nil
+}
+
+// scrubEnv returns env without API keys, such as ANTHROPIC_API_KEY, which
+// sandboxed commands could otherwise read and send out.
+func scrubEnv(env []string) []string {
+	return slices.DeleteFunc(slices.Clone(env), func(kv string) bool {
+		name, _, _ := strings.Cut(kv, "=")
+		return strings.HasSuffix(strings.ToUpper(name), "_API_KEY")
+	})
+}
+
+// writablePaths resolves symlinks in paths, since mounts apply to real
+// paths. Paths that do not exist are dropped.
+func writablePaths(paths []string) []string {
+	var out []string
+	for _, p := range paths {
+		real, err := filepath.EvalSymlinks(p)
+		if err != nil {
+			continue
+		}
+		if !slices.Contains(out, real) {
+			out = append(out, real)
+		}
+	}
+	return out
+}
+
+// Available reports whether sandboxed commands can run on this system.
+func Available() error {
+	return available()
+}
+
+// available runs a command in a sandbox once to see whether it works.
+var available = sync.OnceValue(func() error {
+	path, err := exec.LookPath("true")
+	if err != nil {
+		return fmt.Errorf("sandbox unavailable: %w", err)
+	}
+	cmd := exec.Command(path)
+	if err := wrap(cmd, spec{}); err != nil {
+		return fmt.Errorf("sandbox unavailable: %w", err)
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("sandbox unavailable (are unprivileged user namespaces enabled?): %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+})
+
+// helperFailed is the exit status of a helper that could not set up the
+// sandbox.
+const helperFailed = 125
+
+func fail(err error) int {
+	fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+	return helperFailed
+}
+
+// runInit runs the init stage. args are the command's path and argv.
+func runInit(args []string) int {
+	var sp spec
+	if err := json.Unmarshal([]byte(os.Getenv(specEnv)), &sp); err != nil {
+		return fail(fmt.Errorf("bad spec: %w", err))
+	}
+	os.Unsetenv(specEnv)
+	// The working directory is found again by name below, so it resolves
+	// to the writable bind mount rather than the directory underneath.
+	wd, err := os.Getwd()
+	if err != nil {
+		return fail(err)
+	}
+
+	// The proxy's socket is in the temporary directory, which is about to
+	// be covered, so it is reached through an open directory instead.
+	var proxySocket string
+	if sp.ProxySocket != "" {
+		dir, err := os.Open(filepath.Dir(sp.ProxySocket))
+		if err != nil {
+			return fail(err)
+		}
+		defer dir.Close()
+		proxySocket = fmt.Sprintf("/proc/self/fd/%d/%s", dir.Fd(), filepath.Base(sp.ProxySocket))
+	}
+
+	if err := setupMounts(sp.Writable, sp.TempDirs, sp.Keep); err != nil {
+		return fail(err)
+	}
+	env := os.Environ()
+	switch sp.Config.network() {
+	case NetworkNone:
+		if err := loopbackUp(); err != nil {
+			return fail(err)
+		}
+	case NetworkAllowlist:
+		if err := loopbackUp(); err != nil {
+			return fail(err)
+		}
+		addr, err := bridgeProxy(proxySocket)
+		if err != nil {
+			return fail(err)
+		}
+		proxyURL := "http://" + addr
+		noProxy := "localhost,127.0.0.1,::1"
+		env = append(env,
+			"HTTP_PROXY="+proxyURL, "HTTPS_PROXY="+proxyURL,
+			"http_proxy="+proxyURL, "https_proxy="+proxyURL,
+			"NO_PROXY="+noProxy, "no_proxy="+noProxy,
+		)
+	}
+
+	limits, err := json.Marshal(spec{Config: sp.Config})
+	if err != nil {
+		return fail(err)
+	}
+	child := exec.Command("/proc/self/exe", append([]string{execArg}, args...)...)
+	child.Args[0] = os.Args[0]
+	child.Env = append(env, specEnv+"="+string(limits))
+	child.Dir = wd
+	child.Stdin, child.Stdout, child.Stderr = os.Stdin, os.Stdout, os.Stderr
+	// A nested user namespace maps the real user back, so the command
+	// runs without the capabilities that set up the sandbox.
+	child.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags:                 syscall.CLONE_NEWUSER,
+		UidMappings:                []syscall.SysProcIDMap{{ContainerID: sp.UID, HostID: 0, Size: 1}},
+		GidMappings:                []syscall.SysProcIDMap{{ContainerID: sp.GID, HostID: 0, Size: 1}},
+		GidMappingsEnableSetgroups: false,
+		Pdeathsig:                  syscall.SIGKILL,
+	}
+	if err := child.Start(); err != nil {
+		return fail(err)
+	}
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP, syscall.SIGQUIT)
+	go func() {
+		for sig := range sigs {
+			child.Process.Signal(sig) //nolint:errcheck // child may have exited
+		}
+	}()
+
+	status, err := reap(child.Process.Pid)
+	if err != nil {
+		return fail(err)
+	}
+	if status.Signaled() {
+		// Die the same way, so the caller sees the signal.
+		signal.Reset(status.Signal())
+		syscall.Kill(os.Getpid(), status.Signal()) //nolint:errcheck // exit below otherwise
+		return 128 + int(status.Signal())
+	}
+	return status.ExitStatus()
+}
+
+// reap waits for the process pid to exit, reaping any other process that
+// exits first: orphans in the PID namespace become children of its PID 1.
+func reap(pid int) (syscall.WaitStatus, error) {
+	for {
+		var status syscall.WaitStatus
+		wpid, err := syscall.Wait4(-1, &status, 0, nil)
+		if errors.Is(err, syscall.EINTR) {
+			continue
+		}
+		if err != nil {
+			return 0, fmt.Errorf("wait: %w", err)
+		}
+		if wpid == pid {
+			return status, nil
+		}
+	}
+}
+
+// runExec runs the exec stage. args are the command's path and argv.
+func runExec(args []string) int {
+	var sp spec
+	if err := json.Unmarshal([]byte(os.Getenv(specEnv)), &sp); err != nil {
+		return fail(fmt.Errorf("bad spec: %w", err))
+	}
+	os.Unsetenv(specEnv)
+	if len(args) < 2 {
+		return fail(errors.New("no command"))
+	}
+	cfg := sp.Config
+	for _, l := range []struct {
+		resource int
+		value    int
+		scale    uint64
+	}{
+		{unix.RLIMIT_AS, cfg.MemoryMB, 1 << 20},
+		{unix.RLIMIT_CPU, cfg.CPUSeconds, 1},
+		{unix.RLIMIT_NPROC, cfg.MaxProcesses, 1},
+		{unix.RLIMIT_FSIZE, cfg.MaxFileSizeMB, 1 << 20},
+	} {
+		if l.value <= 0 {
+			continue
+		}
+		v := uint64(l.value) * l.scale
+		if err := unix.Setrlimit(l.resource, &unix.Rlimit{Cur: v, Max: v}); err != nil {
+			return fail(fmt.Errorf("setrlimit: %w", err))
+		}
+	}
+	err := syscall.Exec(args[0], args[1:], os.Environ())
+	return fail(fmt.Errorf("exec %s: %w", args[0], err))
+}
+
+// setupMounts mounts a /proc for the new PID namespace and private tmpfs
+// file systems on the temporary directories, and makes every mount
+// read-only except those, the writable paths and the ones under /dev. The
+// keep paths stay visible, read-only, if a temporary directory covers them.
+func setupMounts(writable, tempDirs, keep []string) error {
+	// Keep the changes below out of the host's mount namespace.
+	if err := unix.Mount("", "/", "", unix.MS_REC|unix.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("make mounts private: %w", err)
+	}
+	// The host's /proc would show processes outside the sandbox, and their
+	// /proc/<pid>/root leads to the host's writable mounts.
+	if err := unix.Mount("proc", "/proc", "proc", unix.MS_NOSUID|unix.MS_NODEV|unix.MS_NOEXEC, ""); err != nil {
+		return fmt.Errorf("mount /proc: %w", err)
+	}
+	// Writable paths may be inside a temporary directory, so open them
+	// before it is covered and bind them from the open files.
+	files := make([]*os.File, len(writable))
+	for i, p := range writable {
+		f, err := os.OpenFile(p, unix.O_PATH|unix.O_CLOEXEC, 0)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		files[i] = f
+	}
+	keepFiles := make([]*os.File, len(keep))
+	for i, p := range keep {
+		f, err := os.OpenFile(p, unix.O_PATH|unix.O_CLOEXEC, 0)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		keepFiles[i] = f
+	}
+	// The temporary directories are private, so that host sockets and
+	// other users' files in them are out of reach.
+	var mounted []string
+	for _, dir := range tempDirs {
+		if slices.ContainsFunc(mounted, func(m string) bool { return within(dir, m) }) {
+			if err := os.MkdirAll(dir, 0o700); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := unix.Mount("tmpfs", dir, "tmpfs", unix.MS_NOSUID|unix.MS_NODEV, "mode=1777"); err != nil {
+			return fmt.Errorf("mount tmpfs on %s: %w", dir, err)
+		}
+		mounted = append(mounted, dir)
+	}
+	for i, p := range keep {
+		if !slices.ContainsFunc(mounted, func(m string) bool { return within(p, m) }) {
+			continue
+		}
+		if err := mountPoint(p, keepFiles[i]); err != nil {
+			return err
+		}
+		src := fmt.Sprintf("/proc/self/fd/%d", keepFiles[i].Fd())
+		if err := unix.Mount(src, p, "", unix.MS_BIND, ""); err != nil {
+			return fmt.Errorf("bind %s: %w", p, err)
+		}
+	}
+	// Bind mounts of the writable paths keep them writable when the mounts
+	// they are on become read-only.
+	for i, p := range writable {
+		if err := mountPoint(p, files[i]); err != nil {
+			return err
+		}
+		src := fmt.Sprintf("/proc/self/fd/%d", files[i].Fd())
+		if err := unix.Mount(src, p, "", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+			return fmt.Errorf("bind %s: %w", p, err)
+		}
+	}
+	mounts, err := readMountInfo()
+	if err != nil {
+		return err
+	}
+	skip := append(append([]string{"/proc", "/dev"}, mounted...), writable...)
+	for _, m := range mounts {
+		if slices.ContainsFunc(skip, func(p string) bool { return within(m.point, p) }) {
+			continue
+		}
+		err := unix.Mount("", m.point, "", unix.MS_BIND|unix.MS_REMOUNT|unix.MS_RDONLY|m.flags, "")
+		switch {
+		case err == nil:
+		case errors.Is(err, unix.ENOENT), errors.Is(err, unix.EACCES):
+			// Gone, or unreachable and so not writable either.
+		case m.flags&unix.MS_RDONLY != 0:
+			// Already read-only.
+		default:
+			return fmt.Errorf("remount %s read-only: %w", m.point, err)
+		}
+	}
+	return nil
+}
+
+// mountPoint creates p, if a temporary directory covered it, to bind the
+// open file f onto.
+func mountPoint(p string, f *os.File) error {
+	if _, err := os.Lstat(p); err == nil {
+		return nil
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if fi.IsDir() {
+		return os.MkdirAll(p, 0o700)
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(p, nil, 0o600)
+}
+
+// within reports whether path is dir or inside it.
+func within(path, dir string) bool {
+	if dir == "/" {
+		return true
+	}
+	return path == dir || strings.HasPrefix(path, dir+"/")
+}
+
+type mount struct {
+	point string
+	flags uintptr // the per-mount flags, which must be kept on remount
+}
+
+var mountFlags = map[string]uintptr{
+	"ro":          unix.MS_RDONLY,
+	"nosuid":      unix.MS_NOSUID,
+	"nodev":       unix.MS_NODEV,
+	"noexec":      unix.MS_NOEXEC,
+	"noatime":     unix.MS_NOATIME,
+	"nodiratime":  unix.MS_NODIRATIME,
+	"relatime":    unix.MS_RELATIME,
+	"strictatime": unix.MS_STRICTATIME,
+}
+
+// readMountInfo returns the mounts of the current mount namespace.
+func readMountInfo() ([]mount, error) {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseMountInfo(f)
+}
+
+func parseMountInfo(r io.Reader) ([]mount, error) {
+	var mounts []mount
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		// 36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 6 {
+			return nil, fmt.Errorf("bad mountinfo line %q", sc.Text())
+		}
+		m := mount{point: unescapeMountPath(fields[4])}
+		for _, opt := range strings.Split(fields[5], ",") {
+			m.flags |= mountFlags[opt]
+		}
+		mounts = append(mounts, m)
+	}
+	return mounts, sc.Err()
+}
+
+// unescapeMountPath decodes the octal escapes mountinfo uses for spaces and
+// other special characters.
+func unescapeMountPath(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+4 <= len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				sb.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
+
+// loopbackUp brings up the loopback interface of a new network namespace.
+func loopbackUp() error {
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+	ifr, err := unix.NewIfreq("lo")
+	if err != nil {
+		return err
+	}
+	if err := unix.IoctlIfreq(fd, unix.SIOCGIFFLAGS, ifr); err != nil {
+		return fmt.Errorf("get loopback flags: %w", err)
+	}
+	ifr.SetUint16(ifr.Uint16() | unix.IFF_UP)
+	if err := unix.IoctlIfreq(fd, unix.SIOCSIFFLAGS, ifr); err != nil {
+		return fmt.Errorf("bring up loopback: %w", err)
+	}
+	return nil
+}
+
+// bridgeProxy listens on loopback inside the network namespace and forwards
+// connections to the proxy's socket, which is reachable through the
+// filesystem. It returns the address listened on.
+func bridgeProxy(socket string) (string, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				upstream, err := net.Dial("unix", socket)
+				if err != nil {
+					conn.Close()
+					return
+				}
+				splice(conn, upstream)
+			}()
+		}
+	}()
+	return ln.Addr().String(), nil
+}
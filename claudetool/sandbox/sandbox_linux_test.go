@@ -0,0 +1,225 @@
+package sandbox
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// run runs a bash script in a sandbox with cfg and wd writable.
+func run(t *testing.T, cfg *Config, wd, script string) (string, error) {
+	t.Helper()
+	if err := available(); err != nil {
+		t.Skip(err)
+	}
+	s := New(cfg)
+	t.Cleanup(s.Close)
+	cmd := exec.Command("bash", "-c", script)
+	cmd.Dir = wd
+	if err := s.Wrap(cmd, wd); err != nil {
+		t.Fatal(err)
+	}
+	out, err := cmd.CombinedOutput()
+	return string(out), err
+}
+
+func TestSandboxFilesystem(t *testing.T) {
+	outside := t.TempDir()
+	wd := t.TempDir()
+	// Only the parent of these directories is on a read-only mount; make
+	// the temporary directory somewhere else.
+	t.Setenv("TMPDIR", t.TempDir())
+
+	if out, err := run(t, nil, wd, "echo inside > file.txt && echo temp > $TMPDIR/temp.txt && cat /etc/hostname >/dev/null"); err != nil {
+		t.Fatalf("writing the working tree failed: %v\n%s", err, out)
+	}
+	if data, err := os.ReadFile(filepath.Join(wd, "file.txt")); err != nil || string(data) != "inside\n" {
+		t.Fatalf("file.txt = %q, %v", data, err)
+	}
+
+	// The host's temporary directory is hidden by a private one.
+	out, err := run(t, nil, wd, "echo outside > "+filepath.Join(outside, "file.txt"))
+	if err == nil {
+		t.Fatal("writing outside the working tree succeeded")
+	}
+	if !strings.Contains(out, "No such file or directory") {
+		t.Errorf("output = %q, want the host temporary directory hidden", out)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "file.txt")); err == nil {
+		t.Error("file outside the working tree was created")
+	}
+
+	// Elsewhere, the filesystem is read-only.
+	pkgDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	readOnly := filepath.Join(pkgDir, "sandbox-write-test.txt")
+	t.Cleanup(func() { os.Remove(readOnly) })
+	out, err = run(t, nil, wd, "echo outside > "+readOnly)
+	if err == nil || !strings.Contains(out, "Read-only file system") {
+		t.Errorf("writing outside the working tree: %q, %v; want a read-only error", out, err)
+	}
+}
+
+func TestSandboxRun(t *testing.T) {
+	wd := t.TempDir()
+	t.Setenv("TMPDIR", t.TempDir())
+	entries, err := os.ReadDir("/run")
+	if err != nil {
+		t.Skip(err)
+	}
+	resolv, _ := filepath.EvalSymlinks("/etc/resolv.conf")
+
+	// Sockets in /run, such as Docker's or the user's D-Bus session, lead
+	// out of the sandbox.
+	var script strings.Builder
+	for _, e := range entries {
+		p := filepath.Join("/run", e.Name())
+		if resolv != "" && within(resolv, p) {
+			continue
+		}
+		fmt.Fprintf(&script, "test -e %s && echo visible: %s; ", p, p)
+	}
+	script.WriteString("cat /etc/resolv.conf >/dev/null && echo ok")
+	out, err := run(t, nil, wd, script.String())
+	if err != nil || strings.TrimSpace(out) != "ok" {
+		t.Errorf("/run is not private: %v\n%s", err, out)
+	}
+}
+
+func TestSandboxEnv(t *testing.T) {
+	wd := t.TempDir()
+	t.Setenv("TMPDIR", t.TempDir())
+	t.Setenv("ANTHROPIC_API_KEY", "sk-ant-test")
+	t.Setenv("OPENAI_API_KEY", "sk-test")
+	t.Setenv("PERCY_SANDBOX_TEST", "kept")
+
+	out, err := run(t, nil, wd, `echo ${ANTHROPIC_API_KEY-unset} ${OPENAI_API_KEY-unset} $PERCY_SANDBOX_TEST`)
+	if err != nil {
+		t.Fatalf("%v\n%s", err, out)
+	}
+	if got := strings.TrimSpace(out); got != "unset unset kept" {
+		t.Errorf("sandbox environment = %q, want API keys removed and other variables kept", got)
+	}
+}
+
+func TestSandboxProc(t *testing.T) {
+	wd := t.TempDir()
+	t.Setenv("TMPDIR", t.TempDir())
+	pkgDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := filepath.Join(pkgDir, "sandbox-proc-test.txt")
+	t.Cleanup(func() { os.Remove(target) })
+
+	// Processes outside the sandbox are not visible, so their roots, which
+	// are writable, cannot be reached through /proc.
+	script := fmt.Sprintf("echo escaped > /proc/1/root%s; echo escaped > /proc/%d/root%s; ls /proc | grep -c '^[0-9]'", target, os.Getpid(), target)
+	out, _ := run(t, nil, wd, script)
+	if _, err := os.Stat(target); err == nil {
+		t.Fatalf("wrote outside the sandbox through /proc: %s", out)
+	}
+	// bash, ls and grep, plus the sandbox helpers.
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if n, err := strconv.Atoi(lines[len(lines)-1]); err != nil || n > 5 {
+		t.Errorf("sandbox sees %s processes, want only its own:\n%s", lines[len(lines)-1], out)
+	}
+}
+
+func TestSandboxWritablePaths(t *testing.T) {
+	extra := t.TempDir()
+	wd := t.TempDir()
+	t.Setenv("TMPDIR", t.TempDir())
+
+	cfg := &Config{WritablePaths: []string{extra}}
+	if out, err := run(t, cfg, wd, "echo extra > "+filepath.Join(extra, "file.txt")); err != nil {
+		t.Fatalf("writing a writable path failed: %v\n%s", err, out)
+	}
+}
+
+func TestSandboxNetwork(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "hello")
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	connect := fmt.Sprintf("exec 3<>/dev/tcp/%s/%s", u.Hostname(), u.Port())
+	wd := t.TempDir()
+
+	if out, err := run(t, nil, wd, connect); err == nil {
+		t.Errorf("connecting with network disabled succeeded: %s", out)
+	}
+	if out, err := run(t, &Config{Network: NetworkHost}, wd, connect); err != nil {
+		t.Errorf("connecting with host network failed: %v\n%s", err, out)
+	}
+}
+
+func TestSandboxAllowlist(t *testing.T) {
+	if _, err := exec.LookPath("curl"); err != nil {
+		t.Skip("curl not installed")
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "hello")
+	}))
+	defer srv.Close()
+	wd := t.TempDir()
+	// NO_PROXY covers 127.0.0.1, so clear it to reach the test server
+	// through the proxy.
+	curl := "curl -sS --noproxy '' " + srv.URL
+
+	out, err := run(t, &Config{Network: NetworkAllowlist, AllowedHosts: []string{"127.0.0.1"}}, wd, curl)
+	if err != nil || out != "hello" {
+		t.Errorf("allowed host: %q, %v", out, err)
+	}
+	out, err = run(t, &Config{Network: NetworkAllowlist, AllowedHosts: []string{"example.com"}}, wd, curl+" --fail-with-body")
+	if err == nil || !strings.Contains(out, "not allowed") {
+		t.Errorf("blocked host: %q, %v", out, err)
+	}
+}
+
+func TestSandboxLimits(t *testing.T) {
+	cfg := &Config{MemoryMB: 512, MaxFileSizeMB: 1, MaxProcesses: 1000}
+	out, err := run(t, cfg, t.TempDir(), "ulimit -v; ulimit -f; ulimit -u")
+	if err != nil {
+		t.Fatalf("%v\n%s", err, out)
+	}
+	if want := "524288\n1024\n1000\n"; out != want {
+		t.Errorf("limits = %q, want %q", out, want)
+	}
+}
+
+func TestSandboxExitStatus(t *testing.T) {
+	_, err := run(t, nil, t.TempDir(), "exit 3")
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok || exitErr.ExitCode() != 3 {
+		t.Fatalf("err = %v, want exit status 3", err)
+	}
+}
+
+func TestParseMountInfo(t *testing.T) {
+	info := `28 1 254:0 / / rw,relatime - ext4 /dev/vda rw
+29 28 254:16 / /mnt/my\040disk ro,nosuid,nodev,relatime - ext4 /dev/vdb ro
+`
+	mounts, err := parseMountInfo(strings.NewReader(info))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mounts) != 2 {
+		t.Fatalf("got %d mounts", len(mounts))
+	}
+	if mounts[1].point != "/mnt/my disk" {
+		t.Errorf("point = %q", mounts[1].point)
+	}
+	if mounts[1].flags != mountFlags["ro"]|mountFlags["nosuid"]|mountFlags["nodev"]|mountFlags["relatime"] {
+		t.Errorf("flags = %#x", mounts[1].flags)
+	}
+}
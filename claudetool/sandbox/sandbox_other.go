@@ -0,0 +1,20 @@
+//go:build !linux
+
+package sandbox
+
+import (
+	"errors"
+	"os/exec"
+)
+
+var errUnsupported = errors.New("sandbox unavailable: sandboxing is only supported on Linux")
+
+// Available returns an error: the sandbox needs Linux namespaces.
+func Available() error {
+	return errUnsupported
+}
+
+// Wrap returns an error: the sandbox needs Linux namespaces.
+func (s *Sandbox) Wrap(cmd *exec.Cmd, writable ...string) error {
+	return errUnsupported
+}
@@ -0,0 +1,40 @@
+package sandbox
+
+import "testing"
+
+func TestAllowsHost(t *testing.T) {
+	allowlist := New(&Config{Network: NetworkAllowlist, AllowedHosts: []string{"example.com", "*.github.com"}})
+	for host, want := range map[string]bool{
+		"example.com":     true,
+		"www.example.com": true,
+		"api.github.com":  true,
+		"github.com":      false,
+		"evilgithub.com":  false,
+	} {
+		if got := allowlist.AllowsHost(host); got != want {
+			t.Errorf("AllowsHost(%q) = %v, want %v", host, got, want)
+		}
+	}
+	if New(nil).AllowsHost("example.com") || !New(&Config{Network: NetworkHost}).AllowsHost("example.com") {
+		t.Error("AllowsHost ignores the network mode")
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	for _, tt := range []struct {
+		cfg Config
+		ok  bool
+	}{
+		{Config{}, true},
+		{Config{Network: NetworkHost, WritablePaths: []string{"/var/cache"}}, true},
+		{Config{Network: NetworkAllowlist, AllowedHosts: []string{"example.com"}}, true},
+		{Config{Network: NetworkAllowlist}, false},
+		{Config{Network: "bridge"}, false},
+		{Config{WritablePaths: []string{"cache"}}, false},
+		{Config{MemoryMB: -1}, false},
+	} {
+		if err := tt.cfg.Validate(); (err == nil) != tt.ok {
+			t.Errorf("Validate(%+v) = %v, want ok=%v", tt.cfg, err, tt.ok)
+		}
+	}
+}
@@ -4,66 +4,104 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"os"
-	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/tgruben-circuit/percy/llm"
 )
 
-// TodoWriteTool manages a TODO.md checklist in the working directory.
+// Todo statuses.
+const (
+	TodoPending    = "pending"
+	TodoInProgress = "in_progress"
+	TodoDone       = "done"
+)
+
+// Todo is an item of a conversation's task list.
+type Todo struct {
+	// ID identifies the task across updates, such as t1.
+	ID      string `json:"id"`
+	Content string `json:"content"`
+	Status  string `json:"status"`
+}
+
+// TodoStore persists a conversation's task list.
+type TodoStore interface {
+	// ListTodos returns the task list in order.
+	ListTodos(ctx context.Context) ([]Todo, error)
+	// NextTodoNumber returns the number of the next new task's ID, or 1 if
+	// none was saved.
+	NextTodoNumber(ctx context.Context) (int, error)
+	// ReplaceTodos atomically replaces the task list and saves the number
+	// of the next new task's ID.
+	ReplaceTodos(ctx context.Context, todos []Todo, next int) error
+}
+
+// TodoWriteTool maintains the conversation's task list. Each call replaces
+// the whole list; tasks keep stable IDs so they can be updated and
+// reordered without relying on their position.
 type TodoWriteTool struct {
-	WorkingDir *MutableWorkingDir
+	// Store persists the list; nil keeps it in memory.
+	Store TodoStore
+
+	mu    sync.Mutex
+	todos []Todo // used when Store is nil
+	next  int    // used when Store is nil
 }
 
 const (
 	todoWriteName        = "todo_write"
-	todoWriteDescription = `Manage a TODO.md checklist file in the working directory.
-
-Supports three operations:
-- add: Append a new task to TODO.md (creates the file if it doesn't exist)
-- update_status: Update a task's status by its 1-based index among checklist items
-- list: Display the current contents of TODO.md
-
-Tasks use markdown checklist format:
-- [ ] pending
-- [~] in_progress
-- [x] done
-`
+	todoWriteDescription = `Replace the task list for this conversation.
+
+Use it to plan and track work with several steps, and keep it current as you go:
+- Send the complete list every time; tasks left out are removed.
+- Give the id of each existing task to keep it. New tasks have no id and are assigned one.
+- Mark a task in_progress before starting it and done as soon as it is finished. Keep one task in_progress at a time.
+- Reorder the list to reflect the order you will work in.
+
+The list is stored with the conversation, not in the repository, and is shown to the user as a checklist.`
+
 	todoWriteInputSchema = `{
   "type": "object",
-  "required": ["operation"],
+  "required": ["todos"],
   "properties": {
-    "operation": {
-      "type": "string",
-      "enum": ["add", "update_status", "list"],
-      "description": "The operation to perform"
-    },
-    "task": {
-      "type": "string",
-      "description": "The task description (for add operation)"
-    },
-    "task_id": {
-      "type": "integer",
-      "description": "1-based index of the task among checklist items (for update_status operation)"
-    },
-    "status": {
-      "type": "string",
-      "enum": ["pending", "in_progress", "done"],
-      "description": "The new status for the task (for update_status operation)"
+    "todos": {
+      "type": "array",
+      "description": "The complete task list, in order",
+      "items": {
+        "type": "object",
+        "required": ["content", "status"],
+        "properties": {
+          "id": {
+            "type": "string",
+            "description": "ID of an existing task, such as t3; omit for new tasks"
+          },
+          "content": {
+            "type": "string",
+            "description": "What the task is, in one line"
+          },
+          "status": {
+            "type": "string",
+            "enum": ["pending", "in_progress", "done"]
+          }
+        }
+      }
     }
   }
 }`
 )
 
 type todoWriteInput struct {
-	Operation string `json:"operation"`
-	Task      string `json:"task"`
-	TaskID    int    `json:"task_id"`
-	Status    string `json:"status"`
+	Todos []Todo `json:"todos"`
 }
 
-// Tool returns an llm.Tool for managing TODO.md.
+// TodoDisplayData is the structured data sent to the UI for display.
+type TodoDisplayData struct {
+	Todos []Todo `json:"todos"`
+}
+
+// Tool returns an llm.Tool for maintaining the task list.
 func (t *TodoWriteTool) Tool() *llm.Tool {
 	return &llm.Tool{
 		Name:        todoWriteName,
@@ -73,127 +111,131 @@ func (t *TodoWriteTool) Tool() *llm.Tool {
 	}
 }
 
-// Run executes the todo_write tool.
+// Run replaces the task list with the one in m.
 func (t *TodoWriteTool) Run(ctx context.Context, m json.RawMessage) llm.ToolOut {
-	var req todoWriteInput
-	if err := json.Unmarshal(m, &req); err != nil {
+	var input todoWriteInput
+	if err := json.Unmarshal(m, &input); err != nil {
 		return llm.ErrorfToolOut("failed to parse todo_write input: %w", err)
 	}
 
-	todoPath := filepath.Join(t.WorkingDir.Get(), "TODO.md")
-
-	switch req.Operation {
-	case "add":
-		return t.add(todoPath, req.Task)
-	case "update_status":
-		return t.updateStatus(todoPath, req.TaskID, req.Status)
-	case "list":
-		return t.list(todoPath)
-	default:
-		return llm.ErrorfToolOut("unknown operation: %s", req.Operation)
-	}
-}
+	t.mu.Lock()
+	defer t.mu.Unlock()
 
-func (t *TodoWriteTool) add(todoPath, task string) llm.ToolOut {
-	if task == "" {
-		return llm.ErrorfToolOut("task is required for add operation")
+	current, next, err := t.load(ctx)
+	if err != nil {
+		return llm.ErrorfToolOut("failed to read the task list: %w", err)
 	}
-
-	// Read existing content (may not exist yet).
-	content, _ := os.ReadFile(todoPath)
-
-	// Ensure a trailing newline before appending.
-	s := string(content)
-	if len(s) > 0 && !strings.HasSuffix(s, "\n") {
-		s += "\n"
+	todos, next, err := mergeTodos(current, input.Todos, next)
+	if err != nil {
+		return llm.ErrorToolOut(err)
 	}
-	s += fmt.Sprintf("- [ ] %s\n", task)
-
-	if err := os.WriteFile(todoPath, []byte(s), 0o644); err != nil {
-		return llm.ErrorfToolOut("failed to write TODO.md: %w", err)
+	if t.Store != nil {
+		if err := t.Store.ReplaceTodos(ctx, todos, next); err != nil {
+			return llm.ErrorfToolOut("failed to save the task list: %w", err)
+		}
+	} else {
+		t.todos, t.next = todos, next
 	}
 
+	text := "Task list cleared."
+	if len(todos) > 0 {
+		done := 0
+		for _, todo := range todos {
+			if todo.Status == TodoDone {
+				done++
+			}
+		}
+		text = fmt.Sprintf("Task list updated: %d of %d done.\n%s", done, len(todos), FormatTodos(todos))
+	}
 	return llm.ToolOut{
-		LLMContent: llm.TextContent(fmt.Sprintf("Added task: %s", task)),
+		LLMContent: llm.TextContent(text),
+		Display:    TodoDisplayData{Todos: todos},
 	}
 }
 
-func (t *TodoWriteTool) updateStatus(todoPath string, taskID int, status string) llm.ToolOut {
-	if taskID < 1 {
-		return llm.ErrorfToolOut("task_id must be >= 1")
-	}
-	if status == "" {
-		return llm.ErrorfToolOut("status is required for update_status operation")
+// load returns the current task list and the number of the next new task.
+func (t *TodoWriteTool) load(ctx context.Context) ([]Todo, int, error) {
+	if t.Store == nil {
+		return t.todos, t.next, nil
 	}
-
-	var marker string
-	switch status {
-	case "pending":
-		marker = "[ ]"
-	case "in_progress":
-		marker = "[~]"
-	case "done":
-		marker = "[x]"
-	default:
-		return llm.ErrorfToolOut("unknown status: %s (must be pending, in_progress, or done)", status)
+	todos, err := t.Store.ListTodos(ctx)
+	if err != nil {
+		return nil, 0, err
 	}
-
-	data, err := os.ReadFile(todoPath)
+	next, err := t.Store.NextTodoNumber(ctx)
 	if err != nil {
-		return llm.ErrorfToolOut("failed to read TODO.md: %w", err)
+		return nil, 0, err
 	}
+	return todos, next, nil
+}
 
-	lines := strings.Split(string(data), "\n")
-	checklistIndex := 0
-	found := false
-	for i, line := range lines {
-		trimmed := strings.TrimSpace(line)
-		if strings.HasPrefix(trimmed, "- [ ] ") || strings.HasPrefix(trimmed, "- [x] ") || strings.HasPrefix(trimmed, "- [~] ") {
-			checklistIndex++
-			if checklistIndex == taskID {
-				// Replace the checkbox marker, preserving leading whitespace.
-				prefix := line[:len(line)-len(trimmed)]
-				// Extract task text after "- [.] "
-				taskText := trimmed[6:]
-				lines[i] = fmt.Sprintf("%s- %s %s", prefix, marker, taskText)
-				found = true
-				break
+// mergeTodos validates the new list against the current one and assigns
+// IDs to new tasks, numbered from next. It returns the number of the next
+// new task, which only goes up, so the IDs of removed tasks are not reused.
+func mergeTodos(current, todos []Todo, next int) ([]Todo, int, error) {
+	known := make(map[string]bool, len(current))
+	// Lists saved before the number was kept need it raised past their IDs.
+	next = max(next, 1)
+	for _, todo := range current {
+		known[todo.ID] = true
+		next = max(next, todoNumber(todo.ID)+1)
+	}
+
+	merged := make([]Todo, 0, len(todos))
+	seen := make(map[string]bool, len(todos))
+	for i, todo := range todos {
+		todo.Content = strings.TrimSpace(todo.Content)
+		if todo.Content == "" {
+			return nil, 0, fmt.Errorf("task %d has no content", i+1)
+		}
+		switch todo.Status {
+		case "":
+			todo.Status = TodoPending
+		case TodoPending, TodoInProgress, TodoDone:
+		default:
+			return nil, 0, fmt.Errorf("task %q has unknown status %q (must be pending, in_progress or done)", todo.Content, todo.Status)
+		}
+		if todo.ID != "" {
+			if !known[todo.ID] {
+				return nil, 0, fmt.Errorf("unknown task id %q: omit the id for new tasks", todo.ID)
+			}
+			if seen[todo.ID] {
+				return nil, 0, fmt.Errorf("task id %q appears more than once", todo.ID)
 			}
+			seen[todo.ID] = true
 		}
+		merged = append(merged, todo)
 	}
-
-	if !found {
-		return llm.ErrorfToolOut("task_id %d not found (found %d checklist items)", taskID, checklistIndex)
-	}
-
-	if err := os.WriteFile(todoPath, []byte(strings.Join(lines, "\n")), 0o644); err != nil {
-		return llm.ErrorfToolOut("failed to write TODO.md: %w", err)
-	}
-
-	return llm.ToolOut{
-		LLMContent: llm.TextContent(fmt.Sprintf("Updated task %d to %s", taskID, status)),
+	for i := range merged {
+		if merged[i].ID == "" {
+			merged[i].ID = "t" + strconv.Itoa(next)
+			next++
+		}
 	}
+	return merged, next, nil
 }
 
-func (t *TodoWriteTool) list(todoPath string) llm.ToolOut {
-	data, err := os.ReadFile(todoPath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return llm.ToolOut{
-				LLMContent: llm.TextContent("TODO.md does not exist yet. Use the add operation to create it."),
-			}
-		}
-		return llm.ErrorfToolOut("failed to read TODO.md: %w", err)
+// todoNumber returns N for an ID of the form tN, or 0.
+func todoNumber(id string) int {
+	n, err := strconv.Atoi(strings.TrimPrefix(id, "t"))
+	if err != nil || !strings.HasPrefix(id, "t") {
+		return 0
 	}
+	return n
+}
 
-	content := string(data)
-	if strings.TrimSpace(content) == "" {
-		return llm.ToolOut{
-			LLMContent: llm.TextContent("TODO.md is empty."),
+// FormatTodos renders a task list as a markdown checklist with task IDs.
+func FormatTodos(todos []Todo) string {
+	var sb strings.Builder
+	for _, todo := range todos {
+		marker := "[ ]"
+		switch todo.Status {
+		case TodoInProgress:
+			marker = "[~]"
+		case TodoDone:
+			marker = "[x]"
 		}
+		fmt.Fprintf(&sb, "- %s %s: %s\n", marker, todo.ID, todo.Content)
 	}
-
-	return llm.ToolOut{
-		LLMContent: llm.TextContent(content),
-	}
+	return strings.TrimSuffix(sb.String(), "\n")
 }
@@ -0,0 +1,95 @@
+package claudetool
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/tgruben-circuit/percy/llm"
+)
+
+func runTodoWrite(t *testing.T, tool *TodoWriteTool, todos ...Todo) llm.ToolOut {
+	t.Helper()
+	msg, err := json.Marshal(todoWriteInput{Todos: todos})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tool.Run(context.Background(), msg)
+}
+
+func todoList(t *testing.T, out llm.ToolOut) []string {
+	t.Helper()
+	if out.Error != nil {
+		t.Fatal(out.Error)
+	}
+	var list []string
+	for _, todo := range out.Display.(TodoDisplayData).Todos {
+		list = append(list, todo.ID+" "+todo.Status+" "+todo.Content)
+	}
+	return list
+}
+
+func TestTodoWriteTool(t *testing.T) {
+	tool := &TodoWriteTool{}
+
+	out := runTodoWrite(t, tool,
+		Todo{Content: "write tests", Status: TodoInProgress},
+		Todo{Content: "implement"},
+		Todo{Content: "document", Status: TodoPending},
+	)
+	want := []string{"t1 in_progress write tests", "t2 pending implement", "t3 pending document"}
+	if got := todoList(t, out); !slices.Equal(got, want) {
+		t.Fatalf("todos = %q, want %q", got, want)
+	}
+	text := out.LLMContent[0].Text
+	if !strings.Contains(text, "0 of 3 done") || !strings.Contains(text, "- [~] t1: write tests") {
+		t.Errorf("output = %q", text)
+	}
+
+	// Reorder, finish t1, drop t3 and add a task: IDs stay with their tasks
+	// and new IDs are not reused.
+	out = runTodoWrite(t, tool,
+		Todo{ID: "t2", Content: "implement", Status: TodoInProgress},
+		Todo{ID: "t1", Content: "write tests", Status: TodoDone},
+		Todo{Content: "release", Status: TodoPending},
+	)
+	want = []string{"t2 in_progress implement", "t1 done write tests", "t4 pending release"}
+	if got := todoList(t, out); !slices.Equal(got, want) {
+		t.Fatalf("todos = %q, want %q", got, want)
+	}
+
+	for _, bad := range [][]Todo{
+		{{ID: "t9", Content: "unknown", Status: TodoPending}},
+		{{ID: "t2", Content: "a"}, {ID: "t2", Content: "b"}},
+		{{Content: "  ", Status: TodoPending}},
+		{{Content: "x", Status: "blocked"}},
+	} {
+		if out := runTodoWrite(t, tool, bad...); out.Error == nil {
+			t.Errorf("todo_write %+v succeeded", bad)
+		}
+	}
+	if len(tool.todos) != 3 {
+		t.Errorf("failed update changed the list: %+v", tool.todos)
+	}
+
+	// Dropping the task with the highest ID does not free it.
+	out = runTodoWrite(t, tool,
+		Todo{ID: "t2", Content: "implement", Status: TodoDone},
+		Todo{Content: "announce"},
+	)
+	want = []string{"t2 done implement", "t5 pending announce"}
+	if got := todoList(t, out); !slices.Equal(got, want) {
+		t.Fatalf("todos = %q, want %q", got, want)
+	}
+
+	out = runTodoWrite(t, tool)
+	if got := todoList(t, out); len(got) != 0 || out.LLMContent[0].Text != "Task list cleared." {
+		t.Errorf("cleared list = %q, %q", got, out.LLMContent[0].Text)
+	}
+	out = runTodoWrite(t, tool, Todo{Content: "follow up"})
+	if got := todoList(t, out); !slices.Equal(got, []string{"t6 pending follow up"}) {
+		t.Errorf("todos after clearing = %q, want t6", got)
+	}
+}
@@ -4,9 +4,11 @@ import (
 	"context"
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	"github.com/tgruben-circuit/percy/claudetool/browse"
 	"github.com/tgruben-circuit/percy/claudetool/lsp"
+	"github.com/tgruben-circuit/percy/claudetool/sandbox"
 	"github.com/tgruben-circuit/percy/cluster"
 	"github.com/tgruben-circuit/percy/llm"
 	"github.com/tgruben-circuit/percy/skills"
@@ -49,6 +51,31 @@ type ToolSetConfig struct {
 	EnableBrowser bool
 	// EnableCodeIntelligence enables LSP-based code intelligence tools.
 	EnableCodeIntelligence bool
+	// LSPServers adds to or overrides the built-in LSP servers.
+	LSPServers []lsp.ServerConfig
+	// LSPTrustRepoConfig lets a repository's LSP config choose server
+	// commands; see lsp.Manager.TrustRepoConfig.
+	LSPTrustRepoConfig bool
+
+	// PatchDiagnostics reports language-server errors introduced by each
+	// patch. It requires EnableCodeIntelligence and can be changed later
+	// with ToolSet.SetPatchDiagnostics.
+	PatchDiagnostics bool
+	// Sandbox configures the sandbox bash commands run in while
+	// SandboxEnabled is set; nil uses the defaults. SandboxEnabled can be
+	// changed later with ToolSet.SetSandbox.
+	Sandbox        *sandbox.Config
+	SandboxEnabled bool
+	// WebFetch restricts the domains the web_fetch tool may fetch; nil
+	// allows any domain.
+	WebFetch *WebFetchConfig
+	// Checkpointer, if set, saves files before the patch, bash and code
+	// intelligence tools modify them, so the conversation's edits can be
+	// undone.
+	Checkpointer Checkpointer
+	// TodoStore persists the conversation's task list; nil keeps it in
+	// memory for the life of the tool set.
+	TodoStore TodoStore
 	// ModelID is the model being used for this conversation.
 	// Used to determine tool configuration (e.g., simplified patch schema for weaker models).
 	ModelID string
@@ -85,9 +112,12 @@ type ToolSetConfig struct {
 // ToolSet holds a set of tools for a single conversation.
 // Each conversation should have its own ToolSet.
 type ToolSet struct {
-	tools   []*llm.Tool
-	cleanup func()
-	wd      *MutableWorkingDir
+	tools            []*llm.Tool
+	cleanup          func()
+	wd               *MutableWorkingDir
+	jobs             *JobManager
+	patchDiagnostics *atomic.Bool
+	sandboxEnabled   *atomic.Bool
 }
 
 // Tools returns the tools in this set.
@@ -95,7 +125,8 @@ func (ts *ToolSet) Tools() []*llm.Tool {
 	return ts.tools
 }
 
-// Cleanup releases resources held by the tools (e.g., browser).
+// Cleanup releases resources held by the tools (e.g., browser) and kills
+// any background jobs.
 func (ts *ToolSet) Cleanup() {
 	if ts.cleanup != nil {
 		ts.cleanup()
@@ -107,6 +138,22 @@ func (ts *ToolSet) WorkingDir() *MutableWorkingDir {
 	return ts.wd
 }
 
+// Jobs returns the background jobs started by the bash tool.
+func (ts *ToolSet) Jobs() *JobManager {
+	return ts.jobs
+}
+
+// SetPatchDiagnostics turns reporting of errors introduced by patches on or off.
+func (ts *ToolSet) SetPatchDiagnostics(enabled bool) {
+	ts.patchDiagnostics.Store(enabled)
+}
+
+// SetSandbox turns running bash commands in the sandbox on or off. Commands
+// already running are not affected.
+func (ts *ToolSet) SetSandbox(enabled bool) {
+	ts.sandboxEnabled.Store(enabled)
+}
+
 // NewToolSet creates a new set of tools for a conversation.
 // isStrongModel returns true for models that can handle complex tool schemas.
 func isStrongModel(modelID string) bool {
@@ -120,23 +167,37 @@ func NewToolSet(ctx context.Context, cfg ToolSetConfig) *ToolSet {
 		workingDir = "/"
 	}
 	wd := NewMutableWorkingDir(workingDir)
+	jobs := NewJobManager()
+	sb := sandbox.New(cfg.Sandbox)
+	sandboxEnabled := new(atomic.Bool)
+	sandboxEnabled.Store(cfg.SandboxEnabled)
 
 	bashTool := &BashTool{
 		WorkingDir:       wd,
 		LLMProvider:      cfg.LLMProvider,
+		ModelID:          cfg.ModelID,
 		EnableJITInstall: cfg.EnableJITInstall,
 		ConversationID:   cfg.ConversationID,
+		Checkpointer:     cfg.Checkpointer,
+		Jobs:             jobs,
+		Sandbox:          sb,
+		SandboxEnabled:   sandboxEnabled.Load,
 	}
 
 	// Use simplified patch schema for weaker models, full schema for sonnet/opus
 	simplified := !isStrongModel(cfg.ModelID)
+	patchDiagnostics := new(atomic.Bool)
+	patchDiagnostics.Store(cfg.PatchDiagnostics)
 	patchTool := &PatchTool{
-		Simplified:       simplified,
-		WorkingDir:       wd,
-		ClipboardEnabled: true,
+		Simplified:        simplified,
+		WorkingDir:        wd,
+		ClipboardEnabled:  true,
+		ReportDiagnostics: patchDiagnostics.Load,
+		Checkpointer:      cfg.Checkpointer,
 	}
 
 	keywordTool := NewKeywordToolWithWorkingDir(cfg.LLMProvider, wd)
+	keywordTool.modelID = cfg.ModelID
 
 	changeDirTool := &ChangeDirTool{
 		WorkingDir: wd,
@@ -147,13 +208,46 @@ func NewToolSet(ctx context.Context, cfg ToolSetConfig) *ToolSet {
 
 	readFileTool := &ReadFileTool{WorkingDir: wd}
 
+	applyDiffTool := &ApplyDiffTool{WorkingDir: wd, Checkpointer: cfg.Checkpointer}
+
+	jobOutputTool := &JobOutputTool{Jobs: jobs}
+	jobKillTool := &JobKillTool{Jobs: jobs}
+
+	globTool := &GlobTool{WorkingDir: wd}
+	grepTool := &GrepTool{WorkingDir: wd}
+	webFetchTool := &WebFetchTool{
+		Config:         cfg.WebFetch,
+		Sandbox:        sb,
+		SandboxEnabled: sandboxEnabled.Load,
+	}
+
+	cleanups := []func(){jobs.Close, sb.Close}
+
+	// Code intelligence comes first so the patch tool can share its servers.
+	var lspTools []*llm.Tool
+	if cfg.EnableCodeIntelligence {
+		var lspManager *lsp.Manager
+		lspTools, lspManager = lsp.RegisterLSPTools(wd.Get, cfg.LSPServers, func(ctx context.Context, paths []string) {
+			saveCheckpoint(ctx, cfg.Checkpointer, paths)
+		})
+		lspManager.TrustRepoConfig = cfg.LSPTrustRepoConfig
+		patchTool.LSP = lspManager
+		cleanups = append(cleanups, lspManager.Close)
+	}
+
 	tools := []*llm.Tool{
 		bashTool.Tool(),
+		jobOutputTool.Tool(),
+		jobKillTool.Tool(),
 		patchTool.Tool(),
+		applyDiffTool.Tool(),
 		keywordTool.Tool(),
+		globTool.Tool(),
+		grepTool.Tool(),
 		changeDirTool.Tool(),
 		outputIframeTool.Tool(),
 		readFileTool.Tool(),
+		webFetchTool.Tool(),
 	}
 
 	// Add subagent tool if configured and depth limit not reached.
@@ -170,7 +264,7 @@ func NewToolSet(ctx context.Context, cfg ToolSetConfig) *ToolSet {
 	}
 
 	// Register todo_write tool
-	todoWriteTool := &TodoWriteTool{WorkingDir: wd}
+	todoWriteTool := &TodoWriteTool{Store: cfg.TodoStore}
 	tools = append(tools, todoWriteTool.Tool())
 
 	// Register skill_load tool if skills are available
@@ -189,8 +283,6 @@ func NewToolSet(ctx context.Context, cfg ToolSetConfig) *ToolSet {
 		}
 	}
 
-	var cleanups []func()
-
 	if cfg.EnableBrowser {
 		// Get max image dimension from the LLM service
 		maxImageDimension := 0
@@ -206,24 +298,20 @@ func NewToolSet(ctx context.Context, cfg ToolSetConfig) *ToolSet {
 		cleanups = append(cleanups, browserCleanup)
 	}
 
-	if cfg.EnableCodeIntelligence {
-		lspTools, lspCleanup := lsp.RegisterLSPTools(wd.Get)
-		tools = append(tools, lspTools...)
-		cleanups = append(cleanups, lspCleanup)
-	}
+	tools = append(tools, lspTools...)
 
-	var cleanup func()
-	if len(cleanups) > 0 {
-		cleanup = func() {
-			for _, fn := range cleanups {
-				fn()
-			}
+	cleanup := func() {
+		for _, fn := range cleanups {
+			fn()
 		}
 	}
 
 	return &ToolSet{
-		tools:   tools,
-		cleanup: cleanup,
-		wd:      wd,
+		tools:            tools,
+		cleanup:          cleanup,
+		wd:               wd,
+		jobs:             jobs,
+		patchDiagnostics: patchDiagnostics,
+		sandboxEnabled:   sandboxEnabled,
 	}
 }
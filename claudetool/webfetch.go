@@ -0,0 +1,382 @@
+package claudetool
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"github.com/tgruben-circuit/percy/claudetool/hostmatch"
+	"github.com/tgruben-circuit/percy/claudetool/sandbox"
+	"github.com/tgruben-circuit/percy/llm"
+)
+
+// WebFetchConfig restricts the domains the web_fetch tool may fetch. A
+// domain matches itself and its subdomains; *.example.com matches only the
+// subdomains. See hostmatch.Match.
+type WebFetchConfig struct {
+	// AllowedDomains, if non-empty, are the only domains that may be fetched.
+	AllowedDomains []string `json:"allowed_domains,omitempty"`
+	// DeniedDomains may never be fetched; they take precedence over
+	// AllowedDomains.
+	DeniedDomains []string `json:"denied_domains,omitempty"`
+}
+
+// hostAllowed reports whether c permits fetching from host.
+func (c *WebFetchConfig) hostAllowed(host string) bool {
+	if c == nil {
+		return true
+	}
+	if hostmatch.Match(c.DeniedDomains, host) {
+		return false
+	}
+	return len(c.AllowedDomains) == 0 || hostmatch.Match(c.AllowedDomains, host)
+}
+
+// errPrivateAddress is returned for connections to addresses that are not
+// on the public internet.
+var errPrivateAddress = errors.New("fetching from loopback, link-local and private addresses is not allowed")
+
+// webFetchTransport refuses to connect to loopback, link-local and private
+// addresses. The check is made on the address of each connection, after DNS
+// resolution, so neither redirects nor DNS answers can reach them.
+var webFetchTransport = func() *http.Transport {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, Control: checkPublicAddress}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	// A proxy would connect on our behalf, out of reach of the check.
+	t.Proxy = nil
+	t.DialContext = dialer.DialContext
+	return t
+}()
+
+// checkPublicAddress is a net.Dialer Control function that rejects
+// addresses that are not on the public internet.
+func checkPublicAddress(network, address string, _ syscall.RawConn) error {
+	ap, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	ip := ap.Addr()
+	if !isPublicAddr(ip) {
+		return fmt.Errorf("%w: %s", errPrivateAddress, ip.Unmap())
+	}
+	return nil
+}
+
+// nonPublicPrefixes are the ranges that are not on the public internet
+// besides those the netip.Addr methods cover.
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),      // this network
+	netip.MustParsePrefix("100.64.0.0/10"),  // carrier-grade NAT, used by Tailscale
+	netip.MustParsePrefix("192.0.0.0/24"),   // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"),  // benchmarking
+	netip.MustParsePrefix("240.0.0.0/4"),    // reserved, and broadcast
+	netip.MustParsePrefix("64:ff9b:1::/48"), // local-use NAT64
+}
+
+// nat64Prefix is the well-known NAT64 prefix, which embeds an IPv4 address
+// in its last four bytes.
+var nat64Prefix = netip.MustParsePrefix("64:ff9b::/96")
+
+// isPublicAddr reports whether ip is on the public internet. IPv4 addresses
+// mapped into IPv6 or behind NAT64 are checked as the IPv4 address.
+func isPublicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if nat64Prefix.Contains(ip) {
+		b := ip.As16()
+		return isPublicAddr(netip.AddrFrom4([4]byte(b[12:])))
+	}
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsUnspecified() {
+		return false
+	}
+	for _, p := range nonPublicPrefixes {
+		if p.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// WebFetchTool fetches web pages over HTTP and converts them to markdown,
+// without a browser. Fetched pages are cached, so paging through a long
+// page fetches it once; each conversation has its own tool and cache.
+type WebFetchTool struct {
+	Config *WebFetchConfig
+	// Client makes the requests; nil uses a client with a timeout that
+	// only connects to public addresses.
+	Client *http.Client
+	// Sandbox, while SandboxEnabled reports true, limits fetches to the
+	// hosts sandboxed commands may reach.
+	Sandbox        *sandbox.Sandbox
+	SandboxEnabled func() bool
+
+	mu    sync.Mutex
+	cache map[string]*fetchedPage
+}
+
+const (
+	webFetchName        = "web_fetch"
+	webFetchDescription = `Fetch a web page over HTTP and return its main content as markdown.
+
+Navigation, scripts and other page chrome are removed; links are made absolute. Plain text, markdown and JSON responses are returned as-is.
+Long pages are split into pages; request later ones with the page parameter. Fetched pages are cached for this conversation, so paging is cheap.
+
+Use this to read documentation, READMEs, issues and articles. It does not run JavaScript: use the browser tools for pages that need it.`
+
+	webFetchInputSchema = `{
+  "type": "object",
+  "required": ["url"],
+  "properties": {
+    "url": {
+      "type": "string",
+      "description": "The http or https URL to fetch"
+    },
+    "page": {
+      "type": "integer",
+      "description": "Page of the converted content to return, starting at 1 (default: 1)"
+    },
+    "refresh": {
+      "type": "boolean",
+      "description": "Fetch the URL again instead of using the cached copy"
+    }
+  }
+}`
+
+	webFetchPageSize   = 20000
+	webFetchMaxBody    = 5 << 20
+	webFetchTimeout    = 30 * time.Second
+	webFetchCacheTTL   = 15 * time.Minute
+	webFetchCacheLimit = 32
+	webFetchUserAgent  = "Mozilla/5.0 (compatible; percy-web-fetch)"
+)
+
+type webFetchInput struct {
+	URL     string `json:"url"`
+	Page    int    `json:"page"`
+	Refresh bool   `json:"refresh"`
+}
+
+// WebFetchDisplayData is the structured data sent to the UI for display.
+type WebFetchDisplayData struct {
+	URL         string `json:"url"`
+	Title       string `json:"title,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Page        int    `json:"page"`
+	Pages       int    `json:"pages"`
+	Cached      bool   `json:"cached,omitempty"`
+}
+
+// fetchedPage is a fetched URL converted to text and split into pages.
+type fetchedPage struct {
+	url         string // final URL, after redirects
+	title       string
+	contentType string
+	pages       []string
+	fetchedAt   time.Time
+}
+
+// Tool returns an llm.Tool for fetching web pages.
+func (w *WebFetchTool) Tool() *llm.Tool {
+	return &llm.Tool{
+		Name:        webFetchName,
+		Description: webFetchDescription,
+		InputSchema: llm.MustSchema(webFetchInputSchema),
+		Run:         w.Run,
+	}
+}
+
+// Run fetches the URL in m and returns the requested page of its content.
+func (w *WebFetchTool) Run(ctx context.Context, m json.RawMessage) llm.ToolOut {
+	var input webFetchInput
+	if err := json.Unmarshal(m, &input); err != nil {
+		return llm.ErrorfToolOut("failed to unmarshal web_fetch input: %w", err)
+	}
+	u, err := url.Parse(strings.TrimSpace(input.URL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return llm.ErrorfToolOut("invalid URL %q: must be an absolute http or https URL", input.URL)
+	}
+	u.Fragment = ""
+	if err := w.checkHost(u.Hostname()); err != nil {
+		return llm.ErrorToolOut(err)
+	}
+	pageNum := max(input.Page, 1)
+
+	key := u.String()
+	page, cached := w.cached(key)
+	if input.Refresh || !cached {
+		page, err = w.fetch(ctx, u)
+		if err != nil {
+			return llm.ErrorToolOut(err)
+		}
+		w.store(key, page)
+		cached = false
+	}
+	if pageNum > len(page.pages) {
+		return llm.ErrorfToolOut("page %d out of range: %s has %d pages", pageNum, page.url, len(page.pages))
+	}
+
+	var sb strings.Builder
+	if page.title != "" {
+		fmt.Fprintf(&sb, "Title: %s\n", page.title)
+	}
+	fmt.Fprintf(&sb, "URL: %s\n", page.url)
+	if len(page.pages) > 1 {
+		fmt.Fprintf(&sb, "Page %d of %d", pageNum, len(page.pages))
+		if pageNum < len(page.pages) {
+			fmt.Fprintf(&sb, "; use page=%d to continue", pageNum+1)
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString("\n")
+	sb.WriteString(page.pages[pageNum-1])
+
+	return llm.ToolOut{
+		LLMContent: llm.TextContent(sb.String()),
+		Display: WebFetchDisplayData{
+			URL:         page.url,
+			Title:       page.title,
+			ContentType: page.contentType,
+			Page:        pageNum,
+			Pages:       len(page.pages),
+			Cached:      cached,
+		},
+	}
+}
+
+// checkHost returns an error if host may not be fetched.
+func (w *WebFetchTool) checkHost(host string) error {
+	if !w.Config.hostAllowed(host) {
+		return fmt.Errorf("fetching from %s is not allowed by the web_fetch domain list", host)
+	}
+	if w.Sandbox != nil && w.SandboxEnabled != nil && w.SandboxEnabled() && !w.Sandbox.AllowsHost(host) {
+		return fmt.Errorf("fetching from %s is not allowed in the sandbox", host)
+	}
+	return nil
+}
+
+// fetch fetches u and converts the response to text pages.
+func (w *WebFetchTool) fetch(ctx context.Context, u *url.URL) (*fetchedPage, error) {
+	client := http.Client{Timeout: webFetchTimeout, Transport: webFetchTransport}
+	if w.Client != nil {
+		client = *w.Client
+	}
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return errors.New("too many redirects")
+		}
+		return w.checkHost(req.URL.Hostname())
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", webFetchUserAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,text/markdown,text/plain;q=0.9,*/*;q=0.8")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", u, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, webFetchMaxBody+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", u, err)
+	}
+	if len(body) > webFetchMaxBody {
+		return nil, fmt.Errorf("%s is larger than %d MB", u, webFetchMaxBody>>20)
+	}
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("fetching %s: %s", resp.Request.URL, resp.Status)
+	}
+
+	final := resp.Request.URL
+	contentType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if contentType == "" {
+		contentType = http.DetectContentType(body)
+		contentType, _, _ = mime.ParseMediaType(contentType)
+	}
+	page := &fetchedPage{url: final.String(), contentType: contentType, fetchedAt: time.Now()}
+	var text string
+	switch {
+	case contentType == "text/html" || contentType == "application/xhtml+xml":
+		doc, err := htmlToMarkdown(string(body), final)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", final, err)
+		}
+		page.title, text = doc.Title, doc.Markdown
+	case strings.HasPrefix(contentType, "text/") || contentType == "application/json" ||
+		strings.HasSuffix(contentType, "+json") || contentType == "application/xml" || strings.HasSuffix(contentType, "+xml"):
+		text = string(body)
+	default:
+		return nil, fmt.Errorf("%s has unsupported content type %s", final, contentType)
+	}
+	if strings.TrimSpace(text) == "" {
+		text = "(no content)"
+	}
+	page.pages = splitPages(text, webFetchPageSize)
+	return page, nil
+}
+
+// cached returns the unexpired cached page for key.
+func (w *WebFetchTool) cached(key string) (*fetchedPage, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	page, ok := w.cache[key]
+	if !ok || time.Since(page.fetchedAt) > webFetchCacheTTL {
+		return nil, false
+	}
+	return page, true
+}
+
+// store caches page under key, evicting the oldest page when full.
+func (w *WebFetchTool) store(key string, page *fetchedPage) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.cache == nil {
+		w.cache = make(map[string]*fetchedPage)
+	}
+	if _, ok := w.cache[key]; !ok && len(w.cache) >= webFetchCacheLimit {
+		var oldest string
+		for k, p := range w.cache {
+			if oldest == "" || p.fetchedAt.Before(w.cache[oldest].fetchedAt) {
+				oldest = k
+			}
+		}
+		delete(w.cache, oldest)
+	}
+	w.cache[key] = page
+}
+
+// splitPages splits text into pages of at most size bytes, breaking at
+// paragraph or line boundaries where possible.
+func splitPages(text string, size int) []string {
+	var pages []string
+	for len(text) > size {
+		cut := strings.LastIndex(text[:size], "\n\n")
+		if cut < size/2 {
+			cut = strings.LastIndex(text[:size], "\n")
+		}
+		if cut < size/2 {
+			cut = size
+			for cut > 0 && !utf8.RuneStart(text[cut]) {
+				cut--
+			}
+		}
+		pages = append(pages, strings.TrimRight(text[:cut], "\n"))
+		text = strings.TrimLeft(text[cut:], "\n")
+	}
+	return append(pages, text)
+}
@@ -0,0 +1,240 @@
+package claudetool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/tgruben-circuit/percy/claudetool/sandbox"
+	"github.com/tgruben-circuit/percy/llm"
+)
+
+// localClient reaches the loopback test servers, which the default client
+// refuses to connect to.
+var localClient = &http.Client{}
+
+func runWebFetch(tool *WebFetchTool, input webFetchInput) llm.ToolOut {
+	msg, _ := json.Marshal(input)
+	return tool.Run(context.Background(), msg)
+}
+
+func toolText(out llm.ToolOut) string {
+	var sb strings.Builder
+	for _, c := range out.LLMContent {
+		sb.WriteString(c.Text)
+	}
+	return sb.String()
+}
+
+func TestWebFetchTool(t *testing.T) {
+	var requests atomic.Int32
+	long := strings.Repeat("<p>"+strings.Repeat("word ", 1000)+"</p>", 10)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/doc", func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, `<html><head><title>Doc</title></head><body><nav>menu</nav><main><h1>Doc</h1><p>See <a href="/other">other</a>.</p></main></body></html>`)
+	})
+	mux.HandleFunc("/long", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, "<main>"+long+"</main>")
+	})
+	mux.HandleFunc("/data.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"ok": true}`)
+	})
+	mux.HandleFunc("/image.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte{0x89, 'P', 'N', 'G'})
+	})
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/doc", http.StatusFound)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	tool := &WebFetchTool{Client: localClient}
+
+	out := runWebFetch(tool, webFetchInput{URL: srv.URL + "/doc#section"})
+	if out.Error != nil {
+		t.Fatal(out.Error)
+	}
+	text := toolText(out)
+	want := "Title: Doc\nURL: " + srv.URL + "/doc\n\n# Doc\n\nSee [other](" + srv.URL + "/other)."
+	if text != want {
+		t.Errorf("output =\n%s\nwant\n%s", text, want)
+	}
+	display := out.Display.(WebFetchDisplayData)
+	if display.Cached || display.Pages != 1 || display.ContentType != "text/html" {
+		t.Errorf("display = %+v", display)
+	}
+
+	out = runWebFetch(tool, webFetchInput{URL: srv.URL + "/doc"})
+	if !out.Display.(WebFetchDisplayData).Cached || requests.Load() != 1 {
+		t.Errorf("second fetch was not cached: %d requests", requests.Load())
+	}
+	runWebFetch(tool, webFetchInput{URL: srv.URL + "/doc", Refresh: true})
+	if requests.Load() != 2 {
+		t.Errorf("refresh did not fetch again: %d requests", requests.Load())
+	}
+	if other := runWebFetch(&WebFetchTool{Client: localClient}, webFetchInput{URL: srv.URL + "/doc"}); other.Display.(WebFetchDisplayData).Cached {
+		t.Error("cache shared between tools")
+	}
+
+	out = runWebFetch(tool, webFetchInput{URL: srv.URL + "/redirect"})
+	if out.Error != nil || out.Display.(WebFetchDisplayData).URL != srv.URL+"/doc" {
+		t.Errorf("redirect: %v, %+v", out.Error, out.Display)
+	}
+
+	out = runWebFetch(tool, webFetchInput{URL: srv.URL + "/long"})
+	display = out.Display.(WebFetchDisplayData)
+	if display.Pages < 2 || !strings.Contains(toolText(out), "Page 1 of") {
+		t.Fatalf("long page: %+v", display)
+	}
+	var total int
+	for page := 1; page <= display.Pages; page++ {
+		out := runWebFetch(tool, webFetchInput{URL: srv.URL + "/long", Page: page})
+		if out.Error != nil {
+			t.Fatal(out.Error)
+		}
+		total += strings.Count(toolText(out), "word")
+	}
+	if total != 10000 {
+		t.Errorf("pages hold %d words, want 10000", total)
+	}
+	if out := runWebFetch(tool, webFetchInput{URL: srv.URL + "/long", Page: display.Pages + 1}); out.Error == nil {
+		t.Error("page past the end succeeded")
+	}
+
+	out = runWebFetch(tool, webFetchInput{URL: srv.URL + "/data.json"})
+	if !strings.HasSuffix(toolText(out), `{"ok": true}`) {
+		t.Errorf("json output = %q", toolText(out))
+	}
+	for _, path := range []string{"/image.png", "/missing"} {
+		if out := runWebFetch(tool, webFetchInput{URL: srv.URL + path}); out.Error == nil {
+			t.Errorf("fetching %s succeeded", path)
+		}
+	}
+	if out := runWebFetch(tool, webFetchInput{URL: "file:///etc/passwd"}); out.Error == nil {
+		t.Error("fetching a file URL succeeded")
+	}
+}
+
+func TestWebFetchDomains(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "hello")
+	}))
+	defer srv.Close()
+	// localhost resolves to the server too, so redirects can change the host.
+	local := strings.Replace(srv.URL, "127.0.0.1", "localhost", 1)
+
+	for _, tt := range []struct {
+		cfg  *WebFetchConfig
+		url  string
+		want bool
+	}{
+		{nil, srv.URL, true},
+		{&WebFetchConfig{DeniedDomains: []string{"127.0.0.1"}}, srv.URL, false},
+		{&WebFetchConfig{AllowedDomains: []string{"localhost"}}, srv.URL, false},
+		{&WebFetchConfig{AllowedDomains: []string{"localhost"}}, local, true},
+	} {
+		out := runWebFetch(&WebFetchTool{Config: tt.cfg, Client: localClient}, webFetchInput{URL: tt.url})
+		if (out.Error == nil) != tt.want {
+			t.Errorf("fetch %s with %+v: error %v, want ok=%v", tt.url, tt.cfg, out.Error, tt.want)
+		}
+	}
+
+	redirect := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, local, http.StatusFound)
+	}))
+	defer redirect.Close()
+	tool := &WebFetchTool{Config: &WebFetchConfig{DeniedDomains: []string{"localhost"}}, Client: localClient}
+	if out := runWebFetch(tool, webFetchInput{URL: redirect.URL}); out.Error == nil || !strings.Contains(out.Error.Error(), "not allowed") {
+		t.Errorf("redirect to a denied domain: %v", out.Error)
+	}
+}
+
+func TestWebFetchSandbox(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "hello")
+	}))
+	defer srv.Close()
+
+	var enabled atomic.Bool
+	tool := &WebFetchTool{Client: localClient, Sandbox: sandbox.New(nil), SandboxEnabled: enabled.Load}
+	if out := runWebFetch(tool, webFetchInput{URL: srv.URL}); out.Error != nil {
+		t.Errorf("fetch outside the sandbox: %v", out.Error)
+	}
+	enabled.Store(true)
+	if out := runWebFetch(tool, webFetchInput{URL: srv.URL, Refresh: true}); out.Error == nil {
+		t.Error("fetch succeeded in a sandbox without network")
+	}
+	tool.Sandbox = sandbox.New(&sandbox.Config{Network: sandbox.NetworkAllowlist, AllowedHosts: []string{"127.0.0.1"}})
+	if out := runWebFetch(tool, webFetchInput{URL: srv.URL, Refresh: true}); out.Error != nil {
+		t.Errorf("fetch of an allowed host in the sandbox: %v", out.Error)
+	}
+}
+
+func TestWebFetchPrivateAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "hello")
+	}))
+	defer srv.Close()
+
+	// localhost is checked after it resolves, as a redirect target is.
+	local := strings.Replace(srv.URL, "127.0.0.1", "localhost", 1)
+	for _, u := range []string{srv.URL, local} {
+		out := runWebFetch(&WebFetchTool{}, webFetchInput{URL: u})
+		if out.Error == nil || !strings.Contains(out.Error.Error(), "private addresses is not allowed") {
+			t.Errorf("fetch %s: %v, want a private address error", u, out.Error)
+		}
+	}
+
+	for addr, want := range map[string]bool{
+		"93.184.216.34:443":           true,
+		"[2606:4700::1]:443":          true,
+		"127.0.0.1:80":                false,
+		"10.1.2.3:80":                 false,
+		"192.168.0.1:80":              false,
+		"169.254.169.254:80":          false,
+		"0.0.0.0:80":                  false,
+		"[::1]:80":                    false,
+		"[fe80::1]:80":                false,
+		"[fd00::1]:80":                false,
+		"[::ffff:10.0.0.1]:80":        false,
+		"100.64.0.1:80":               false,
+		"100.127.255.254:80":          false,
+		"100.128.0.1:80":              true,
+		"198.18.0.1:80":               false,
+		"198.19.255.255:80":           false,
+		"192.0.0.8:80":                false,
+		"0.1.2.3:80":                  false,
+		"255.255.255.255:80":          false,
+		"[::ffff:100.100.100.100]:80": false,
+		"[::ffff:198.18.0.1]:80":      false,
+		"[64:ff9b::a00:1]:80":         false,
+		"[64:ff9b::6464:6464]:80":     false,
+		"[64:ff9b::7f00:1]:80":        false,
+		"[64:ff9b::5db8:d822]:443":    true,
+		"[64:ff9b:1::1]:80":           false,
+	} {
+		if err := checkPublicAddress("tcp", addr, nil); (err == nil) != want {
+			t.Errorf("checkPublicAddress(%s) = %v, want ok=%v", addr, err, want)
+		}
+	}
+}
+
+func TestSplitPages(t *testing.T) {
+	text := "aaaa\n\nbbbb\n\ncccc"
+	if got := splitPages(text, 8); len(got) != 3 || got[0] != "aaaa" || got[2] != "cccc" {
+		t.Errorf("splitPages = %q", got)
+	}
+	if got := splitPages("ééééé", 3); strings.Join(got, "") != "ééééé" {
+		t.Errorf("splitPages split a rune: %q", got)
+	}
+}
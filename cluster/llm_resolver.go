@@ -28,27 +28,23 @@ OURS version (working branch):
 THEIRS version (worker's change):
 %s
 
-Output ONLY the resolved file content. No explanation, no markdown fences, no line numbers. Just the raw file content.`,
+Submit the complete resolved file content. No explanation, no markdown fences, no line numbers.`,
 			path, taskTitle, taskDesc, base, ours, theirs)
 
-		resp, err := service.Do(ctx, &llm.Request{
+		resolved, err := llm.DoStructured(ctx, service, &llm.Request{
 			Messages: []llm.Message{
 				{
 					Role:    llm.MessageRoleUser,
 					Content: []llm.Content{{Type: llm.ContentTypeText, Text: prompt}},
 				},
 			},
+		}, &llm.StructuredOptions[string]{
+			Name:        "resolve_conflict",
+			Description: "Submit the resolved file content.",
 		})
 		if err != nil {
 			return "", fmt.Errorf("llm resolve %q: %w", path, err)
 		}
-
-		for _, c := range resp.Content {
-			if c.Type == llm.ContentTypeText {
-				return c.Text, nil
-			}
-		}
-
-		return "", fmt.Errorf("llm resolve %q: no text in response", path)
+		return resolved, nil
 	}
 }
@@ -109,6 +109,7 @@ type conversationWithStateForTS struct {
 	Archived             bool    `json:"archived"`
 	ParentConversationID *string `json:"parent_conversation_id"`
 	Model                *string `json:"model"`
+	ThinkingLevel        *string `json:"thinking_level"`
 	Working              bool    `json:"working"`
 }
 
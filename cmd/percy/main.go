@@ -1,6 +1,7 @@
 package main
 
 import (
+	"cmp"
 	"context"
 	crypto_rand "crypto/rand"
 	"encoding/json"
@@ -12,11 +13,15 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/tgruben-circuit/percy/claudetool"
+	"github.com/tgruben-circuit/percy/claudetool/lsp"
 	memtool "github.com/tgruben-circuit/percy/claudetool/memory"
+	"github.com/tgruben-circuit/percy/claudetool/sandbox"
 	"github.com/tgruben-circuit/percy/cluster"
 	"github.com/tgruben-circuit/percy/db"
+	"github.com/tgruben-circuit/percy/llm/llmhttp"
 	"github.com/tgruben-circuit/percy/memory"
 	"github.com/tgruben-circuit/percy/models"
 	"github.com/tgruben-circuit/percy/server"
@@ -53,6 +58,8 @@ func main() {
 		flag.PrintDefaults()
 		fmt.Fprintf(flag.CommandLine.Output(), "\nCommands:\n")
 		fmt.Fprintf(flag.CommandLine.Output(), "  serve [flags]                 Start the web server\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  memory <subcommand>           Manage the memory database\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  cassette <conversation-id>    Export a conversation's LLM requests for replay\n")
 		fmt.Fprintf(flag.CommandLine.Output(), "  unpack-template <name> <dir>  Unpack a project template to a directory\n")
 		fmt.Fprintf(flag.CommandLine.Output(), "  version                       Print version information as JSON\n")
 		fmt.Fprintf(flag.CommandLine.Output(), "\nUse '%s <command> -h' for command-specific help\n", os.Args[0])
@@ -71,6 +78,10 @@ func main() {
 	switch command {
 	case "serve":
 		runServe(global, args[1:])
+	case "memory":
+		runMemory(global, args[1:])
+	case "cassette":
+		runCassette(global, args[1:])
 	case "unpack-template":
 		runUnpackTemplate(args[1:])
 	case "version":
@@ -116,6 +127,7 @@ func runServe(global GlobalConfig, args []string) {
 
 	// Build LLM configuration
 	llmConfig := buildLLMConfig(logger, global.ConfigPath, global.TerminalURL, global.DefaultModel, database)
+	database.SetLLMRequestDeltas(!llmConfig.RecordFullLLMRequestBodies)
 
 	// Initialize LLM service manager (includes custom model support via database)
 	llmManager := server.NewLLMServiceManager(llmConfig)
@@ -125,34 +137,21 @@ func runServe(global GlobalConfig, args []string) {
 	logger.Info("Available models", "models", strings.Join(availableModels, ", "))
 
 	toolSetConfig := setupToolSetConfig(llmManager)
+	toolSetConfig.LSPServers = llmConfig.LSPServers
+	toolSetConfig.LSPTrustRepoConfig = llmConfig.LSPTrustRepoConfig
+	toolSetConfig.Sandbox = llmConfig.Sandbox
+	toolSetConfig.WebFetch = llmConfig.WebFetch
 
 	// Create embedder if configured
-	var embedder memory.Embedder
-	switch provider := os.Getenv("PERCY_EMBED_PROVIDER"); provider {
-	case "ollama":
-		embedURL := os.Getenv("PERCY_EMBED_URL")
-		if embedURL == "" {
-			embedURL = "http://localhost:11434"
-		}
-		embedModel := os.Getenv("PERCY_EMBED_MODEL")
-		if embedModel == "" {
-			embedModel = "nomic-embed-text"
-		}
-		embedder = memory.NewOllamaEmbedder(embedURL, embedModel)
-		logger.Info("Embedder configured", "provider", "ollama", "url", embedURL, "model", embedModel)
-	case "openai":
-		apiKey := os.Getenv("OPENAI_API_KEY")
-		if apiKey == "" {
-			logger.Warn("PERCY_EMBED_PROVIDER=openai but OPENAI_API_KEY is not set")
-		} else {
-			embedder = memory.NewOpenAIEmbedder(apiKey)
-			logger.Info("Embedder configured", "provider", "openai", "model", "text-embedding-3-small")
-		}
-	case "":
-		// Auto-detect: use OpenAI if an API key is available
-		if apiKey := os.Getenv("OPENAI_API_KEY"); apiKey != "" {
-			embedder = memory.NewOpenAIEmbedder(apiKey)
-			logger.Info("Embedder configured", "provider", "openai (auto-detected)", "model", "text-embedding-3-small")
+	embedder := setupEmbedder(logger)
+	if memoryDB != nil && embedder != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		ok, err := memoryDB.CheckEmbedder(ctx, embedder)
+		cancel()
+		if err != nil {
+			logger.Warn("Failed to check memory embedder", "error", err)
+		} else if !ok {
+			logger.Warn("Memory embeddings were produced by a different embedder; run 'percy memory reembed' to migrate them", "embedder", memory.EmbedderName(embedder))
 		}
 	}
 
@@ -167,6 +166,9 @@ func runServe(global GlobalConfig, args []string) {
 	// Pass memory DB and embedder to server for post-conversation indexing
 	svr.SetMemoryDB(memoryDB)
 	svr.SetEmbedder(embedder)
+	svr.SetMemoryMaintenanceInterval(memoryMaintenanceInterval(logger))
+	svr.SetLLMRequestRetention(llmConfig.LLMRequestRetention)
+	svr.SetMemoryBatch(llmConfig.MemoryBatch)
 
 	// Seed notification channels from config file if DB is empty (one-time migration)
 	svr.SeedNotificationChannelsFromConfig(llmConfig.NotificationChannels)
@@ -216,6 +218,68 @@ func runServe(global GlobalConfig, args []string) {
 	}
 }
 
+// setupEmbedder creates the memory embedder selected by PERCY_EMBED_PROVIDER.
+// It returns nil when no embedder is configured (FTS-only search).
+func setupEmbedder(logger *slog.Logger) memory.Embedder {
+	switch provider := os.Getenv("PERCY_EMBED_PROVIDER"); provider {
+	case "local":
+		dim := memory.DefaultLocalDimension
+		if v := os.Getenv("PERCY_EMBED_DIM"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				logger.Warn("Invalid PERCY_EMBED_DIM, using default", "value", v, "default", dim)
+			} else {
+				dim = n
+			}
+		}
+		logger.Info("Embedder configured", "provider", "local", "dimension", dim)
+		return memory.NewLocalEmbedder(dim)
+	case "ollama":
+		embedURL := os.Getenv("PERCY_EMBED_URL")
+		if embedURL == "" {
+			embedURL = "http://localhost:11434"
+		}
+		embedModel := os.Getenv("PERCY_EMBED_MODEL")
+		if embedModel == "" {
+			embedModel = "nomic-embed-text"
+		}
+		logger.Info("Embedder configured", "provider", "ollama", "url", embedURL, "model", embedModel)
+		return memory.NewOllamaEmbedder(embedURL, embedModel)
+	case "openai":
+		apiKey := os.Getenv("OPENAI_API_KEY")
+		if apiKey == "" {
+			logger.Warn("PERCY_EMBED_PROVIDER=openai but OPENAI_API_KEY is not set")
+			return nil
+		}
+		logger.Info("Embedder configured", "provider", "openai", "model", "text-embedding-3-small")
+		return memory.NewOpenAIEmbedder(apiKey)
+	case "":
+		// Auto-detect: use OpenAI if an API key is available
+		if apiKey := os.Getenv("OPENAI_API_KEY"); apiKey != "" {
+			logger.Info("Embedder configured", "provider", "openai (auto-detected)", "model", "text-embedding-3-small")
+			return memory.NewOpenAIEmbedder(apiKey)
+		}
+	default:
+		logger.Warn("Unknown PERCY_EMBED_PROVIDER, vector search disabled", "provider", provider)
+	}
+	return nil
+}
+
+// memoryMaintenanceInterval reads PERCY_MEMORY_MAINTENANCE_INTERVAL (a Go
+// duration such as "6h"; "0" disables). Defaults to 24h.
+func memoryMaintenanceInterval(logger *slog.Logger) time.Duration {
+	v := os.Getenv("PERCY_MEMORY_MAINTENANCE_INTERVAL")
+	if v == "" {
+		return 24 * time.Hour
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		logger.Warn("Invalid PERCY_MEMORY_MAINTENANCE_INTERVAL, using default", "value", v, "error", err)
+		return 24 * time.Hour
+	}
+	return d
+}
+
 func setupLogging(debug bool) *slog.Logger {
 	logLevel := slog.LevelInfo
 	if debug {
@@ -309,6 +373,205 @@ func runUnpackTemplate(args []string) {
 	fmt.Printf("Template %q unpacked to %s\n", templateName, destDir)
 }
 
+// runMemory dispatches memory database subcommands
+func runMemory(global GlobalConfig, args []string) {
+	usage := func() {
+		fmt.Fprintf(os.Stderr, "Usage: percy memory <subcommand> [flags]\n\n")
+		fmt.Fprintf(os.Stderr, "Subcommands:\n")
+		fmt.Fprintf(os.Stderr, "  reembed    Recompute all stored embeddings with the configured embedder\n")
+		fmt.Fprintf(os.Stderr, "  export     Write topics and cells as JSONL\n")
+		fmt.Fprintf(os.Stderr, "  import     Merge a JSONL export into the memory database\n")
+	}
+	if len(args) == 0 {
+		usage()
+		os.Exit(1)
+	}
+
+	switch args[0] {
+	case "reembed":
+		runMemoryReembed(global, args[1:])
+	case "export":
+		runMemoryExport(global, args[1:])
+	case "import":
+		runMemoryImport(global, args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown memory subcommand: %s\n", args[0])
+		usage()
+		os.Exit(1)
+	}
+}
+
+// runMemoryReembed re-embeds every cell and topic with the embedder selected
+// by PERCY_EMBED_PROVIDER, e.g. after switching providers or dimensions.
+func runMemoryReembed(global GlobalConfig, args []string) {
+	fs := flag.NewFlagSet("memory reembed", flag.ExitOnError)
+	batchSize := fs.Int("batch", 64, "Number of texts to embed per request")
+	if err := fs.Parse(args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing memory reembed flags: %v\n", err)
+		os.Exit(1)
+	}
+
+	logger := setupLogging(global.Debug)
+	embedder := setupEmbedder(logger)
+	if embedder == nil {
+		fmt.Fprintf(os.Stderr, "Error: no embedder configured; set PERCY_EMBED_PROVIDER\n")
+		os.Exit(1)
+	}
+
+	memoryDB, err := memory.Open(memory.MemoryDBPath(global.DBPath))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening memory database: %v\n", err)
+		os.Exit(1)
+	}
+	defer memoryDB.Close()
+
+	progress := func(done, total int) {
+		fmt.Fprintf(os.Stderr, "\rRe-embedded %d/%d", done, total)
+	}
+	if err := memoryDB.Reembed(context.Background(), embedder, *batchSize, progress); err != nil {
+		fmt.Fprintf(os.Stderr, "\nError re-embedding memory: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "\n")
+	fmt.Printf("Memory re-embedded with %s\n", memory.EmbedderName(embedder))
+}
+
+// runMemoryExport writes the memory database as JSONL to a file or stdout
+func runMemoryExport(global GlobalConfig, args []string) {
+	fs := flag.NewFlagSet("memory export", flag.ExitOnError)
+	output := fs.String("o", "", "Output file (default stdout)")
+	embeddings := fs.Bool("embeddings", false, "Include stored embedding vectors")
+	superseded := fs.Bool("superseded", false, "Include superseded cells")
+	if err := fs.Parse(args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing memory export flags: %v\n", err)
+		os.Exit(1)
+	}
+
+	memoryDB, err := memory.Open(memory.MemoryDBPath(global.DBPath))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening memory database: %v\n", err)
+		os.Exit(1)
+	}
+	defer memoryDB.Close()
+
+	w := os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating %s: %v\n", *output, err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	opts := memory.ExportOptions{IncludeEmbeddings: *embeddings, IncludeSuperseded: *superseded}
+	if err := memoryDB.Export(context.Background(), w, opts); err != nil {
+		fmt.Fprintf(os.Stderr, "Error exporting memory: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// runMemoryImport merges a JSONL export from a file or stdin
+func runMemoryImport(global GlobalConfig, args []string) {
+	fs := flag.NewFlagSet("memory import", flag.ExitOnError)
+	input := fs.String("f", "", "Input file (default stdin)")
+	if err := fs.Parse(args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing memory import flags: %v\n", err)
+		os.Exit(1)
+	}
+
+	logger := setupLogging(global.Debug)
+	embedder := setupEmbedder(logger)
+
+	memoryDB, err := memory.Open(memory.MemoryDBPath(global.DBPath))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening memory database: %v\n", err)
+		os.Exit(1)
+	}
+	defer memoryDB.Close()
+
+	r := os.Stdin
+	if *input != "" {
+		f, err := os.Open(*input)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error opening %s: %v\n", *input, err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	report, err := memoryDB.Import(context.Background(), r, embedder)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error importing memory: %v\n", err)
+		os.Exit(1)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(report)
+}
+
+// runCassette writes a conversation's recorded LLM requests as a cassette
+// that PERCY_LLM_REPLAY can serve.
+func runCassette(global GlobalConfig, args []string) {
+	fs := flag.NewFlagSet("cassette", flag.ExitOnError)
+	output := fs.String("o", "", "Output file (default <conversation-id>.cassette.json)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: percy cassette [flags] <conversation-id>\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing cassette flags: %v\n", err)
+		os.Exit(1)
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	conversationID := fs.Arg(0)
+
+	logger := setupLogging(global.Debug)
+	database := setupDatabase(global.DBPath, logger)
+	defer database.Close()
+
+	requests, err := database.ListConversationLLMRequests(context.Background(), conversationID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading LLM requests: %v\n", err)
+		os.Exit(1)
+	}
+	if len(requests) == 0 {
+		fmt.Fprintf(os.Stderr, "No LLM requests recorded for conversation %s\n", conversationID)
+		os.Exit(1)
+	}
+
+	cassette := &llmhttp.Cassette{}
+	for _, r := range requests {
+		// Requests that never got a response cannot be replayed
+		if r.StatusCode == nil || r.ResponseBody == nil {
+			continue
+		}
+		in := llmhttp.Interaction{
+			Model:        r.Model,
+			Provider:     r.Provider,
+			URL:          r.Url,
+			StatusCode:   int(*r.StatusCode),
+			ResponseBody: *r.ResponseBody,
+		}
+		if r.RequestBody != nil {
+			in.RequestBody = *r.RequestBody
+		}
+		cassette.Interactions = append(cassette.Interactions, in)
+	}
+
+	path := cmp.Or(*output, conversationID+".cassette.json")
+	if err := cassette.Save(path); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing cassette: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Wrote %d interactions to %s\n", len(cassette.Interactions), path)
+}
+
 // runVersion prints version information as JSON
 func runVersion() {
 	info := version.GetInfo()
@@ -346,6 +609,15 @@ func buildLLMConfig(logger *slog.Logger, configPath, terminalURL, defaultModel s
 		DefaultModel:    defaultModel,
 		DB:              database,
 		Logger:          logger,
+		LLMReplayPath:   os.Getenv("PERCY_LLM_REPLAY"),
+	}
+
+	// Fail fast on a bad cassette rather than starting with no models
+	if llmCfg.LLMReplayPath != "" {
+		if _, err := llmhttp.LoadCassette(llmCfg.LLMReplayPath); err != nil {
+			logger.Error("Failed to load LLM replay cassette", "error", err)
+			os.Exit(1)
+		}
 	}
 
 	if configPath != "" {
@@ -358,11 +630,26 @@ func buildLLMConfig(logger *slog.Logger, configPath, terminalURL, defaultModel s
 		}
 
 		var cfg struct {
-			LLMGateway           string           `json:"llm_gateway"`
-			TerminalURL          string           `json:"terminal_url"`
-			DefaultModel         string           `json:"default_model"`
-			Links                []server.Link    `json:"links"`
-			NotificationChannels []map[string]any `json:"notification_channels"`
+			LLMGateway           string                      `json:"llm_gateway"`
+			TerminalURL          string                      `json:"terminal_url"`
+			DefaultModel         string                      `json:"default_model"`
+			Links                []server.Link               `json:"links"`
+			NotificationChannels []map[string]any            `json:"notification_channels"`
+			ModelFallbacks       map[string][]string         `json:"model_fallbacks"`
+			RateLimits           map[string]models.RateLimit `json:"rate_limits"`
+			ModelRoutes          json.RawMessage             `json:"model_routes"`
+			MemoryBatch          bool                        `json:"memory_batch"`
+			LSPServers           []lsp.ServerConfig          `json:"lsp_servers"`
+			LSPTrustRepoConfig   bool                        `json:"lsp_trust_repo_config"`
+			Sandbox              *sandbox.Config             `json:"sandbox"`
+			WebFetch             *claudetool.WebFetchConfig  `json:"web_fetch"`
+			LLMRequests          *struct {
+				MaxAge      *string `json:"max_age"`
+				MaxRows     *int64  `json:"max_rows"`
+				MaxBytes    *int64  `json:"max_bytes"`
+				Redact      *bool   `json:"redact"`
+				StoreDeltas *bool   `json:"store_deltas"`
+			} `json:"llm_requests"`
 		}
 		if err := json.Unmarshal(data, &cfg); err != nil {
 			logger.Warn("Failed to parse config file", "path", configPath, "error", err)
@@ -411,6 +698,81 @@ func buildLLMConfig(logger *slog.Logger, configPath, terminalURL, defaultModel s
 			llmCfg.NotificationChannels = cfg.NotificationChannels
 			logger.Info("Notification channels configured", "count", len(cfg.NotificationChannels))
 		}
+
+		if len(cfg.ModelFallbacks) > 0 {
+			llmCfg.ModelFallbacks = cfg.ModelFallbacks
+			logger.Info("Model fallback chains configured", "count", len(cfg.ModelFallbacks))
+		}
+
+		if len(cfg.RateLimits) > 0 {
+			llmCfg.RateLimits = cfg.RateLimits
+			logger.Info("LLM rate limits configured", "count", len(cfg.RateLimits))
+		}
+
+		if len(cfg.ModelRoutes) > 0 {
+			if routes, err := models.ParseRoutes(string(cfg.ModelRoutes)); err != nil {
+				logger.Warn("Ignoring model_routes in config file", "error", err)
+			} else if len(routes) > 0 {
+				llmCfg.ModelRoutes = routes
+				logger.Info("Model routes configured", "count", len(routes))
+			}
+		}
+
+		if len(cfg.LSPServers) > 0 {
+			if err := lsp.ValidateServers(lsp.MergeServers(lsp.DefaultServers(), cfg.LSPServers)); err != nil {
+				logger.Warn("Ignoring lsp_servers in config file", "error", err)
+			} else {
+				llmCfg.LSPServers = cfg.LSPServers
+				logger.Info("LSP servers configured", "count", len(cfg.LSPServers))
+			}
+		}
+
+		if cfg.LSPTrustRepoConfig {
+			llmCfg.LSPTrustRepoConfig = true
+			logger.Info("Trusting repository LSP server commands")
+		}
+
+		if cfg.Sandbox != nil {
+			if err := cfg.Sandbox.Validate(); err != nil {
+				logger.Warn("Ignoring sandbox in config file", "error", err)
+			} else {
+				llmCfg.Sandbox = cfg.Sandbox
+				logger.Info("Sandbox configured", "network", cfg.Sandbox.Network)
+			}
+		}
+
+		if cfg.WebFetch != nil {
+			llmCfg.WebFetch = cfg.WebFetch
+			logger.Info("Web fetch domains configured", "allowed", len(cfg.WebFetch.AllowedDomains), "denied", len(cfg.WebFetch.DeniedDomains))
+		}
+
+		if cfg.MemoryBatch {
+			llmCfg.MemoryBatch = true
+			logger.Info("Memory indexing will use batch APIs")
+		}
+
+		// Retention is opt-in: recorded requests are kept until a limit is set
+		if r := cfg.LLMRequests; r != nil {
+			if r.MaxAge != nil {
+				if d, err := time.ParseDuration(*r.MaxAge); err != nil {
+					logger.Warn("Invalid llm_requests.max_age, ignoring", "value", *r.MaxAge, "error", err)
+				} else {
+					llmCfg.LLMRequestRetention.MaxAge = d
+				}
+			}
+			if r.MaxRows != nil {
+				llmCfg.LLMRequestRetention.MaxRows = *r.MaxRows
+			}
+			if r.MaxBytes != nil {
+				llmCfg.LLMRequestRetention.MaxBytes = *r.MaxBytes
+			}
+			if r.Redact != nil {
+				llmCfg.RecordUnredactedLLMRequests = !*r.Redact
+			}
+			if r.StoreDeltas != nil {
+				llmCfg.RecordFullLLMRequestBodies = !*r.StoreDeltas
+			}
+		}
 	}
 
 	return llmCfg
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"log/slog"
 	"net"
 	"net/http"
 	"os"
@@ -13,6 +14,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/tgruben-circuit/percy/llm"
 	"github.com/tgruben-circuit/percy/slug"
 )
 
@@ -232,3 +234,29 @@ func TestSystemdListenerIntegration(t *testing.T) {
 		t.Errorf("Unexpected status code %d, body: %s", resp.StatusCode, body)
 	}
 }
+
+func TestBuildLLMConfigValidatesRoutes(t *testing.T) {
+	t.Setenv("PERCY_LLM_REPLAY", "")
+	dir := t.TempDir()
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+
+	good := filepath.Join(dir, "good.json")
+	os.WriteFile(good, []byte(`{"model_routes": {"slug": ["small"]}}`), 0o644)
+	if cfg := buildLLMConfig(logger, good, "", "", nil); len(cfg.ModelRoutes[llm.TaskSlug]) != 1 {
+		t.Errorf("ModelRoutes = %v, want the slug route", cfg.ModelRoutes)
+	}
+
+	bad := filepath.Join(dir, "bad.json")
+	os.WriteFile(bad, []byte(`{"default_model": "big", "model_routes": {"slugs": ["small"]}}`), 0o644)
+	cfg := buildLLMConfig(logger, bad, "", "", nil)
+	if cfg.ModelRoutes != nil {
+		t.Errorf("ModelRoutes = %v, want invalid routes ignored", cfg.ModelRoutes)
+	}
+	if cfg.DefaultModel != "big" {
+		t.Errorf("DefaultModel = %q, want the rest of the config loaded", cfg.DefaultModel)
+	}
+	if !strings.Contains(logs.String(), `unknown task \"slugs\"`) {
+		t.Errorf("expected a warning about the unknown task, got:\n%s", logs.String())
+	}
+}
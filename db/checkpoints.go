@@ -0,0 +1,93 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"github.com/tgruben-circuit/percy/db/generated"
+)
+
+// FileSnapshot is the state of a file before the agent modified it.
+type FileSnapshot struct {
+	Path    string
+	Existed bool // false if the file did not exist
+	Mode    fs.FileMode
+	Content []byte
+	Omitted bool // the file existed but its content was not saved
+}
+
+// SaveFileCheckpoints records snapshots under the conversation's current
+// turn, which is started by its latest user message. A path already
+// recorded in the turn keeps its first snapshot.
+func (db *DB) SaveFileCheckpoints(ctx context.Context, conversationID string, snapshots []FileSnapshot) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		turn, err := q.GetLatestUserMessage(ctx, conversationID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("no user message in conversation: %s", conversationID)
+		}
+		if err != nil {
+			return err
+		}
+		for _, s := range snapshots {
+			err := q.CreateFileCheckpoint(ctx, generated.CreateFileCheckpointParams{
+				ConversationID: conversationID,
+				MessageID:      turn.MessageID,
+				SequenceID:     turn.SequenceID,
+				Path:           s.Path,
+				Existed:        s.Existed,
+				Mode:           int64(s.Mode.Perm()),
+				Content:        s.Content,
+				Omitted:        s.Omitted,
+			})
+			if err != nil {
+				return fmt.Errorf("checkpoint %s: %w", s.Path, err)
+			}
+		}
+		return nil
+	})
+}
+
+// ListFileCheckpoints returns the conversation's checkpoints, without their
+// contents, oldest turn first.
+func (db *DB) ListFileCheckpoints(ctx context.Context, conversationID string) ([]generated.ListFileCheckpointsRow, error) {
+	var rows []generated.ListFileCheckpointsRow
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		rows, err = q.ListFileCheckpoints(ctx, conversationID)
+		return err
+	})
+	return rows, err
+}
+
+// ListFileCheckpointsSince returns the checkpoints of the turn started by the
+// message with sequenceID and of all later turns, oldest first.
+func (db *DB) ListFileCheckpointsSince(ctx context.Context, conversationID string, sequenceID int64) ([]generated.FileCheckpoint, error) {
+	var checkpoints []generated.FileCheckpoint
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		checkpoints, err = q.ListFileCheckpointsSince(ctx, generated.ListFileCheckpointsSinceParams{
+			ConversationID: conversationID,
+			SequenceID:     sequenceID,
+		})
+		return err
+	})
+	return checkpoints, err
+}
+
+// DeleteFileCheckpointsSince deletes the checkpoints that
+// ListFileCheckpointsSince returns.
+func (db *DB) DeleteFileCheckpointsSince(ctx context.Context, conversationID string, sequenceID int64) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.DeleteFileCheckpointsSince(ctx, generated.DeleteFileCheckpointsSinceParams{
+			ConversationID: conversationID,
+			SequenceID:     sequenceID,
+		})
+	})
+}
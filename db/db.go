@@ -41,6 +41,8 @@ func generateConversationID() (string, error) {
 // DB wraps the database connection pool and provides high-level operations
 type DB struct {
 	pool *Pool
+
+	fullLLMRequestBodies bool // see SetLLMRequestDeltas
 }
 
 // Config holds database configuration
@@ -375,6 +377,46 @@ func (db *DB) UpdateConversationModel(ctx context.Context, conversationID, model
 	})
 }
 
+// UpdateConversationThinkingLevel sets the thinking level for a conversation.
+// An empty level reverts to the model's default.
+func (db *DB) UpdateConversationThinkingLevel(ctx context.Context, conversationID, level string) error {
+	var levelPtr *string
+	if level != "" {
+		levelPtr = &level
+	}
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.UpdateConversationThinkingLevel(ctx, generated.UpdateConversationThinkingLevelParams{
+			ThinkingLevel:  levelPtr,
+			ConversationID: conversationID,
+		})
+	})
+}
+
+// UpdateConversationPatchDiagnostics turns reporting of errors introduced by
+// patches on or off for a conversation.
+func (db *DB) UpdateConversationPatchDiagnostics(ctx context.Context, conversationID string, enabled bool) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.UpdateConversationPatchDiagnostics(ctx, generated.UpdateConversationPatchDiagnosticsParams{
+			PatchDiagnostics: enabled,
+			ConversationID:   conversationID,
+		})
+	})
+}
+
+// UpdateConversationSandbox turns running bash commands in the sandbox on or
+// off for a conversation.
+func (db *DB) UpdateConversationSandbox(ctx context.Context, conversationID string, enabled bool) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		return q.UpdateConversationSandbox(ctx, generated.UpdateConversationSandboxParams{
+			Sandbox:        enabled,
+			ConversationID: conversationID,
+		})
+	})
+}
+
 // Message methods (moved from MessageService)
 
 // MessageType represents the type of message
@@ -763,7 +805,7 @@ func (db *DB) InsertLLMRequest(ctx context.Context, params generated.InsertLLMRe
 		q := generated.New(tx.Conn())
 
 		// If we have a conversation ID and request body, try to find common prefix
-		if params.ConversationID != nil && params.RequestBody != nil {
+		if !db.fullLLMRequestBodies && params.ConversationID != nil && params.RequestBody != nil {
 			// Get the last request for this conversation
 			lastReq, err := q.GetLastRequestForConversation(ctx, params.ConversationID)
 			if err == nil {
@@ -873,6 +915,32 @@ func (db *DB) GetLLMResponseBody(ctx context.Context, id int64) (*string, error)
 	return body, err
 }
 
+// ListConversationLLMRequests returns a conversation's LLM requests in the
+// order they were made, with prefix-deduplicated request bodies reconstructed.
+func (db *DB) ListConversationLLMRequests(ctx context.Context, conversationID string) ([]generated.LlmRequest, error) {
+	var requests []generated.LlmRequest
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		requests, err = q.ListLLMRequestsForConversation(ctx, &conversationID)
+		if err != nil {
+			return err
+		}
+		for i := range requests {
+			if requests[i].PrefixRequestID == nil {
+				continue
+			}
+			var body string
+			if err := reconstructRequestBody(ctx, q, requests[i].ID, &body); err != nil {
+				return err
+			}
+			requests[i].RequestBody = &body
+		}
+		return nil
+	})
+	return requests, err
+}
+
 // GetFullLLMRequestBody reconstructs the full request body for a request,
 // following the prefix chain if necessary.
 func (db *DB) GetFullLLMRequestBody(ctx context.Context, requestID int64) (string, error) {
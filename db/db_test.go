@@ -334,6 +334,20 @@ func TestLLMRequestPrefixDeduplication(t *testing.T) {
 	if reconstructed3 != req3Body {
 		t.Errorf("Reconstructed third request mismatch: expected %q, got %q", req3Body, reconstructed3)
 	}
+
+	// Listing the conversation's requests returns full bodies in order
+	listed, err := db.ListConversationLLMRequests(ctx, conv.ConversationID)
+	if err != nil {
+		t.Fatalf("Failed to list conversation requests: %v", err)
+	}
+	if len(listed) != 3 {
+		t.Fatalf("Expected 3 requests, got %d", len(listed))
+	}
+	for i, want := range []string{req1Body, req2Body, req3Body} {
+		if got := safeDeref(listed[i].RequestBody); got != want {
+			t.Errorf("Listed request %d body mismatch: expected %q, got %q", i, want, got)
+		}
+	}
 }
 
 func TestLLMRequestNoPrefixForShortOverlap(t *testing.T) {
@@ -631,3 +645,32 @@ func TestLLMRequestOpenAIStyle(t *testing.T) {
 		req2FullLen-req2StoredLen,
 		100.0*float64(req2FullLen-req2StoredLen)/float64(req2FullLen))
 }
+
+func TestCreateModelProviderTypes(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	_, err := db.CreateModel(ctx, generated.CreateModelParams{
+		ModelID:      "custom-local",
+		DisplayName:  "Local Qwen",
+		ProviderType: "ollama",
+		Endpoint:     "http://localhost:11434",
+		ModelName:    "qwen3-coder:30b",
+		MaxTokens:    32768,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create ollama model: %v", err)
+	}
+
+	_, err = db.CreateModel(ctx, generated.CreateModelParams{
+		ModelID:      "custom-bogus",
+		DisplayName:  "Bogus",
+		ProviderType: "bogus",
+		Endpoint:     "http://example.com",
+		ModelName:    "x",
+	})
+	if err == nil {
+		t.Error("Expected CHECK constraint to reject unknown provider type")
+	}
+}
@@ -13,7 +13,7 @@ const archiveConversation = `-- name: ArchiveConversation :one
 UPDATE conversations
 SET archived = TRUE, updated_at = CURRENT_TIMESTAMP
 WHERE conversation_id = ?
-RETURNING conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model
+RETURNING conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model, thinking_level, patch_diagnostics, sandbox
 `
 
 func (q *Queries) ArchiveConversation(ctx context.Context, conversationID string) (Conversation, error) {
@@ -29,6 +29,9 @@ func (q *Queries) ArchiveConversation(ctx context.Context, conversationID string
 		&i.Archived,
 		&i.ParentConversationID,
 		&i.Model,
+		&i.ThinkingLevel,
+		&i.PatchDiagnostics,
+		&i.Sandbox,
 	)
 	return i, err
 }
@@ -58,7 +61,7 @@ func (q *Queries) CountConversations(ctx context.Context) (int64, error) {
 const createConversation = `-- name: CreateConversation :one
 INSERT INTO conversations (conversation_id, slug, user_initiated, cwd, model)
 VALUES (?, ?, ?, ?, ?)
-RETURNING conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model
+RETURNING conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model, thinking_level, patch_diagnostics, sandbox
 `
 
 type CreateConversationParams struct {
@@ -88,6 +91,9 @@ func (q *Queries) CreateConversation(ctx context.Context, arg CreateConversation
 		&i.Archived,
 		&i.ParentConversationID,
 		&i.Model,
+		&i.ThinkingLevel,
+		&i.PatchDiagnostics,
+		&i.Sandbox,
 	)
 	return i, err
 }
@@ -95,7 +101,7 @@ func (q *Queries) CreateConversation(ctx context.Context, arg CreateConversation
 const createSubagentConversation = `-- name: CreateSubagentConversation :one
 INSERT INTO conversations (conversation_id, slug, user_initiated, cwd, parent_conversation_id)
 VALUES (?, ?, FALSE, ?, ?)
-RETURNING conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model
+RETURNING conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model, thinking_level, patch_diagnostics, sandbox
 `
 
 type CreateSubagentConversationParams struct {
@@ -123,6 +129,9 @@ func (q *Queries) CreateSubagentConversation(ctx context.Context, arg CreateSuba
 		&i.Archived,
 		&i.ParentConversationID,
 		&i.Model,
+		&i.ThinkingLevel,
+		&i.PatchDiagnostics,
+		&i.Sandbox,
 	)
 	return i, err
 }
@@ -138,7 +147,7 @@ func (q *Queries) DeleteConversation(ctx context.Context, conversationID string)
 }
 
 const getConversation = `-- name: GetConversation :one
-SELECT conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model FROM conversations
+SELECT conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model, thinking_level, patch_diagnostics, sandbox FROM conversations
 WHERE conversation_id = ?
 `
 
@@ -155,12 +164,15 @@ func (q *Queries) GetConversation(ctx context.Context, conversationID string) (C
 		&i.Archived,
 		&i.ParentConversationID,
 		&i.Model,
+		&i.ThinkingLevel,
+		&i.PatchDiagnostics,
+		&i.Sandbox,
 	)
 	return i, err
 }
 
 const getConversationBySlug = `-- name: GetConversationBySlug :one
-SELECT conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model FROM conversations
+SELECT conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model, thinking_level, patch_diagnostics, sandbox FROM conversations
 WHERE slug = ?
 `
 
@@ -177,12 +189,15 @@ func (q *Queries) GetConversationBySlug(ctx context.Context, slug *string) (Conv
 		&i.Archived,
 		&i.ParentConversationID,
 		&i.Model,
+		&i.ThinkingLevel,
+		&i.PatchDiagnostics,
+		&i.Sandbox,
 	)
 	return i, err
 }
 
 const getConversationBySlugAndParent = `-- name: GetConversationBySlugAndParent :one
-SELECT conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model FROM conversations
+SELECT conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model, thinking_level, patch_diagnostics, sandbox FROM conversations
 WHERE slug = ? AND parent_conversation_id = ?
 `
 
@@ -204,12 +219,15 @@ func (q *Queries) GetConversationBySlugAndParent(ctx context.Context, arg GetCon
 		&i.Archived,
 		&i.ParentConversationID,
 		&i.Model,
+		&i.ThinkingLevel,
+		&i.PatchDiagnostics,
+		&i.Sandbox,
 	)
 	return i, err
 }
 
 const getSubagents = `-- name: GetSubagents :many
-SELECT conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model FROM conversations
+SELECT conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model, thinking_level, patch_diagnostics, sandbox FROM conversations
 WHERE parent_conversation_id = ?
 ORDER BY created_at ASC
 `
@@ -233,6 +251,9 @@ func (q *Queries) GetSubagents(ctx context.Context, parentConversationID *string
 			&i.Archived,
 			&i.ParentConversationID,
 			&i.Model,
+			&i.ThinkingLevel,
+			&i.PatchDiagnostics,
+			&i.Sandbox,
 		); err != nil {
 			return nil, err
 		}
@@ -248,7 +269,7 @@ func (q *Queries) GetSubagents(ctx context.Context, parentConversationID *string
 }
 
 const listArchivedConversations = `-- name: ListArchivedConversations :many
-SELECT conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model FROM conversations
+SELECT conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model, thinking_level, patch_diagnostics, sandbox FROM conversations
 WHERE archived = TRUE
 ORDER BY updated_at DESC
 LIMIT ? OFFSET ?
@@ -278,6 +299,9 @@ func (q *Queries) ListArchivedConversations(ctx context.Context, arg ListArchive
 			&i.Archived,
 			&i.ParentConversationID,
 			&i.Model,
+			&i.ThinkingLevel,
+			&i.PatchDiagnostics,
+			&i.Sandbox,
 		); err != nil {
 			return nil, err
 		}
@@ -293,7 +317,7 @@ func (q *Queries) ListArchivedConversations(ctx context.Context, arg ListArchive
 }
 
 const listConversations = `-- name: ListConversations :many
-SELECT conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model FROM conversations
+SELECT conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model, thinking_level, patch_diagnostics, sandbox FROM conversations
 WHERE archived = FALSE AND parent_conversation_id IS NULL
 ORDER BY updated_at DESC
 LIMIT ? OFFSET ?
@@ -323,6 +347,9 @@ func (q *Queries) ListConversations(ctx context.Context, arg ListConversationsPa
 			&i.Archived,
 			&i.ParentConversationID,
 			&i.Model,
+			&i.ThinkingLevel,
+			&i.PatchDiagnostics,
+			&i.Sandbox,
 		); err != nil {
 			return nil, err
 		}
@@ -338,7 +365,7 @@ func (q *Queries) ListConversations(ctx context.Context, arg ListConversationsPa
 }
 
 const searchArchivedConversations = `-- name: SearchArchivedConversations :many
-SELECT conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model FROM conversations
+SELECT conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model, thinking_level, patch_diagnostics, sandbox FROM conversations
 WHERE slug LIKE '%' || ? || '%' AND archived = TRUE
 ORDER BY updated_at DESC
 LIMIT ? OFFSET ?
@@ -369,6 +396,9 @@ func (q *Queries) SearchArchivedConversations(ctx context.Context, arg SearchArc
 			&i.Archived,
 			&i.ParentConversationID,
 			&i.Model,
+			&i.ThinkingLevel,
+			&i.PatchDiagnostics,
+			&i.Sandbox,
 		); err != nil {
 			return nil, err
 		}
@@ -384,7 +414,7 @@ func (q *Queries) SearchArchivedConversations(ctx context.Context, arg SearchArc
 }
 
 const searchConversations = `-- name: SearchConversations :many
-SELECT conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model FROM conversations
+SELECT conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model, thinking_level, patch_diagnostics, sandbox FROM conversations
 WHERE slug LIKE '%' || ? || '%' AND archived = FALSE AND parent_conversation_id IS NULL
 ORDER BY updated_at DESC
 LIMIT ? OFFSET ?
@@ -415,6 +445,9 @@ func (q *Queries) SearchConversations(ctx context.Context, arg SearchConversatio
 			&i.Archived,
 			&i.ParentConversationID,
 			&i.Model,
+			&i.ThinkingLevel,
+			&i.PatchDiagnostics,
+			&i.Sandbox,
 		); err != nil {
 			return nil, err
 		}
@@ -430,7 +463,7 @@ func (q *Queries) SearchConversations(ctx context.Context, arg SearchConversatio
 }
 
 const searchConversationsWithMessages = `-- name: SearchConversationsWithMessages :many
-SELECT DISTINCT c.conversation_id, c.slug, c.user_initiated, c.created_at, c.updated_at, c.cwd, c.archived, c.parent_conversation_id, c.model FROM conversations c
+SELECT DISTINCT c.conversation_id, c.slug, c.user_initiated, c.created_at, c.updated_at, c.cwd, c.archived, c.parent_conversation_id, c.model, c.thinking_level, c.patch_diagnostics, c.sandbox FROM conversations c
 LEFT JOIN messages m ON c.conversation_id = m.conversation_id AND m.type IN ('user', 'agent')
 WHERE c.archived = FALSE
   AND (
@@ -477,6 +510,9 @@ func (q *Queries) SearchConversationsWithMessages(ctx context.Context, arg Searc
 			&i.Archived,
 			&i.ParentConversationID,
 			&i.Model,
+			&i.ThinkingLevel,
+			&i.PatchDiagnostics,
+			&i.Sandbox,
 		); err != nil {
 			return nil, err
 		}
@@ -495,7 +531,7 @@ const unarchiveConversation = `-- name: UnarchiveConversation :one
 UPDATE conversations
 SET archived = FALSE, updated_at = CURRENT_TIMESTAMP
 WHERE conversation_id = ?
-RETURNING conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model
+RETURNING conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model, thinking_level, patch_diagnostics, sandbox
 `
 
 func (q *Queries) UnarchiveConversation(ctx context.Context, conversationID string) (Conversation, error) {
@@ -511,6 +547,9 @@ func (q *Queries) UnarchiveConversation(ctx context.Context, conversationID stri
 		&i.Archived,
 		&i.ParentConversationID,
 		&i.Model,
+		&i.ThinkingLevel,
+		&i.PatchDiagnostics,
+		&i.Sandbox,
 	)
 	return i, err
 }
@@ -519,7 +558,7 @@ const updateConversationCwd = `-- name: UpdateConversationCwd :one
 UPDATE conversations
 SET cwd = ?, updated_at = CURRENT_TIMESTAMP
 WHERE conversation_id = ?
-RETURNING conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model
+RETURNING conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model, thinking_level, patch_diagnostics, sandbox
 `
 
 type UpdateConversationCwdParams struct {
@@ -540,6 +579,9 @@ func (q *Queries) UpdateConversationCwd(ctx context.Context, arg UpdateConversat
 		&i.Archived,
 		&i.ParentConversationID,
 		&i.Model,
+		&i.ThinkingLevel,
+		&i.PatchDiagnostics,
+		&i.Sandbox,
 	)
 	return i, err
 }
@@ -560,11 +602,59 @@ func (q *Queries) UpdateConversationModel(ctx context.Context, arg UpdateConvers
 	return err
 }
 
+const updateConversationThinkingLevel = `-- name: UpdateConversationThinkingLevel :exec
+UPDATE conversations
+SET thinking_level = ?
+WHERE conversation_id = ?
+`
+
+type UpdateConversationThinkingLevelParams struct {
+	ThinkingLevel  *string `json:"thinking_level"`
+	ConversationID string  `json:"conversation_id"`
+}
+
+func (q *Queries) UpdateConversationThinkingLevel(ctx context.Context, arg UpdateConversationThinkingLevelParams) error {
+	_, err := q.db.ExecContext(ctx, updateConversationThinkingLevel, arg.ThinkingLevel, arg.ConversationID)
+	return err
+}
+
+const updateConversationPatchDiagnostics = `-- name: UpdateConversationPatchDiagnostics :exec
+UPDATE conversations
+SET patch_diagnostics = ?
+WHERE conversation_id = ?
+`
+
+type UpdateConversationPatchDiagnosticsParams struct {
+	PatchDiagnostics bool   `json:"patch_diagnostics"`
+	ConversationID   string `json:"conversation_id"`
+}
+
+func (q *Queries) UpdateConversationPatchDiagnostics(ctx context.Context, arg UpdateConversationPatchDiagnosticsParams) error {
+	_, err := q.db.ExecContext(ctx, updateConversationPatchDiagnostics, arg.PatchDiagnostics, arg.ConversationID)
+	return err
+}
+
+const updateConversationSandbox = `-- name: UpdateConversationSandbox :exec
+UPDATE conversations
+SET sandbox = ?
+WHERE conversation_id = ?
+`
+
+type UpdateConversationSandboxParams struct {
+	Sandbox        bool   `json:"sandbox"`
+	ConversationID string `json:"conversation_id"`
+}
+
+func (q *Queries) UpdateConversationSandbox(ctx context.Context, arg UpdateConversationSandboxParams) error {
+	_, err := q.db.ExecContext(ctx, updateConversationSandbox, arg.Sandbox, arg.ConversationID)
+	return err
+}
+
 const updateConversationSlug = `-- name: UpdateConversationSlug :one
 UPDATE conversations
 SET slug = ?, updated_at = CURRENT_TIMESTAMP
 WHERE conversation_id = ?
-RETURNING conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model
+RETURNING conversation_id, slug, user_initiated, created_at, updated_at, cwd, archived, parent_conversation_id, model, thinking_level, patch_diagnostics, sandbox
 `
 
 type UpdateConversationSlugParams struct {
@@ -585,6 +675,9 @@ func (q *Queries) UpdateConversationSlug(ctx context.Context, arg UpdateConversa
 		&i.Archived,
 		&i.ParentConversationID,
 		&i.Model,
+		&i.ThinkingLevel,
+		&i.PatchDiagnostics,
+		&i.Sandbox,
 	)
 	return i, err
 }
@@ -149,6 +149,49 @@ func (q *Queries) InsertLLMRequest(ctx context.Context, arg InsertLLMRequestPara
 	return i, err
 }
 
+const listLLMRequestsForConversation = `-- name: ListLLMRequestsForConversation :many
+SELECT id, conversation_id, model, provider, url, request_body, response_body, status_code, error, duration_ms, created_at, prefix_request_id, prefix_length FROM llm_requests
+WHERE conversation_id = ?
+ORDER BY id ASC
+`
+
+func (q *Queries) ListLLMRequestsForConversation(ctx context.Context, conversationID *string) ([]LlmRequest, error) {
+	rows, err := q.db.QueryContext(ctx, listLLMRequestsForConversation, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []LlmRequest{}
+	for rows.Next() {
+		var i LlmRequest
+		if err := rows.Scan(
+			&i.ID,
+			&i.ConversationID,
+			&i.Model,
+			&i.Provider,
+			&i.Url,
+			&i.RequestBody,
+			&i.ResponseBody,
+			&i.StatusCode,
+			&i.Error,
+			&i.DurationMs,
+			&i.CreatedAt,
+			&i.PrefixRequestID,
+			&i.PrefixLength,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listRecentLLMRequests = `-- name: ListRecentLLMRequests :many
 SELECT
     r.id,
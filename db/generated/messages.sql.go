@@ -129,6 +129,31 @@ func (q *Queries) GetLatestMessage(ctx context.Context, conversationID string) (
 	return i, err
 }
 
+const getLatestUserMessage = `-- name: GetLatestUserMessage :one
+SELECT message_id, conversation_id, sequence_id, type, llm_data, user_data, usage_data, created_at, display_data, excluded_from_context FROM messages
+WHERE conversation_id = ? AND type = 'user'
+ORDER BY sequence_id DESC
+LIMIT 1
+`
+
+func (q *Queries) GetLatestUserMessage(ctx context.Context, conversationID string) (Message, error) {
+	row := q.db.QueryRowContext(ctx, getLatestUserMessage, conversationID)
+	var i Message
+	err := row.Scan(
+		&i.MessageID,
+		&i.ConversationID,
+		&i.SequenceID,
+		&i.Type,
+		&i.LlmData,
+		&i.UserData,
+		&i.UsageData,
+		&i.CreatedAt,
+		&i.DisplayData,
+		&i.ExcludedFromContext,
+	)
+	return i, err
+}
+
 const getMessage = `-- name: GetMessage :one
 SELECT message_id, conversation_id, sequence_id, type, llm_data, user_data, usage_data, created_at, display_data, excluded_from_context FROM messages
 WHERE message_id = ?
@@ -18,6 +18,22 @@ type Conversation struct {
 	Archived             bool      `json:"archived"`
 	ParentConversationID *string   `json:"parent_conversation_id"`
 	Model                *string   `json:"model"`
+	ThinkingLevel        *string   `json:"thinking_level"`
+	PatchDiagnostics     bool      `json:"patch_diagnostics"`
+	Sandbox              bool      `json:"sandbox"`
+}
+
+type FileCheckpoint struct {
+	CheckpointID   int64     `json:"checkpoint_id"`
+	ConversationID string    `json:"conversation_id"`
+	MessageID      string    `json:"message_id"`
+	SequenceID     int64     `json:"sequence_id"`
+	Path           string    `json:"path"`
+	Existed        bool      `json:"existed"`
+	Mode           int64     `json:"mode"`
+	Content        []byte    `json:"content"`
+	CreatedAt      time.Time `json:"created_at"`
+	Omitted        bool      `json:"omitted"`
 }
 
 type LlmRequest struct {
@@ -83,3 +99,18 @@ type Setting struct {
 	Value     string    `json:"value"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
+
+type Todo struct {
+	ConversationID string    `json:"conversation_id"`
+	TodoID         string    `json:"todo_id"`
+	Position       int64     `json:"position"`
+	Content        string    `json:"content"`
+	Status         string    `json:"status"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+type TodoCounter struct {
+	ConversationID string `json:"conversation_id"`
+	NextTodoNumber int64  `json:"next_todo_number"`
+}
@@ -107,3 +107,18 @@ WHERE slug = ? AND parent_conversation_id = ?;
 UPDATE conversations
 SET model = ?
 WHERE conversation_id = ? AND model IS NULL;
+
+-- name: UpdateConversationThinkingLevel :exec
+UPDATE conversations
+SET thinking_level = ?
+WHERE conversation_id = ?;
+
+-- name: UpdateConversationPatchDiagnostics :exec
+UPDATE conversations
+SET patch_diagnostics = ?
+WHERE conversation_id = ?;
+
+-- name: UpdateConversationSandbox :exec
+UPDATE conversations
+SET sandbox = ?
+WHERE conversation_id = ?;
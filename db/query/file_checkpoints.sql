@@ -0,0 +1,19 @@
+-- name: CreateFileCheckpoint :exec
+-- The first snapshot of a path in a turn wins; later ones are ignored.
+INSERT OR IGNORE INTO file_checkpoints (conversation_id, message_id, sequence_id, path, existed, mode, content, omitted)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?);
+
+-- name: ListFileCheckpoints :many
+SELECT checkpoint_id, conversation_id, message_id, sequence_id, path, existed, mode, created_at, omitted
+FROM file_checkpoints
+WHERE conversation_id = ?
+ORDER BY sequence_id ASC, checkpoint_id ASC;
+
+-- name: ListFileCheckpointsSince :many
+SELECT * FROM file_checkpoints
+WHERE conversation_id = ? AND sequence_id >= ?
+ORDER BY sequence_id ASC, checkpoint_id ASC;
+
+-- name: DeleteFileCheckpointsSince :exec
+DELETE FROM file_checkpoints
+WHERE conversation_id = ? AND sequence_id >= ?;
@@ -23,6 +23,11 @@ LIMIT 1;
 -- name: GetLLMRequestByID :one
 SELECT * FROM llm_requests WHERE id = ?;
 
+-- name: ListLLMRequestsForConversation :many
+SELECT * FROM llm_requests
+WHERE conversation_id = ?
+ORDER BY id ASC;
+
 -- name: ListRecentLLMRequests :many
 SELECT
     r.id,
@@ -62,3 +62,9 @@ ORDER BY sequence_id ASC;
 
 -- name: UpdateMessageUserData :exec
 UPDATE messages SET user_data = ? WHERE message_id = ?;
+
+-- name: GetLatestUserMessage :one
+SELECT * FROM messages
+WHERE conversation_id = ? AND type = 'user'
+ORDER BY sequence_id DESC
+LIMIT 1;
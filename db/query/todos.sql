@@ -0,0 +1,44 @@
+-- name: ListTodos :many
+SELECT * FROM todos
+WHERE conversation_id = ?
+ORDER BY position ASC;
+
+-- name: UpsertTodo :exec
+-- updated_at only changes when the task does.
+INSERT INTO todos (conversation_id, todo_id, position, content, status)
+VALUES (?, ?, ?, ?, ?)
+ON CONFLICT(conversation_id, todo_id) DO UPDATE SET
+    position = excluded.position,
+    content = excluded.content,
+    status = excluded.status,
+    updated_at = CASE
+        WHEN todos.content != excluded.content OR todos.status != excluded.status THEN CURRENT_TIMESTAMP
+        ELSE todos.updated_at
+    END;
+
+-- name: DeleteTodo :exec
+DELETE FROM todos
+WHERE conversation_id = ? AND todo_id = ?;
+
+-- name: CopyTodos :exec
+INSERT INTO todos (conversation_id, todo_id, position, content, status, created_at, updated_at)
+SELECT sqlc.arg(to_conversation_id), todo_id, position, content, status, created_at, updated_at
+FROM todos
+WHERE todos.conversation_id = sqlc.arg(from_conversation_id);
+
+-- name: GetNextTodoNumber :one
+SELECT next_todo_number FROM todo_counters
+WHERE conversation_id = ?;
+
+-- name: SetNextTodoNumber :exec
+-- The counter never goes down.
+INSERT INTO todo_counters (conversation_id, next_todo_number)
+VALUES (?, ?)
+ON CONFLICT(conversation_id) DO UPDATE SET
+    next_todo_number = MAX(todo_counters.next_todo_number, excluded.next_todo_number);
+
+-- name: CopyTodoCounter :exec
+INSERT INTO todo_counters (conversation_id, next_todo_number)
+SELECT sqlc.arg(to_conversation_id), next_todo_number
+FROM todo_counters
+WHERE todo_counters.conversation_id = sqlc.arg(from_conversation_id);
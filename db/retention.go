@@ -0,0 +1,119 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/tgruben-circuit/percy/db/generated"
+)
+
+// LLMRequestRetention bounds the size of the llm_requests table.
+// Zero fields are unlimited.
+type LLMRequestRetention struct {
+	MaxAge   time.Duration // delete requests older than this
+	MaxRows  int64         // keep at most this many requests
+	MaxBytes int64         // keep at most this many bytes of request and response bodies
+}
+
+// IsZero reports whether r places no limits.
+func (r LLMRequestRetention) IsZero() bool {
+	return r.MaxAge <= 0 && r.MaxRows <= 0 && r.MaxBytes <= 0
+}
+
+// sqliteTimeFormat matches SQLite's CURRENT_TIMESTAMP.
+const sqliteTimeFormat = "2006-01-02 15:04:05"
+
+// SetLLMRequestDeltas controls whether InsertLLMRequest stores request
+// bodies as deltas against the conversation's previous request (the
+// default) or in full. Call it before recording requests.
+func (db *DB) SetLLMRequestDeltas(enabled bool) {
+	db.fullLLMRequestBodies = !enabled
+}
+
+// PruneLLMRequests deletes the oldest LLM requests until r is satisfied and
+// returns the number deleted. Surviving requests whose bodies are stored as
+// deltas against a deleted request are rewritten with their full bodies.
+func (db *DB) PruneLLMRequests(ctx context.Context, r LLMRequestRetention) (int64, error) {
+	if r.IsZero() {
+		return 0, nil
+	}
+
+	var deleted int64
+	err := db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		// IDs increase with time, so every limit reduces to "delete id <= cutoff".
+		var cutoff int64
+		raise := func(query string, args ...any) error {
+			var id int64
+			if err := tx.QueryRow(query, args...).Scan(&id); err != nil {
+				return err
+			}
+			cutoff = max(cutoff, id)
+			return nil
+		}
+		if r.MaxAge > 0 {
+			before := tx.Now.Add(-r.MaxAge).UTC().Format(sqliteTimeFormat)
+			if err := raise(`SELECT COALESCE(MAX(id), 0) FROM llm_requests WHERE created_at < ?`, before); err != nil {
+				return fmt.Errorf("age cutoff: %w", err)
+			}
+		}
+		if r.MaxRows > 0 {
+			if err := raise(`SELECT COALESCE((SELECT id FROM llm_requests ORDER BY id DESC LIMIT 1 OFFSET ?), 0)`, r.MaxRows); err != nil {
+				return fmt.Errorf("row cutoff: %w", err)
+			}
+		}
+		if r.MaxBytes > 0 {
+			if err := raise(`SELECT COALESCE((
+				SELECT id FROM (
+					SELECT id, SUM(COALESCE(LENGTH(CAST(request_body AS BLOB)), 0) + COALESCE(LENGTH(CAST(response_body AS BLOB)), 0))
+						OVER (ORDER BY id DESC) AS total
+					FROM llm_requests
+				) WHERE total > ? ORDER BY id DESC LIMIT 1), 0)`, r.MaxBytes); err != nil {
+				return fmt.Errorf("byte cutoff: %w", err)
+			}
+		}
+		if cutoff == 0 {
+			return nil
+		}
+
+		// Materialize surviving requests that depend on a pruned prefix.
+		rows, err := tx.Query(`SELECT id FROM llm_requests WHERE id > ? AND prefix_request_id <= ?`, cutoff, cutoff)
+		if err != nil {
+			return err
+		}
+		var dependents []int64
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return err
+			}
+			dependents = append(dependents, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		q := generated.New(tx.Conn())
+		for _, id := range dependents {
+			var body string
+			if err := reconstructRequestBody(ctx, q, id, &body); err != nil {
+				return fmt.Errorf("reconstruct request %d: %w", id, err)
+			}
+			if _, err := tx.Exec(`UPDATE llm_requests SET request_body = ?, prefix_request_id = NULL, prefix_length = NULL WHERE id = ?`, body, id); err != nil {
+				return err
+			}
+		}
+
+		res, err := tx.Exec(`DELETE FROM llm_requests WHERE id <= ?`, cutoff)
+		if err != nil {
+			return err
+		}
+		deleted, err = res.RowsAffected()
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("prune llm_requests: %w", err)
+	}
+	return deleted, nil
+}
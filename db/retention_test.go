@@ -0,0 +1,113 @@
+package db
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tgruben-circuit/percy/db/generated"
+)
+
+func insertTestLLMRequests(t *testing.T, db *DB, convID string, bodies ...string) []int64 {
+	t.Helper()
+	var ids []int64
+	for _, body := range bodies {
+		resp := `{"ok":true}`
+		req, err := db.InsertLLMRequest(context.Background(), generated.InsertLLMRequestParams{
+			ConversationID: &convID,
+			Model:          "test-model",
+			Provider:       "test-provider",
+			Url:            "http://example.com",
+			RequestBody:    &body,
+			ResponseBody:   &resp,
+		})
+		if err != nil {
+			t.Fatalf("Failed to insert request: %v", err)
+		}
+		ids = append(ids, req.ID)
+	}
+	return ids
+}
+
+func TestPruneLLMRequestsMaterializesDeltas(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	conv, err := db.CreateConversation(ctx, nil, true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prefix := strings.Repeat("A", 200)
+	bodies := []string{prefix + "1", prefix + "12", prefix + "123"}
+	ids := insertTestLLMRequests(t, db, conv.ConversationID, bodies...)
+
+	n, err := db.PruneLLMRequests(ctx, LLMRequestRetention{MaxRows: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("Expected 1 deleted, got %d", n)
+	}
+
+	// The second request referenced the deleted one and must still reconstruct.
+	for i, id := range ids[1:] {
+		body, err := db.GetFullLLMRequestBody(ctx, id)
+		if err != nil {
+			t.Fatalf("Failed to reconstruct request %d: %v", id, err)
+		}
+		if body != bodies[i+1] {
+			t.Errorf("Request %d body mismatch: expected %q, got %q", id, bodies[i+1], body)
+		}
+	}
+}
+
+func TestPruneLLMRequestsByAgeAndBytes(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	db.SetLLMRequestDeltas(false)
+	conv, err := db.CreateConversation(ctx, nil, true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := insertTestLLMRequests(t, db, conv.ConversationID, strings.Repeat("x", 500), strings.Repeat("y", 500), strings.Repeat("z", 500))
+
+	// Full bodies are stored when deltas are off.
+	listed, err := db.ListConversationLLMRequests(ctx, conv.ConversationID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range listed {
+		if r.PrefixRequestID != nil {
+			t.Errorf("Request %d stored as delta with deltas disabled", r.ID)
+		}
+	}
+
+	// Age the first request.
+	old := time.Now().Add(-48 * time.Hour).UTC().Format(sqliteTimeFormat)
+	if err := db.pool.Exec(ctx, `UPDATE llm_requests SET created_at = ? WHERE id = ?`, old, ids[0]); err != nil {
+		t.Fatal(err)
+	}
+	n, err := db.PruneLLMRequests(ctx, LLMRequestRetention{MaxAge: 24 * time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("Age prune: expected 1 deleted, got %d", n)
+	}
+
+	// Each remaining row is 511 bytes; a 600 byte budget keeps only the newest.
+	n, err = db.PruneLLMRequests(ctx, LLMRequestRetention{MaxBytes: 600})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("Byte prune: expected 1 deleted, got %d", n)
+	}
+	if _, err := db.GetFullLLMRequestBody(ctx, ids[2]); err != nil {
+		t.Errorf("Newest request should survive: %v", err)
+	}
+}
@@ -0,0 +1,5 @@
+-- Add thinking_level column to conversations table
+-- This stores the extended-thinking level chosen for the conversation
+-- (off, minimal, low, medium or high); NULL uses the model's default
+
+ALTER TABLE conversations ADD COLUMN thinking_level TEXT;
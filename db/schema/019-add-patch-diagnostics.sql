@@ -0,0 +1,5 @@
+-- Add patch_diagnostics column to conversations table
+-- When true, the patch tool reports language-server errors introduced by
+-- each edit
+
+ALTER TABLE conversations ADD COLUMN patch_diagnostics BOOLEAN NOT NULL DEFAULT TRUE;
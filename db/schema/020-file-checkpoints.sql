@@ -0,0 +1,21 @@
+-- File checkpoints table
+-- Stores the contents of files as they were before the agent modified them,
+-- keyed by the user message that started the turn, so a conversation's edits
+-- can be undone independently of git
+
+CREATE TABLE file_checkpoints (
+    checkpoint_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    conversation_id TEXT NOT NULL,
+    message_id TEXT NOT NULL, -- the user message that started the turn
+    sequence_id INTEGER NOT NULL, -- sequence_id of that message
+    path TEXT NOT NULL, -- absolute path of the file
+    existed BOOLEAN NOT NULL, -- FALSE if the file did not exist yet
+    mode INTEGER NOT NULL DEFAULT 0, -- permission bits of the file
+    content BLOB, -- NULL if the file did not exist
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (conversation_id) REFERENCES conversations(conversation_id) ON DELETE CASCADE,
+    -- Only the first snapshot of a file in a turn describes its state before the turn
+    UNIQUE (conversation_id, message_id, path)
+);
+
+CREATE INDEX idx_file_checkpoints_conversation_sequence ON file_checkpoints(conversation_id, sequence_id);
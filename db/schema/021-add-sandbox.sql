@@ -0,0 +1,5 @@
+-- Add sandbox column to conversations table
+-- When true, bash commands run in a sandbox with only the working tree
+-- writable and network access restricted
+
+ALTER TABLE conversations ADD COLUMN sandbox BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,15 @@
+-- Todos table
+-- Stores each conversation's task list, maintained by the todo_write tool.
+-- Tasks keep their IDs across updates so the agent can refer to them.
+
+CREATE TABLE todos (
+    conversation_id TEXT NOT NULL,
+    todo_id TEXT NOT NULL, -- stable ID, such as t1
+    position INTEGER NOT NULL, -- order in the list, from 0
+    content TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'in_progress', 'done')),
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (conversation_id, todo_id),
+    FOREIGN KEY (conversation_id) REFERENCES conversations(conversation_id) ON DELETE CASCADE
+);
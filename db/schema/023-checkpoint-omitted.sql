@@ -0,0 +1,5 @@
+-- Add omitted column to file_checkpoints table
+-- When true, the file existed but was too large to save, so the checkpoint
+-- records only that it cannot be restored
+
+ALTER TABLE file_checkpoints ADD COLUMN omitted BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,9 @@
+-- Todo counters table
+-- Stores the number of the next new task in each conversation's task list,
+-- so that the IDs of removed tasks are never given to new ones.
+
+CREATE TABLE todo_counters (
+    conversation_id TEXT PRIMARY KEY,
+    next_todo_number INTEGER NOT NULL, -- the next new task is t<next_todo_number>
+    FOREIGN KEY (conversation_id) REFERENCES conversations(conversation_id) ON DELETE CASCADE
+);
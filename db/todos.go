@@ -0,0 +1,107 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/tgruben-circuit/percy/db/generated"
+)
+
+// TodoItem is an item of a conversation's task list.
+type TodoItem struct {
+	ID      string
+	Content string
+	Status  string
+}
+
+// ListTodos returns the conversation's task list in order.
+func (db *DB) ListTodos(ctx context.Context, conversationID string) ([]generated.Todo, error) {
+	var todos []generated.Todo
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		todos, err = q.ListTodos(ctx, conversationID)
+		return err
+	})
+	return todos, err
+}
+
+// NextTodoNumber returns the number of the conversation's next new task,
+// or 1 if none has been recorded.
+func (db *DB) NextTodoNumber(ctx context.Context, conversationID string) (int64, error) {
+	var next int64
+	err := db.pool.Rx(ctx, func(ctx context.Context, rx *Rx) error {
+		q := generated.New(rx.Conn())
+		var err error
+		next, err = q.GetNextTodoNumber(ctx, conversationID)
+		if errors.Is(err, sql.ErrNoRows) {
+			next, err = 1, nil
+		}
+		return err
+	})
+	return next, err
+}
+
+// ReplaceTodos replaces the conversation's task list with items, in order,
+// and raises the number of its next new task to nextNumber; the number
+// never goes down. Items keep their creation time when an item with the
+// same ID existed.
+func (db *DB) ReplaceTodos(ctx context.Context, conversationID string, items []TodoItem, nextNumber int64) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		err := q.SetNextTodoNumber(ctx, generated.SetNextTodoNumberParams{ConversationID: conversationID, NextTodoNumber: nextNumber})
+		if err != nil {
+			return err
+		}
+		existing, err := q.ListTodos(ctx, conversationID)
+		if err != nil {
+			return err
+		}
+		keep := make(map[string]bool, len(items))
+		for i, item := range items {
+			keep[item.ID] = true
+			err := q.UpsertTodo(ctx, generated.UpsertTodoParams{
+				ConversationID: conversationID,
+				TodoID:         item.ID,
+				Position:       int64(i),
+				Content:        item.Content,
+				Status:         item.Status,
+			})
+			if err != nil {
+				return fmt.Errorf("todo %s: %w", item.ID, err)
+			}
+		}
+		for _, t := range existing {
+			if keep[t.TodoID] {
+				continue
+			}
+			err := q.DeleteTodo(ctx, generated.DeleteTodoParams{ConversationID: conversationID, TodoID: t.TodoID})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// CopyTodos copies the task list of one conversation, and the number of its
+// next new task, to another that has none, as when a conversation is
+// continued in a new one.
+func (db *DB) CopyTodos(ctx context.Context, fromConversationID, toConversationID string) error {
+	return db.pool.Tx(ctx, func(ctx context.Context, tx *Tx) error {
+		q := generated.New(tx.Conn())
+		err := q.CopyTodos(ctx, generated.CopyTodosParams{
+			ToConversationID:   toConversationID,
+			FromConversationID: fromConversationID,
+		})
+		if err != nil {
+			return err
+		}
+		return q.CopyTodoCounter(ctx, generated.CopyTodoCounterParams{
+			ToConversationID:   toConversationID,
+			FromConversationID: fromConversationID,
+		})
+	})
+}
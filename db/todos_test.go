@@ -0,0 +1,103 @@
+package db
+
+import (
+	"context"
+	"testing"
+)
+
+func todoIDs(t *testing.T, db *DB, conversationID string) []string {
+	t.Helper()
+	todos, err := db.ListTodos(context.Background(), conversationID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, todo := range todos {
+		ids = append(ids, todo.TodoID+":"+todo.Status)
+	}
+	return ids
+}
+
+func TestTodos(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+	ctx := context.Background()
+
+	conv, err := db.CreateConversation(ctx, nil, true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := conv.ConversationID
+
+	err = db.ReplaceTodos(ctx, id, []TodoItem{
+		{ID: "t1", Content: "first", Status: "done"},
+		{ID: "t2", Content: "second", Status: "in_progress"},
+		{ID: "t3", Content: "third", Status: "pending"},
+	}, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before, _ := db.ListTodos(ctx, id)
+
+	// Reorder, drop t1 and add t4.
+	err = db.ReplaceTodos(ctx, id, []TodoItem{
+		{ID: "t3", Content: "third", Status: "in_progress"},
+		{ID: "t4", Content: "fourth", Status: "pending"},
+		{ID: "t2", Content: "second", Status: "done"},
+	}, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := todoIDs(t, db, id)
+	want := []string{"t3:in_progress", "t4:pending", "t2:done"}
+	if len(got) != len(want) {
+		t.Fatalf("todos = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("todos = %v, want %v", got, want)
+		}
+	}
+	after, _ := db.ListTodos(ctx, id)
+	if !after[2].CreatedAt.Equal(before[1].CreatedAt) {
+		t.Errorf("t2 created_at changed from %v to %v", before[1].CreatedAt, after[2].CreatedAt)
+	}
+
+	if err := db.ReplaceTodos(ctx, id, []TodoItem{{ID: "t5", Content: "bad", Status: "blocked"}}, 6); err == nil {
+		t.Error("invalid status accepted")
+	}
+	if got := todoIDs(t, db, id); len(got) != 3 {
+		t.Errorf("failed replace changed the list: %v", got)
+	}
+	if n, err := db.NextTodoNumber(ctx, id); err != nil || n != 5 {
+		t.Errorf("NextTodoNumber = %d, %v after a failed replace, want 5", n, err)
+	}
+	// The number never goes down.
+	if err := db.ReplaceTodos(ctx, id, []TodoItem{
+		{ID: "t3", Content: "third", Status: "in_progress"},
+		{ID: "t4", Content: "fourth", Status: "pending"},
+		{ID: "t2", Content: "second", Status: "done"},
+	}, 2); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := db.NextTodoNumber(ctx, id); err != nil || n != 5 {
+		t.Errorf("NextTodoNumber = %d, %v, want it to stay at 5", n, err)
+	}
+
+	next, err := db.CreateConversation(ctx, nil, true, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.CopyTodos(ctx, id, next.ConversationID); err != nil {
+		t.Fatal(err)
+	}
+	if got := todoIDs(t, db, next.ConversationID); len(got) != 3 || got[0] != "t3:in_progress" {
+		t.Errorf("copied todos = %v", got)
+	}
+	if n, err := db.NextTodoNumber(ctx, next.ConversationID); err != nil || n != 5 {
+		t.Errorf("copied NextTodoNumber = %d, %v, want 5", n, err)
+	}
+	if n, err := db.NextTodoNumber(ctx, "nope"); err != nil || n != 1 {
+		t.Errorf("NextTodoNumber of a conversation without tasks = %d, %v, want 1", n, err)
+	}
+}
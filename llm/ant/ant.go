@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"cmp"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -111,7 +112,8 @@ type content struct {
 	// is somewhat acceptable but hard to read.
 	Text      *string         `json:"text,omitempty"`
 	MediaType string          `json:"media_type,omitempty"` // for image
-	Source    json.RawMessage `json:"source,omitempty"`     // for image
+	Source    json.RawMessage `json:"source,omitempty"`     // for image or document
+	Title     string          `json:"title,omitempty"`      // for document
 
 	// for thinking
 	Thinking  string `json:"thinking,omitempty"`
@@ -277,6 +279,7 @@ var (
 		llm.ContentTypeRedactedThinking: "redacted_thinking",
 		llm.ContentTypeToolUse:          "tool_use",
 		llm.ContentTypeToolResult:       "tool_result",
+		llm.ContentTypeDocument:         "document",
 	}
 	toLLMContentType = inverted(fromLLMContentType)
 
@@ -352,11 +355,43 @@ func fromLLMContent(c llm.Content) content {
 		d.ToolUseID = c.ToolUseID
 		d.ToolError = c.ToolError
 		d.ToolResult = toolResult
+	case llm.ContentTypeDocument:
+		if d.Source = fromLLMDocument(c); d.Source != nil {
+			d.Title = c.Title
+		} else {
+			d.Type = "text"
+			text := llm.DocumentText(c)
+			d.Text = &text
+		}
 	}
 
 	return d
 }
 
+// fromLLMDocument returns the source of a document block: PDFs are sent
+// as base64 data and text documents as plain text. It returns nil for
+// other media types, which are sent as extracted text instead.
+func fromLLMDocument(c llm.Content) json.RawMessage {
+	var source map[string]string
+	switch {
+	case c.MediaType == "application/pdf":
+		source = map[string]string{"type": "base64", "media_type": c.MediaType, "data": c.Data}
+	case strings.HasPrefix(c.MediaType, "text/"):
+		data, err := base64.StdEncoding.DecodeString(c.Data)
+		if err != nil {
+			return nil
+		}
+		source = map[string]string{"type": "text", "media_type": "text/plain", "data": string(data)}
+	default:
+		return nil
+	}
+	b, err := json.Marshal(source)
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
 func fromLLMToolUse(tu *llm.ToolUse) *toolUse {
 	if tu == nil {
 		return nil
@@ -415,9 +450,11 @@ func (s *Service) fromLLMRequest(r *llm.Request) *request {
 		System:     mapped(r.System, fromLLMSystem),
 	}
 
-	// Enable extended thinking if a thinking level is set
-	if s.ThinkingLevel != llm.ThinkingLevelOff {
-		budget := s.ThinkingLevel.ThinkingBudgetTokens()
+	// Enable extended thinking if a thinking level is set.
+	// The API rejects thinking combined with forced tool use.
+	forced := r.ToolChoice != nil && (r.ToolChoice.Type == llm.ToolChoiceTypeAny || r.ToolChoice.Type == llm.ToolChoiceTypeTool)
+	if level := r.ThinkingLevelOr(s.ThinkingLevel); level != llm.ThinkingLevelOff && !forced {
+		budget := level.ThinkingBudgetTokens()
 		// Ensure max_tokens > budget_tokens as required by Anthropic API
 		if maxTokens <= budget {
 			req.MaxTokens = budget + 1024
@@ -451,6 +488,7 @@ func toLLMContent(c content) llm.Content {
 		ID:         c.ID,
 		Type:       toLLMContentType[c.Type],
 		MediaType:  c.MediaType,
+		Title:      c.Title,
 		Thinking:   c.Thinking,
 		Data:       c.Data,
 		Signature:  c.Signature,
@@ -479,6 +517,14 @@ func toLLMResponse(r *response) *llm.Response {
 	}
 }
 
+// setHeaders sets the headers every Anthropic API request carries.
+func (s *Service) setHeaders(h http.Header) {
+	h.Set("Content-Type", "application/json")
+	h.Set("X-API-Key", s.APIKey)
+	h.Set("Anthropic-Version", "2023-06-01")
+	h.Set("anthropic-beta", "output-128k-2025-02-19")
+}
+
 // Do sends a request to Anthropic.
 func (s *Service) Do(ctx context.Context, ir *llm.Request) (*llm.Response, error) {
 	startTime := time.Now()
@@ -497,7 +543,7 @@ func (s *Service) Do(ctx context.Context, ir *llm.Request) (*llm.Response, error
 	// retry loop
 	var errs error // accumulated errors across all attempts
 	for attempts := 0; ; attempts++ {
-		if attempts > 10 {
+		if attempts >= llm.MaxAttempts(ctx, 11) {
 			return nil, fmt.Errorf("anthropic request failed after %d attempts: %w", attempts, errs)
 		}
 		if attempts > 0 {
@@ -510,10 +556,7 @@ func (s *Service) Do(ctx context.Context, ir *llm.Request) (*llm.Response, error
 			return nil, errors.Join(errs, err)
 		}
 
-		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("X-API-Key", s.APIKey)
-		req.Header.Set("Anthropic-Version", "2023-06-01")
-		req.Header.Set("anthropic-beta", "output-128k-2025-02-19")
+		s.setHeaders(req.Header)
 
 		resp, err := httpc.Do(req)
 		if err != nil {
@@ -549,21 +592,21 @@ func (s *Service) Do(ctx context.Context, ir *llm.Request) (*llm.Response, error
 		case resp.StatusCode >= 500 && resp.StatusCode < 600:
 			// server error, retry
 			slog.WarnContext(ctx, "anthropic_request_failed", "response", string(buf), "status_code", resp.StatusCode, "url", url, "model", s.Model)
-			errs = errors.Join(errs, fmt.Errorf("status %v (url=%s, model=%s): %s", resp.Status, url, cmp.Or(s.Model, DefaultModel), buf))
+			errs = errors.Join(errs, llm.StatusErrorf(resp.StatusCode, "status %v (url=%s, model=%s): %s", resp.Status, url, cmp.Or(s.Model, DefaultModel), buf))
 			continue
 		case resp.StatusCode == 429:
 			// rate limited, retry
 			slog.WarnContext(ctx, "anthropic_request_rate_limited", "response", string(buf), "url", url, "model", s.Model)
-			errs = errors.Join(errs, fmt.Errorf("status %v (url=%s, model=%s): %s", resp.Status, url, cmp.Or(s.Model, DefaultModel), buf))
+			errs = errors.Join(errs, llm.StatusErrorf(resp.StatusCode, "status %v (url=%s, model=%s): %s", resp.Status, url, cmp.Or(s.Model, DefaultModel), buf))
 			continue
 		case resp.StatusCode >= 400 && resp.StatusCode < 500:
 			// some other 400, probably unrecoverable
 			slog.WarnContext(ctx, "anthropic_request_failed", "response", string(buf), "status_code", resp.StatusCode, "url", url, "model", s.Model)
-			return nil, errors.Join(errs, fmt.Errorf("status %v (url=%s, model=%s): %s", resp.Status, url, cmp.Or(s.Model, DefaultModel), buf))
+			return nil, errors.Join(errs, llm.StatusErrorf(resp.StatusCode, "status %v (url=%s, model=%s): %s", resp.Status, url, cmp.Or(s.Model, DefaultModel), buf))
 		default:
 			// ...retry, I guess?
 			slog.WarnContext(ctx, "anthropic_request_failed", "response", string(buf), "status_code", resp.StatusCode, "url", url, "model", s.Model)
-			errs = errors.Join(errs, fmt.Errorf("status %v (url=%s, model=%s): %s", resp.Status, url, cmp.Or(s.Model, DefaultModel), buf))
+			errs = errors.Join(errs, llm.StatusErrorf(resp.StatusCode, "status %v (url=%s, model=%s): %s", resp.Status, url, cmp.Or(s.Model, DefaultModel), buf))
 			continue
 		}
 	}
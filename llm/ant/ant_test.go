@@ -597,6 +597,18 @@ func TestFromLLMRequest(t *testing.T) {
 	}
 }
 
+func TestFromLLMRequestForcedToolDisablesThinking(t *testing.T) {
+	s := &Service{ThinkingLevel: llm.ThinkingLevelMedium}
+	req := &llm.Request{Messages: []llm.Message{llm.UserStringMessage("hi")}}
+	if got := s.fromLLMRequest(req); got.Thinking == nil {
+		t.Error("fromLLMRequest().Thinking = nil, want thinking enabled")
+	}
+	req.ToolChoice = &llm.ToolChoice{Type: llm.ToolChoiceTypeTool, Name: "respond"}
+	if got := s.fromLLMRequest(req); got.Thinking != nil {
+		t.Errorf("fromLLMRequest().Thinking = %+v, want nil with a forced tool", got.Thinking)
+	}
+}
+
 func TestConfigDetails(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -878,6 +890,51 @@ func TestFromLLMContent(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "pdf document",
+			c:    llm.Content{Type: llm.ContentTypeDocument, Title: "spec.pdf", MediaType: "application/pdf", Data: "JVBERi0="},
+			want: content{
+				Type:   "document",
+				Title:  "spec.pdf",
+				Source: json.RawMessage(`{"data":"JVBERi0=","media_type":"application/pdf","type":"base64"}`),
+			},
+		},
+		{
+			name: "text document",
+			c:    llm.DocumentContent("notes.md", "text/markdown", []byte("notes")),
+			want: content{
+				Type:   "document",
+				Title:  "notes.md",
+				Source: json.RawMessage(`{"data":"notes","media_type":"text/plain","type":"text"}`),
+			},
+		},
+		{
+			name: "unsupported document falls back to text",
+			c:    llm.DocumentContent("a.zip", "application/zip", []byte("PK")),
+			want: content{
+				Type: "text",
+				Text: &[]string{"Document a.zip (application/zip): text could not be extracted: cannot extract text from application/zip"}[0],
+			},
+		},
+		{
+			name: "tool result with nested document",
+			c: llm.Content{
+				Type:      llm.ContentTypeToolResult,
+				ToolUseID: "tool-use-id",
+				ToolResult: []llm.Content{
+					{Type: llm.ContentTypeText, Text: "File: spec.pdf"},
+					{Type: llm.ContentTypeDocument, Title: "spec.pdf", MediaType: "application/pdf", Data: "JVBERi0="},
+				},
+			},
+			want: content{
+				Type:      "tool_result",
+				ToolUseID: "tool-use-id",
+				ToolResult: []content{
+					{Type: "text", Text: &[]string{"File: spec.pdf"}[0]},
+					{Type: "document", Title: "spec.pdf", Source: json.RawMessage(`{"data":"JVBERi0=","media_type":"application/pdf","type":"base64"}`)},
+				},
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -909,6 +966,10 @@ func TestFromLLMContent(t *testing.T) {
 				t.Errorf("fromLLMContent().ToolName = %v, want %v", got.ToolName, tt.want.ToolName)
 			}
 
+			if got.Title != tt.want.Title {
+				t.Errorf("fromLLMContent().Title = %v, want %v", got.Title, tt.want.Title)
+			}
+
 			if string(got.ToolInput) != string(tt.want.ToolInput) {
 				t.Errorf("fromLLMContent().ToolInput = %v, want %v", string(got.ToolInput), string(tt.want.ToolInput))
 			}
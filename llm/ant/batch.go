@@ -0,0 +1,176 @@
+package ant
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/tgruben-circuit/percy/llm"
+)
+
+// messageBatch is a Message Batches API batch.
+type messageBatch struct {
+	ID               string `json:"id"`
+	ProcessingStatus string `json:"processing_status"` // in_progress, canceling, ended
+	ResultsURL       string `json:"results_url"`
+	RequestCounts    struct {
+		Processing int `json:"processing"`
+		Succeeded  int `json:"succeeded"`
+		Errored    int `json:"errored"`
+		Canceled   int `json:"canceled"`
+		Expired    int `json:"expired"`
+	} `json:"request_counts"`
+}
+
+// total returns the number of requests in the batch.
+func (b *messageBatch) total() int {
+	c := b.RequestCounts
+	return c.Processing + c.Succeeded + c.Errored + c.Canceled + c.Expired
+}
+
+type batchItem struct {
+	CustomID string   `json:"custom_id"`
+	Params   *request `json:"params"`
+}
+
+// batchResultLine is one line of a batch's JSONL results.
+type batchResultLine struct {
+	CustomID string `json:"custom_id"`
+	Result   struct {
+		Type    string   `json:"type"` // succeeded, errored, canceled, expired
+		Message response `json:"message"`
+		Error   struct {
+			Error struct {
+				Type    string `json:"type"`
+				Message string `json:"message"`
+			} `json:"error"`
+		} `json:"error"`
+	} `json:"result"`
+}
+
+var _ llm.Batcher = (*Service)(nil)
+
+func (s *Service) batchURL() string {
+	return cmp.Or(s.URL, DefaultURL) + "/batches"
+}
+
+// SubmitBatch implements llm.Batcher using the Message Batches API.
+func (s *Service) SubmitBatch(ctx context.Context, reqs []*llm.Request) (string, error) {
+	items := make([]batchItem, len(reqs))
+	for i, r := range reqs {
+		items[i] = batchItem{CustomID: strconv.Itoa(i), Params: s.fromLLMRequest(r)}
+	}
+	payload, err := json.Marshal(map[string]any{"requests": items})
+	if err != nil {
+		return "", err
+	}
+	var batch messageBatch
+	if err := s.batchRequest(ctx, "POST", s.batchURL(), payload, &batch); err != nil {
+		return "", err
+	}
+	return batch.ID, nil
+}
+
+// PollBatch implements llm.Batcher.
+func (s *Service) PollBatch(ctx context.Context, batchID string) (bool, error) {
+	var batch messageBatch
+	if err := s.batchRequest(ctx, "GET", s.batchURL()+"/"+batchID, nil, &batch); err != nil {
+		return false, err
+	}
+	return batch.ProcessingStatus == "ended", nil
+}
+
+// CollectBatch implements llm.Batcher.
+func (s *Service) CollectBatch(ctx context.Context, batchID string) ([]llm.BatchResult, error) {
+	var batch messageBatch
+	if err := s.batchRequest(ctx, "GET", s.batchURL()+"/"+batchID, nil, &batch); err != nil {
+		return nil, err
+	}
+	if batch.ResultsURL == "" {
+		return nil, fmt.Errorf("batch %s has no results (status %s)", batchID, batch.ProcessingStatus)
+	}
+	// results_url names the API host directly; fetch through s.URL so a
+	// gateway, if any, is used as it is for every other request.
+	var buf bytes.Buffer
+	if err := s.batchRequest(ctx, "GET", s.batchURL()+"/"+batchID+"/results", nil, &buf); err != nil {
+		return nil, err
+	}
+
+	results := map[string]llm.BatchResult{}
+	n := batch.total()
+	end := time.Now()
+	scanner := bufio.NewScanner(&buf)
+	scanner.Buffer(nil, 64<<20)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var line batchResultLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			return nil, fmt.Errorf("batch %s: bad result line: %w", batchID, err)
+		}
+		switch line.Result.Type {
+		case "succeeded":
+			resp := toLLMResponse(&line.Result.Message)
+			resp.EndTime = &end
+			results[line.CustomID] = llm.BatchResult{Response: resp}
+		case "errored":
+			e := line.Result.Error.Error
+			results[line.CustomID] = llm.BatchResult{Err: fmt.Errorf("batch request failed: %s: %s", e.Type, e.Message)}
+		default:
+			results[line.CustomID] = llm.BatchResult{Err: fmt.Errorf("batch request %s", line.Result.Type)}
+		}
+		if i, err := strconv.Atoi(line.CustomID); err == nil {
+			n = max(n, i+1)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	out := make([]llm.BatchResult, n)
+	for i := range out {
+		r, ok := results[strconv.Itoa(i)]
+		if !ok {
+			r = llm.BatchResult{Err: fmt.Errorf("batch request missing from results (batch %s)", batchID)}
+		}
+		out[i] = r
+	}
+	return out, nil
+}
+
+// batchRequest makes a Message Batches API call, decoding the JSON response
+// into v, or copying it verbatim if v is an io.Writer.
+func (s *Service) batchRequest(ctx context.Context, method, url string, payload []byte, v any) error {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return err
+	}
+	s.setHeaders(req.Header)
+
+	resp, err := cmp.Or(s.HTTPC, http.DefaultClient).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		buf, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("status %v (url=%s): %s", resp.Status, url, buf)
+	}
+	if w, ok := v.(io.Writer); ok {
+		_, err = io.Copy(w, resp.Body)
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,123 @@
+package ant
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tgruben-circuit/percy/llm"
+)
+
+// fakeBatchServer implements enough of the Message Batches API for tests.
+// Each submitted request is answered with its first message's text, except
+// for requests saying "fail", which error, and "lost", which have no result.
+func fakeBatchServer(t *testing.T) *httptest.Server {
+	var submitted []batchItem
+	polls := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/messages/batches", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Requests []batchItem `json:"requests"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		submitted = body.Requests
+		_ = json.NewEncoder(w).Encode(messageBatch{ID: "msgbatch_1", ProcessingStatus: "in_progress"})
+	})
+	mux.HandleFunc("GET /v1/messages/batches/msgbatch_1", func(w http.ResponseWriter, r *http.Request) {
+		polls++
+		batch := messageBatch{ID: "msgbatch_1", ProcessingStatus: "in_progress"}
+		if polls > 1 {
+			batch.ProcessingStatus = "ended"
+			batch.RequestCounts.Succeeded = len(submitted)
+			// The real API names its own host, not the gateway's
+			batch.ResultsURL = "https://api.anthropic.invalid/v1/messages/batches/msgbatch_1/results"
+		}
+		_ = json.NewEncoder(w).Encode(batch)
+	})
+	mux.HandleFunc("GET /v1/messages/batches/msgbatch_1/results", func(w http.ResponseWriter, r *http.Request) {
+		// Results may come back in any order
+		for i := len(submitted) - 1; i >= 0; i-- {
+			item := submitted[i]
+			text := *item.Params.Messages[0].Content[0].Text
+			if text == "lost" {
+				continue
+			}
+			if text == "fail" {
+				fmt.Fprintf(w, `{"custom_id":%q,"result":{"type":"errored","error":{"type":"error","error":{"type":"invalid_request_error","message":"bad"}}}}`+"\n", item.CustomID)
+				continue
+			}
+			line, _ := json.Marshal(map[string]any{
+				"custom_id": item.CustomID,
+				"result": map[string]any{
+					"type": "succeeded",
+					"message": response{
+						ID: "msg_" + item.CustomID, Type: "message", Role: "assistant", Model: item.Params.Model,
+						Content:    []content{{Type: "text", Text: ptr("echo: " + text)}},
+						StopReason: "end_turn",
+						Usage:      usage{InputTokens: 10, OutputTokens: 2},
+					},
+				},
+			})
+			fmt.Fprintf(w, "%s\n", line)
+		}
+	})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-API-Key") != "key" || r.Header.Get("Anthropic-Version") == "" || r.Header.Get("anthropic-beta") == "" {
+			t.Errorf("%s %s: missing headers %v", r.Method, r.URL.Path, r.Header)
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestBatch(t *testing.T) {
+	srv := fakeBatchServer(t)
+	svc := &Service{APIKey: "key", URL: srv.URL + "/v1/messages"}
+	ctx := context.Background()
+
+	id, err := svc.SubmitBatch(ctx, []*llm.Request{
+		{Messages: []llm.Message{llm.UserStringMessage("one")}},
+		{Messages: []llm.Message{llm.UserStringMessage("fail")}},
+		{Messages: []llm.Message{llm.UserStringMessage("three")}},
+		{Messages: []llm.Message{llm.UserStringMessage("lost")}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if done, err := svc.PollBatch(ctx, id); err != nil || done {
+		t.Fatalf("first poll = %v, %v; want not done", done, err)
+	}
+	if done, err := svc.PollBatch(ctx, id); err != nil || !done {
+		t.Fatalf("second poll = %v, %v; want done", done, err)
+	}
+
+	results, err := svc.CollectBatch(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 4 {
+		t.Fatalf("got %d results", len(results))
+	}
+	if r := results[0]; r.Err != nil || r.Response.Content[0].Text != "echo: one" || r.Response.Usage.InputTokens != 10 {
+		t.Errorf("result 0 = %+v", r)
+	}
+	if r := results[1]; r.Err == nil || !strings.Contains(r.Err.Error(), "invalid_request_error") {
+		t.Errorf("result 1 = %+v", r)
+	}
+	if r := results[2]; r.Err != nil || r.Response.Content[0].Text != "echo: three" {
+		t.Errorf("result 2 = %+v", r)
+	}
+	if r := results[3]; r.Err == nil || !strings.Contains(r.Err.Error(), "missing") {
+		t.Errorf("result 3 = %+v", r)
+	}
+}
+
+func ptr[T any](v T) *T { return &v }
@@ -0,0 +1,193 @@
+package llm
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Batcher is implemented by services that can run requests through their
+// provider's asynchronous batch API, which costs less than interactive
+// requests but may take minutes or hours to complete.
+type Batcher interface {
+	// SubmitBatch starts processing reqs and returns the batch ID.
+	SubmitBatch(ctx context.Context, reqs []*Request) (string, error)
+	// PollBatch reports whether the batch has finished processing.
+	PollBatch(ctx context.Context, batchID string) (done bool, err error)
+	// CollectBatch returns the results of a finished batch in request order.
+	CollectBatch(ctx context.Context, batchID string) ([]BatchResult, error)
+}
+
+// BatchResult is the outcome of one request in a batch.
+type BatchResult struct {
+	Response *Response
+	Err      error
+}
+
+const (
+	DefaultBatchDelay        = 2 * time.Second
+	DefaultBatchPollInterval = 30 * time.Second
+	DefaultMaxBatchSize      = 1000
+
+	// maxPollErrors is how many consecutive poll failures abandon a batch.
+	maxPollErrors = 10
+)
+
+var errBatchAbandoned = errors.New("batch abandoned")
+
+// BatchingService is a Service whose requests run through a Batcher.
+// Calls to Do that arrive within Delay of the first are submitted together,
+// and each blocks until its batch's results are collected. If the batch
+// can't be submitted, for example because the endpoint has no batch API,
+// the requests are sent to Service one at a time instead.
+type BatchingService struct {
+	Service              // answers TokenContextWindow, MaxImageDimension and unbatchable requests
+	Batcher      Batcher // runs the requests
+	Delay        time.Duration
+	PollInterval time.Duration
+	MaxBatchSize int
+
+	mu      sync.Mutex
+	pending []*batchCall
+	timer   *time.Timer
+}
+
+type batchCall struct {
+	ctx    context.Context
+	req    *Request
+	result chan BatchResult
+}
+
+// Do queues req for the next batch and waits for its result.
+func (b *BatchingService) Do(ctx context.Context, req *Request) (*Response, error) {
+	call := &batchCall{ctx: ctx, req: req, result: make(chan BatchResult, 1)}
+
+	b.mu.Lock()
+	b.pending = append(b.pending, call)
+	switch {
+	case len(b.pending) >= cmp.Or(b.MaxBatchSize, DefaultMaxBatchSize):
+		b.flushLocked()
+	case b.timer == nil:
+		b.timer = time.AfterFunc(cmp.Or(b.Delay, DefaultBatchDelay), b.flush)
+	}
+	b.mu.Unlock()
+
+	select {
+	case r := <-call.result:
+		return r.Response, r.Err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (b *BatchingService) flush() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.flushLocked()
+}
+
+func (b *BatchingService) flushLocked() {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	if len(b.pending) == 0 {
+		return
+	}
+	calls := b.pending
+	b.pending = nil
+	go b.run(calls)
+}
+
+// run submits calls as one batch and delivers each its result.
+func (b *BatchingService) run(calls []*batchCall) {
+	// The batch outlives any one caller; keep the first caller's values
+	// (model, priority) for the HTTP transport, and give up once every
+	// caller has.
+	ctx, cancel := context.WithCancelCause(context.WithoutCancel(calls[0].ctx))
+	defer cancel(nil)
+	var waiting atomic.Int32
+	waiting.Store(int32(len(calls)))
+	for _, call := range calls {
+		stop := context.AfterFunc(call.ctx, func() {
+			if waiting.Add(-1) == 0 {
+				cancel(errBatchAbandoned)
+			}
+		})
+		defer stop()
+	}
+
+	results, err := b.process(ctx, calls)
+	for i, call := range calls {
+		r := BatchResult{Err: err}
+		if err == nil {
+			r = results[i]
+		}
+		call.result <- r
+	}
+}
+
+func (b *BatchingService) process(ctx context.Context, calls []*batchCall) ([]BatchResult, error) {
+	reqs := make([]*Request, len(calls))
+	for i, call := range calls {
+		reqs[i] = call.req
+	}
+	id, err := b.Batcher.SubmitBatch(ctx, reqs)
+	if err != nil {
+		slog.WarnContext(ctx, "LLM batch submit failed; sending requests individually", "requests", len(reqs), "error", err)
+		return b.doEach(calls), nil
+	}
+	slog.InfoContext(ctx, "LLM batch submitted", "batch_id", id, "requests", len(reqs))
+
+	timer := time.NewTimer(cmp.Or(b.PollInterval, DefaultBatchPollInterval))
+	defer timer.Stop()
+	var pollErrs error
+	for failures := 0; ; {
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			// Nobody is waiting; let the provider finish on its own
+			return nil, context.Cause(ctx)
+		}
+		timer.Reset(cmp.Or(b.PollInterval, DefaultBatchPollInterval))
+		done, err := b.Batcher.PollBatch(ctx, id)
+		if err != nil {
+			failures++
+			pollErrs = errors.Join(pollErrs, err)
+			if failures >= maxPollErrors {
+				return nil, fmt.Errorf("poll batch %s: %w", id, pollErrs)
+			}
+			continue
+		}
+		failures, pollErrs = 0, nil
+		if done {
+			break
+		}
+	}
+
+	results, err := b.Batcher.CollectBatch(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("collect batch %s: %w", id, err)
+	}
+	if len(results) != len(calls) {
+		return nil, fmt.Errorf("batch %s returned %d results for %d requests", id, len(results), len(calls))
+	}
+	slog.InfoContext(ctx, "LLM batch collected", "batch_id", id, "requests", len(reqs))
+	return results, nil
+}
+
+// doEach sends each call's request through Service with the caller's
+// context.
+func (b *BatchingService) doEach(calls []*batchCall) []BatchResult {
+	results := make([]BatchResult, len(calls))
+	for i, call := range calls {
+		resp, err := b.Service.Do(call.ctx, call.req)
+		results[i] = BatchResult{Response: resp, Err: err}
+	}
+	return results
+}
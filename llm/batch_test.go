@@ -0,0 +1,126 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeBatcher answers each request with its first message's text.
+type fakeBatcher struct {
+	mu      sync.Mutex
+	batches [][]*Request
+	polls   int
+	fail    bool
+}
+
+func (f *fakeBatcher) SubmitBatch(ctx context.Context, reqs []*Request) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.fail {
+		return "", errors.New("quota exceeded")
+	}
+	f.batches = append(f.batches, reqs)
+	return fmt.Sprint(len(f.batches) - 1), nil
+}
+
+func (f *fakeBatcher) PollBatch(ctx context.Context, id string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.polls++
+	return f.polls%2 == 0, nil
+}
+
+func (f *fakeBatcher) CollectBatch(ctx context.Context, id string) ([]BatchResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var n int
+	fmt.Sscan(id, &n)
+	var results []BatchResult
+	for _, r := range f.batches[n] {
+		text := r.Messages[0].Content[0].Text
+		results = append(results, BatchResult{Response: &Response{Content: []Content{StringContent(text)}}})
+	}
+	return results, nil
+}
+
+func TestBatchingServiceCoalesces(t *testing.T) {
+	fb := &fakeBatcher{}
+	svc := &BatchingService{Service: &scriptedService{}, Batcher: fb, Delay: 50 * time.Millisecond, PollInterval: time.Millisecond}
+
+	var wg sync.WaitGroup
+	for i := range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			want := fmt.Sprint("req ", i)
+			resp, err := svc.Do(context.Background(), &Request{Messages: []Message{UserStringMessage(want)}})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if got := resp.Content[0].Text; got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(fb.batches) != 1 || len(fb.batches[0]) != 5 {
+		t.Errorf("got %d batches, want one batch of 5", len(fb.batches))
+	}
+}
+
+func TestBatchingServiceMaxBatchSize(t *testing.T) {
+	fb := &fakeBatcher{}
+	svc := &BatchingService{Service: &scriptedService{}, Batcher: fb, Delay: time.Hour, PollInterval: time.Millisecond, MaxBatchSize: 2}
+
+	var wg sync.WaitGroup
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := svc.Do(context.Background(), &Request{Messages: []Message{UserStringMessage("hi")}}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait() // would hang until Delay if the full batch didn't flush
+}
+
+func TestBatchingServiceSubmitError(t *testing.T) {
+	// An endpoint without a batch API still answers requests one at a time.
+	direct := &scriptedService{responses: []*Response{{Content: []Content{StringContent("direct")}}}}
+	svc := &BatchingService{Service: direct, Batcher: &fakeBatcher{fail: true}, Delay: time.Millisecond}
+	resp, err := svc.Do(context.Background(), &Request{Messages: []Message{UserStringMessage("hi")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resp.Content[0].Text; got != "direct" || len(direct.requests) != 1 {
+		t.Errorf("got %q after %d direct requests, want the direct response", got, len(direct.requests))
+	}
+}
+
+func TestBatchingServiceAbandon(t *testing.T) {
+	svc := &BatchingService{Service: &scriptedService{}, Batcher: &fakeBatcher{}, PollInterval: time.Hour}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	call := &batchCall{ctx: ctx, req: &Request{Messages: []Message{UserStringMessage("hi")}}, result: make(chan BatchResult, 1)}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		svc.run([]*batchCall{call})
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("poll loop did not stop after every caller gave up")
+	}
+	if r := <-call.result; !errors.Is(r.Err, errBatchAbandoned) {
+		t.Errorf("err = %v, want %v", r.Err, errBatchAbandoned)
+	}
+}
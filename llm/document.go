@@ -0,0 +1,65 @@
+package llm
+
+import (
+	"encoding/base64"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/tgruben-circuit/percy/llm/docutil"
+)
+
+// maxDocumentText caps the extracted text of a document, so that one large
+// document sent as text cannot fill the context window.
+const maxDocumentText = 100_000
+
+// DocumentContent returns content carrying a document, such as a PDF, for
+// the model to read. Services that cannot take documents as input send its
+// text instead; see DocumentText.
+func DocumentContent(title, mediaType string, data []byte) Content {
+	c := Content{
+		Type:      ContentTypeDocument,
+		Title:     title,
+		MediaType: mediaType,
+		Data:      base64.StdEncoding.EncodeToString(data),
+	}
+	// Extract once here rather than on every request that resends it
+	c.Text = documentText(c, data)
+	return c
+}
+
+// DocumentText returns the extracted text of document content c, headed by
+// its title, for services that cannot read documents directly.
+func DocumentText(c Content) string {
+	if c.Text != "" {
+		return c.Text
+	}
+	data, err := base64.StdEncoding.DecodeString(c.Data)
+	if err != nil {
+		return fmt.Sprintf("%s: invalid document data: %v", documentHeader(c), err)
+	}
+	return documentText(c, data)
+}
+
+func documentText(c Content, data []byte) string {
+	header := documentHeader(c)
+	text, err := docutil.ExtractText(data, c.MediaType)
+	if err != nil {
+		return fmt.Sprintf("%s: text could not be extracted: %v", header, err)
+	}
+	if len(text) > maxDocumentText {
+		cut := maxDocumentText
+		for cut > 0 && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+		text = fmt.Sprintf("%s\n[document text truncated: %d of %d bytes shown]", text[:cut], cut, len(text))
+	}
+	return header + ":\n" + text
+}
+
+func documentHeader(c Content) string {
+	header := "Document"
+	if c.Title != "" {
+		header += " " + c.Title
+	}
+	return header + " (" + c.MediaType + ")"
+}
@@ -0,0 +1,454 @@
+// Package docutil extracts text from documents for models that cannot read
+// them directly.
+package docutil
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+)
+
+// maxStreamSize bounds how much a single decompressed stream may grow to.
+const maxStreamSize = 64 << 20
+
+// IsPDF reports whether data starts with the PDF file signature.
+func IsPDF(data []byte) bool {
+	return bytes.HasPrefix(data, []byte("%PDF-"))
+}
+
+// ExtractText returns the text of a document of the given media type.
+// Plain text documents are returned as is.
+func ExtractText(data []byte, mediaType string) (string, error) {
+	switch {
+	case mediaType == "application/pdf":
+		return ExtractPDFText(data)
+	case strings.HasPrefix(mediaType, "text/"):
+		return string(data), nil
+	}
+	return "", fmt.Errorf("cannot extract text from %s", mediaType)
+}
+
+var streamRe = regexp.MustCompile(`stream\r?\n`)
+
+// ExtractPDFText extracts the text shown by a PDF's content streams.
+//
+// It understands uncompressed and Flate-compressed streams and text in
+// single-byte or UTF-16 encodings. Text drawn with fonts that need a
+// ToUnicode map to decode (common for embedded subset fonts) is dropped,
+// so the result may be incomplete; it is meant as a fallback only.
+func ExtractPDFText(data []byte) (string, error) {
+	if !IsPDF(data) {
+		return "", errors.New("not a PDF file")
+	}
+	var out strings.Builder
+	for _, loc := range streamRe.FindAllIndex(data, -1) {
+		start := loc[1]
+		end := bytes.Index(data[start:], []byte("endstream"))
+		if end < 0 {
+			break
+		}
+		dict := streamDict(data[:loc[0]])
+		if skipStream(dict) {
+			continue
+		}
+		stream := data[start : start+end]
+		if bytes.Contains(dict, []byte("/FlateDecode")) {
+			decoded, err := inflate(stream)
+			if err != nil {
+				continue
+			}
+			stream = decoded
+		} else if bytes.Contains(dict, []byte("/Filter")) {
+			// Other filters (DCT, LZW, ...) do not hold text we can read.
+			continue
+		}
+		showText(stream, &out)
+	}
+	text := strings.TrimSpace(out.String())
+	if text == "" {
+		return "", errors.New("no extractable text found in PDF")
+	}
+	return text, nil
+}
+
+// skipStream reports whether a stream with the given dictionary is an
+// image, font program or cross-reference table rather than page content.
+func skipStream(dict []byte) bool {
+	for _, key := range []string{"/Image", "/XRef", "/Length1", "/Type1C", "/CIDFontType0C", "/OpenType"} {
+		if bytes.Contains(dict, []byte(key)) {
+			return true
+		}
+	}
+	return false
+}
+
+// streamDict returns the dictionary that precedes a stream keyword.
+func streamDict(before []byte) []byte {
+	i := bytes.LastIndex(before, []byte("<<"))
+	if i < 0 {
+		return nil
+	}
+	// Nested dictionaries, such as /DecodeParms, start after the outer one.
+	if j := bytes.LastIndex(before[:i], []byte("obj")); j >= 0 {
+		if k := bytes.Index(before[j:], []byte("<<")); k >= 0 {
+			i = j + k
+		}
+	}
+	return before[i:]
+}
+
+func inflate(b []byte) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	out, err := io.ReadAll(io.LimitReader(r, maxStreamSize))
+	// Streams often carry trailing bytes after the compressed data.
+	if len(out) > 0 && (err == nil || errors.Is(err, io.ErrUnexpectedEOF)) {
+		return out, nil
+	}
+	return out, err
+}
+
+// showText interprets the text operators of a content stream and writes the
+// strings they show to out.
+func showText(stream []byte, out *strings.Builder) {
+	var operands []string // decoded strings since the last operator
+	var numbers []float64 // numeric operands since the last operator
+	inText := false
+	lex := &lexer{b: stream}
+	for {
+		tok, kind := lex.next()
+		switch kind {
+		case tokEOF:
+			return
+		case tokString:
+			operands = append(operands, tok)
+			continue
+		case tokNumber:
+			n, _ := strconv.ParseFloat(tok, 64)
+			numbers = append(numbers, n)
+			continue
+		case tokArray:
+			// TJ arrays: strings with kerning adjustments between them.
+			operands = append(operands, tok)
+			continue
+		}
+		switch tok {
+		case "BT":
+			inText = true
+		case "ET":
+			inText = false
+			endLine(out)
+		case "Tj", "TJ":
+			if inText {
+				for _, s := range operands {
+					out.WriteString(s)
+				}
+			}
+		case "'", `"`:
+			endLine(out)
+			if inText && len(operands) > 0 {
+				out.WriteString(operands[len(operands)-1])
+			}
+		case "T*":
+			endLine(out)
+		case "Td", "TD":
+			if len(numbers) == 2 && numbers[1] != 0 {
+				endLine(out)
+			} else if len(numbers) == 2 && numbers[0] != 0 {
+				space(out)
+			}
+		case "Tm":
+			endLine(out)
+		}
+		operands = operands[:0]
+		numbers = numbers[:0]
+	}
+}
+
+func endLine(out *strings.Builder) {
+	s := out.String()
+	if s != "" && !strings.HasSuffix(s, "\n") {
+		out.WriteByte('\n')
+	}
+}
+
+func space(out *strings.Builder) {
+	s := out.String()
+	if s != "" && !strings.HasSuffix(s, " ") && !strings.HasSuffix(s, "\n") {
+		out.WriteByte(' ')
+	}
+}
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokOperator
+	tokNumber
+	tokString
+	tokArray
+)
+
+// lexer tokenizes a PDF content stream. Dictionaries and names are skipped.
+type lexer struct {
+	b []byte
+	i int
+}
+
+func (l *lexer) next() (string, tokKind) {
+	for l.i < len(l.b) {
+		c := l.b[l.i]
+		switch {
+		case isSpace(c):
+			l.i++
+		case c == '%':
+			for l.i < len(l.b) && l.b[l.i] != '\n' && l.b[l.i] != '\r' {
+				l.i++
+			}
+		case c == '(':
+			return decodeString(l.literal()), tokString
+		case c == '<' && l.i+1 < len(l.b) && l.b[l.i+1] == '<':
+			l.skipDict()
+		case c == '<':
+			return decodeString(l.hex()), tokString
+		case c == '[':
+			return l.array(), tokArray
+		case c == '/':
+			l.i++
+			l.word()
+		case c == ']' || c == '>' || c == '{' || c == '}' || c == ')':
+			l.i++
+		case c == '+' || c == '-' || c == '.' || (c >= '0' && c <= '9'):
+			return l.word(), tokNumber
+		default:
+			w := l.word()
+			if w == "BI" {
+				l.skipInlineImage()
+				continue
+			}
+			return w, tokOperator
+		}
+	}
+	return "", tokEOF
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+func isDelim(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+func (l *lexer) word() string {
+	start := l.i
+	for l.i < len(l.b) && !isSpace(l.b[l.i]) && !isDelim(l.b[l.i]) {
+		l.i++
+	}
+	if l.i == start {
+		l.i++ // never stall on an unexpected byte
+	}
+	return string(l.b[start:l.i])
+}
+
+// literal reads a (string), handling nesting and escapes.
+func (l *lexer) literal() []byte {
+	l.i++ // (
+	var s []byte
+	depth := 1
+	for l.i < len(l.b) {
+		c := l.b[l.i]
+		l.i++
+		switch c {
+		case '\\':
+			if l.i >= len(l.b) {
+				return s
+			}
+			e := l.b[l.i]
+			l.i++
+			switch e {
+			case 'n':
+				s = append(s, '\n')
+			case 'r':
+				s = append(s, '\r')
+			case 't':
+				s = append(s, '\t')
+			case 'b':
+				s = append(s, '\b')
+			case 'f':
+				s = append(s, '\f')
+			case '\r':
+				if l.i < len(l.b) && l.b[l.i] == '\n' {
+					l.i++
+				}
+			case '\n':
+			default:
+				if e >= '0' && e <= '7' {
+					n := int(e - '0')
+					for k := 0; k < 2 && l.i < len(l.b) && l.b[l.i] >= '0' && l.b[l.i] <= '7'; k++ {
+						n = n*8 + int(l.b[l.i]-'0')
+						l.i++
+					}
+					s = append(s, byte(n))
+				} else {
+					s = append(s, e)
+				}
+			}
+		case '(':
+			depth++
+			s = append(s, c)
+		case ')':
+			depth--
+			if depth == 0 {
+				return s
+			}
+			s = append(s, c)
+		default:
+			s = append(s, c)
+		}
+	}
+	return s
+}
+
+// hex reads a <hex string>.
+func (l *lexer) hex() []byte {
+	l.i++ // <
+	var digits []byte
+	for l.i < len(l.b) && l.b[l.i] != '>' {
+		if c := l.b[l.i]; !isSpace(c) {
+			digits = append(digits, c)
+		}
+		l.i++
+	}
+	l.i++ // >
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	s := make([]byte, 0, len(digits)/2)
+	for k := 0; k < len(digits); k += 2 {
+		n, err := strconv.ParseUint(string(digits[k:k+2]), 16, 8)
+		if err != nil {
+			return nil
+		}
+		s = append(s, byte(n))
+	}
+	return s
+}
+
+// array reads a [...] TJ operand, joining its strings and turning wide
+// kerning gaps into spaces.
+func (l *lexer) array() string {
+	l.i++ // [
+	var sb strings.Builder
+	for l.i < len(l.b) {
+		c := l.b[l.i]
+		switch {
+		case c == ']':
+			l.i++
+			return sb.String()
+		case isSpace(c):
+			l.i++
+		case c == '(':
+			sb.WriteString(decodeString(l.literal()))
+		case c == '<':
+			sb.WriteString(decodeString(l.hex()))
+		case c == '+' || c == '-' || c == '.' || (c >= '0' && c <= '9'):
+			if n, err := strconv.ParseFloat(l.word(), 64); err == nil && n < -200 {
+				sb.WriteByte(' ')
+			}
+		default:
+			l.word()
+		}
+	}
+	return sb.String()
+}
+
+func (l *lexer) skipDict() {
+	depth := 0
+	for l.i+1 < len(l.b) {
+		switch {
+		case l.b[l.i] == '<' && l.b[l.i+1] == '<':
+			depth++
+			l.i += 2
+		case l.b[l.i] == '>' && l.b[l.i+1] == '>':
+			depth--
+			l.i += 2
+			if depth == 0 {
+				return
+			}
+		case l.b[l.i] == '(':
+			l.literal()
+		default:
+			l.i++
+		}
+	}
+	l.i = len(l.b)
+}
+
+func (l *lexer) skipInlineImage() {
+	end := bytes.Index(l.b[l.i:], []byte("EI"))
+	if end < 0 {
+		l.i = len(l.b)
+		return
+	}
+	l.i += end + 2
+}
+
+// decodeString converts the bytes of a PDF string to text. UTF-16BE strings
+// (with a byte order mark, or two-byte codes that look like Unicode) are
+// decoded as such; other strings are read as Latin-1, which matches the
+// standard encodings for ASCII text. Undecodable strings yield "".
+func decodeString(b []byte) string {
+	if len(b) >= 2 && b[0] == 0xfe && b[1] == 0xff {
+		return printable(utf16String(b[2:]))
+	}
+	if len(b) >= 2 && len(b)%2 == 0 && bytes.IndexByte(b, 0) >= 0 {
+		return printable(utf16String(b))
+	}
+	r := make([]rune, len(b))
+	for i, c := range b {
+		r[i] = rune(c)
+	}
+	return printable(string(r))
+}
+
+func utf16String(b []byte) string {
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		u[i] = uint16(b[2*i])<<8 | uint16(b[2*i+1])
+	}
+	return string(utf16.Decode(u))
+}
+
+// printable returns s if it is mostly printable text, and "" otherwise.
+func printable(s string) string {
+	var bad, total int
+	for _, r := range s {
+		total++
+		if r == unicode.ReplacementChar || (!unicode.IsPrint(r) && !unicode.IsSpace(r)) {
+			bad++
+		}
+	}
+	if total == 0 || bad*4 > total {
+		return ""
+	}
+	return strings.Map(func(r rune) rune {
+		if unicode.IsPrint(r) || r == '\n' || r == '\t' {
+			return r
+		}
+		if unicode.IsSpace(r) {
+			return ' '
+		}
+		return -1
+	}, s)
+}
@@ -0,0 +1,65 @@
+package docutil
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// makePDF builds a minimal PDF whose single page draws content.
+func makePDF(content []byte, compress bool) []byte {
+	stream := content
+	filter := ""
+	if compress {
+		var buf bytes.Buffer
+		w := zlib.NewWriter(&buf)
+		w.Write(content)
+		w.Close()
+		stream = buf.Bytes()
+		filter = " /Filter /FlateDecode"
+	}
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n")
+	b.WriteString("1 0 obj << /Type /Catalog /Pages 2 0 R >> endobj\n")
+	b.WriteString("2 0 obj << /Type /Pages /Kids [3 0 R] /Count 1 >> endobj\n")
+	b.WriteString("3 0 obj << /Type /Page /Parent 2 0 R /Contents 4 0 R >> endobj\n")
+	fmt.Fprintf(&b, "4 0 obj << /Length %d%s >>\nstream\n", len(stream), filter)
+	b.Write(stream)
+	b.WriteString("\nendstream\nendobj\n%%EOF\n")
+	return b.Bytes()
+}
+
+func TestExtractPDFText(t *testing.T) {
+	content := []byte(`BT /F1 12 Tf 72 720 Td (Hello, \(PDF\) world) Tj 0 -14 Td [(Second) -300 (line)] TJ T* <0048006900210021> Tj ET`)
+	want := "Hello, (PDF) world\nSecond line\nHi!!"
+	for _, compress := range []bool{false, true} {
+		got, err := ExtractPDFText(makePDF(content, compress))
+		if err != nil {
+			t.Fatalf("compress=%v: %v", compress, err)
+		}
+		if got != want {
+			t.Errorf("compress=%v: got %q, want %q", compress, got, want)
+		}
+	}
+}
+
+func TestExtractPDFTextErrors(t *testing.T) {
+	if _, err := ExtractPDFText([]byte("hello")); err == nil {
+		t.Error("expected error for non-PDF data")
+	}
+	if _, err := ExtractPDFText(makePDF([]byte("0 0 m 10 10 l S"), true)); err == nil {
+		t.Error("expected error for PDF without text")
+	}
+}
+
+func TestExtractText(t *testing.T) {
+	got, err := ExtractText([]byte("plain"), "text/plain")
+	if err != nil || got != "plain" {
+		t.Errorf("ExtractText(text/plain) = %q, %v", got, err)
+	}
+	if _, err := ExtractText([]byte{0, 1}, "application/zip"); err == nil || !strings.Contains(err.Error(), "application/zip") {
+		t.Errorf("ExtractText(application/zip) error = %v", err)
+	}
+}
@@ -4,9 +4,11 @@ import (
 	"cmp"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"math/rand"
+	"net"
 	"net/http"
 	"strings"
 	"time"
@@ -27,6 +29,9 @@ type Service struct {
 	URL    string       // Gemini API URL, uses the gemini package default if empty
 	APIKey string       // must be non-empty
 	Model  string       // defaults to DefaultModel if empty
+	// ThinkingLevel sets the thinking config; off leaves the model's
+	// default, since Gemini 3 models can't turn thinking off.
+	ThinkingLevel llm.ThinkingLevel
 }
 
 var _ llm.Service = (*Service)(nil)
@@ -144,6 +149,30 @@ func convertJSONSchemaToGeminiSchema(schemaJSON map[string]any) gemini.Schema {
 	return schema
 }
 
+// thinkingConfig maps level to model's thinking controls: a level for
+// Gemini 3, a token budget like Anthropic's for earlier models.
+func thinkingConfig(model string, level llm.ThinkingLevel) *gemini.ThinkingConfig {
+	if !strings.HasPrefix(model, "gemini-3") {
+		return &gemini.ThinkingConfig{ThinkingBudget: level.ThinkingBudgetTokens()}
+	}
+	if level >= llm.ThinkingLevelMedium {
+		return &gemini.ThinkingConfig{ThinkingLevel: "high"}
+	}
+	return &gemini.ThinkingConfig{ThinkingLevel: "low"}
+}
+
+// fromLLMContentPart converts text, image and document content to a part.
+// Documents Gemini cannot read are sent as their extracted text.
+func fromLLMContentPart(c llm.Content) gemini.Part {
+	switch {
+	case c.Type == llm.ContentTypeDocument && c.MediaType != "application/pdf" && !strings.HasPrefix(c.MediaType, "text/"):
+		return gemini.Part{Text: llm.DocumentText(c)}
+	case c.MediaType != "":
+		return gemini.Part{InlineData: &gemini.Blob{MimeType: c.MediaType, Data: c.Data}}
+	}
+	return gemini.Part{Text: c.Text}
+}
+
 // buildGeminiRequest converts Sketch's llm.Request to Gemini's request format
 func (s *Service) buildGeminiRequest(req *llm.Request) (*gemini.Request, error) {
 	gemReq := &gemini.Request{}
@@ -191,11 +220,8 @@ func (s *Service) buildGeminiRequest(req *llm.Request) (*gemini.Request, error)
 		// Map each content item to Gemini's format
 		for _, c := range msg.Content {
 			switch c.Type {
-			case llm.ContentTypeText, llm.ContentTypeThinking, llm.ContentTypeRedactedThinking:
-				// Simple text content
-				content.Parts = append(content.Parts, gemini.Part{
-					Text: c.Text,
-				})
+			case llm.ContentTypeText, llm.ContentTypeThinking, llm.ContentTypeRedactedThinking, llm.ContentTypeDocument:
+				content.Parts = append(content.Parts, fromLLMContentPart(c))
 			case llm.ContentTypeToolUse:
 				// Tool use becomes a function call
 				var args map[string]any
@@ -283,6 +309,13 @@ func (s *Service) buildGeminiRequest(req *llm.Request) (*gemini.Request, error)
 						Response: response,
 					},
 				})
+				// Function responses only carry text, so images and
+				// documents follow as parts of their own.
+				for _, result := range c.ToolResult {
+					if result.MediaType != "" {
+						content.Parts = append(content.Parts, fromLLMContentPart(result))
+					}
+				}
 			}
 		}
 
@@ -301,6 +334,10 @@ func (s *Service) buildGeminiRequest(req *llm.Request) (*gemini.Request, error)
 		}
 	}
 
+	if level := req.ThinkingLevelOr(s.ThinkingLevel); level != llm.ThinkingLevelOff {
+		gemReq.GenerationConfig = &gemini.GenerationConfig{ThinkingConfig: thinkingConfig(cmp.Or(s.Model, DefaultModel), level)}
+	}
+
 	return gemReq, nil
 }
 
@@ -557,7 +594,23 @@ func (s *Service) Do(ctx context.Context, ir *llm.Request) (*llm.Response, error
 
 	// Retry mechanism for handling server errors and rate limiting
 	backoff := []time.Duration{1 * time.Second, 3 * time.Second, 5 * time.Second, 10 * time.Second}
-	for attempts := 0; attempts <= len(backoff); attempts++ {
+	var errs error // accumulated errors across all attempts
+	for attempts := 0; ; attempts++ {
+		if attempts >= llm.MaxAttempts(ctx, len(backoff)+1) {
+			// We've exhausted all retry attempts
+			return nil, fmt.Errorf("gemini: API error after %d attempts: %w", attempts, errs)
+		}
+		if attempts > 0 {
+			random := time.Duration(rand.Int63n(int64(time.Second)))
+			sleep := backoff[min(attempts-1, len(backoff)-1)] + random
+			slog.WarnContext(ctx, "gemini_request_retry", "attempt", attempts+1, "sleep", sleep)
+			select {
+			case <-time.After(sleep):
+			case <-ctx.Done():
+				return nil, errors.Join(errs, ctx.Err())
+			}
+		}
+
 		gemAPIErr := error(nil)
 		gemRes, gemAPIErr = model.GenerateContent(ctx, gemReq)
 		endTime = time.Now()
@@ -571,23 +624,26 @@ func (s *Service) Do(ctx context.Context, ir *llm.Request) (*llm.Response, error
 			break
 		}
 
-		if attempts == len(backoff) {
-			// We've exhausted all retry attempts
-			return nil, fmt.Errorf("gemini: API error after %d attempts: %w", attempts, gemAPIErr)
+		var apiErr *gemini.APIError
+		if !errors.As(gemAPIErr, &apiErr) {
+			// Network failures are worth retrying; anything else is not
+			var netErr net.Error
+			if errors.As(gemAPIErr, &netErr) && ctx.Err() == nil {
+				errs = errors.Join(errs, gemAPIErr)
+				continue
+			}
+			return nil, errors.Join(errs, fmt.Errorf("gemini: API error: %w", gemAPIErr))
 		}
-
-		// Check if the error is retryable (e.g., server error or rate limiting)
-		if strings.Contains(gemAPIErr.Error(), "429") || strings.Contains(gemAPIErr.Error(), "5") {
+		statusErr := llm.StatusErrorf(apiErr.StatusCode, "gemini: API error: %s", apiErr.Error())
+		if apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode >= 500 {
 			// Rate limited or server error - wait and retry
-			random := time.Duration(rand.Int63n(int64(time.Second)))
-			sleep := backoff[attempts] + random
-			slog.WarnContext(ctx, "gemini_request_retry", "error", gemAPIErr.Error(), "attempt", attempts+1, "sleep", sleep)
-			time.Sleep(sleep)
+			slog.WarnContext(ctx, "gemini_request_failed", "error", apiErr.Error(), "status_code", apiErr.StatusCode)
+			errs = errors.Join(errs, statusErr)
 			continue
 		}
 
 		// Non-retryable error
-		return nil, fmt.Errorf("gemini: API error: %w", gemAPIErr)
+		return nil, errors.Join(errs, statusErr)
 	}
 
 	content := convertGeminiResponseToContent(gemRes)
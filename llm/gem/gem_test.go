@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"io"
 	"net/http"
+	"strings"
 	"testing"
 
 	"github.com/tgruben-circuit/percy/llm"
@@ -781,3 +782,138 @@ func TestCalculateUsageWithComplexFunctionCall(t *testing.T) {
 		t.Errorf("Expected output tokens with complex function call to be greater than 0, got %d", usage.OutputTokens)
 	}
 }
+
+func TestBuildGeminiRequestThinking(t *testing.T) {
+	req := &llm.Request{Messages: []llm.Message{llm.UserStringMessage("hi")}}
+
+	s := &Service{Model: "gemini-2.5-pro"}
+	gemReq, err := s.buildGeminiRequest(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gemReq.GenerationConfig != nil {
+		t.Errorf("expected no generation config without a thinking level, got %+v", gemReq.GenerationConfig)
+	}
+
+	high := llm.ThinkingLevelHigh
+	req.ThinkingLevel = &high
+	gemReq, err = s.buildGeminiRequest(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := gemReq.GenerationConfig.ThinkingConfig.ThinkingBudget; got != high.ThinkingBudgetTokens() {
+		t.Errorf("thinking budget = %d, want %d", got, high.ThinkingBudgetTokens())
+	}
+
+	s.Model = "gemini-3-pro-preview"
+	gemReq, err = s.buildGeminiRequest(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := gemReq.GenerationConfig.ThinkingConfig.ThinkingLevel; got != "high" {
+		t.Errorf("thinking level = %q, want high", got)
+	}
+}
+
+func TestBuildGeminiRequestInlineData(t *testing.T) {
+	s := &Service{Model: DefaultModel}
+	req := &llm.Request{
+		Messages: []llm.Message{
+			{
+				Role: llm.MessageRoleUser,
+				Content: []llm.Content{
+					{Type: llm.ContentTypeText, MediaType: "image/png", Data: "aW1n"},
+					{Type: llm.ContentTypeDocument, Title: "spec.pdf", MediaType: "application/pdf", Data: "JVBERi0="},
+					llm.DocumentContent("a.zip", "application/zip", []byte("PK")),
+				},
+			},
+			{
+				Role: llm.MessageRoleAssistant,
+				Content: []llm.Content{
+					{Type: llm.ContentTypeToolUse, ID: "t1", ToolName: "read_file", ToolInput: json.RawMessage(`{"path":"spec.pdf"}`)},
+				},
+			},
+			{
+				Role: llm.MessageRoleUser,
+				Content: []llm.Content{
+					{
+						Type:      llm.ContentTypeToolResult,
+						ToolUseID: "t1",
+						ToolResult: []llm.Content{
+							{Type: llm.ContentTypeText, Text: "File: spec.pdf"},
+							{Type: llm.ContentTypeDocument, Title: "spec.pdf", MediaType: "application/pdf", Data: "JVBERi0="},
+						},
+					},
+				},
+			},
+		},
+	}
+	gemReq, err := s.buildGeminiRequest(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parts := gemReq.Contents[0].Parts
+	if len(parts) != 3 {
+		t.Fatalf("expected 3 parts, got %d", len(parts))
+	}
+	if parts[0].InlineData == nil || parts[0].InlineData.MimeType != "image/png" || parts[0].InlineData.Data != "aW1n" {
+		t.Errorf("image part = %+v", parts[0])
+	}
+	if parts[1].InlineData == nil || parts[1].InlineData.MimeType != "application/pdf" {
+		t.Errorf("document part = %+v", parts[1])
+	}
+	if parts[2].InlineData != nil || !strings.HasPrefix(parts[2].Text, "Document a.zip (application/zip)") {
+		t.Errorf("unsupported document part = %+v", parts[2])
+	}
+
+	parts = gemReq.Contents[2].Parts
+	if len(parts) != 2 || parts[0].FunctionResponse == nil {
+		t.Fatalf("tool result parts = %+v", parts)
+	}
+	if parts[0].FunctionResponse.Response["result"] != "File: spec.pdf" {
+		t.Errorf("function response = %+v", parts[0].FunctionResponse.Response)
+	}
+	if parts[1].InlineData == nil || parts[1].InlineData.MimeType != "application/pdf" {
+		t.Errorf("tool result document part = %+v", parts[1])
+	}
+}
+
+// countingRoundTripper answers every request with status.
+type countingRoundTripper struct {
+	status int
+	calls  int
+}
+
+func (c *countingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.calls++
+	return &http.Response{
+		StatusCode: c.status,
+		Header:     http.Header{},
+		Body:       io.NopCloser(strings.NewReader(`{"error":{"message":"unavailable"}}`)),
+	}, nil
+}
+
+func TestServiceDoRetries(t *testing.T) {
+	for _, tt := range []struct {
+		status    int
+		wantCalls int
+	}{
+		{http.StatusServiceUnavailable, 2}, // retried up to the attempt cap
+		{http.StatusBadRequest, 1},         // not retried
+	} {
+		rt := &countingRoundTripper{status: tt.status}
+		service := &Service{APIKey: "test-key", HTTPC: &http.Client{Transport: rt}}
+		ctx := llm.WithMaxAttempts(context.Background(), 2)
+		_, err := service.Do(ctx, &llm.Request{Messages: []llm.Message{llm.UserStringMessage("hi")}})
+		if err == nil {
+			t.Fatalf("status %d: expected error", tt.status)
+		}
+		if rt.calls != tt.wantCalls {
+			t.Errorf("status %d: got %d calls, want %d", tt.status, rt.calls, tt.wantCalls)
+		}
+		if got := llm.HTTPStatus(err); got != tt.status {
+			t.Errorf("status %d: HTTPStatus(err) = %d", tt.status, got)
+		}
+	}
+}
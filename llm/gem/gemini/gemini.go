@@ -53,10 +53,16 @@ type Part struct {
 	// ThoughtSignature is required for Gemini 3 models when using function calling.
 	// It must be passed back exactly as received when sending the conversation history.
 	ThoughtSignature string `json:"thoughtSignature,omitempty"`
-	// TODO inlineData
+	InlineData       *Blob  `json:"inlineData,omitempty"`
 	// TODO fileData
 }
 
+// Blob is inline media, such as an image or PDF.
+type Blob struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"` // base64-encoded
+}
+
 type FunctionCall struct {
 	Name string         `json:"name"`
 	Args map[string]any `json:"args"`
@@ -95,8 +101,15 @@ const (
 
 // https://ai.google.dev/api/generate-content#v1beta.GenerationConfig
 type GenerationConfig struct {
-	ResponseMimeType string  `json:"responseMimeType,omitempty"` // text/plain, application/json, or text/x.enum
-	ResponseSchema   *Schema `json:"responseSchema,omitempty"`   // for JSON
+	ResponseMimeType string          `json:"responseMimeType,omitempty"` // text/plain, application/json, or text/x.enum
+	ResponseSchema   *Schema         `json:"responseSchema,omitempty"`   // for JSON
+	ThinkingConfig   *ThinkingConfig `json:"thinkingConfig,omitempty"`
+}
+
+// https://ai.google.dev/gemini-api/docs/thinking
+type ThinkingConfig struct {
+	ThinkingBudget int    `json:"thinkingBudget,omitempty"` // tokens; Gemini 2.5
+	ThinkingLevel  string `json:"thinkingLevel,omitempty"`  // "low" or "high"; Gemini 3
 }
 
 // https://ai.google.dev/api/caching#Tool
@@ -148,6 +161,16 @@ type Model struct {
 	Endpoint string       // if empty, DefaultEndpoint is used
 }
 
+// APIError is a non-200 response from the Gemini API.
+type APIError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("GenerateContent: HTTP status: %d, %s", e.StatusCode, e.Body)
+}
+
 func (m Model) GenerateContent(ctx context.Context, req *Request) (*Response, error) {
 	reqBytes, err := json.Marshal(req)
 	if err != nil {
@@ -168,7 +191,7 @@ func (m Model) GenerateContent(ctx context.Context, req *Request) (*Response, er
 		return nil, fmt.Errorf("GenerateContent: reading response body: %w", err)
 	}
 	if httpResp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("GenerateContent: HTTP status: %d, %s", httpResp.StatusCode, string(body))
+		return nil, &APIError{StatusCode: httpResp.StatusCode, Body: string(body)}
 	}
 	var res Response
 	if err := json.Unmarshal(body, &res); err != nil {
@@ -75,6 +75,16 @@ type Request struct {
 	ToolChoice *ToolChoice
 	Tools      []*Tool
 	System     []SystemContent
+	// ThinkingLevel overrides the service's thinking level for this request if set
+	ThinkingLevel *ThinkingLevel
+}
+
+// ThinkingLevelOr returns the request's thinking level, or def if it doesn't set one.
+func (r *Request) ThinkingLevelOr(def ThinkingLevel) ThinkingLevel {
+	if r.ThinkingLevel != nil {
+		return *r.ThinkingLevel
+	}
+	return def
 }
 
 // Message represents a message in the conversation.
@@ -152,9 +162,12 @@ type Content struct {
 	Type ContentType
 	Text string
 
-	// Media type for image content
+	// Media type for image and document content
 	MediaType string
 
+	// Title of document content, usually its file name
+	Title string
+
 	// for thinking
 	Thinking  string
 	Data      string
@@ -200,6 +213,9 @@ func ContentsAttr(contents []Content) slog.Attr {
 			attrs = append(attrs, slog.Bool("tool_error", content.ToolError))
 		case ContentTypeThinking:
 			attrs = append(attrs, slog.String("thinking", content.Thinking))
+		case ContentTypeDocument:
+			attrs = append(attrs, slog.String("title", content.Title))
+			attrs = append(attrs, slog.String("media_type", content.MediaType))
 		default:
 			attrs = append(attrs, slog.String("unknown_content_type", content.Type.String()))
 			attrs = append(attrs, slog.Any("text", content)) // just log it all raw, better to have too much than not enough
@@ -228,6 +244,7 @@ const (
 	ContentTypeRedactedThinking
 	ContentTypeToolUse
 	ContentTypeToolResult
+	ContentTypeDocument // MediaType and base64 Data; see DocumentContent
 
 	ToolChoiceTypeAuto ToolChoiceType = iota // default
 	ToolChoiceTypeAny                        // any tool, but must use one
@@ -283,6 +300,24 @@ func (t ThinkingLevel) ThinkingEffort() string {
 	}
 }
 
+// Name returns the level's lowercase name, as accepted by ParseThinkingLevel.
+func (t ThinkingLevel) Name() string {
+	if t == ThinkingLevelOff {
+		return "off"
+	}
+	return t.ThinkingEffort()
+}
+
+// ParseThinkingLevel parses a thinking level name: off, minimal, low, medium or high.
+func ParseThinkingLevel(s string) (ThinkingLevel, error) {
+	for t := ThinkingLevelOff; t <= ThinkingLevelHigh; t++ {
+		if t.Name() == s {
+			return t, nil
+		}
+	}
+	return ThinkingLevelOff, fmt.Errorf("unknown thinking level %q", s)
+}
+
 type Response struct {
 	ID           string
 	Type         string
@@ -328,6 +363,7 @@ type Usage struct {
 	OutputTokens             uint64     `json:"output_tokens"`
 	CostUSD                  float64    `json:"cost_usd"`
 	Model                    string     `json:"model,omitempty"`
+	FallbackFrom             string     `json:"fallback_from,omitempty"` // model ID that failed before this one answered
 	StartTime                *time.Time `json:"start_time,omitempty"`
 	EndTime                  *time.Time `json:"end_time,omitempty"`
 }
@@ -430,3 +466,57 @@ func DumpToFile(typ, url string, content []byte) error {
 
 	return os.WriteFile(filePath, data, 0o600)
 }
+
+type maxAttemptsKey struct{}
+
+// WithMaxAttempts returns a context that caps how many attempts a service
+// makes before giving up on retryable errors. Services in a fallback chain
+// use it to fail over quickly instead of retrying for minutes.
+func WithMaxAttempts(ctx context.Context, n int) context.Context {
+	return context.WithValue(ctx, maxAttemptsKey{}, n)
+}
+
+// MaxAttempts returns the attempt cap set by WithMaxAttempts, or def if none.
+func MaxAttempts(ctx context.Context, def int) int {
+	if n, ok := ctx.Value(maxAttemptsKey{}).(int); ok && n > 0 {
+		return n
+	}
+	return def
+}
+
+// StatusError is a non-success HTTP response from a provider API. Services
+// return it, possibly joined with earlier attempts' errors, so callers can
+// classify failures by status code rather than by message text.
+type StatusError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *StatusError) Error() string { return e.Message }
+
+// StatusErrorf returns a StatusError with a formatted message.
+func StatusErrorf(code int, format string, args ...any) *StatusError {
+	return &StatusError{StatusCode: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// HTTPStatus returns the status code of the most recent StatusError in err's
+// tree, or 0 if there is none. Errors joined later are treated as more recent.
+func HTTPStatus(err error) int {
+	switch e := err.(type) {
+	case nil:
+		return 0
+	case *StatusError:
+		return e.StatusCode
+	case interface{ Unwrap() []error }:
+		errs := e.Unwrap()
+		for i := len(errs) - 1; i >= 0; i-- {
+			if code := HTTPStatus(errs[i]); code != 0 {
+				return code
+			}
+		}
+		return 0
+	case interface{ Unwrap() error }:
+		return HTTPStatus(e.Unwrap())
+	}
+	return 0
+}
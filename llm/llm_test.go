@@ -5,7 +5,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 	"testing"
+	"unicode/utf8"
 )
 
 // mockService implements Service interface for testing
@@ -547,3 +549,55 @@ func TestDumpToFile(t *testing.T) {
 	// This might fail due to permissions, but it shouldn't panic
 	_ = DumpToFile("test", "http://example.com", content)
 }
+
+func TestParseThinkingLevel(t *testing.T) {
+	for level := ThinkingLevelOff; level <= ThinkingLevelHigh; level++ {
+		got, err := ParseThinkingLevel(level.Name())
+		if err != nil || got != level {
+			t.Errorf("ParseThinkingLevel(%q) = %v, %v; want %v", level.Name(), got, err, level)
+		}
+	}
+	if _, err := ParseThinkingLevel("extreme"); err == nil {
+		t.Error("ParseThinkingLevel(\"extreme\") succeeded, want error")
+	}
+}
+
+func TestRequestThinkingLevelOr(t *testing.T) {
+	r := &Request{}
+	if got := r.ThinkingLevelOr(ThinkingLevelMedium); got != ThinkingLevelMedium {
+		t.Errorf("ThinkingLevelOr without override = %v, want medium", got)
+	}
+	off := ThinkingLevelOff
+	r.ThinkingLevel = &off
+	if got := r.ThinkingLevelOr(ThinkingLevelMedium); got != ThinkingLevelOff {
+		t.Errorf("ThinkingLevelOr with override = %v, want off", got)
+	}
+}
+
+func TestDocumentText(t *testing.T) {
+	c := DocumentContent("notes.txt", "text/plain", []byte("some notes"))
+	if c.Type != ContentTypeDocument {
+		t.Fatalf("Type = %v, want ContentTypeDocument", c.Type)
+	}
+	if got, want := DocumentText(c), "Document notes.txt (text/plain):\nsome notes"; got != want {
+		t.Errorf("DocumentText = %q, want %q", got, want)
+	}
+
+	c = DocumentContent("broken.pdf", "application/pdf", []byte("%PDF-1.4\n%%EOF\n"))
+	if got := DocumentText(c); !strings.HasPrefix(got, "Document broken.pdf (application/pdf): text could not be extracted") {
+		t.Errorf("DocumentText for PDF without text = %q", got)
+	}
+
+	// Content stored before the text was cached is extracted on demand
+	c = DocumentContent("notes.txt", "text/plain", []byte("some notes"))
+	c.Text = ""
+	if got, want := DocumentText(c), "Document notes.txt (text/plain):\nsome notes"; got != want {
+		t.Errorf("DocumentText without cached text = %q, want %q", got, want)
+	}
+
+	// Long text is capped on a rune boundary
+	c = DocumentContent("big.txt", "text/plain", []byte("x"+strings.Repeat("é", maxDocumentText)))
+	if !strings.Contains(c.Text, "[document text truncated:") || !utf8.ValidString(c.Text) || len(c.Text) > maxDocumentText+100 {
+		t.Errorf("DocumentText for long text has %d bytes, valid UTF-8 %v", len(c.Text), utf8.ValidString(c.Text))
+	}
+}
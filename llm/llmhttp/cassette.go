@@ -0,0 +1,186 @@
+package llmhttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+// CassetteVersion is the current cassette file format version.
+const CassetteVersion = 1
+
+// Cassette is a recorded sequence of LLM HTTP interactions that can be
+// replayed without network access.
+type Cassette struct {
+	Version      int           `json:"version"`
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is one recorded request/response pair.
+type Interaction struct {
+	Model        string `json:"model,omitempty"`
+	Provider     string `json:"provider,omitempty"`
+	URL          string `json:"url"`
+	RequestBody  string `json:"request_body"`
+	StatusCode   int    `json:"status_code"`
+	ResponseBody string `json:"response_body"`
+}
+
+// LoadCassette reads a cassette file.
+func LoadCassette(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("llmhttp: load cassette: %w", err)
+	}
+	var c Cassette
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("llmhttp: parse cassette %s: %w", path, err)
+	}
+	if c.Version > CassetteVersion {
+		return nil, fmt.Errorf("llmhttp: cassette %s has unsupported version %d", path, c.Version)
+	}
+	return &c, nil
+}
+
+// Save writes the cassette to path as indented JSON.
+func (c *Cassette) Save(path string) error {
+	c.Version = CassetteVersion
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("llmhttp: encode cassette: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("llmhttp: save cassette: %w", err)
+	}
+	return nil
+}
+
+// volatileRequestFields are top-level request fields that vary between
+// runs without changing what the model is asked to do.
+var volatileRequestFields = []string{"metadata", "user", "prompt_cache_key", "safety_identifier"}
+
+// NormalizeRequestBody returns a canonical form of a JSON request body for
+// matching: secrets are masked as RedactSecrets does, object keys are sorted,
+// whitespace is dropped, and volatile top-level fields are removed. Masking
+// lets a live request match a recording that was redacted before storage.
+// Bodies that are not JSON are returned as is, apart from masking.
+func NormalizeRequestBody(body []byte) string {
+	body = RedactSecrets(body)
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		return string(bytes.TrimSpace(body))
+	}
+	if m, ok := v.(map[string]any); ok {
+		for _, k := range volatileRequestFields {
+			delete(m, k)
+		}
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		return string(body)
+	}
+	return string(out)
+}
+
+// ReplayTransport is an http.RoundTripper that serves responses from a
+// Cassette instead of the network. Each interaction is served at most once.
+//
+// A request is matched to the first unused interaction with the same
+// normalized body. If there is none and Strict is false, the next unused
+// interaction for the same URL path is served, which tolerates prompts that
+// embed the date or working directory.
+type ReplayTransport struct {
+	Strict bool
+
+	mu         sync.Mutex
+	cassette   *Cassette
+	normalized []string
+	used       []bool
+}
+
+// NewReplayTransport creates a ReplayTransport serving c.
+func NewReplayTransport(c *Cassette) *ReplayTransport {
+	t := &ReplayTransport{
+		cassette:   c,
+		normalized: make([]string, len(c.Interactions)),
+		used:       make([]bool, len(c.Interactions)),
+	}
+	for i, in := range c.Interactions {
+		t.normalized[i] = NormalizeRequestBody([]byte(in.RequestBody))
+	}
+	return t
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *ReplayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	in, ok := t.match(req.URL, NormalizeRequestBody(body))
+	if !ok {
+		return nil, fmt.Errorf("llmhttp: no recorded response for request to %s", req.URL)
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", in.StatusCode, http.StatusText(in.StatusCode)),
+		StatusCode:    in.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          io.NopCloser(strings.NewReader(in.ResponseBody)),
+		ContentLength: int64(len(in.ResponseBody)),
+		Request:       req,
+	}, nil
+}
+
+// Remaining returns the number of interactions not yet served.
+func (t *ReplayTransport) Remaining() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	n := 0
+	for _, u := range t.used {
+		if !u {
+			n++
+		}
+	}
+	return n
+}
+
+func (t *ReplayTransport) match(u *url.URL, normalized string) (Interaction, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for i, n := range t.normalized {
+		if !t.used[i] && n == normalized {
+			t.used[i] = true
+			return t.cassette.Interactions[i], true
+		}
+	}
+	if t.Strict {
+		return Interaction{}, false
+	}
+	for i, in := range t.cassette.Interactions {
+		if t.used[i] {
+			continue
+		}
+		if recorded, err := url.Parse(in.URL); err == nil && recorded.Path == u.Path {
+			t.used[i] = true
+			return in, true
+		}
+	}
+	return Interaction{}, false
+}
@@ -0,0 +1,120 @@
+package llmhttp
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNormalizeRequestBody(t *testing.T) {
+	a := NormalizeRequestBody([]byte(`{"model": "m", "messages": [1, 2], "metadata": {"user_id": "x"}}`))
+	b := NormalizeRequestBody([]byte(`{"messages":[1,2],"model":"m"}`))
+	if a != b {
+		t.Errorf("normalized bodies differ:\n%s\n%s", a, b)
+	}
+	if got := NormalizeRequestBody([]byte(" not json \n")); got != "not json" {
+		t.Errorf("NormalizeRequestBody(non-JSON) = %q", got)
+	}
+}
+
+func TestCassetteSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	c := &Cassette{Interactions: []Interaction{{URL: "https://api.example.com/v1/messages", RequestBody: `{}`, StatusCode: 200, ResponseBody: `{"ok":true}`}}}
+	if err := c.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadCassette(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Version != CassetteVersion || len(loaded.Interactions) != 1 || loaded.Interactions[0].ResponseBody != `{"ok":true}` {
+		t.Errorf("unexpected cassette: %+v", loaded)
+	}
+}
+
+func TestReplayTransport(t *testing.T) {
+	c := &Cassette{Interactions: []Interaction{
+		{URL: "https://api.example.com/v1/messages", RequestBody: `{"n":1}`, StatusCode: 200, ResponseBody: "first"},
+		{URL: "https://api.example.com/v1/messages", RequestBody: `{"n":2}`, StatusCode: 529, ResponseBody: "second"},
+		{URL: "https://api.example.com/v1/messages", RequestBody: `{"n":3}`, StatusCode: 200, ResponseBody: "third"},
+	}}
+	transport := NewReplayTransport(c)
+	client := NewClient(&http.Client{Transport: transport}, nil)
+
+	do := func(body string) (int, string, error) {
+		resp, err := client.Post("https://api.example.com/v1/messages", "application/json", strings.NewReader(body))
+		if err != nil {
+			return 0, "", err
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(b), nil
+	}
+
+	// Exact match, regardless of recording order.
+	if code, body, err := do(`{ "n": 2 }`); err != nil || code != 529 || body != "second" {
+		t.Errorf("match n=2: code=%d body=%q err=%v", code, body, err)
+	}
+	// No exact match: falls back to the next unused interaction.
+	if code, body, err := do(`{"n":99}`); err != nil || code != 200 || body != "first" {
+		t.Errorf("fallback: code=%d body=%q err=%v", code, body, err)
+	}
+	if got := transport.Remaining(); got != 1 {
+		t.Errorf("Remaining() = %d, want 1", got)
+	}
+
+	transport.Strict = true
+	if _, _, err := do(`{"n":99}`); err == nil {
+		t.Error("strict mode served an unmatched request")
+	}
+	if _, body, err := do(`{"n":3}`); err != nil || body != "third" {
+		t.Errorf("match n=3: body=%q err=%v", body, err)
+	}
+	if _, _, err := do(`{"n":3}`); err == nil {
+		t.Error("interaction served twice")
+	}
+}
+
+func TestReplayRedactedRecording(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"ok":true}`)
+	}))
+	defer upstream.Close()
+
+	// Record the way the models manager does, masking secrets before storage.
+	var cassette Cassette
+	recorder := func(ctx context.Context, url string, requestBody, responseBody []byte, statusCode int, err error, duration time.Duration) {
+		cassette.Interactions = append(cassette.Interactions, Interaction{
+			URL:          url,
+			RequestBody:  string(RedactSecrets(requestBody)),
+			StatusCode:   statusCode,
+			ResponseBody: string(RedactSecrets(responseBody)),
+		})
+	}
+	body := `{"messages":[{"role":"user","content":"deploy with key sk-ant-REDACTED"}]}`
+	resp, err := NewClient(upstream.Client(), recorder).Post(upstream.URL+"/v1/messages", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if len(cassette.Interactions) != 1 || strings.Contains(cassette.Interactions[0].RequestBody, "sk-ant-") {
+		t.Fatalf("expected one redacted recording, got %+v", cassette.Interactions)
+	}
+
+	// The same live request, secret included, matches in strict mode.
+	transport := NewReplayTransport(&cassette)
+	transport.Strict = true
+	resp, err = (&http.Client{Transport: transport}).Post(upstream.URL+"/v1/messages", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("strict replay of redacted recording failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if b, _ := io.ReadAll(resp.Body); string(b) != `{"ok":true}` {
+		t.Errorf("unexpected replayed body %q", b)
+	}
+}
@@ -19,6 +19,7 @@ const (
 	conversationIDKey contextKey = iota
 	modelIDKey
 	providerKey
+	priorityKey
 )
 
 // WithConversationID returns a context with the conversation ID attached.
@@ -60,6 +61,31 @@ func ProviderFromContext(ctx context.Context) string {
 	return ""
 }
 
+// Priority orders LLM requests that are queued behind a rate limit.
+type Priority int
+
+const (
+	// PriorityInteractive is for requests a user is waiting on (the default).
+	PriorityInteractive Priority = iota
+	// PriorityBackground is for housekeeping such as slug generation and
+	// memory extraction; it only runs when no interactive request is queued.
+	PriorityBackground
+)
+
+// WithPriority returns a context with the request priority attached.
+func WithPriority(ctx context.Context, p Priority) context.Context {
+	return context.WithValue(ctx, priorityKey, p)
+}
+
+// PriorityFromContext returns the request priority from the context,
+// defaulting to PriorityInteractive.
+func PriorityFromContext(ctx context.Context) Priority {
+	if v := ctx.Value(priorityKey); v != nil {
+		return v.(Priority)
+	}
+	return PriorityInteractive
+}
+
 // Recorder is called after each LLM HTTP request with the request/response details.
 type Recorder func(ctx context.Context, url string, requestBody, responseBody []byte, statusCode int, err error, duration time.Duration)
 
@@ -0,0 +1,43 @@
+package llmhttp
+
+import (
+	"regexp"
+)
+
+// secretPatterns match credentials that commonly leak into tool output.
+// Each match is replaced with "[REDACTED:<name>]".
+var secretPatterns = []struct {
+	name string
+	re   *regexp.Regexp
+}{
+	// PEM blocks may appear with real or JSON-escaped ("\n") newlines. The
+	// body is limited to base64 so a match cannot span JSON fields.
+	{"private-key", regexp.MustCompile(`-----BEGIN [A-Z0-9 ]*PRIVATE KEY-----[A-Za-z0-9+/=\s\\]+-----END [A-Z0-9 ]*PRIVATE KEY-----`)},
+	{"anthropic-key", regexp.MustCompile(`sk-ant-[A-Za-z0-9_-]{20,}`)},
+	{"openai-key", regexp.MustCompile(`sk-(?:proj-|svcacct-)?[A-Za-z0-9_-]{32,}`)},
+	{"github-token", regexp.MustCompile(`(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{40,})`)},
+	{"aws-access-key", regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"google-api-key", regexp.MustCompile(`AIza[0-9A-Za-z_-]{35}`)},
+	{"slack-token", regexp.MustCompile(`xox[abposr]-[A-Za-z0-9-]{10,}`)},
+	{"jwt", regexp.MustCompile(`eyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`)},
+	// Credentials in HTTP headers, e.g. "Authorization: Bearer ..." in a
+	// curl command. Quotes may be JSON-escaped.
+	{"bearer-token", regexp.MustCompile(`(?i)(\b(?:authorization|proxy-authorization|x-api-key|api-key|x-auth-token)\\?["']?\s*[:=]\s*\\?["']?\s*(?:(?:bearer|token|basic)\s+)?)[A-Za-z0-9._~+/-]{20,}=*`)},
+	// Credentials as JSON string values, e.g. {"access_token": "..."}.
+	{"token-field", regexp.MustCompile(`(?i)(\\?"(?:access_token|refresh_token|id_token|auth_token|token|api_key|apikey|client_secret|secret)\\?"\s*:\s*\\?")[A-Za-z0-9._~+/-]{20,}=*`)},
+}
+
+// RedactSecrets masks recognizable secrets (API keys, access tokens,
+// private keys) in body. Bodies without secrets are returned unchanged.
+// When a pattern has a capture group, such as a header name, the group is
+// kept and only the secret after it is masked.
+func RedactSecrets(body []byte) []byte {
+	for _, p := range secretPatterns {
+		repl := "[REDACTED:" + p.name + "]"
+		if p.re.NumSubexp() > 0 {
+			repl = "${1}" + repl
+		}
+		body = p.re.ReplaceAll(body, []byte(repl))
+	}
+	return body
+}
@@ -0,0 +1,133 @@
+package oai
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/tgruben-circuit/percy/llm"
+)
+
+// batchOutputLine is one line of a batch's JSONL output or error file.
+type batchOutputLine struct {
+	CustomID string `json:"custom_id"`
+	Response *struct {
+		StatusCode int             `json:"status_code"`
+		Body       json.RawMessage `json:"body"`
+	} `json:"response"`
+	Error *struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+var _ llm.Batcher = (*Service)(nil)
+
+// SubmitBatch implements llm.Batcher using the Batch API.
+func (s *Service) SubmitBatch(ctx context.Context, reqs []*llm.Request) (string, error) {
+	client, _ := s.client()
+	upload := openai.UploadBatchFileRequest{FileName: "percy-batch.jsonl"}
+	for i, r := range reqs {
+		upload.AddChatCompletion(strconv.Itoa(i), s.fromLLMRequest(r))
+	}
+	batch, err := client.CreateBatchWithUploadFile(ctx, openai.CreateBatchWithUploadFileRequest{
+		Endpoint:               openai.BatchEndpointChatCompletions,
+		CompletionWindow:       "24h",
+		UploadBatchFileRequest: upload,
+	})
+	if err != nil {
+		return "", err
+	}
+	return batch.ID, nil
+}
+
+// PollBatch implements llm.Batcher.
+func (s *Service) PollBatch(ctx context.Context, batchID string) (bool, error) {
+	client, _ := s.client()
+	batch, err := client.RetrieveBatch(ctx, batchID)
+	if err != nil {
+		return false, err
+	}
+	switch batch.Status {
+	case "completed", "failed", "expired", "cancelled":
+		return true, nil
+	}
+	return false, nil
+}
+
+// CollectBatch implements llm.Batcher.
+func (s *Service) CollectBatch(ctx context.Context, batchID string) ([]llm.BatchResult, error) {
+	client, _ := s.client()
+	batch, err := client.RetrieveBatch(ctx, batchID)
+	if err != nil {
+		return nil, err
+	}
+	if batch.Status == "failed" && batch.Errors != nil && len(batch.Errors.Data) > 0 {
+		var msgs []string
+		for _, e := range batch.Errors.Data {
+			msgs = append(msgs, e.Code+": "+e.Message)
+		}
+		return nil, fmt.Errorf("batch %s failed: %s", batchID, strings.Join(msgs, "; "))
+	}
+
+	results := map[string]llm.BatchResult{}
+	for _, fileID := range []*string{batch.OutputFileID, batch.ErrorFileID} {
+		if fileID == nil || *fileID == "" {
+			continue
+		}
+		content, err := client.GetFileContent(ctx, *fileID)
+		if err != nil {
+			return nil, err
+		}
+		err = s.readBatchOutput(content, results)
+		content.Close()
+		if err != nil {
+			return nil, fmt.Errorf("batch %s: %w", batchID, err)
+		}
+	}
+
+	out := make([]llm.BatchResult, batch.RequestCounts.Total)
+	for i := range out {
+		r, ok := results[strconv.Itoa(i)]
+		if !ok {
+			r = llm.BatchResult{Err: fmt.Errorf("batch request not processed (batch %s)", batch.Status)}
+		}
+		out[i] = r
+	}
+	return out, nil
+}
+
+// readBatchOutput adds the results in a JSONL output or error file to results.
+func (s *Service) readBatchOutput(r io.Reader, results map[string]llm.BatchResult) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 64<<20)
+	for scanner.Scan() {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var line batchOutputLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			return fmt.Errorf("bad output line: %w", err)
+		}
+		switch {
+		case line.Error != nil:
+			results[line.CustomID] = llm.BatchResult{Err: fmt.Errorf("batch request failed: %s: %s", line.Error.Code, line.Error.Message)}
+		case line.Response == nil:
+			results[line.CustomID] = llm.BatchResult{Err: fmt.Errorf("batch request has no response")}
+		case line.Response.StatusCode != 200:
+			results[line.CustomID] = llm.BatchResult{Err: fmt.Errorf("batch request failed: status %d: %s", line.Response.StatusCode, line.Response.Body)}
+		default:
+			var resp openai.ChatCompletionResponse
+			if err := json.Unmarshal(line.Response.Body, &resp); err != nil {
+				return fmt.Errorf("bad response body: %w", err)
+			}
+			results[line.CustomID] = llm.BatchResult{Response: s.toLLMResponse(&resp)}
+		}
+	}
+	return scanner.Err()
+}
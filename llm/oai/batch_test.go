@@ -0,0 +1,90 @@
+package oai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/tgruben-circuit/percy/llm"
+)
+
+func TestBatch(t *testing.T) {
+	var input []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/files", func(w http.ResponseWriter, r *http.Request) {
+		f, _, err := r.FormFile("file")
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(f)
+		input = strings.Split(strings.TrimSpace(string(data)), "\n")
+		_ = json.NewEncoder(w).Encode(openai.File{ID: "file-in"})
+	})
+	mux.HandleFunc("POST /v1/batches", func(w http.ResponseWriter, r *http.Request) {
+		var req openai.CreateBatchRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatal(err)
+		}
+		if req.InputFileID != "file-in" || req.Endpoint != openai.BatchEndpointChatCompletions {
+			t.Errorf("create batch = %+v", req)
+		}
+		_ = json.NewEncoder(w).Encode(openai.Batch{ID: "batch_1", Status: "validating"})
+	})
+	mux.HandleFunc("GET /v1/batches/batch_1", func(w http.ResponseWriter, r *http.Request) {
+		out, errs := "file-out", "file-err"
+		_ = json.NewEncoder(w).Encode(openai.Batch{
+			ID: "batch_1", Status: "completed", OutputFileID: &out, ErrorFileID: &errs,
+			RequestCounts: openai.BatchRequestCounts{Total: 2, Completed: 1, Failed: 1},
+		})
+	})
+	mux.HandleFunc("GET /v1/files/file-out/content", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := json.Marshal(openai.ChatCompletionResponse{
+			Model:   "gpt-4.1",
+			Choices: []openai.ChatCompletionChoice{{Message: openai.ChatCompletionMessage{Role: "assistant", Content: "hello"}, FinishReason: "stop"}},
+			Usage:   openai.Usage{PromptTokens: 7, CompletionTokens: 1},
+		})
+		fmt.Fprintf(w, `{"custom_id":"1","response":{"status_code":200,"body":%s}}`+"\n", body)
+	})
+	mux.HandleFunc("GET /v1/files/file-err/content", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"custom_id":"0","response":{"status_code":400,"body":{"error":{"message":"too long"}}}}`)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	svc := &Service{APIKey: "key", Model: GPT41, ModelURL: srv.URL + "/v1"}
+	ctx := context.Background()
+
+	id, err := svc.SubmitBatch(ctx, []*llm.Request{
+		{Messages: []llm.Message{llm.UserStringMessage("first")}},
+		{Messages: []llm.Message{llm.UserStringMessage("second")}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(input) != 2 || !strings.Contains(input[0], `"custom_id":"0"`) || !strings.Contains(input[1], "second") {
+		t.Errorf("batch input = %q", input)
+	}
+
+	if done, err := svc.PollBatch(ctx, id); err != nil || !done {
+		t.Fatalf("poll = %v, %v", done, err)
+	}
+	results, err := svc.CollectBatch(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("got %d results", len(results))
+	}
+	if r := results[0]; r.Err == nil || !strings.Contains(r.Err.Error(), "status 400") {
+		t.Errorf("result 0 = %+v", r)
+	}
+	if r := results[1]; r.Err != nil || r.Response.Content[0].Text != "hello" || r.Response.Usage.InputTokens != 7 {
+		t.Errorf("result 1 = %+v", r)
+	}
+}
@@ -1,11 +1,13 @@
 package oai
 
 import (
+	"bytes"
 	"cmp"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"math/rand/v2"
 	"net/http"
@@ -43,8 +45,9 @@ type Model struct {
 	ModelName          string // provided to the service provide to specify which model to use (e.g. "gpt-4.1-2025-04-14")
 	URL                string
 	APIKeyEnv          string // environment variable name for the API key
-	IsReasoningModel   bool   // whether this model is a reasoning model (e.g. O3, O4-mini)
+	IsReasoningModel   bool   // whether this model is a reasoning model that accepts reasoning_effort (e.g. O3, GLM-4.7)
 	UseSimplifiedPatch bool   // whether to use the simplified patch input schema; defaults to false
+	ReasoningContent   bool   // whether the endpoint accepts reasoning_content on prior assistant messages
 }
 
 var (
@@ -244,17 +247,20 @@ var (
 	}
 
 	GLM4P6Fireworks = Model{
-		UserName:  "glm-4p6-fireworks",
-		ModelName: "accounts/fireworks/models/glm-4p6",
-		URL:       FireworksURL,
-		APIKeyEnv: FireworksAPIKeyEnv,
+		UserName:         "glm-4p6-fireworks",
+		ModelName:        "accounts/fireworks/models/glm-4p6",
+		URL:              FireworksURL,
+		APIKeyEnv:        FireworksAPIKeyEnv,
+		IsReasoningModel: true,
 	}
 
 	GLM47Fireworks = Model{
-		UserName:  "glm-4.7-fireworks",
-		ModelName: "accounts/fireworks/models/glm-4p7",
-		URL:       FireworksURL,
-		APIKeyEnv: FireworksAPIKeyEnv,
+		UserName:         "glm-4.7-fireworks",
+		ModelName:        "accounts/fireworks/models/glm-4p7",
+		URL:              FireworksURL,
+		APIKeyEnv:        FireworksAPIKeyEnv,
+		IsReasoningModel: true,
+		ReasoningContent: true,
 	}
 
 	GPTOSS20B = Model{
@@ -335,6 +341,8 @@ type Service struct {
 	ModelURL  string       // optional, overrides Model.URL
 	MaxTokens int          // defaults to DefaultMaxTokens if zero
 	Org       string       // optional - organization ID
+
+	ThinkingLevel llm.ThinkingLevel // sent as reasoning_effort; leave off for models that reject it unless Model.IsReasoningModel
 }
 
 var _ llm.Service = (*Service)(nil)
@@ -458,15 +466,22 @@ func fromLLMContent(c llm.Content) (string, []openai.ToolCall) {
 			// Collect all text from content objects
 			texts := make([]string, 0, len(c.ToolResult))
 			for _, result := range c.ToolResult {
-				if result.Text != "" {
+				if result.Type == llm.ContentTypeDocument {
+					texts = append(texts, llm.DocumentText(result))
+				} else if result.Text != "" {
 					texts = append(texts, result.Text)
 				}
 			}
 			resultText = strings.Join(texts, "\n")
 		}
 		return resultText, nil
+	case llm.ContentTypeThinking, llm.ContentTypeRedactedThinking:
+		// Reasoning travels in reasoning_content, never as visible text
+		return "", nil
+	case llm.ContentTypeDocument:
+		// Chat completions have no portable document input; send the text
+		return llm.DocumentText(c), nil
 	default:
-		// For thinking or other types, convert to text
 		return c.Text, nil
 	}
 }
@@ -496,7 +511,9 @@ func fromLLMMessage(msg llm.Message) []openai.ChatCompletionMessage {
 		// Collect all text from content objects
 		var texts []string
 		for _, result := range tr.ToolResult {
-			if strings.TrimSpace(result.Text) != "" {
+			if result.Type == llm.ContentTypeDocument {
+				texts = append(texts, llm.DocumentText(result))
+			} else if strings.TrimSpace(result.Text) != "" {
 				texts = append(texts, result.Text)
 			}
 		}
@@ -529,6 +546,15 @@ func fromLLMMessage(msg llm.Message) []openai.ChatCompletionMessage {
 		var textContent string
 
 		for _, c := range regularContent {
+			if c.Type == llm.ContentTypeThinking {
+				if reasoning := cmp.Or(c.Thinking, c.Text); reasoning != "" {
+					if m.ReasoningContent != "" {
+						m.ReasoningContent += "\n"
+					}
+					m.ReasoningContent += reasoning
+				}
+				continue
+			}
 			content, tools := fromLLMContent(c)
 			if len(tools) > 0 {
 				toolCalls = append(toolCalls, tools...)
@@ -620,15 +646,22 @@ func toToolCallLLMContent(toolCall openai.ToolCall) llm.Content {
 	// Generate a content ID if needed
 	id := toolCall.ID
 	if id == "" {
-		// Create a deterministic ID based on the function name if no ID is provided
+		// Create a deterministic ID based on the function name if no ID is provided.
+		// Include the index so parallel calls to the same tool stay distinct.
 		id = "tc_" + toolCall.Function.Name
+		if toolCall.Index != nil {
+			id = fmt.Sprintf("tc_%d_%s", *toolCall.Index, toolCall.Function.Name)
+		}
 	}
 
+	// Some providers send empty arguments for tools without parameters
+	args := cmp.Or(strings.TrimSpace(toolCall.Function.Arguments), "{}")
+
 	return llm.Content{
 		ID:        id,
 		Type:      llm.ContentTypeToolUse,
 		ToolName:  toolCall.Function.Name,
-		ToolInput: json.RawMessage(toolCall.Function.Arguments),
+		ToolInput: json.RawMessage(args),
 	}
 }
 
@@ -654,13 +687,24 @@ func toLLMContents(msg openai.ChatCompletionMessage) []llm.Content {
 		return []llm.Content{toToolResultLLMContent(msg)}
 	}
 
+	// Reasoning comes first, as it does for Anthropic thinking blocks
+	if msg.ReasoningContent != "" {
+		contents = append(contents, llm.Content{
+			Type:     llm.ContentTypeThinking,
+			Thinking: msg.ReasoningContent,
+		})
+	}
+
 	// If there's text content, add it
 	if msg.Content != "" {
 		contents = append(contents, toRawLLMContent(msg.Content))
 	}
 
 	// If there are tool calls, add them
-	for _, tc := range msg.ToolCalls {
+	for i, tc := range msg.ToolCalls {
+		if tc.ID == "" && tc.Index == nil {
+			tc.Index = &i
+		}
 		contents = append(contents, toToolCallLLMContent(tc))
 	}
 
@@ -684,11 +728,12 @@ func (s *Service) toLLMUsage(au openai.Usage, headers http.Header) llm.Usage {
 		inc = uint64(au.PromptTokensDetails.CachedTokens)
 	}
 	out := uint64(au.CompletionTokens)
+	// prompt_tokens includes cached tokens; report them separately, as Anthropic does.
+	// OpenAI caches automatically, so there are no cache creation tokens.
 	u := llm.Usage{
-		InputTokens:              in,
-		CacheReadInputTokens:     inc,
-		CacheCreationInputTokens: in,
-		OutputTokens:             out,
+		InputTokens:          in - min(inc, in),
+		CacheReadInputTokens: inc,
+		OutputTokens:         out,
 	}
 	u.CostUSD = llm.CostUSDFromResponse(headers)
 	return u
@@ -780,13 +825,11 @@ func (s *Service) MaxImageDimension() int {
 	return 0 // No known limit
 }
 
-// Do sends a request to OpenAI using the go-openai package.
-func (s *Service) Do(ctx context.Context, ir *llm.Request) (*llm.Response, error) {
-	// Configure the OpenAI client
+// client returns an OpenAI API client for s and its base URL.
+func (s *Service) client() (*openai.Client, string) {
 	httpc := cmp.Or(s.HTTPC, http.DefaultClient)
 	model := cmp.Or(s.Model, DefaultModel)
 
-	// TODO: do this one during Service setup? maybe with a constructor instead?
 	config := openai.DefaultConfig(s.APIKey)
 	baseURL := cmp.Or(s.ModelURL, model.URL)
 	if baseURL != "" {
@@ -797,7 +840,12 @@ func (s *Service) Do(ctx context.Context, ir *llm.Request) (*llm.Response, error
 	}
 	config.HTTPClient = httpc
 
-	client := openai.NewClientWithConfig(config)
+	return openai.NewClientWithConfig(config), baseURL
+}
+
+// fromLLMRequest converts ir to a chat completion request.
+func (s *Service) fromLLMRequest(ir *llm.Request) openai.ChatCompletionRequest {
+	model := cmp.Or(s.Model, DefaultModel)
 
 	// Start with system messages if provided
 	var allMessages []openai.ChatCompletionMessage
@@ -808,8 +856,7 @@ func (s *Service) Do(ctx context.Context, ir *llm.Request) (*llm.Response, error
 
 	// Add regular and tool messages
 	for _, msg := range ir.Messages {
-		msgs := fromLLMMessage(msg)
-		allMessages = append(allMessages, msgs...)
+		allMessages = append(allMessages, s.fromLLMMessage(msg)...)
 	}
 
 	// Convert tools
@@ -818,14 +865,175 @@ func (s *Service) Do(ctx context.Context, ir *llm.Request) (*llm.Response, error
 		tools = append(tools, fromLLMTool(t))
 	}
 
-	// Create the OpenAI request
-	req := openai.ChatCompletionRequest{
+	// Models that don't reason reject reasoning_effort, so levels only
+	// apply to reasoning models and services given a thinking level.
+	level := llm.ThinkingLevelOff
+	if model.IsReasoningModel || s.ThinkingLevel != llm.ThinkingLevelOff {
+		level = ir.ThinkingLevelOr(s.ThinkingLevel)
+	}
+
+	return openai.ChatCompletionRequest{
 		Model:               model.ModelName,
 		Messages:            allMessages,
 		Tools:               tools,
 		ToolChoice:          fromLLMToolChoice(ir.ToolChoice), // TODO: make fromLLMToolChoice return an error when a perfect translation is not possible
 		MaxCompletionTokens: cmp.Or(s.MaxTokens, DefaultMaxTokens),
+		ReasoningEffort:     level.ThinkingEffort(),
+	}
+}
+
+// fromLLMMessage converts msg, keeping reasoning content only for
+// endpoints that accept it.
+func (s *Service) fromLLMMessage(msg llm.Message) []openai.ChatCompletionMessage {
+	msgs := fromLLMMessage(msg)
+	if !cmp.Or(s.Model, DefaultModel).ReasoningContent {
+		for i := range msgs {
+			msgs[i].ReasoningContent = ""
+		}
+	}
+	return msgs
+}
+
+// chatFileRequest is a chat completion request whose messages may include
+// "file" content parts, which go-openai does not model.
+type chatFileRequest struct {
+	openai.ChatCompletionRequest
+	Messages []any `json:"messages"`
+}
+
+// chatFileMessage is a user message carrying file content parts.
+type chatFileMessage struct {
+	Role    string         `json:"role"`
+	Content []chatFilePart `json:"content"`
+}
+
+type chatFilePart struct {
+	Type string `json:"type"` // "file"
+	File struct {
+		Filename string `json:"filename"`
+		FileData string `json:"file_data"` // data URL
+	} `json:"file"`
+}
+
+// fromLLMRequestWithFiles returns req, converted from ir, with PDFs sent
+// as file content parts rather than as their extracted text. Only OpenAI's own
+// models accept file parts, and tool messages take text only, so PDFs
+// follow the message they came from in a user message of their own, as
+// in the Responses API. It returns nil if the model can't take files or
+// ir has no PDFs.
+func (s *Service) fromLLMRequestWithFiles(ir *llm.Request, req openai.ChatCompletionRequest) *chatFileRequest {
+	if cmp.Or(s.Model, DefaultModel).URL != OpenAIURL {
+		return nil
+	}
+
+	var messages []any
+	var hasFiles bool
+	for _, m := range fromLLMSystem(ir.System) {
+		messages = append(messages, m)
+	}
+	for _, msg := range ir.Messages {
+		msg, files := detachPDFs(msg)
+		for _, m := range s.fromLLMMessage(msg) {
+			messages = append(messages, m)
+		}
+		if len(files) > 0 {
+			messages = append(messages, chatFileMessage{Role: "user", Content: files})
+			hasFiles = true
+		}
+	}
+	if !hasFiles {
+		return nil
+	}
+	return &chatFileRequest{ChatCompletionRequest: req, Messages: messages}
+}
+
+// detachPDFs returns msg without its PDFs, including those in tool
+// results, and the PDFs as file content parts. Each PDF is replaced by a
+// note pointing at the attachment.
+func detachPDFs(msg llm.Message) (llm.Message, []chatFilePart) {
+	if msg.Role != llm.MessageRoleUser {
+		return msg, nil
+	}
+	var files []chatFilePart
+	detach := func(contents []llm.Content) []llm.Content {
+		out := make([]llm.Content, len(contents))
+		for i, c := range contents {
+			out[i] = c
+			if c.Type != llm.ContentTypeDocument || c.MediaType != "application/pdf" {
+				continue
+			}
+			var part chatFilePart
+			part.Type = "file"
+			part.File.Filename = cmp.Or(c.Title, "document.pdf")
+			part.File.FileData = "data:" + c.MediaType + ";base64," + c.Data
+			files = append(files, part)
+			out[i] = llm.StringContent(fmt.Sprintf("[PDF %s attached below]", part.File.Filename))
+		}
+		return out
+	}
+	content := detach(msg.Content)
+	for i, c := range content {
+		if c.Type == llm.ContentTypeToolResult {
+			content[i].ToolResult = detach(c.ToolResult)
+		}
+	}
+	msg.Content = content
+	return msg, files
+}
+
+// createChatCompletion posts req to the chat completions endpoint. It is
+// used for requests go-openai cannot express, and reports errors the way
+// the go-openai client does.
+func (s *Service) createChatCompletion(ctx context.Context, url string, req *chatFileRequest) (openai.ChatCompletionResponse, error) {
+	var out openai.ChatCompletionResponse
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return out, err
 	}
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(payload))
+	if err != nil {
+		return out, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if s.APIKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+s.APIKey)
+	}
+	if s.Org != "" {
+		httpReq.Header.Set("OpenAI-Organization", s.Org)
+	}
+
+	resp, err := cmp.Or(s.HTTPC, http.DefaultClient).Do(httpReq)
+	if err != nil {
+		return out, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return out, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var errResp openai.ErrorResponse
+		if json.Unmarshal(body, &errResp) == nil && errResp.Error != nil {
+			errResp.Error.HTTPStatus = resp.Status
+			errResp.Error.HTTPStatusCode = resp.StatusCode
+			return out, errResp.Error
+		}
+		return out, &openai.RequestError{HTTPStatus: resp.Status, HTTPStatusCode: resp.StatusCode, Err: errors.New(string(body)), Body: body}
+	}
+	if err := json.Unmarshal(body, &out); err != nil {
+		return out, err
+	}
+	out.SetHeader(resp.Header)
+	return out, nil
+}
+
+// Do sends a request to OpenAI using the go-openai package.
+func (s *Service) Do(ctx context.Context, ir *llm.Request) (*llm.Response, error) {
+	model := cmp.Or(s.Model, DefaultModel)
+	client, baseURL := s.client()
+	req := s.fromLLMRequest(ir)
+	fileReq := s.fromLLMRequestWithFiles(ir, req)
+
 	// Construct the full URL for logging and debugging
 	fullURL := baseURL + "/chat/completions"
 
@@ -835,7 +1043,7 @@ func (s *Service) Do(ctx context.Context, ir *llm.Request) (*llm.Response, error
 	// retry loop
 	var errs error // accumulated errors across all attempts
 	for attempts := 0; ; attempts++ {
-		if attempts > 10 {
+		if attempts >= llm.MaxAttempts(ctx, 11) {
 			return nil, fmt.Errorf("openai request failed after %d attempts (url=%s, model=%s): %w", attempts, fullURL, model.ModelName, errs)
 		}
 		if attempts > 0 {
@@ -844,7 +1052,13 @@ func (s *Service) Do(ctx context.Context, ir *llm.Request) (*llm.Response, error
 			time.Sleep(sleep)
 		}
 
-		resp, err := client.CreateChatCompletion(ctx, req)
+		var resp openai.ChatCompletionResponse
+		var err error
+		if fileReq != nil {
+			resp, err = s.createChatCompletion(ctx, fullURL, fileReq)
+		} else {
+			resp, err = client.CreateChatCompletion(ctx, req)
+		}
 
 		// Handle successful response
 		if err == nil {
@@ -862,6 +1076,11 @@ func (s *Service) Do(ctx context.Context, ir *llm.Request) (*llm.Response, error
 		var apiErr *openai.APIError
 		if ok := errors.As(err, &apiErr); !ok {
 			// Not an OpenAI API error, return immediately with accumulated errors
+			var reqErr *openai.RequestError
+			if errors.As(err, &reqErr) && reqErr.HTTPStatusCode != 0 {
+				// An HTTP error without a JSON body, e.g. from a proxy
+				return nil, errors.Join(errs, llm.StatusErrorf(reqErr.HTTPStatusCode, "url=%s model=%s: %v", fullURL, model.ModelName, err))
+			}
 			return nil, errors.Join(errs, fmt.Errorf("url=%s model=%s: %w", fullURL, model.ModelName, err))
 		}
 
@@ -869,24 +1088,24 @@ func (s *Service) Do(ctx context.Context, ir *llm.Request) (*llm.Response, error
 		case apiErr.HTTPStatusCode >= 500:
 			// Server error, try again with backoff
 			slog.WarnContext(ctx, "openai_request_failed", "error", apiErr.Error(), "status_code", apiErr.HTTPStatusCode, "url", fullURL, "model", model.ModelName)
-			errs = errors.Join(errs, fmt.Errorf("status %d (url=%s, model=%s): %s", apiErr.HTTPStatusCode, fullURL, model.ModelName, apiErr.Error()))
+			errs = errors.Join(errs, llm.StatusErrorf(apiErr.HTTPStatusCode, "status %d (url=%s, model=%s): %s", apiErr.HTTPStatusCode, fullURL, model.ModelName, apiErr.Error()))
 			continue
 
 		case apiErr.HTTPStatusCode == 429:
 			// Rate limited, accumulate error and retry
 			slog.WarnContext(ctx, "openai_request_rate_limited", "error", apiErr.Error(), "url", fullURL, "model", model.ModelName)
-			errs = errors.Join(errs, fmt.Errorf("status %d (rate limited, url=%s, model=%s): %s", apiErr.HTTPStatusCode, fullURL, model.ModelName, apiErr.Error()))
+			errs = errors.Join(errs, llm.StatusErrorf(apiErr.HTTPStatusCode, "status %d (rate limited, url=%s, model=%s): %s", apiErr.HTTPStatusCode, fullURL, model.ModelName, apiErr.Error()))
 			continue
 
 		case apiErr.HTTPStatusCode >= 400 && apiErr.HTTPStatusCode < 500:
 			// Client error, probably unrecoverable
 			slog.WarnContext(ctx, "openai_request_failed", "error", apiErr.Error(), "status_code", apiErr.HTTPStatusCode, "url", fullURL, "model", model.ModelName)
-			return nil, errors.Join(errs, fmt.Errorf("status %d (url=%s, model=%s): %s", apiErr.HTTPStatusCode, fullURL, model.ModelName, apiErr.Error()))
+			return nil, errors.Join(errs, llm.StatusErrorf(apiErr.HTTPStatusCode, "status %d (url=%s, model=%s): %s", apiErr.HTTPStatusCode, fullURL, model.ModelName, apiErr.Error()))
 
 		default:
 			// Other error, accumulate and retry
 			slog.WarnContext(ctx, "openai_request_failed", "error", apiErr.Error(), "status_code", apiErr.HTTPStatusCode, "url", fullURL, "model", model.ModelName)
-			errs = errors.Join(errs, fmt.Errorf("status %d (url=%s, model=%s): %s", apiErr.HTTPStatusCode, fullURL, model.ModelName, apiErr.Error()))
+			errs = errors.Join(errs, llm.StatusErrorf(apiErr.HTTPStatusCode, "status %d (url=%s, model=%s): %s", apiErr.HTTPStatusCode, fullURL, model.ModelName, apiErr.Error()))
 			continue
 		}
 	}
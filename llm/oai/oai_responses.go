@@ -59,8 +59,11 @@ type responsesInputItem struct {
 }
 
 type responsesContent struct {
-	Type string `json:"type"` // "input_text", "output_text"
-	Text string `json:"text"`
+	Type     string `json:"type"` // "input_text", "output_text", "input_image", "input_file"
+	Text     string `json:"text,omitempty"`
+	ImageURL string `json:"image_url,omitempty"` // for input_image, a data URL
+	Filename string `json:"filename,omitempty"`  // for input_file
+	FileData string `json:"file_data,omitempty"` // for input_file, a data URL
 }
 
 type responsesTool struct {
@@ -133,11 +136,16 @@ func fromLLMMessageResponses(msg llm.Message) []responsesInputItem {
 	}
 
 	// Process tool results first - they need to come before the assistant message
+	// Function call outputs only carry text, so images and documents follow
+	// in a user message of their own.
+	var toolMedia []responsesContent
 	for _, tr := range toolResults {
 		// Collect all text from content objects
 		var texts []string
 		for _, result := range tr.ToolResult {
-			if strings.TrimSpace(result.Text) != "" {
+			if media, ok := fromLLMMediaResponses(result); ok {
+				toolMedia = append(toolMedia, media)
+			} else if strings.TrimSpace(result.Text) != "" {
 				texts = append(texts, result.Text)
 			}
 		}
@@ -158,6 +166,13 @@ func fromLLMMessageResponses(msg llm.Message) []responsesInputItem {
 			Output: cmp.Or(toolResultContent, " "),
 		})
 	}
+	if len(toolMedia) > 0 {
+		items = append(items, responsesInputItem{
+			Type:    "message",
+			Role:    "user",
+			Content: toolMedia,
+		})
+	}
 
 	// Process regular content
 	if len(regularContent) > 0 {
@@ -165,6 +180,10 @@ func fromLLMMessageResponses(msg llm.Message) []responsesInputItem {
 		var functionCalls []responsesInputItem
 
 		for _, c := range regularContent {
+			if media, ok := fromLLMMediaResponses(c); ok && msg.Role == llm.MessageRoleUser {
+				messageContent = append(messageContent, media)
+				continue
+			}
 			switch c.Type {
 			case llm.ContentTypeText:
 				if c.Text != "" {
@@ -208,6 +227,24 @@ func fromLLMMessageResponses(msg llm.Message) []responsesInputItem {
 	return items
 }
 
+// fromLLMMediaResponses converts image and document content to an input
+// item. PDFs are sent as files; other documents as their extracted text.
+func fromLLMMediaResponses(c llm.Content) (responsesContent, bool) {
+	switch {
+	case c.Type == llm.ContentTypeDocument && c.MediaType == "application/pdf":
+		return responsesContent{
+			Type:     "input_file",
+			Filename: cmp.Or(c.Title, "document.pdf"),
+			FileData: "data:" + c.MediaType + ";base64," + c.Data,
+		}, true
+	case c.Type == llm.ContentTypeDocument:
+		return responsesContent{Type: "input_text", Text: llm.DocumentText(c)}, true
+	case c.Type == llm.ContentTypeText && strings.HasPrefix(c.MediaType, "image/"):
+		return responsesContent{Type: "input_image", ImageURL: "data:" + c.MediaType + ";base64," + c.Data}, true
+	}
+	return responsesContent{}, false
+}
+
 // fromLLMToolResponses converts llm.Tool to Responses API tool format
 func fromLLMToolResponses(t *llm.Tool) responsesTool {
 	return responsesTool{
@@ -395,8 +432,8 @@ func (s *ResponsesService) Do(ctx context.Context, ir *llm.Request) (*llm.Respon
 	}
 
 	// Add reasoning if thinking is enabled
-	if s.ThinkingLevel != llm.ThinkingLevelOff {
-		effort := s.ThinkingLevel.ThinkingEffort()
+	if level := ir.ThinkingLevelOr(s.ThinkingLevel); level != llm.ThinkingLevelOff {
+		effort := level.ThinkingEffort()
 		if effort != "" {
 			req.Reasoning = &responsesReasoning{Effort: effort}
 		}
@@ -432,7 +469,7 @@ func (s *ResponsesService) Do(ctx context.Context, ir *llm.Request) (*llm.Respon
 	// retry loop
 	var errs error // accumulated errors across all attempts
 	for attempts := 0; ; attempts++ {
-		if attempts > 10 {
+		if attempts >= llm.MaxAttempts(ctx, 11) {
 			return nil, fmt.Errorf("responses request failed after %d attempts (url=%s, model=%s): %w", attempts, fullURL, model.ModelName, errs)
 		}
 		if attempts > 0 {
@@ -478,25 +515,25 @@ func (s *ResponsesService) Do(ctx context.Context, ir *llm.Request) (*llm.Respon
 				case httpResp.StatusCode >= 500:
 					// Server error, retry
 					slog.WarnContext(ctx, "responses_request_failed", "error", apiErr.Message, "status_code", httpResp.StatusCode, "url", fullURL, "model", model.ModelName)
-					errs = errors.Join(errs, fmt.Errorf("status %d (url=%s, model=%s): %s", httpResp.StatusCode, fullURL, model.ModelName, apiErr.Message))
+					errs = errors.Join(errs, llm.StatusErrorf(httpResp.StatusCode, "status %d (url=%s, model=%s): %s", httpResp.StatusCode, fullURL, model.ModelName, apiErr.Message))
 					continue
 
 				case httpResp.StatusCode == 429:
 					// Rate limited, retry
 					slog.WarnContext(ctx, "responses_request_rate_limited", "error", apiErr.Message, "url", fullURL, "model", model.ModelName)
-					errs = errors.Join(errs, fmt.Errorf("status %d (rate limited, url=%s, model=%s): %s", httpResp.StatusCode, fullURL, model.ModelName, apiErr.Message))
+					errs = errors.Join(errs, llm.StatusErrorf(httpResp.StatusCode, "status %d (rate limited, url=%s, model=%s): %s", httpResp.StatusCode, fullURL, model.ModelName, apiErr.Message))
 					continue
 
 				case httpResp.StatusCode >= 400 && httpResp.StatusCode < 500:
 					// Client error, probably unrecoverable
 					slog.WarnContext(ctx, "responses_request_failed", "error", apiErr.Message, "status_code", httpResp.StatusCode, "url", fullURL, "model", model.ModelName)
-					return nil, errors.Join(errs, fmt.Errorf("status %d (url=%s, model=%s): %s", httpResp.StatusCode, fullURL, model.ModelName, apiErr.Message))
+					return nil, errors.Join(errs, llm.StatusErrorf(httpResp.StatusCode, "status %d (url=%s, model=%s): %s", httpResp.StatusCode, fullURL, model.ModelName, apiErr.Message))
 				}
 			}
 
 			// No structured error, use the raw body
 			slog.WarnContext(ctx, "responses_request_failed", "status_code", httpResp.StatusCode, "url", fullURL, "model", model.ModelName, "body", string(body))
-			return nil, fmt.Errorf("status %d (url=%s, model=%s): %s", httpResp.StatusCode, fullURL, model.ModelName, string(body))
+			return nil, llm.StatusErrorf(httpResp.StatusCode, "status %d (url=%s, model=%s): %s", httpResp.StatusCode, fullURL, model.ModelName, string(body))
 		}
 
 		// Parse successful response
@@ -125,6 +125,40 @@ func TestFromLLMMessageResponses(t *testing.T) {
 	}
 }
 
+func TestFromLLMMessageResponsesMedia(t *testing.T) {
+	msg := llm.Message{
+		Role: llm.MessageRoleUser,
+		Content: []llm.Content{
+			{
+				Type:      llm.ContentTypeToolResult,
+				ToolUseID: "call_123",
+				ToolResult: []llm.Content{
+					{Type: llm.ContentTypeText, Text: "File: spec.pdf"},
+					{Type: llm.ContentTypeDocument, Title: "spec.pdf", MediaType: "application/pdf", Data: "JVBERi0="},
+				},
+			},
+			{Type: llm.ContentTypeText, Text: "Compare with this"},
+			{Type: llm.ContentTypeText, MediaType: "image/png", Data: "aW1n"},
+			llm.DocumentContent("notes.txt", "text/plain", []byte("notes")),
+		},
+	}
+	items := fromLLMMessageResponses(msg)
+	got, err := json.Marshal(items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `[` +
+		`{"type":"function_call_output","call_id":"call_123","output":"File: spec.pdf"},` +
+		`{"type":"message","role":"user","content":[{"type":"input_file","filename":"spec.pdf","file_data":"data:application/pdf;base64,JVBERi0="}]},` +
+		`{"type":"message","role":"user","content":[` +
+		`{"type":"input_text","text":"Compare with this"},` +
+		`{"type":"input_image","image_url":"data:image/png;base64,aW1n"},` +
+		`{"type":"input_text","text":"Document notes.txt (text/plain):\nnotes"}]}]`
+	if string(got) != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
 func TestFromLLMToolResponses(t *testing.T) {
 	tool := &llm.Tool{
 		Name:        "test_tool",
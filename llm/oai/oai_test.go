@@ -3,8 +3,10 @@ package oai
 import (
 	"context"
 	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -351,14 +353,14 @@ func TestFromLLMContent(t *testing.T) {
 		t.Errorf("fromLLMContent(toolResult) toolCalls length = %d, expected 0", len(toolCalls))
 	}
 
-	// Test default case (thinking content)
+	// Thinking content is never sent as visible text
 	thinkingContent := llm.Content{
 		Type: llm.ContentTypeThinking,
 		Text: "Thinking about the answer...",
 	}
 	text, toolCalls = fromLLMContent(thinkingContent)
-	if text != "Thinking about the answer..." {
-		t.Errorf("fromLLMContent(thinking) text = %q, expected %q", text, "Thinking about the answer...")
+	if text != "" {
+		t.Errorf("fromLLMContent(thinking) text = %q, expected empty string", text)
 	}
 	if len(toolCalls) != 0 {
 		t.Errorf("fromLLMContent(thinking) toolCalls length = %d, expected 0", len(toolCalls))
@@ -828,12 +830,18 @@ func TestToLLMUsage(t *testing.T) {
 		},
 	}
 	usage = service.toLLMUsage(openaiUsageWithDetails, nil)
-	if usage.InputTokens != 100 {
-		t.Errorf("toLLMUsage().InputTokens = %d, expected 100", usage.InputTokens)
+	if usage.InputTokens != 75 {
+		t.Errorf("toLLMUsage().InputTokens = %d, expected 75", usage.InputTokens)
 	}
 	if usage.CacheReadInputTokens != 25 {
 		t.Errorf("toLLMUsage().CacheReadInputTokens = %d, expected 25", usage.CacheReadInputTokens)
 	}
+	if usage.CacheCreationInputTokens != 0 {
+		t.Errorf("toLLMUsage().CacheCreationInputTokens = %d, expected 0", usage.CacheCreationInputTokens)
+	}
+	if usage.TotalInputTokens() != 100 {
+		t.Errorf("toLLMUsage().TotalInputTokens() = %d, expected 100", usage.TotalInputTokens())
+	}
 }
 
 func TestToLLMResponse(t *testing.T) {
@@ -1325,3 +1333,241 @@ func TestServiceDo(t *testing.T) {
 		t.Errorf("resp.Usage.OutputTokens = %d, expected 20", resp.Usage.OutputTokens)
 	}
 }
+
+func TestToLLMContentsReasoningAndParallelToolCalls(t *testing.T) {
+	msg := openai.ChatCompletionMessage{
+		Role:             "assistant",
+		ReasoningContent: "Need the weather in two cities.",
+		ToolCalls: []openai.ToolCall{
+			{Type: openai.ToolTypeFunction, Function: openai.FunctionCall{Name: "get_weather", Arguments: `{"city":"Paris"}`}},
+			{Type: openai.ToolTypeFunction, Function: openai.FunctionCall{Name: "get_weather", Arguments: `{"city":"Oslo"}`}},
+			{Type: openai.ToolTypeFunction, Function: openai.FunctionCall{Name: "get_time"}},
+		},
+	}
+	contents := toLLMContents(msg)
+	if len(contents) != 4 {
+		t.Fatalf("toLLMContents() length = %d, expected 4", len(contents))
+	}
+	if contents[0].Type != llm.ContentTypeThinking || contents[0].Thinking != "Need the weather in two cities." {
+		t.Errorf("contents[0] = %+v, expected thinking content", contents[0])
+	}
+	if contents[1].ID == contents[2].ID {
+		t.Errorf("parallel calls to the same tool share ID %q", contents[1].ID)
+	}
+	if string(contents[3].ToolInput) != "{}" {
+		t.Errorf("empty arguments = %q, expected {}", contents[3].ToolInput)
+	}
+}
+
+func TestFromLLMMessageReasoningContent(t *testing.T) {
+	msg := llm.Message{
+		Role: llm.MessageRoleAssistant,
+		Content: []llm.Content{
+			{Type: llm.ContentTypeThinking, Thinking: "Check the file first."},
+			{Type: llm.ContentTypeText, Text: "Reading it now."},
+			{Type: llm.ContentTypeToolUse, ID: "call_1", ToolName: "read", ToolInput: json.RawMessage(`{}`)},
+		},
+	}
+	msgs := fromLLMMessage(msg)
+	if len(msgs) != 1 {
+		t.Fatalf("fromLLMMessage() length = %d, expected 1", len(msgs))
+	}
+	if msgs[0].ReasoningContent != "Check the file first." {
+		t.Errorf("ReasoningContent = %q, expected %q", msgs[0].ReasoningContent, "Check the file first.")
+	}
+	if msgs[0].Content != "Reading it now." {
+		t.Errorf("Content = %q, expected %q", msgs[0].Content, "Reading it now.")
+	}
+}
+
+func TestFromLLMMessageDocument(t *testing.T) {
+	doc := llm.DocumentContent("notes.txt", "text/plain", []byte("first\nsecond"))
+	msg := llm.Message{
+		Role: llm.MessageRoleUser,
+		Content: []llm.Content{
+			{Type: llm.ContentTypeToolResult, ToolUseID: "call_1", ToolResult: []llm.Content{doc}},
+			{Type: llm.ContentTypeText, Text: "Summarize it."},
+			doc,
+		},
+	}
+	msgs := fromLLMMessage(msg)
+	if len(msgs) != 2 {
+		t.Fatalf("fromLLMMessage() length = %d, expected 2", len(msgs))
+	}
+	want := "Document notes.txt (text/plain):\nfirst\nsecond"
+	if msgs[0].Content != want {
+		t.Errorf("tool result content = %q, expected %q", msgs[0].Content, want)
+	}
+	if msgs[1].Content != "Summarize it.\n"+want {
+		t.Errorf("user content = %q, expected %q", msgs[1].Content, "Summarize it.\n"+want)
+	}
+}
+
+func TestServiceDoPDF(t *testing.T) {
+	var got map[string]any
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = nil
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		if status != http.StatusOK {
+			io.WriteString(w, `{"error":{"message":"bad file","type":"invalid_request_error"}}`)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(openai.ChatCompletionResponse{
+			Choices: []openai.ChatCompletionChoice{{
+				Message:      openai.ChatCompletionMessage{Role: "assistant", Content: "Read it."},
+				FinishReason: "stop",
+			}},
+		})
+	}))
+	defer server.Close()
+
+	req := &llm.Request{Messages: []llm.Message{
+		llm.UserStringMessage("Read report.pdf"),
+		{Role: llm.MessageRoleAssistant, Content: []llm.Content{
+			{Type: llm.ContentTypeToolUse, ID: "call_1", ToolName: "read", ToolInput: json.RawMessage(`{}`)},
+		}},
+		{Role: llm.MessageRoleUser, Content: []llm.Content{
+			{Type: llm.ContentTypeToolResult, ToolUseID: "call_1", ToolResult: []llm.Content{
+				llm.DocumentContent("report.pdf", "application/pdf", []byte("%PDF-1.4\n%%EOF\n")),
+			}},
+		}},
+	}}
+	messages := func() []map[string]any {
+		var out []map[string]any
+		for _, m := range got["messages"].([]any) {
+			out = append(out, m.(map[string]any))
+		}
+		return out
+	}
+
+	// OpenAI's models get the PDF as a file part after the tool message
+	svc := &Service{APIKey: "k", Model: GPT41, ModelURL: server.URL}
+	resp, err := svc.Do(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content[0].Text != "Read it." {
+		t.Errorf("response = %+v", resp)
+	}
+	msgs := messages()
+	if len(msgs) != 4 || msgs[2]["role"] != "tool" || msgs[3]["role"] != "user" {
+		t.Fatalf("messages = %v", msgs)
+	}
+	if msgs[2]["content"] != "[PDF report.pdf attached below]" {
+		t.Errorf("tool content = %v", msgs[2]["content"])
+	}
+	part := msgs[3]["content"].([]any)[0].(map[string]any)
+	file, _ := part["file"].(map[string]any)
+	if part["type"] != "file" || file["filename"] != "report.pdf" || !strings.HasPrefix(file["file_data"].(string), "data:application/pdf;base64,") {
+		t.Errorf("file part = %v", part)
+	}
+
+	// Errors are reported as the go-openai client reports them
+	status = http.StatusBadRequest
+	if _, err := svc.Do(context.Background(), req); llm.HTTPStatus(err) != http.StatusBadRequest || !strings.Contains(err.Error(), "bad file") {
+		t.Errorf("err = %v", err)
+	}
+	status = http.StatusOK
+
+	// Other providers get the extracted text
+	svc = &Service{APIKey: "k", Model: Model{ModelName: "other", URL: FireworksURL}, ModelURL: server.URL}
+	if _, err := svc.Do(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	msgs = messages()
+	if len(msgs) != 3 || !strings.HasPrefix(msgs[2]["content"].(string), "Document report.pdf (application/pdf)") {
+		t.Errorf("messages = %v", msgs)
+	}
+}
+
+func TestServiceDoReasoning(t *testing.T) {
+	var got []openai.ChatCompletionRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req openai.ChatCompletionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		got = append(got, req)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(openai.ChatCompletionResponse{
+			ID: "chatcmpl-test",
+			Choices: []openai.ChatCompletionChoice{{
+				Message: openai.ChatCompletionMessage{
+					Role:             "assistant",
+					Content:          "Done.",
+					ReasoningContent: "Thought about it.",
+				},
+				FinishReason: "stop",
+			}},
+		})
+	}))
+	defer server.Close()
+
+	req := &llm.Request{
+		Messages: []llm.Message{
+			{Role: llm.MessageRoleUser, Content: []llm.Content{{Type: llm.ContentTypeText, Text: "Hi"}}},
+			{Role: llm.MessageRoleAssistant, Content: []llm.Content{
+				{Type: llm.ContentTypeThinking, Thinking: "Earlier reasoning."},
+				{Type: llm.ContentTypeText, Text: "Hello."},
+			}},
+			{Role: llm.MessageRoleUser, Content: []llm.Content{{Type: llm.ContentTypeText, Text: "Again"}}},
+		},
+	}
+
+	for _, tt := range []struct {
+		name          string
+		model         Model
+		wantReasoning string
+	}{
+		{"preserved", Model{ModelName: "glm", ReasoningContent: true}, "Earlier reasoning."},
+		{"dropped", Model{ModelName: "gpt"}, ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got = nil
+			svc := &Service{APIKey: "k", Model: tt.model, ModelURL: server.URL, ThinkingLevel: llm.ThinkingLevelHigh}
+			resp, err := svc.Do(context.Background(), req)
+			if err != nil {
+				t.Fatalf("Do() error = %v", err)
+			}
+			if len(got) != 1 {
+				t.Fatalf("expected 1 request, got %d", len(got))
+			}
+			if got[0].ReasoningEffort != "high" {
+				t.Errorf("ReasoningEffort = %q, expected high", got[0].ReasoningEffort)
+			}
+			if rc := got[0].Messages[1].ReasoningContent; rc != tt.wantReasoning {
+				t.Errorf("assistant ReasoningContent = %q, expected %q", rc, tt.wantReasoning)
+			}
+			if resp.Content[0].Type != llm.ContentTypeThinking || resp.Content[0].Thinking != "Thought about it." {
+				t.Errorf("resp.Content[0] = %+v, expected thinking content", resp.Content[0])
+			}
+		})
+	}
+}
+
+func TestFromLLMRequestReasoningEffort(t *testing.T) {
+	high := llm.ThinkingLevelHigh
+	for _, tt := range []struct {
+		name  string
+		svc   *Service
+		level *llm.ThinkingLevel
+		want  string
+	}{
+		{"reasoning model, per-request level", &Service{Model: Model{ModelName: "glm", IsReasoningModel: true}}, &high, "high"},
+		{"reasoning model, no level", &Service{Model: Model{ModelName: "glm", IsReasoningModel: true}}, nil, ""},
+		{"service level", &Service{Model: Model{ModelName: "glm", IsReasoningModel: true}, ThinkingLevel: llm.ThinkingLevelLow}, nil, "low"},
+		{"plain model ignores per-request level", &Service{Model: Model{ModelName: "qwen"}}, &high, ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			req := tt.svc.fromLLMRequest(&llm.Request{Messages: []llm.Message{llm.UserStringMessage("hi")}, ThinkingLevel: tt.level})
+			if req.ReasoningEffort != tt.want {
+				t.Errorf("ReasoningEffort = %q, want %q", req.ReasoningEffort, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,470 @@
+// Package ollama implements llm.Service using Ollama's native chat API,
+// for running against local models without an API key.
+package ollama
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tgruben-circuit/percy/llm"
+)
+
+const (
+	DefaultURL = "http://localhost:11434"
+
+	// DefaultContextWindow is used when the server does not report one.
+	DefaultContextWindow = 8192
+
+	// DefaultMaxContextWindow caps a context length taken from the model's
+	// metadata when MaxContextWindow is unset. That length is the model's
+	// architectural maximum, and loading a model with it can exhaust memory
+	// on local hardware. A num_ctx set in the Modelfile is used as is.
+	DefaultMaxContextWindow = 32768
+
+	// discoveryTimeout bounds the /api/show request.
+	discoveryTimeout = 5 * time.Second
+)
+
+// Service provides completions from an Ollama server.
+// Fields should not be altered concurrently with calling any method on Service.
+type Service struct {
+	HTTPC            *http.Client      // defaults to http.DefaultClient if nil
+	URL              string            // server base URL, defaults to DefaultURL
+	Model            string            // model name, e.g. "qwen3-coder:30b"; must be non-empty
+	MaxTokens        int               // num_predict; 0 uses the server default
+	MaxContextWindow int               // caps the discovered context window (optional)
+	ThinkingLevel    llm.ThinkingLevel // default level; any level other than off sends "think": true to models that can think
+
+	discoverMu    sync.Mutex // serializes discovery
+	mu            sync.Mutex // guards contextWindow and thinks
+	contextWindow int        // discovered window, or 0 before discovery
+	thinks        bool       // whether the model reports the thinking capability
+}
+
+var _ llm.Service = (*Service)(nil)
+
+type chatRequest struct {
+	Model    string         `json:"model"`
+	Messages []message      `json:"messages"`
+	Tools    []tool         `json:"tools,omitempty"`
+	Stream   bool           `json:"stream"`
+	Think    bool           `json:"think,omitempty"`
+	Options  map[string]any `json:"options,omitempty"`
+}
+
+type message struct {
+	Role      string     `json:"role"`
+	Content   string     `json:"content"`
+	Thinking  string     `json:"thinking,omitempty"`
+	Images    []string   `json:"images,omitempty"`
+	ToolCalls []toolCall `json:"tool_calls,omitempty"`
+	ToolName  string     `json:"tool_name,omitempty"`
+}
+
+type toolCall struct {
+	ID       string       `json:"id,omitempty"`
+	Function toolFunction `json:"function"`
+}
+
+type toolFunction struct {
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+type tool struct {
+	Type     string `json:"type"`
+	Function struct {
+		Name        string          `json:"name"`
+		Description string          `json:"description"`
+		Parameters  json.RawMessage `json:"parameters"`
+	} `json:"function"`
+}
+
+type chatResponse struct {
+	Model           string  `json:"model"`
+	Message         message `json:"message"`
+	Done            bool    `json:"done"`
+	DoneReason      string  `json:"done_reason"`
+	PromptEvalCount uint64  `json:"prompt_eval_count"`
+	EvalCount       uint64  `json:"eval_count"`
+	Error           string  `json:"error"`
+}
+
+type showResponse struct {
+	Parameters   string         `json:"parameters"`
+	ModelInfo    map[string]any `json:"model_info"`
+	Capabilities []string       `json:"capabilities"`
+}
+
+func (s *Service) baseURL() string {
+	return strings.TrimSuffix(cmp.Or(s.URL, DefaultURL), "/")
+}
+
+// fromLLMMessage converts a message to Ollama's format. Tool results become
+// separate "tool" messages, which Ollama matches to calls by tool name.
+func fromLLMMessage(msg llm.Message, toolNames map[string]string) ([]message, error) {
+	m := message{Role: "user"}
+	if msg.Role == llm.MessageRoleAssistant {
+		m.Role = "assistant"
+	}
+	var results []message
+	var texts []string
+	for _, c := range msg.Content {
+		switch c.Type {
+		case llm.ContentTypeText:
+			if c.MediaType != "" {
+				m.Images = append(m.Images, c.Data)
+			} else if c.Text != "" {
+				texts = append(texts, c.Text)
+			}
+		case llm.ContentTypeDocument:
+			texts = append(texts, llm.DocumentText(c))
+		case llm.ContentTypeThinking:
+			m.Thinking += c.Thinking
+		case llm.ContentTypeToolUse:
+			args := c.ToolInput
+			if len(bytes.TrimSpace(args)) == 0 {
+				args = json.RawMessage("{}")
+			}
+			if !json.Valid(args) {
+				return nil, fmt.Errorf("tool %s: invalid input JSON", c.ToolName)
+			}
+			toolNames[c.ID] = c.ToolName
+			m.ToolCalls = append(m.ToolCalls, toolCall{ID: c.ID, Function: toolFunction{Name: c.ToolName, Arguments: args}})
+		case llm.ContentTypeToolResult:
+			r := message{Role: "tool", ToolName: toolNames[c.ToolUseID]}
+			var out []string
+			for _, tr := range c.ToolResult {
+				if tr.Type == llm.ContentTypeDocument {
+					out = append(out, llm.DocumentText(tr))
+				} else if tr.MediaType != "" {
+					r.Images = append(r.Images, tr.Data)
+				} else if tr.Text != "" {
+					out = append(out, tr.Text)
+				}
+			}
+			r.Content = strings.Join(out, "\n")
+			if c.ToolError {
+				r.Content = "error: " + r.Content
+			}
+			results = append(results, r)
+		}
+	}
+	m.Content = strings.Join(texts, "\n")
+
+	var msgs []message
+	msgs = append(msgs, results...)
+	if m.Content != "" || m.Thinking != "" || len(m.Images) > 0 || len(m.ToolCalls) > 0 || len(results) == 0 {
+		msgs = append(msgs, m)
+	}
+	return msgs, nil
+}
+
+func fromLLMTool(t *llm.Tool) tool {
+	var out tool
+	out.Type = "function"
+	out.Function.Name = t.Name
+	out.Function.Description = t.Description
+	out.Function.Parameters = t.InputSchema
+	if len(out.Function.Parameters) == 0 {
+		out.Function.Parameters = llm.EmptySchema()
+	}
+	return out
+}
+
+func (s *Service) buildRequest(ir *llm.Request, numCtx int, thinks bool) (*chatRequest, error) {
+	req := &chatRequest{
+		Model: s.Model,
+		// Models that can't think reject "think", so levels only apply
+		// to models that report the thinking capability.
+		Think: thinks && ir.ThinkingLevelOr(s.ThinkingLevel) != llm.ThinkingLevelOff,
+		// Ollama loads models with a small context unless asked for more
+		Options: map[string]any{"num_ctx": numCtx},
+	}
+	if s.MaxTokens > 0 {
+		req.Options["num_predict"] = s.MaxTokens
+	}
+
+	var system []string
+	for _, sys := range ir.System {
+		if sys.Text != "" {
+			system = append(system, sys.Text)
+		}
+	}
+	if len(system) > 0 {
+		req.Messages = append(req.Messages, message{Role: "system", Content: strings.Join(system, "\n")})
+	}
+
+	toolNames := make(map[string]string)
+	for _, msg := range ir.Messages {
+		msgs, err := fromLLMMessage(msg, toolNames)
+		if err != nil {
+			return nil, err
+		}
+		req.Messages = append(req.Messages, msgs...)
+	}
+
+	if ir.ToolChoice == nil || ir.ToolChoice.Type != llm.ToolChoiceTypeNone {
+		for _, t := range ir.Tools {
+			req.Tools = append(req.Tools, fromLLMTool(t))
+		}
+	}
+	return req, nil
+}
+
+func toLLMResponse(r *chatResponse, start, end time.Time) *llm.Response {
+	var contents []llm.Content
+	if r.Message.Thinking != "" {
+		contents = append(contents, llm.Content{Type: llm.ContentTypeThinking, Thinking: r.Message.Thinking})
+	}
+	if r.Message.Content != "" {
+		contents = append(contents, llm.Content{Type: llm.ContentTypeText, Text: r.Message.Content})
+	}
+	for i, tc := range r.Message.ToolCalls {
+		args := tc.Function.Arguments
+		if len(bytes.TrimSpace(args)) == 0 || string(args) == "null" {
+			args = json.RawMessage("{}")
+		}
+		// Older servers don't assign tool call IDs
+		id := cmp.Or(tc.ID, fmt.Sprintf("ollama_%d_%d_%s", start.UnixNano(), i, tc.Function.Name))
+		contents = append(contents, llm.Content{
+			ID:        id,
+			Type:      llm.ContentTypeToolUse,
+			ToolName:  tc.Function.Name,
+			ToolInput: args,
+		})
+	}
+	if len(contents) == 0 {
+		contents = append(contents, llm.Content{Type: llm.ContentTypeText})
+	}
+
+	stop := llm.StopReasonEndTurn
+	switch {
+	case len(r.Message.ToolCalls) > 0:
+		stop = llm.StopReasonToolUse
+	case r.DoneReason == "length":
+		stop = llm.StopReasonMaxTokens
+	}
+
+	return &llm.Response{
+		Role:       llm.MessageRoleAssistant,
+		Model:      r.Model,
+		Content:    contents,
+		StopReason: stop,
+		Usage: llm.Usage{
+			InputTokens:  r.PromptEvalCount,
+			OutputTokens: r.EvalCount,
+			Model:        r.Model,
+			StartTime:    &start,
+			EndTime:      &end,
+		},
+		StartTime: &start,
+		EndTime:   &end,
+	}
+}
+
+// Do sends a request to the Ollama server's /api/chat endpoint.
+func (s *Service) Do(ctx context.Context, ir *llm.Request) (*llm.Response, error) {
+	if s.Model == "" {
+		return nil, errors.New("ollama: model name is required")
+	}
+	numCtx, thinks := s.discover(ctx)
+	req, err := s.buildRequest(ir, numCtx, thinks)
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("ollama: marshal request: %w", err)
+	}
+	url := s.baseURL() + "/api/chat"
+	httpc := cmp.Or(s.HTTPC, http.DefaultClient)
+
+	backoff := []time.Duration{1 * time.Second, 2 * time.Second, 5 * time.Second}
+	var errs error
+	for attempts := 0; ; attempts++ {
+		if attempts >= llm.MaxAttempts(ctx, 4) {
+			return nil, fmt.Errorf("ollama request failed after %d attempts (url=%s, model=%s): %w", attempts, url, s.Model, errs)
+		}
+		if attempts > 0 {
+			sleep := backoff[min(attempts, len(backoff)-1)] + rand.N(time.Second)
+			slog.WarnContext(ctx, "ollama request sleep before retry", "sleep", sleep, "attempts", attempts)
+			select {
+			case <-time.After(sleep):
+			case <-ctx.Done():
+				return nil, errors.Join(errs, ctx.Err())
+			}
+		}
+
+		start := time.Now()
+		httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		httpReq.Header.Set("Content-Type", "application/json")
+		resp, err := httpc.Do(httpReq)
+		if err != nil {
+			// Usually the server isn't running; retrying won't help
+			return nil, errors.Join(errs, fmt.Errorf("url=%s model=%s: %w", url, s.Model, err))
+		}
+		respBody, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			errs = errors.Join(errs, fmt.Errorf("read response: %w", err))
+			continue
+		}
+
+		// Errors carry a JSON body too, as {"error": "..."}
+		var cr chatResponse
+		decodeErr := json.Unmarshal(respBody, &cr)
+		switch {
+		case resp.StatusCode == http.StatusOK && decodeErr != nil:
+			return nil, fmt.Errorf("ollama: decode response: %w", decodeErr)
+		case resp.StatusCode == http.StatusOK && cr.Error == "":
+			return toLLMResponse(&cr, start, time.Now()), nil
+		case resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests:
+			slog.WarnContext(ctx, "ollama_request_failed", "status_code", resp.StatusCode, "error", cr.Error, "url", url, "model", s.Model)
+			errs = errors.Join(errs, llm.StatusErrorf(resp.StatusCode, "status %d (url=%s, model=%s): %s", resp.StatusCode, url, s.Model, cmp.Or(cr.Error, string(respBody))))
+			continue
+		default:
+			return nil, errors.Join(errs, llm.StatusErrorf(resp.StatusCode, "status %d (url=%s, model=%s): %s", resp.StatusCode, url, s.Model, cmp.Or(cr.Error, string(respBody))))
+		}
+	}
+}
+
+// TokenContextWindow returns the model's context window. It does not block:
+// the window is discovered by the first request, and until then
+// DefaultContextWindow (capped by MaxContextWindow) is reported.
+func (s *Service) TokenContextWindow() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.contextWindow > 0 {
+		return s.contextWindow
+	}
+	return s.capContextWindow(DefaultContextWindow, true)
+}
+
+// discover returns the model's context window and whether it can think, as
+// reported by the server's /api/show. A num_ctx set in the model's Modelfile
+// is preferred over the architectural context length. The result is cached.
+// If discovery fails, DefaultContextWindow is cached instead, unless ctx
+// ended first, in which case the next request retries.
+func (s *Service) discover(ctx context.Context) (numCtx int, thinks bool) {
+	s.discoverMu.Lock()
+	defer s.discoverMu.Unlock()
+	s.mu.Lock()
+	n, thinks := s.contextWindow, s.thinks
+	s.mu.Unlock()
+	if n > 0 {
+		return n, thinks
+	}
+
+	showCtx, cancel := context.WithTimeout(ctx, discoveryTimeout)
+	defer cancel()
+	var fromModelfile bool
+	show, err := s.fetchShow(showCtx)
+	if err == nil {
+		thinks = slices.Contains(show.Capabilities, "thinking")
+		n, fromModelfile, err = contextWindowFromShow(show)
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return s.capContextWindow(DefaultContextWindow, true), false
+		}
+		slog.WarnContext(ctx, "ollama: context window discovery failed", "model", s.Model, "url", s.baseURL(), "error", err)
+		n, fromModelfile = DefaultContextWindow, true
+	}
+	n = s.capContextWindow(n, fromModelfile)
+	s.mu.Lock()
+	s.contextWindow, s.thinks = n, thinks
+	s.mu.Unlock()
+	return n, thinks
+}
+
+// capContextWindow applies MaxContextWindow, or DefaultMaxContextWindow to
+// windows that did not come from the Modelfile.
+func (s *Service) capContextWindow(n int, fromModelfile bool) int {
+	switch {
+	case s.MaxContextWindow > 0:
+		return min(n, s.MaxContextWindow)
+	case !fromModelfile:
+		return min(n, DefaultMaxContextWindow)
+	}
+	return n
+}
+
+func (s *Service) fetchShow(ctx context.Context) (*showResponse, error) {
+	body, err := json.Marshal(map[string]string{"model": s.Model})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.baseURL()+"/api/show", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := cmp.Or(s.HTTPC, http.DefaultClient).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("status %d: %s", resp.StatusCode, bytes.TrimSpace(b))
+	}
+	var show showResponse
+	if err := json.NewDecoder(resp.Body).Decode(&show); err != nil {
+		return nil, err
+	}
+	return &show, nil
+}
+
+// contextWindowFromShow extracts the context window from an /api/show
+// response and reports whether it was set in the Modelfile.
+func contextWindowFromShow(show *showResponse) (int, bool, error) {
+	// Parameters is Modelfile syntax, one "name value" per line
+	sc := bufio.NewScanner(strings.NewReader(show.Parameters))
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 2 && fields[0] == "num_ctx" {
+			if n, err := strconv.Atoi(fields[1]); err == nil && n > 0 {
+				return n, true, nil
+			}
+		}
+	}
+	// model_info keys are prefixed by architecture, e.g. "llama.context_length"
+	for k, v := range show.ModelInfo {
+		if n, ok := v.(float64); ok && n > 0 && strings.HasSuffix(k, ".context_length") {
+			return int(n), false, nil
+		}
+	}
+	return 0, false, errors.New("no context length reported")
+}
+
+// MaxImageDimension returns the maximum allowed image dimension.
+func (s *Service) MaxImageDimension() int {
+	return 0 // No known limit
+}
+
+// ConfigDetails returns configuration information for logging
+func (s *Service) ConfigDetails() map[string]string {
+	return map[string]string{
+		"base_url":   s.baseURL(),
+		"model_name": s.Model,
+		"full_url":   s.baseURL() + "/api/chat",
+	}
+}
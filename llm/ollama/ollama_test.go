@@ -0,0 +1,209 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tgruben-circuit/percy/llm"
+)
+
+// fakeOllama is a local stand-in for an Ollama server.
+type fakeOllama struct {
+	show     string
+	chat     string
+	lastChat chatRequest
+	shows    atomic.Int32
+}
+
+func (f *fakeOllama) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	switch r.URL.Path {
+	case "/api/show":
+		f.shows.Add(1)
+		io.WriteString(w, f.show)
+	case "/api/chat":
+		if err := json.Unmarshal(body, &f.lastChat); err != nil {
+			http.Error(w, `{"error":"bad request"}`, http.StatusBadRequest)
+			return
+		}
+		io.WriteString(w, f.chat)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestServiceDo(t *testing.T) {
+	fake := &fakeOllama{
+		show: `{"parameters":"stop \"<|im_end|>\"","model_info":{"general.architecture":"qwen3","qwen3.context_length":262144}}`,
+		chat: `{"model":"qwen3-coder:30b","message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"bash","arguments":{"command":"ls"}}}]},"done":true,"done_reason":"stop","prompt_eval_count":120,"eval_count":15}`,
+	}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	svc := &Service{URL: srv.URL, Model: "qwen3-coder:30b", MaxContextWindow: 65536}
+	resp, err := svc.Do(context.Background(), &llm.Request{
+		System: []llm.SystemContent{{Text: "be brief"}},
+		Messages: []llm.Message{
+			llm.UserStringMessage("list files"),
+			{Role: llm.MessageRoleAssistant, Content: []llm.Content{
+				{ID: "call_1", Type: llm.ContentTypeToolUse, ToolName: "bash", ToolInput: json.RawMessage(`{"command":"pwd"}`)},
+			}},
+			{Role: llm.MessageRoleUser, Content: []llm.Content{
+				{Type: llm.ContentTypeToolResult, ToolUseID: "call_1", ToolResult: []llm.Content{{Type: llm.ContentTypeText, Text: "/tmp"}}},
+			}},
+		},
+		Tools: []*llm.Tool{{Name: "bash", Description: "run a command", InputSchema: llm.MustSchema(`{"type":"object","properties":{"command":{"type":"string"}}}`)}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := fake.lastChat
+	if req.Stream || req.Options["num_ctx"] != float64(65536) {
+		t.Errorf("stream=%v num_ctx=%v, want false and the capped window", req.Stream, req.Options["num_ctx"])
+	}
+	roles := ""
+	for _, m := range req.Messages {
+		roles += m.Role + " "
+	}
+	if roles != "system user assistant tool " {
+		t.Errorf("message roles = %q", roles)
+	}
+	if tm := req.Messages[3]; tm.ToolName != "bash" || tm.Content != "/tmp" {
+		t.Errorf("tool result message = %+v", tm)
+	}
+	if len(req.Tools) != 1 || req.Tools[0].Function.Name != "bash" {
+		t.Errorf("tools = %+v", req.Tools)
+	}
+
+	if resp.StopReason != llm.StopReasonToolUse || len(resp.Content) != 1 {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+	c := resp.Content[0]
+	if c.Type != llm.ContentTypeToolUse || c.ToolName != "bash" || c.ID == "" || string(c.ToolInput) != `{"command":"ls"}` {
+		t.Errorf("tool use = %+v", c)
+	}
+	if resp.Usage.InputTokens != 120 || resp.Usage.OutputTokens != 15 {
+		t.Errorf("usage = %+v", resp.Usage)
+	}
+
+	// Discovery is cached.
+	if _, err := svc.Do(context.Background(), &llm.Request{Messages: []llm.Message{llm.UserStringMessage("hi")}}); err != nil {
+		t.Fatal(err)
+	}
+	if n := fake.shows.Load(); n != 1 {
+		t.Errorf("/api/show called %d times, want 1", n)
+	}
+}
+
+func TestServiceDefaultContextCap(t *testing.T) {
+	fake := &fakeOllama{
+		show: `{"model_info":{"general.architecture":"qwen3","qwen3.context_length":262144}}`,
+		chat: `{"message":{"role":"assistant","content":"hi"},"done":true,"done_reason":"stop"}`,
+	}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	svc := &Service{URL: srv.URL, Model: "qwen3-coder:30b"}
+	// TokenContextWindow must not block on discovery.
+	if got := svc.TokenContextWindow(); got != DefaultContextWindow || fake.shows.Load() != 0 {
+		t.Errorf("TokenContextWindow() = %d after %d /api/show calls, want %d without discovery", got, fake.shows.Load(), DefaultContextWindow)
+	}
+	if _, err := svc.Do(context.Background(), &llm.Request{Messages: []llm.Message{llm.UserStringMessage("hi")}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := fake.lastChat.Options["num_ctx"]; got != float64(DefaultMaxContextWindow) {
+		t.Errorf("num_ctx = %v, want %d", got, DefaultMaxContextWindow)
+	}
+	if got := svc.TokenContextWindow(); got != DefaultMaxContextWindow {
+		t.Errorf("TokenContextWindow() = %d, want %d", got, DefaultMaxContextWindow)
+	}
+}
+
+func TestServiceDoError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, `{"error":"model \"nope\" not found, try pulling it first"}`)
+	}))
+	defer srv.Close()
+
+	svc := &Service{URL: srv.URL, Model: "nope"}
+	_, err := svc.Do(context.Background(), &llm.Request{Messages: []llm.Message{llm.UserStringMessage("hi")}})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if got := svc.TokenContextWindow(); got != DefaultContextWindow {
+		t.Errorf("TokenContextWindow() = %d, want default %d", got, DefaultContextWindow)
+	}
+}
+
+func TestContextWindowFromShow(t *testing.T) {
+	tests := []struct {
+		show          showResponse
+		want          int
+		fromModelfile bool
+	}{
+		{showResponse{Parameters: "num_ctx 16384\nstop \"x\"", ModelInfo: map[string]any{"llama.context_length": 131072.0}}, 16384, true},
+		{showResponse{ModelInfo: map[string]any{"general.architecture": "llama", "llama.context_length": 131072.0}}, 131072, false},
+		{showResponse{}, 0, false},
+	}
+	for _, tt := range tests {
+		got, fromModelfile, err := contextWindowFromShow(&tt.show)
+		if got != tt.want || fromModelfile != tt.fromModelfile || (tt.want == 0) != (err != nil) {
+			t.Errorf("contextWindowFromShow(%+v) = %d, %v, %v; want %d, %v", tt.show, got, fromModelfile, err, tt.want, tt.fromModelfile)
+		}
+	}
+}
+
+func TestToLLMResponseThinking(t *testing.T) {
+	resp := toLLMResponse(&chatResponse{
+		Message:    message{Role: "assistant", Thinking: "hmm", Content: "done"},
+		DoneReason: "length",
+	}, time.Now(), time.Now())
+	if len(resp.Content) != 2 || resp.Content[0].Type != llm.ContentTypeThinking || resp.Content[1].Text != "done" {
+		t.Errorf("content = %+v", resp.Content)
+	}
+	if resp.StopReason != llm.StopReasonMaxTokens {
+		t.Errorf("stop reason = %v", resp.StopReason)
+	}
+}
+
+func TestServiceThinking(t *testing.T) {
+	off, high := llm.ThinkingLevelOff, llm.ThinkingLevelHigh
+	for _, tt := range []struct {
+		name         string
+		capabilities string
+		service      llm.ThinkingLevel
+		level        *llm.ThinkingLevel
+		want         bool
+	}{
+		{"thinking model", `["completion","tools","thinking"]`, llm.ThinkingLevelMedium, nil, true},
+		{"turned off", `["completion","tools","thinking"]`, llm.ThinkingLevelMedium, &off, false},
+		{"turned on per request", `["completion","tools","thinking"]`, llm.ThinkingLevelOff, &high, true},
+		{"model cannot think", `["completion","tools"]`, llm.ThinkingLevelMedium, &high, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeOllama{
+				show: `{"model_info":{"qwen3.context_length":40960},"capabilities":` + tt.capabilities + `}`,
+				chat: `{"message":{"role":"assistant","content":"hi"},"done":true,"done_reason":"stop"}`,
+			}
+			srv := httptest.NewServer(fake)
+			defer srv.Close()
+
+			svc := &Service{URL: srv.URL, Model: "qwen3:8b", ThinkingLevel: tt.service}
+			req := &llm.Request{Messages: []llm.Message{llm.UserStringMessage("hi")}, ThinkingLevel: tt.level}
+			if _, err := svc.Do(context.Background(), req); err != nil {
+				t.Fatal(err)
+			}
+			if fake.lastChat.Think != tt.want {
+				t.Errorf("think = %v, want %v", fake.lastChat.Think, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,390 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// StructuredOptions configures DoStructured.
+type StructuredOptions[T any] struct {
+	// Name and Description describe the tool the model is forced to answer
+	// through. Name defaults to "respond".
+	Name        string
+	Description string
+	// Schema is the JSON schema the answer must satisfy.
+	// It defaults to SchemaFor the result type.
+	Schema json.RawMessage
+	// Validate checks constraints the schema can't express (optional).
+	Validate func(T) error
+	// MaxAttempts bounds requests made to get a valid answer; defaults to 3.
+	MaxAttempts int
+}
+
+// DoStructured sends req to svc and returns the answer decoded as a T.
+//
+// The model is forced to answer by calling a single tool whose input schema
+// is the requested schema; non-object schemas are wrapped in a "value"
+// property. Services that answer in text instead are accepted too if the
+// text is JSON, optionally in a code fence; for string results, plain text
+// is the answer. Answers that don't match the schema or fail Validate are
+// sent back to the model with the error, up to MaxAttempts times.
+//
+// req's tools and tool choice are replaced; req itself is not modified.
+func DoStructured[T any](ctx context.Context, svc Service, req *Request, opts *StructuredOptions[T]) (T, error) {
+	var zero T
+	if opts == nil {
+		opts = &StructuredOptions[T]{}
+	}
+	name := opts.Name
+	if name == "" {
+		name = "respond"
+	}
+	schemaJSON := opts.Schema
+	if len(schemaJSON) == 0 {
+		var err error
+		if schemaJSON, err = SchemaFor[T](); err != nil {
+			return zero, err
+		}
+	}
+	var schema map[string]any
+	if err := json.Unmarshal(schemaJSON, &schema); err != nil {
+		return zero, fmt.Errorf("llm: invalid structured output schema: %w", err)
+	}
+
+	// Tool inputs must be objects
+	wrapped := schema["type"] != "object"
+	toolSchema := schemaJSON
+	if wrapped {
+		toolSchema = MustSchema(fmt.Sprintf(`{"type":"object","properties":{"value":%s},"required":["value"]}`, schemaJSON))
+	}
+	description := opts.Description
+	if description == "" {
+		description = "Submit your answer. Always answer by calling this tool."
+	}
+
+	r := *req
+	r.Tools = []*Tool{{Name: name, Description: description, InputSchema: toolSchema}}
+	r.ToolChoice = &ToolChoice{Type: ToolChoiceTypeTool, Name: name}
+	r.Messages = slices.Clone(req.Messages)
+
+	maxAttempts := opts.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 3
+	}
+	var lastErr error
+	for range maxAttempts {
+		resp, err := svc.Do(ctx, &r)
+		if err != nil {
+			return zero, err
+		}
+
+		v, toolIDs, err := decodeStructured[T](resp, name, schema, wrapped)
+		if err == nil && opts.Validate != nil {
+			err = opts.Validate(v)
+		}
+		if err == nil {
+			return v, nil
+		}
+		lastErr = err
+
+		// Show the model what was wrong and ask again
+		feedback := fmt.Sprintf("Your answer was invalid: %v. Call the %s tool again with a corrected answer.", err, name)
+		r.Messages = append(r.Messages, resp.ToMessage())
+		reply := Message{Role: MessageRoleUser}
+		for _, id := range toolIDs {
+			reply.Content = append(reply.Content, Content{
+				Type:       ContentTypeToolResult,
+				ToolUseID:  id,
+				ToolError:  true,
+				ToolResult: []Content{{Type: ContentTypeText, Text: feedback}},
+			})
+		}
+		if len(toolIDs) == 0 {
+			reply.Content = append(reply.Content, Content{Type: ContentTypeText, Text: feedback})
+		}
+		r.Messages = append(r.Messages, reply)
+	}
+	return zero, fmt.Errorf("llm: no valid structured output after %d attempts: %w", maxAttempts, lastErr)
+}
+
+// decodeStructured extracts the answer from resp. It returns the IDs of the
+// tool calls made so a retry can answer each of them.
+func decodeStructured[T any](resp *Response, name string, schema map[string]any, wrapped bool) (T, []string, error) {
+	var zero T
+	var toolIDs []string
+	var input json.RawMessage
+	var text string
+	for _, c := range resp.Content {
+		switch c.Type {
+		case ContentTypeToolUse:
+			toolIDs = append(toolIDs, c.ID)
+			if c.ToolName == name && input == nil {
+				input = c.ToolInput
+			}
+		case ContentTypeText:
+			text += c.Text
+		}
+	}
+
+	var raw json.RawMessage
+	switch {
+	case input != nil && wrapped:
+		var w struct {
+			Value json.RawMessage `json:"value"`
+		}
+		if err := json.Unmarshal(input, &w); err != nil || w.Value == nil {
+			return zero, toolIDs, errors.New(`tool input must have a "value" property`)
+		}
+		raw = w.Value
+	case input != nil:
+		raw = input
+	case schema["type"] == "string":
+		// Plain text is the answer, unless it's a JSON string
+		var s string
+		if err := json.Unmarshal([]byte(strings.TrimSpace(text)), &s); err != nil {
+			s = text
+		}
+		if strings.TrimSpace(s) == "" {
+			return zero, toolIDs, errors.New("empty answer")
+		}
+		raw, _ = json.Marshal(s)
+	default:
+		raw = extractJSON(text)
+		if raw == nil {
+			return zero, toolIDs, fmt.Errorf("expected a call to the %s tool, got no JSON", name)
+		}
+		// Text answers may mimic the tool input
+		if wrapped {
+			var w struct {
+				Value json.RawMessage `json:"value"`
+			}
+			if json.Unmarshal(raw, &w) == nil && w.Value != nil && bytes.HasPrefix(bytes.TrimSpace(raw), []byte("{")) {
+				raw = w.Value
+			}
+		}
+	}
+
+	var generic any
+	if err := json.Unmarshal(raw, &generic); err != nil {
+		return zero, toolIDs, fmt.Errorf("invalid JSON: %w", err)
+	}
+	if err := validateSchema(generic, schema, "$"); err != nil {
+		return zero, toolIDs, err
+	}
+	var v T
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return zero, toolIDs, fmt.Errorf("invalid answer: %w", err)
+	}
+	return v, toolIDs, nil
+}
+
+// extractJSON returns the JSON value in text, ignoring code fences and
+// surrounding prose, or nil if there is none.
+func extractJSON(text string) json.RawMessage {
+	s := strings.TrimSpace(text)
+	if strings.HasPrefix(s, "```") {
+		if i := strings.Index(s, "\n"); i != -1 {
+			s = s[i+1:]
+		}
+		if i := strings.LastIndex(s, "```"); i != -1 {
+			s = s[:i]
+		}
+		s = strings.TrimSpace(s)
+	}
+	if json.Valid([]byte(s)) {
+		return json.RawMessage(s)
+	}
+	start := strings.IndexAny(s, "{[")
+	if start == -1 {
+		return nil
+	}
+	closer := "}"
+	if s[start] == '[' {
+		closer = "]"
+	}
+	end := strings.LastIndex(s, closer)
+	if end < start || !json.Valid([]byte(s[start:end+1])) {
+		return nil
+	}
+	return json.RawMessage(s[start : end+1])
+}
+
+// SchemaFor returns a JSON schema describing T's JSON encoding.
+// Struct fields are required unless tagged omitempty; a field's
+// `description` tag and comma-separated `enum` tag are copied into the schema.
+func SchemaFor[T any]() (json.RawMessage, error) {
+	s, err := schemaForType(reflect.TypeFor[T](), nil)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(s)
+}
+
+func schemaForType(t reflect.Type, seen []reflect.Type) (map[string]any, error) {
+	if slices.Contains(seen, t) {
+		return nil, fmt.Errorf("llm: recursive type %s has no schema", t)
+	}
+	seen = append(seen, t)
+
+	if t.Implements(reflect.TypeFor[json.Marshaler]()) {
+		return map[string]any{}, nil // encoding is opaque
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return schemaForType(t.Elem(), seen)
+	case reflect.String:
+		return map[string]any{"type": "string"}, nil
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}, nil
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}, nil
+	case reflect.Interface:
+		return map[string]any{}, nil
+	case reflect.Slice, reflect.Array:
+		items, err := schemaForType(t.Elem(), seen)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"type": "array", "items": items}, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("llm: map key type %s has no schema", t.Key())
+		}
+		values, err := schemaForType(t.Elem(), seen)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"type": "object", "additionalProperties": values}, nil
+	case reflect.Struct:
+		props := map[string]any{}
+		required := []string{}
+		for f := range t.Fields() {
+			if !f.IsExported() {
+				continue
+			}
+			name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			ps, err := schemaForType(f.Type, seen)
+			if err != nil {
+				return nil, err
+			}
+			if d := f.Tag.Get("description"); d != "" {
+				ps["description"] = d
+			}
+			if e := f.Tag.Get("enum"); e != "" {
+				ps["enum"] = strings.Split(e, ",")
+			}
+			props[name] = ps
+			if !slices.Contains(strings.Split(opts, ","), "omitempty") {
+				required = append(required, name)
+			}
+		}
+		return map[string]any{"type": "object", "properties": props, "required": required}, nil
+	default:
+		return nil, fmt.Errorf("llm: type %s has no schema", t)
+	}
+}
+
+// validateSchema checks v, as decoded by encoding/json into an any, against
+// the subset of JSON schema that SchemaFor produces plus minimum, maximum,
+// minItems and maxItems.
+func validateSchema(v any, schema map[string]any, path string) error {
+	if typ, ok := schema["type"].(string); ok && !matchesType(v, typ) {
+		return fmt.Errorf("%s: expected %s, got %s", path, typ, jsonTypeName(v))
+	}
+	if enum, ok := schema["enum"].([]any); ok && !slices.Contains(enum, v) {
+		return fmt.Errorf("%s: %v is not one of %v", path, v, enum)
+	}
+	switch v := v.(type) {
+	case float64:
+		if lo, ok := schema["minimum"].(float64); ok && v < lo {
+			return fmt.Errorf("%s: %v is less than the minimum %v", path, v, lo)
+		}
+		if hi, ok := schema["maximum"].(float64); ok && v > hi {
+			return fmt.Errorf("%s: %v is greater than the maximum %v", path, v, hi)
+		}
+	case []any:
+		if lo, ok := schema["minItems"].(float64); ok && float64(len(v)) < lo {
+			return fmt.Errorf("%s: expected at least %v items", path, lo)
+		}
+		if hi, ok := schema["maxItems"].(float64); ok && float64(len(v)) > hi {
+			return fmt.Errorf("%s: expected at most %v items", path, hi)
+		}
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range v {
+				if err := validateSchema(item, items, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case map[string]any:
+		if required, ok := schema["required"].([]any); ok {
+			for _, name := range required {
+				if n, _ := name.(string); n != "" {
+					if _, ok := v[n]; !ok {
+						return fmt.Errorf("%s: missing required property %q", path, n)
+					}
+				}
+			}
+		}
+		props, _ := schema["properties"].(map[string]any)
+		for k, pv := range v {
+			ps, ok := props[k].(map[string]any)
+			if !ok {
+				ps, _ = schema["additionalProperties"].(map[string]any)
+			}
+			if ps == nil {
+				continue
+			}
+			if err := validateSchema(pv, ps, path+"."+k); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func matchesType(v any, typ string) bool {
+	switch typ {
+	case "integer":
+		f, ok := v.(float64)
+		return ok && f == float64(int64(f))
+	case "null":
+		return v == nil
+	default:
+		return jsonTypeName(v) == typ
+	}
+}
+
+func jsonTypeName(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
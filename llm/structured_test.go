@@ -0,0 +1,228 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// scriptedService returns its responses in order and records requests.
+type scriptedService struct {
+	responses []*Response
+	requests  []*Request
+}
+
+func (s *scriptedService) Do(ctx context.Context, req *Request) (*Response, error) {
+	s.requests = append(s.requests, req)
+	if len(s.responses) == 0 {
+		return nil, errors.New("no more responses")
+	}
+	resp := s.responses[0]
+	s.responses = s.responses[1:]
+	return resp, nil
+}
+
+func (s *scriptedService) TokenContextWindow() int { return 8192 }
+func (s *scriptedService) MaxImageDimension() int  { return 0 }
+
+func toolUse(id, name, input string) *Response {
+	return &Response{
+		Role:       MessageRoleAssistant,
+		StopReason: StopReasonToolUse,
+		Content:    []Content{{ID: id, Type: ContentTypeToolUse, ToolName: name, ToolInput: json.RawMessage(input)}},
+	}
+}
+
+type verdict struct {
+	Answer     string   `json:"answer" enum:"yes,no"`
+	Confidence float64  `json:"confidence" description:"0-1"`
+	Notes      []string `json:"notes,omitempty"`
+}
+
+func TestDoStructuredToolCall(t *testing.T) {
+	svc := &scriptedService{responses: []*Response{toolUse("t1", "respond", `{"answer":"yes","confidence":0.8}`)}}
+	req := &Request{Messages: []Message{UserStringMessage("is it?")}}
+
+	got, err := DoStructured(context.Background(), svc, req, &StructuredOptions[verdict]{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Answer != "yes" || got.Confidence != 0.8 {
+		t.Errorf("got %+v", got)
+	}
+
+	sent := svc.requests[0]
+	if sent.ToolChoice == nil || sent.ToolChoice.Type != ToolChoiceTypeTool || sent.ToolChoice.Name != "respond" {
+		t.Errorf("tool choice = %+v", sent.ToolChoice)
+	}
+	if len(sent.Tools) != 1 || !strings.Contains(string(sent.Tools[0].InputSchema), `"enum":["yes","no"]`) {
+		t.Errorf("tools = %+v", sent.Tools)
+	}
+	if req.ToolChoice != nil || req.Tools != nil {
+		t.Error("caller's request was modified")
+	}
+}
+
+func TestDoStructuredRetriesInvalidAnswer(t *testing.T) {
+	svc := &scriptedService{responses: []*Response{
+		toolUse("t1", "respond", `{"answer":"maybe","confidence":0.5}`),
+		toolUse("t2", "respond", `{"answer":"no","confidence":0.5}`),
+	}}
+	req := &Request{Messages: []Message{UserStringMessage("is it?")}}
+
+	got, err := DoStructured(context.Background(), svc, req, &StructuredOptions[verdict]{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Answer != "no" {
+		t.Errorf("got %+v", got)
+	}
+
+	retry := svc.requests[1]
+	if len(retry.Messages) != 3 {
+		t.Fatalf("retry has %d messages, want 3", len(retry.Messages))
+	}
+	feedback := retry.Messages[2].Content[0]
+	if feedback.Type != ContentTypeToolResult || feedback.ToolUseID != "t1" || !feedback.ToolError {
+		t.Errorf("feedback = %+v", feedback)
+	}
+	if !strings.Contains(feedback.ToolResult[0].Text, "not one of") {
+		t.Errorf("feedback text = %q", feedback.ToolResult[0].Text)
+	}
+	if len(req.Messages) != 1 {
+		t.Error("caller's messages were modified")
+	}
+}
+
+func TestDoStructuredGivesUp(t *testing.T) {
+	svc := &scriptedService{responses: []*Response{
+		{Content: []Content{{Type: ContentTypeText, Text: "sorry"}}},
+		{Content: []Content{{Type: ContentTypeText, Text: "sorry"}}},
+	}}
+	_, err := DoStructured(context.Background(), svc, &Request{}, &StructuredOptions[verdict]{MaxAttempts: 2})
+	if err == nil || !strings.Contains(err.Error(), "after 2 attempts") {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestDoStructuredValidate(t *testing.T) {
+	svc := &scriptedService{responses: []*Response{
+		toolUse("t1", "respond", `{"answer":"yes","confidence":0.1}`),
+		toolUse("t2", "respond", `{"answer":"yes","confidence":0.9}`),
+	}}
+	got, err := DoStructured(context.Background(), svc, &Request{}, &StructuredOptions[verdict]{
+		Validate: func(v verdict) error {
+			if v.Confidence < 0.5 {
+				return errors.New("be more confident")
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Confidence != 0.9 || len(svc.requests) != 2 {
+		t.Errorf("got %+v after %d requests", got, len(svc.requests))
+	}
+}
+
+func TestDoStructuredTextFallback(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want int
+	}{
+		{"bare", `[1, 2, 3]`, 3},
+		{"fenced", "```json\n[1, 2]\n```", 2},
+		{"prose", "Here you go: [1] Hope that helps.", 1},
+		{"wrapped", `{"value": [1, 2, 3, 4]}`, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &scriptedService{responses: []*Response{{Content: []Content{{Type: ContentTypeText, Text: tt.text}}}}}
+			got, err := DoStructured(context.Background(), svc, &Request{}, &StructuredOptions[[]int]{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != tt.want {
+				t.Errorf("got %v, want %d items", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDoStructuredWrapsNonObjects(t *testing.T) {
+	svc := &scriptedService{responses: []*Response{toolUse("t1", "slug", `{"value":"fix-login-bug"}`)}}
+	got, err := DoStructured(context.Background(), svc, &Request{}, &StructuredOptions[string]{Name: "slug"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "fix-login-bug" {
+		t.Errorf("got %q", got)
+	}
+	if schema := string(svc.requests[0].Tools[0].InputSchema); !strings.Contains(schema, `"required":["value"]`) {
+		t.Errorf("schema = %s", schema)
+	}
+
+	// Plain text answers a string schema as is.
+	svc = &scriptedService{responses: []*Response{{Content: []Content{{Type: ContentTypeText, Text: "line one\n{not json}\n"}}}}}
+	got, err = DoStructured(context.Background(), svc, &Request{}, &StructuredOptions[string]{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "line one\n{not json}\n" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestSchemaFor(t *testing.T) {
+	type item struct {
+		Name  string            `json:"name"`
+		Count int               `json:"count,omitempty"`
+		Tags  map[string]string `json:"tags,omitempty"`
+		skip  bool
+	}
+	raw, err := SchemaFor[[]item]()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"items":{"properties":{"count":{"type":"integer"},"name":{"type":"string"},"tags":{"additionalProperties":{"type":"string"},"type":"object"}},"required":["name"],"type":"object"},"type":"array"}`
+	if string(raw) != want {
+		t.Errorf("SchemaFor = %s\nwant %s", raw, want)
+	}
+
+	type node struct {
+		Children []node `json:"children"`
+	}
+	if _, err := SchemaFor[node](); err == nil {
+		t.Error("expected error for recursive type")
+	}
+}
+
+func TestValidateSchema(t *testing.T) {
+	schema := map[string]any{}
+	if err := json.Unmarshal([]byte(`{"type":"object","required":["n"],"properties":{"n":{"type":"integer","minimum":1,"maximum":5}}}`), &schema); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		value   string
+		wantErr string
+	}{
+		{`{"n":3}`, ""},
+		{`{}`, "missing required"},
+		{`{"n":2.5}`, "expected integer"},
+		{`{"n":9}`, "maximum"},
+		{`{"n":0}`, "minimum"},
+		{`[]`, "expected object"},
+	}
+	for _, tt := range tests {
+		var v any
+		_ = json.Unmarshal([]byte(tt.value), &v)
+		err := validateSchema(v, schema, "$")
+		if (err == nil) != (tt.wantErr == "") || (err != nil && !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("validateSchema(%s) = %v, want %q", tt.value, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package llm
+
+// Task classifies the work an LLM request does, so that side tasks can be
+// routed to a cheaper model than the main agent loop.
+type Task string
+
+const (
+	TaskMain            Task = "main"
+	TaskSlug            Task = "slug"
+	TaskKeyword         Task = "keyword"
+	TaskToolInstall     Task = "tool_install"
+	TaskMemoryExtract   Task = "memory_extract"
+	TaskConsolidate     Task = "consolidate"
+	TaskDistill         Task = "distill"
+	TaskConflictResolve Task = "conflict_resolve"
+	TaskProgressSummary Task = "progress_summary"
+)
+
+// Tasks lists every task class.
+var Tasks = []Task{
+	TaskMain, TaskSlug, TaskKeyword, TaskToolInstall, TaskMemoryExtract,
+	TaskConsolidate, TaskDistill, TaskConflictResolve, TaskProgressSummary,
+}
@@ -40,20 +40,27 @@ type Config struct {
 // Loop manages a conversation turn with an LLM including tool execution and message recording.
 // Notably, when the turn ends, the "Loop" is over. TODO: maybe rename to Turn?
 type Loop struct {
-	llm              llm.Service
-	tools            []*llm.Tool
-	recordMessage    MessageRecordFunc
-	history          []llm.Message
-	messageQueue     []llm.Message
-	totalUsage       llm.Usage
-	mu               sync.Mutex
-	logger           *slog.Logger
-	system           []llm.SystemContent
-	workingDir       string
-	onGitStateChange GitStateChangeFunc
-	getWorkingDir    func() string
+	llm               llm.Service
+	tools             []*llm.Tool
+	recordMessage     MessageRecordFunc
+	history           []llm.Message
+	messageQueue      []llm.Message
+	totalUsage        llm.Usage
+	mu                sync.Mutex
+	logger            *slog.Logger
+	system            []llm.SystemContent
+	workingDir        string
+	onGitStateChange  GitStateChangeFunc
+	getWorkingDir     func() string
 	lastGitState      *gitstate.GitState
 	truncationRetries int
+
+	// thinkingLevel overrides the service's thinking level; nil keeps it.
+	// nextTurnThinking applies to the turn started by the next queued
+	// messages only, and turnThinking to the turn in progress.
+	thinkingLevel    *llm.ThinkingLevel
+	nextTurnThinking *llm.ThinkingLevel
+	turnThinking     *llm.ThinkingLevel
 }
 
 // NewLoop creates a new Loop instance with the provided configuration
@@ -93,6 +100,33 @@ func (l *Loop) QueueUserMessage(message llm.Message) {
 	l.logger.Debug("queued user message", "content_count", len(message.Content))
 }
 
+// SetThinkingLevel sets the thinking level for the rest of the conversation.
+// Nil reverts to the service's default.
+func (l *Loop) SetThinkingLevel(level *llm.ThinkingLevel) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.thinkingLevel = level
+}
+
+// QueueUserMessageWithThinking is like QueueUserMessage, but the turn that
+// answers the message uses the given thinking level.
+func (l *Loop) QueueUserMessageWithThinking(message llm.Message, level llm.ThinkingLevel) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.messageQueue = append(l.messageQueue, message)
+	l.nextTurnThinking = &level
+	l.logger.Debug("queued user message", "content_count", len(message.Content), "thinking_level", level.Name())
+}
+
+// takeQueuedMessagesLocked moves queued messages into the history and
+// starts their turn. l.mu must be held.
+func (l *Loop) takeQueuedMessagesLocked() {
+	l.history = append(l.history, l.messageQueue...)
+	l.messageQueue = l.messageQueue[:0]
+	l.turnThinking = l.nextTurnThinking
+	l.nextTurnThinking = nil
+}
+
 // GetUsage returns the total usage accumulated by this loop
 func (l *Loop) GetUsage() llm.Usage {
 	l.mu.Lock()
@@ -140,8 +174,7 @@ func (l *Loop) Go(ctx context.Context) error {
 		hasQueuedMessages := len(l.messageQueue) > 0
 		if hasQueuedMessages {
 			// Add queued messages to history (they are already recorded to DB by ConversationManager)
-			l.history = append(l.history, l.messageQueue...)
-			l.messageQueue = l.messageQueue[:0] // Clear queue
+			l.takeQueuedMessagesLocked()
 			l.truncationRetries = 0
 		}
 		l.mu.Unlock()
@@ -178,8 +211,7 @@ func (l *Loop) ProcessOneTurn(ctx context.Context) error {
 	l.mu.Lock()
 	if len(l.messageQueue) > 0 {
 		// Add queued messages to history (they are already recorded to DB by ConversationManager)
-		l.history = append(l.history, l.messageQueue...)
-		l.messageQueue = nil
+		l.takeQueuedMessagesLocked()
 		l.truncationRetries = 0
 	}
 	l.mu.Unlock()
@@ -195,6 +227,10 @@ func (l *Loop) processLLMRequest(ctx context.Context) error {
 	tools := l.tools
 	system := l.system
 	llmService := l.llm
+	thinkingLevel := l.thinkingLevel
+	if l.turnThinking != nil {
+		thinkingLevel = l.turnThinking
+	}
 	l.mu.Unlock()
 
 	// Enable prompt caching: set cache flag on last tool and last user message content
@@ -223,9 +259,10 @@ func (l *Loop) processLLMRequest(ctx context.Context) error {
 	}
 
 	req := &llm.Request{
-		Messages: messages,
-		Tools:    tools,
-		System:   system,
+		Messages:      messages,
+		Tools:         tools,
+		System:        system,
+		ThinkingLevel: thinkingLevel,
 	}
 
 	// Insert missing tool results if the previous message had tool_use blocks
@@ -572,8 +609,7 @@ func (l *Loop) handleToolCalls(ctx context.Context, content []llm.Content) error
 		// Check for queued user messages (interruptions) before continuing.
 		// This allows user messages to be processed as soon as possible.
 		if len(l.messageQueue) > 0 {
-			l.history = append(l.history, l.messageQueue...)
-			l.messageQueue = l.messageQueue[:0]
+			l.takeQueuedMessagesLocked()
 			l.logger.Info("processing user interruption during tool execution")
 		}
 		l.mu.Unlock()
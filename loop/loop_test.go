@@ -2386,3 +2386,40 @@ func truncText(msg llm.Message) string {
 //		t.Error("expected to find tool2 result in message 3")
 //	}
 //}
+
+func TestLoopThinkingLevel(t *testing.T) {
+	service := NewPredictableService()
+	loop := NewLoop(Config{
+		LLM:           service,
+		RecordMessage: func(ctx context.Context, message llm.Message, usage llm.Usage) error { return nil },
+	})
+	ctx := context.Background()
+	hello := llm.Message{Role: llm.MessageRoleUser, Content: []llm.Content{{Type: llm.ContentTypeText, Text: "hello"}}}
+
+	loop.QueueUserMessage(hello)
+	if err := loop.ProcessOneTurn(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if level := service.GetLastRequest().ThinkingLevel; level != nil {
+		t.Errorf("default turn sent thinking level %v", *level)
+	}
+
+	high := llm.ThinkingLevelHigh
+	loop.SetThinkingLevel(&high)
+	loop.QueueUserMessageWithThinking(hello, llm.ThinkingLevelOff)
+	if err := loop.ProcessOneTurn(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if level := service.GetLastRequest().ThinkingLevel; level == nil || *level != llm.ThinkingLevelOff {
+		t.Errorf("overridden turn sent thinking level %v, want off", level)
+	}
+
+	// The override only lasts one turn
+	loop.QueueUserMessage(hello)
+	if err := loop.ProcessOneTurn(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if level := service.GetLastRequest().ThinkingLevel; level == nil || *level != llm.ThinkingLevelHigh {
+		t.Errorf("next turn sent thinking level %v, want high", level)
+	}
+}
@@ -20,9 +20,11 @@ import (
 // Available patterns include:
 //   - "echo: <text>" - echoes the text back
 //   - "bash: <command>" - triggers bash tool with command
+//   - "bash_background: <command>" - triggers bash tool with background=true
 //   - "think: <thoughts>" - returns response with extended thinking content
 //   - "subagent: <slug> <prompt>" - triggers subagent tool
 //   - "change_dir: <path>" - triggers change_dir tool
+//   - "todo: <task>; <task>..." - triggers todo_write with pending tasks
 //   - "delay: <seconds>" - delays response by specified seconds
 //   - See Do() method for complete list of supported patterns
 type PredictableService struct {
@@ -157,7 +159,12 @@ func (s *PredictableService) Do(ctx context.Context, req *llm.Request) (*llm.Res
 
 		if strings.HasPrefix(inputText, "bash: ") {
 			cmd := strings.TrimPrefix(inputText, "bash: ")
-			return s.makeBashToolResponse(cmd, inputTokens), nil
+			return s.makeBashToolResponse(cmd, false, inputTokens), nil
+		}
+
+		if strings.HasPrefix(inputText, "bash_background: ") {
+			cmd := strings.TrimPrefix(inputText, "bash_background: ")
+			return s.makeBashToolResponse(cmd, true, inputTokens), nil
 		}
 
 		if strings.HasPrefix(inputText, "think: ") {
@@ -196,6 +203,11 @@ func (s *PredictableService) Do(ctx context.Context, req *llm.Request) (*llm.Res
 			return s.makeChangeDirToolResponse(path, inputTokens), nil
 		}
 
+		if strings.HasPrefix(inputText, "todo: ") {
+			tasks := strings.Split(strings.TrimPrefix(inputText, "todo: "), ";")
+			return s.makeTodoWriteToolResponse(tasks, inputTokens), nil
+		}
+
 		if strings.HasPrefix(inputText, "delay: ") {
 			delayStr := strings.TrimPrefix(inputText, "delay: ")
 			delaySeconds, err := strconv.ParseFloat(delayStr, 64)
@@ -262,9 +274,12 @@ func (s *PredictableService) makeResponse(text string, inputTokens uint64) *llm.
 }
 
 // makeBashToolResponse creates a response that calls the bash tool
-func (s *PredictableService) makeBashToolResponse(command string, inputTokens uint64) *llm.Response {
+func (s *PredictableService) makeBashToolResponse(command string, background bool, inputTokens uint64) *llm.Response {
 	// Properly marshal the command to avoid JSON escaping issues
-	toolInputData := map[string]string{"command": command}
+	toolInputData := map[string]any{"command": command}
+	if background {
+		toolInputData["background"] = true
+	}
 	toolInputBytes, err := json.Marshal(toolInputData)
 	if err != nil {
 		panic(fmt.Sprintf("predictable: failed to marshal bash tool input: %v", err))
@@ -591,6 +606,44 @@ func (s *PredictableService) makeChangeDirToolResponse(path string, inputTokens
 	}
 }
 
+// makeTodoWriteToolResponse creates a response that calls the todo_write tool
+func (s *PredictableService) makeTodoWriteToolResponse(tasks []string, inputTokens uint64) *llm.Response {
+	todos := []map[string]string{}
+	for _, task := range tasks {
+		todos = append(todos, map[string]string{"content": strings.TrimSpace(task), "status": "pending"})
+	}
+	toolInputBytes, err := json.Marshal(map[string]any{"todos": todos})
+	if err != nil {
+		panic(fmt.Sprintf("predictable: failed to marshal todo_write tool input: %v", err))
+	}
+	responseText := "I'll write the task list."
+	outputTokens := uint64(len(responseText)/4 + len(toolInputBytes)/4)
+	if outputTokens == 0 {
+		outputTokens = 1
+	}
+	return &llm.Response{
+		ID:    fmt.Sprintf("pred-todo_write-%d", time.Now().UnixNano()),
+		Type:  "message",
+		Role:  llm.MessageRoleAssistant,
+		Model: "predictable-v1",
+		Content: []llm.Content{
+			{Type: llm.ContentTypeText, Text: responseText},
+			{
+				ID:        fmt.Sprintf("tool_%d", time.Now().UnixNano()%1000),
+				Type:      llm.ContentTypeToolUse,
+				ToolName:  "todo_write",
+				ToolInput: json.RawMessage(toolInputBytes),
+			},
+		},
+		StopReason: llm.StopReasonToolUse,
+		Usage: llm.Usage{
+			InputTokens:  inputTokens,
+			OutputTokens: outputTokens,
+			CostUSD:      0.001,
+		},
+	}
+}
+
 func (s *PredictableService) makeSubagentToolResponse(slug, prompt string, inputTokens uint64) *llm.Response {
 	toolInputData := map[string]any{
 		"slug":   slug,
@@ -92,6 +92,46 @@ func (d *DB) SupersedeCells(cellIDs []string) error {
 	return nil
 }
 
+// TouchCells records that the given cells were just retrieved, which resets
+// their salience decay clock.
+func (d *DB) TouchCells(cellIDs []string) error {
+	if len(cellIDs) == 0 {
+		return nil
+	}
+	placeholders := make([]string, len(cellIDs))
+	args := make([]any, len(cellIDs))
+	for i, id := range cellIDs {
+		placeholders[i] = "?"
+		args[i] = id
+	}
+	q := fmt.Sprintf(`UPDATE cells SET last_accessed_at = CURRENT_TIMESTAMP WHERE cell_id IN (%s)`, strings.Join(placeholders, ","))
+	_, err := d.db.Exec(q, args...)
+	if err != nil {
+		return fmt.Errorf("memory: touch cells: %w", err)
+	}
+	return nil
+}
+
+// TouchTopicCells records that the summaries of the given topics were just
+// retrieved, which resets the decay clock of their active cells.
+func (d *DB) TouchTopicCells(topicIDs []string) error {
+	if len(topicIDs) == 0 {
+		return nil
+	}
+	placeholders := make([]string, len(topicIDs))
+	args := make([]any, len(topicIDs))
+	for i, id := range topicIDs {
+		placeholders[i] = "?"
+		args[i] = id
+	}
+	q := fmt.Sprintf(`UPDATE cells SET last_accessed_at = CURRENT_TIMESTAMP WHERE superseded = FALSE AND topic_id IN (%s)`, strings.Join(placeholders, ","))
+	_, err := d.db.Exec(q, args...)
+	if err != nil {
+		return fmt.Errorf("memory: touch topic cells: %w", err)
+	}
+	return nil
+}
+
 // DeleteCellsBySource removes all cells for a given source.
 func (d *DB) DeleteCellsBySource(sourceType, sourceID string) error {
 	_, err := d.db.Exec(`DELETE FROM cells WHERE source_type = ? AND source_id = ?`, sourceType, sourceID)
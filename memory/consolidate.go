@@ -3,7 +3,9 @@ package memory
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/tgruben-circuit/percy/llm"
 )
@@ -12,8 +14,8 @@ const consolidationThreshold = 5 // Minimum unsummarized cells before consolidat
 
 // ConsolidationResult is the structured output from LLM consolidation.
 type ConsolidationResult struct {
-	Summary           string   `json:"summary"`
-	SupersededCellIDs []string `json:"superseded_cell_ids"`
+	Summary           string   `json:"summary" description:"updated topic summary, under 150 words"`
+	SupersededCellIDs []string `json:"superseded_cell_ids,omitempty" description:"cell_ids of cells contradicted by newer ones"`
 }
 
 // NeedsConsolidation returns true if the topic has enough unsummarized cells
@@ -38,10 +40,7 @@ Rules:
 Topic: %s
 Existing summary: %s
 Cells (newest first):
-%s
-
-Return ONLY valid JSON with no code fences:
-{"summary": "...", "superseded_cell_ids": ["cell_id_1", ...]}`
+%s`
 
 // ConsolidateTopic uses an LLM to consolidate a topic's cells into an updated summary.
 func ConsolidateTopic(ctx context.Context, db *DB, svc llm.Service, embedder Embedder, topicID string) error {
@@ -72,24 +71,20 @@ func ConsolidateTopic(ctx context.Context, db *DB, svc llm.Service, embedder Emb
 		},
 	}
 
-	resp, err := svc.Do(ctx, req)
+	result, err := llm.DoStructured(ctx, svc, req, &llm.StructuredOptions[ConsolidationResult]{
+		Name:        "save_summary",
+		Description: "Save the consolidated topic summary.",
+		Validate: func(r ConsolidationResult) error {
+			if strings.TrimSpace(r.Summary) == "" {
+				return errors.New("summary must not be empty")
+			}
+			return nil
+		},
+	})
 	if err != nil {
 		return fmt.Errorf("memory: consolidate llm: %w", err)
 	}
 
-	var text string
-	for _, c := range resp.Content {
-		if c.Type == llm.ContentTypeText {
-			text = c.Text
-			break
-		}
-	}
-
-	result := parseConsolidationResult(text)
-	if result.Summary == "" {
-		return fmt.Errorf("memory: consolidate: empty summary from LLM")
-	}
-
 	// Update topic summary.
 	topic.Summary = result.Summary
 
@@ -138,14 +133,3 @@ func formatCellsForPrompt(cells []Cell) string {
 	}
 	return string(buf)
 }
-
-// parseConsolidationResult parses the LLM response into a ConsolidationResult.
-// Strips code fences and returns zero-value on parse failure.
-func parseConsolidationResult(text string) ConsolidationResult {
-	text = stripCodeFences(text)
-	var result ConsolidationResult
-	if err := json.Unmarshal([]byte(text), &result); err != nil {
-		return ConsolidationResult{}
-	}
-	return result
-}
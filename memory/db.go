@@ -1,6 +1,7 @@
 package memory
 
 import (
+	"context"
 	"database/sql"
 	_ "embed"
 	"fmt"
@@ -14,8 +15,18 @@ import (
 var schemaSQL string
 
 type DB struct {
-	db   *sql.DB
-	path string
+	db    querier // sqldb, or a transaction while one is in progress
+	sqldb *sql.DB
+	path  string
+}
+
+// querier is the subset of *sql.DB and *sql.Tx that DB methods use.
+type querier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
 }
 
 func Open(path string) (*DB, error) {
@@ -46,11 +57,33 @@ func Open(path string) (*DB, error) {
 		return nil, fmt.Errorf("memory: schema: %w", err)
 	}
 
-	return &DB{db: sqldb, path: path}, nil
+	if err := addMissingColumns(sqldb); err != nil {
+		sqldb.Close()
+		return nil, fmt.Errorf("memory: migrate: %w", err)
+	}
+
+	return &DB{db: sqldb, sqldb: sqldb, path: path}, nil
 }
 
 func (d *DB) Close() error {
-	return d.db.Close()
+	return d.sqldb.Close()
+}
+
+// inTx runs fn with a DB whose methods all use one transaction, committing
+// if fn succeeds and rolling back otherwise.
+func (d *DB) inTx(ctx context.Context, fn func(tx *DB) error) error {
+	tx, err := d.sqldb.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("memory: begin: %w", err)
+	}
+	defer tx.Rollback()
+	if err := fn(&DB{db: tx, sqldb: d.sqldb, path: d.path}); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("memory: commit: %w", err)
+	}
+	return nil
 }
 
 func (d *DB) QueryRow(query string, args ...any) *sql.Row {
@@ -84,3 +117,28 @@ func migrateFromOldSchema(db *sql.DB) error {
 	}
 	return nil
 }
+
+// cellColumnsAdded lists columns added to the cells table after its initial
+// release. CREATE TABLE IF NOT EXISTS leaves older databases without them.
+var cellColumnsAdded = []struct{ name, decl string }{
+	{"last_accessed_at", "DATETIME"},
+	{"decayed_at", "DATETIME"},
+}
+
+// addMissingColumns adds any columns in cellColumnsAdded that an existing
+// cells table lacks.
+func addMissingColumns(db *sql.DB) error {
+	for _, col := range cellColumnsAdded {
+		var n int
+		if err := db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('cells') WHERE name = ?", col.name).Scan(&n); err != nil {
+			return fmt.Errorf("migration: inspect cells.%s: %w", col.name, err)
+		}
+		if n > 0 {
+			continue
+		}
+		if _, err := db.Exec(fmt.Sprintf("ALTER TABLE cells ADD COLUMN %s %s", col.name, col.decl)); err != nil {
+			return fmt.Errorf("migration: add cells.%s: %w", col.name, err)
+		}
+	}
+	return nil
+}
@@ -163,3 +163,38 @@ func TestMigrateFromOldSchema(t *testing.T) {
 		t.Error("index_state should be cleared during migration")
 	}
 }
+
+func TestOpenAddsMissingCellColumns(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "memory.db")
+
+	// Create a cells table as it looked before decay tracking was added.
+	sqldb, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = sqldb.Exec(`CREATE TABLE cells (
+		cell_id TEXT PRIMARY KEY, topic_id TEXT, source_type TEXT NOT NULL, source_id TEXT NOT NULL,
+		source_name TEXT, cell_type TEXT NOT NULL, salience REAL NOT NULL DEFAULT 0.5, content TEXT NOT NULL,
+		embedding BLOB, created_at DATETIME DEFAULT CURRENT_TIMESTAMP, superseded BOOLEAN DEFAULT FALSE)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqldb.Close()
+
+	mdb, err := Open(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mdb.Close()
+
+	for _, col := range []string{"last_accessed_at", "decayed_at"} {
+		var n int
+		if err := mdb.QueryRow("SELECT COUNT(*) FROM pragma_table_info('cells') WHERE name = ?", col).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		if n != 1 {
+			t.Errorf("expected column %s to be added", col)
+		}
+	}
+}
@@ -19,8 +19,12 @@ func (NoneEmbedder) Embed(context.Context, []string) ([][]float32, error) { retu
 func (NoneEmbedder) Dimension() int                                       { return 0 }
 
 // CosineSimilarity returns the cosine similarity between two vectors.
-// Returns 0 if either vector has zero magnitude.
+// Returns 0 if either vector has zero magnitude or the lengths differ, as
+// happens when stored vectors came from a different embedder.
 func CosineSimilarity(a, b []float32) float32 {
+	if len(a) != len(b) {
+		return 0
+	}
 	var dot, normA, normB float64
 	for i := range a {
 		dot += float64(a[i]) * float64(b[i])
@@ -0,0 +1,164 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// DefaultLocalDimension is the vector size used by NewLocalEmbedder when
+// given a non-positive dimension.
+const DefaultLocalDimension = 512
+
+// LocalEmbedder generates embeddings in-process with no network access. It
+// uses feature hashing over words, word bigrams and character trigrams, so
+// texts sharing vocabulary and subword fragments score as similar. It is not
+// a semantic model, but it gives air-gapped machines useful vector recall.
+type LocalEmbedder struct {
+	dim int
+}
+
+// NewLocalEmbedder creates a LocalEmbedder producing vectors of length dim.
+func NewLocalEmbedder(dim int) *LocalEmbedder {
+	if dim <= 0 {
+		dim = DefaultLocalDimension
+	}
+	return &LocalEmbedder{dim: dim}
+}
+
+// Feature weights: whole words carry the most signal, trigrams the least.
+const (
+	localWordWeight    = 1.0
+	localBigramWeight  = 0.7
+	localTrigramWeight = 0.3
+)
+
+// Embed returns one L2-normalized vector per text. Texts with no usable
+// tokens map to the zero vector.
+func (l *LocalEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	out := make([][]float32, len(texts))
+	for i, text := range texts {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		out[i] = l.embedOne(text)
+	}
+	return out, nil
+}
+
+// Dimension returns the configured vector length.
+func (l *LocalEmbedder) Dimension() int { return l.dim }
+
+// Name identifies the embedder and its dimension so stored vectors can be
+// matched to the embedder that produced them.
+func (l *LocalEmbedder) Name() string { return fmt.Sprintf("local:hash-%d", l.dim) }
+
+func (l *LocalEmbedder) embedOne(text string) []float32 {
+	counts := make(map[string]float64)
+	words := localTokens(text)
+	for i, w := range words {
+		counts["w:"+w] += localWordWeight
+		if i > 0 {
+			counts["b:"+words[i-1]+" "+w] += localBigramWeight
+		}
+		padded := []rune("^" + w + "$")
+		for j := 0; j+3 <= len(padded); j++ {
+			counts["t:"+string(padded[j:j+3])] += localTrigramWeight
+		}
+	}
+
+	vec := make([]float64, l.dim)
+	for feature, weight := range counts {
+		h := fnv.New64a()
+		h.Write([]byte(feature))
+		sum := h.Sum64()
+		idx := int(sum % uint64(l.dim))
+		// Sublinear term frequency keeps repeated words from dominating.
+		v := 1 + math.Log(weight)
+		if weight < 1 {
+			v = weight
+		}
+		// A second hash bit picks the sign so collisions tend to cancel.
+		if sum&(1<<63) != 0 {
+			v = -v
+		}
+		vec[idx] += v
+	}
+
+	var norm float64
+	for _, v := range vec {
+		norm += v * v
+	}
+	norm = math.Sqrt(norm)
+	result := make([]float32, l.dim)
+	if norm == 0 {
+		return result
+	}
+	for i, v := range vec {
+		result[i] = float32(v / norm)
+	}
+	return result
+}
+
+// localStopwords are dropped before hashing; they add noise, not meaning.
+var localStopwords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "by": true, "for": true, "from": true, "in": true, "is": true,
+	"it": true, "of": true, "on": true, "or": true, "that": true, "the": true,
+	"this": true, "to": true, "was": true, "we": true, "with": true,
+}
+
+// localTokens lowercases text and splits it into words. Identifiers are split
+// on camelCase and snake_case boundaries, and the joined form is kept too,
+// so "parseConfig" matches both "parse config" and "parseconfig".
+func localTokens(text string) []string {
+	var tokens []string
+	fields := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+	for _, f := range fields {
+		parts := splitIdentifier(f)
+		if len(parts) > 1 {
+			joined := strings.ToLower(strings.Join(parts, ""))
+			tokens = append(tokens, joined)
+		}
+		for _, p := range parts {
+			p = strings.ToLower(p)
+			if p == "" || localStopwords[p] {
+				continue
+			}
+			tokens = append(tokens, p)
+		}
+	}
+	return tokens
+}
+
+// splitIdentifier splits s on underscores and lower-to-upper case changes.
+func splitIdentifier(s string) []string {
+	var parts []string
+	var cur []rune
+	prevLower := false
+	for _, r := range s {
+		switch {
+		case r == '_':
+			if len(cur) > 0 {
+				parts = append(parts, string(cur))
+			}
+			cur = cur[:0]
+			prevLower = false
+			continue
+		case unicode.IsUpper(r) && prevLower:
+			parts = append(parts, string(cur))
+			cur = cur[:0]
+		}
+		cur = append(cur, r)
+		prevLower = unicode.IsLower(r) || unicode.IsDigit(r)
+	}
+	if len(cur) > 0 {
+		parts = append(parts, string(cur))
+	}
+	return parts
+}
@@ -0,0 +1,81 @@
+package memory
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+func TestLocalEmbedderDimensionAndNorm(t *testing.T) {
+	e := NewLocalEmbedder(128)
+	if e.Dimension() != 128 {
+		t.Fatalf("expected dimension 128, got %d", e.Dimension())
+	}
+	vecs, err := e.Embed(context.Background(), []string{"Auth uses JWT tokens", ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vecs) != 2 || len(vecs[0]) != 128 || len(vecs[1]) != 128 {
+		t.Fatalf("unexpected shapes: %d vectors", len(vecs))
+	}
+
+	var norm float64
+	for _, v := range vecs[0] {
+		norm += float64(v) * float64(v)
+	}
+	if math.Abs(norm-1) > 1e-5 {
+		t.Errorf("expected unit vector, got squared norm %f", norm)
+	}
+	for _, v := range vecs[1] {
+		if v != 0 {
+			t.Fatal("expected zero vector for empty text")
+		}
+	}
+}
+
+func TestLocalEmbedderDeterministic(t *testing.T) {
+	a, _ := NewLocalEmbedder(0).Embed(context.Background(), []string{"database migration strategy"})
+	b, _ := NewLocalEmbedder(0).Embed(context.Background(), []string{"database migration strategy"})
+	if len(a[0]) != DefaultLocalDimension {
+		t.Fatalf("expected default dimension %d, got %d", DefaultLocalDimension, len(a[0]))
+	}
+	if CosineSimilarity(a[0], b[0]) < 0.9999 {
+		t.Fatal("expected identical embeddings across instances")
+	}
+}
+
+func TestLocalEmbedderSimilarity(t *testing.T) {
+	e := NewLocalEmbedder(0)
+	vecs, err := e.Embed(context.Background(), []string{
+		"The server uses JWT authentication middleware",
+		"JWT authentication is handled by server middleware",
+		"Deploy the frontend bundle to the CDN",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	related := CosineSimilarity(vecs[0], vecs[1])
+	unrelated := CosineSimilarity(vecs[0], vecs[2])
+	if related <= unrelated {
+		t.Fatalf("expected related texts to score higher: related=%f unrelated=%f", related, unrelated)
+	}
+}
+
+func TestLocalTokensSplitsIdentifiers(t *testing.T) {
+	got := localTokens("parseConfig read_file")
+	want := map[string]bool{"parseconfig": true, "parse": true, "config": true, "readfile": true, "read": true, "file": true}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d tokens, got %v", len(want), got)
+	}
+	for _, tok := range got {
+		if !want[tok] {
+			t.Errorf("unexpected token %q in %v", tok, got)
+		}
+	}
+}
+
+func TestCosineSimilarityDimensionMismatch(t *testing.T) {
+	if sim := CosineSimilarity([]float32{1, 0}, []float32{1, 0, 0}); sim != 0 {
+		t.Fatalf("expected 0 for mismatched lengths, got %f", sim)
+	}
+}
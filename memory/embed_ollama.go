@@ -65,3 +65,6 @@ func (o *OllamaEmbedder) Embed(ctx context.Context, texts []string) ([][]float32
 // Dimension returns 0 because the dimension is model-dependent and unknown
 // until the first embedding call.
 func (o *OllamaEmbedder) Dimension() int { return 0 }
+
+// Name identifies the embedder and model.
+func (o *OllamaEmbedder) Name() string { return "ollama:" + o.model }
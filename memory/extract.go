@@ -2,7 +2,6 @@ package memory
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"strings"
 
@@ -11,10 +10,10 @@ import (
 
 // ExtractedCell is the structured output from LLM extraction.
 type ExtractedCell struct {
-	CellType  string  `json:"cell_type"`
-	Salience  float64 `json:"salience"`
-	Content   string  `json:"content"`
-	TopicHint string  `json:"topic_hint"`
+	CellType  string  `json:"cell_type" description:"one of fact, decision, preference, task, risk, code_ref"`
+	Salience  float64 `json:"salience" description:"0.0-1.0, how important for future work"`
+	Content   string  `json:"content" description:"compressed, factual statement (1-2 sentences max)"`
+	TopicHint string  `json:"topic_hint" description:"short topic label"`
 }
 
 var validCellTypes = map[string]bool{
@@ -28,7 +27,7 @@ var validCellTypes = map[string]bool{
 
 const extractionPrompt = `You are a memory extraction engine for a coding assistant called Percy. Convert the conversation below into structured memory cells — atomic units of knowledge useful for FUTURE conversations.
 
-Return a JSON array. Each object must have:
+Submit an array of cells. Each cell must have:
 - cell_type: one of (fact, decision, preference, task, risk, code_ref)
 - salience: 0.0-1.0 (how important for future work?)
 - content: compressed, factual statement (1-2 sentences max)
@@ -50,13 +49,13 @@ Conversation:
 %s`
 
 // ExtractCells calls the LLM to extract structured cells from a conversation.
-// Returns nil, nil on unparseable output (graceful degradation).
+// It returns an error if the LLM doesn't produce valid cells.
 func ExtractCells(ctx context.Context, svc llm.Service, messages []MessageText) ([]ExtractedCell, error) {
 	transcript := formatTranscript(messages)
 	prompt := fmt.Sprintf(extractionPrompt, transcript)
 
 	req := &llm.Request{
-		System: []llm.SystemContent{{Text: "You extract structured memory cells from conversations. Respond only with a JSON array."}},
+		System: []llm.SystemContent{{Text: "You extract structured memory cells from conversations."}},
 		Messages: []llm.Message{
 			{
 				Role:    llm.MessageRoleUser,
@@ -65,33 +64,14 @@ func ExtractCells(ctx context.Context, svc llm.Service, messages []MessageText)
 		},
 	}
 
-	resp, err := svc.Do(ctx, req)
+	raw, err := llm.DoStructured(ctx, svc, req, &llm.StructuredOptions[[]ExtractedCell]{
+		Name:        "save_cells",
+		Description: "Save the memory cells extracted from the conversation.",
+	})
 	if err != nil {
 		return nil, fmt.Errorf("memory: extract cells: %w", err)
 	}
 
-	// Find the text content in the response.
-	var text string
-	for _, c := range resp.Content {
-		if c.Type == llm.ContentTypeText {
-			text = c.Text
-			break
-		}
-	}
-
-	if text == "" {
-		return nil, nil
-	}
-
-	// Strip code fences if present.
-	text = stripCodeFences(text)
-
-	var raw []ExtractedCell
-	if err := json.Unmarshal([]byte(text), &raw); err != nil {
-		// Graceful degradation: return nil, nil on bad JSON.
-		return nil, nil
-	}
-
 	var cells []ExtractedCell
 	for _, c := range raw {
 		// Skip cells with empty content.
@@ -145,20 +125,3 @@ func formatTranscript(messages []MessageText) string {
 	}
 	return buf.String()
 }
-
-// stripCodeFences removes ```json ... ``` wrapping from LLM output.
-func stripCodeFences(s string) string {
-	s = strings.TrimSpace(s)
-	if strings.HasPrefix(s, "```") {
-		// Remove opening fence line.
-		if idx := strings.Index(s, "\n"); idx != -1 {
-			s = s[idx+1:]
-		}
-		// Remove closing fence.
-		if idx := strings.LastIndex(s, "```"); idx != -1 {
-			s = s[:idx]
-		}
-		s = strings.TrimSpace(s)
-	}
-	return s
-}
@@ -10,9 +10,11 @@ import (
 
 type mockLLMService struct {
 	response string
+	calls    int
 }
 
 func (m *mockLLMService) Do(_ context.Context, _ *llm.Request) (*llm.Response, error) {
+	m.calls++
 	return &llm.Response{
 		Content: []llm.Content{{Type: llm.ContentTypeText, Text: m.response}},
 	}, nil
@@ -63,7 +65,7 @@ func TestExtractCells(t *testing.T) {
 	}
 }
 
-func TestExtractCellsFallbackOnBadJSON(t *testing.T) {
+func TestExtractCellsErrorOnBadJSON(t *testing.T) {
 	mock := &mockLLMService{response: "not valid json"}
 
 	msgs := []memory.MessageText{
@@ -72,11 +74,11 @@ func TestExtractCellsFallbackOnBadJSON(t *testing.T) {
 	}
 
 	cells, err := memory.ExtractCells(context.Background(), mock, msgs)
-	if err != nil {
-		t.Fatalf("expected no error on bad JSON, got: %v", err)
+	if err == nil {
+		t.Fatalf("expected error on bad JSON, got %d cells", len(cells))
 	}
-	if len(cells) != 0 {
-		t.Fatalf("expected empty slice on bad JSON, got %d cells", len(cells))
+	if mock.calls < 2 {
+		t.Errorf("expected bad JSON to be retried, got %d calls", mock.calls)
 	}
 }
 
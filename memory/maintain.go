@@ -0,0 +1,378 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tgruben-circuit/percy/llm"
+)
+
+// sqliteTimeFormat matches the format SQLite uses for CURRENT_TIMESTAMP.
+const sqliteTimeFormat = "2006-01-02 15:04:05"
+
+// MaintenanceOptions controls a RunMaintenance pass. Zero values fall back to
+// the defaults documented on each field.
+type MaintenanceOptions struct {
+	// DryRun computes the report without modifying the database.
+	DryRun bool
+	// DedupThreshold is the cosine similarity at or above which two cells in
+	// the same topic are treated as duplicates. Default 0.95.
+	DedupThreshold float32
+	// SupersedeThreshold is the cosine similarity at or above which a newer
+	// task or decision cell supersedes an older one of the same type in the
+	// same topic. Default 0.85.
+	SupersedeThreshold float32
+	// DecayAfter is how long a cell may go unretrieved before its salience
+	// decays. Default 30 days.
+	DecayAfter time.Duration
+	// DecayFactor multiplies the salience of stale cells. Default 0.8.
+	DecayFactor float64
+	// MinSalience is the floor below which decay does not push salience.
+	// Default 0.1.
+	MinSalience float64
+	// Now overrides the current time. Used by tests.
+	Now time.Time
+	// Lock, if set, is held for the whole pass, including topic
+	// consolidation, so that concurrent passes neither rewrite the same
+	// cells nor consolidate the same topic twice.
+	Lock sync.Locker
+}
+
+func (o MaintenanceOptions) withDefaults() MaintenanceOptions {
+	if o.DedupThreshold <= 0 {
+		o.DedupThreshold = 0.95
+	}
+	if o.SupersedeThreshold <= 0 {
+		o.SupersedeThreshold = 0.85
+	}
+	if o.DecayAfter <= 0 {
+		o.DecayAfter = 30 * 24 * time.Hour
+	}
+	if o.DecayFactor <= 0 || o.DecayFactor >= 1 {
+		o.DecayFactor = 0.8
+	}
+	if o.MinSalience <= 0 {
+		o.MinSalience = 0.1
+	}
+	if o.Now.IsZero() {
+		o.Now = time.Now()
+	}
+	return o
+}
+
+// DuplicateCell records a cell removed as a near-duplicate of another.
+type DuplicateCell struct {
+	CellID     string  `json:"cell_id"`
+	KeptCellID string  `json:"kept_cell_id"`
+	TopicID    string  `json:"topic_id"`
+	Similarity float32 `json:"similarity"`
+	Content    string  `json:"content"`
+}
+
+// DecayedCell records a salience reduction for a stale cell.
+type DecayedCell struct {
+	CellID       string  `json:"cell_id"`
+	OldSalience  float64 `json:"old_salience"`
+	NewSalience  float64 `json:"new_salience"`
+	LastAccessed string  `json:"last_accessed"`
+}
+
+// SupersededCell records an older task or decision cell replaced by a newer one.
+type SupersededCell struct {
+	CellID      string  `json:"cell_id"`
+	NewerCellID string  `json:"newer_cell_id"`
+	TopicID     string  `json:"topic_id"`
+	CellType    string  `json:"cell_type"`
+	Similarity  float32 `json:"similarity"`
+	Content     string  `json:"content"`
+}
+
+// MaintenanceReport describes what a maintenance pass changed, or would
+// change when DryRun is set.
+type MaintenanceReport struct {
+	DryRun       bool             `json:"dry_run"`
+	StartedAt    time.Time        `json:"started_at"`
+	Duplicates   []DuplicateCell  `json:"duplicates"`
+	Superseded   []SupersededCell `json:"superseded"`
+	Decayed      []DecayedCell    `json:"decayed"`
+	Consolidated []string         `json:"consolidated_topics"`
+}
+
+// maintCell is the subset of cell state maintenance needs, including
+// timestamps that Cell does not carry.
+type maintCell struct {
+	CellID       string
+	TopicID      string
+	SourceID     string
+	CellType     string
+	Salience     float64
+	Content      string
+	Vec          []float32
+	CreatedAt    string
+	LastAccessed string // max of created_at, last_accessed_at, decayed_at
+}
+
+// RunMaintenance deduplicates near-identical cells, supersedes contradicted
+// task and decision cells, decays the salience of cells that have not been
+// retrieved recently, and consolidates topics that have accumulated enough
+// new cells. Consolidation requires svc; it is skipped when svc is nil.
+func RunMaintenance(ctx context.Context, db *DB, svc llm.Service, embedder Embedder, opts MaintenanceOptions) (*MaintenanceReport, error) {
+	opts = opts.withDefaults()
+	report := &MaintenanceReport{DryRun: opts.DryRun, StartedAt: opts.Now}
+
+	if opts.Lock != nil {
+		opts.Lock.Lock()
+		defer opts.Lock.Unlock()
+	}
+	topicIDs, err := maintainCells(db, opts, report)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, topicID := range topicIDs {
+		if topicID == "" {
+			continue
+		}
+		needs, err := NeedsConsolidation(db, topicID)
+		if err != nil {
+			slog.Warn("memory: consolidation check failed", "topic_id", topicID, "error", err)
+			continue
+		}
+		if !needs {
+			continue
+		}
+		if opts.DryRun {
+			report.Consolidated = append(report.Consolidated, topicID)
+			continue
+		}
+		if svc == nil {
+			continue
+		}
+		if err := ConsolidateTopic(ctx, db, svc, embedder, topicID); err != nil {
+			slog.Warn("memory: consolidation failed", "topic_id", topicID, "error", err)
+			continue
+		}
+		report.Consolidated = append(report.Consolidated, topicID)
+	}
+
+	return report, nil
+}
+
+// maintainCells fills in the duplicate, superseded and decay sections of
+// report and, unless opts.DryRun is set, applies them. It returns the IDs of
+// the topics that had active cells.
+func maintainCells(db *DB, opts MaintenanceOptions, report *MaintenanceReport) ([]string, error) {
+	cells, err := db.activeCells()
+	if err != nil {
+		return nil, fmt.Errorf("memory: maintenance: %w", err)
+	}
+
+	byTopic := make(map[string][]*maintCell)
+	var topicIDs []string
+	for i := range cells {
+		c := &cells[i]
+		if _, ok := byTopic[c.TopicID]; !ok {
+			topicIDs = append(topicIDs, c.TopicID)
+		}
+		byTopic[c.TopicID] = append(byTopic[c.TopicID], c)
+	}
+	sort.Strings(topicIDs)
+
+	removed := make(map[string]bool)
+	for _, topicID := range topicIDs {
+		group := byTopic[topicID]
+		report.Duplicates = append(report.Duplicates, findDuplicates(group, opts.DedupThreshold, removed)...)
+		report.Superseded = append(report.Superseded, findSuperseded(group, opts.SupersedeThreshold, removed)...)
+	}
+
+	cutoff := opts.Now.Add(-opts.DecayAfter).UTC().Format(sqliteTimeFormat)
+	for _, c := range cells {
+		if removed[c.CellID] || c.LastAccessed >= cutoff || c.Salience <= opts.MinSalience {
+			continue
+		}
+		report.Decayed = append(report.Decayed, DecayedCell{
+			CellID:       c.CellID,
+			OldSalience:  c.Salience,
+			NewSalience:  max(opts.MinSalience, c.Salience*opts.DecayFactor),
+			LastAccessed: c.LastAccessed,
+		})
+	}
+
+	if !opts.DryRun {
+		var ids []string
+		for _, d := range report.Duplicates {
+			ids = append(ids, d.CellID)
+		}
+		for _, s := range report.Superseded {
+			ids = append(ids, s.CellID)
+		}
+		if err := db.SupersedeCells(ids); err != nil {
+			return nil, err
+		}
+		now := opts.Now.UTC().Format(sqliteTimeFormat)
+		for _, d := range report.Decayed {
+			if err := db.decayCell(d.CellID, d.NewSalience, now); err != nil {
+				return nil, err
+			}
+		}
+		for _, topicID := range topicIDs {
+			if topicID == "" {
+				continue
+			}
+			if err := db.refreshTopicCellCount(topicID); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return topicIDs, nil
+}
+
+// findDuplicates marks near-identical cells within one topic. The cell with
+// the highest salience (newest on ties) is kept; the rest are reported.
+func findDuplicates(group []*maintCell, threshold float32, removed map[string]bool) []DuplicateCell {
+	ordered := append([]*maintCell(nil), group...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		if ordered[i].Salience != ordered[j].Salience {
+			return ordered[i].Salience > ordered[j].Salience
+		}
+		return ordered[i].CreatedAt > ordered[j].CreatedAt
+	})
+
+	var dups []DuplicateCell
+	for i, keep := range ordered {
+		if removed[keep.CellID] {
+			continue
+		}
+		for _, other := range ordered[i+1:] {
+			if removed[other.CellID] {
+				continue
+			}
+			sim, ok := cellSimilarity(keep, other)
+			if !ok || sim < threshold {
+				continue
+			}
+			removed[other.CellID] = true
+			dups = append(dups, DuplicateCell{
+				CellID:     other.CellID,
+				KeptCellID: keep.CellID,
+				TopicID:    other.TopicID,
+				Similarity: sim,
+				Content:    other.Content,
+			})
+		}
+	}
+	return dups
+}
+
+// findSuperseded marks older task and decision cells that a newer cell of the
+// same type, from a different source, restates closely enough to replace.
+func findSuperseded(group []*maintCell, threshold float32, removed map[string]bool) []SupersededCell {
+	ordered := append([]*maintCell(nil), group...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].CreatedAt > ordered[j].CreatedAt
+	})
+
+	var out []SupersededCell
+	for i, newer := range ordered {
+		if removed[newer.CellID] || (newer.CellType != "task" && newer.CellType != "decision") {
+			continue
+		}
+		for _, older := range ordered[i+1:] {
+			if removed[older.CellID] || older.CellType != newer.CellType {
+				continue
+			}
+			if older.SourceID == newer.SourceID || older.CreatedAt >= newer.CreatedAt {
+				continue
+			}
+			if newer.Vec == nil || older.Vec == nil || len(newer.Vec) != len(older.Vec) {
+				continue
+			}
+			sim := CosineSimilarity(newer.Vec, older.Vec)
+			if sim < threshold {
+				continue
+			}
+			removed[older.CellID] = true
+			out = append(out, SupersededCell{
+				CellID:      older.CellID,
+				NewerCellID: newer.CellID,
+				TopicID:     older.TopicID,
+				CellType:    older.CellType,
+				Similarity:  sim,
+				Content:     older.Content,
+			})
+		}
+	}
+	return out
+}
+
+// cellSimilarity compares two cells by embedding, falling back to normalized
+// content equality when either lacks a comparable embedding.
+func cellSimilarity(a, b *maintCell) (float32, bool) {
+	if a.Vec != nil && b.Vec != nil && len(a.Vec) == len(b.Vec) {
+		return CosineSimilarity(a.Vec, b.Vec), true
+	}
+	if normalizeContent(a.Content) == normalizeContent(b.Content) {
+		return 1, true
+	}
+	return 0, false
+}
+
+// normalizeContent lowercases s and collapses runs of whitespace.
+func normalizeContent(s string) string {
+	return strings.Join(strings.Fields(strings.ToLower(s)), " ")
+}
+
+// activeCells returns all non-superseded cells with their timestamps.
+func (d *DB) activeCells() ([]maintCell, error) {
+	rows, err := d.db.Query(
+		`SELECT cell_id, COALESCE(topic_id, ''), source_id, cell_type, salience, content, embedding,
+		        COALESCE(strftime('%Y-%m-%d %H:%M:%S', created_at), ''),
+		        COALESCE(strftime('%Y-%m-%d %H:%M:%S', last_accessed_at), ''),
+		        COALESCE(strftime('%Y-%m-%d %H:%M:%S', decayed_at), '')
+		 FROM cells WHERE superseded = FALSE`)
+	if err != nil {
+		return nil, fmt.Errorf("active cells: %w", err)
+	}
+	defer rows.Close()
+
+	var cells []maintCell
+	for rows.Next() {
+		var c maintCell
+		var emb []byte
+		var accessed, decayed string
+		if err := rows.Scan(&c.CellID, &c.TopicID, &c.SourceID, &c.CellType, &c.Salience, &c.Content, &emb, &c.CreatedAt, &accessed, &decayed); err != nil {
+			return nil, fmt.Errorf("scan cell: %w", err)
+		}
+		c.Vec = DeserializeEmbedding(emb)
+		c.LastAccessed = max(c.CreatedAt, accessed, decayed)
+		cells = append(cells, c)
+	}
+	return cells, rows.Err()
+}
+
+// decayCell sets a cell's salience and stamps decayed_at so the next decay
+// waits for another full DecayAfter period.
+func (d *DB) decayCell(cellID string, salience float64, now string) error {
+	_, err := d.db.Exec(`UPDATE cells SET salience = ?, decayed_at = ? WHERE cell_id = ?`, salience, now, cellID)
+	if err != nil {
+		return fmt.Errorf("memory: decay cell: %w", err)
+	}
+	return nil
+}
+
+// refreshTopicCellCount recomputes a topic's non-superseded cell count.
+func (d *DB) refreshTopicCellCount(topicID string) error {
+	_, err := d.db.Exec(
+		`UPDATE topics SET cell_count = (SELECT COUNT(*) FROM cells WHERE topic_id = ? AND superseded = FALSE) WHERE topic_id = ?`,
+		topicID, topicID,
+	)
+	if err != nil {
+		return fmt.Errorf("memory: refresh topic cell_count: %w", err)
+	}
+	return nil
+}
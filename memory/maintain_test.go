@@ -0,0 +1,282 @@
+package memory_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tgruben-circuit/percy/llm"
+	"github.com/tgruben-circuit/percy/memory"
+)
+
+func insertMaintCell(t *testing.T, mdb *memory.DB, c memory.Cell) {
+	t.Helper()
+	if c.SourceType == "" {
+		c.SourceType = "conversation"
+	}
+	if err := mdb.InsertCell(c); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func setCreatedAt(t *testing.T, mdb *memory.DB, cellID, ts string) {
+	t.Helper()
+	var id string
+	if err := mdb.QueryRow(`UPDATE cells SET created_at = ? WHERE cell_id = ? RETURNING cell_id`, ts, cellID).Scan(&id); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRunMaintenanceDedup(t *testing.T) {
+	mdb := openTestDB(t)
+	if err := mdb.UpsertTopic(memory.Topic{TopicID: "t1", Name: "auth"}); err != nil {
+		t.Fatal(err)
+	}
+
+	insertMaintCell(t, mdb, memory.Cell{CellID: "a", TopicID: "t1", SourceID: "c1", CellType: "fact", Salience: 0.9,
+		Content: "Auth uses JWT", Embedding: memory.SerializeEmbedding([]float32{1, 0, 0})})
+	insertMaintCell(t, mdb, memory.Cell{CellID: "b", TopicID: "t1", SourceID: "c2", CellType: "fact", Salience: 0.5,
+		Content: "Authentication is JWT based", Embedding: memory.SerializeEmbedding([]float32{0.99, 0.01, 0})})
+	insertMaintCell(t, mdb, memory.Cell{CellID: "c", TopicID: "t1", SourceID: "c2", CellType: "fact", Salience: 0.5,
+		Content: "Sessions live in Redis", Embedding: memory.SerializeEmbedding([]float32{0, 1, 0})})
+	// No embedding: exact content match still counts as a duplicate.
+	insertMaintCell(t, mdb, memory.Cell{CellID: "d", TopicID: "t1", SourceID: "c3", CellType: "fact", Salience: 0.4,
+		Content: "sessions  live in redis"})
+
+	ctx := context.Background()
+	dry, err := memory.RunMaintenance(ctx, mdb, nil, nil, memory.MaintenanceOptions{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dry.Duplicates) != 2 {
+		t.Fatalf("expected 2 duplicates, got %+v", dry.Duplicates)
+	}
+	if dry.Duplicates[0].CellID != "b" || dry.Duplicates[0].KeptCellID != "a" {
+		t.Errorf("expected b to duplicate a, got %+v", dry.Duplicates[0])
+	}
+	if dry.Duplicates[1].CellID != "d" || dry.Duplicates[1].KeptCellID != "c" {
+		t.Errorf("expected d to duplicate c, got %+v", dry.Duplicates[1])
+	}
+
+	// Dry run must not change anything.
+	cells, err := mdb.GetCellsByTopic("t1", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cells) != 4 {
+		t.Fatalf("dry run changed cells: got %d active", len(cells))
+	}
+
+	report, err := memory.RunMaintenance(ctx, mdb, nil, nil, memory.MaintenanceOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Duplicates) != 2 {
+		t.Fatalf("expected 2 duplicates, got %+v", report.Duplicates)
+	}
+	cells, err = mdb.GetCellsByTopic("t1", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cells) != 2 {
+		t.Fatalf("expected 2 active cells after dedup, got %d", len(cells))
+	}
+	topic, err := mdb.GetTopic("t1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if topic.CellCount != 2 {
+		t.Errorf("expected cell_count 2, got %d", topic.CellCount)
+	}
+}
+
+func TestRunMaintenanceDedupWithoutEmbedding(t *testing.T) {
+	mdb := openTestDB(t)
+	insertMaintCell(t, mdb, memory.Cell{CellID: "x", SourceID: "c1", CellType: "fact", Salience: 0.5, Content: "Port is 8080"})
+	insertMaintCell(t, mdb, memory.Cell{CellID: "y", SourceID: "c2", CellType: "fact", Salience: 0.6, Content: "port is  8080"})
+
+	report, err := memory.RunMaintenance(context.Background(), mdb, nil, nil, memory.MaintenanceOptions{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Duplicates) != 1 || report.Duplicates[0].CellID != "x" {
+		t.Fatalf("expected x to be a duplicate of y, got %+v", report.Duplicates)
+	}
+}
+
+func TestRunMaintenanceSupersede(t *testing.T) {
+	mdb := openTestDB(t)
+	insertMaintCell(t, mdb, memory.Cell{CellID: "old", TopicID: "t1", SourceID: "c1", CellType: "task", Salience: 0.7,
+		Content: "Auth middleware blocked on JWT library", Embedding: memory.SerializeEmbedding([]float32{1, 0.5, 0})})
+	insertMaintCell(t, mdb, memory.Cell{CellID: "new", TopicID: "t1", SourceID: "c2", CellType: "task", Salience: 0.7,
+		Content: "Auth middleware done using golang-jwt", Embedding: memory.SerializeEmbedding([]float32{1, 0, 0})})
+	// Similar to both but a fact, not a task: left alone.
+	insertMaintCell(t, mdb, memory.Cell{CellID: "fact", TopicID: "t1", SourceID: "c0", CellType: "fact", Salience: 0.7,
+		Content: "Auth middleware lives in server/auth.go", Embedding: memory.SerializeEmbedding([]float32{1, 0.25, 0.6})})
+	setCreatedAt(t, mdb, "old", "2020-01-01 00:00:00")
+	setCreatedAt(t, mdb, "new", "2020-02-01 00:00:00")
+
+	report, err := memory.RunMaintenance(context.Background(), mdb, nil, nil, memory.MaintenanceOptions{
+		DryRun: true,
+		Now:    time.Date(2020, 2, 2, 0, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Duplicates) != 0 {
+		t.Fatalf("unexpected duplicates: %+v", report.Duplicates)
+	}
+	if len(report.Superseded) != 1 || report.Superseded[0].CellID != "old" || report.Superseded[0].NewerCellID != "new" {
+		t.Fatalf("unexpected superseded: %+v", report.Superseded)
+	}
+}
+
+func TestRunMaintenanceDecay(t *testing.T) {
+	mdb := openTestDB(t)
+	insertMaintCell(t, mdb, memory.Cell{CellID: "stale", SourceID: "c1", CellType: "fact", Salience: 0.5, Content: "Deploys go through ArgoCD"})
+	insertMaintCell(t, mdb, memory.Cell{CellID: "fresh", SourceID: "c1", CellType: "fact", Salience: 0.5, Content: "Percy listens on port 9000"})
+	setCreatedAt(t, mdb, "stale", "2020-01-01 00:00:00")
+	setCreatedAt(t, mdb, "fresh", "2020-01-01 00:00:00")
+
+	// Retrieving "fresh" resets its decay clock.
+	if err := mdb.TouchCells([]string{"fresh"}); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := memory.MaintenanceOptions{DecayAfter: 24 * time.Hour, DecayFactor: 0.5}
+	report, err := memory.RunMaintenance(context.Background(), mdb, nil, nil, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Decayed) != 1 || report.Decayed[0].CellID != "stale" {
+		t.Fatalf("expected only stale to decay, got %+v", report.Decayed)
+	}
+	if report.Decayed[0].NewSalience != 0.25 {
+		t.Errorf("expected new salience 0.25, got %v", report.Decayed[0].NewSalience)
+	}
+
+	var salience float64
+	if err := mdb.QueryRow(`SELECT salience FROM cells WHERE cell_id = 'stale'`).Scan(&salience); err != nil {
+		t.Fatal(err)
+	}
+	if salience != 0.25 {
+		t.Errorf("expected stored salience 0.25, got %v", salience)
+	}
+
+	// A second pass right away must not decay the same cell again.
+	report, err = memory.RunMaintenance(context.Background(), mdb, nil, nil, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Decayed) != 0 {
+		t.Fatalf("expected no decay on immediate rerun, got %+v", report.Decayed)
+	}
+}
+
+func TestRunMaintenanceConsolidates(t *testing.T) {
+	mdb := openTestDB(t)
+	if err := mdb.UpsertTopic(memory.Topic{TopicID: "t1", Name: "deploy"}); err != nil {
+		t.Fatal(err)
+	}
+	for i, content := range []string{"Uses ArgoCD", "Staging is on k3s", "Prod is on EKS", "Helm charts in deploy/", "Rollbacks via argocd app rollback"} {
+		insertMaintCell(t, mdb, memory.Cell{CellID: string(rune('a' + i)), TopicID: "t1", SourceID: "c1", CellType: "fact", Salience: 0.6, Content: content})
+	}
+
+	dry, err := memory.RunMaintenance(context.Background(), mdb, nil, nil, memory.MaintenanceOptions{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dry.Consolidated) != 1 || dry.Consolidated[0] != "t1" {
+		t.Fatalf("expected t1 to need consolidation, got %v", dry.Consolidated)
+	}
+
+	mock := &consolidationMockLLM{response: `{"summary": "Deploys use ArgoCD and Helm.", "superseded_cell_ids": []}`}
+	report, err := memory.RunMaintenance(context.Background(), mdb, mock, nil, memory.MaintenanceOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Consolidated) != 1 {
+		t.Fatalf("expected one consolidated topic, got %v", report.Consolidated)
+	}
+	topic, err := mdb.GetTopic("t1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if topic.Summary != "Deploys use ArgoCD and Helm." {
+		t.Errorf("unexpected summary %q", topic.Summary)
+	}
+}
+
+// countingLLM counts the requests it answers, each of which takes a while,
+// as a real model's do.
+type countingLLM struct {
+	consolidationMockLLM
+	calls atomic.Int32
+}
+
+func (m *countingLLM) Do(ctx context.Context, req *llm.Request) (*llm.Response, error) {
+	m.calls.Add(1)
+	time.Sleep(50 * time.Millisecond)
+	return m.consolidationMockLLM.Do(ctx, req)
+}
+
+func TestRunMaintenanceConcurrentConsolidatesOnce(t *testing.T) {
+	mdb := openTestDB(t)
+	if err := mdb.UpsertTopic(memory.Topic{TopicID: "t1", Name: "deploy"}); err != nil {
+		t.Fatal(err)
+	}
+	var id string
+	if err := mdb.QueryRow(`UPDATE topics SET updated_at = '2020-01-01 00:00:00' WHERE topic_id = 't1' RETURNING topic_id`).Scan(&id); err != nil {
+		t.Fatal(err)
+	}
+	for i, content := range []string{"Uses ArgoCD", "Staging is on k3s", "Prod is on EKS", "Helm charts in deploy/", "Rollbacks via argocd app rollback"} {
+		cellID := string(rune('a' + i))
+		insertMaintCell(t, mdb, memory.Cell{CellID: cellID, TopicID: "t1", SourceID: "c1", CellType: "fact", Salience: 0.6, Content: content})
+		setCreatedAt(t, mdb, cellID, "2020-06-01 00:00:00")
+	}
+
+	// A scheduled pass and a manual one share the server's lock.
+	var mu sync.Mutex
+	mock := &countingLLM{consolidationMockLLM: consolidationMockLLM{response: `{"summary": "Deploys use ArgoCD and Helm.", "superseded_cell_ids": []}`}}
+	var wg sync.WaitGroup
+	for range 2 {
+		wg.Go(func() {
+			if _, err := memory.RunMaintenance(context.Background(), mdb, mock, nil, memory.MaintenanceOptions{Lock: &mu}); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+	wg.Wait()
+	if n := mock.calls.Load(); n != 1 {
+		t.Errorf("topic consolidated %d times, want 1", n)
+	}
+}
+
+func TestRunMaintenanceTopicSearchPreventsDecay(t *testing.T) {
+	mdb := openTestDB(t)
+	if err := mdb.UpsertTopic(memory.Topic{TopicID: "topic_deploy", Name: "deploys", Summary: "Deploys go through ArgoCD"}); err != nil {
+		t.Fatal(err)
+	}
+	insertMaintCell(t, mdb, memory.Cell{CellID: "summarized", TopicID: "topic_deploy", SourceID: "c1", CellType: "fact", Salience: 0.5, Content: "Rollouts are canaried for an hour"})
+	setCreatedAt(t, mdb, "summarized", "2020-01-01 00:00:00")
+
+	// Only the topic summary matches, yet its cells count as retrieved.
+	results, err := mdb.TwoTierSearch("ArgoCD", nil, "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) == 0 || results[0].ResultType != "topic_summary" {
+		t.Fatalf("expected a topic summary hit, got %+v", results)
+	}
+
+	opts := memory.MaintenanceOptions{DecayAfter: 24 * time.Hour, DryRun: true}
+	report, err := memory.RunMaintenance(context.Background(), mdb, nil, nil, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Decayed) != 0 {
+		t.Fatalf("expected no decay after topic retrieval, got %+v", report.Decayed)
+	}
+}
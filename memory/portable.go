@@ -0,0 +1,457 @@
+package memory
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// exportVersion is the JSONL format version written in the export header.
+const exportVersion = 1
+
+// Record kinds in an export stream.
+const (
+	recordHeader = "header"
+	recordTopic  = "topic"
+	recordCell   = "cell"
+)
+
+// ExportRecord is one line of a memory export. Kind selects which fields
+// are meaningful. Embeddings are base64-encoded little-endian float32s and
+// are only present when exported with IncludeEmbeddings.
+type ExportRecord struct {
+	Kind string `json:"kind"`
+
+	// Header fields.
+	Version    int       `json:"version,omitempty"`
+	Embedder   string    `json:"embedder,omitempty"`
+	ExportedAt time.Time `json:"exported_at,omitzero"`
+
+	// Shared by topics and cells.
+	TopicID   string `json:"topic_id,omitempty"`
+	Embedding []byte `json:"embedding,omitempty"`
+
+	// Topic fields.
+	Name      string `json:"name,omitempty"`
+	Summary   string `json:"summary,omitempty"`
+	UpdatedAt string `json:"updated_at,omitempty"`
+
+	// Cell fields. SourceName carries the conversation slug or file name.
+	CellID     string  `json:"cell_id,omitempty"`
+	SourceType string  `json:"source_type,omitempty"`
+	SourceID   string  `json:"source_id,omitempty"`
+	SourceName string  `json:"source_name,omitempty"`
+	CellType   string  `json:"cell_type,omitempty"`
+	Salience   float64 `json:"salience,omitempty"`
+	Content    string  `json:"content,omitempty"`
+	CreatedAt  string  `json:"created_at,omitempty"`
+	Superseded bool    `json:"superseded,omitempty"`
+}
+
+// ExportOptions controls Export.
+type ExportOptions struct {
+	// IncludeEmbeddings writes stored vectors alongside each record.
+	IncludeEmbeddings bool
+	// IncludeSuperseded also exports cells marked superseded.
+	IncludeSuperseded bool
+}
+
+// Export writes all topics and cells to w as JSONL, preceded by a header
+// record naming the embedder that produced the stored vectors.
+func (d *DB) Export(ctx context.Context, w io.Writer, opts ExportOptions) error {
+	embedder, err := d.StoredEmbedder()
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(w)
+	header := ExportRecord{Kind: recordHeader, Version: exportVersion, ExportedAt: time.Now().UTC()}
+	if opts.IncludeEmbeddings {
+		header.Embedder = embedder
+	}
+	if err := enc.Encode(header); err != nil {
+		return fmt.Errorf("memory: export: %w", err)
+	}
+
+	topicRows, err := d.db.QueryContext(ctx,
+		`SELECT topic_id, name, COALESCE(summary, ''), embedding, COALESCE(strftime('%Y-%m-%d %H:%M:%S', updated_at), '')
+		 FROM topics ORDER BY rowid`)
+	if err != nil {
+		return fmt.Errorf("memory: export topics: %w", err)
+	}
+	defer topicRows.Close()
+	for topicRows.Next() {
+		rec := ExportRecord{Kind: recordTopic}
+		if err := topicRows.Scan(&rec.TopicID, &rec.Name, &rec.Summary, &rec.Embedding, &rec.UpdatedAt); err != nil {
+			return fmt.Errorf("memory: export scan topic: %w", err)
+		}
+		if !opts.IncludeEmbeddings {
+			rec.Embedding = nil
+		}
+		if err := enc.Encode(rec); err != nil {
+			return fmt.Errorf("memory: export: %w", err)
+		}
+	}
+	if err := topicRows.Err(); err != nil {
+		return fmt.Errorf("memory: export topics: %w", err)
+	}
+
+	q := `SELECT cell_id, COALESCE(topic_id, ''), source_type, source_id, COALESCE(source_name, ''), cell_type,
+	             salience, content, embedding, COALESCE(strftime('%Y-%m-%d %H:%M:%S', created_at), ''), COALESCE(superseded, FALSE)
+	      FROM cells`
+	if !opts.IncludeSuperseded {
+		q += ` WHERE superseded = FALSE`
+	}
+	q += ` ORDER BY rowid`
+	cellRows, err := d.db.QueryContext(ctx, q)
+	if err != nil {
+		return fmt.Errorf("memory: export cells: %w", err)
+	}
+	defer cellRows.Close()
+	for cellRows.Next() {
+		rec := ExportRecord{Kind: recordCell}
+		if err := cellRows.Scan(&rec.CellID, &rec.TopicID, &rec.SourceType, &rec.SourceID, &rec.SourceName, &rec.CellType,
+			&rec.Salience, &rec.Content, &rec.Embedding, &rec.CreatedAt, &rec.Superseded); err != nil {
+			return fmt.Errorf("memory: export scan cell: %w", err)
+		}
+		if !opts.IncludeEmbeddings {
+			rec.Embedding = nil
+		}
+		if err := enc.Encode(rec); err != nil {
+			return fmt.Errorf("memory: export: %w", err)
+		}
+	}
+	return cellRows.Err()
+}
+
+// ImportReport summarizes an Import.
+type ImportReport struct {
+	TopicsCreated     int `json:"topics_created"`
+	TopicsMerged      int `json:"topics_merged"`
+	CellsImported     int `json:"cells_imported"`
+	CellsDuplicate    int `json:"cells_duplicate"`
+	CellsReembedded   int `json:"cells_reembedded"`
+	EmbeddingsDropped int `json:"embeddings_dropped"`
+}
+
+// importDedupThreshold is the similarity at which an imported cell is
+// considered already present.
+const importDedupThreshold = 0.95
+
+// Import merges an Export stream into the database. Topics are matched by
+// ID, then by name; unmatched topics are created. Cells whose ID already
+// exists, or whose content matches an existing active cell in the same
+// topic (by normalized text or embedding similarity), are skipped.
+//
+// Imported embeddings are kept only when the export's embedder matches the
+// one recorded for this database. Otherwise, if embedder is non-nil, the
+// imported topics and cells are embedded with it. Embedding happens in
+// batches before the write transaction opens, so a slow embedder does not
+// hold up other memory writes.
+//
+// The import runs in a single transaction: if any record fails, nothing is
+// written and the returned report is nil.
+func (d *DB) Import(ctx context.Context, r io.Reader, embedder Embedder) (*ImportReport, error) {
+	records, err := readExport(r)
+	if err != nil {
+		return nil, err
+	}
+	embedded, err := d.embedImportRecords(ctx, records, embedder)
+	if err != nil {
+		return nil, err
+	}
+
+	var report *ImportReport
+	err = d.inTx(ctx, func(tx *DB) error {
+		var err error
+		report, err = tx.importRecords(ctx, records, embedded)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// readExport parses an Export stream, rejecting unknown record kinds and
+// newer format versions.
+func readExport(r io.Reader) ([]ExportRecord, error) {
+	var records []ExportRecord
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var rec ExportRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("memory: import line %d: %w", line, err)
+		}
+		switch rec.Kind {
+		case recordHeader:
+			if rec.Version > exportVersion {
+				return nil, fmt.Errorf("memory: import: unsupported export version %d", rec.Version)
+			}
+		case recordTopic, recordCell:
+		default:
+			return nil, fmt.Errorf("memory: import line %d: unknown record kind %q", line, rec.Kind)
+		}
+		records = append(records, rec)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("memory: import: %w", err)
+	}
+	return records, nil
+}
+
+// keepImportedEmbeddings reports whether vectors from an export made with
+// exported can be stored in a database whose vectors came from local.
+func keepImportedEmbeddings(exported, local string) bool {
+	return exported != "" && (local == "" || exported == local)
+}
+
+// importEmbedBatch is the number of texts embedded per call during Import.
+const importEmbedBatch = 64
+
+// embedImportRecords computes fresh embeddings for the records Import will
+// store without their exported vectors, keyed by index into records. Topics
+// that will merge into existing ones and cells that already exist are
+// skipped.
+func (d *DB) embedImportRecords(ctx context.Context, records []ExportRecord, embedder Embedder) (map[int][]byte, error) {
+	if embedder == nil {
+		return nil, nil
+	}
+	local, err := d.StoredEmbedder()
+	if err != nil {
+		return nil, err
+	}
+	existingTopics, err := d.AllTopics()
+	if err != nil {
+		return nil, fmt.Errorf("memory: import: %w", err)
+	}
+	topicKnown := make(map[string]bool, 2*len(existingTopics))
+	for _, t := range existingTopics {
+		topicKnown[t.TopicID] = true
+		topicKnown["name:"+normalizeName(t.Name)] = true
+	}
+
+	var indexes []int
+	var texts []string
+	keep := false
+	for i, rec := range records {
+		var text string
+		switch rec.Kind {
+		case recordHeader:
+			keep = keepImportedEmbeddings(rec.Embedder, local)
+			continue
+		case recordTopic:
+			name := "name:" + normalizeName(rec.Name)
+			if topicKnown[rec.TopicID] || topicKnown[name] {
+				continue
+			}
+			topicKnown[rec.TopicID] = true
+			topicKnown[name] = true
+			text = topicText(rec)
+		case recordCell:
+			if d.cellExists(rec.CellID) {
+				continue
+			}
+			text = rec.Content
+		}
+		if text == "" || (keep && rec.Embedding != nil) {
+			continue
+		}
+		indexes = append(indexes, i)
+		texts = append(texts, text)
+	}
+
+	embedded := make(map[int][]byte, len(texts))
+	for start := 0; start < len(texts); start += importEmbedBatch {
+		end := min(start+importEmbedBatch, len(texts))
+		vecs, err := embedder.Embed(ctx, texts[start:end])
+		if err != nil {
+			return nil, fmt.Errorf("memory: import embed: %w", err)
+		}
+		for j, vec := range vecs {
+			if start+j < end && vec != nil {
+				embedded[indexes[start+j]] = SerializeEmbedding(vec)
+			}
+		}
+	}
+	return embedded, nil
+}
+
+// importRecords does the work of Import. It must run inside a transaction.
+// embedded holds the precomputed embeddings from embedImportRecords.
+func (d *DB) importRecords(ctx context.Context, records []ExportRecord, embedded map[int][]byte) (*ImportReport, error) {
+	report := &ImportReport{}
+
+	localEmbedder, err := d.StoredEmbedder()
+	if err != nil {
+		return nil, err
+	}
+
+	existingTopics, err := d.AllTopics()
+	if err != nil {
+		return nil, fmt.Errorf("memory: import: %w", err)
+	}
+	topicByName := make(map[string]string, len(existingTopics))
+	topicExists := make(map[string]bool, len(existingTopics))
+	for _, t := range existingTopics {
+		topicByName[normalizeName(t.Name)] = t.TopicID
+		topicExists[t.TopicID] = true
+	}
+
+	active, err := d.activeCells()
+	if err != nil {
+		return nil, fmt.Errorf("memory: import: %w", err)
+	}
+	byTopic := make(map[string][]*maintCell)
+	for i := range active {
+		c := &active[i]
+		byTopic[c.TopicID] = append(byTopic[c.TopicID], c)
+	}
+
+	topicMap := make(map[string]string) // imported topic ID -> local topic ID
+	touched := make(map[string]bool)
+	keepEmbeddings := false
+
+	for i, rec := range records {
+		switch rec.Kind {
+		case recordHeader:
+			keepEmbeddings = keepImportedEmbeddings(rec.Embedder, localEmbedder)
+			if keepEmbeddings && localEmbedder == "" {
+				if err := d.setStoredEmbedder(rec.Embedder); err != nil {
+					return report, err
+				}
+				localEmbedder = rec.Embedder
+			}
+
+		case recordTopic:
+			if topicExists[rec.TopicID] {
+				topicMap[rec.TopicID] = rec.TopicID
+				report.TopicsMerged++
+				continue
+			}
+			if id, ok := topicByName[normalizeName(rec.Name)]; ok {
+				topicMap[rec.TopicID] = id
+				report.TopicsMerged++
+				continue
+			}
+			emb, _ := importEmbedding(rec.Embedding, embedded[i], keepEmbeddings, report)
+			if err := d.UpsertTopic(Topic{TopicID: rec.TopicID, Name: rec.Name, Summary: rec.Summary, Embedding: emb}); err != nil {
+				return report, err
+			}
+			topicMap[rec.TopicID] = rec.TopicID
+			topicExists[rec.TopicID] = true
+			topicByName[normalizeName(rec.Name)] = rec.TopicID
+			report.TopicsCreated++
+
+		case recordCell:
+			if rec.Content == "" {
+				continue
+			}
+			if id, ok := topicMap[rec.TopicID]; ok {
+				rec.TopicID = id
+			}
+			if d.cellExists(rec.CellID) {
+				report.CellsDuplicate++
+				continue
+			}
+			emb, reembedded := importEmbedding(rec.Embedding, embedded[i], keepEmbeddings, report)
+			candidate := &maintCell{Content: rec.Content, Vec: DeserializeEmbedding(emb)}
+			if !rec.Superseded && isDuplicateOf(candidate, byTopic[rec.TopicID]) {
+				report.CellsDuplicate++
+				continue
+			}
+			rec.Embedding = emb
+			if err := d.insertImportedCell(ctx, rec); err != nil {
+				return report, err
+			}
+			if !rec.Superseded {
+				candidate.CellID = rec.CellID
+				byTopic[rec.TopicID] = append(byTopic[rec.TopicID], candidate)
+			}
+			if reembedded {
+				report.CellsReembedded++
+			}
+			if rec.TopicID != "" {
+				touched[rec.TopicID] = true
+			}
+			report.CellsImported++
+		}
+	}
+
+	for topicID := range touched {
+		if err := d.refreshTopicCellCount(topicID); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+// importEmbedding decides which embedding to store for an imported record:
+// the exported one when compatible, the fresh one computed before the
+// transaction, or none.
+func importEmbedding(exported, fresh []byte, keep bool, report *ImportReport) ([]byte, bool) {
+	if keep && exported != nil {
+		return exported, false
+	}
+	if exported != nil {
+		report.EmbeddingsDropped++
+	}
+	if fresh == nil {
+		return nil, false
+	}
+	return fresh, true
+}
+
+// isDuplicateOf reports whether c matches any cell in existing.
+func isDuplicateOf(c *maintCell, existing []*maintCell) bool {
+	for _, e := range existing {
+		if sim, ok := cellSimilarity(c, e); ok && sim >= importDedupThreshold {
+			return true
+		}
+	}
+	return false
+}
+
+func topicText(rec ExportRecord) string {
+	if rec.Summary != "" {
+		return rec.Summary
+	}
+	return rec.Name
+}
+
+func (d *DB) cellExists(cellID string) bool {
+	var n int
+	_ = d.db.QueryRow(`SELECT COUNT(*) FROM cells WHERE cell_id = ?`, cellID).Scan(&n)
+	return n > 0
+}
+
+// insertImportedCell inserts a cell preserving its provenance timestamps and
+// superseded flag, which InsertCell does not carry.
+func (d *DB) insertImportedCell(ctx context.Context, rec ExportRecord) error {
+	var topicID any
+	if rec.TopicID != "" {
+		topicID = rec.TopicID
+	}
+	var createdAt any
+	if rec.CreatedAt != "" {
+		createdAt = rec.CreatedAt
+	}
+	_, err := d.db.ExecContext(ctx,
+		`INSERT INTO cells (cell_id, topic_id, source_type, source_id, source_name, cell_type, salience, content, embedding, created_at, superseded)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, COALESCE(?, CURRENT_TIMESTAMP), ?)`,
+		rec.CellID, topicID, rec.SourceType, rec.SourceID, rec.SourceName, rec.CellType, rec.Salience, rec.Content, rec.Embedding, createdAt, rec.Superseded,
+	)
+	if err != nil {
+		return fmt.Errorf("memory: import cell %s: %w", rec.CellID, err)
+	}
+	return nil
+}
@@ -0,0 +1,263 @@
+package memory_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tgruben-circuit/percy/memory"
+)
+
+func seedExportDB(t *testing.T, mdb *memory.DB) {
+	t.Helper()
+	if err := mdb.UpsertTopic(memory.Topic{TopicID: "topic-auth", Name: "Authentication", Summary: "Auth uses JWT."}); err != nil {
+		t.Fatal(err)
+	}
+	cells := []memory.Cell{
+		{CellID: "c1", TopicID: "topic-auth", SourceType: "conversation", SourceID: "conv-1", SourceName: "auth-design", CellType: "decision", Salience: 0.9, Content: "Auth uses JWT with RS256"},
+		{CellID: "c2", TopicID: "topic-auth", SourceType: "conversation", SourceID: "conv-1", SourceName: "auth-design", CellType: "fact", Salience: 0.6, Content: "Tokens expire after one hour"},
+		{CellID: "c3", TopicID: "topic-auth", SourceType: "conversation", SourceID: "conv-0", SourceName: "old-auth", CellType: "decision", Salience: 0.4, Content: "Auth uses sessions"},
+	}
+	for _, c := range cells {
+		if err := mdb.InsertCell(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := mdb.SupersedeCells([]string{"c3"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	src := openTestDB(t)
+	seedExportDB(t, src)
+
+	var buf bytes.Buffer
+	if err := src.Export(context.Background(), &buf, memory.ExportOptions{IncludeSuperseded: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("expected header + 1 topic + 3 cells, got %d lines:\n%s", len(lines), buf.String())
+	}
+	var header memory.ExportRecord
+	if err := json.Unmarshal([]byte(lines[0]), &header); err != nil {
+		t.Fatal(err)
+	}
+	if header.Kind != "header" || header.Version != 1 {
+		t.Fatalf("unexpected header: %+v", header)
+	}
+	var cell memory.ExportRecord
+	if err := json.Unmarshal([]byte(lines[2]), &cell); err != nil {
+		t.Fatal(err)
+	}
+	if cell.SourceName != "auth-design" || cell.CreatedAt == "" {
+		t.Errorf("expected provenance on cell record, got %+v", cell)
+	}
+
+	dst := openTestDB(t)
+	report, err := dst.Import(context.Background(), bytes.NewReader(buf.Bytes()), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.TopicsCreated != 1 || report.CellsImported != 3 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+
+	active, err := dst.GetCellsByTopic("topic-auth", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(active) != 2 {
+		t.Errorf("expected 2 active cells (superseded flag preserved), got %d", len(active))
+	}
+	topic, err := dst.GetTopic("topic-auth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if topic.Summary != "Auth uses JWT." || topic.CellCount != 2 {
+		t.Errorf("unexpected topic: %+v", topic)
+	}
+
+	// Importing again is a no-op.
+	report, err = dst.Import(context.Background(), bytes.NewReader(buf.Bytes()), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.CellsImported != 0 || report.CellsDuplicate != 3 || report.TopicsMerged != 1 {
+		t.Fatalf("expected idempotent re-import, got %+v", report)
+	}
+}
+
+func TestImportRollsBackOnBadRecord(t *testing.T) {
+	src := openTestDB(t)
+	seedExportDB(t, src)
+	var buf bytes.Buffer
+	if err := src.Export(context.Background(), &buf, memory.ExportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Corrupt a record after the topic and first cell have been written.
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	bad := append(append(lines[:3:3], `{"kind":"bogus"}`), lines[3:]...)
+
+	dst := openTestDB(t)
+	report, err := dst.Import(context.Background(), strings.NewReader(strings.Join(bad, "\n")), nil)
+	if err == nil {
+		t.Fatalf("expected error, got report %+v", report)
+	}
+	if report != nil {
+		t.Errorf("expected nil report on failure, got %+v", report)
+	}
+	topics, err := dst.AllTopics()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(topics) != 0 {
+		t.Errorf("expected no topics after failed import, got %d", len(topics))
+	}
+	var n int
+	if err := dst.QueryRow(`SELECT COUNT(*) FROM cells`).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("expected no cells after failed import, got %d", n)
+	}
+}
+
+func TestImportMergesByTopicNameAndContent(t *testing.T) {
+	dst := openTestDB(t)
+	if err := dst.UpsertTopic(memory.Topic{TopicID: "local-auth", Name: "authentication"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := dst.InsertCell(memory.Cell{CellID: "mine", TopicID: "local-auth", SourceType: "conversation", SourceID: "x",
+		CellType: "fact", Salience: 0.5, Content: "Tokens expire after ONE hour"}); err != nil {
+		t.Fatal(err)
+	}
+
+	src := openTestDB(t)
+	seedExportDB(t, src)
+	var buf bytes.Buffer
+	if err := src.Export(context.Background(), &buf, memory.ExportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := dst.Import(context.Background(), &buf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.TopicsMerged != 1 || report.TopicsCreated != 0 {
+		t.Errorf("expected topic merged by name, got %+v", report)
+	}
+	if report.CellsImported != 1 || report.CellsDuplicate != 1 {
+		t.Errorf("expected 1 imported and 1 duplicate, got %+v", report)
+	}
+	cells, err := dst.GetCellsByTopic("local-auth", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cells) != 2 {
+		t.Errorf("expected imported cell under the local topic, got %d cells", len(cells))
+	}
+}
+
+func TestImportEmbeddings(t *testing.T) {
+	src := openTestDB(t)
+	seedExportDB(t, src)
+	if err := src.Reembed(context.Background(), memory.NewLocalEmbedder(32), 0, nil); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := src.Export(context.Background(), &buf, memory.ExportOptions{IncludeEmbeddings: true}); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	// Same embedder: vectors are kept as-is.
+	same := openTestDB(t)
+	report, err := same.Import(context.Background(), bytes.NewReader(data), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.EmbeddingsDropped != 0 {
+		t.Errorf("expected embeddings kept, got %+v", report)
+	}
+	cells, _ := same.GetCellsByTopic("topic-auth", false)
+	for _, c := range cells {
+		if len(memory.DeserializeEmbedding(c.Embedding)) != 32 {
+			t.Errorf("cell %s: expected 32-dim embedding", c.CellID)
+		}
+	}
+
+	// Different embedder: vectors are recomputed locally.
+	other := openTestDB(t)
+	if _, err := other.CheckEmbedder(context.Background(), memory.NewLocalEmbedder(16)); err != nil {
+		t.Fatal(err)
+	}
+	report, err = other.Import(context.Background(), bytes.NewReader(data), memory.NewLocalEmbedder(16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.EmbeddingsDropped != 3 || report.CellsReembedded != 2 {
+		t.Errorf("expected foreign embeddings replaced, got %+v", report)
+	}
+	cells, _ = other.GetCellsByTopic("topic-auth", false)
+	for _, c := range cells {
+		if len(memory.DeserializeEmbedding(c.Embedding)) != 16 {
+			t.Errorf("cell %s: expected 16-dim embedding", c.CellID)
+		}
+	}
+}
+
+// probingEmbedder checks that the database is usable while it embeds, which
+// fails if Import calls it with the write transaction open.
+type probingEmbedder struct {
+	memory.Embedder
+	t     *testing.T
+	mdb   *memory.DB
+	calls int
+}
+
+func (p *probingEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	p.calls++
+	done := make(chan error, 1)
+	go func() {
+		var n int
+		done <- p.mdb.QueryRow(`SELECT COUNT(*) FROM cells`).Scan(&n)
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			p.t.Error(err)
+		}
+	case <-time.After(2 * time.Second):
+		p.t.Error("database blocked while embedding")
+	}
+	return p.Embedder.Embed(ctx, texts)
+}
+
+func TestImportEmbedsOutsideTransaction(t *testing.T) {
+	src := openTestDB(t)
+	seedExportDB(t, src)
+	var buf bytes.Buffer
+	if err := src.Export(context.Background(), &buf, memory.ExportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := openTestDB(t)
+	e := &probingEmbedder{Embedder: memory.NewLocalEmbedder(16), t: t, mdb: dst}
+	report, err := dst.Import(context.Background(), &buf, e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.calls != 1 {
+		t.Errorf("expected one batched embed call, got %d", e.calls)
+	}
+	if report.CellsReembedded != 2 {
+		t.Errorf("expected 2 cells embedded, got %+v", report)
+	}
+}
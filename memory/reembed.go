@@ -0,0 +1,200 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// metaKeyEmbedder records which embedder produced the stored vectors.
+const metaKeyEmbedder = "embedder"
+
+// EmbedderName returns a stable identifier for e, such as
+// "openai:text-embedding-3-small". Embedders without a Name method are
+// identified by their Go type. A nil embedder is "none".
+func EmbedderName(e Embedder) string {
+	if e == nil {
+		return "none"
+	}
+	if n, ok := e.(interface{ Name() string }); ok {
+		return n.Name()
+	}
+	return fmt.Sprintf("%T", e)
+}
+
+// StoredEmbedder returns the name of the embedder recorded for the stored
+// vectors, or "" if none has been recorded.
+func (d *DB) StoredEmbedder() (string, error) {
+	var name string
+	err := d.db.QueryRow(`SELECT value FROM meta WHERE key = ?`, metaKeyEmbedder).Scan(&name)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("memory: stored embedder: %w", err)
+	}
+	return name, nil
+}
+
+func (d *DB) setStoredEmbedder(name string) error {
+	_, err := d.db.Exec(`INSERT OR REPLACE INTO meta (key, value) VALUES (?, ?)`, metaKeyEmbedder, name)
+	if err != nil {
+		return fmt.Errorf("memory: set stored embedder: %w", err)
+	}
+	return nil
+}
+
+// CheckEmbedder compares e against the embedder recorded for the stored
+// vectors. It returns false when the stored vectors came from a different
+// embedder and need re-embedding with Reembed.
+//
+// When no embedder has been recorded yet, e is recorded only if no vectors
+// are stored, or if every stored vector has e's dimension. Otherwise the
+// origin of the vectors is unknown, so nothing is recorded and CheckEmbedder
+// keeps returning false until Reembed runs. Embedders whose Dimension is
+// not known up front, such as Ollama, are probed with one Embed call.
+func (d *DB) CheckEmbedder(ctx context.Context, e Embedder) (bool, error) {
+	stored, err := d.StoredEmbedder()
+	if err != nil {
+		return false, err
+	}
+	name := EmbedderName(e)
+	if stored != "" {
+		return stored == name, nil
+	}
+
+	dims, err := d.storedDimensions()
+	if err != nil {
+		return false, err
+	}
+	if len(dims) > 0 {
+		dim, err := embedderDimension(ctx, e)
+		if err != nil {
+			return false, err
+		}
+		if dim <= 0 || len(dims) != 1 || dims[0] != dim {
+			return false, nil
+		}
+	}
+	return true, d.setStoredEmbedder(name)
+}
+
+// embedderDimension returns e's dimension, embedding a probe string when e
+// does not report one. A nil embedder has dimension 0.
+func embedderDimension(ctx context.Context, e Embedder) (int, error) {
+	if e == nil {
+		return 0, nil
+	}
+	if dim := e.Dimension(); dim > 0 {
+		return dim, nil
+	}
+	vecs, err := e.Embed(ctx, []string{"dimension probe"})
+	if err != nil {
+		return 0, fmt.Errorf("memory: probe embedder dimension: %w", err)
+	}
+	if len(vecs) == 0 {
+		return 0, nil
+	}
+	return len(vecs[0]), nil
+}
+
+// storedDimensions returns the distinct dimensions of the stored cell and
+// topic embeddings.
+func (d *DB) storedDimensions() ([]int, error) {
+	rows, err := d.db.Query(
+		`SELECT DISTINCT length(embedding) / 4 FROM cells WHERE embedding IS NOT NULL AND length(embedding) > 0
+		 UNION
+		 SELECT DISTINCT length(embedding) / 4 FROM topics WHERE embedding IS NOT NULL AND length(embedding) > 0`)
+	if err != nil {
+		return nil, fmt.Errorf("memory: stored dimensions: %w", err)
+	}
+	defer rows.Close()
+	var dims []int
+	for rows.Next() {
+		var dim int
+		if err := rows.Scan(&dim); err != nil {
+			return nil, fmt.Errorf("memory: stored dimensions: %w", err)
+		}
+		dims = append(dims, dim)
+	}
+	return dims, rows.Err()
+}
+
+// ReembedProgress is called after each batch with the number of items
+// processed so far and the total.
+type ReembedProgress func(done, total int)
+
+// Reembed recomputes every cell and topic embedding with e and records e as
+// the stored embedder. A nil embedder clears all embeddings.
+func (d *DB) Reembed(ctx context.Context, e Embedder, batchSize int, progress ReembedProgress) error {
+	if batchSize <= 0 {
+		batchSize = 64
+	}
+
+	type item struct{ id, text string }
+	load := func(q string) ([]item, error) {
+		rows, err := d.db.QueryContext(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		var items []item
+		for rows.Next() {
+			var it item
+			if err := rows.Scan(&it.id, &it.text); err != nil {
+				return nil, err
+			}
+			items = append(items, it)
+		}
+		return items, rows.Err()
+	}
+
+	cells, err := load(`SELECT cell_id, content FROM cells ORDER BY rowid`)
+	if err != nil {
+		return fmt.Errorf("memory: reembed: load cells: %w", err)
+	}
+	topics, err := load(`SELECT topic_id, CASE WHEN COALESCE(summary, '') = '' THEN name ELSE summary END FROM topics ORDER BY rowid`)
+	if err != nil {
+		return fmt.Errorf("memory: reembed: load topics: %w", err)
+	}
+
+	total := len(cells) + len(topics)
+	done := 0
+	for _, pass := range []struct {
+		items []item
+		query string
+	}{
+		{cells, `UPDATE cells SET embedding = ? WHERE cell_id = ?`},
+		{topics, `UPDATE topics SET embedding = ? WHERE topic_id = ?`},
+	} {
+		for start := 0; start < len(pass.items); start += batchSize {
+			batch := pass.items[start:min(start+batchSize, len(pass.items))]
+			var vecs [][]float32
+			if e != nil {
+				texts := make([]string, len(batch))
+				for i, it := range batch {
+					texts[i] = it.text
+				}
+				vecs, err = e.Embed(ctx, texts)
+				if err != nil {
+					return fmt.Errorf("memory: reembed: %w", err)
+				}
+			}
+			for i, it := range batch {
+				var blob []byte
+				if i < len(vecs) {
+					blob = SerializeEmbedding(vecs[i])
+				}
+				if _, err := d.db.ExecContext(ctx, pass.query, blob, it.id); err != nil {
+					return fmt.Errorf("memory: reembed: update %s: %w", it.id, err)
+				}
+			}
+			done += len(batch)
+			if progress != nil {
+				progress(done, total)
+			}
+		}
+	}
+
+	return d.setStoredEmbedder(EmbedderName(e))
+}
@@ -0,0 +1,146 @@
+package memory_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tgruben-circuit/percy/memory"
+)
+
+func TestReembed(t *testing.T) {
+	mdb := openTestDB(t)
+	if err := mdb.UpsertTopic(memory.Topic{TopicID: "t1", Name: "auth", Embedding: memory.SerializeEmbedding([]float32{1, 2, 3})}); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"a", "b", "c"} {
+		err := mdb.InsertCell(memory.Cell{
+			CellID: id, TopicID: "t1", SourceType: "conversation", SourceID: "c1",
+			CellType: "fact", Salience: 0.5, Content: "cell " + id,
+			Embedding: memory.SerializeEmbedding([]float32{1, 2, 3}),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	e := memory.NewLocalEmbedder(64)
+	var calls, lastDone, lastTotal int
+	err := mdb.Reembed(context.Background(), e, 2, func(done, total int) {
+		calls++
+		lastDone, lastTotal = done, total
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 3 || lastDone != 4 || lastTotal != 4 {
+		t.Errorf("unexpected progress: calls=%d done=%d total=%d", calls, lastDone, lastTotal)
+	}
+
+	cells, err := mdb.GetCellsByTopic("t1", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range cells {
+		if got := len(memory.DeserializeEmbedding(c.Embedding)); got != 64 {
+			t.Errorf("cell %s: expected 64-dim embedding, got %d", c.CellID, got)
+		}
+	}
+	topic, err := mdb.GetTopic("t1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(memory.DeserializeEmbedding(topic.Embedding)); got != 64 {
+		t.Errorf("topic: expected 64-dim embedding, got %d", got)
+	}
+
+	stored, err := mdb.StoredEmbedder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored != "local:hash-64" {
+		t.Errorf("expected stored embedder local:hash-64, got %q", stored)
+	}
+}
+
+func TestCheckEmbedder(t *testing.T) {
+	mdb := openTestDB(t)
+
+	ok, err := mdb.CheckEmbedder(context.Background(), memory.NewLocalEmbedder(64))
+	if err != nil || !ok {
+		t.Fatalf("first check should record and pass: ok=%v err=%v", ok, err)
+	}
+	ok, err = mdb.CheckEmbedder(context.Background(), memory.NewLocalEmbedder(64))
+	if err != nil || !ok {
+		t.Fatalf("same embedder should pass: ok=%v err=%v", ok, err)
+	}
+	ok, err = mdb.CheckEmbedder(context.Background(), memory.NewLocalEmbedder(128))
+	if err != nil || ok {
+		t.Fatalf("different dimension should fail: ok=%v err=%v", ok, err)
+	}
+}
+
+func TestCheckEmbedderExistingVectors(t *testing.T) {
+	mdb := openTestDB(t)
+	// Vectors written before the embedder was tracked.
+	err := mdb.InsertCell(memory.Cell{
+		CellID: "a", SourceType: "conversation", SourceID: "c1",
+		CellType: "fact", Salience: 0.5, Content: "cell a",
+		Embedding: memory.SerializeEmbedding(make([]float32, 1536)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ok, err := mdb.CheckEmbedder(context.Background(), memory.NewLocalEmbedder(64))
+	if err != nil || ok {
+		t.Fatalf("dimension mismatch should fail: ok=%v err=%v", ok, err)
+	}
+	if stored, _ := mdb.StoredEmbedder(); stored != "" {
+		t.Fatalf("mismatched embedder must not be recorded, got %q", stored)
+	}
+	// The warning keeps firing until the vectors are migrated.
+	ok, err = mdb.CheckEmbedder(context.Background(), memory.NewLocalEmbedder(64))
+	if err != nil || ok {
+		t.Fatalf("second check should still fail: ok=%v err=%v", ok, err)
+	}
+
+	ok, err = mdb.CheckEmbedder(context.Background(), memory.NewLocalEmbedder(1536))
+	if err != nil || !ok {
+		t.Fatalf("matching dimension should pass: ok=%v err=%v", ok, err)
+	}
+	if stored, _ := mdb.StoredEmbedder(); stored != "local:hash-1536" {
+		t.Fatalf("expected embedder to be recorded, got %q", stored)
+	}
+}
+
+// unsizedEmbedder reports no dimension until it has embedded something, like
+// the Ollama embedder.
+type unsizedEmbedder struct{ fixedEmbedder }
+
+func (u *unsizedEmbedder) Dimension() int { return 0 }
+
+func TestCheckEmbedderUnknownDimension(t *testing.T) {
+	mdb := openTestDB(t)
+	err := mdb.InsertCell(memory.Cell{
+		CellID: "a", SourceType: "conversation", SourceID: "c1",
+		CellType: "fact", Salience: 0.5, Content: "cell a",
+		Embedding: memory.SerializeEmbedding(make([]float32, 8)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ok, err := mdb.CheckEmbedder(context.Background(), &unsizedEmbedder{fixedEmbedder{vec: make([]float32, 4)}})
+	if err != nil || ok {
+		t.Fatalf("probed dimension mismatch should fail: ok=%v err=%v", ok, err)
+	}
+
+	e := &unsizedEmbedder{fixedEmbedder{vec: make([]float32, 8)}}
+	ok, err = mdb.CheckEmbedder(context.Background(), e)
+	if err != nil || !ok {
+		t.Fatalf("probed dimension match should pass: ok=%v err=%v", ok, err)
+	}
+	if stored, _ := mdb.StoredEmbedder(); stored != memory.EmbedderName(e) {
+		t.Fatalf("expected embedder to be recorded, got %q", stored)
+	}
+}
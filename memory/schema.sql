@@ -9,7 +9,9 @@ CREATE TABLE IF NOT EXISTS cells (
     content     TEXT NOT NULL,
     embedding   BLOB,
     created_at  DATETIME DEFAULT CURRENT_TIMESTAMP,
-    superseded  BOOLEAN DEFAULT FALSE
+    superseded  BOOLEAN DEFAULT FALSE,
+    last_accessed_at DATETIME,
+    decayed_at  DATETIME
 );
 CREATE INDEX IF NOT EXISTS idx_cells_source ON cells(source_type, source_id);
 CREATE INDEX IF NOT EXISTS idx_cells_topic ON cells(topic_id);
@@ -64,3 +66,8 @@ CREATE TABLE IF NOT EXISTS index_state (
     hash        TEXT,
     PRIMARY KEY (source_type, source_id)
 );
+
+CREATE TABLE IF NOT EXISTS meta (
+    key   TEXT PRIMARY KEY,
+    value TEXT NOT NULL
+);
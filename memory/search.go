@@ -26,11 +26,13 @@ func (d *DB) TwoTierSearch(query string, queryVec []float32, sourceType string,
 	}
 
 	var results []MemoryResult
+	var touchedTopics, touchedCells []string
 
 	// Tier 1: Topic summaries via FTS.
 	topicResults, topicErr := d.SearchTopicsFTS(query, topicLimit)
 	if topicErr == nil {
 		for _, tr := range topicResults {
+			touchedTopics = append(touchedTopics, tr.TopicID)
 			results = append(results, MemoryResult{
 				ResultType: "topic_summary",
 				TopicID:    tr.TopicID,
@@ -46,6 +48,7 @@ func (d *DB) TwoTierSearch(query string, queryVec []float32, sourceType string,
 	cellResults, cellErr := d.SearchCellsFTS(query, sourceType, cellLimit)
 	if cellErr == nil {
 		for _, cr := range cellResults {
+			touchedCells = append(touchedCells, cr.CellID)
 			results = append(results, MemoryResult{
 				ResultType: "cell",
 				TopicID:    cr.TopicID,
@@ -61,6 +64,11 @@ func (d *DB) TwoTierSearch(query string, queryVec []float32, sourceType string,
 		}
 	}
 
+	// Best-effort: retrieval keeps cells from decaying, whether they were
+	// returned directly or summarized by a returned topic.
+	_ = d.TouchCells(touchedCells)
+	_ = d.TouchTopicCells(touchedTopics)
+
 	// If both tiers failed, return the cell error (or topic error).
 	if topicErr != nil && cellErr != nil {
 		return nil, cellErr
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"github.com/tgruben-circuit/percy/llm"
+)
+
+// BatchServiceForTask is like ServiceForTask, but when the routed model's
+// provider supports a batch API the returned service submits requests
+// through it. Batch requests are cheaper but may take hours, so this is
+// only suitable for background work. Concurrent calls to Do on services
+// for the same model are coalesced into one batch.
+func (m *Manager) BatchServiceForTask(task llm.Task, modelID string) (llm.Service, error) {
+	id, err := m.RouteFor(task, modelID)
+	if err != nil {
+		return nil, err
+	}
+	entry, ok := m.services[id]
+	if !ok {
+		return m.ServiceForTask(task, modelID)
+	}
+	batcher, ok := entry.service.(llm.Batcher)
+	if !ok {
+		return m.ServiceForTask(task, modelID)
+	}
+
+	m.batchersMu.Lock()
+	bs, ok := m.batchers[id]
+	if !ok || bs.Batcher != batcher {
+		// Created on first use, or the model was reconfigured
+		bs = &llm.BatchingService{Service: entry.service, Batcher: batcher}
+		if m.batchers == nil {
+			m.batchers = map[string]*llm.BatchingService{}
+		}
+		m.batchers[id] = bs
+	}
+	m.batchersMu.Unlock()
+
+	entry.service = bs
+	if m.logger != nil {
+		m.logger.Debug("Routed LLM task to batch API", "task", task, "model", id, "requested", modelID)
+	}
+	return &taskService{Service: m.wrapService(entry), task: task}, nil
+}
@@ -0,0 +1,128 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"syscall"
+
+	"github.com/tgruben-circuit/percy/llm"
+)
+
+// fallbackAttempts caps the attempts each non-final model in a fallback
+// chain makes, so an overloaded provider fails over in seconds rather than
+// exhausting its own retry schedule.
+const fallbackAttempts = 2
+
+// fallbackTarget is one model in a fallback chain.
+type fallbackTarget struct {
+	modelID  string
+	provider Provider
+	service  llm.Service
+}
+
+// fallbackService tries each model in its chain in order, moving on when a
+// model fails with an error that another provider might not share (overload,
+// rate limiting, server errors, network failures).
+type fallbackService struct {
+	chain  []fallbackTarget // chain[0] is the requested model
+	logger *slog.Logger
+}
+
+// Do sends the request to the first model that answers.
+func (f *fallbackService) Do(ctx context.Context, request *llm.Request) (*llm.Response, error) {
+	primary := f.chain[0]
+	var errs error
+	for i, target := range f.chain {
+		req := request
+		if target.provider != primary.provider {
+			req = withoutThinking(request)
+		}
+		attemptCtx := ctx
+		if i < len(f.chain)-1 {
+			attemptCtx = llm.WithMaxAttempts(ctx, fallbackAttempts)
+		}
+
+		resp, err := target.service.Do(attemptCtx, req)
+		if err == nil {
+			if i > 0 {
+				resp.Usage.FallbackFrom = primary.modelID
+				if f.logger != nil {
+					f.logger.Warn("LLM request answered by fallback model", "requested", primary.modelID, "answered", target.modelID)
+				}
+			}
+			return resp, nil
+		}
+
+		errs = errors.Join(errs, fmt.Errorf("%s: %w", target.modelID, err))
+		if !IsFailoverError(err) || ctx.Err() != nil {
+			return nil, errs
+		}
+		if f.logger != nil && i < len(f.chain)-1 {
+			f.logger.Warn("LLM request failed, trying fallback model", "model", target.modelID, "next", f.chain[i+1].modelID, "error", err)
+		}
+	}
+	return nil, errs
+}
+
+// TokenContextWindow reports the requested model's window. Fallbacks are
+// expected to have windows at least as large.
+func (f *fallbackService) TokenContextWindow() int {
+	return f.chain[0].service.TokenContextWindow()
+}
+
+// MaxImageDimension delegates to the requested model.
+func (f *fallbackService) MaxImageDimension() int {
+	return f.chain[0].service.MaxImageDimension()
+}
+
+// UseSimplifiedPatch delegates to the requested model, since the tool schema
+// is fixed before the request is sent.
+func (f *fallbackService) UseSimplifiedPatch() bool {
+	if sp, ok := f.chain[0].service.(llm.SimplifiedPatcher); ok {
+		return sp.UseSimplifiedPatch()
+	}
+	return false
+}
+
+// IsFailoverError reports whether err suggests a different model might
+// succeed where this one failed: rate limiting, overload and other server
+// errors, or a network failure. Client errors such as invalid requests and
+// cancellation do not fail over.
+func IsFailoverError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if code := llm.HTTPStatus(err); code != 0 {
+		return code == http.StatusTooManyRequests || code >= 500
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// withoutThinking returns a copy of request with thinking blocks removed.
+// Thinking signatures are only valid for the provider that produced them.
+func withoutThinking(request *llm.Request) *llm.Request {
+	req := *request
+	req.Messages = make([]llm.Message, len(request.Messages))
+	for i, msg := range request.Messages {
+		content := make([]llm.Content, 0, len(msg.Content))
+		for _, c := range msg.Content {
+			if c.Type == llm.ContentTypeThinking || c.Type == llm.ContentTypeRedactedThinking {
+				continue
+			}
+			content = append(content, c)
+		}
+		msg.Content = content
+		req.Messages[i] = msg
+	}
+	return &req
+}
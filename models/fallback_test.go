@@ -0,0 +1,128 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/tgruben-circuit/percy/llm"
+)
+
+// scriptedService fails with err, if set, and records how it was called.
+type scriptedService struct {
+	mockLLMService
+	err         error
+	calls       int
+	maxAttempts int
+	lastRequest *llm.Request
+}
+
+func (s *scriptedService) Do(ctx context.Context, request *llm.Request) (*llm.Response, error) {
+	s.calls++
+	s.maxAttempts = llm.MaxAttempts(ctx, 0)
+	s.lastRequest = request
+	if s.err != nil {
+		return nil, s.err
+	}
+	return s.mockLLMService.Do(ctx, request)
+}
+
+func TestFallbackServiceFailsOver(t *testing.T) {
+	primary := &scriptedService{err: fmt.Errorf("anthropic request failed after 2 attempts: %w", llm.StatusErrorf(529, "status 529 : overloaded_error"))}
+	backup := &scriptedService{}
+	svc := &fallbackService{chain: []fallbackTarget{
+		{modelID: "claude-opus-4.6", provider: ProviderAnthropic, service: primary},
+		{modelID: "gpt-5.3-codex", provider: ProviderOpenAI, service: backup},
+	}}
+
+	req := &llm.Request{Messages: []llm.Message{
+		llm.UserStringMessage("hi"),
+		{Role: llm.MessageRoleAssistant, Content: []llm.Content{
+			{Type: llm.ContentTypeThinking, Thinking: "hmm", Signature: "sig"},
+			{Type: llm.ContentTypeText, Text: "hello"},
+		}},
+		llm.UserStringMessage("again"),
+	}}
+	resp, err := svc.Do(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Do failed: %v", err)
+	}
+	if resp.Usage.FallbackFrom != "claude-opus-4.6" {
+		t.Errorf("FallbackFrom = %q, want claude-opus-4.6", resp.Usage.FallbackFrom)
+	}
+	if primary.maxAttempts != fallbackAttempts {
+		t.Errorf("primary attempts cap = %d, want %d", primary.maxAttempts, fallbackAttempts)
+	}
+	if backup.maxAttempts != 0 {
+		t.Errorf("last model should keep its own retry schedule, got cap %d", backup.maxAttempts)
+	}
+	if n := len(backup.lastRequest.Messages[1].Content); n != 1 {
+		t.Errorf("thinking not stripped for other provider: %d content blocks", n)
+	}
+	if n := len(req.Messages[1].Content); n != 2 {
+		t.Errorf("original request modified: %d content blocks", n)
+	}
+}
+
+func TestFallbackServiceStopsOnClientError(t *testing.T) {
+	primary := &scriptedService{err: llm.StatusErrorf(400, "status 400 Bad Request: invalid_request_error")}
+	backup := &scriptedService{}
+	svc := &fallbackService{chain: []fallbackTarget{
+		{modelID: "a", service: primary},
+		{modelID: "b", service: backup},
+	}}
+	if _, err := svc.Do(context.Background(), &llm.Request{}); err == nil {
+		t.Fatal("expected error")
+	}
+	if backup.calls != 0 {
+		t.Errorf("fallback called %d times on client error", backup.calls)
+	}
+}
+
+func TestManagerFallbacks(t *testing.T) {
+	manager, err := NewManager(&Config{Fallbacks: map[string][]string{
+		"predictable": {"missing-model", "predictable"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Unavailable and self-referencing fallbacks are dropped.
+	svc, err := manager.GetService("predictable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := svc.(*fallbackService); ok {
+		t.Error("expected plain service when no fallbacks are available")
+	}
+	if info := manager.GetModelInfo("predictable"); len(info.Fallbacks) != 0 {
+		t.Errorf("Fallbacks = %v, want none", info.Fallbacks)
+	}
+}
+
+func TestIsFailoverError(t *testing.T) {
+	for _, tt := range []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{context.Canceled, false},
+		{llm.StatusErrorf(529, `status 529 : {"type":"overloaded_error"}`), true},
+		{fmt.Errorf("openai request failed after 2 attempts: %w", llm.StatusErrorf(429, "status 429 (rate limited, url=x, model=y)")), true},
+		{errors.Join(llm.StatusErrorf(500, "status 500"), llm.StatusErrorf(503, "status 503 (url=x, model=y): unavailable")), true},
+		{&net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}, true},
+		{fmt.Errorf("read response: %w", io.ErrUnexpectedEOF), true},
+		{llm.StatusErrorf(400, "status 400 Bad Request"), false},
+		// The most recent attempt decides: a client error after a 503.
+		{errors.Join(llm.StatusErrorf(503, "status 503"), llm.StatusErrorf(400, "status 400")), false},
+		// Message text alone is not enough.
+		{errors.New("model overloaded, status 503"), false},
+	} {
+		if got := IsFailoverError(tt.err); got != tt.want {
+			t.Errorf("IsFailoverError(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,331 @@
+package models
+
+import (
+	"cmp"
+	"context"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/tgruben-circuit/percy/llm/llmhttp"
+)
+
+// RateLimit bounds the traffic sent to one provider or model.
+// Zero fields are unlimited.
+type RateLimit struct {
+	RequestsPerMinute int `json:"requests_per_minute,omitempty"`
+	TokensPerMinute   int `json:"tokens_per_minute,omitempty"` // estimated from request size
+	MaxInFlight       int `json:"max_in_flight,omitempty"`
+}
+
+// RateLimitStats is a snapshot of one rate limit's queue.
+type RateLimitStats struct {
+	Key                string     `json:"key"`
+	Limit              RateLimit  `json:"limit"`
+	InFlight           int        `json:"in_flight"`
+	QueuedInteractive  int        `json:"queued_interactive"`
+	QueuedBackground   int        `json:"queued_background"`
+	RequestsLastMinute int        `json:"requests_last_minute"`
+	TokensLastMinute   int        `json:"tokens_last_minute"`
+	BlockedUntil       *time.Time `json:"blocked_until,omitempty"`
+}
+
+const (
+	rateWindow = time.Minute
+	// maxRetryAfter caps how long a single retry-after header can stall a queue.
+	maxRetryAfter = 5 * time.Minute
+	// bytesPerToken is a rough estimate used to charge requests against
+	// TokensPerMinute before the provider reports actual usage.
+	bytesPerToken = 4
+)
+
+// rateLimiter is an http.RoundTripper that queues LLM requests so each
+// configured provider or model stays under its limits. Requests are keyed by
+// model ID if that model has a limit, otherwise by provider; requests with
+// neither pass straight through.
+type rateLimiter struct {
+	base   http.RoundTripper
+	limits map[string]RateLimit
+	now    func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*rateBucket
+}
+
+type rateBucket struct {
+	limit        RateLimit
+	inFlight     int
+	sent         []sentRequest // within the last rateWindow, oldest first
+	blockedUntil time.Time
+	queues       [2][]*rateWaiter // indexed by llmhttp.Priority
+	timer        *time.Timer
+}
+
+type sentRequest struct {
+	at     time.Time
+	tokens int
+}
+
+type rateWaiter struct {
+	tokens   int
+	admitted bool
+	ready    chan struct{}
+}
+
+func newRateLimiter(base http.RoundTripper, limits map[string]RateLimit) *rateLimiter {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &rateLimiter{
+		base:    base,
+		limits:  limits,
+		now:     time.Now,
+		buckets: make(map[string]*rateBucket),
+	}
+}
+
+// bucket returns the bucket for the request's model or provider, or nil if
+// neither is limited. Callers must hold l.mu.
+func (l *rateLimiter) bucket(req *http.Request) *rateBucket {
+	ctx := req.Context()
+	for _, key := range []string{llmhttp.ModelIDFromContext(ctx), llmhttp.ProviderFromContext(ctx)} {
+		if key == "" {
+			continue
+		}
+		if b := l.buckets[key]; b != nil {
+			return b
+		}
+		if limit, ok := l.limits[key]; ok {
+			b := &rateBucket{limit: limit}
+			l.buckets[key] = b
+			return b
+		}
+	}
+	return nil
+}
+
+// RoundTrip implements http.RoundTripper.
+func (l *rateLimiter) RoundTrip(req *http.Request) (*http.Response, error) {
+	l.mu.Lock()
+	b := l.bucket(req)
+	if b == nil {
+		l.mu.Unlock()
+		return l.base.RoundTrip(req)
+	}
+	prio := llmhttp.PriorityInteractive
+	if llmhttp.PriorityFromContext(req.Context()) == llmhttp.PriorityBackground {
+		prio = llmhttp.PriorityBackground
+	}
+	w := &rateWaiter{tokens: int(max(req.ContentLength, 0) / bytesPerToken), ready: make(chan struct{})}
+	b.queues[prio] = append(b.queues[prio], w)
+	l.dispatch(b)
+	l.mu.Unlock()
+
+	select {
+	case <-w.ready:
+	case <-req.Context().Done():
+		l.mu.Lock()
+		if w.admitted {
+			l.releaseLocked(b)
+		} else {
+			b.queues[prio] = slices.DeleteFunc(b.queues[prio], func(q *rateWaiter) bool { return q == w })
+			l.dispatch(b)
+		}
+		l.mu.Unlock()
+		return nil, req.Context().Err()
+	}
+
+	resp, err := l.base.RoundTrip(req)
+	if err != nil {
+		l.release(b)
+		return nil, err
+	}
+	l.mu.Lock()
+	now := l.now()
+	if d, ok := retryAfter(resp, now); ok && now.Add(d).After(b.blockedUntil) {
+		b.blockedUntil = now.Add(d)
+	}
+	l.mu.Unlock()
+	resp.Body = newReleasingBody(req.Context(), resp.Body, func() { l.release(b) })
+	return resp, nil
+}
+
+func (l *rateLimiter) release(b *rateBucket) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.releaseLocked(b)
+}
+
+func (l *rateLimiter) releaseLocked(b *rateBucket) {
+	b.inFlight--
+	l.dispatch(b)
+}
+
+// dispatch admits queued requests, interactive first, for as long as the
+// bucket's limits allow. If the head of the queue must wait for the rate
+// window to slide or a retry-after to expire, a timer re-runs dispatch.
+// Callers must hold l.mu.
+func (l *rateLimiter) dispatch(b *rateBucket) {
+	for {
+		prio := llmhttp.PriorityInteractive
+		if len(b.queues[prio]) == 0 {
+			prio = llmhttp.PriorityBackground
+		}
+		if len(b.queues[prio]) == 0 {
+			return
+		}
+		w := b.queues[prio][0]
+		now := l.now()
+		wait, ok := b.admit(now, w.tokens)
+		if !ok {
+			if wait > 0 {
+				if b.timer != nil {
+					b.timer.Stop()
+				}
+				b.timer = time.AfterFunc(wait, func() {
+					l.mu.Lock()
+					defer l.mu.Unlock()
+					l.dispatch(b)
+				})
+			}
+			return
+		}
+		b.queues[prio] = b.queues[prio][1:]
+		b.inFlight++
+		b.sent = append(b.sent, sentRequest{at: now, tokens: w.tokens})
+		w.admitted = true
+		close(w.ready)
+	}
+}
+
+// admit reports whether a request of the given size may be sent now. If not,
+// wait is how long until it might be, or zero if it must wait for an
+// in-flight request to finish.
+func (b *rateBucket) admit(now time.Time, tokens int) (wait time.Duration, ok bool) {
+	i := 0
+	for i < len(b.sent) && now.Sub(b.sent[i].at) >= rateWindow {
+		i++
+	}
+	b.sent = b.sent[i:]
+
+	if now.Before(b.blockedUntil) {
+		return b.blockedUntil.Sub(now), false
+	}
+	if b.limit.MaxInFlight > 0 && b.inFlight >= b.limit.MaxInFlight {
+		return 0, false
+	}
+	if len(b.sent) == 0 {
+		// Always let one request through, even one larger than TokensPerMinute.
+		return 0, true
+	}
+	expiry := b.sent[0].at.Add(rateWindow).Sub(now)
+	if b.limit.RequestsPerMinute > 0 && len(b.sent) >= b.limit.RequestsPerMinute {
+		return expiry, false
+	}
+	if b.limit.TokensPerMinute > 0 && b.tokens()+tokens > b.limit.TokensPerMinute {
+		return expiry, false
+	}
+	return 0, true
+}
+
+func (b *rateBucket) tokens() int {
+	n := 0
+	for _, s := range b.sent {
+		n += s.tokens
+	}
+	return n
+}
+
+// retryAfter returns the delay requested by a throttled response's
+// Retry-After header, which may be in seconds or an HTTP date.
+func retryAfter(resp *http.Response, now time.Time) (time.Duration, bool) {
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable, 529:
+	default:
+		return 0, false
+	}
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	var d time.Duration
+	if secs, err := strconv.ParseFloat(v, 64); err == nil {
+		d = time.Duration(secs * float64(time.Second))
+	} else if t, err := http.ParseTime(v); err == nil {
+		d = t.Sub(now)
+	} else {
+		return 0, false
+	}
+	if d <= 0 {
+		return 0, false
+	}
+	return min(d, maxRetryAfter), true
+}
+
+// Stats returns a snapshot of every configured limit, sorted by key.
+func (l *rateLimiter) Stats() []RateLimitStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	var stats []RateLimitStats
+	for key, limit := range l.limits {
+		st := RateLimitStats{Key: key, Limit: limit}
+		if b := l.buckets[key]; b != nil {
+			st.InFlight = b.inFlight
+			st.QueuedInteractive = len(b.queues[llmhttp.PriorityInteractive])
+			st.QueuedBackground = len(b.queues[llmhttp.PriorityBackground])
+			for _, s := range b.sent {
+				if now.Sub(s.at) < rateWindow {
+					st.RequestsLastMinute++
+					st.TokensLastMinute += s.tokens
+				}
+			}
+			if now.Before(b.blockedUntil) {
+				until := b.blockedUntil
+				st.BlockedUntil = &until
+			}
+		}
+		stats = append(stats, st)
+	}
+	slices.SortFunc(stats, func(a, b RateLimitStats) int { return cmp.Compare(a.Key, b.Key) })
+	return stats
+}
+
+// releasingBody frees the request's in-flight slot once the response body is
+// closed, fully read, fails, or its request's context ends, so a caller that
+// never closes the body cannot hold the slot forever.
+type releasingBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+	stop    func() bool // stops the context watch
+}
+
+func newReleasingBody(ctx context.Context, body io.ReadCloser, release func()) *releasingBody {
+	r := &releasingBody{ReadCloser: body, release: release}
+	r.stop = context.AfterFunc(ctx, r.done)
+	return r
+}
+
+func (r *releasingBody) done() {
+	r.once.Do(r.release)
+}
+
+func (r *releasingBody) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if err != nil {
+		r.stop()
+		r.done()
+	}
+	return n, err
+}
+
+func (r *releasingBody) Close() error {
+	err := r.ReadCloser.Close()
+	r.stop()
+	r.done()
+	return err
+}
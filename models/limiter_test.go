@@ -0,0 +1,262 @@
+package models
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tgruben-circuit/percy/llm"
+	"github.com/tgruben-circuit/percy/llm/llmhttp"
+)
+
+// gatedTransport holds each request until it is released.
+type gatedTransport struct {
+	mu      sync.Mutex
+	started []string
+	gate    chan struct{}
+	status  int
+	header  http.Header
+}
+
+func (g *gatedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, _ := io.ReadAll(req.Body)
+	g.mu.Lock()
+	g.started = append(g.started, string(body))
+	g.mu.Unlock()
+	if g.gate != nil {
+		<-g.gate
+	}
+	return &http.Response{
+		StatusCode: max(g.status, http.StatusOK),
+		Header:     g.header,
+		Body:       io.NopCloser(strings.NewReader("{}")),
+		Request:    req,
+	}, nil
+}
+
+func (g *gatedTransport) startedCount() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return len(g.started)
+}
+
+func limitedRequest(t *testing.T, ctx context.Context, client *http.Client, body string) error {
+	t.Helper()
+	ctx = llmhttp.WithProvider(ctx, "anthropic")
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://api.example.com/v1/messages", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for condition")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestRateLimiterPrioritizesInteractive(t *testing.T) {
+	base := &gatedTransport{gate: make(chan struct{})}
+	limiter := newRateLimiter(base, map[string]RateLimit{"anthropic": {MaxInFlight: 1}})
+	client := &http.Client{Transport: limiter}
+	ctx := context.Background()
+	bg := llmhttp.WithPriority(ctx, llmhttp.PriorityBackground)
+
+	var wg sync.WaitGroup
+	send := func(ctx context.Context, body string) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := limitedRequest(t, ctx, client, body); err != nil {
+				t.Errorf("request %s: %v", body, err)
+			}
+		}()
+	}
+
+	send(ctx, "first")
+	waitFor(t, func() bool { return base.startedCount() == 1 })
+	send(bg, "background")
+	waitFor(t, func() bool { return limiter.Stats()[0].QueuedBackground == 1 })
+	send(ctx, "interactive")
+	waitFor(t, func() bool { return limiter.Stats()[0].QueuedInteractive == 1 })
+
+	st := limiter.Stats()[0]
+	if st.Key != "anthropic" || st.InFlight != 1 || st.RequestsLastMinute != 1 {
+		t.Errorf("unexpected stats: %+v", st)
+	}
+
+	close(base.gate)
+	wg.Wait()
+	want := []string{"first", "interactive", "background"}
+	if strings.Join(base.started, ",") != strings.Join(want, ",") {
+		t.Errorf("requests sent in order %v, want %v", base.started, want)
+	}
+	if st := limiter.Stats()[0]; st.InFlight != 0 || st.QueuedBackground != 0 || st.QueuedInteractive != 0 {
+		t.Errorf("queue not drained: %+v", st)
+	}
+}
+
+func TestRateLimiterRequestsPerMinute(t *testing.T) {
+	base := &gatedTransport{}
+	limiter := newRateLimiter(base, map[string]RateLimit{"anthropic": {RequestsPerMinute: 2}})
+	client := &http.Client{Transport: limiter}
+
+	for range 2 {
+		if err := limitedRequest(t, context.Background(), client, "x"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := limitedRequest(t, ctx, client, "x"); err == nil {
+		t.Error("third request within a minute was not queued")
+	}
+	if st := limiter.Stats()[0]; st.RequestsLastMinute != 2 || st.QueuedInteractive != 0 {
+		t.Errorf("unexpected stats after cancellation: %+v", st)
+	}
+
+	// Once the window slides, the queue drains.
+	limiter.mu.Lock()
+	limiter.now = func() time.Time { return time.Now().Add(rateWindow) }
+	limiter.mu.Unlock()
+	if err := limitedRequest(t, context.Background(), client, "x"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRateLimiterRetryAfter(t *testing.T) {
+	base := &gatedTransport{status: http.StatusTooManyRequests, header: http.Header{"Retry-After": []string{"30"}}}
+	limiter := newRateLimiter(base, map[string]RateLimit{"anthropic": {MaxInFlight: 4}})
+	client := &http.Client{Transport: limiter}
+
+	if err := limitedRequest(t, context.Background(), client, "x"); err != nil {
+		t.Fatal(err)
+	}
+	st := limiter.Stats()[0]
+	if st.BlockedUntil == nil || time.Until(*st.BlockedUntil) < 25*time.Second {
+		t.Fatalf("retry-after not honored: %+v", st)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := limitedRequest(t, ctx, client, "x"); err == nil {
+		t.Error("request sent while blocked by retry-after")
+	}
+	if n := base.startedCount(); n != 1 {
+		t.Errorf("sent %d requests, want 1", n)
+	}
+}
+
+func TestRateLimiterUnlimitedPassesThrough(t *testing.T) {
+	base := &gatedTransport{}
+	limiter := newRateLimiter(base, map[string]RateLimit{"openai": {MaxInFlight: 1}})
+	client := &http.Client{Transport: limiter}
+	if err := limitedRequest(t, context.Background(), client, "x"); err != nil {
+		t.Fatal(err)
+	}
+	if st := limiter.Stats()[0]; st.Key != "openai" || st.RequestsLastMinute != 0 {
+		t.Errorf("unlimited provider was counted: %+v", st)
+	}
+}
+
+func TestRateLimiterReleasesUnclosedBody(t *testing.T) {
+	base := &gatedTransport{}
+	limiter := newRateLimiter(base, map[string]RateLimit{"anthropic": {MaxInFlight: 1}})
+	client := &http.Client{Transport: limiter}
+	send := func(ctx context.Context) *http.Response {
+		t.Helper()
+		req, err := http.NewRequestWithContext(llmhttp.WithProvider(ctx, "anthropic"), http.MethodPost, "https://api.example.com/v1/messages", strings.NewReader("x"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	inFlight := func() int { return limiter.Stats()[0].InFlight }
+
+	// Reading to EOF frees the slot without Close.
+	resp := send(context.Background())
+	if _, err := io.ReadAll(resp.Body); err != nil {
+		t.Fatal(err)
+	}
+	if n := inFlight(); n != 0 {
+		t.Fatalf("in flight after EOF = %d, want 0", n)
+	}
+
+	// So does cancelling the request's context.
+	ctx, cancel := context.WithCancel(context.Background())
+	send(ctx)
+	if n := inFlight(); n != 1 {
+		t.Fatalf("in flight before cancel = %d, want 1", n)
+	}
+	cancel()
+	waitFor(t, func() bool { return inFlight() == 0 })
+}
+
+func TestManagerRateLimitsWithoutLogger(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	cassette := &llmhttp.Cassette{Interactions: []llmhttp.Interaction{{
+		URL:          "https://api.anthropic.com/v1/messages",
+		RequestBody:  `{}`,
+		StatusCode:   200,
+		ResponseBody: `{"id":"msg_1","type":"message","role":"assistant","model":"claude-test","content":[{"type":"text","text":"ok"}],"stop_reason":"end_turn","usage":{"input_tokens":3,"output_tokens":1}}`,
+	}}}
+	if err := cassette.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	manager, err := NewManager(&Config{ReplayPath: path, RateLimits: map[string]RateLimit{"anthropic": {RequestsPerMinute: 10}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc, err := manager.GetService("claude-opus-4.6")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Do(context.Background(), &llm.Request{Messages: []llm.Message{llm.UserStringMessage("hi")}}); err != nil {
+		t.Fatal(err)
+	}
+	if st := manager.RateLimitStats(); len(st) != 1 || st[0].RequestsLastMinute != 1 {
+		t.Errorf("request bypassed the rate limit: %+v", st)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		status int
+		value  string
+		want   time.Duration
+	}{
+		{http.StatusTooManyRequests, "2", 2 * time.Second},
+		{529, "0.5", 500 * time.Millisecond},
+		{http.StatusServiceUnavailable, now.Add(time.Minute).Format(http.TimeFormat), time.Minute},
+		{http.StatusTooManyRequests, "3600", maxRetryAfter},
+		{http.StatusOK, "2", 0},
+		{http.StatusTooManyRequests, "soon", 0},
+	}
+	for _, tt := range tests {
+		resp := &http.Response{StatusCode: tt.status, Header: http.Header{"Retry-After": []string{tt.value}}}
+		if got, _ := retryAfter(resp, now); got != tt.want {
+			t.Errorf("retryAfter(%d, %q) = %v, want %v", tt.status, tt.value, got, tt.want)
+		}
+	}
+}
@@ -1,10 +1,12 @@
 package models
 
 import (
+	"cmp"
 	"context"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/tgruben-circuit/percy/db"
@@ -14,6 +16,7 @@ import (
 	"github.com/tgruben-circuit/percy/llm/gem"
 	"github.com/tgruben-circuit/percy/llm/llmhttp"
 	"github.com/tgruben-circuit/percy/llm/oai"
+	"github.com/tgruben-circuit/percy/llm/ollama"
 	"github.com/tgruben-circuit/percy/loop"
 )
 
@@ -25,6 +28,7 @@ const (
 	ProviderAnthropic Provider = "anthropic"
 	ProviderFireworks Provider = "fireworks"
 	ProviderGemini    Provider = "gemini"
+	ProviderOllama    Provider = "ollama"
 	ProviderBuiltIn   Provider = "builtin"
 )
 
@@ -119,6 +123,27 @@ type Config struct {
 
 	// Database for recording LLM requests (optional)
 	DB *db.DB
+
+	// Fallbacks maps a model ID to the model IDs to try, in order, when it
+	// fails with an overload, rate limit, or server error (optional)
+	Fallbacks map[string][]string
+
+	// ReplayPath is a cassette file to serve LLM responses from instead of
+	// the network (optional). Missing API keys are filled with placeholders
+	// so every model is available.
+	ReplayPath string
+
+	// RecordUnredacted disables masking of secrets in recorded LLM requests
+	RecordUnredacted bool
+
+	// RateLimits maps a model ID or provider name to the limits its requests
+	// are queued behind (optional). A model's own limit takes precedence
+	// over its provider's.
+	RateLimits map[string]RateLimit
+
+	// Routes maps a task to the model IDs to try for it, in order (optional).
+	// Route overrides in the settings table take precedence.
+	Routes map[llm.Task][]string
 }
 
 // getAnthropicURL returns the Anthropic API URL, with gateway suffix if gateway is set
@@ -234,7 +259,7 @@ func All() []Model {
 				if config.FireworksAPIKey == "" {
 					return nil, fmt.Errorf("glm-4.7-fireworks requires FIREWORKS_API_KEY")
 				}
-				svc := &oai.Service{Model: oai.GLM47Fireworks, APIKey: config.FireworksAPIKey, HTTPC: httpc}
+				svc := &oai.Service{Model: oai.GLM47Fireworks, APIKey: config.FireworksAPIKey, HTTPC: httpc, ThinkingLevel: llm.ThinkingLevelMedium}
 				if url := config.getFireworksURL(); url != "" {
 					svc.ModelURL = url
 				}
@@ -302,7 +327,7 @@ func All() []Model {
 				if config.FireworksAPIKey == "" {
 					return nil, fmt.Errorf("glm-4p6-fireworks requires FIREWORKS_API_KEY")
 				}
-				svc := &oai.Service{Model: oai.GLM4P6Fireworks, APIKey: config.FireworksAPIKey, HTTPC: httpc}
+				svc := &oai.Service{Model: oai.GLM4P6Fireworks, APIKey: config.FireworksAPIKey, HTTPC: httpc, ThinkingLevel: llm.ThinkingLevelMedium}
 				if url := config.getFireworksURL(); url != "" {
 					svc.ModelURL = url
 				}
@@ -388,6 +413,16 @@ type Manager struct {
 	db         *db.DB       // for custom models and LLM request recording
 	httpc      *http.Client // HTTP client with recording middleware
 	cfg        *Config      // retained for refreshing custom models
+	fallbacks  map[string][]string
+	limiter    *rateLimiter // nil when no rate limits are configured
+	usage      *usageTracker
+
+	routesMu       sync.RWMutex
+	routes         map[llm.Task][]string // from Config
+	routeOverrides map[llm.Task][]string // from settings
+
+	batchersMu sync.Mutex
+	batchers   map[string]*llm.BatchingService // by model ID
 }
 
 type serviceEntry struct {
@@ -405,13 +440,15 @@ type ConfigInfo interface {
 	ConfigDetails() map[string]string
 }
 
-// loggingService wraps an llm.Service to log request completion with usage information
+// loggingService wraps an llm.Service to tag its requests with the model and
+// provider and to log request completion with usage information
 type loggingService struct {
 	service  llm.Service
 	logger   *slog.Logger
 	modelID  string
 	provider Provider
 	db       *db.DB
+	usage    *usageTracker
 }
 
 // Do wraps the underlying service's Do method with logging and database recording
@@ -467,6 +504,7 @@ func (l *loggingService) Do(ctx context.Context, request *llm.Request) (*llm.Res
 		}
 
 		l.logger.Info("LLM request completed", logAttrs...)
+		l.usage.add(taskFromContext(ctx), l.modelID, response.Usage)
 	}
 
 	return response, err
@@ -492,10 +530,39 @@ func (l *loggingService) UseSimplifiedPatch() bool {
 
 // NewManager creates a new Manager with all models configured
 func NewManager(cfg *Config) (*Manager, error) {
+	// In replay mode, requests never leave the process
+	baseClient := http.DefaultClient
+	if cfg.ReplayPath != "" {
+		cassette, err := llmhttp.LoadCassette(cfg.ReplayPath)
+		if err != nil {
+			return nil, err
+		}
+		baseClient = &http.Client{Transport: llmhttp.NewReplayTransport(cassette)}
+		replayCfg := *cfg
+		replayCfg.AnthropicAPIKey = cmp.Or(cfg.AnthropicAPIKey, "replay")
+		replayCfg.OpenAIAPIKey = cmp.Or(cfg.OpenAIAPIKey, "replay")
+		replayCfg.GeminiAPIKey = cmp.Or(cfg.GeminiAPIKey, "replay")
+		replayCfg.FireworksAPIKey = cmp.Or(cfg.FireworksAPIKey, "replay")
+		cfg = &replayCfg
+		if cfg.Logger != nil {
+			cfg.Logger.Info("Replaying LLM responses from cassette", "path", cfg.ReplayPath, "interactions", len(cassette.Interactions))
+		}
+	}
+
 	manager := &Manager{
-		services: make(map[string]serviceEntry),
-		logger:   cfg.Logger,
-		db:       cfg.DB,
+		services:  make(map[string]serviceEntry),
+		logger:    cfg.Logger,
+		db:        cfg.DB,
+		fallbacks: cfg.Fallbacks,
+		usage:     &usageTracker{},
+		routes:    cfg.Routes,
+	}
+
+	// Queue requests behind the configured rate limits, beneath recording so
+	// only requests actually sent are recorded
+	if len(cfg.RateLimits) > 0 {
+		manager.limiter = newRateLimiter(baseClient.Transport, cfg.RateLimits)
+		baseClient = &http.Client{Transport: manager.limiter, Timeout: baseClient.Timeout}
 	}
 
 	// Create HTTP client with recording if database is available
@@ -506,6 +573,12 @@ func NewManager(cfg *Config) (*Manager, error) {
 			provider := llmhttp.ProviderFromContext(ctx)
 			conversationID := llmhttp.ConversationIDFromContext(ctx)
 
+			// Mask secrets from tool output before they reach disk
+			if !cfg.RecordUnredacted {
+				requestBody = llmhttp.RedactSecrets(requestBody)
+				responseBody = llmhttp.RedactSecrets(responseBody)
+			}
+
 			var convIDPtr *string
 			if conversationID != "" {
 				convIDPtr = &conversationID
@@ -554,10 +627,10 @@ func NewManager(cfg *Config) (*Manager, error) {
 				}
 			}()
 		}
-		httpc = llmhttp.NewClient(nil, recorder)
+		httpc = llmhttp.NewClient(baseClient, recorder)
 	} else {
 		// Still use the custom transport for headers, just without recording
-		httpc = llmhttp.NewClient(nil, nil)
+		httpc = llmhttp.NewClient(baseClient, nil)
 	}
 
 	// Store the HTTP client and config for use with custom models
@@ -592,6 +665,9 @@ func NewManager(cfg *Config) (*Manager, error) {
 	if err := manager.loadCustomModels(); err != nil && cfg.Logger != nil {
 		cfg.Logger.Warn("Failed to load custom models", "error", err)
 	}
+	if err := manager.RefreshRoutes(); err != nil && cfg.Logger != nil {
+		cfg.Logger.Warn("Failed to load model routes", "error", err)
+	}
 
 	return manager, nil
 }
@@ -656,24 +732,50 @@ func (m *Manager) RefreshCustomModels() error {
 	return m.loadCustomModels()
 }
 
-// GetService returns the LLM service for the given model ID, wrapped with logging
+// GetService returns the LLM service for the given model ID, wrapped with logging.
+// If the model has a fallback chain, the service fails over along it.
 func (m *Manager) GetService(modelID string) (llm.Service, error) {
 	entry, ok := m.services[modelID]
 	if !ok {
 		return nil, fmt.Errorf("unsupported model: %s", modelID)
 	}
 
-	// Wrap with logging if we have a logger
-	if m.logger != nil {
-		return &loggingService{
-			service:  entry.service,
-			logger:   m.logger,
-			modelID:  entry.modelID,
-			provider: entry.provider,
-			db:       m.db,
-		}, nil
+	fallbacks := m.fallbacks[modelID]
+	if len(fallbacks) == 0 {
+		return m.wrapService(entry), nil
+	}
+
+	chain := []fallbackTarget{{modelID: entry.modelID, provider: entry.provider, service: m.wrapService(entry)}}
+	for _, id := range fallbacks {
+		fb, ok := m.services[id]
+		if !ok || id == modelID {
+			// Fallbacks whose API keys are missing are simply skipped
+			continue
+		}
+		chain = append(chain, fallbackTarget{modelID: fb.modelID, provider: fb.provider, service: m.wrapService(fb)})
+	}
+	if len(chain) == 1 {
+		return chain[0].service, nil
+	}
+	return &fallbackService{chain: chain, logger: m.logger}, nil
+}
+
+// wrapService wraps entry's service so its requests carry the model ID and
+// provider, which the HTTP transport needs for recording and rate limiting,
+// and are logged if we have a logger.
+func (m *Manager) wrapService(entry serviceEntry) llm.Service {
+	logger := m.logger
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+	return &loggingService{
+		service:  entry.service,
+		logger:   logger,
+		modelID:  entry.modelID,
+		provider: entry.provider,
+		db:       m.db,
+		usage:    m.usage,
 	}
-	return entry.service, nil
 }
 
 // GetAvailableModels returns a list of available model IDs.
@@ -691,11 +793,21 @@ func (m *Manager) HasModel(modelID string) bool {
 	return ok
 }
 
+// RateLimitStats returns the queue state of each configured rate limit,
+// or nil if none are configured
+func (m *Manager) RateLimitStats() []RateLimitStats {
+	if m.limiter == nil {
+		return nil
+	}
+	return m.limiter.Stats()
+}
+
 // ModelInfo contains display name, tags, and source for a model
 type ModelInfo struct {
 	DisplayName string
 	Tags        string
-	Source      string // Human-readable source (e.g., "exe.dev gateway", "$ANTHROPIC_API_KEY", "custom")
+	Source      string   // Human-readable source (e.g., "exe.dev gateway", "$ANTHROPIC_API_KEY", "custom")
+	Fallbacks   []string // Available fallback model IDs, in the order they are tried
 }
 
 // GetModelInfo returns the display name, tags, and source for a model
@@ -704,11 +816,39 @@ func (m *Manager) GetModelInfo(modelID string) *ModelInfo {
 	if !ok {
 		return nil
 	}
+	var fallbacks []string
+	for _, id := range m.fallbacks[modelID] {
+		if _, ok := m.services[id]; ok && id != modelID {
+			fallbacks = append(fallbacks, id)
+		}
+	}
 	return &ModelInfo{
 		DisplayName: entry.displayName,
 		Tags:        entry.tags,
 		Source:      entry.source,
+		Fallbacks:   fallbacks,
+	}
+}
+
+// CustomChatService creates a Chat Completions service for a custom model.
+// Models tagged "reasoning" are sent reasoning_effort; other endpoints may
+// reject it, so it is opt-in.
+func CustomChatService(apiKey, endpoint, modelName, tags string, maxTokens int, httpc *http.Client) *oai.Service {
+	svc := &oai.Service{
+		APIKey:   apiKey,
+		ModelURL: endpoint,
+		Model: oai.Model{
+			ModelName:        modelName,
+			URL:              endpoint,
+			IsReasoningModel: hasTag(tags, "reasoning"),
+		},
+		MaxTokens: maxTokens,
+		HTTPC:     httpc,
+	}
+	if svc.Model.IsReasoningModel {
+		svc.ThinkingLevel = llm.ThinkingLevelMedium
 	}
+	return svc
 }
 
 // createServiceFromModel creates an LLM service from a database model configuration
@@ -723,16 +863,7 @@ func (m *Manager) createServiceFromModel(model *generated.Model) llm.Service {
 			ThinkingLevel: llm.ThinkingLevelMedium,
 		}
 	case "openai":
-		return &oai.Service{
-			APIKey:   model.ApiKey,
-			ModelURL: model.Endpoint,
-			Model: oai.Model{
-				ModelName: model.ModelName,
-				URL:       model.Endpoint,
-			},
-			MaxTokens: int(model.MaxTokens),
-			HTTPC:     m.httpc,
-		}
+		return CustomChatService(model.ApiKey, model.Endpoint, model.ModelName, model.Tags, int(model.MaxTokens), m.httpc)
 	case "openai-responses":
 		return &oai.ResponsesService{
 			APIKey:   model.ApiKey,
@@ -752,6 +883,15 @@ func (m *Manager) createServiceFromModel(model *generated.Model) llm.Service {
 			Model:  model.ModelName,
 			HTTPC:  m.httpc,
 		}
+	case "ollama":
+		// MaxTokens defaults to 200000 for every custom model, so it is
+		// not a usable context cap; DefaultMaxContextWindow applies instead.
+		return &ollama.Service{
+			URL:           model.Endpoint,
+			Model:         model.ModelName,
+			HTTPC:         m.httpc,
+			ThinkingLevel: llm.ThinkingLevelMedium,
+		}
 	default:
 		if m.logger != nil {
 			m.logger.Error("Unknown provider type for model", "model_id", model.ModelID, "provider_type", model.ProviderType)
@@ -2,11 +2,21 @@ package models
 
 import (
 	"context"
+	"encoding/json"
+	"io"
 	"log/slog"
 	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
 	"testing"
 
+	"github.com/tgruben-circuit/percy/db"
+	"github.com/tgruben-circuit/percy/db/generated"
 	"github.com/tgruben-circuit/percy/llm"
+	"github.com/tgruben-circuit/percy/llm/llmhttp"
+	"github.com/tgruben-circuit/percy/llm/oai"
+	"github.com/tgruben-circuit/percy/llm/ollama"
 )
 
 func TestAll(t *testing.T) {
@@ -512,3 +522,167 @@ func TestGetAvailableModelsUnion(t *testing.T) {
 		}
 	}
 }
+
+func TestManagerReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	cassette := &llmhttp.Cassette{Interactions: []llmhttp.Interaction{{
+		URL:          "https://api.anthropic.com/v1/messages",
+		RequestBody:  `{}`,
+		StatusCode:   200,
+		ResponseBody: `{"id":"msg_1","type":"message","role":"assistant","model":"claude-test","content":[{"type":"text","text":"replayed"}],"stop_reason":"end_turn","usage":{"input_tokens":3,"output_tokens":1}}`,
+	}}}
+	if err := cassette.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// No API keys: replay mode makes every model available.
+	manager, err := NewManager(&Config{ReplayPath: path})
+	if err != nil {
+		t.Fatalf("NewManager failed: %v", err)
+	}
+	svc, err := manager.GetService(Default().ID)
+	if err != nil {
+		t.Fatalf("GetService failed: %v", err)
+	}
+	resp, err := svc.Do(context.Background(), &llm.Request{Messages: []llm.Message{llm.UserStringMessage("hi")}})
+	if err != nil {
+		t.Fatalf("Do failed: %v", err)
+	}
+	if len(resp.Content) != 1 || resp.Content[0].Text != "replayed" {
+		t.Errorf("unexpected response content: %+v", resp.Content)
+	}
+
+	if _, err := NewManager(&Config{ReplayPath: filepath.Join(t.TempDir(), "missing.json")}); err == nil {
+		t.Error("expected error for missing cassette")
+	}
+}
+
+func TestManagerChatServicesSendReasoningEffort(t *testing.T) {
+	var mu sync.Mutex
+	efforts := map[string]string{} // model name -> reasoning_effort
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Model           string `json:"model"`
+			ReasoningEffort string `json:"reasoning_effort"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		mu.Lock()
+		efforts[body.Model] = body.ReasoningEffort
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"id":"chatcmpl-test","choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	database, err := db.New(db.Config{DSN: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	if err := database.Migrate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range []generated.CreateModelParams{
+		{ModelID: "custom-reasoning", ProviderType: "openai", Endpoint: srv.URL, ApiKey: "k", ModelName: "custom-r1", MaxTokens: 1000, Tags: "slug, reasoning"},
+		{ModelID: "custom-plain", ProviderType: "openai", Endpoint: srv.URL, ApiKey: "k", ModelName: "custom-plain", MaxTokens: 1000},
+	} {
+		m.DisplayName = m.ModelID
+		if _, err := database.CreateModel(ctx, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	manager, err := NewManager(&Config{Gateway: srv.URL, FireworksAPIKey: "k", DB: database})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"glm-4.7-fireworks", "qwen3-coder-fireworks", "custom-reasoning", "custom-plain"} {
+		svc, err := manager.GetService(id)
+		if err != nil {
+			t.Fatalf("GetService(%s): %v", id, err)
+		}
+		if _, err := svc.Do(ctx, &llm.Request{Messages: []llm.Message{llm.UserStringMessage("Hi")}}); err != nil {
+			t.Fatalf("%s: Do: %v", id, err)
+		}
+	}
+
+	for model, want := range map[string]string{
+		oai.GLM47Fireworks.ModelName:      "medium",
+		oai.Qwen3CoderFireworks.ModelName: "",
+		"custom-r1":                       "medium",
+		"custom-plain":                    "",
+	} {
+		got, ok := efforts[model]
+		if !ok {
+			t.Errorf("no request for %s", model)
+		} else if got != want {
+			t.Errorf("%s: reasoning_effort = %q, want %q", model, got, want)
+		}
+	}
+}
+
+func TestManagerOllamaModel(t *testing.T) {
+	var mu sync.Mutex
+	var chat struct {
+		Think   bool           `json:"think"`
+		Options map[string]any `json:"options"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/show":
+			io.WriteString(w, `{"model_info":{"qwen3.context_length":262144},"capabilities":["completion","tools","thinking"]}`)
+		case "/api/chat":
+			mu.Lock()
+			err := json.NewDecoder(r.Body).Decode(&chat)
+			mu.Unlock()
+			if err != nil {
+				t.Errorf("decode request: %v", err)
+			}
+			io.WriteString(w, `{"message":{"role":"assistant","content":"ok"},"done":true,"done_reason":"stop"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	database, err := db.New(db.Config{DSN: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	if err := database.Migrate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// 200000 is the max tokens default for custom models.
+	_, err = database.CreateModel(ctx, generated.CreateModelParams{
+		ModelID: "custom-ollama", DisplayName: "qwen3", ProviderType: "ollama",
+		Endpoint: srv.URL, ModelName: "qwen3:30b", MaxTokens: 200000,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manager, err := NewManager(&Config{DB: database})
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc, err := manager.GetService("custom-ollama")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Do(ctx, &llm.Request{Messages: []llm.Message{llm.UserStringMessage("Hi")}}); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if got := chat.Options["num_ctx"]; got != float64(ollama.DefaultMaxContextWindow) {
+		t.Errorf("num_ctx = %v, want %d", got, ollama.DefaultMaxContextWindow)
+	}
+	if !chat.Think {
+		t.Error("expected think to be sent to a model with the thinking capability")
+	}
+}
@@ -0,0 +1,218 @@
+package models
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/tgruben-circuit/percy/llm"
+)
+
+// RoutesSettingKey is the settings key holding route overrides, as a JSON
+// object mapping task names to model IDs in the order they are tried.
+const RoutesSettingKey = "model_routes"
+
+// cheapModels are tried for lightweight side tasks that have no route.
+var cheapModels = []string{"qwen3-coder-fireworks", "claude-haiku-4.5", "gemini-3-flash", "claude-sonnet-4.5"}
+
+// defaultRoutes apply to tasks without a configured route.
+// Tasks not listed here use the caller's model.
+var defaultRoutes = map[llm.Task][]string{
+	llm.TaskKeyword:     cheapModels,
+	llm.TaskToolInstall: cheapModels,
+}
+
+// TaskRouter picks the model for a task. *Manager implements it; callers
+// that only have a narrower provider interface should type-assert for it.
+type TaskRouter interface {
+	ServiceForTask(task llm.Task, modelID string) (llm.Service, error)
+}
+
+// ParseRoutes parses and validates a route table in the RoutesSettingKey format.
+func ParseRoutes(s string) (map[llm.Task][]string, error) {
+	routes := map[llm.Task][]string{}
+	if strings.TrimSpace(s) == "" {
+		return routes, nil
+	}
+	if err := json.Unmarshal([]byte(s), &routes); err != nil {
+		return nil, fmt.Errorf("invalid model routes: %w", err)
+	}
+	for task := range routes {
+		if !slices.Contains(llm.Tasks, task) {
+			return nil, fmt.Errorf("invalid model routes: unknown task %q", task)
+		}
+	}
+	return routes, nil
+}
+
+// RouteFor returns the model ID to use for task. modelID is the caller's
+// model (usually the conversation's); it may be empty.
+//
+// For TaskMain the caller's model wins and the route only supplies
+// fallbacks for when it isn't available. For side tasks the candidates are,
+// in order: the route from settings, the route from percy.json, models
+// tagged with the task name, the built-in default route, the caller's
+// model, and finally any available model. The predictable test model is
+// never routed away from.
+func (m *Manager) RouteFor(task llm.Task, modelID string) (string, error) {
+	if modelID == "predictable" && m.HasModel(modelID) {
+		return modelID, nil
+	}
+
+	m.routesMu.RLock()
+	route, ok := m.routeOverrides[task]
+	if !ok {
+		route = m.routes[task]
+	}
+	m.routesMu.RUnlock()
+
+	var candidates []string
+	if task == llm.TaskMain {
+		candidates = append([]string{modelID}, route...)
+	} else {
+		candidates = append(candidates, route...)
+		for _, id := range m.modelOrder {
+			if hasTag(m.services[id].tags, string(task)) {
+				candidates = append(candidates, id)
+			}
+		}
+		candidates = append(candidates, defaultRoutes[task]...)
+		candidates = append(candidates, modelID)
+		for _, id := range m.modelOrder {
+			if id != "predictable" {
+				candidates = append(candidates, id)
+			}
+		}
+	}
+
+	for _, id := range candidates {
+		if id != "" && m.HasModel(id) {
+			return id, nil
+		}
+	}
+	return "", fmt.Errorf("no model available for task %s", task)
+}
+
+// ServiceForTask returns the service for the model RouteFor picks.
+// Usage of requests made through it is accounted to task.
+func (m *Manager) ServiceForTask(task llm.Task, modelID string) (llm.Service, error) {
+	id, err := m.RouteFor(task, modelID)
+	if err != nil {
+		return nil, err
+	}
+	svc, err := m.GetService(id)
+	if err != nil {
+		return nil, err
+	}
+	if m.logger != nil && id != modelID {
+		m.logger.Debug("Routed LLM task", "task", task, "model", id, "requested", modelID)
+	}
+	return &taskService{Service: svc, task: task}, nil
+}
+
+// hasTag reports whether the comma-separated tags include tag.
+func hasTag(tags, tag string) bool {
+	return slices.ContainsFunc(strings.Split(tags, ","), func(t string) bool {
+		return strings.TrimSpace(t) == tag
+	})
+}
+
+// RefreshRoutes reloads route overrides from the settings table.
+// Call this after changing the RoutesSettingKey setting.
+func (m *Manager) RefreshRoutes() error {
+	if m.db == nil {
+		return nil
+	}
+	value, err := m.db.GetSetting(context.Background(), RoutesSettingKey)
+	if err != nil {
+		return err
+	}
+	overrides, err := ParseRoutes(value)
+	if err != nil {
+		return err
+	}
+	m.routesMu.Lock()
+	m.routeOverrides = overrides
+	m.routesMu.Unlock()
+	return nil
+}
+
+// taskService tags requests with the task they serve.
+type taskService struct {
+	llm.Service
+	task llm.Task
+}
+
+func (t *taskService) Do(ctx context.Context, request *llm.Request) (*llm.Response, error) {
+	return t.Service.Do(context.WithValue(ctx, taskKey{}, t.task), request)
+}
+
+// UseSimplifiedPatch delegates to the routed service.
+func (t *taskService) UseSimplifiedPatch() bool {
+	return llm.UseSimplifiedPatch(t.Service)
+}
+
+type taskKey struct{}
+
+// taskFromContext returns the task a request serves. Requests that weren't
+// routed are made by the main loop.
+func taskFromContext(ctx context.Context) llm.Task {
+	task, _ := ctx.Value(taskKey{}).(llm.Task)
+	return cmp.Or(task, llm.TaskMain)
+}
+
+// TaskUsage is the usage accumulated by one model on one task since startup.
+type TaskUsage struct {
+	Task     llm.Task  `json:"task"`
+	Model    string    `json:"model"`
+	Requests int       `json:"requests"`
+	Usage    llm.Usage `json:"usage"`
+}
+
+// usageTracker accumulates usage per task and model.
+type usageTracker struct {
+	mu    sync.Mutex
+	usage map[[2]string]*TaskUsage
+}
+
+func (u *usageTracker) add(task llm.Task, modelID string, usage llm.Usage) {
+	if u == nil {
+		return
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	key := [2]string{string(task), modelID}
+	tu, ok := u.usage[key]
+	if !ok {
+		if u.usage == nil {
+			u.usage = map[[2]string]*TaskUsage{}
+		}
+		tu = &TaskUsage{Task: task, Model: modelID}
+		u.usage[key] = tu
+	}
+	tu.Requests++
+	tu.Usage.Add(usage)
+}
+
+func (u *usageTracker) snapshot() []TaskUsage {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	result := make([]TaskUsage, 0, len(u.usage))
+	for _, tu := range u.usage {
+		result = append(result, *tu)
+	}
+	slices.SortFunc(result, func(a, b TaskUsage) int {
+		return cmp.Or(cmp.Compare(a.Task, b.Task), cmp.Compare(a.Model, b.Model))
+	})
+	return result
+}
+
+// TaskUsage returns the usage of each task and model since startup.
+// Requests made outside ServiceForTask are accounted to TaskMain.
+func (m *Manager) TaskUsage() []TaskUsage {
+	return m.usage.snapshot()
+}
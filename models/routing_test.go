@@ -0,0 +1,201 @@
+package models
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/tgruben-circuit/percy/llm"
+)
+
+func newRoutingManager(routes map[llm.Task][]string) *Manager {
+	m := &Manager{
+		services: map[string]serviceEntry{},
+		logger:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+		usage:    &usageTracker{},
+		routes:   routes,
+	}
+	for _, e := range []serviceEntry{
+		{modelID: "big"},
+		{modelID: "small", tags: "fast, slug"},
+		{modelID: "claude-haiku-4.5"},
+		{modelID: "predictable"},
+	} {
+		e.service = &mockLLMService{}
+		m.services[e.modelID] = e
+		m.modelOrder = append(m.modelOrder, e.modelID)
+	}
+	return m
+}
+
+func TestRouteFor(t *testing.T) {
+	m := newRoutingManager(map[llm.Task][]string{
+		llm.TaskMain:          {"missing", "small"},
+		llm.TaskDistill:       {"missing", "claude-haiku-4.5"},
+		llm.TaskMemoryExtract: {"missing"},
+	})
+	m.routeOverrides = map[llm.Task][]string{llm.TaskConsolidate: {"small"}}
+
+	tests := []struct {
+		task    llm.Task
+		modelID string
+		want    string
+	}{
+		{llm.TaskMain, "big", "big"},                 // the requested model wins
+		{llm.TaskMain, "gone", "small"},              // the route is a fallback
+		{llm.TaskDistill, "big", "claude-haiku-4.5"}, // configured route
+		{llm.TaskConsolidate, "big", "small"},        // settings override
+		{llm.TaskSlug, "big", "small"},               // tagged model
+		{llm.TaskKeyword, "big", "claude-haiku-4.5"}, // built-in default route
+		{llm.TaskMemoryExtract, "big", "big"},        // caller's model
+		{llm.TaskConflictResolve, "", "big"},         // any available model
+		{llm.TaskSlug, "predictable", "predictable"}, // never routed away from
+		{llm.TaskProgressSummary, "gone", "big"},
+	}
+	for _, tt := range tests {
+		got, err := m.RouteFor(tt.task, tt.modelID)
+		if err != nil || got != tt.want {
+			t.Errorf("RouteFor(%s, %q) = %q, %v; want %q", tt.task, tt.modelID, got, err, tt.want)
+		}
+	}
+}
+
+func TestRouteForMainWithoutRoute(t *testing.T) {
+	m := newRoutingManager(nil)
+	if id, err := m.RouteFor(llm.TaskMain, "gone"); err == nil {
+		t.Errorf("RouteFor(main, missing model) = %q, want error", id)
+	}
+}
+
+func TestServiceForTaskSimplifiedPatch(t *testing.T) {
+	m := newRoutingManager(nil)
+	e := m.services["big"]
+	e.service = &mockLLMService{useSimplifiedPatch: true}
+	m.services["big"] = e
+
+	svc, err := m.ServiceForTask(llm.TaskMain, "big")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !llm.UseSimplifiedPatch(svc) {
+		t.Error("routed service lost the simplified patch setting")
+	}
+}
+
+func TestParseRoutes(t *testing.T) {
+	routes, err := ParseRoutes(`{"slug": ["small"], "main": ["big", "small"]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(routes[llm.TaskMain]) != 2 || routes[llm.TaskSlug][0] != "small" {
+		t.Errorf("routes = %v", routes)
+	}
+	if routes, err := ParseRoutes(""); err != nil || len(routes) != 0 {
+		t.Errorf("ParseRoutes(\"\") = %v, %v", routes, err)
+	}
+	if _, err := ParseRoutes(`{"slugs": ["small"]}`); err == nil {
+		t.Error("expected error for unknown task")
+	}
+}
+
+func TestTaskUsage(t *testing.T) {
+	m := newRoutingManager(nil)
+	ctx := context.Background()
+	req := &llm.Request{Messages: []llm.Message{llm.UserStringMessage("hi")}}
+
+	svc, err := m.ServiceForTask(llm.TaskSlug, "big")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		if _, err := svc.Do(ctx, req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	main, err := m.GetService("big")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := main.Do(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+
+	usage := m.TaskUsage()
+	if len(usage) != 2 {
+		t.Fatalf("TaskUsage() = %+v, want 2 entries", usage)
+	}
+	if u := usage[0]; u.Task != llm.TaskMain || u.Model != "big" || u.Requests != 1 {
+		t.Errorf("usage[0] = %+v", u)
+	}
+	if u := usage[1]; u.Task != llm.TaskSlug || u.Model != "small" || u.Requests != 2 || u.Usage.InputTokens != 20 {
+		t.Errorf("usage[1] = %+v", u)
+	}
+}
+
+// batchMockService is a mockLLMService whose batches finish immediately.
+type batchMockService struct {
+	mockLLMService
+	submitted int
+}
+
+func (b *batchMockService) SubmitBatch(ctx context.Context, reqs []*llm.Request) (string, error) {
+	b.submitted += len(reqs)
+	return "b1", nil
+}
+
+func (b *batchMockService) PollBatch(ctx context.Context, id string) (bool, error) { return true, nil }
+
+func (b *batchMockService) CollectBatch(ctx context.Context, id string) ([]llm.BatchResult, error) {
+	resp, err := b.Do(ctx, nil)
+	return []llm.BatchResult{{Response: resp, Err: err}}, nil
+}
+
+func TestBatchServiceForTask(t *testing.T) {
+	m := newRoutingManager(nil)
+	batcher := &batchMockService{}
+	entry := m.services["small"]
+	entry.service = batcher
+	m.services["small"] = entry
+
+	// Models without a batch API get the ordinary service
+	svc, err := m.BatchServiceForTask(llm.TaskMemoryExtract, "big")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Do(context.Background(), &llm.Request{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(m.batchers) != 0 {
+		t.Error("batching service created for a model without a batch API")
+	}
+
+	svc, err = m.BatchServiceForTask(llm.TaskMemoryExtract, "small")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.batchers["small"].PollInterval = time.Millisecond
+	m.batchers["small"].Delay = time.Millisecond
+	if _, err := svc.Do(context.Background(), &llm.Request{}); err != nil {
+		t.Fatal(err)
+	}
+	if batcher.submitted != 1 {
+		t.Errorf("submitted %d requests, want 1", batcher.submitted)
+	}
+
+	// Usage is still accounted to the task
+	var found bool
+	for _, u := range m.TaskUsage() {
+		if u.Task == llm.TaskMemoryExtract && u.Model == "small" && u.Requests == 1 {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("usage = %+v", m.TaskUsage())
+	}
+
+	if again, _ := m.BatchServiceForTask(llm.TaskConsolidate, "small"); again == nil || len(m.batchers) != 1 {
+		t.Error("batching service not reused")
+	}
+}
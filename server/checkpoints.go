@@ -0,0 +1,222 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/tgruben-circuit/percy/db"
+	"github.com/tgruben-circuit/percy/db/generated"
+)
+
+// maxCheckpointFileSize is the largest file that is checkpointed before an
+// edit. Larger files, typically build outputs or data, are recorded as
+// omitted, and a restore that would need their content is refused.
+const maxCheckpointFileSize = 4 << 20
+
+// errCheckpointOmitted reports a restore that needs a file whose content was
+// too large to checkpoint.
+var errCheckpointOmitted = errors.New("file was too large to checkpoint")
+
+// fileCheckpointer implements claudetool.Checkpointer by storing file
+// contents in the database under the conversation's current turn.
+type fileCheckpointer struct {
+	db             *db.DB
+	conversationID string
+}
+
+func (c *fileCheckpointer) SaveFiles(ctx context.Context, paths []string) error {
+	var snapshots []db.FileSnapshot
+	for _, p := range paths {
+		fi, err := os.Lstat(p)
+		if errors.Is(err, fs.ErrNotExist) {
+			snapshots = append(snapshots, db.FileSnapshot{Path: p})
+			continue
+		}
+		// Directories, symlinks and special files are not restorable as
+		// file contents.
+		if err != nil || !fi.Mode().IsRegular() {
+			continue
+		}
+		// Oversized files would bloat the database; record that they
+		// existed so a restore can refuse rather than skip them.
+		if fi.Size() > maxCheckpointFileSize {
+			snapshots = append(snapshots, db.FileSnapshot{Path: p, Existed: true, Mode: fi.Mode(), Omitted: true})
+			continue
+		}
+		content, err := os.ReadFile(p)
+		if err != nil {
+			continue
+		}
+		snapshots = append(snapshots, db.FileSnapshot{Path: p, Existed: true, Mode: fi.Mode(), Content: content})
+	}
+	if len(snapshots) == 0 {
+		return nil
+	}
+	return c.db.SaveFileCheckpoints(ctx, c.conversationID, snapshots)
+}
+
+// CheckpointTurn lists the files checkpointed during one agent turn.
+type CheckpointTurn struct {
+	// MessageID and SequenceID identify the user message that started the turn.
+	MessageID  string           `json:"message_id"`
+	SequenceID int64            `json:"sequence_id"`
+	Files      []CheckpointFile `json:"files"`
+}
+
+// CheckpointFile is a file as it was before the agent first changed it in a turn.
+type CheckpointFile struct {
+	Path      string    `json:"path"`
+	Existed   bool      `json:"existed"`
+	Omitted   bool      `json:"omitted,omitempty"` // too large to checkpoint
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// RestoredFile describes what restoring a checkpoint did to a file.
+type RestoredFile struct {
+	Path string `json:"path"`
+	// Action is "restored" or "deleted", for files the agent created.
+	Action string `json:"action"`
+}
+
+// RestoreCheckpointResponse is the response to a checkpoint restore.
+type RestoreCheckpointResponse struct {
+	Files []RestoredFile `json:"files"`
+}
+
+// handleListCheckpoints handles GET /conversation/<id>/checkpoints
+func (s *Server) handleListCheckpoints(w http.ResponseWriter, r *http.Request, conversationID string) {
+	rows, err := s.db.ListFileCheckpoints(r.Context(), conversationID)
+	if err != nil {
+		s.logger.Error("Failed to list checkpoints", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	turns := []CheckpointTurn{}
+	for _, row := range rows {
+		if len(turns) == 0 || turns[len(turns)-1].MessageID != row.MessageID {
+			turns = append(turns, CheckpointTurn{MessageID: row.MessageID, SequenceID: row.SequenceID})
+		}
+		turn := &turns[len(turns)-1]
+		turn.Files = append(turn.Files, CheckpointFile{Path: row.Path, Existed: row.Existed, Omitted: row.Omitted, CreatedAt: row.CreatedAt})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(turns) //nolint:errchkjson // best-effort HTTP response
+}
+
+// handleRestoreCheckpoint handles POST /conversation/<id>/checkpoints/<message_id>/restore.
+// It returns every file the agent changed in the turn started by the message,
+// or in a later turn, to its state before that turn.
+func (s *Server) handleRestoreCheckpoint(w http.ResponseWriter, r *http.Request, conversationID, messageID string) {
+	ctx := r.Context()
+
+	s.mu.Lock()
+	manager, exists := s.activeConversations[conversationID]
+	s.mu.Unlock()
+	if exists && manager.IsAgentWorking() {
+		http.Error(w, "Cannot restore files while the agent is working", http.StatusConflict)
+		return
+	}
+
+	message, err := s.db.GetMessageByID(ctx, messageID)
+	if err != nil || message.ConversationID != conversationID {
+		http.Error(w, "Message not found", http.StatusNotFound)
+		return
+	}
+
+	files, err := restoreFileCheckpoints(ctx, s.db, conversationID, message.SequenceID)
+	if errors.Is(err, errCheckpointOmitted) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		s.logger.Error("Failed to restore checkpoint", "conversationID", conversationID, "messageID", messageID, "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(RestoreCheckpointResponse{Files: files}) //nolint:errchkjson // best-effort HTTP response
+}
+
+// restoreFileCheckpoints returns each file checkpointed at or after
+// sequenceID to its earliest checkpointed state, then deletes those
+// checkpoints. If a file cannot be restored the checkpoints are kept, so
+// the restore can be retried. If a file was too large to checkpoint, nothing
+// is restored.
+func restoreFileCheckpoints(ctx context.Context, database *db.DB, conversationID string, sequenceID int64) ([]RestoredFile, error) {
+	checkpoints, err := database.ListFileCheckpointsSince(ctx, conversationID, sequenceID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Checkpoints are oldest first, so the first one per path is its state
+	// before the turn.
+	var first []generated.FileCheckpoint
+	seen := make(map[string]bool)
+	for _, cp := range checkpoints {
+		if seen[cp.Path] {
+			continue
+		}
+		seen[cp.Path] = true
+		if cp.Omitted {
+			return nil, fmt.Errorf("cannot restore %s: %w (over %d bytes)", cp.Path, errCheckpointOmitted, maxCheckpointFileSize)
+		}
+		first = append(first, cp)
+	}
+
+	restored := []RestoredFile{}
+	var errs []error
+	for _, cp := range first {
+
+		if !cp.Existed {
+			if err := os.Remove(cp.Path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				errs = append(errs, err)
+				continue
+			}
+			restored = append(restored, RestoredFile{Path: cp.Path, Action: "deleted"})
+			continue
+		}
+		mode := fs.FileMode(cp.Mode).Perm()
+		if mode == 0 {
+			mode = 0o644
+		}
+		if err := writeCheckpointFile(cp.Path, cp.Content, mode); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		restored = append(restored, RestoredFile{Path: cp.Path, Action: "restored"})
+	}
+	if len(errs) > 0 {
+		return restored, fmt.Errorf("failed to restore %d of %d files: %w", len(errs), len(seen), errors.Join(errs...))
+	}
+	if err := database.DeleteFileCheckpointsSince(ctx, conversationID, sequenceID); err != nil {
+		return restored, err
+	}
+	return restored, nil
+}
+
+func writeCheckpointFile(path string, content []byte, mode fs.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	// A directory may have been created where the file was.
+	if fi, err := os.Lstat(path); err == nil && !fi.Mode().IsRegular() {
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+	}
+	if err := os.WriteFile(path, content, mode); err != nil {
+		return err
+	}
+	// WriteFile keeps the permissions of an existing file.
+	return os.Chmod(path, mode)
+}
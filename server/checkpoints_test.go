@@ -0,0 +1,166 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tgruben-circuit/percy/db"
+)
+
+func TestFileCheckpointRestore(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "a.txt")
+	created := filepath.Join(dir, "b.txt")
+	if err := os.WriteFile(existing, []byte("original\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	h.NewConversation("bash: echo first > a.txt && echo new > b.txt", dir)
+	h.WaitToolResult()
+	waitAgentIdle(t, h)
+	h.Chat("bash: echo second > a.txt")
+	h.WaitToolResult()
+	waitAgentIdle(t, h)
+
+	if got, _ := os.ReadFile(existing); string(got) != "second\n" {
+		t.Fatalf("a.txt = %q before restore", got)
+	}
+
+	req := httptest.NewRequest("GET", "/api/conversation/"+h.convID+"/checkpoints", nil)
+	w := httptest.NewRecorder()
+	h.server.handleListCheckpoints(w, req, h.convID)
+	if w.Code != http.StatusOK {
+		t.Fatalf("list checkpoints: status %d: %s", w.Code, w.Body.String())
+	}
+	var turns []CheckpointTurn
+	if err := json.Unmarshal(w.Body.Bytes(), &turns); err != nil {
+		t.Fatal(err)
+	}
+	if len(turns) != 2 {
+		t.Fatalf("got %d checkpoint turns, want 2: %s", len(turns), w.Body.String())
+	}
+	if n := len(turns[0].Files); n != 2 {
+		t.Errorf("first turn checkpointed %d files, want 2", n)
+	}
+
+	// Undoing the first turn undoes the second one too.
+	userMessages, err := h.db.ListMessagesByType(context.Background(), h.convID, db.MessageTypeUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if turns[0].MessageID != userMessages[0].MessageID {
+		t.Fatalf("first turn is message %s, want %s", turns[0].MessageID, userMessages[0].MessageID)
+	}
+	req = httptest.NewRequest("POST", "/api/conversation/"+h.convID+"/checkpoints/"+turns[0].MessageID+"/restore", nil)
+	w = httptest.NewRecorder()
+	h.server.handleRestoreCheckpoint(w, req, h.convID, turns[0].MessageID)
+	if w.Code != http.StatusOK {
+		t.Fatalf("restore: status %d: %s", w.Code, w.Body.String())
+	}
+
+	if got, _ := os.ReadFile(existing); string(got) != "original\n" {
+		t.Errorf("a.txt = %q after restore, want original", got)
+	}
+	if fi, err := os.Stat(existing); err != nil || fi.Mode().Perm() != 0o600 {
+		t.Errorf("a.txt mode not restored: %v %v", fi.Mode(), err)
+	}
+	if _, err := os.Stat(created); !os.IsNotExist(err) {
+		t.Errorf("b.txt should have been deleted, stat error: %v", err)
+	}
+
+	remaining, err := h.db.ListFileCheckpoints(context.Background(), h.convID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(remaining) != 0 {
+		t.Errorf("%d checkpoints remain after restore", len(remaining))
+	}
+}
+
+func TestFileCheckpointRestoreOmitted(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	h.NewConversation("hello", "")
+	h.WaitResponse()
+	waitAgentIdle(t, h)
+
+	dir := t.TempDir()
+	small := filepath.Join(dir, "small.txt")
+	large := filepath.Join(dir, "large.bin")
+	if err := os.WriteFile(small, []byte("original\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(large, make([]byte, maxCheckpointFileSize+1), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cp := &fileCheckpointer{db: h.db, conversationID: h.convID}
+	if err := cp.SaveFiles(context.Background(), []string{small, large}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(small, []byte("changed\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("GET", "/api/conversation/"+h.convID+"/checkpoints", nil)
+	w := httptest.NewRecorder()
+	h.server.handleListCheckpoints(w, req, h.convID)
+	var turns []CheckpointTurn
+	if err := json.Unmarshal(w.Body.Bytes(), &turns); err != nil {
+		t.Fatal(err)
+	}
+	if len(turns) != 1 || len(turns[0].Files) != 2 || !turns[0].Files[1].Omitted {
+		t.Fatalf("checkpoints = %s, want large.bin marked omitted", w.Body.String())
+	}
+
+	// The restore is refused rather than leaving large.bin as it is.
+	req = httptest.NewRequest("POST", "/api/conversation/"+h.convID+"/checkpoints/"+turns[0].MessageID+"/restore", nil)
+	w = httptest.NewRecorder()
+	h.server.handleRestoreCheckpoint(w, req, h.convID, turns[0].MessageID)
+	if w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), "large.bin") {
+		t.Errorf("restore: status %d: %s, want %d naming large.bin", w.Code, w.Body.String(), http.StatusConflict)
+	}
+	if got, _ := os.ReadFile(small); string(got) != "changed\n" {
+		t.Errorf("small.txt = %q, want it untouched by the refused restore", got)
+	}
+}
+
+func TestFileCheckpointRestoreUnknownMessage(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	h.NewConversation("hello", "")
+	h.WaitResponse()
+
+	req := httptest.NewRequest("POST", "/api/conversation/"+h.convID+"/checkpoints/nope/restore", nil)
+	w := httptest.NewRecorder()
+	h.server.handleRestoreCheckpoint(w, req, h.convID, "nope")
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func waitAgentIdle(t *testing.T, h *TestHarness) {
+	t.Helper()
+	deadline := time.Now().Add(h.timeout)
+	for time.Now().Before(deadline) {
+		h.server.mu.Lock()
+		manager := h.server.activeConversations[h.convID]
+		h.server.mu.Unlock()
+		if manager != nil && !manager.IsAgentWorking() {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatal("agent did not finish working")
+}
@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/tgruben-circuit/percy/cluster"
+	"github.com/tgruben-circuit/percy/llm"
 )
 
 // startClusterMonitor starts the orchestrator monitor if this node is the orchestrator.
@@ -30,7 +31,7 @@ func (s *Server) startClusterMonitor() {
 	}
 
 	var resolver cluster.ConflictResolver
-	llmService, err := s.llmManager.GetService(s.defaultModel)
+	llmService, err := s.serviceForTask(llm.TaskConflictResolve, s.defaultModel)
 	if err == nil {
 		resolver = cluster.NewLLMConflictResolver(llmService)
 	}
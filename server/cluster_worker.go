@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 	"time"
 
+	"github.com/tgruben-circuit/percy/claudetool/sandbox"
 	"github.com/tgruben-circuit/percy/cluster"
 	"github.com/tgruben-circuit/percy/db"
 	"github.com/tgruben-circuit/percy/llm"
@@ -39,6 +40,13 @@ func (s *Server) executeClusterTask(ctx context.Context, task cluster.Task) clus
 	taskID := task.ID
 	branchName := fmt.Sprintf("agent/%s/%s", agentID, taskID)
 
+	// Cluster tasks always run bash commands in the sandbox; refuse them
+	// where it cannot run rather than run them unconfined.
+	if err := sandbox.Available(); err != nil {
+		s.logger.Error("Cannot run cluster task", "task", taskID, "error", err)
+		return cluster.TaskResult{Summary: err.Error()}
+	}
+
 	// 1. Create git worktree
 	worktreeDir, err := s.createWorktree(ctx, task, branchName)
 	if err != nil {
@@ -57,6 +65,10 @@ func (s *Server) executeClusterTask(ctx context.Context, task cluster.Task) clus
 		return cluster.TaskResult{Summary: fmt.Sprintf("conversation creation failed: %v", err)}
 	}
 
+	if err := s.db.UpdateConversationSandbox(ctx, conv.ConversationID, true); err != nil {
+		return cluster.TaskResult{Summary: fmt.Sprintf("enabling sandbox failed: %v", err)}
+	}
+
 	// 3. Insert system prompt directly into DB
 	systemPrompt := fmt.Sprintf(
 		"You are a worker agent executing a task from the cluster orchestrator.\n"+
@@ -78,7 +90,7 @@ func (s *Server) executeClusterTask(ctx context.Context, task cluster.Task) clus
 		return cluster.TaskResult{Summary: fmt.Sprintf("manager creation failed: %v", err)}
 	}
 
-	llmService, err := s.llmManager.GetService(modelID)
+	llmService, err := s.serviceForTask(llm.TaskMain, modelID)
 	if err != nil {
 		return cluster.TaskResult{Summary: fmt.Sprintf("llm service failed: %v", err)}
 	}
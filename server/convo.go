@@ -35,10 +35,17 @@ type ConversationManager struct {
 	mu             sync.Mutex
 	lastActivity   time.Time
 	modelID        string
-	recordMessage  loop.MessageRecordFunc
-	logger         *slog.Logger
-	toolSetConfig  claudetool.ToolSetConfig
-	toolSet        *claudetool.ToolSet // created per-conversation when loop starts
+	thinkingLevel  *llm.ThinkingLevel // nil uses the model's default
+	// patchDiagnostics reports language-server errors introduced by patches.
+	patchDiagnostics bool
+	// sandbox runs bash commands in the sandbox. sandboxRequired keeps it
+	// on, for cluster tasks and subagents of sandboxed conversations.
+	sandbox         bool
+	sandboxRequired bool
+	recordMessage   loop.MessageRecordFunc
+	logger          *slog.Logger
+	toolSetConfig   claudetool.ToolSetConfig
+	toolSet         *claudetool.ToolSet // created per-conversation when loop starts
 
 	subpub *subpub.SubPub[StreamResponse]
 
@@ -76,6 +83,7 @@ func NewConversationManager(conversationID string, database *db.DB, memoryDB *me
 		recordMessage:      recordMessage,
 		logger:             logger,
 		toolSetConfig:      toolSetConfig,
+		patchDiagnostics:   true,
 		subpub:             subpub.New[StreamResponse](),
 		onStateChange:      onStateChange,
 		onConversationDone: onConversationDone,
@@ -151,6 +159,26 @@ func (cm *ConversationManager) Hydrate(ctx context.Context) error {
 		modelID = *conversation.Model
 	}
 
+	sandboxRequired, err := conversationSandboxRequired(ctx, cm.db, conversation)
+	if err != nil {
+		return err
+	}
+	if sandboxRequired && !conversation.Sandbox {
+		if err := cm.db.UpdateConversationSandbox(ctx, cm.conversationID, true); err != nil {
+			return fmt.Errorf("failed to enable sandbox: %w", err)
+		}
+	}
+
+	var thinkingLevel *llm.ThinkingLevel
+	if conversation.ThinkingLevel != nil {
+		level, err := llm.ParseThinkingLevel(*conversation.ThinkingLevel)
+		if err != nil {
+			cm.logger.Warn("Ignoring conversation thinking level", "error", err)
+		} else {
+			thinkingLevel = &level
+		}
+	}
+
 	// Generate system prompt if missing:
 	// - For user-initiated conversations: full system prompt
 	// - For subagent conversations (has parent): minimal subagent prompt
@@ -183,6 +211,10 @@ func (cm *ConversationManager) Hydrate(ctx context.Context) error {
 	cm.lastActivity = time.Now()
 	cm.hydrated = true
 	cm.modelID = modelID
+	cm.thinkingLevel = thinkingLevel
+	cm.patchDiagnostics = conversation.PatchDiagnostics
+	cm.sandbox = conversation.Sandbox || sandboxRequired
+	cm.sandboxRequired = sandboxRequired
 	cm.mu.Unlock()
 
 	if modelID != "" {
@@ -196,6 +228,17 @@ func (cm *ConversationManager) Hydrate(ctx context.Context) error {
 // The message is recorded to the database immediately so it appears in the UI,
 // even if the loop is busy processing a previous request.
 func (cm *ConversationManager) AcceptUserMessage(ctx context.Context, service llm.Service, modelID string, message llm.Message) (bool, error) {
+	return cm.acceptUserMessage(ctx, service, modelID, message, nil)
+}
+
+// AcceptUserMessageWithThinking is like AcceptUserMessage, but the turn that
+// answers the message uses the given thinking level instead of the
+// conversation's.
+func (cm *ConversationManager) AcceptUserMessageWithThinking(ctx context.Context, service llm.Service, modelID string, message llm.Message, level llm.ThinkingLevel) (bool, error) {
+	return cm.acceptUserMessage(ctx, service, modelID, message, &level)
+}
+
+func (cm *ConversationManager) acceptUserMessage(ctx context.Context, service llm.Service, modelID string, message llm.Message, thinking *llm.ThinkingLevel) (bool, error) {
 	if service == nil {
 		return false, fmt.Errorf("llm service is required")
 	}
@@ -229,7 +272,11 @@ func (cm *ConversationManager) AcceptUserMessage(ctx context.Context, service ll
 		}
 	}
 
-	loopInstance.QueueUserMessage(message)
+	if thinking != nil {
+		loopInstance.QueueUserMessageWithThinking(message, *thinking)
+	} else {
+		loopInstance.QueueUserMessage(message)
+	}
 
 	// Mark agent as working - we just queued work for the loop
 	cm.SetAgentWorking(true)
@@ -237,6 +284,55 @@ func (cm *ConversationManager) AcceptUserMessage(ctx context.Context, service ll
 	return isFirst, nil
 }
 
+// SetThinkingLevel changes the conversation's thinking level, taking effect
+// from its next LLM request. Nil reverts to the model's default. The caller
+// persists the level.
+func (cm *ConversationManager) SetThinkingLevel(level *llm.ThinkingLevel) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	cm.thinkingLevel = level
+	if cm.loop != nil {
+		cm.loop.SetThinkingLevel(level)
+	}
+}
+
+// SetPatchDiagnostics turns reporting of errors introduced by patches on or
+// off. The caller persists the setting.
+func (cm *ConversationManager) SetPatchDiagnostics(enabled bool) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	cm.patchDiagnostics = enabled
+	if cm.toolSet != nil {
+		cm.toolSet.SetPatchDiagnostics(enabled)
+	}
+}
+
+// SetSandbox turns running bash commands in the sandbox on or off. It fails
+// to turn off a required sandbox. The caller persists the setting.
+func (cm *ConversationManager) SetSandbox(enabled bool) error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	if !enabled && cm.sandboxRequired {
+		return errSandboxRequired
+	}
+	cm.sandbox = enabled
+	if cm.toolSet != nil {
+		cm.toolSet.SetSandbox(enabled)
+	}
+	return nil
+}
+
+// Jobs returns the background jobs of the running loop, or nil if the loop
+// is not running.
+func (cm *ConversationManager) Jobs() *claudetool.JobManager {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	if cm.toolSet == nil {
+		return nil
+	}
+	return cm.toolSet.Jobs()
+}
+
 // Touch updates last activity timestamp.
 func (cm *ConversationManager) Touch() {
 	cm.mu.Lock()
@@ -414,6 +510,8 @@ func (cm *ConversationManager) ensureLoop(service llm.Service, modelID string) e
 	logger := cm.logger
 	cwd := cm.cwd
 	toolSetConfig := cm.toolSetConfig
+	toolSetConfig.PatchDiagnostics = cm.patchDiagnostics
+	toolSetConfig.SandboxEnabled = cm.sandbox
 	conversationID := cm.conversationID
 	db := cm.db
 	cm.mu.Unlock()
@@ -439,6 +537,8 @@ func (cm *ConversationManager) ensureLoop(service llm.Service, modelID string) e
 	toolSetConfig.ModelID = modelID
 	toolSetConfig.ConversationID = conversationID
 	toolSetConfig.ParentConversationID = conversationID // For subagent tool
+	toolSetConfig.Checkpointer = &fileCheckpointer{db: db, conversationID: conversationID}
+	toolSetConfig.TodoStore = &todoStore{db: db, conversationID: conversationID}
 	toolSetConfig.OnWorkingDirChange = func(newDir string) {
 		// Persist working directory change to database
 		if err := db.UpdateConversationCwd(context.Background(), conversationID, newDir); err != nil {
@@ -506,6 +606,10 @@ func (cm *ConversationManager) ensureLoop(service llm.Service, modelID string) e
 	}
 	// Check if we need to persist the model (for conversations created before model column existed)
 	needsPersist := cm.modelID == "" && modelID != ""
+	// Set under the lock so concurrent setters aren't lost
+	loopInstance.SetThinkingLevel(cm.thinkingLevel)
+	toolSet.SetPatchDiagnostics(cm.patchDiagnostics)
+	toolSet.SetSandbox(cm.sandbox)
 	cm.loop = loopInstance
 	cm.loopCancel = cancel
 	cm.loopCtx = processCtx
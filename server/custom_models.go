@@ -14,6 +14,8 @@ import (
 	"github.com/tgruben-circuit/percy/llm/ant"
 	"github.com/tgruben-circuit/percy/llm/gem"
 	"github.com/tgruben-circuit/percy/llm/oai"
+	"github.com/tgruben-circuit/percy/llm/ollama"
+	"github.com/tgruben-circuit/percy/models"
 )
 
 // ModelAPI is the API representation of a model
@@ -57,6 +59,7 @@ type TestModelRequest struct {
 	Endpoint     string `json:"endpoint"`
 	APIKey       string `json:"api_key"`
 	ModelName    string `json:"model_name"`
+	Tags         string `json:"tags"` // Comma-separated tags
 }
 
 func toModelAPI(m generated.Model) ModelAPI {
@@ -107,14 +110,14 @@ func (s *Server) handleCreateModel(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Validate required fields
-	if req.DisplayName == "" || req.ProviderType == "" || req.Endpoint == "" || req.APIKey == "" || req.ModelName == "" {
+	if req.DisplayName == "" || req.ProviderType == "" || req.Endpoint == "" || (req.APIKey == "" && requiresAPIKey(req.ProviderType)) || req.ModelName == "" {
 		http.Error(w, "display_name, provider_type, endpoint, api_key, and model_name are required", http.StatusBadRequest)
 		return
 	}
 
 	// Validate provider type
-	if req.ProviderType != "anthropic" && req.ProviderType != "openai" && req.ProviderType != "openai-responses" && req.ProviderType != "gemini" {
-		http.Error(w, "provider_type must be 'anthropic', 'openai', 'openai-responses', or 'gemini'", http.StatusBadRequest)
+	if req.ProviderType != "anthropic" && req.ProviderType != "openai" && req.ProviderType != "openai-responses" && req.ProviderType != "gemini" && req.ProviderType != "ollama" {
+		http.Error(w, "provider_type must be 'anthropic', 'openai', 'openai-responses', 'gemini', or 'ollama'", http.StatusBadRequest)
 		return
 	}
 
@@ -339,13 +342,14 @@ func (s *Server) handleTestModel(w http.ResponseWriter, r *http.Request) {
 		req.APIKey = model.ApiKey
 	}
 
-	if req.ProviderType == "" || req.Endpoint == "" || req.APIKey == "" || req.ModelName == "" {
+	if req.ProviderType == "" || req.Endpoint == "" || (req.APIKey == "" && requiresAPIKey(req.ProviderType)) || req.ModelName == "" {
 		http.Error(w, "provider_type, endpoint, api_key, and model_name are required", http.StatusBadRequest)
 		return
 	}
 
 	// Create the appropriate service based on provider type
 	var service llm.Service
+	timeout := 10 * time.Second
 	switch req.ProviderType {
 	case "anthropic":
 		service = &ant.Service{
@@ -355,14 +359,7 @@ func (s *Server) handleTestModel(w http.ResponseWriter, r *http.Request) {
 			ThinkingLevel: llm.ThinkingLevelMedium,
 		}
 	case "openai":
-		service = &oai.Service{
-			APIKey:   req.APIKey,
-			ModelURL: req.Endpoint,
-			Model: oai.Model{
-				ModelName: req.ModelName,
-				URL:       req.Endpoint,
-			},
-		}
+		service = models.CustomChatService(req.APIKey, req.Endpoint, req.ModelName, req.Tags, 0, nil)
 	case "gemini":
 		service = &gem.Service{
 			APIKey: req.APIKey,
@@ -377,13 +374,21 @@ func (s *Server) handleTestModel(w http.ResponseWriter, r *http.Request) {
 				URL:       req.Endpoint,
 			},
 		}
+	case "ollama":
+		service = &ollama.Service{
+			URL:           req.Endpoint,
+			Model:         req.ModelName,
+			ThinkingLevel: llm.ThinkingLevelMedium,
+		}
+		// A local model may need loading into memory first
+		timeout = 2 * time.Minute
 	default:
 		http.Error(w, "Invalid provider_type", http.StatusBadRequest)
 		return
 	}
 
 	// Send a simple test request
-	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
 	defer cancel()
 
 	request := &llm.Request{
@@ -423,3 +428,9 @@ func (s *Server) handleTestModel(w http.ResponseWriter, r *http.Request) {
 		"message": fmt.Sprintf("Test successful! Response: %s", response.Content[0].Text),
 	})
 }
+
+// requiresAPIKey reports whether models of the given provider type need an
+// API key. Local servers such as Ollama don't.
+func requiresAPIKey(providerType string) bool {
+	return providerType != "ollama"
+}
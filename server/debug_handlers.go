@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/tgruben-circuit/percy/models"
 	"github.com/tgruben-circuit/percy/ui"
 )
 
@@ -52,6 +53,30 @@ func (s *Server) handleDebugLLMRequestsAPI(w http.ResponseWriter, r *http.Reques
 	_ = json.NewEncoder(w).Encode(requests) //nolint:errchkjson // best-effort HTTP response
 }
 
+// handleDebugLLMRateLimits returns the queue depth and recent usage of each
+// configured LLM rate limit
+func (s *Server) handleDebugLLMRateLimits(w http.ResponseWriter, r *http.Request) {
+	stats := []models.RateLimitStats{}
+	if rl, ok := s.llmManager.(interface {
+		RateLimitStats() []models.RateLimitStats
+	}); ok {
+		stats = append(stats, rl.RateLimitStats()...)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(stats) //nolint:errchkjson // best-effort HTTP response
+}
+
+// handleDebugLLMUsage returns the LLM usage of each task class and model
+// since startup
+func (s *Server) handleDebugLLMUsage(w http.ResponseWriter, r *http.Request) {
+	usage := []models.TaskUsage{}
+	if tu, ok := s.llmManager.(interface{ TaskUsage() []models.TaskUsage }); ok {
+		usage = append(usage, tu.TaskUsage()...)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(usage) //nolint:errchkjson // best-effort HTTP response
+}
+
 // handleDebugLLMRequestBody returns the request body for a specific LLM request
 func (s *Server) handleDebugLLMRequestBody(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
@@ -241,15 +266,31 @@ tr:hover { background: #f8f8f8; }
 .boolean { color: #0097a7; }
 .null { color: #7b1fa2; }
 .key { color: #c62828; }
+.link { color: #1976d2; cursor: pointer; text-decoration: underline; }
+#diff-view { margin-bottom: 24px; }
+#diff-view h2 { font-size: 18px; margin: 0 0 12px 0; display: flex; align-items: center; gap: 8px; }
+.warning { color: #e65100; }
+.added { color: #2e7d32; }
+.removed { color: #c62828; }
+.changed { color: #f57c00; }
+.blocks { list-style: none; margin: 0; padding: 0; font-size: 12px; }
+.blocks li { padding: 2px 6px; border-left: 3px solid transparent; }
+.blocks li.cached { background: #e8f5e9; }
+.blocks li.diff-added { border-left-color: #2e7d32; }
+.blocks li.diff-changed { border-left-color: #f57c00; }
+.blocks li.breakpoint { border-bottom: 2px dashed #1976d2; }
+.blocks .kind { display: inline-block; width: 60px; color: #666; }
 </style>
 </head>
 <body>
 <h1>LLM Requests</h1>
+<div id="diff-view" style="display: none"></div>
 <table id="requests-table">
 <thead>
 <tr>
 	<th>ID</th>
 	<th>Time</th>
+	<th>Conversation</th>
 	<th>Model</th>
 	<th>Provider</th>
 	<th>Status</th>
@@ -261,7 +302,7 @@ tr:hover { background: #f8f8f8; }
 </tr>
 </thead>
 <tbody id="requests-body">
-<tr><td colspan="10" class="loading">Loading...</td></tr>
+<tr><td colspan="11" class="loading">Loading...</td></tr>
 </tbody>
 </table>
 
@@ -321,14 +362,14 @@ async function loadRequests() {
 		renderTable(data);
 	} catch (e) {
 		document.getElementById('requests-body').innerHTML =
-			'<tr><td colspan="10" class="error">Error loading requests: ' + e.message + '</td></tr>';
+			'<tr><td colspan="11" class="error">Error loading requests: ' + e.message + '</td></tr>';
 	}
 }
 
 function renderTable(requests) {
 	const tbody = document.getElementById('requests-body');
 	if (!requests || requests.length === 0) {
-		tbody.innerHTML = '<tr><td colspan="10">No requests found</td></tr>';
+		tbody.innerHTML = '<tr><td colspan="11">No requests found</td></tr>';
 		return;
 	}
 	tbody.innerHTML = '';
@@ -348,6 +389,7 @@ function renderTable(requests) {
 		tr.innerHTML = ` + "`" + `
 			<td class="mono">${req.id}</td>
 			<td>${formatDate(req.created_at)}</td>
+			<td class="mono">${req.conversation_id ? '<span class="link" onclick="loadDiff(\'' + req.conversation_id + '\')">' + req.conversation_id + '</span>' : '-'}</td>
 			<td>${formatModel(req.model, req.model_display_name)}</td>
 			<td>${req.provider}</td>
 			<td class="${statusClass}">${req.status_code || '-'}${req.error ? ' ⚠' : ''}</td>
@@ -375,7 +417,7 @@ async function toggleExpand(id, hasPrefix) {
 	expandRow.id = 'expand-' + id;
 	expandRow.className = 'expand-row';
 	expandRow.innerHTML = ` + "`" + `
-		<td colspan="10">
+		<td colspan="11">
 			<div class="expand-content">
 				<div class="panels">
 					<div class="panel">
@@ -498,7 +540,101 @@ function renderBody(id, type, data) {
 	}
 }
 
+function escapeHTML(s) {
+	return String(s).replace(/&/g, '&amp;').replace(/</g, '&lt;').replace(/>/g, '&gt;');
+}
+
+// Shows, for each request of a conversation, what changed since the
+// previous request to the same model and how the prompt cache was used.
+async function loadDiff(conversationId) {
+	const view = document.getElementById('diff-view');
+	view.style.display = '';
+	view.innerHTML = '<p class="loading">Loading conversation ' + escapeHTML(conversationId) + '...</p>';
+	history.replaceState(null, '', '?conversation_id=' + encodeURIComponent(conversationId));
+	try {
+		const resp = await fetch('/debug/llm_requests/diff?conversation_id=' + encodeURIComponent(conversationId));
+		if (!resp.ok) throw new Error(await resp.text());
+		renderDiff(conversationId, await resp.json());
+	} catch (e) {
+		view.innerHTML = '<p class="error">Error loading conversation: ' + escapeHTML(e.message) + '</p>';
+	}
+}
+
+function closeDiff() {
+	document.getElementById('diff-view').style.display = 'none';
+	history.replaceState(null, '', location.pathname);
+}
+
+function renderDiff(conversationId, analyses) {
+	let html = '<h2>Conversation ' + escapeHTML(conversationId) +
+		' <button class="btn" onclick="closeDiff()">Close</button></h2>';
+	html += '<table><thead><tr><th>ID</th><th>Time</th><th>Model</th><th>Prefix</th><th>Changes</th>' +
+		'<th>Breakpoints</th><th>Cache Read</th><th>Cache Write</th><th>Input</th><th>Actions</th></tr></thead><tbody>';
+	for (const a of analyses) {
+		const counts = { added: 0, changed: 0, removed: 0 };
+		for (const c of a.changes) counts[c.op]++;
+		let changes = a.previous_id === null ? 'first request' :
+			'<span class="added">+' + counts.added + '</span> <span class="changed">~' + counts.changed +
+			'</span> <span class="removed">-' + counts.removed + '</span>';
+		if (a.system_changed) changes += ' <span class="warning">system</span>';
+		if (a.tools_changed) changes += ' <span class="warning">tools</span>';
+		const u = a.usage;
+		html += '<tr id="diff-row-' + a.id + '">' +
+			'<td class="mono">' + a.id + (a.cache_warning ? ' <span class="warning" title="' + escapeHTML(a.cache_warning) + '">⚠</span>' : '') + '</td>' +
+			'<td>' + formatDate(a.created_at) + '</td>' +
+			'<td>' + escapeHTML(a.model) + '</td>' +
+			'<td>' + (a.previous_id === null ? '-' : a.common_prefix + '/' + a.blocks.length + ' from #' + a.previous_id) + '</td>' +
+			'<td>' + changes + '</td>' +
+			'<td>' + (a.breakpoints.length ? a.breakpoints.join(', ') : '-') + '</td>' +
+			'<td>' + (u ? u.cache_read_tokens : '-') + '</td>' +
+			'<td>' + (u ? u.cache_write_tokens : '-') + '</td>' +
+			'<td>' + (u ? u.input_tokens : '-') + '</td>' +
+			'<td><button class="btn" onclick="toggleBlocks(' + a.id + ')">Blocks</button></td></tr>';
+		if (a.cache_warning || a.parse_error) {
+			html += '<tr><td></td><td colspan="9" class="warning">' + escapeHTML(a.cache_warning || a.parse_error) + '</td></tr>';
+		}
+	}
+	html += '</tbody></table>';
+	const view = document.getElementById('diff-view');
+	view.innerHTML = html;
+	view.analyses = analyses;
+}
+
+function toggleBlocks(id) {
+	const existing = document.getElementById('diff-blocks-' + id);
+	if (existing) {
+		existing.remove();
+		return;
+	}
+	const a = document.getElementById('diff-view').analyses.find(x => x.id === id);
+	const diffOps = {};
+	for (const c of a.changes) if (c.op !== 'removed') diffOps[c.index] = c.op;
+	let html = '<ul class="blocks">';
+	a.blocks.forEach((b, i) => {
+		const cls = [];
+		if (i <= a.cached_through) cls.push('cached');
+		if (diffOps[i]) cls.push('diff-' + diffOps[i]);
+		if (b.cache_breakpoint) cls.push('breakpoint');
+		html += '<li class="' + cls.join(' ') + '"><span class="mono">' + i + '</span> <span class="kind">' +
+			escapeHTML(b.role || b.kind) + '</span> <span class="size">~' + b.approx_tokens + ' tok</span> ' +
+			escapeHTML(b.summary) + (b.cache_breakpoint ? ' <span class="dedup-info">cache breakpoint</span>' : '') + '</li>';
+	});
+	for (const c of a.changes) {
+		if (c.op === 'removed') {
+			html += '<li class="removed">removed ' + escapeHTML(c.block.role || c.block.kind) + ' #' + c.index + ': ' + escapeHTML(c.block.summary) + '</li>';
+		}
+	}
+	html += '</ul><p class="size">Shaded blocks are estimated to have been read from the cache.</p>';
+	const tr = document.createElement('tr');
+	tr.id = 'diff-blocks-' + id;
+	tr.className = 'expand-row';
+	tr.innerHTML = '<td colspan="10"><div class="expand-content">' + html + '</div></td>';
+	document.getElementById('diff-row-' + id).after(tr);
+}
+
 loadRequests();
+const initialConversation = new URLSearchParams(location.search).get('conversation_id');
+if (initialConversation) loadDiff(initialConversation);
 </script>
 </body>
 </html>
@@ -117,6 +117,7 @@ func (s *Server) handleDistillConversation(w http.ResponseWriter, r *http.Reques
 		return
 	}
 	conversationID := conversation.ConversationID
+	s.carryOverTodos(ctx, req.SourceConversationID, conversationID)
 
 	// Notify conversation list subscribers
 	go s.publishConversationListUpdate(ConversationListUpdate{
@@ -170,7 +171,7 @@ func (s *Server) runDistillation(ctx context.Context, conversationID, sourceSlug
 	transcript := buildDistillTranscript(sourceSlug, messages)
 
 	// Get LLM service
-	svc, err := s.llmManager.GetService(modelID)
+	svc, err := s.serviceForTask(llm.TaskDistill, modelID)
 	if err != nil {
 		logger.Error("Failed to get LLM service for distillation", "model", modelID, "error", err)
 		s.insertDistillError(ctx, conversationID, fmt.Sprintf("Failed to get model %q: %v", modelID, err))
@@ -215,6 +216,7 @@ func (s *Server) runDistillation(ctx context.Context, conversationID, sourceSlug
 	}
 
 	logger.Info("Distillation complete", "output_length", len(distilledText))
+	distilledText += s.carriedTodosNote(ctx, conversationID)
 
 	// Update the status message to "complete"
 	s.updateDistillStatus(ctx, conversationID, "complete")
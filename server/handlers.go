@@ -537,6 +537,30 @@ func (s *Server) conversationMux() *http.ServeMux {
 	mux.HandleFunc("POST /{id}/rename", func(w http.ResponseWriter, r *http.Request) {
 		s.handleRenameConversation(w, r, r.PathValue("id"))
 	})
+	mux.HandleFunc("POST /{id}/thinking-level", func(w http.ResponseWriter, r *http.Request) {
+		s.handleSetThinkingLevel(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("POST /{id}/patch-diagnostics", func(w http.ResponseWriter, r *http.Request) {
+		s.handleSetPatchDiagnostics(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("POST /{id}/sandbox", func(w http.ResponseWriter, r *http.Request) {
+		s.handleSetSandbox(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("GET /{id}/checkpoints", func(w http.ResponseWriter, r *http.Request) {
+		s.handleListCheckpoints(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("POST /{id}/checkpoints/{message_id}/restore", func(w http.ResponseWriter, r *http.Request) {
+		s.handleRestoreCheckpoint(w, r, r.PathValue("id"), r.PathValue("message_id"))
+	})
+	mux.HandleFunc("GET /{id}/todos", func(w http.ResponseWriter, r *http.Request) {
+		s.handleListTodos(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("GET /{id}/jobs", func(w http.ResponseWriter, r *http.Request) {
+		s.handleListJobs(w, r, r.PathValue("id"))
+	})
+	mux.HandleFunc("POST /{id}/jobs/{job_id}/kill", func(w http.ResponseWriter, r *http.Request) {
+		s.handleKillJob(w, r, r.PathValue("id"), r.PathValue("job_id"))
+	})
 	mux.HandleFunc("GET /{id}/subagents", func(w http.ResponseWriter, r *http.Request) {
 		s.handleGetSubagents(w, r, r.PathValue("id"))
 	})
@@ -589,6 +613,22 @@ type ChatRequest struct {
 	Message string `json:"message"`
 	Model   string `json:"model,omitempty"`
 	Cwd     string `json:"cwd,omitempty"`
+	// ThinkingLevel (off, minimal, low, medium or high) applies to the turn
+	// answering this message; for a new conversation it becomes the
+	// conversation's level. Empty uses the conversation's level.
+	ThinkingLevel string `json:"thinking_level,omitempty"`
+}
+
+// parseThinkingLevel parses an optional thinking level; empty returns nil.
+func parseThinkingLevel(s string) (*llm.ThinkingLevel, error) {
+	if s == "" {
+		return nil, nil
+	}
+	level, err := llm.ParseThinkingLevel(s)
+	if err != nil {
+		return nil, err
+	}
+	return &level, nil
 }
 
 // handleChatConversation handles POST /conversation/<id>/chat
@@ -611,6 +651,11 @@ func (s *Server) handleChatConversation(w http.ResponseWriter, r *http.Request,
 		http.Error(w, "Message is required", http.StatusBadRequest)
 		return
 	}
+	thinking, err := parseThinkingLevel(req.ThinkingLevel)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	// Get LLM service for the requested model
 	modelID := req.Model
@@ -618,7 +663,7 @@ func (s *Server) handleChatConversation(w http.ResponseWriter, r *http.Request,
 		modelID = s.defaultModel
 	}
 
-	llmService, err := s.llmManager.GetService(modelID)
+	llmService, err := s.serviceForTask(llm.TaskMain, modelID)
 	if err != nil {
 		s.logger.Error("Unsupported model requested", "model", modelID, "error", err)
 		http.Error(w, fmt.Sprintf("Unsupported model: %s", modelID), http.StatusBadRequest)
@@ -645,7 +690,12 @@ func (s *Server) handleChatConversation(w http.ResponseWriter, r *http.Request,
 		},
 	}
 
-	firstMessage, err := manager.AcceptUserMessage(ctx, llmService, modelID, userMessage)
+	var firstMessage bool
+	if thinking != nil {
+		firstMessage, err = manager.AcceptUserMessageWithThinking(ctx, llmService, modelID, userMessage, *thinking)
+	} else {
+		firstMessage, err = manager.AcceptUserMessage(ctx, llmService, modelID, userMessage)
+	}
 	if errors.Is(err, errConversationModelMismatch) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
@@ -694,6 +744,10 @@ func (s *Server) handleNewConversation(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Message is required", http.StatusBadRequest)
 		return
 	}
+	if _, err := parseThinkingLevel(req.ThinkingLevel); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	// Get LLM service for the requested model
 	modelID := req.Model
@@ -702,7 +756,7 @@ func (s *Server) handleNewConversation(w http.ResponseWriter, r *http.Request) {
 		modelID = "qwen3-coder-fireworks"
 	}
 
-	llmService, err := s.llmManager.GetService(modelID)
+	llmService, err := s.serviceForTask(llm.TaskMain, modelID)
 	if err != nil {
 		s.logger.Error("Unsupported model requested", "model", modelID, "error", err)
 		http.Error(w, fmt.Sprintf("Unsupported model: %s", modelID), http.StatusBadRequest)
@@ -722,6 +776,15 @@ func (s *Server) handleNewConversation(w http.ResponseWriter, r *http.Request) {
 	}
 	conversationID := conversation.ConversationID
 
+	if req.ThinkingLevel != "" {
+		if err := s.db.UpdateConversationThinkingLevel(ctx, conversationID, req.ThinkingLevel); err != nil {
+			s.logger.Error("Failed to set conversation thinking level", "conversationID", conversationID, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		conversation.ThinkingLevel = &req.ThinkingLevel
+	}
+
 	// Notify conversation list subscribers about the new conversation
 	go s.publishConversationListUpdate(ConversationListUpdate{
 		Type:         "update",
@@ -857,6 +920,8 @@ func (s *Server) handleContinueConversation(w http.ResponseWriter, r *http.Reque
 		return
 	}
 	conversationID := conversation.ConversationID
+	s.carryOverTodos(ctx, req.SourceConversationID, conversationID)
+	summary += s.carriedTodosNote(ctx, conversationID)
 
 	// Notify conversation list subscribers about the new conversation
 	go s.publishConversationListUpdate(ConversationListUpdate{
@@ -1181,11 +1246,12 @@ func (s *Server) handleVersion(w http.ResponseWriter, r *http.Request) {
 
 // ModelInfo represents a model in the API response
 type ModelInfo struct {
-	ID               string `json:"id"`
-	DisplayName      string `json:"display_name,omitempty"`
-	Source           string `json:"source,omitempty"` // Human-readable source (e.g., "exe.dev gateway", "$ANTHROPIC_API_KEY")
-	Ready            bool   `json:"ready"`
-	MaxContextTokens int    `json:"max_context_tokens,omitempty"`
+	ID               string   `json:"id"`
+	DisplayName      string   `json:"display_name,omitempty"`
+	Source           string   `json:"source,omitempty"` // Human-readable source (e.g., "exe.dev gateway", "$ANTHROPIC_API_KEY")
+	Ready            bool     `json:"ready"`
+	MaxContextTokens int      `json:"max_context_tokens,omitempty"`
+	Fallbacks        []string `json:"fallbacks,omitempty"` // Models tried, in order, if this one is overloaded
 }
 
 // getModelList returns the list of available models
@@ -1210,6 +1276,7 @@ func (s *Server) getModelList() []ModelInfo {
 			if modelInfo := s.llmManager.GetModelInfo(id); modelInfo != nil {
 				info.DisplayName = modelInfo.DisplayName
 				info.Source = modelInfo.Source
+				info.Fallbacks = modelInfo.Fallbacks
 			}
 			modelList = append(modelList, info)
 		}
@@ -1418,6 +1485,98 @@ func (s *Server) handleRenameConversation(w http.ResponseWriter, r *http.Request
 	_ = json.NewEncoder(w).Encode(conversation) //nolint:errchkjson // best-effort HTTP response
 }
 
+// ThinkingLevelRequest represents a request to change a conversation's thinking level
+type ThinkingLevelRequest struct {
+	// ThinkingLevel is off, minimal, low, medium or high; empty uses the model's default
+	ThinkingLevel string `json:"thinking_level"`
+}
+
+// handleSetThinkingLevel handles POST /conversation/<id>/thinking-level
+func (s *Server) handleSetThinkingLevel(w http.ResponseWriter, r *http.Request, conversationID string) {
+	ctx := r.Context()
+
+	var req ThinkingLevelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	level, err := parseThinkingLevel(req.ThinkingLevel)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.db.UpdateConversationThinkingLevel(ctx, conversationID, req.ThinkingLevel); err != nil {
+		s.logger.Error("Failed to set conversation thinking level", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	conversation, err := s.db.GetConversationByID(ctx, conversationID)
+	if err != nil {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+
+	s.mu.Lock()
+	manager, exists := s.activeConversations[conversationID]
+	s.mu.Unlock()
+	if exists {
+		manager.SetThinkingLevel(level)
+	}
+
+	// Notify conversation list subscribers
+	go s.publishConversationListUpdate(ConversationListUpdate{
+		Type:         "update",
+		Conversation: conversation,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(conversation) //nolint:errchkjson // best-effort HTTP response
+}
+
+// PatchDiagnosticsRequest represents a request to turn patch diagnostics on or off
+type PatchDiagnosticsRequest struct {
+	Enabled bool `json:"enabled"`
+}
+
+// handleSetPatchDiagnostics handles POST /conversation/<id>/patch-diagnostics
+func (s *Server) handleSetPatchDiagnostics(w http.ResponseWriter, r *http.Request, conversationID string) {
+	ctx := r.Context()
+
+	var req PatchDiagnosticsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.db.UpdateConversationPatchDiagnostics(ctx, conversationID, req.Enabled); err != nil {
+		s.logger.Error("Failed to set conversation patch diagnostics", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	conversation, err := s.db.GetConversationByID(ctx, conversationID)
+	if err != nil {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+
+	s.mu.Lock()
+	manager, exists := s.activeConversations[conversationID]
+	s.mu.Unlock()
+	if exists {
+		manager.SetPatchDiagnostics(req.Enabled)
+	}
+
+	// Notify conversation list subscribers
+	go s.publishConversationListUpdate(ConversationListUpdate{
+		Type:         "update",
+		Conversation: conversation,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(conversation) //nolint:errchkjson // best-effort HTTP response
+}
+
 // handleVersionCheck returns version check information including update availability
 func (s *Server) handleVersionCheck(w http.ResponseWriter, r *http.Request) {
 	forceRefresh := r.URL.Query().Get("refresh") == "true"
@@ -1538,12 +1697,19 @@ func (s *Server) handleSetSetting(w http.ResponseWriter, r *http.Request) {
 
 	// Only allow known setting keys
 	allowedKeys := map[string]bool{
-		"auto_upgrade": true,
+		"auto_upgrade":          true,
+		models.RoutesSettingKey: true,
 	}
 	if !allowedKeys[req.Key] {
 		http.Error(w, fmt.Sprintf("Invalid setting key: %s", req.Key), http.StatusBadRequest)
 		return
 	}
+	if req.Key == models.RoutesSettingKey {
+		if _, err := models.ParseRoutes(req.Value); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
 
 	if err := s.db.SetSetting(r.Context(), req.Key, req.Value); err != nil {
 		s.logger.Error("Failed to set setting", "error", err, "key", req.Key)
@@ -1551,6 +1717,14 @@ func (s *Server) handleSetSetting(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.Key == models.RoutesSettingKey {
+		if rr, ok := s.llmManager.(interface{ RefreshRoutes() error }); ok {
+			if err := rr.RefreshRoutes(); err != nil {
+				s.logger.Warn("Failed to refresh model routes", "error", err)
+			}
+		}
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(map[string]string{"status": "ok"}) //nolint:errchkjson // best-effort HTTP response
 }
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/tgruben-circuit/percy/claudetool"
+)
+
+// conversationJobs returns the background jobs of an active conversation, or
+// nil if it has none.
+func (s *Server) conversationJobs(conversationID string) *claudetool.JobManager {
+	s.mu.Lock()
+	manager, exists := s.activeConversations[conversationID]
+	s.mu.Unlock()
+	if !exists {
+		return nil
+	}
+	return manager.Jobs()
+}
+
+// handleListJobs handles GET /conversation/<id>/jobs
+func (s *Server) handleListJobs(w http.ResponseWriter, r *http.Request, conversationID string) {
+	jobs := []claudetool.JobInfo{}
+	if manager := s.conversationJobs(conversationID); manager != nil {
+		jobs = manager.List()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(jobs) //nolint:errchkjson // best-effort HTTP response
+}
+
+// handleKillJob handles POST /conversation/<id>/jobs/<job_id>/kill
+func (s *Server) handleKillJob(w http.ResponseWriter, r *http.Request, conversationID, jobID string) {
+	manager := s.conversationJobs(conversationID)
+	if manager == nil {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+	job := manager.Get(jobID)
+	if job == nil {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+	job.Kill()
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(job.Info()) //nolint:errchkjson // best-effort HTTP response
+}
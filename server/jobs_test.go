@@ -0,0 +1,101 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tgruben-circuit/percy/claudetool"
+)
+
+func TestBackgroundJobsAPI(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	h.NewConversation("bash_background: echo started; sleep 60", t.TempDir())
+	result := h.WaitToolResult()
+	if !strings.Contains(result, "job-1") {
+		t.Fatalf("unexpected bash result: %q", result)
+	}
+	waitAgentIdle(t, h)
+
+	listJobs := func() []claudetool.JobInfo {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/api/conversation/"+h.convID+"/jobs", nil)
+		w := httptest.NewRecorder()
+		h.server.handleListJobs(w, req, h.convID)
+		if w.Code != http.StatusOK {
+			t.Fatalf("list jobs: status %d: %s", w.Code, w.Body.String())
+		}
+		var jobs []claudetool.JobInfo
+		if err := json.Unmarshal(w.Body.Bytes(), &jobs); err != nil {
+			t.Fatal(err)
+		}
+		return jobs
+	}
+
+	jobs := listJobs()
+	if len(jobs) != 1 || !jobs[0].Running || jobs[0].Command != "echo started; sleep 60" {
+		t.Fatalf("unexpected jobs: %+v", jobs)
+	}
+
+	req := httptest.NewRequest("POST", "/api/conversation/"+h.convID+"/jobs/job-1/kill", nil)
+	w := httptest.NewRecorder()
+	h.server.handleKillJob(w, req, h.convID, "job-1")
+	if w.Code != http.StatusOK {
+		t.Fatalf("kill job: status %d: %s", w.Code, w.Body.String())
+	}
+	jobs = listJobs()
+	if len(jobs) != 1 || jobs[0].Running || !jobs[0].Killed {
+		t.Fatalf("job not killed: %+v", jobs)
+	}
+
+	w = httptest.NewRecorder()
+	h.server.handleKillJob(w, req, h.convID, "job-2")
+	if w.Code != http.StatusNotFound {
+		t.Errorf("kill unknown job: status %d, want 404", w.Code)
+	}
+}
+
+func TestCleanupKeepsConversationsWithJobs(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	h.NewConversation("bash_background: sleep 60", t.TempDir())
+	h.WaitToolResult()
+	waitAgentIdle(t, h)
+
+	// backdate makes the conversation look idle and reports whether it is
+	// still active.
+	backdate := func() bool {
+		h.server.mu.Lock()
+		defer h.server.mu.Unlock()
+		manager, ok := h.server.activeConversations[h.convID]
+		if ok {
+			manager.mu.Lock()
+			manager.lastActivity = time.Now().Add(-time.Hour)
+			manager.mu.Unlock()
+		}
+		return ok
+	}
+
+	backdate()
+	h.server.Cleanup()
+	if !backdate() {
+		t.Fatal("conversation with a running job was cleaned up")
+	}
+
+	req := httptest.NewRequest("POST", "/api/conversation/"+h.convID+"/jobs/job-1/kill", nil)
+	w := httptest.NewRecorder()
+	h.server.handleKillJob(w, req, h.convID, "job-1")
+	if w.Code != http.StatusOK {
+		t.Fatalf("kill job: status %d: %s", w.Code, w.Body.String())
+	}
+	h.server.Cleanup()
+	if backdate() {
+		t.Error("idle conversation without jobs was not cleaned up")
+	}
+}
@@ -0,0 +1,416 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/tgruben-circuit/percy/db/generated"
+)
+
+// promptCacheTTL is how long providers keep a cached prompt prefix after
+// its last use. Misses after a longer gap are expected.
+const promptCacheTTL = 5 * time.Minute
+
+// LLMRequestBlock is one cacheable unit of a recorded request: a tool
+// definition, a system prompt block, or a message. Blocks are listed in
+// the order providers hash them for prompt caching.
+type LLMRequestBlock struct {
+	Kind            string `json:"kind"` // "tool", "system" or "message"
+	Role            string `json:"role,omitempty"`
+	Summary         string `json:"summary"`
+	ApproxTokens    int    `json:"approx_tokens"`
+	CacheBreakpoint bool   `json:"cache_breakpoint,omitempty"`
+
+	hash string // of the block without cache markers
+}
+
+// LLMBlockChange is a block added, removed or changed since the previous request.
+type LLMBlockChange struct {
+	Op    string          `json:"op"`    // "added", "removed" or "changed"
+	Index int             `json:"index"` // in the request that has the block; for changes, the new one
+	Block LLMRequestBlock `json:"block"`
+}
+
+// LLMCacheUsage is the prompt token usage a provider reported for a request.
+type LLMCacheUsage struct {
+	InputTokens      int64 `json:"input_tokens"` // not read from or written to the cache
+	CacheReadTokens  int64 `json:"cache_read_tokens"`
+	CacheWriteTokens int64 `json:"cache_write_tokens"`
+}
+
+// LLMRequestAnalysis describes one request of a conversation relative to
+// the previous request to the same model.
+type LLMRequestAnalysis struct {
+	ID         int64     `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	Model      string    `json:"model"`
+	Provider   string    `json:"provider"`
+	StatusCode *int64    `json:"status_code"`
+
+	Blocks      []LLMRequestBlock `json:"blocks"`
+	Breakpoints []int             `json:"breakpoints"` // indexes of blocks with cache markers
+	Usage       *LLMCacheUsage    `json:"usage"`
+	// CachedThrough estimates the last block covered by the cache read,
+	// from approximate block sizes; -1 if nothing was read from the cache.
+	CachedThrough int `json:"cached_through"`
+
+	PreviousID    *int64           `json:"previous_id"`
+	CommonPrefix  int              `json:"common_prefix"` // leading blocks unchanged since the previous request
+	Changes       []LLMBlockChange `json:"changes"`
+	SystemChanged bool             `json:"system_changed"`
+	ToolsChanged  bool             `json:"tools_changed"`
+
+	// CacheWarning explains why the cache was, or should have been,
+	// invalidated when that looks unintended.
+	CacheWarning string `json:"cache_warning,omitempty"`
+	ParseError   string `json:"parse_error,omitempty"`
+}
+
+// handleDebugLLMRequestDiff returns the analysis of each LLM request of a
+// conversation: what changed since the previous request and how the
+// prompt cache was used.
+func (s *Server) handleDebugLLMRequestDiff(w http.ResponseWriter, r *http.Request) {
+	conversationID := r.URL.Query().Get("conversation_id")
+	if conversationID == "" {
+		http.Error(w, "conversation_id is required", http.StatusBadRequest)
+		return
+	}
+	requests, err := s.db.ListConversationLLMRequests(r.Context(), conversationID)
+	if err != nil {
+		s.logger.Error("Failed to list conversation LLM requests", "error", err, "conversationID", conversationID)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(analyzeLLMRequests(requests)) //nolint:errchkjson // best-effort HTTP response
+}
+
+// analyzeLLMRequests analyzes requests, which must be in the order they were made.
+func analyzeLLMRequests(requests []generated.LlmRequest) []LLMRequestAnalysis {
+	result := make([]LLMRequestAnalysis, len(requests))
+	lastByModel := map[string]int{}
+	for i, req := range requests {
+		a := &result[i]
+		*a = LLMRequestAnalysis{
+			ID:            req.ID,
+			CreatedAt:     req.CreatedAt,
+			Model:         req.Model,
+			Provider:      req.Provider,
+			StatusCode:    req.StatusCode,
+			Breakpoints:   []int{},
+			CachedThrough: -1,
+			Changes:       []LLMBlockChange{},
+		}
+		if req.RequestBody != nil {
+			blocks, err := parseRequestBlocks(*req.RequestBody)
+			if err != nil {
+				a.ParseError = err.Error()
+			}
+			a.Blocks = blocks
+		}
+		if a.Blocks == nil {
+			a.Blocks = []LLMRequestBlock{}
+		}
+		for j, b := range a.Blocks {
+			if b.CacheBreakpoint {
+				a.Breakpoints = append(a.Breakpoints, j)
+			}
+		}
+		if req.ResponseBody != nil {
+			a.Usage = parseCacheUsage(*req.ResponseBody)
+		}
+		if a.Usage != nil && a.Usage.CacheReadTokens > 0 {
+			a.CachedThrough = cachedThrough(a.Blocks, a.Usage.CacheReadTokens)
+		}
+
+		// Side tasks (slugs, summaries) interleave with the main loop;
+		// compare against the same model's last request.
+		if j, ok := lastByModel[req.Model]; ok {
+			diffRequests(&result[j], a)
+		}
+		lastByModel[req.Model] = i
+	}
+	return result
+}
+
+// diffRequests fills in cur's changes relative to prev and checks whether
+// the prompt cache behaved as its breakpoints predict.
+func diffRequests(prev, cur *LLMRequestAnalysis) {
+	cur.PreviousID = &prev.ID
+
+	n := 0
+	for n < len(prev.Blocks) && n < len(cur.Blocks) && prev.Blocks[n].hash == cur.Blocks[n].hash {
+		n++
+	}
+	cur.CommonPrefix = n
+
+	// Blocks past the common prefix are paired up by position within
+	// their kind, so an edited system prompt shows up as one change
+	// rather than a removal and an addition.
+	prevRest := map[string][]int{}
+	for j := n; j < len(prev.Blocks); j++ {
+		prevRest[prev.Blocks[j].Kind] = append(prevRest[prev.Blocks[j].Kind], j)
+	}
+	for j := n; j < len(cur.Blocks); j++ {
+		b := cur.Blocks[j]
+		if olds := prevRest[b.Kind]; len(olds) > 0 {
+			prevRest[b.Kind] = olds[1:]
+			if prev.Blocks[olds[0]].hash != b.hash {
+				cur.Changes = append(cur.Changes, LLMBlockChange{Op: "changed", Index: j, Block: b})
+				cur.markChanged(b.Kind)
+			}
+			continue
+		}
+		cur.Changes = append(cur.Changes, LLMBlockChange{Op: "added", Index: j, Block: b})
+		cur.markChanged(b.Kind)
+	}
+	for _, kind := range []string{"tool", "system", "message"} {
+		for _, j := range prevRest[kind] {
+			cur.Changes = append(cur.Changes, LLMBlockChange{Op: "removed", Index: j, Block: prev.Blocks[j]})
+			cur.markChanged(kind)
+		}
+	}
+
+	if cur.CreatedAt.Sub(prev.CreatedAt) > promptCacheTTL || !succeeded(prev.StatusCode) || len(prev.Breakpoints) == 0 {
+		return
+	}
+	for _, bp := range prev.Breakpoints {
+		if bp >= n && n < len(cur.Blocks) {
+			cur.CacheWarning = fmt.Sprintf("%s changed at block %d, invalidating the cache breakpoint at block %d of request %d",
+				cur.Blocks[n].Kind, n, bp, prev.ID)
+			return
+		}
+	}
+	if cur.Usage != nil && cur.Usage.CacheReadTokens == 0 && len(cur.Breakpoints) > 0 {
+		last := prev.Breakpoints[len(prev.Breakpoints)-1]
+		cur.CacheWarning = fmt.Sprintf("no cache read although the prefix through block %d was cached by request %d and is unchanged", last, prev.ID)
+	}
+}
+
+func (a *LLMRequestAnalysis) markChanged(kind string) {
+	switch kind {
+	case "system":
+		a.SystemChanged = true
+	case "tool":
+		a.ToolsChanged = true
+	}
+}
+
+func succeeded(status *int64) bool {
+	return status != nil && *status >= 200 && *status < 300
+}
+
+// cachedThrough returns the index of the last block that fits within
+// tokens, by the blocks' approximate sizes.
+func cachedThrough(blocks []LLMRequestBlock, tokens int64) int {
+	last, total := -1, int64(0)
+	for i, b := range blocks {
+		total += int64(b.ApproxTokens)
+		if total > tokens {
+			break
+		}
+		last = i
+	}
+	return last
+}
+
+// recordedRequest holds the fields of the request formats we record that
+// matter for prompt caching: Anthropic Messages, OpenAI Chat Completions
+// and Responses, and Gemini.
+type recordedRequest struct {
+	Tools    []json.RawMessage `json:"tools"`
+	System   json.RawMessage   `json:"system"`
+	Messages []json.RawMessage `json:"messages"`
+
+	Instructions json.RawMessage `json:"instructions"`
+	Input        json.RawMessage `json:"input"`
+
+	SystemInstruction json.RawMessage   `json:"systemInstruction"`
+	Contents          []json.RawMessage `json:"contents"`
+}
+
+// parseRequestBlocks splits a recorded request body into its cacheable
+// blocks: tools, then system prompt, then messages.
+func parseRequestBlocks(body string) ([]LLMRequestBlock, error) {
+	var req recordedRequest
+	if err := json.Unmarshal([]byte(body), &req); err != nil {
+		return nil, fmt.Errorf("unrecognized request body: %w", err)
+	}
+
+	var blocks []LLMRequestBlock
+	for _, raw := range req.Tools {
+		var v any
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return nil, err
+		}
+		// Gemini groups its function declarations in one tool
+		if m, ok := v.(map[string]any); ok {
+			if decls, ok := m["functionDeclarations"].([]any); ok {
+				for _, d := range decls {
+					blocks = append(blocks, newRequestBlock("tool", "", d))
+				}
+				continue
+			}
+		}
+		blocks = append(blocks, newRequestBlock("tool", "", v))
+	}
+
+	for _, raw := range []json.RawMessage{req.System, req.Instructions, req.SystemInstruction} {
+		if len(raw) == 0 || string(raw) == "null" {
+			continue
+		}
+		var v any
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return nil, err
+		}
+		if parts, ok := v.([]any); ok {
+			for _, p := range parts {
+				blocks = append(blocks, newRequestBlock("system", "", p))
+			}
+		} else {
+			blocks = append(blocks, newRequestBlock("system", "", v))
+		}
+	}
+
+	messages := append(req.Messages, req.Contents...)
+	if len(req.Input) > 0 && req.Input[0] == '[' {
+		var input []json.RawMessage
+		if err := json.Unmarshal(req.Input, &input); err != nil {
+			return nil, err
+		}
+		messages = append(messages, input...)
+	}
+	for _, raw := range messages {
+		var v any
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return nil, err
+		}
+		m, _ := v.(map[string]any)
+		role, _ := m["role"].(string)
+		kind := "message"
+		if role == "system" || role == "developer" {
+			kind = "system"
+		}
+		blocks = append(blocks, newRequestBlock(kind, role, v))
+	}
+	return blocks, nil
+}
+
+func newRequestBlock(kind, role string, v any) LLMRequestBlock {
+	cached := stripCacheControl(v)
+	canonical, _ := json.Marshal(v)
+	sum := sha256.Sum256([]byte(kind + "\x00" + string(canonical)))
+	return LLMRequestBlock{
+		Kind:            kind,
+		Role:            role,
+		Summary:         blockSummary(v),
+		ApproxTokens:    (len(canonical) + 3) / 4,
+		CacheBreakpoint: cached,
+		hash:            hex.EncodeToString(sum[:8]),
+	}
+}
+
+// stripCacheControl removes cache markers from v in place, reporting
+// whether there were any. Moving a breakpoint doesn't change the prefix.
+func stripCacheControl(v any) bool {
+	found := false
+	switch v := v.(type) {
+	case map[string]any:
+		if _, ok := v["cache_control"]; ok {
+			delete(v, "cache_control")
+			found = true
+		}
+		for _, child := range v {
+			found = stripCacheControl(child) || found
+		}
+	case []any:
+		for _, child := range v {
+			found = stripCacheControl(child) || found
+		}
+	}
+	return found
+}
+
+// blockSummary returns a short human-readable description of a block.
+func blockSummary(v any) string {
+	if m, ok := v.(map[string]any); ok {
+		if name, ok := m["name"].(string); ok {
+			return name
+		}
+		if fn, ok := m["function"].(map[string]any); ok {
+			if name, ok := fn["name"].(string); ok {
+				return name
+			}
+		}
+	}
+	var texts []string
+	collectText(v, &texts)
+	return truncateUTF8(strings.Join(strings.Fields(strings.Join(texts, " ")), " "), 100)
+}
+
+// collectText gathers the text and tool names in a block, depth first.
+func collectText(v any, texts *[]string) {
+	switch v := v.(type) {
+	case string:
+		*texts = append(*texts, v)
+	case map[string]any:
+		for _, key := range []string{"text", "content", "parts", "name", "input", "tool_use_id"} {
+			if child, ok := v[key]; ok {
+				collectText(child, texts)
+			}
+		}
+	case []any:
+		for _, child := range v {
+			collectText(child, texts)
+		}
+	}
+}
+
+// parseCacheUsage extracts prompt token usage from a recorded response
+// body, or returns nil if it has none.
+func parseCacheUsage(body string) *LLMCacheUsage {
+	var resp struct {
+		Usage *struct {
+			// Anthropic
+			InputTokens              int64 `json:"input_tokens"`
+			CacheReadInputTokens     int64 `json:"cache_read_input_tokens"`
+			CacheCreationInputTokens int64 `json:"cache_creation_input_tokens"`
+			// OpenAI Chat Completions
+			PromptTokens        int64 `json:"prompt_tokens"`
+			PromptTokensDetails *struct {
+				CachedTokens int64 `json:"cached_tokens"`
+			} `json:"prompt_tokens_details"`
+			// OpenAI Responses
+			InputTokensDetails *struct {
+				CachedTokens int64 `json:"cached_tokens"`
+			} `json:"input_tokens_details"`
+		} `json:"usage"`
+		UsageMetadata *struct {
+			PromptTokenCount        int64 `json:"promptTokenCount"`
+			CachedContentTokenCount int64 `json:"cachedContentTokenCount"`
+		} `json:"usageMetadata"`
+	}
+	if err := json.Unmarshal([]byte(body), &resp); err != nil {
+		return nil
+	}
+	switch u := resp.Usage; {
+	case u != nil && u.PromptTokensDetails != nil:
+		return &LLMCacheUsage{InputTokens: u.PromptTokens - u.PromptTokensDetails.CachedTokens, CacheReadTokens: u.PromptTokensDetails.CachedTokens}
+	case u != nil && u.PromptTokens > 0:
+		return &LLMCacheUsage{InputTokens: u.PromptTokens}
+	case u != nil && u.InputTokensDetails != nil:
+		return &LLMCacheUsage{InputTokens: u.InputTokens - u.InputTokensDetails.CachedTokens, CacheReadTokens: u.InputTokensDetails.CachedTokens}
+	case u != nil:
+		return &LLMCacheUsage{InputTokens: u.InputTokens, CacheReadTokens: u.CacheReadInputTokens, CacheWriteTokens: u.CacheCreationInputTokens}
+	}
+	if m := resp.UsageMetadata; m != nil {
+		return &LLMCacheUsage{InputTokens: m.PromptTokenCount - m.CachedContentTokenCount, CacheReadTokens: m.CachedContentTokenCount}
+	}
+	return nil
+}
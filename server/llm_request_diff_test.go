@@ -0,0 +1,106 @@
+package server
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tgruben-circuit/percy/db/generated"
+)
+
+func recordedLLMRequest(id int64, at time.Time, model, body, response string) generated.LlmRequest {
+	status := int64(200)
+	return generated.LlmRequest{
+		ID: id, CreatedAt: at, Model: model, Provider: "anthropic", StatusCode: &status,
+		RequestBody: &body, ResponseBody: &response,
+	}
+}
+
+func TestAnalyzeLLMRequests(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	const (
+		tools  = `"tools":[{"name":"bash","input_schema":{}},{"name":"patch","input_schema":{},"cache_control":{"type":"ephemeral"}}]`
+		system = `"system":[{"type":"text","text":"You are Percy.","cache_control":{"type":"ephemeral"}}]`
+		first  = `{"role":"user","content":[{"type":"text","text":"hello"}]}`
+		reply  = `{"role":"assistant","content":[{"type":"text","text":"hi there"}]}`
+		cached = `,"cache_control":{"type":"ephemeral"}}]}`
+	)
+	requests := []generated.LlmRequest{
+		recordedLLMRequest(1, start, "claude", `{`+tools+`,`+system+`,"messages":[`+strings.TrimSuffix(first, "}]}")+cached+`]}`,
+			`{"usage":{"input_tokens":10,"cache_creation_input_tokens":500}}`),
+		// A slug request to another model doesn't break the chain
+		recordedLLMRequest(2, start.Add(time.Second), "haiku", `{"messages":[{"role":"user","content":"name this"}]}`,
+			`{"usage":{"input_tokens":5}}`),
+		// Normal turn: the breakpoint moves, new messages are appended
+		recordedLLMRequest(3, start.Add(time.Minute), "claude", `{`+tools+`,`+system+`,"messages":[`+first+`,`+reply+`,`+
+			`{"role":"user","content":[{"type":"text","text":"thanks"`+cached+`]}`,
+			`{"usage":{"input_tokens":4,"cache_read_input_tokens":500,"cache_creation_input_tokens":20}}`),
+		// The system prompt changed, invalidating everything after the tools
+		recordedLLMRequest(4, start.Add(2*time.Minute), "claude", `{`+tools+`,`+strings.Replace(system, "Percy", "Percy!", 1)+`,"messages":[`+first+`,`+reply+`,`+
+			`{"role":"user","content":[{"type":"text","text":"thanks"`+cached+`]}`,
+			`{"usage":{"input_tokens":4,"cache_read_input_tokens":100,"cache_creation_input_tokens":420}}`),
+		// Unchanged prefix but no cache read
+		recordedLLMRequest(5, start.Add(3*time.Minute), "claude", `{`+tools+`,`+strings.Replace(system, "Percy", "Percy!", 1)+`,"messages":[`+first+`,`+reply+`,`+
+			`{"role":"user","content":[{"type":"text","text":"thanks"}]},{"role":"assistant","content":"ok"},{"role":"user","content":[{"type":"text","text":"more"`+cached+`]}`,
+			`{"usage":{"input_tokens":600,"cache_read_input_tokens":0}}`),
+		// After the cache TTL a miss is expected
+		recordedLLMRequest(6, start.Add(time.Hour), "claude", `{`+tools+`,"messages":[`+first+cached+`]}`,
+			`{"usage":{"input_tokens":600}}`),
+	}
+
+	got := analyzeLLMRequests(requests)
+
+	a := got[0]
+	if a.PreviousID != nil || len(a.Blocks) != 4 || a.Blocks[0].Summary != "bash" || a.Blocks[2].Kind != "system" {
+		t.Fatalf("request 1 = %+v", a)
+	}
+	if want := []int{1, 2, 3}; !slices.Equal(a.Breakpoints, want) {
+		t.Errorf("breakpoints = %v, want %v", a.Breakpoints, want)
+	}
+
+	a = got[2]
+	if a.PreviousID == nil || *a.PreviousID != 1 || a.CommonPrefix != 4 || a.CacheWarning != "" {
+		t.Errorf("request 3 = %+v", a)
+	}
+	if len(a.Changes) != 2 || a.Changes[0].Op != "added" || a.Changes[0].Block.Role != "assistant" || a.SystemChanged {
+		t.Errorf("request 3 changes = %+v", a.Changes)
+	}
+	if a.Usage == nil || a.Usage.CacheReadTokens != 500 || a.CachedThrough != 5 {
+		t.Errorf("request 3 usage = %+v, cached through %d", a.Usage, a.CachedThrough)
+	}
+
+	a = got[3]
+	if !a.SystemChanged || a.ToolsChanged || a.CommonPrefix != 2 || len(a.Changes) != 1 || a.Changes[0].Op != "changed" {
+		t.Errorf("request 4 = %+v", a)
+	}
+	if !strings.Contains(a.CacheWarning, "system changed at block 2") {
+		t.Errorf("request 4 warning = %q", a.CacheWarning)
+	}
+
+	if a = got[4]; !strings.Contains(a.CacheWarning, "no cache read") {
+		t.Errorf("request 5 warning = %q", a.CacheWarning)
+	}
+	if a = got[5]; a.CacheWarning != "" || !a.SystemChanged {
+		t.Errorf("request 6 = %+v", a)
+	}
+}
+
+func TestParseCacheUsage(t *testing.T) {
+	tests := []struct {
+		body string
+		want LLMCacheUsage
+	}{
+		{`{"usage":{"prompt_tokens":100,"prompt_tokens_details":{"cached_tokens":60}}}`, LLMCacheUsage{InputTokens: 40, CacheReadTokens: 60}},
+		{`{"usage":{"input_tokens":100,"input_tokens_details":{"cached_tokens":30}}}`, LLMCacheUsage{InputTokens: 70, CacheReadTokens: 30}},
+		{`{"usageMetadata":{"promptTokenCount":50,"cachedContentTokenCount":20}}`, LLMCacheUsage{InputTokens: 30, CacheReadTokens: 20}},
+	}
+	for _, tt := range tests {
+		if got := parseCacheUsage(tt.body); got == nil || *got != tt.want {
+			t.Errorf("parseCacheUsage(%s) = %+v, want %+v", tt.body, got, tt.want)
+		}
+	}
+	if got := parseCacheUsage(`{"error":"nope"}`); got != nil {
+		t.Errorf("got %+v for a response without usage", got)
+	}
+}
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/tgruben-circuit/percy/db"
+)
+
+// llmRequestPruneInterval is how often the llm_requests table is pruned.
+const llmRequestPruneInterval = time.Hour
+
+// SetLLMRequestRetention sets the limits enforced on the llm_requests table.
+// A zero retention disables pruning.
+func (s *Server) SetLLMRequestRetention(r db.LLMRequestRetention) {
+	s.llmRequestRetention = r
+}
+
+// startLLMRequestPruner prunes recorded LLM requests at startup and then
+// periodically, if a retention policy is set.
+func (s *Server) startLLMRequestPruner() {
+	if s.db == nil || s.llmRequestRetention.IsZero() {
+		return
+	}
+	go func() {
+		s.pruneLLMRequests()
+		ticker := time.NewTicker(llmRequestPruneInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.pruneLLMRequests()
+			case <-s.shutdownCh:
+				return
+			}
+		}
+	}()
+	s.logger.Info("LLM request retention enabled",
+		"max_age", s.llmRequestRetention.MaxAge,
+		"max_rows", s.llmRequestRetention.MaxRows,
+		"max_bytes", s.llmRequestRetention.MaxBytes)
+}
+
+func (s *Server) pruneLLMRequests() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	n, err := s.db.PruneLLMRequests(ctx, s.llmRequestRetention)
+	if err != nil {
+		s.logger.Warn("Failed to prune LLM requests", "error", err)
+		return
+	}
+	if n > 0 {
+		s.logger.Info("Pruned LLM requests", "deleted", n)
+	}
+}
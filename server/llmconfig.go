@@ -3,7 +3,12 @@ package server
 import (
 	"log/slog"
 
+	"github.com/tgruben-circuit/percy/claudetool"
+	"github.com/tgruben-circuit/percy/claudetool/lsp"
+	"github.com/tgruben-circuit/percy/claudetool/sandbox"
 	"github.com/tgruben-circuit/percy/db"
+	"github.com/tgruben-circuit/percy/llm"
+	"github.com/tgruben-circuit/percy/models"
 )
 
 // Link represents a custom link to be displayed in the UI
@@ -37,6 +42,43 @@ type LLMConfig struct {
 	// Each entry is a map with at least a "type" key, plus channel-specific fields.
 	NotificationChannels []map[string]any
 
+	// ModelFallbacks maps a model ID to the model IDs to fail over to, in order (optional)
+	ModelFallbacks map[string][]string
+
+	// RateLimits maps a model ID or provider name to its request limits (optional)
+	RateLimits map[string]models.RateLimit
+
+	// ModelRoutes maps a task class to the model IDs to use for it, in order (optional)
+	ModelRoutes map[llm.Task][]string
+
+	// LSPServers adds to or overrides the built-in LSP servers (optional)
+	LSPServers []lsp.ServerConfig
+
+	// LSPTrustRepoConfig lets a repository's .percy/lsp.json choose server
+	// commands (optional)
+	LSPTrustRepoConfig bool
+
+	// Sandbox configures the sandbox for bash commands (optional)
+	Sandbox *sandbox.Config
+
+	// WebFetch restricts the domains the web_fetch tool may fetch (optional)
+	WebFetch *claudetool.WebFetchConfig
+
+	// MemoryBatch runs memory indexing through provider batch APIs where available
+	MemoryBatch bool
+
+	// LLMReplayPath is a cassette file to serve LLM responses from instead of the network (optional)
+	LLMReplayPath string
+
+	// LLMRequestRetention bounds the llm_requests table; zero fields are unlimited
+	LLMRequestRetention db.LLMRequestRetention
+
+	// RecordUnredactedLLMRequests disables masking of secrets in recorded LLM requests
+	RecordUnredactedLLMRequests bool
+
+	// RecordFullLLMRequestBodies stores each request body in full instead of as a delta
+	RecordFullLLMRequestBodies bool
+
 	// DB is the database for recording LLM requests (optional)
 	DB *db.DB
 
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/tgruben-circuit/percy/memory"
+)
+
+// handleMemoryExport streams the memory database as JSONL.
+// Query parameters: embeddings=true includes vectors, superseded=true
+// includes superseded cells.
+func (s *Server) handleMemoryExport(w http.ResponseWriter, r *http.Request) {
+	if s.memoryDB == nil {
+		http.Error(w, "memory database not available", http.StatusNotFound)
+		return
+	}
+
+	opts := memory.ExportOptions{
+		IncludeEmbeddings: r.URL.Query().Get("embeddings") == "true",
+		IncludeSuperseded: r.URL.Query().Get("superseded") == "true",
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", `attachment; filename="percy-memory.jsonl"`)
+	if err := s.memoryDB.Export(r.Context(), w, opts); err != nil {
+		// Headers are already sent; the truncated stream is the only signal.
+		s.logger.Warn("Memory export failed", "error", err)
+	}
+}
+
+// handleMemoryImport merges a JSONL export from the request body and returns
+// an import report.
+func (s *Server) handleMemoryImport(w http.ResponseWriter, r *http.Request) {
+	if s.memoryDB == nil {
+		http.Error(w, "memory database not available", http.StatusNotFound)
+		return
+	}
+
+	report, err := s.memoryDB.Import(r.Context(), r.Body, s.embedder)
+	if err != nil {
+		http.Error(w, "memory import failed: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(report) //nolint:errchkjson // best-effort HTTP response
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tgruben-circuit/percy/claudetool"
+	"github.com/tgruben-circuit/percy/loop"
+	"github.com/tgruben-circuit/percy/memory"
+)
+
+func TestHandleMemoryExportImport(t *testing.T) {
+	database, cleanup := setupTestDB(t)
+	defer cleanup()
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelWarn}))
+
+	newMux := func(mdb *memory.DB) *http.ServeMux {
+		srv := NewServer(database, &testLLMManager{service: loop.NewPredictableService()}, claudetool.ToolSetConfig{}, logger, true, "", "predictable", "", nil)
+		srv.SetMemoryDB(mdb)
+		mux := http.NewServeMux()
+		srv.RegisterRoutes(mux)
+		return mux
+	}
+
+	src, err := memory.Open(filepath.Join(t.TempDir(), "memory.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	if err := src.InsertCell(memory.Cell{CellID: "c1", SourceType: "conversation", SourceID: "conv-1", SourceName: "setup",
+		CellType: "fact", Salience: 0.7, Content: "Percy listens on port 9000"}); err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	newMux(src).ServeHTTP(w, httptest.NewRequest("GET", "/api/memory/export", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("export: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	exported := w.Body.Bytes()
+
+	dst, err := memory.Open(filepath.Join(t.TempDir(), "memory.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+
+	w = httptest.NewRecorder()
+	newMux(dst).ServeHTTP(w, httptest.NewRequest("POST", "/api/memory/import", bytes.NewReader(exported)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("import: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var report memory.ImportReport
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.CellsImported != 1 {
+		t.Fatalf("expected 1 imported cell, got %+v", report)
+	}
+
+	w = httptest.NewRecorder()
+	newMux(dst).ServeHTTP(w, httptest.NewRequest("POST", "/api/memory/import", bytes.NewReader([]byte("not json\n"))))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for malformed import, got %d", w.Code)
+	}
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/tgruben-circuit/percy/llm"
+	"github.com/tgruben-circuit/percy/llm/llmhttp"
+	"github.com/tgruben-circuit/percy/memory"
+)
+
+// SetMemoryMaintenanceInterval sets how often the background memory
+// maintenance job runs. Zero or negative disables the scheduled job; the
+// HTTP endpoint still works.
+func (s *Server) SetMemoryMaintenanceInterval(d time.Duration) {
+	s.memoryMaintenanceInterval = d
+}
+
+// startMemoryMaintenance starts the periodic memory maintenance job if a
+// memory database is configured and an interval is set.
+func (s *Server) startMemoryMaintenance() {
+	if s.memoryDB == nil || s.memoryMaintenanceInterval <= 0 {
+		return
+	}
+	interval := s.memoryMaintenanceInterval
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.runMemoryMaintenance(context.Background(), false)
+			case <-s.shutdownCh:
+				return
+			}
+		}
+	}()
+	s.logger.Info("Memory maintenance scheduled", "interval", interval)
+}
+
+// runMemoryMaintenance runs one maintenance pass. Passes are serialized,
+// including topic consolidation, which uses the server's default model.
+func (s *Server) runMemoryMaintenance(ctx context.Context, dryRun bool) (*memory.MaintenanceReport, error) {
+	ctx, cancel := context.WithTimeout(llmhttp.WithPriority(ctx, llmhttp.PriorityBackground), 10*time.Minute)
+	defer cancel()
+
+	var llmSvc llm.Service
+	if !dryRun && s.defaultModel != "" {
+		llmSvc, _ = s.serviceForTask(llm.TaskConsolidate, s.defaultModel) // best-effort
+	}
+
+	report, err := memory.RunMaintenance(ctx, s.memoryDB, llmSvc, s.embedder, memory.MaintenanceOptions{
+		DryRun: dryRun,
+		Lock:   &s.memoryMaintenanceMu,
+	})
+	if err != nil {
+		s.logger.Warn("Memory maintenance failed", "dry_run", dryRun, "error", err)
+		return nil, err
+	}
+	if !dryRun {
+		s.logger.Info("Memory maintenance completed",
+			"duplicates", len(report.Duplicates),
+			"superseded", len(report.Superseded),
+			"decayed", len(report.Decayed),
+			"consolidated", len(report.Consolidated))
+	}
+	return report, nil
+}
+
+// handleMemoryMaintenance reports what memory maintenance would change (GET)
+// or runs it immediately (POST).
+func (s *Server) handleMemoryMaintenance(w http.ResponseWriter, r *http.Request) {
+	if s.memoryDB == nil {
+		http.Error(w, "memory database not available", http.StatusNotFound)
+		return
+	}
+
+	var dryRun bool
+	switch r.Method {
+	case http.MethodGet:
+		dryRun = true
+	case http.MethodPost:
+		dryRun = r.URL.Query().Get("dry_run") == "true"
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	report, err := s.runMemoryMaintenance(r.Context(), dryRun)
+	if err != nil {
+		http.Error(w, "memory maintenance failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(report) //nolint:errchkjson // best-effort HTTP response
+}
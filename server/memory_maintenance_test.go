@@ -0,0 +1,71 @@
+package server
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tgruben-circuit/percy/claudetool"
+	"github.com/tgruben-circuit/percy/loop"
+	"github.com/tgruben-circuit/percy/memory"
+)
+
+func TestHandleMemoryMaintenance(t *testing.T) {
+	database, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	mdb, err := memory.Open(filepath.Join(t.TempDir(), "memory.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mdb.Close()
+
+	for _, c := range []memory.Cell{
+		{CellID: "a", SourceType: "conversation", SourceID: "c1", CellType: "fact", Salience: 0.8, Content: "Percy stores data in SQLite"},
+		{CellID: "b", SourceType: "conversation", SourceID: "c2", CellType: "fact", Salience: 0.5, Content: "percy stores data in sqlite"},
+	} {
+		if err := mdb.InsertCell(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelWarn}))
+	srv := NewServer(database, &testLLMManager{service: loop.NewPredictableService()}, claudetool.ToolSetConfig{}, logger, true, "", "predictable", "", nil)
+	srv.SetMemoryDB(mdb)
+
+	mux := http.NewServeMux()
+	srv.RegisterRoutes(mux)
+
+	// GET is a dry run.
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/api/memory/maintenance", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var report memory.MaintenanceReport
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if !report.DryRun || len(report.Duplicates) != 1 || report.Duplicates[0].CellID != "b" {
+		t.Fatalf("unexpected dry-run report: %+v", report)
+	}
+
+	// POST applies the changes.
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("POST", "/api/memory/maintenance", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("POST: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var superseded bool
+	if err := mdb.QueryRow(`SELECT superseded FROM cells WHERE cell_id = 'b'`).Scan(&superseded); err != nil {
+		t.Fatal(err)
+	}
+	if !superseded {
+		t.Error("expected duplicate cell to be superseded after POST")
+	}
+}
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tgruben-circuit/percy/llm"
+)
+
+func TestConversationUsesMainRoute(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	newConversation := func() *httptest.ResponseRecorder {
+		t.Helper()
+		body, err := json.Marshal(ChatRequest{Message: "hello", Model: "retired-model"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := httptest.NewRequest("POST", "/api/conversations/new", strings.NewReader(string(body)))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		h.server.handleNewConversation(w, req)
+		return w
+	}
+
+	// Without a route an unavailable model is rejected.
+	h.server.llmManager = NewLLMServiceManager(&LLMConfig{Logger: h.server.logger})
+	if w := newConversation(); w.Code != http.StatusBadRequest {
+		t.Fatalf("unrouted: status %d, want 400: %s", w.Code, w.Body.String())
+	}
+
+	// The main route supplies a fallback for it.
+	h.server.llmManager = NewLLMServiceManager(&LLMConfig{
+		Logger:      h.server.logger,
+		ModelRoutes: map[llm.Task][]string{llm.TaskMain: {"predictable"}},
+	})
+	w := newConversation()
+	if w.Code != http.StatusCreated {
+		t.Fatalf("routed: status %d: %s", w.Code, w.Body.String())
+	}
+	var created struct {
+		ConversationID string `json:"conversation_id"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	h.convID = created.ConversationID
+	if resp := h.WaitResponse(); resp == "" {
+		t.Error("expected a response from the routed model")
+	}
+}
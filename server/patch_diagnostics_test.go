@@ -0,0 +1,53 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPatchDiagnostics(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	h.NewConversation("hello", "")
+	h.WaitResponse()
+
+	conv, err := h.db.GetConversationByID(context.Background(), h.convID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !conv.PatchDiagnostics {
+		t.Error("patch diagnostics should be on by default")
+	}
+
+	req := httptest.NewRequest("POST", "/api/conversation/"+h.convID+"/patch-diagnostics", strings.NewReader(`{"enabled":false}`))
+	w := httptest.NewRecorder()
+	h.server.handleSetPatchDiagnostics(w, req, h.convID)
+	if w.Code != http.StatusOK {
+		t.Fatalf("set patch diagnostics: status %d: %s", w.Code, w.Body.String())
+	}
+
+	conv, err = h.db.GetConversationByID(context.Background(), h.convID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conv.PatchDiagnostics {
+		t.Error("stored patch diagnostics = true, want false")
+	}
+
+	h.server.mu.Lock()
+	manager := h.server.activeConversations[h.convID]
+	h.server.mu.Unlock()
+	if manager == nil {
+		t.Fatal("conversation is not active")
+	}
+	manager.mu.Lock()
+	enabled := manager.patchDiagnostics
+	manager.mu.Unlock()
+	if enabled {
+		t.Error("active conversation still reports patch diagnostics")
+	}
+}
@@ -0,0 +1,119 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/tgruben-circuit/percy/claudetool/sandbox"
+	"github.com/tgruben-circuit/percy/db"
+	"github.com/tgruben-circuit/percy/db/generated"
+)
+
+var errSandboxRequired = errors.New("this conversation must run commands in the sandbox")
+
+// conversationSandboxRequired reports whether conv must run bash commands in
+// the sandbox. Cluster tasks, the only conversations without a parent that
+// the user did not start, always do, and subagents of sandboxed
+// conversations inherit the sandbox.
+func conversationSandboxRequired(ctx context.Context, database *db.DB, conv *generated.Conversation) (bool, error) {
+	if conv.ParentConversationID == nil {
+		return !conv.UserInitiated, nil
+	}
+	parent, err := database.GetConversationByID(ctx, *conv.ParentConversationID)
+	if err != nil {
+		return false, fmt.Errorf("failed to get parent conversation: %w", err)
+	}
+	return parent.Sandbox, nil
+}
+
+// setConversationSandbox turns the sandbox on or off for a conversation and
+// its active manager. Turning it on also turns it on for the conversation's
+// subagents.
+func (s *Server) setConversationSandbox(ctx context.Context, conversationID string, enabled bool) error {
+	if err := s.db.UpdateConversationSandbox(ctx, conversationID, enabled); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	manager, exists := s.activeConversations[conversationID]
+	s.mu.Unlock()
+	if exists {
+		if err := manager.SetSandbox(enabled); err != nil {
+			return err
+		}
+	}
+	if !enabled {
+		return nil
+	}
+	subagents, err := s.db.GetSubagents(ctx, conversationID)
+	if err != nil {
+		return err
+	}
+	for _, sub := range subagents {
+		if err := s.setConversationSandbox(ctx, sub.ConversationID, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SandboxRequest represents a request to turn the sandbox on or off
+type SandboxRequest struct {
+	Enabled bool `json:"enabled"`
+}
+
+// handleSetSandbox handles POST /conversation/<id>/sandbox
+func (s *Server) handleSetSandbox(w http.ResponseWriter, r *http.Request, conversationID string) {
+	ctx := r.Context()
+
+	var req SandboxRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	conversation, err := s.db.GetConversationByID(ctx, conversationID)
+	if err != nil {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+	if req.Enabled {
+		if err := sandbox.Available(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	} else {
+		required, err := conversationSandboxRequired(ctx, s.db, conversation)
+		if err != nil {
+			s.logger.Error("Failed to check sandbox requirement", "conversationID", conversationID, "error", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		if required {
+			http.Error(w, errSandboxRequired.Error(), http.StatusConflict)
+			return
+		}
+	}
+
+	if err := s.setConversationSandbox(ctx, conversationID, req.Enabled); err != nil {
+		s.logger.Error("Failed to set conversation sandbox", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	conversation, err = s.db.GetConversationByID(ctx, conversationID)
+	if err != nil {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+
+	// Notify conversation list subscribers
+	go s.publishConversationListUpdate(ConversationListUpdate{
+		Type:         "update",
+		Conversation: conversation,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(conversation) //nolint:errchkjson // best-effort HTTP response
+}
@@ -0,0 +1,138 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tgruben-circuit/percy/claudetool/sandbox"
+)
+
+func setSandbox(t *testing.T, h *TestHarness, conversationID string, enabled bool) *httptest.ResponseRecorder {
+	t.Helper()
+	body := `{"enabled":false}`
+	if enabled {
+		body = `{"enabled":true}`
+	}
+	req := httptest.NewRequest("POST", "/api/conversation/"+conversationID+"/sandbox", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	h.server.handleSetSandbox(w, req, conversationID)
+	return w
+}
+
+func TestSandboxToggle(t *testing.T) {
+	if err := sandbox.Available(); err != nil {
+		t.Skip(err)
+	}
+	outside := t.TempDir()
+	cwd := t.TempDir()
+	// The temporary directory is writable in the sandbox; move it away
+	// from outside.
+	t.Setenv("TMPDIR", t.TempDir())
+
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	h.NewConversation("hello", cwd)
+	h.WaitResponse()
+
+	conv, err := h.db.GetConversationByID(context.Background(), h.convID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conv.Sandbox {
+		t.Error("sandbox should be off by default")
+	}
+
+	if w := setSandbox(t, h, h.convID, true); w.Code != http.StatusOK {
+		t.Fatalf("enable sandbox: status %d: %s", w.Code, w.Body.String())
+	}
+	conv, err = h.db.GetConversationByID(context.Background(), h.convID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !conv.Sandbox {
+		t.Error("stored sandbox = false, want true")
+	}
+
+	h.Chat("bash: touch inside.txt " + filepath.Join(outside, "outside.txt"))
+	result := h.WaitToolResult()
+	if !strings.Contains(result, "Read-only file system") || strings.Contains(result, "inside.txt") {
+		t.Errorf("unexpected bash result: %q", result)
+	}
+	h.WaitResponse()
+
+	if w := setSandbox(t, h, h.convID, false); w.Code != http.StatusOK {
+		t.Fatalf("disable sandbox: status %d: %s", w.Code, w.Body.String())
+	}
+	h.Chat("bash: touch " + filepath.Join(outside, "outside.txt"))
+	h.WaitResponse()
+	if _, err := os.Stat(filepath.Join(outside, "outside.txt")); err != nil {
+		t.Errorf("command still sandboxed: %v", err)
+	}
+}
+
+func TestSandboxRequired(t *testing.T) {
+	if err := sandbox.Available(); err != nil {
+		t.Skip(err)
+	}
+	h := NewTestHarness(t)
+	defer h.Close()
+	ctx := context.Background()
+	cwd := t.TempDir()
+
+	// Cluster tasks are the conversations without a parent that the user
+	// did not start.
+	slug := "task-1"
+	task, err := h.db.CreateConversation(ctx, &slug, false, &cwd, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := setSandbox(t, h, task.ConversationID, false); w.Code != http.StatusConflict {
+		t.Errorf("disable cluster task sandbox: status %d, want 409", w.Code)
+	}
+	manager, err := h.server.getOrCreateConversationManager(ctx, task.ConversationID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.SetSandbox(false); err == nil {
+		t.Error("manager turned off a required sandbox")
+	}
+	task, err = h.db.GetConversationByID(ctx, task.ConversationID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !task.Sandbox {
+		t.Error("cluster task sandbox was not stored")
+	}
+
+	parent, err := h.db.CreateConversation(ctx, nil, true, &cwd, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub, err := h.db.CreateSubagentConversation(ctx, "helper", parent.ConversationID, &cwd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := setSandbox(t, h, sub.ConversationID, false); w.Code != http.StatusOK {
+		t.Errorf("disable subagent sandbox of unsandboxed parent: status %d", w.Code)
+	}
+
+	if w := setSandbox(t, h, parent.ConversationID, true); w.Code != http.StatusOK {
+		t.Fatalf("enable sandbox: status %d: %s", w.Code, w.Body.String())
+	}
+	sub, err = h.db.GetConversationByID(ctx, sub.ConversationID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sub.Sandbox {
+		t.Error("subagent did not inherit the sandbox")
+	}
+	if w := setSandbox(t, h, sub.ConversationID, false); w.Code != http.StatusConflict {
+		t.Errorf("disable subagent sandbox of sandboxed parent: status %d, want 409", w.Code)
+	}
+}
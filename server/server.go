@@ -11,6 +11,7 @@ import (
 	"os/exec"
 	"os/signal"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
@@ -24,6 +25,7 @@ import (
 	"github.com/tgruben-circuit/percy/db"
 	"github.com/tgruben-circuit/percy/db/generated"
 	"github.com/tgruben-circuit/percy/llm"
+	"github.com/tgruben-circuit/percy/llm/llmhttp"
 	"github.com/tgruben-circuit/percy/memory"
 	"github.com/tgruben-circuit/percy/models"
 	"github.com/tgruben-circuit/percy/server/notifications"
@@ -86,13 +88,18 @@ type LLMProvider interface {
 func NewLLMServiceManager(cfg *LLMConfig) LLMProvider {
 	// Convert LLMConfig to models.Config
 	modelConfig := &models.Config{
-		AnthropicAPIKey: cfg.AnthropicAPIKey,
-		OpenAIAPIKey:    cfg.OpenAIAPIKey,
-		GeminiAPIKey:    cfg.GeminiAPIKey,
-		FireworksAPIKey: cfg.FireworksAPIKey,
-		Gateway:         cfg.Gateway,
-		Logger:          cfg.Logger,
-		DB:              cfg.DB,
+		AnthropicAPIKey:  cfg.AnthropicAPIKey,
+		OpenAIAPIKey:     cfg.OpenAIAPIKey,
+		GeminiAPIKey:     cfg.GeminiAPIKey,
+		FireworksAPIKey:  cfg.FireworksAPIKey,
+		Gateway:          cfg.Gateway,
+		Logger:           cfg.Logger,
+		DB:               cfg.DB,
+		Fallbacks:        cfg.ModelFallbacks,
+		ReplayPath:       cfg.LLMReplayPath,
+		RecordUnredacted: cfg.RecordUnredactedLLMRequests,
+		RateLimits:       cfg.RateLimits,
+		Routes:           cfg.ModelRoutes,
 	}
 
 	manager, err := models.NewManager(modelConfig)
@@ -104,6 +111,26 @@ func NewLLMServiceManager(cfg *LLMConfig) LLMProvider {
 	return manager
 }
 
+// serviceForTask returns the service the routing policy picks for task,
+// falling back to modelID on providers without routing.
+func (s *Server) serviceForTask(task llm.Task, modelID string) (llm.Service, error) {
+	if r, ok := s.llmManager.(models.TaskRouter); ok {
+		return r.ServiceForTask(task, modelID)
+	}
+	return s.llmManager.GetService(modelID)
+}
+
+// batchServiceForTask is like serviceForTask, but prefers the provider's
+// batch API when the manager supports it.
+func (s *Server) batchServiceForTask(task llm.Task, modelID string) (llm.Service, error) {
+	if r, ok := s.llmManager.(interface {
+		BatchServiceForTask(llm.Task, string) (llm.Service, error)
+	}); ok {
+		return r.BatchServiceForTask(task, modelID)
+	}
+	return s.serviceForTask(task, modelID)
+}
+
 // toAPIMessages converts database messages to API messages.
 // When display_data is present (tool results), llm_data is omitted to save bandwidth
 // since the display_data contains all information needed for UI rendering.
@@ -236,6 +263,12 @@ type Server struct {
 	clusterNode         *cluster.Node
 	shutdownCh          chan struct{} // Signals background routines to stop
 	indexQueue          chan string   // Buffered queue for conversation IDs to index
+	memoryBatch         bool          // Index through provider batch APIs
+
+	memoryMaintenanceInterval time.Duration
+	memoryMaintenanceMu       sync.Mutex // Serializes memory maintenance passes
+
+	llmRequestRetention db.LLMRequestRetention
 }
 
 // NewServer creates a new server instance
@@ -295,34 +328,100 @@ func (s *Server) EnqueueIndex(conversationID string) {
 	}
 }
 
+// SetMemoryBatch makes memory indexing submit its LLM requests through the
+// provider's batch API when the extraction model supports one. Batches are
+// cheaper but can take hours, so indexing lags accordingly.
+func (s *Server) SetMemoryBatch(enabled bool) {
+	s.memoryBatch = enabled
+}
+
 // indexWorker processes the indexQueue sequentially until shutdownCh is closed,
 // then drains any remaining items.
+//
+// In batch mode it instead indexes everything queued at once, so that the
+// extraction requests are submitted together as one batch. A batch runs in
+// the background; conversations queued meanwhile wait for the next one.
+// Pending batches are abandoned at shutdown.
 func (s *Server) indexWorker() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var (
+		queued    []string      // waiting for the running batch to finish
+		batchDone chan struct{} // closed when the running batch finishes; nil if none
+	)
 	for {
 		select {
 		case convID := <-s.indexQueue:
-			s.indexConversation(convID)
+			if !s.memoryBatch {
+				s.indexConversation(ctx, convID, false)
+				continue
+			}
+			queued = append(queued, convID)
+		case <-batchDone:
+			batchDone = nil
 		case <-s.shutdownCh:
-			// Drain remaining items
-			for {
-				select {
-				case convID := <-s.indexQueue:
-					s.indexConversation(convID)
-				default:
-					return
-				}
+			cancel()
+			// Drain remaining items without waiting on a batch
+			for _, convID := range append(queued, s.drainIndexQueue()...) {
+				s.indexConversation(context.Background(), convID, false)
 			}
+			return
+		}
+
+		if len(queued) > 0 && batchDone == nil {
+			ids := append(queued, s.drainIndexQueue()...)
+			queued = nil
+			done := make(chan struct{})
+			batchDone = done
+			go func() {
+				defer close(done)
+				s.indexBatch(ctx, ids)
+			}()
+		}
+	}
+}
+
+// drainIndexQueue removes and returns the conversation IDs currently queued.
+func (s *Server) drainIndexQueue() []string {
+	var ids []string
+	for {
+		select {
+		case convID := <-s.indexQueue:
+			ids = append(ids, convID)
+		default:
+			return ids
 		}
 	}
 }
 
+// indexBatch indexes conversations concurrently through the batch API.
+func (s *Server) indexBatch(ctx context.Context, conversationIDs []string) {
+	var wg sync.WaitGroup
+	slices.Sort(conversationIDs)
+	for _, convID := range slices.Compact(conversationIDs) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.indexConversation(ctx, convID, true)
+		}()
+	}
+	wg.Wait()
+}
+
 // indexConversation indexes a conversation's messages into the memory database.
-func (s *Server) indexConversation(conversationID string) {
+// If batch is set, LLM requests go through the provider's batch API when it
+// has one, and may take up to a day to complete.
+func (s *Server) indexConversation(ctx context.Context, conversationID string, batch bool) {
 	if s.memoryDB == nil {
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	timeout := 60 * time.Second
+	if batch {
+		timeout = 24 * time.Hour
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
 	conv, err := s.db.GetConversationByID(ctx, conversationID)
@@ -382,9 +481,14 @@ func (s *Server) indexConversation(conversationID string) {
 		modelID = *conv.Model
 	}
 	if modelID != "" {
-		llmSvc, _ = s.llmManager.GetService(modelID) // best-effort
+		if batch {
+			llmSvc, _ = s.batchServiceForTask(llm.TaskMemoryExtract, modelID) // best-effort
+		} else {
+			llmSvc, _ = s.serviceForTask(llm.TaskMemoryExtract, modelID) // best-effort
+		}
 	}
 
+	ctx = llmhttp.WithPriority(ctx, llmhttp.PriorityBackground)
 	if err := s.memoryDB.IndexConversation(ctx, conversationID, slug, messages, s.embedder, llmSvc); err != nil {
 		s.logger.Warn("Memory index: failed to index conversation", "conversationID", conversationID, "error", err)
 		return
@@ -433,6 +537,11 @@ func (s *Server) RegisterRoutes(mux *http.ServeMux) {
 	// Cluster API
 	mux.Handle("GET /api/cluster/status", http.HandlerFunc(s.handleClusterStatus))
 
+	// Memory API (maintenance: GET = dry-run report, POST = run now)
+	mux.Handle("/api/memory/maintenance", http.HandlerFunc(s.handleMemoryMaintenance))
+	mux.Handle("GET /api/memory/export", gzipHandler(http.HandlerFunc(s.handleMemoryExport)))
+	mux.Handle("POST /api/memory/import", http.HandlerFunc(s.handleMemoryImport))
+
 	// Models API (dynamic list refresh)
 	mux.Handle("/api/models", http.HandlerFunc(s.handleModels))
 
@@ -452,9 +561,12 @@ func (s *Server) RegisterRoutes(mux *http.ServeMux) {
 	mux.Handle("GET /debug/conversations", http.HandlerFunc(s.handleDebugConversationsPage))
 	mux.Handle("GET /debug/llm_requests", http.HandlerFunc(s.handleDebugLLMRequests))
 	mux.Handle("GET /debug/llm_requests/api", http.HandlerFunc(s.handleDebugLLMRequestsAPI))
+	mux.Handle("GET /debug/llm_requests/diff", gzipHandler(http.HandlerFunc(s.handleDebugLLMRequestDiff)))
 	mux.Handle("GET /debug/llm_requests/{id}/request", http.HandlerFunc(s.handleDebugLLMRequestBody))
 	mux.Handle("GET /debug/llm_requests/{id}/request_full", http.HandlerFunc(s.handleDebugLLMRequestBodyFull))
 	mux.Handle("GET /debug/llm_requests/{id}/response", http.HandlerFunc(s.handleDebugLLMResponseBody))
+	mux.Handle("GET /debug/llm_rate_limits", http.HandlerFunc(s.handleDebugLLMRateLimits))
+	mux.Handle("GET /debug/llm_usage", http.HandlerFunc(s.handleDebugLLMUsage))
 
 	// Serve embedded UI assets
 	mux.Handle("/", s.staticHandler(ui.Assets()))
@@ -1221,22 +1333,34 @@ func (s *Server) IsAgentWorking(conversationID string) bool {
 	return manager.IsAgentWorking()
 }
 
-// Cleanup removes inactive conversation managers
+// Cleanup removes inactive conversation managers. Conversations with
+// running background jobs, such as dev servers, are kept so that the jobs
+// are not killed behind the user's back.
 func (s *Server) Cleanup() {
+	var idle []*ConversationManager
 	s.mu.Lock()
-	defer s.mu.Unlock()
-
 	now := time.Now()
 	for id, manager := range s.activeConversations {
 		// Remove managers that have been inactive for more than 30 minutes
 		manager.mu.Lock()
 		lastActivity := manager.lastActivity
 		manager.mu.Unlock()
-		if now.Sub(lastActivity) > 30*time.Minute {
-			manager.stopLoop()
-			delete(s.activeConversations, id)
-			s.logger.Debug("Cleaned up inactive conversation", "conversationID", id)
+		if now.Sub(lastActivity) <= 30*time.Minute {
+			continue
+		}
+		if jobs := manager.Jobs(); jobs != nil && jobs.HasRunning() {
+			continue
 		}
+		idle = append(idle, manager)
+		delete(s.activeConversations, id)
+		s.logger.Debug("Cleaned up inactive conversation", "conversationID", id)
+	}
+	s.mu.Unlock()
+
+	// Stopping a loop kills its jobs, which can take a while, so do it
+	// without holding s.mu.
+	for _, manager := range idle {
+		manager.stopLoop()
 	}
 }
 
@@ -1263,6 +1387,12 @@ func (s *Server) StartWithListener(listener net.Listener) error {
 	// Start cluster monitor (merge pipeline) on orchestrator node
 	s.startClusterMonitor()
 
+	// Start scheduled memory maintenance
+	s.startMemoryMaintenance()
+
+	// Start pruning recorded LLM requests
+	s.startLLMRequestPruner()
+
 	// Add middleware (applied in reverse order: last added = first executed)
 	handler := LoggerMiddleware(s.logger)(mux)
 	cop := http.NewCrossOriginProtection()
@@ -45,7 +45,7 @@ func (r *SubagentRunner) RunSubagent(ctx context.Context, conversationID, prompt
 	}
 
 	// Get LLM service
-	llmService, err := s.llmManager.GetService(modelID)
+	llmService, err := s.serviceForTask(llm.TaskMain, modelID)
 	if err != nil {
 		return "", fmt.Errorf("failed to get LLM service: %w", err)
 	}
@@ -222,6 +222,11 @@ Provide your summary now:`
 		},
 	}
 
+	// Side tasks may be routed to a cheaper model than the subagent's
+	if routed, err := s.serviceForTask(llm.TaskProgressSummary, modelID); err == nil {
+		llmService = routed
+	}
+
 	// Use a short timeout for the summary call
 	summaryCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
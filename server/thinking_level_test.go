@@ -0,0 +1,117 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tgruben-circuit/percy/llm"
+)
+
+func TestThinkingLevel(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+
+	post := func(path string, body any, handle func(http.ResponseWriter, *http.Request)) *httptest.ResponseRecorder {
+		t.Helper()
+		b, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := httptest.NewRequest("POST", path, strings.NewReader(string(b)))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		handle(w, req)
+		return w
+	}
+	// lastLevel returns the level of the latest agent loop request. Slug
+	// generation shares the service but sends no system prompt, and may
+	// finish after the turn it was started by.
+	lastLevel := func() *llm.ThinkingLevel {
+		t.Helper()
+		reqs := h.llm.GetRecentRequests()
+		for i := len(reqs) - 1; i >= 0; i-- {
+			if len(reqs[i].System) > 0 {
+				return reqs[i].ThinkingLevel
+			}
+		}
+		t.Fatal("no LLM request recorded")
+		return nil
+	}
+	wantLevel := func(step string, want llm.ThinkingLevel) {
+		t.Helper()
+		if got := lastLevel(); got == nil || *got != want {
+			t.Errorf("%s: request thinking level = %v, want %s", step, got, want.Name())
+		}
+	}
+
+	// Invalid levels are rejected before a conversation is created.
+	w := post("/api/conversations/new", ChatRequest{Message: "hello", Model: "predictable", ThinkingLevel: "extreme"}, h.server.handleNewConversation)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("new conversation with bad level: status %d, want 400", w.Code)
+	}
+
+	w = post("/api/conversations/new", ChatRequest{Message: "hello", Model: "predictable", ThinkingLevel: "high"}, h.server.handleNewConversation)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("new conversation: status %d: %s", w.Code, w.Body.String())
+	}
+	var created struct {
+		ConversationID string `json:"conversation_id"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatal(err)
+	}
+	h.convID = created.ConversationID
+	h.WaitResponse()
+	wantLevel("new conversation", llm.ThinkingLevelHigh)
+
+	conv, err := h.db.GetConversationByID(context.Background(), h.convID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conv.ThinkingLevel == nil || *conv.ThinkingLevel != "high" {
+		t.Errorf("stored thinking level = %v, want high", conv.ThinkingLevel)
+	}
+
+	// Changing the conversation's level applies to later turns.
+	handleSet := func(w http.ResponseWriter, r *http.Request) { h.server.handleSetThinkingLevel(w, r, h.convID) }
+	w = post("/api/conversation/"+h.convID+"/thinking-level", ThinkingLevelRequest{ThinkingLevel: "off"}, handleSet)
+	if w.Code != http.StatusOK {
+		t.Fatalf("set thinking level: status %d: %s", w.Code, w.Body.String())
+	}
+	h.Chat("hello")
+	h.WaitResponse()
+	wantLevel("after set", llm.ThinkingLevelOff)
+
+	// A per-message level applies to that turn only.
+	handleChat := func(w http.ResponseWriter, r *http.Request) { h.server.handleChatConversation(w, r, h.convID) }
+	w = post("/api/conversation/"+h.convID+"/chat", ChatRequest{Message: "hello", Model: "predictable", ThinkingLevel: "low"}, handleChat)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("chat: status %d: %s", w.Code, w.Body.String())
+	}
+	h.WaitResponse()
+	wantLevel("per-message", llm.ThinkingLevelLow)
+
+	h.Chat("hello")
+	h.WaitResponse()
+	wantLevel("after per-message", llm.ThinkingLevelOff)
+
+	// Clearing the level restores the model default.
+	w = post("/api/conversation/"+h.convID+"/thinking-level", ThinkingLevelRequest{}, handleSet)
+	if w.Code != http.StatusOK {
+		t.Fatalf("clear thinking level: status %d: %s", w.Code, w.Body.String())
+	}
+	h.Chat("hello")
+	h.WaitResponse()
+	if got := lastLevel(); got != nil {
+		t.Errorf("after clear: request thinking level = %s, want default", got.Name())
+	}
+
+	w = post("/api/conversation/"+h.convID+"/thinking-level", ThinkingLevelRequest{ThinkingLevel: "extreme"}, handleSet)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("set bad level: status %d, want 400", w.Code)
+	}
+}
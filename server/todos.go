@@ -0,0 +1,79 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/tgruben-circuit/percy/claudetool"
+	"github.com/tgruben-circuit/percy/db"
+)
+
+// todoStore implements claudetool.TodoStore by storing the conversation's
+// task list in the database.
+type todoStore struct {
+	db             *db.DB
+	conversationID string
+}
+
+func (s *todoStore) ListTodos(ctx context.Context) ([]claudetool.Todo, error) {
+	return listTodos(ctx, s.db, s.conversationID)
+}
+
+func (s *todoStore) NextTodoNumber(ctx context.Context) (int, error) {
+	next, err := s.db.NextTodoNumber(ctx, s.conversationID)
+	return int(next), err
+}
+
+func (s *todoStore) ReplaceTodos(ctx context.Context, todos []claudetool.Todo, next int) error {
+	items := make([]db.TodoItem, len(todos))
+	for i, todo := range todos {
+		items[i] = db.TodoItem{ID: todo.ID, Content: todo.Content, Status: todo.Status}
+	}
+	return s.db.ReplaceTodos(ctx, s.conversationID, items, int64(next))
+}
+
+// listTodos returns a conversation's task list.
+func listTodos(ctx context.Context, database *db.DB, conversationID string) ([]claudetool.Todo, error) {
+	rows, err := database.ListTodos(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	todos := make([]claudetool.Todo, len(rows))
+	for i, row := range rows {
+		todos[i] = claudetool.Todo{ID: row.TodoID, Content: row.Content, Status: row.Status}
+	}
+	return todos, nil
+}
+
+// carryOverTodos copies the task list of a conversation being continued to
+// the new conversation.
+func (s *Server) carryOverTodos(ctx context.Context, fromConversationID, toConversationID string) {
+	if err := s.db.CopyTodos(ctx, fromConversationID, toConversationID); err != nil {
+		s.logger.Warn("Failed to carry over task list", "from", fromConversationID, "to", toConversationID, "error", err)
+	}
+}
+
+// carriedTodosNote describes a continued conversation's carried-over task
+// list for its first message, or returns "" if there is none.
+func (s *Server) carriedTodosNote(ctx context.Context, conversationID string) string {
+	todos, err := listTodos(ctx, s.db, conversationID)
+	if err != nil || len(todos) == 0 {
+		return ""
+	}
+	return "\n\n## Task List\n\nThe task list carried over from the previous conversation. Keep it current with todo_write, using these IDs:\n\n" +
+		claudetool.FormatTodos(todos)
+}
+
+// handleListTodos handles GET /conversation/<id>/todos
+func (s *Server) handleListTodos(w http.ResponseWriter, r *http.Request, conversationID string) {
+	todos, err := listTodos(r.Context(), s.db, conversationID)
+	if err != nil {
+		s.logger.Error("Failed to list todos", "conversationID", conversationID, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(todos) //nolint:errchkjson // best-effort HTTP response
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tgruben-circuit/percy/claudetool"
+	"github.com/tgruben-circuit/percy/db"
+	"github.com/tgruben-circuit/percy/llm"
+)
+
+func TestTodoList(t *testing.T) {
+	h := NewTestHarness(t)
+	defer h.Close()
+	ctx := context.Background()
+
+	h.NewConversation("todo: write tests; ship it", t.TempDir())
+	if result := h.WaitToolResult(); !strings.Contains(result, "- [ ] t2: ship it") {
+		t.Fatalf("unexpected todo_write result: %q", result)
+	}
+	h.WaitResponse()
+	sourceID := h.convID
+
+	req := httptest.NewRequest("GET", "/api/conversation/"+sourceID+"/todos", nil)
+	w := httptest.NewRecorder()
+	h.server.handleListTodos(w, req, sourceID)
+	var todos []claudetool.Todo
+	if err := json.Unmarshal(w.Body.Bytes(), &todos); err != nil {
+		t.Fatal(err)
+	}
+	if len(todos) != 2 || todos[0] != (claudetool.Todo{ID: "t1", Content: "write tests", Status: claudetool.TodoPending}) {
+		t.Fatalf("todos = %+v", todos)
+	}
+
+	body, _ := json.Marshal(ContinueConversationRequest{SourceConversationID: sourceID, Model: "predictable"})
+	req = httptest.NewRequest("POST", "/api/conversations/continue", strings.NewReader(string(body)))
+	w = httptest.NewRecorder()
+	h.server.handleContinueConversation(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("continue: status %d: %s", w.Code, w.Body.String())
+	}
+	var resp map[string]string
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	newID := resp["conversation_id"]
+
+	carried, err := listTodos(ctx, h.db, newID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(carried) != 2 || carried[1].ID != "t2" {
+		t.Errorf("carried todos = %+v", carried)
+	}
+	msgs, err := h.db.ListMessages(ctx, newID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var summary string
+	for _, msg := range msgs {
+		if msg.Type == string(db.MessageTypeUser) && msg.LlmData != nil {
+			var llmMsg llm.Message
+			if err := json.Unmarshal([]byte(*msg.LlmData), &llmMsg); err == nil && len(llmMsg.Content) > 0 {
+				summary = llmMsg.Content[0].Text
+			}
+		}
+	}
+	if !strings.Contains(summary, "## Task List") || !strings.Contains(summary, "- [ ] t2: ship it") {
+		t.Errorf("continuation message does not list the tasks:\n%s", summary)
+	}
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/tgruben-circuit/percy/db"
 	"github.com/tgruben-circuit/percy/llm"
+	"github.com/tgruben-circuit/percy/llm/llmhttp"
 	"github.com/tgruben-circuit/percy/models"
 )
 
@@ -23,6 +24,8 @@ type LLMServiceProvider interface {
 // GenerateSlug generates a slug for a conversation and updates the database
 // If conversationModelID is provided, it will be used as a fallback if no model is tagged with "slug"
 func GenerateSlug(ctx context.Context, llmProvider LLMServiceProvider, database *db.DB, logger *slog.Logger, conversationID, userMessage, conversationModelID string) (string, error) {
+	// Slugs are cosmetic; never hold up a conversation turn for one
+	ctx = llmhttp.WithPriority(ctx, llmhttp.PriorityBackground)
 	baseSlug, err := generateSlugText(ctx, llmProvider, logger, userMessage, conversationModelID)
 	if err != nil {
 		return "", err
@@ -55,12 +58,20 @@ func GenerateSlug(ctx context.Context, llmProvider LLMServiceProvider, database
 	return "", fmt.Errorf("failed to generate unique slug after 100 attempts")
 }
 
-// generateSlugText generates a human-readable slug for a conversation based on the user message
-// Priority order:
+// slugService picks the model for slug generation using the provider's
+// routing policy. Providers without one are searched in priority order:
 // 1. If conversationModelID is "predictable", use it
 // 2. Try models tagged with "slug"
 // 3. Fall back to the conversation's model (conversationModelID)
-func generateSlugText(ctx context.Context, llmProvider LLMServiceProvider, logger *slog.Logger, userMessage, conversationModelID string) (string, error) {
+func slugService(llmProvider LLMServiceProvider, logger *slog.Logger, conversationModelID string) (llm.Service, error) {
+	if router, ok := llmProvider.(models.TaskRouter); ok {
+		svc, err := router.ServiceForTask(llm.TaskSlug, conversationModelID)
+		if err != nil {
+			return nil, fmt.Errorf("no suitable model available for slug generation: %w", err)
+		}
+		return svc, nil
+	}
+
 	var llmService llm.Service
 	var err error
 
@@ -101,7 +112,16 @@ func generateSlugText(ctx context.Context, llmProvider LLMServiceProvider, logge
 	}
 
 	if llmService == nil {
-		return "", fmt.Errorf("no suitable model available for slug generation")
+		return nil, fmt.Errorf("no suitable model available for slug generation")
+	}
+	return llmService, nil
+}
+
+// generateSlugText generates a human-readable slug for a conversation based on the user message
+func generateSlugText(ctx context.Context, llmProvider LLMServiceProvider, logger *slog.Logger, userMessage, conversationModelID string) (string, error) {
+	llmService, err := slugService(llmProvider, logger, conversationModelID)
+	if err != nil {
+		return "", err
 	}
 
 	// Create a focused prompt for slug generation
@@ -113,9 +133,7 @@ The slug should:
 - Be concise and descriptive
 - Use only lowercase letters, numbers, and hyphens
 - Capture the main topic or intent
-- Be suitable as a filename or URL path
-
-Respond with only the slug, nothing else.`, userMessage)
+- Be suitable as a filename or URL path`, userMessage)
 
 	message := llm.Message{
 		Role: llm.MessageRoleUser,
@@ -132,24 +150,21 @@ Respond with only the slug, nothing else.`, userMessage)
 	ctxWithTimeout, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
-	response, err := llmService.Do(ctxWithTimeout, request)
+	slug, err := llm.DoStructured(ctxWithTimeout, llmService, request, &llm.StructuredOptions[string]{
+		Name:        "set_slug",
+		Description: "Set the conversation slug.",
+		Validate: func(s string) error {
+			if Sanitize(s) == "" {
+				return fmt.Errorf("slug %q is empty after sanitization", s)
+			}
+			return nil
+		},
+	})
 	if err != nil {
 		return "", fmt.Errorf("failed to generate slug: %w", err)
 	}
 
-	// Extract text from response
-	if len(response.Content) == 0 {
-		return "", fmt.Errorf("empty response from LLM")
-	}
-
-	slug := strings.TrimSpace(response.Content[0].Text)
-
-	// Clean and validate the slug
-	slug = Sanitize(slug)
-	if slug == "" {
-		return "", fmt.Errorf("generated slug is empty after sanitization")
-	}
-
+	slug = Sanitize(strings.TrimSpace(slug))
 	return slug, nil
 }
 
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/tgruben-circuit/percy/db"
@@ -284,7 +285,7 @@ func TestGenerateSlug_EmptyResponse(t *testing.T) {
 	if err == nil {
 		t.Error("Expected error for empty LLM response, got nil")
 	}
-	if err.Error() != "empty response from LLM" {
+	if !strings.Contains(err.Error(), "empty answer") {
 		t.Errorf("Expected 'empty response' error, got %q", err.Error())
 	}
 }
@@ -338,7 +339,7 @@ func TestGenerateSlug_SanitizationError(t *testing.T) {
 	if err == nil {
 		t.Error("Expected error for empty slug after sanitization, got nil")
 	}
-	if err.Error() != "generated slug is empty after sanitization" {
+	if !strings.Contains(err.Error(), "empty after sanitization") {
 		t.Errorf("Expected 'empty after sanitization' error, got %q", err.Error())
 	}
 }
@@ -405,9 +405,19 @@ function App() {
   const mostRecentCwd =
     currentConversation?.cwd || (conversations.length > 0 ? conversations[0].cwd : null);
 
-  const handleFirstMessage = async (message: string, model: string, cwd?: string) => {
+  const handleFirstMessage = async (
+    message: string,
+    model: string,
+    cwd?: string,
+    thinkingLevel?: string,
+  ) => {
     try {
-      const response = await api.sendMessageWithNewConversation({ message, model, cwd });
+      const response = await api.sendMessageWithNewConversation({
+        message,
+        model,
+        cwd,
+        thinking_level: thinkingLevel,
+      });
       const newConversationId = response.conversation_id;
 
       // Fetch the new conversation details
@@ -4,6 +4,8 @@ import { LLMContent } from "../types";
 // Display data from the bash tool backend
 interface BashDisplayData {
   workingDir: string;
+  jobId?: string; // set for commands started with background=true
+  sandboxed?: boolean; // set for commands run in the sandbox
 }
 
 interface BashToolProps {
@@ -78,6 +80,16 @@ function BashTool({
               in {displayData.workingDir}
             </span>
           )}
+          {displayData?.jobId && (
+            <span className="bash-tool-cwd" title="Running as a background job">
+              background {displayData.jobId}
+            </span>
+          )}
+          {displayData?.sandboxed && (
+            <span className="bash-tool-cwd" title="Ran in the sandbox">
+              sandboxed
+            </span>
+          )}
           {isComplete && isCancelled && <span className="bash-tool-cancelled">✗ cancelled</span>}
           {isComplete && hasError && !isCancelled && <span className="bash-tool-error">✗</span>}
           {isComplete && !hasError && <span className="bash-tool-success">✓</span>}
@@ -1,10 +1,11 @@
-import React, { useState, useEffect, useRef, useCallback } from "react";
+import React, { useState, useEffect, useRef, useCallback, useMemo } from "react";
 import {
   Message,
   Conversation,
   StreamResponse,
   LLMContent,
   ConversationListUpdate,
+  Todo,
   isDistillStatusMessage,
 } from "../types";
 import { api } from "../services/api";
@@ -30,6 +31,11 @@ import BrowserEvalTool from "./BrowserEvalTool";
 import ReadImageTool from "./ReadImageTool";
 import BrowserConsoleLogsTool from "./BrowserConsoleLogsTool";
 import ChangeDirTool from "./ChangeDirTool";
+import CodeIntelTool from "./CodeIntelTool";
+import GlobTool from "./GlobTool";
+import GrepTool from "./GrepTool";
+import WebFetchTool from "./WebFetchTool";
+import TodoTool, { TodoChecklist, todoProgress } from "./TodoTool";
 import BrowserResizeTool from "./BrowserResizeTool";
 import SubagentTool from "./SubagentTool";
 import OutputIframeTool from "./OutputIframeTool";
@@ -37,6 +43,7 @@ import DirectoryPickerModal from "./DirectoryPickerModal";
 import { useVersionChecker } from "./VersionChecker";
 import TerminalPanel, { EphemeralTerminal } from "./TerminalPanel";
 import ModelPicker from "./ModelPicker";
+import ThinkingLevelSelect from "./ThinkingLevelSelect";
 import SystemPromptView from "./SystemPromptView";
 
 interface ContextUsageBarProps {
@@ -250,6 +257,30 @@ interface CoalescedToolCallProps {
   onCommentTextChange?: (text: string) => void;
 }
 
+// latestTodos returns the task list from the newest todo_write result in
+// messages, or null if there is none. Tool results are recognized by the
+// shape of their display data.
+function latestTodos(messages: Message[]): Todo[] | null {
+  for (let i = messages.length - 1; i >= 0; i--) {
+    const raw = messages[i].display_data;
+    if (!raw) continue;
+    let displays: unknown;
+    try {
+      displays = typeof raw === "string" ? JSON.parse(raw) : raw;
+    } catch {
+      continue;
+    }
+    if (!Array.isArray(displays)) continue;
+    for (let j = displays.length - 1; j >= 0; j--) {
+      const display = (displays[j] as { display?: { todos?: unknown } } | null)?.display;
+      if (display && Array.isArray(display.todos)) {
+        return display.todos as Todo[];
+      }
+    }
+  }
+  return null;
+}
+
 // Map tool names to their specialized components.
 // IMPORTANT: When adding a new tool here, also add it to Message.tsx renderContent()
 // for both tool_use and tool_result cases. See AGENTS.md in this directory.
@@ -261,6 +292,10 @@ const TOOL_COMPONENTS: Record<string, React.ComponentType<any>> = {
   browser_take_screenshot: ScreenshotTool,
 
   keyword_search: KeywordSearchTool,
+  glob: GlobTool,
+  grep: GrepTool,
+  web_fetch: WebFetchTool,
+  todo_write: TodoTool,
   browser_navigate: BrowserNavigateTool,
   browser_eval: BrowserEvalTool,
   read_image: ReadImageTool,
@@ -270,6 +305,7 @@ const TOOL_COMPONENTS: Record<string, React.ComponentType<any>> = {
   browser_resize: BrowserResizeTool,
   subagent: SubagentTool,
   output_iframe: OutputIframeTool,
+  code_intelligence: CodeIntelTool,
 };
 
 function CoalescedToolCall({
@@ -485,7 +521,12 @@ interface ChatInterfaceProps {
   onConversationUpdate?: (conversation: Conversation) => void;
   onConversationListUpdate?: (update: ConversationListUpdate) => void;
   onConversationStateUpdate?: (state: ConversationStateUpdate) => void;
-  onFirstMessage?: (message: string, model: string, cwd?: string) => Promise<void>;
+  onFirstMessage?: (
+    message: string,
+    model: string,
+    cwd?: string,
+    thinkingLevel?: string,
+  ) => Promise<void>;
   onContinueConversation?: (
     sourceConversationId: string,
     model: string,
@@ -565,6 +606,14 @@ function ChatInterface({
     setSelectedModelState(model);
     localStorage.setItem("percy_selected_model", model);
   };
+  // Extended-thinking level; "" means the model's default
+  const [thinkingLevel, setThinkingLevelState] = useState<string>(
+    () => localStorage.getItem("percy_thinking_level") || "",
+  );
+  const setThinkingLevel = (level: string) => {
+    setThinkingLevelState(level);
+    localStorage.setItem("percy_thinking_level", level);
+  };
   const [selectedCwd, setSelectedCwdState] = useState<string>("");
   const [cwdInitialized, setCwdInitialized] = useState(false);
   // Wrapper to persist cwd selection to localStorage
@@ -641,6 +690,12 @@ function ChatInterface({
   const [diffViewerCwd, setDiffViewerCwd] = useState<string | undefined>(undefined);
   const [diffCommentText, setDiffCommentText] = useState("");
   const [agentWorking, setAgentWorking] = useState(false);
+  // User messages whose turns have file checkpoints that can be restored
+  const [checkpointMessageIds, setCheckpointMessageIds] = useState<Set<string>>(new Set());
+  // The stored task list, which includes a list carried over from a
+  // continued conversation; newer todo_write results in messages win.
+  const [storedTodos, setStoredTodos] = useState<Todo[]>([]);
+  const [todoPanelExpanded, setTodoPanelExpanded] = useState(true);
   const [cancelling, setCancelling] = useState(false);
   const [contextWindowSize, setContextWindowSize] = useState(0);
   const terminalURL = window.__PERCY_INIT__?.terminal_url || null;
@@ -700,6 +755,30 @@ function ChatInterface({
     };
   }, [conversationId]);
 
+  // Refresh restorable checkpoints whenever the agent finishes a turn
+  useEffect(() => {
+    if (!conversationId) {
+      setCheckpointMessageIds(new Set());
+      return;
+    }
+    if (!agentWorking) {
+      loadCheckpoints(conversationId);
+    }
+  }, [conversationId, agentWorking]);
+
+  // Load the conversation's task list
+  useEffect(() => {
+    setStoredTodos([]);
+    if (!conversationId) return;
+    api
+      .getTodos(conversationId)
+      .then(setStoredTodos)
+      .catch((err) => console.error("Failed to load todos:", err));
+  }, [conversationId]);
+
+  const liveTodos = useMemo(() => latestTodos(messages), [messages]);
+  const todos = liveTodos ?? storedTodos;
+
   // Show working indicator on favicon (UI concern, not a notification)
   useEffect(() => {
     if (agentWorking) {
@@ -1008,6 +1087,66 @@ function ChatInterface({
     };
   };
 
+  const handleConversationThinkingLevel = async (level: string) => {
+    if (!conversationId) return;
+    try {
+      const updated = await api.setThinkingLevel(conversationId, level);
+      onConversationUpdate?.(updated);
+    } catch (err) {
+      console.error("Failed to set thinking level:", err);
+      setError(err instanceof Error ? err.message : "Failed to set thinking level");
+    }
+  };
+
+  const handleConversationPatchDiagnostics = async (enabled: boolean) => {
+    if (!conversationId) return;
+    try {
+      const updated = await api.setPatchDiagnostics(conversationId, enabled);
+      onConversationUpdate?.(updated);
+    } catch (err) {
+      console.error("Failed to set patch diagnostics:", err);
+      setError(err instanceof Error ? err.message : "Failed to set patch diagnostics");
+    }
+  };
+
+  const handleConversationSandbox = async (enabled: boolean) => {
+    if (!conversationId) return;
+    try {
+      const updated = await api.setSandbox(conversationId, enabled);
+      onConversationUpdate?.(updated);
+    } catch (err) {
+      console.error("Failed to set sandbox:", err);
+      setError(err instanceof Error ? err.message : "Failed to set sandbox");
+    }
+  };
+
+  const loadCheckpoints = async (id: string) => {
+    try {
+      const turns = await api.getCheckpoints(id);
+      setCheckpointMessageIds(new Set(turns.map((turn) => turn.message_id)));
+    } catch (err) {
+      console.error("Failed to load checkpoints:", err);
+    }
+  };
+
+  const handleUndoFiles = async (messageId: string) => {
+    if (!conversationId) return;
+    if (
+      !confirm(
+        "Restore all files the agent changed since this message to their earlier state? This cannot be undone.",
+      )
+    ) {
+      return;
+    }
+    try {
+      await api.restoreCheckpoint(conversationId, messageId);
+    } catch (err) {
+      console.error("Failed to restore checkpoint:", err);
+      setError(err instanceof Error ? err.message : "Failed to restore checkpoint");
+    }
+    loadCheckpoints(conversationId);
+  };
+
   const sendMessage = async (message: string) => {
     if (!message.trim() || sending) return;
 
@@ -1063,7 +1202,12 @@ function ChatInterface({
             throw new Error(`Invalid working directory: ${validation.error}`);
           }
         }
-        await onFirstMessage(message.trim(), selectedModel, selectedCwd || undefined);
+        await onFirstMessage(
+          message.trim(),
+          selectedModel,
+          selectedCwd || undefined,
+          thinkingLevel || undefined,
+        );
       } else if (conversationId) {
         await api.sendMessage(conversationId, {
           message: message.trim(),
@@ -1415,6 +1559,7 @@ function ChatInterface({
 
     const rendered = coalescedItems.map((item, index) => {
       if (item.type === "message" && item.message) {
+        const messageId = item.message.message_id;
         return (
           <MessageComponent
             key={item.message.message_id}
@@ -1425,6 +1570,11 @@ function ChatInterface({
               setShowDiffViewer(true);
             }}
             onCommentTextChange={setDiffCommentText}
+            onUndoFiles={
+              !agentWorking && checkpointMessageIds.has(messageId)
+                ? () => handleUndoFiles(messageId)
+                : undefined
+            }
           />
         );
       } else if (item.type === "tool") {
@@ -1870,6 +2020,20 @@ function ChatInterface({
       )}
 
       {/* Unified Status Bar */}
+      {todos.length > 0 && (
+        <div className="todo-panel">
+          <button
+            className="todo-panel-header"
+            onClick={() => setTodoPanelExpanded(!todoPanelExpanded)}
+            aria-expanded={todoPanelExpanded}
+          >
+            <span>Tasks</span>
+            <span className="todo-panel-progress">{todoProgress(todos)} done</span>
+          </button>
+          {todoPanelExpanded && <TodoChecklist todos={todos} />}
+        </div>
+      )}
+
       <div className="status-bar">
         <div className="status-bar-content">
           {isDisconnected ? (
@@ -1956,6 +2120,18 @@ function ChatInterface({
                 />
               </div>
 
+              <div
+                className="status-field status-field-thinking"
+                title="Extended thinking for this conversation"
+              >
+                <span className="status-field-label">Thinking:</span>
+                <ThinkingLevelSelect
+                  value={thinkingLevel}
+                  onChange={setThinkingLevel}
+                  disabled={sending}
+                />
+              </div>
+
               {/* CWD indicator - far right */}
               <div
                 className={`status-field status-field-cwd${cwdError ? " status-field-error" : ""}`}
@@ -1975,6 +2151,33 @@ function ChatInterface({
             // Active conversation - show Ready + context bar
             <div className="status-bar-active">
               <span className="status-message status-ready">Ready on {hostname}</span>
+              <ThinkingLevelSelect
+                value={currentConversation?.thinking_level || ""}
+                onChange={handleConversationThinkingLevel}
+                title="Extended thinking for this conversation"
+              />
+              <label
+                className="patch-diagnostics-toggle"
+                title="Report language-server errors introduced by each patch"
+              >
+                <input
+                  type="checkbox"
+                  checked={currentConversation?.patch_diagnostics ?? true}
+                  onChange={(e) => handleConversationPatchDiagnostics(e.target.checked)}
+                />
+                Diagnostics
+              </label>
+              <label
+                className="sandbox-toggle"
+                title="Run bash commands with only the working tree writable and network access restricted"
+              >
+                <input
+                  type="checkbox"
+                  checked={currentConversation?.sandbox ?? false}
+                  onChange={(e) => handleConversationSandbox(e.target.checked)}
+                />
+                Sandbox
+              </label>
               <ContextUsageBar
                 contextWindowSize={contextWindowSize}
                 maxContextTokens={
@@ -0,0 +1,57 @@
+import React from "react";
+import { LLMContent } from "../types";
+import GenericTool from "./GenericTool";
+import PatchTool from "./PatchTool";
+
+interface CodeIntelToolProps {
+  // For tool_use (pending state)
+  toolInput?: unknown;
+  isRunning?: boolean;
+
+  // For tool_result (completed state)
+  toolResult?: LLMContent[];
+  hasError?: boolean;
+  executionTime?: string;
+  display?: unknown; // For rename, code_action and format: one patch display per changed file
+}
+
+// Edits made by code_intelligence are shown like patch tool edits, one
+// diff per changed file. Queries fall back to the generic tool view.
+function CodeIntelTool({
+  toolInput,
+  isRunning,
+  toolResult,
+  hasError,
+  executionTime,
+  display,
+}: CodeIntelToolProps) {
+  if (!isRunning && !hasError && Array.isArray(display) && display.length > 0) {
+    return (
+      <>
+        {display.map((fileDisplay, i) => (
+          <PatchTool
+            key={i}
+            toolInput={{ path: (fileDisplay as { path?: string }).path }}
+            isRunning={false}
+            toolResult={toolResult}
+            executionTime={executionTime}
+            display={fileDisplay}
+          />
+        ))}
+      </>
+    );
+  }
+
+  return (
+    <GenericTool
+      toolName="code_intelligence"
+      toolInput={toolInput}
+      isRunning={isRunning}
+      toolResult={toolResult}
+      hasError={hasError}
+      executionTime={executionTime}
+    />
+  );
+}
+
+export default CodeIntelTool;
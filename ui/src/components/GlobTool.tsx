@@ -0,0 +1,126 @@
+import React, { useState } from "react";
+import { LLMContent } from "../types";
+
+interface GlobDisplayData {
+  pattern: string;
+  path: string;
+  files: string[];
+  truncated: boolean;
+}
+
+interface GlobToolProps {
+  // For tool_use (pending state)
+  toolInput?: unknown; // { pattern: string, path?: string, limit?: number }
+  isRunning?: boolean;
+
+  // For tool_result (completed state)
+  toolResult?: LLMContent[];
+  hasError?: boolean;
+  executionTime?: string;
+  display?: unknown; // GlobDisplayData from the backend
+}
+
+function GlobTool({
+  toolInput,
+  isRunning,
+  toolResult,
+  hasError,
+  executionTime,
+  display,
+}: GlobToolProps) {
+  const [isExpanded, setIsExpanded] = useState(false);
+
+  const input =
+    typeof toolInput === "object" && toolInput !== null
+      ? (toolInput as { pattern?: unknown; path?: unknown })
+      : {};
+  const pattern = typeof input.pattern === "string" ? input.pattern : "";
+  const path = typeof input.path === "string" ? input.path : "";
+
+  const displayData: GlobDisplayData | null =
+    display && typeof display === "object" && "files" in display
+      ? (display as GlobDisplayData)
+      : null;
+
+  const resultText =
+    toolResult
+      ?.map((r) => r.Text)
+      .filter(Boolean)
+      .join("") || "";
+
+  const isComplete = !isRunning && toolResult !== undefined;
+  const fileCount = displayData?.files.length ?? 0;
+
+  return (
+    <div className="tool" data-testid={isComplete ? "tool-call-completed" : "tool-call-running"}>
+      <div className="tool-header" onClick={() => setIsExpanded(!isExpanded)}>
+        <div className="tool-summary">
+          <span className={`tool-emoji ${isRunning ? "running" : ""}`}>🗂️</span>
+          <span className="tool-command">
+            glob {pattern || "..."}
+            {path && ` in ${path}`}
+          </span>
+          {isComplete && displayData && (
+            <span className="tool-time">
+              {fileCount} {fileCount === 1 ? "file" : "files"}
+              {displayData.truncated && "+"}
+            </span>
+          )}
+          {isComplete && hasError && <span className="tool-error">✗</span>}
+          {isComplete && !hasError && <span className="tool-success">✓</span>}
+        </div>
+        <button
+          className="tool-toggle"
+          aria-label={isExpanded ? "Collapse" : "Expand"}
+          aria-expanded={isExpanded}
+        >
+          <svg
+            width="12"
+            height="12"
+            viewBox="0 0 12 12"
+            fill="none"
+            xmlns="http://www.w3.org/2000/svg"
+            style={{
+              transform: isExpanded ? "rotate(90deg)" : "rotate(0deg)",
+              transition: "transform 0.2s",
+            }}
+          >
+            <path
+              d="M4.5 3L7.5 6L4.5 9"
+              stroke="currentColor"
+              strokeWidth="1.5"
+              strokeLinecap="round"
+              strokeLinejoin="round"
+            />
+          </svg>
+        </button>
+      </div>
+
+      {isExpanded && (
+        <div className="tool-details">
+          <div className="tool-section">
+            <div className="tool-label">
+              Pattern:
+              {executionTime && <span className="tool-time">{executionTime}</span>}
+            </div>
+            <div className="tool-code">{pattern || "(no pattern)"}</div>
+          </div>
+          {isComplete && (
+            <div className="tool-section">
+              <div className="tool-label">Files:</div>
+              <div className={`tool-code ${hasError ? "error" : ""}`}>
+                {displayData && !hasError
+                  ? fileCount > 0
+                    ? displayData.files.join("\n") + (displayData.truncated ? "\n…" : "")
+                    : "(no matches)"
+                  : resultText || "(no output)"}
+              </div>
+            </div>
+          )}
+        </div>
+      )}
+    </div>
+  );
+}
+
+export default GlobTool;
@@ -0,0 +1,178 @@
+import React, { useState } from "react";
+import { LLMContent } from "../types";
+
+interface GrepLine {
+  number: number;
+  text: string;
+  match: boolean;
+}
+
+interface GrepFile {
+  path: string;
+  count: number;
+  lines?: GrepLine[];
+}
+
+interface GrepDisplayData {
+  pattern: string;
+  output_mode: string;
+  files: GrepFile[];
+  truncated: boolean;
+}
+
+interface GrepToolProps {
+  // For tool_use (pending state)
+  toolInput?: unknown; // { pattern: string, path?: string, glob?: string, type?: string, ... }
+  isRunning?: boolean;
+
+  // For tool_result (completed state)
+  toolResult?: LLMContent[];
+  hasError?: boolean;
+  executionTime?: string;
+  display?: unknown; // GrepDisplayData from the backend
+}
+
+function plural(n: number, noun: string) {
+  return `${n} ${n === 1 ? noun : noun.endsWith("h") ? noun + "es" : noun + "s"}`;
+}
+
+function GrepTool({
+  toolInput,
+  isRunning,
+  toolResult,
+  hasError,
+  executionTime,
+  display,
+}: GrepToolProps) {
+  const [isExpanded, setIsExpanded] = useState(false);
+
+  const input =
+    typeof toolInput === "object" && toolInput !== null
+      ? (toolInput as { pattern?: unknown; path?: unknown; glob?: unknown; type?: unknown })
+      : {};
+  const pattern = typeof input.pattern === "string" ? input.pattern : "";
+  const filters = [input.path, input.glob, input.type].filter(
+    (f): f is string => typeof f === "string" && f !== "",
+  );
+
+  const displayData: GrepDisplayData | null =
+    display && typeof display === "object" && "files" in display && "output_mode" in display
+      ? (display as GrepDisplayData)
+      : null;
+
+  const resultText =
+    toolResult
+      ?.map((r) => r.Text)
+      .filter(Boolean)
+      .join("") || "";
+
+  const isComplete = !isRunning && toolResult !== undefined;
+  const fileCount = displayData?.files.length ?? 0;
+  const matchCount = displayData?.files.reduce((n, f) => n + f.count, 0) ?? 0;
+
+  return (
+    <div className="tool" data-testid={isComplete ? "tool-call-completed" : "tool-call-running"}>
+      <div className="tool-header" onClick={() => setIsExpanded(!isExpanded)}>
+        <div className="tool-summary">
+          <span className={`tool-emoji ${isRunning ? "running" : ""}`}>🔎</span>
+          <span className="tool-command">
+            grep {pattern || "..."}
+            {filters.length > 0 && ` (${filters.join(", ")})`}
+          </span>
+          {isComplete && displayData && (
+            <span className="tool-time">
+              {displayData.output_mode === "files_with_matches"
+                ? plural(fileCount, "file")
+                : `${plural(matchCount, "match")} in ${plural(fileCount, "file")}`}
+              {displayData.truncated && "+"}
+            </span>
+          )}
+          {isComplete && hasError && <span className="tool-error">✗</span>}
+          {isComplete && !hasError && <span className="tool-success">✓</span>}
+        </div>
+        <button
+          className="tool-toggle"
+          aria-label={isExpanded ? "Collapse" : "Expand"}
+          aria-expanded={isExpanded}
+        >
+          <svg
+            width="12"
+            height="12"
+            viewBox="0 0 12 12"
+            fill="none"
+            xmlns="http://www.w3.org/2000/svg"
+            style={{
+              transform: isExpanded ? "rotate(90deg)" : "rotate(0deg)",
+              transition: "transform 0.2s",
+            }}
+          >
+            <path
+              d="M4.5 3L7.5 6L4.5 9"
+              stroke="currentColor"
+              strokeWidth="1.5"
+              strokeLinecap="round"
+              strokeLinejoin="round"
+            />
+          </svg>
+        </button>
+      </div>
+
+      {isExpanded && (
+        <div className="tool-details">
+          <div className="tool-section">
+            <div className="tool-label">
+              Pattern:
+              {executionTime && <span className="tool-time">{executionTime}</span>}
+            </div>
+            <div className="tool-code">{pattern || "(no pattern)"}</div>
+          </div>
+          {isComplete && (hasError || !displayData) && (
+            <div className="tool-section">
+              <div className="tool-label">Result:</div>
+              <div className={`tool-code ${hasError ? "error" : ""}`}>
+                {resultText || "(no output)"}
+              </div>
+            </div>
+          )}
+          {isComplete && !hasError && displayData && fileCount === 0 && (
+            <div className="tool-section">
+              <div className="tool-code">(no matches)</div>
+            </div>
+          )}
+          {isComplete &&
+            !hasError &&
+            displayData?.files.map((file) => (
+              <div className="tool-section" key={file.path}>
+                <div className="tool-label">
+                  {file.path}
+                  {displayData.output_mode !== "files_with_matches" && (
+                    <span className="tool-time">{file.count}</span>
+                  )}
+                </div>
+                {file.lines && file.lines.length > 0 && (
+                  <div className="tool-code">
+                    {file.lines.map((line) => (
+                      <div
+                        key={line.number}
+                        style={{ opacity: line.match ? 1 : 0.6, whiteSpace: "pre" }}
+                      >
+                        {line.number}
+                        {line.match ? ":" : "-"} {line.text}
+                      </div>
+                    ))}
+                  </div>
+                )}
+              </div>
+            ))}
+          {isComplete && !hasError && displayData?.truncated && (
+            <div className="tool-section">
+              <div className="tool-label">Results truncated</div>
+            </div>
+          )}
+        </div>
+      )}
+    </div>
+  );
+}
+
+export default GrepTool;
@@ -18,6 +18,11 @@ import BrowserEvalTool from "./BrowserEvalTool";
 import ReadImageTool from "./ReadImageTool";
 import BrowserConsoleLogsTool from "./BrowserConsoleLogsTool";
 import ChangeDirTool from "./ChangeDirTool";
+import CodeIntelTool from "./CodeIntelTool";
+import GlobTool from "./GlobTool";
+import GrepTool from "./GrepTool";
+import WebFetchTool from "./WebFetchTool";
+import TodoTool from "./TodoTool";
 import BrowserResizeTool from "./BrowserResizeTool";
 import SubagentTool from "./SubagentTool";
 import OutputIframeTool from "./OutputIframeTool";
@@ -36,6 +41,8 @@ interface MessageProps {
   message: MessageType;
   onOpenDiffViewer?: (commit: string, cwd?: string) => void;
   onCommentTextChange?: (text: string) => void;
+  // Restores the files the agent changed since this message, if set
+  onUndoFiles?: () => void;
 }
 
 // Copy icon for the commit hash copy button
@@ -274,7 +281,12 @@ function DistillStatusMessage({ message }: { message: MessageType }) {
   );
 }
 
-function Message({ message, onOpenDiffViewer, onCommentTextChange }: MessageProps) {
+function Message({
+  message,
+  onOpenDiffViewer,
+  onCommentTextChange,
+  onUndoFiles,
+}: MessageProps) {
   // Render system messages with distill_status as status indicators
   if (message.type === "system") {
     if (isDistillStatusMessage(message)) {
@@ -322,7 +334,7 @@ function Message({ message, onOpenDiffViewer, onCommentTextChange }: MessageProp
   // Based on llm/llm.go constants (iota continues across types in same const block):
   // MessageRoleUser = 0, MessageRoleAssistant = 1,
   // ContentTypeText = 2, ContentTypeThinking = 3, ContentTypeRedactedThinking = 4,
-  // ContentTypeToolUse = 5, ContentTypeToolResult = 6
+  // ContentTypeToolUse = 5, ContentTypeToolResult = 6, ContentTypeDocument = 7
   const getContentType = (type: number): string => {
     switch (type) {
       case 0:
@@ -339,6 +351,8 @@ function Message({ message, onOpenDiffViewer, onCommentTextChange }: MessageProp
         return "tool_use";
       case 6:
         return "tool_result";
+      case 7:
+        return "document";
       default:
         return "unknown";
     }
@@ -460,6 +474,7 @@ function Message({ message, onOpenDiffViewer, onCommentTextChange }: MessageProp
   const messageText = getMessageText();
   const hasCopyAction = !!messageText;
   const hasUsageAction = message.type === "agent" && !!usage;
+  const hasUndoAction = isUser && !!onUndoFiles;
 
   // Build a map of tool use IDs to their inputs for linking tool_result back to tool_use
   const toolUseMap: Record<string, { name: string; input: unknown }> = {};
@@ -535,6 +550,21 @@ function Message({ message, onOpenDiffViewer, onCommentTextChange }: MessageProp
         if (content.ToolName === "keyword_search") {
           return <KeywordSearchTool toolInput={content.ToolInput} isRunning={true} />;
         }
+        // Use specialized components for glob and grep tools
+        if (content.ToolName === "glob") {
+          return <GlobTool toolInput={content.ToolInput} isRunning={true} />;
+        }
+        if (content.ToolName === "grep") {
+          return <GrepTool toolInput={content.ToolInput} isRunning={true} />;
+        }
+        // Use specialized component for web fetch tool
+        if (content.ToolName === "web_fetch") {
+          return <WebFetchTool toolInput={content.ToolInput} isRunning={true} />;
+        }
+        // Use specialized component for the task list tool
+        if (content.ToolName === "todo_write") {
+          return <TodoTool toolInput={content.ToolInput} isRunning={true} />;
+        }
         // Use specialized component for browser navigate tool
         if (content.ToolName === "browser_navigate") {
           return <BrowserNavigateTool toolInput={content.ToolInput} isRunning={true} />;
@@ -679,6 +709,49 @@ function Message({ message, onOpenDiffViewer, onCommentTextChange }: MessageProp
           );
         }
 
+        // Use specialized components for glob and grep tools
+        if (toolName === "glob" || toolName === "grep") {
+          const SearchTool = toolName === "glob" ? GlobTool : GrepTool;
+          return (
+            <SearchTool
+              toolInput={toolInput}
+              isRunning={false}
+              toolResult={content.ToolResult}
+              hasError={hasError}
+              executionTime={executionTime}
+              display={content.Display}
+            />
+          );
+        }
+
+        // Use specialized component for web fetch tool
+        if (toolName === "web_fetch") {
+          return (
+            <WebFetchTool
+              toolInput={toolInput}
+              isRunning={false}
+              toolResult={content.ToolResult}
+              hasError={hasError}
+              executionTime={executionTime}
+              display={content.Display}
+            />
+          );
+        }
+
+        // Use specialized component for the task list tool
+        if (toolName === "todo_write") {
+          return (
+            <TodoTool
+              toolInput={toolInput}
+              isRunning={false}
+              toolResult={content.ToolResult}
+              hasError={hasError}
+              executionTime={executionTime}
+              display={content.Display}
+            />
+          );
+        }
+
         // Use specialized component for keyword search tool
         if (toolName === "keyword_search") {
           return (
@@ -789,6 +862,20 @@ function Message({ message, onOpenDiffViewer, onCommentTextChange }: MessageProp
           );
         }
 
+        // Use specialized component for code intelligence edits
+        if (toolName === "code_intelligence") {
+          return (
+            <CodeIntelTool
+              toolInput={toolInput}
+              isRunning={false}
+              toolResult={content.ToolResult}
+              hasError={hasError}
+              executionTime={executionTime}
+              display={content.Display}
+            />
+          );
+        }
+
         // Default rendering for other tools using GenericTool
         return (
           <GenericTool
@@ -803,6 +890,12 @@ function Message({ message, onOpenDiffViewer, onCommentTextChange }: MessageProp
       }
       case "redacted_thinking":
         return <div className="text-tertiary italic text-sm">[Thinking content hidden]</div>;
+      case "document":
+        return (
+          <div className="text-secondary text-sm">
+            [Document: {content.Title || "untitled"} ({content.MediaType})]
+          </div>
+        );
       case "thinking": {
         const thinkingText = content.Thinking || content.Text || "";
         if (!thinkingText) return null;
@@ -1086,10 +1179,11 @@ function Message({ message, onOpenDiffViewer, onCommentTextChange }: MessageProp
         data-testid="message"
         role="article"
       >
-        {actionBarVisible && (hasCopyAction || hasUsageAction) && (
+        {actionBarVisible && (hasCopyAction || hasUsageAction || hasUndoAction) && (
           <MessageActionBar
             onCopy={hasCopyAction ? handleCopy : undefined}
             onShowUsage={hasUsageAction ? handleShowUsage : undefined}
+            onUndoFiles={hasUndoAction ? onUndoFiles : undefined}
           />
         )}
         {/* Message content */}
@@ -1098,6 +1192,11 @@ function Message({ message, onOpenDiffViewer, onCommentTextChange }: MessageProp
             <div key={index}>{renderContent(content)}</div>
           ))}
         </div>
+        {usage?.fallback_from && (
+          <div className="message-fallback-note" data-testid="message-fallback-note">
+            {usage.fallback_from} was unavailable; answered by {usage.model || "a fallback model"}
+          </div>
+        )}
       </div>
       {showUsageModal && usage && (
         <UsageDetailModal
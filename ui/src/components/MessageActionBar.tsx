@@ -3,9 +3,10 @@ import React, { useState } from "react";
 interface MessageActionBarProps {
   onCopy?: () => void;
   onShowUsage?: () => void;
+  onUndoFiles?: () => void;
 }
 
-function MessageActionBar({ onCopy, onShowUsage }: MessageActionBarProps) {
+function MessageActionBar({ onCopy, onShowUsage, onUndoFiles }: MessageActionBarProps) {
   const [copyFeedback, setCopyFeedback] = useState(false);
 
   const handleCopy = (e: React.MouseEvent) => {
@@ -24,6 +25,13 @@ function MessageActionBar({ onCopy, onShowUsage }: MessageActionBarProps) {
     }
   };
 
+  const handleUndoFiles = (e: React.MouseEvent) => {
+    e.stopPropagation();
+    if (onUndoFiles) {
+      onUndoFiles();
+    }
+  };
+
   return (
     <div
       className="message-action-bar"
@@ -139,6 +147,46 @@ function MessageActionBar({ onCopy, onShowUsage }: MessageActionBarProps) {
           </svg>
         </button>
       )}
+      {onUndoFiles && (
+        <button
+          onClick={handleUndoFiles}
+          title="Undo file changes from here"
+          data-testid="undo-files-button"
+          style={{
+            display: "flex",
+            alignItems: "center",
+            justifyContent: "center",
+            width: "24px",
+            height: "24px",
+            borderRadius: "4px",
+            border: "none",
+            background: "transparent",
+            cursor: "pointer",
+            color: "var(--text-secondary)",
+            transition: "background-color 0.15s",
+          }}
+          onMouseEnter={(e) => {
+            e.currentTarget.style.backgroundColor = "var(--bg-tertiary)";
+          }}
+          onMouseLeave={(e) => {
+            e.currentTarget.style.backgroundColor = "transparent";
+          }}
+        >
+          <svg
+            width="16"
+            height="16"
+            viewBox="0 0 24 24"
+            fill="none"
+            stroke="currentColor"
+            strokeWidth="2"
+            strokeLinecap="round"
+            strokeLinejoin="round"
+          >
+            <polyline points="1 4 1 10 7 10"></polyline>
+            <path d="M3.51 15a9 9 0 1 0 2.13-9.36L1 10"></path>
+          </svg>
+        </button>
+      )}
     </div>
   );
 }
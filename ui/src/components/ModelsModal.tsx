@@ -13,13 +13,14 @@ interface ModelsModalProps {
   onModelsChanged?: () => void;
 }
 
-type ProviderType = "anthropic" | "openai" | "openai-responses" | "gemini";
+type ProviderType = "anthropic" | "openai" | "openai-responses" | "gemini" | "ollama";
 
 const DEFAULT_ENDPOINTS: Record<ProviderType, string> = {
   anthropic: "https://api.anthropic.com/v1/messages",
   openai: "https://api.openai.com/v1",
   "openai-responses": "https://api.openai.com/v1",
   gemini: "https://generativelanguage.googleapis.com/v1beta",
+  ollama: "http://localhost:11434",
 };
 
 const PROVIDER_LABELS: Record<ProviderType, string> = {
@@ -27,6 +28,7 @@ const PROVIDER_LABELS: Record<ProviderType, string> = {
   openai: "OpenAI (Chat API)",
   "openai-responses": "OpenAI (Responses API)",
   gemini: "Google Gemini",
+  ollama: "Ollama (local)",
 };
 
 const DEFAULT_MODELS: Record<ProviderType, { name: string; model_name: string }[]> = {
@@ -41,8 +43,15 @@ const DEFAULT_MODELS: Record<ProviderType, { name: string; model_name: string }[
     { name: "Gemini 3 Pro", model_name: "gemini-3-pro-preview" },
     { name: "Gemini 3 Flash", model_name: "gemini-3-flash-preview" },
   ],
+  ollama: [
+    { name: "Qwen3 Coder 30B", model_name: "qwen3-coder:30b" },
+    { name: "gpt-oss 20B", model_name: "gpt-oss:20b" },
+  ],
 };
 
+// Local providers run without an API key
+const needsAPIKey = (provider: ProviderType) => provider !== "ollama";
+
 // Built-in model info from init data
 interface BuiltInModel {
   id: string;
@@ -148,7 +157,7 @@ function ModelsModal({ isOpen, onClose, onModelsChanged }: ModelsModalProps) {
       setTestResult({ success: false, message: "Model name is required" });
       return;
     }
-    if (!form.api_key && !editingModelId) {
+    if (needsAPIKey(form.provider_type) && !form.api_key && !editingModelId) {
       setTestResult({ success: false, message: "API key is required" });
       return;
     }
@@ -163,6 +172,7 @@ function ModelsModal({ isOpen, onClose, onModelsChanged }: ModelsModalProps) {
         endpoint: form.endpoint,
         api_key: form.api_key,
         model_name: form.model_name,
+        tags: form.tags,
       };
       const result = await customModelsApi.testCustomModel(request);
       setTestResult(result);
@@ -177,7 +187,11 @@ function ModelsModal({ isOpen, onClose, onModelsChanged }: ModelsModalProps) {
   };
 
   const handleSave = async () => {
-    if (!form.display_name || !form.api_key || !form.model_name) {
+    if (
+      !form.display_name ||
+      (needsAPIKey(form.provider_type) && !form.api_key) ||
+      !form.model_name
+    ) {
       setError("Display name, API key, and model name are required");
       return;
     }
@@ -301,18 +315,16 @@ function ModelsModal({ isOpen, onClose, onModelsChanged }: ModelsModalProps) {
             <div className="form-group">
               <label>Provider / API Format</label>
               <div className="provider-buttons">
-                {(["anthropic", "openai", "openai-responses", "gemini"] as ProviderType[]).map(
-                  (p) => (
-                    <button
-                      key={p}
-                      type="button"
-                      className={`provider-btn ${form.provider_type === p ? "selected" : ""}`}
-                      onClick={() => handleProviderChange(p)}
-                    >
-                      {PROVIDER_LABELS[p]}
-                    </button>
-                  ),
-                )}
+                {(Object.keys(PROVIDER_LABELS) as ProviderType[]).map((p) => (
+                  <button
+                    key={p}
+                    type="button"
+                    className={`provider-btn ${form.provider_type === p ? "selected" : ""}`}
+                    onClick={() => handleProviderChange(p)}
+                  >
+                    {PROVIDER_LABELS[p]}
+                  </button>
+                ))}
               </div>
             </div>
 
@@ -391,7 +403,7 @@ function ModelsModal({ isOpen, onClose, onModelsChanged }: ModelsModalProps) {
                 type="text"
                 value={form.api_key}
                 onChange={(e) => setForm((prev) => ({ ...prev, api_key: e.target.value }))}
-                placeholder="Enter API key"
+                placeholder={needsAPIKey(form.provider_type) ? "Enter API key" : "Not required"}
                 className="form-input"
                 autoComplete="off"
               />
@@ -442,7 +454,8 @@ function ModelsModal({ isOpen, onClose, onModelsChanged }: ModelsModalProps) {
                     <span className="info-tooltip">
                       Comma-separated tags for this model. Use "slug" to mark this model for
                       generating conversation titles. If no model has the "slug" tag, the
-                      conversation's model will be used.
+                      conversation's model will be used. Use "reasoning" on an OpenAI-compatible
+                      model that accepts reasoning_effort.
                     </span>
                   )}
                 </span>
@@ -472,11 +485,15 @@ function ModelsModal({ isOpen, onClose, onModelsChanged }: ModelsModalProps) {
                 type="button"
                 className="btn-secondary"
                 onClick={handleTest}
-                disabled={testing || (!form.api_key && !editingModelId) || !form.model_name}
+                disabled={
+                  testing ||
+                  (needsAPIKey(form.provider_type) && !form.api_key && !editingModelId) ||
+                  !form.model_name
+                }
                 title={
                   !form.model_name
                     ? "Enter model name to test"
-                    : !form.api_key && !editingModelId
+                    : needsAPIKey(form.provider_type) && !form.api_key && !editingModelId
                       ? "Enter API key to test"
                       : ""
                 }
@@ -487,7 +504,11 @@ function ModelsModal({ isOpen, onClose, onModelsChanged }: ModelsModalProps) {
                 type="button"
                 className="btn-primary"
                 onClick={handleSave}
-                disabled={!form.display_name || !form.api_key || !form.model_name}
+                disabled={
+                  !form.display_name ||
+                  (needsAPIKey(form.provider_type) && !form.api_key) ||
+                  !form.model_name
+                }
               >
                 {editingModelId ? "Save" : "Add Model"}
               </button>
@@ -0,0 +1,43 @@
+import React from "react";
+
+// Levels accepted by the server; "" leaves the model's default in place.
+const THINKING_LEVELS = [
+  { value: "", label: "Default" },
+  { value: "off", label: "Off" },
+  { value: "minimal", label: "Minimal" },
+  { value: "low", label: "Low" },
+  { value: "medium", label: "Medium" },
+  { value: "high", label: "High" },
+];
+
+interface ThinkingLevelSelectProps {
+  value: string;
+  onChange: (level: string) => void;
+  disabled?: boolean;
+  title?: string;
+}
+
+function ThinkingLevelSelect({
+  value,
+  onChange,
+  disabled = false,
+  title,
+}: ThinkingLevelSelectProps) {
+  return (
+    <select
+      className="thinking-level-select"
+      value={value}
+      onChange={(e) => onChange(e.target.value)}
+      disabled={disabled}
+      title={title}
+    >
+      {THINKING_LEVELS.map((level) => (
+        <option key={level.value} value={level.value}>
+          {level.label}
+        </option>
+      ))}
+    </select>
+  );
+}
+
+export default ThinkingLevelSelect;
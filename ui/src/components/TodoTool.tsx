@@ -0,0 +1,136 @@
+import React, { useState } from "react";
+import { LLMContent, Todo } from "../types";
+
+interface TodoDisplayData {
+  todos: Todo[];
+}
+
+const STATUS_MARKERS: Record<Todo["status"], string> = {
+  pending: "○",
+  in_progress: "◐",
+  done: "●",
+};
+
+// TodoChecklist renders a task list; it is shared by the todo_write tool and
+// the conversation's task panel.
+export function TodoChecklist({ todos }: { todos: Todo[] }) {
+  return (
+    <ul className="todo-checklist">
+      {todos.map((todo) => (
+        <li key={todo.id} className={`todo-item todo-${todo.status}`}>
+          <span className="todo-marker" aria-label={todo.status}>
+            {STATUS_MARKERS[todo.status] || STATUS_MARKERS.pending}
+          </span>
+          <span className="todo-content">{todo.content}</span>
+        </li>
+      ))}
+    </ul>
+  );
+}
+
+// todoProgress summarizes a task list, e.g. "2/5".
+export function todoProgress(todos: Todo[]): string {
+  return `${todos.filter((t) => t.status === "done").length}/${todos.length}`;
+}
+
+interface TodoToolProps {
+  // For tool_use (pending state)
+  toolInput?: unknown; // { todos: Array<{ id?: string, content: string, status: string }> }
+  isRunning?: boolean;
+
+  // For tool_result (completed state)
+  toolResult?: LLMContent[];
+  hasError?: boolean;
+  executionTime?: string;
+  display?: unknown; // TodoDisplayData from the backend
+}
+
+function TodoTool({
+  toolInput,
+  isRunning,
+  toolResult,
+  hasError,
+  executionTime,
+  display,
+}: TodoToolProps) {
+  const [isExpanded, setIsExpanded] = useState(true);
+
+  const displayData: TodoDisplayData | null =
+    display && typeof display === "object" && "todos" in display
+      ? (display as TodoDisplayData)
+      : null;
+
+  const input =
+    typeof toolInput === "object" && toolInput !== null ? (toolInput as { todos?: unknown }) : {};
+  const requested = Array.isArray(input.todos) ? input.todos.length : 0;
+
+  const resultText =
+    toolResult
+      ?.map((r) => r.Text)
+      .filter(Boolean)
+      .join("") || "";
+
+  const isComplete = !isRunning && toolResult !== undefined;
+  const todos = displayData?.todos ?? [];
+
+  return (
+    <div className="tool" data-testid={isComplete ? "tool-call-completed" : "tool-call-running"}>
+      <div className="tool-header" onClick={() => setIsExpanded(!isExpanded)}>
+        <div className="tool-summary">
+          <span className={`tool-emoji ${isRunning ? "running" : ""}`}>📋</span>
+          <span className="tool-command">
+            {isComplete && displayData
+              ? todos.length > 0
+                ? `tasks ${todoProgress(todos)} done`
+                : "tasks cleared"
+              : `tasks (${requested})`}
+          </span>
+          {executionTime && <span className="tool-time">{executionTime}</span>}
+          {isComplete && hasError && <span className="tool-error">✗</span>}
+          {isComplete && !hasError && <span className="tool-success">✓</span>}
+        </div>
+        <button
+          className="tool-toggle"
+          aria-label={isExpanded ? "Collapse" : "Expand"}
+          aria-expanded={isExpanded}
+        >
+          <svg
+            width="12"
+            height="12"
+            viewBox="0 0 12 12"
+            fill="none"
+            xmlns="http://www.w3.org/2000/svg"
+            style={{
+              transform: isExpanded ? "rotate(90deg)" : "rotate(0deg)",
+              transition: "transform 0.2s",
+            }}
+          >
+            <path
+              d="M4.5 3L7.5 6L4.5 9"
+              stroke="currentColor"
+              strokeWidth="1.5"
+              strokeLinecap="round"
+              strokeLinejoin="round"
+            />
+          </svg>
+        </button>
+      </div>
+
+      {isExpanded && isComplete && (
+        <div className="tool-details">
+          {displayData && !hasError ? (
+            todos.length > 0 && <TodoChecklist todos={todos} />
+          ) : (
+            <div className="tool-section">
+              <div className={`tool-code ${hasError ? "error" : ""}`}>
+                {resultText || "(no output)"}
+              </div>
+            </div>
+          )}
+        </div>
+      )}
+    </div>
+  );
+}
+
+export default TodoTool;
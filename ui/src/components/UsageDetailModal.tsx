@@ -119,6 +119,12 @@ function UsageDetailModal({ usage, durationMs, onClose }: UsageDetailModalProps)
               <div style={{ color: "#1f2937" }}>{usage.model}</div>
             </>
           )}
+          {usage.fallback_from && (
+            <>
+              <div style={{ color: "#6b7280", fontWeight: "500" }}>Fallback From:</div>
+              <div style={{ color: "#b45309" }}>{usage.fallback_from}</div>
+            </>
+          )}
           <div style={{ color: "#6b7280", fontWeight: "500" }}>Input Tokens:</div>
           <div style={{ color: "#1f2937" }}>{usage.input_tokens.toLocaleString()}</div>
           {usage.cache_read_input_tokens > 0 && (
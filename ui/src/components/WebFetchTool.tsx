@@ -0,0 +1,129 @@
+import React, { useState } from "react";
+import { LLMContent } from "../types";
+
+interface WebFetchDisplayData {
+  url: string;
+  title?: string;
+  content_type?: string;
+  page: number;
+  pages: number;
+  cached?: boolean;
+}
+
+interface WebFetchToolProps {
+  // For tool_use (pending state)
+  toolInput?: unknown; // { url: string, page?: number, refresh?: boolean }
+  isRunning?: boolean;
+
+  // For tool_result (completed state)
+  toolResult?: LLMContent[];
+  hasError?: boolean;
+  executionTime?: string;
+  display?: unknown; // WebFetchDisplayData from the backend
+}
+
+function WebFetchTool({
+  toolInput,
+  isRunning,
+  toolResult,
+  hasError,
+  executionTime,
+  display,
+}: WebFetchToolProps) {
+  const [isExpanded, setIsExpanded] = useState(false);
+
+  const input =
+    typeof toolInput === "object" && toolInput !== null
+      ? (toolInput as { url?: unknown; page?: unknown })
+      : {};
+  const url = typeof input.url === "string" ? input.url : "";
+
+  const displayData: WebFetchDisplayData | null =
+    display && typeof display === "object" && "url" in display
+      ? (display as WebFetchDisplayData)
+      : null;
+
+  const resultText =
+    toolResult
+      ?.map((r) => r.Text)
+      .filter(Boolean)
+      .join("") || "";
+
+  const isComplete = !isRunning && toolResult !== undefined;
+
+  return (
+    <div className="tool" data-testid={isComplete ? "tool-call-completed" : "tool-call-running"}>
+      <div className="tool-header" onClick={() => setIsExpanded(!isExpanded)}>
+        <div className="tool-summary">
+          <span className={`tool-emoji ${isRunning ? "running" : ""}`}>📄</span>
+          <span className="tool-command">
+            fetch {(displayData?.title && !hasError ? displayData.title : url) || "..."}
+          </span>
+          {isComplete && displayData && !hasError && (
+            <span className="tool-time">
+              {displayData.pages > 1 && `page ${displayData.page}/${displayData.pages}`}
+              {displayData.cached && (displayData.pages > 1 ? " · cached" : "cached")}
+            </span>
+          )}
+          {isComplete && hasError && <span className="tool-error">✗</span>}
+          {isComplete && !hasError && <span className="tool-success">✓</span>}
+        </div>
+        <button
+          className="tool-toggle"
+          aria-label={isExpanded ? "Collapse" : "Expand"}
+          aria-expanded={isExpanded}
+        >
+          <svg
+            width="12"
+            height="12"
+            viewBox="0 0 12 12"
+            fill="none"
+            xmlns="http://www.w3.org/2000/svg"
+            style={{
+              transform: isExpanded ? "rotate(90deg)" : "rotate(0deg)",
+              transition: "transform 0.2s",
+            }}
+          >
+            <path
+              d="M4.5 3L7.5 6L4.5 9"
+              stroke="currentColor"
+              strokeWidth="1.5"
+              strokeLinecap="round"
+              strokeLinejoin="round"
+            />
+          </svg>
+        </button>
+      </div>
+
+      {isExpanded && (
+        <div className="tool-details">
+          <div className="tool-section">
+            <div className="tool-label">
+              URL:
+              {executionTime && <span className="tool-time">{executionTime}</span>}
+            </div>
+            <div className="tool-code">
+              {url ? (
+                <a href={displayData?.url || url} target="_blank" rel="noopener noreferrer">
+                  {displayData?.url || url}
+                </a>
+              ) : (
+                "(no URL)"
+              )}
+            </div>
+          </div>
+          {isComplete && (
+            <div className="tool-section">
+              <div className="tool-label">{hasError ? "Error:" : "Content:"}</div>
+              <div className={`tool-code ${hasError ? "error" : ""}`}>
+                {resultText || "(no content)"}
+              </div>
+            </div>
+          )}
+        </div>
+      )}
+    </div>
+  );
+}
+
+export default WebFetchTool;
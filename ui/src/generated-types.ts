@@ -13,6 +13,9 @@ export interface Conversation {
   archived: boolean;
   parent_conversation_id: string | null;
   model: string | null;
+  thinking_level: string | null;
+  patch_diagnostics: boolean;
+  sandbox: boolean;
 }
 
 export interface Usage {
@@ -22,6 +25,7 @@ export interface Usage {
   output_tokens: number;
   cost_usd: number;
   model?: string;
+  fallback_from?: string;
   start_time?: string | null;
   end_time?: string | null;
 }
@@ -70,6 +74,9 @@ export interface ConversationWithStateForTS {
   archived: boolean;
   parent_conversation_id: string | null;
   model: string | null;
+  thinking_level: string | null;
+  patch_diagnostics: boolean;
+  sandbox: boolean;
   working: boolean;
 }
 
@@ -8,6 +8,8 @@ import {
   GitFileDiff,
   VersionInfo,
   CommitInfo,
+  CheckpointTurn,
+  RestoreCheckpointResponse,
 } from "../types";
 
 class ApiService {
@@ -32,6 +34,7 @@ class ApiService {
       source?: string;
       ready: boolean;
       max_context_tokens?: number;
+      fallbacks?: string[];
     }>
   > {
     const response = await fetch(`${this.baseUrl}/models`);
@@ -124,6 +127,80 @@ class ApiService {
     }
   }
 
+  async setThinkingLevel(conversationId: string, thinkingLevel: string): Promise<Conversation> {
+    const response = await fetch(`${this.baseUrl}/conversation/${conversationId}/thinking-level`, {
+      method: "POST",
+      headers: this.postHeaders,
+      body: JSON.stringify({ thinking_level: thinkingLevel }),
+    });
+    if (!response.ok) {
+      throw new Error(`Failed to set thinking level: ${response.statusText}`);
+    }
+    return response.json();
+  }
+
+  async setPatchDiagnostics(conversationId: string, enabled: boolean): Promise<Conversation> {
+    const response = await fetch(
+      `${this.baseUrl}/conversation/${conversationId}/patch-diagnostics`,
+      {
+        method: "POST",
+        headers: this.postHeaders,
+        body: JSON.stringify({ enabled }),
+      },
+    );
+    if (!response.ok) {
+      throw new Error(`Failed to set patch diagnostics: ${response.statusText}`);
+    }
+    return response.json();
+  }
+
+  async setSandbox(conversationId: string, enabled: boolean): Promise<Conversation> {
+    const response = await fetch(`${this.baseUrl}/conversation/${conversationId}/sandbox`, {
+      method: "POST",
+      headers: this.postHeaders,
+      body: JSON.stringify({ enabled }),
+    });
+    if (!response.ok) {
+      const text = await response.text();
+      throw new Error(text.trim() || `Failed to set sandbox: ${response.statusText}`);
+    }
+    return response.json();
+  }
+
+  async getTodos(conversationId: string): Promise<Todo[]> {
+    const response = await fetch(`${this.baseUrl}/conversation/${conversationId}/todos`);
+    if (!response.ok) {
+      throw new Error(`Failed to get todos: ${response.statusText}`);
+    }
+    return response.json();
+  }
+
+  async getCheckpoints(conversationId: string): Promise<CheckpointTurn[]> {
+    const response = await fetch(`${this.baseUrl}/conversation/${conversationId}/checkpoints`);
+    if (!response.ok) {
+      throw new Error(`Failed to get checkpoints: ${response.statusText}`);
+    }
+    return response.json();
+  }
+
+  async restoreCheckpoint(
+    conversationId: string,
+    messageId: string,
+  ): Promise<RestoreCheckpointResponse> {
+    const response = await fetch(
+      `${this.baseUrl}/conversation/${conversationId}/checkpoints/${messageId}/restore`,
+      {
+        method: "POST",
+        headers: this.postHeaders,
+      },
+    );
+    if (!response.ok) {
+      const text = await response.text();
+      throw new Error(text.trim() || `Failed to restore checkpoint: ${response.statusText}`);
+    }
+    return response.json();
+  }
+
   createMessageStream(conversationId: string, lastSequenceId?: number): EventSource {
     let url = `${this.baseUrl}/conversation/${conversationId}/stream`;
     if (lastSequenceId !== undefined && lastSequenceId >= 0) {
@@ -360,7 +437,7 @@ export const api = new ApiService();
 export interface CustomModel {
   model_id: string;
   display_name: string;
-  provider_type: "anthropic" | "openai" | "openai-responses" | "gemini";
+  provider_type: "anthropic" | "openai" | "openai-responses" | "gemini" | "ollama";
   endpoint: string;
   api_key: string;
   model_name: string;
@@ -370,7 +447,7 @@ export interface CustomModel {
 
 export interface CreateCustomModelRequest {
   display_name: string;
-  provider_type: "anthropic" | "openai" | "openai-responses" | "gemini";
+  provider_type: "anthropic" | "openai" | "openai-responses" | "gemini" | "ollama";
   endpoint: string;
   api_key: string;
   model_name: string;
@@ -380,10 +457,11 @@ export interface CreateCustomModelRequest {
 
 export interface TestCustomModelRequest {
   model_id?: string; // If provided with empty api_key, use stored key
-  provider_type: "anthropic" | "openai" | "openai-responses" | "gemini";
+  provider_type: "anthropic" | "openai" | "openai-responses" | "gemini" | "ollama";
   endpoint: string;
   api_key: string;
   model_name: string;
+  tags?: string; // Comma-separated tags
 }
 
 class CustomModelsApi {
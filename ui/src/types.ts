@@ -37,6 +37,7 @@ export interface LLMContent {
   ToolError?: boolean;
   // Other fields from Go struct
   MediaType?: string;
+  Title?: string;
   Thinking?: string;
   Data?: string;
   Signature?: string;
@@ -54,12 +55,14 @@ export interface Model {
   source?: string; // Human-readable source (e.g., "exe.dev gateway", "$ANTHROPIC_API_KEY")
   ready: boolean;
   max_context_tokens?: number;
+  fallbacks?: string[]; // Models tried, in order, if this one is overloaded
 }
 
 export interface ChatRequest {
   message: string;
   model?: string;
   cwd?: string;
+  thinking_level?: string;
 }
 // Notification event types
 export type NotificationEventType = "agent_done" | "agent_error";
@@ -174,6 +177,34 @@ export interface CommitInfo {
   date: string;
 }
 
+// An item of a conversation's task list, kept by the todo_write tool
+export interface Todo {
+  id: string;
+  content: string;
+  status: "pending" | "in_progress" | "done";
+}
+
+// File checkpoints saved before agent edits, grouped by the user message
+// that started the turn
+export interface CheckpointTurn {
+  message_id: string;
+  sequence_id: number;
+  files: Array<{
+    path: string;
+    existed: boolean;
+    // True if the file was too large to checkpoint; restoring is refused
+    omitted?: boolean;
+    created_at: string;
+  }>;
+}
+
+export interface RestoreCheckpointResponse {
+  files: Array<{
+    path: string;
+    action: "restored" | "deleted";
+  }>;
+}
+
 // Helper to check if a message is a distill status message
 export function isDistillStatusMessage(message: Message): boolean {
   if (message.type !== "system" || !message.user_data) return false;